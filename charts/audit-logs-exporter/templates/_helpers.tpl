@@ -57,3 +57,113 @@ Producer version — falls back to chart appVersion.
 {{- define "audit-logs-exporter.producerVersion" -}}
 {{- .Values.producerVersion | default .Chart.AppVersion }}
 {{- end }}
+
+{{/*
+Container environment shared by the export and retention CronJobs.
+*/}}
+{{- define "audit-logs-exporter.env" -}}
+# ── VictoriaLogs ──────────────────────────────────────────
+- name: VLOGS_URL
+  value: {{ .Values.vlogsURL | quote }}
+# ── S3 target ─────────────────────────────────────────────
+- name: S3_ENDPOINT
+  value: {{ .Values.s3.endpoint | quote }}
+- name: S3_BUCKET
+  value: {{ .Values.s3.bucket | quote }}
+- name: S3_PREFIX
+  value: {{ .Values.s3.prefix | quote }}
+- name: S3_REGION
+  value: {{ .Values.s3.region | quote }}
+- name: S3_USE_PATH_STYLE
+  value: {{ .Values.s3.usePathStyle | quote }}
+- name: S3_FORCE_HTTP
+  value: {{ .Values.s3.forceHTTP | quote }}
+# Credentials from Secret — omitted when using the default AWS
+# credential chain (IRSA, EC2 instance profile, etc.).
+{{- if and .Values.s3.credentials.accessKey (not .Values.s3.credentials.secretKey) }}
+{{- fail "s3.credentials.secretKey must be set when s3.credentials.accessKey is set" }}
+{{- end }}
+{{- if and .Values.s3.credentials.secretKey (not .Values.s3.credentials.accessKey) }}
+{{- fail "s3.credentials.accessKey must be set when s3.credentials.secretKey is set" }}
+{{- end }}
+{{- if or .Values.s3.existingSecret (and .Values.s3.credentials.accessKey .Values.s3.credentials.secretKey) }}
+- name: S3_ACCESS_KEY
+  valueFrom:
+    secretKeyRef:
+      name: {{ include "audit-logs-exporter.secretName" . }}
+      key: S3_ACCESS_KEY
+- name: S3_SECRET_KEY
+  valueFrom:
+    secretKeyRef:
+      name: {{ include "audit-logs-exporter.secretName" . }}
+      key: S3_SECRET_KEY
+{{- end }}
+# ── KMS signing ───────────────────────────────────────────
+- name: KMS_PROVIDER
+  value: {{ .Values.kms.provider | quote }}
+- name: KMS_KEY_ID
+  value: {{ .Values.kms.keyID | quote }}
+{{- if eq .Values.kms.provider "aws" }}
+{{- with .Values.kms.region }}
+- name: KMS_REGION
+  value: {{ . | quote }}
+{{- end }}
+{{- with .Values.kms.endpoint }}
+- name: KMS_ENDPOINT
+  value: {{ . | quote }}
+{{- end }}
+- name: KMS_SIGNING_ALGORITHM
+  value: {{ .Values.kms.signingAlgorithm | quote }}
+{{- end }}
+{{- if eq .Values.kms.provider "vault" }}
+{{- if not (and .Values.kms.vault.address .Values.kms.vault.tokenSecret) }}
+{{- fail "kms.vault.address and kms.vault.tokenSecret must be set when kms.provider is vault" }}
+{{- end }}
+- name: VAULT_ADDR
+  value: {{ .Values.kms.vault.address | quote }}
+- name: VAULT_TRANSIT_MOUNT
+  value: {{ .Values.kms.vault.transitMount | quote }}
+{{- with .Values.kms.vault.namespace }}
+- name: VAULT_NAMESPACE
+  value: {{ . | quote }}
+{{- end }}
+- name: VAULT_TOKEN
+  valueFrom:
+    secretKeyRef:
+      name: {{ .Values.kms.vault.tokenSecret }}
+      key: VAULT_TOKEN
+{{- end }}
+# ── Export behaviour ──────────────────────────────────────
+- name: STREAMS
+  value: {{ .Values.streams | quote }}
+{{- if .Values.tenants }}
+- name: TENANTS
+  value: {{ .Values.tenants | quote }}
+{{- end }}
+- name: EXPORT_DELAY
+  value: {{ .Values.exportDelay | quote }}
+- name: CATCHUP_HOURS
+  value: {{ .Values.catchupHours | quote }}
+- name: COMPLIANCE_MODE
+  value: {{ .Values.complianceMode | quote }}
+# ── Producer metadata ─────────────────────────────────────
+- name: PRODUCER_NAME
+  value: {{ .Values.producerName | quote }}
+- name: PRODUCER_VERSION
+  value: {{ include "audit-logs-exporter.producerVersion" . | quote }}
+# ── Run report and metrics ────────────────────────────────
+- name: RUN_REPORT
+  value: {{ .Values.runReport | quote }}
+{{- if .Values.metrics.pushgatewayURL }}
+- name: PUSHGATEWAY_URL
+  value: {{ .Values.metrics.pushgatewayURL | quote }}
+{{- end }}
+{{- if .Values.metrics.remoteWriteURL }}
+- name: REMOTE_WRITE_URL
+  value: {{ .Values.metrics.remoteWriteURL | quote }}
+{{- end }}
+{{- if .Values.retention.policy }}
+- name: RETENTION_POLICY
+  value: {{ .Values.retention.policy | quote }}
+{{- end }}
+{{- end }}
@@ -50,3 +50,94 @@ Name of the Secret holding S3 credentials.
   {{- .Values.s3.secretName | default (printf "%s-s3-credentials" (include "cold-storage-exporter.fullname" .)) }}
 {{- end }}
 {{- end }}
+
+{{/*
+Container environment shared by the export and retention CronJobs.
+*/}}
+{{- define "cold-storage-exporter.env" -}}
+# ── Source endpoints ───────────────────────────────────────
+- name: VM_URL
+  value: {{ .Values.vmURL | quote }}
+- name: VLOGS_URL
+  value: {{ .Values.vlogsURL | quote }}
+- name: VTRACES_URL
+  value: {{ .Values.vtracesURL | quote }}
+# ── Sources ───────────────────────────────────────────────
+- name: SOURCES
+  value: {{ .Values.sources | quote }}
+# ── Tenant / cluster filters ───────────────────────────────
+{{- if .Values.tenants }}
+- name: TENANTS
+  value: {{ .Values.tenants | quote }}
+{{- end }}
+{{- if .Values.clusters }}
+- name: CLUSTERS
+  value: {{ .Values.clusters | quote }}
+{{- end }}
+# ── S3 target ─────────────────────────────────────────────
+- name: S3_ENDPOINT
+  value: {{ .Values.s3.endpoint | quote }}
+- name: S3_BUCKET
+  value: {{ .Values.s3.bucket | quote }}
+- name: S3_PREFIX
+  value: {{ .Values.s3.prefix | quote }}
+- name: S3_REGION
+  value: {{ .Values.s3.region | quote }}
+- name: S3_USE_PATH_STYLE
+  value: {{ .Values.s3.usePathStyle | quote }}
+- name: S3_FORCE_HTTP
+  value: {{ .Values.s3.forceHTTP | quote }}
+# Credentials from Secret — omitted when using the default AWS
+# credential chain (IRSA, EC2 instance profile, etc.).
+{{- if and .Values.s3.credentials.accessKey (not .Values.s3.credentials.secretKey) }}
+{{- fail "s3.credentials.secretKey must be set when s3.credentials.accessKey is set" }}
+{{- end }}
+{{- if and .Values.s3.credentials.secretKey (not .Values.s3.credentials.accessKey) }}
+{{- fail "s3.credentials.accessKey must be set when s3.credentials.secretKey is set" }}
+{{- end }}
+{{- if or .Values.s3.existingSecret (and .Values.s3.credentials.accessKey .Values.s3.credentials.secretKey) }}
+- name: S3_ACCESS_KEY
+  valueFrom:
+    secretKeyRef:
+      name: {{ include "cold-storage-exporter.secretName" . }}
+      key: S3_ACCESS_KEY
+- name: S3_SECRET_KEY
+  valueFrom:
+    secretKeyRef:
+      name: {{ include "cold-storage-exporter.secretName" . }}
+      key: S3_SECRET_KEY
+{{- end }}
+# ── Export behaviour ──────────────────────────────────────
+- name: EXPORT_DELAY
+  value: {{ .Values.exportDelay | quote }}
+- name: CATCHUP_HOURS
+  value: {{ .Values.catchupHours | quote }}
+- name: EXPORT_CONCURRENCY
+  value: {{ .Values.concurrency.default | quote }}
+{{- range $source, $n := omit .Values.concurrency "default" }}
+- name: EXPORT_CONCURRENCY_{{ upper $source }}
+  value: {{ $n | quote }}
+{{- end }}
+# ── Upstream rate limits (queries per second, 0 = unlimited) ──
+- name: VM_RATE_LIMIT
+  value: {{ .Values.rateLimits.vm | quote }}
+- name: VLOGS_RATE_LIMIT
+  value: {{ .Values.rateLimits.vlogs | quote }}
+- name: VTRACES_RATE_LIMIT
+  value: {{ .Values.rateLimits.vtraces | quote }}
+# ── Run report and metrics ────────────────────────────────
+- name: RUN_REPORT
+  value: {{ .Values.runReport | quote }}
+{{- if .Values.metrics.pushgatewayURL }}
+- name: PUSHGATEWAY_URL
+  value: {{ .Values.metrics.pushgatewayURL | quote }}
+{{- end }}
+{{- if .Values.metrics.remoteWriteURL }}
+- name: REMOTE_WRITE_URL
+  value: {{ .Values.metrics.remoteWriteURL | quote }}
+{{- end }}
+{{- if .Values.retention.policy }}
+- name: RETENTION_POLICY
+  value: {{ .Values.retention.policy | quote }}
+{{- end }}
+{{- end }}
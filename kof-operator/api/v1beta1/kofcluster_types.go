@@ -0,0 +1,117 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
+
+// KofClusterSpec defines how the ClusterDeployment with the same name and namespace
+// takes part in KOF. It replaces the KOF labels and annotations of the ClusterDeployment,
+// which are still used for the fields left empty.
+// +kubebuilder:validation:XValidation:rule="self.role == 'child' || !has(self.regional)",message="regional is only valid for child clusters"
+// +kubebuilder:validation:XValidation:rule="self.role == 'regional' || (!has(self.domain) && !has(self.endpoints) && !has(self.http_client))",message="domain, endpoints and http_client are only valid for regional clusters"
+type KofClusterSpec struct {
+	// Role of the cluster in KOF.
+	// +kubebuilder:validation:Enum=regional;child
+	Role string `json:"role"`
+	// Regional references the regional cluster of a child cluster.
+	// If not specified, the regional cluster is discovered by location.
+	Regional *RegionalClusterRef `json:"regional,omitempty"`
+	// Domain of a regional cluster, used to derive the endpoints that are not specified.
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`
+	Domain string `json:"domain,omitempty"`
+	// Endpoints of a regional cluster, overriding the ones derived from the domain.
+	Endpoints *KofClusterEndpoints `json:"endpoints,omitempty"`
+	// HttpClient is used to read from a regional cluster, e.g. by promxy.
+	HttpClient *HTTPClientConfig `json:"http_client,omitempty"`
+}
+
+// RegionalClusterRef references the ClusterDeployment of a regional cluster.
+type RegionalClusterRef struct {
+	// Name of the regional ClusterDeployment.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+	// Namespace of the regional ClusterDeployment. If not specified, defaults to the namespace of the KofCluster.
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// KofClusterEndpoints defines the endpoints of every signal.
+type KofClusterEndpoints struct {
+	Metrics   SignalEndpoints `json:"metrics,omitempty"`
+	Logs      SignalEndpoints `json:"logs,omitempty"`
+	AuditLogs SignalEndpoints `json:"audit_logs,omitempty"`
+	Traces    SignalEndpoints `json:"traces,omitempty"`
+}
+
+// SignalEndpoints defines the endpoints to read and write a signal.
+type SignalEndpoints struct {
+	// Read is the URL to query the signal, e.g. "https://vmauth.example.com/vm/select/0/prometheus".
+	// +kubebuilder:validation:Pattern=`^https?://[^\s]+$`
+	Read string `json:"read,omitempty"`
+	// Write is the URL to send the signal, e.g. "https://vmauth.example.com/vm/insert/0/prometheus/api/v1/write".
+	// +kubebuilder:validation:Pattern=`^https?://[^\s]+$`
+	Write string `json:"write,omitempty"`
+}
+
+// Condition types reported in KofClusterStatus.Conditions
+const (
+	// KofClusterReadyCondition reports whether the KOF role of the cluster was reconciled.
+	KofClusterReadyCondition = "Ready"
+)
+
+// KofClusterStatus defines the observed state of KofCluster
+type KofClusterStatus struct {
+	// ObservedGeneration is the last spec generation processed by the controller
+	ObservedGeneration int64 `json:"observed_generation,omitempty"`
+	// Conditions describe the reconcile state of the cluster
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// Regional is the regional cluster a child cluster is placed on
+	Regional *RegionalClusterRef `json:"regional,omitempty"`
+	// Endpoints are the effective endpoints resolved from the spec, the annotations and the domain,
+	// for a child cluster these are the endpoints of its regional cluster
+	Endpoints KofClusterEndpoints `json:"endpoints,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Role",type=string,JSONPath=`.spec.role`
+// +kubebuilder:printcolumn:name="Regional",type=string,JSONPath=`.status.regional.name`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// KofCluster is the Schema for the kofclusters API
+type KofCluster struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   KofClusterSpec   `json:"spec,omitempty"`
+	Status KofClusterStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// KofClusterList contains a list of KofCluster
+type KofClusterList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KofCluster `json:"items"`
+}
@@ -56,14 +56,53 @@ type TLSConfig struct {
 	InsecureSkipVerify bool `json:"insecure_skip_verify,omitempty"`
 }
 
+// Condition types reported in PromxyServerGroupStatus.Conditions
+const (
+	// ConfigRenderedCondition reports whether the server group was rendered into the promxy config.
+	ConfigRenderedCondition = "ConfigRendered"
+	// SecretSyncedCondition reports whether the rendered config was written to the promxy secret.
+	SecretSyncedCondition = "SecretSynced"
+	// PromxyReloadedCondition reports whether promxy was reloaded after the secret was written.
+	PromxyReloadedCondition = "PromxyReloaded"
+	// TargetsHealthyCondition reports whether all targets answered the last probe.
+	TargetsHealthyCondition = "TargetsHealthy"
+)
+
 // PromxyServerGroupStatus defines the observed state of PromxyServerGroup
 type PromxyServerGroupStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// ObservedGeneration is the last spec generation processed by the controller
+	ObservedGeneration int64 `json:"observed_generation,omitempty"`
+	// Conditions describe the config sync state of the server group
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// Targets holds the last probe result for every target from the spec
+	Targets []TargetStatus `json:"targets,omitempty"`
+	// HealthyTargets is a "healthy/total" summary of the last probe results
+	HealthyTargets string `json:"healthy_targets,omitempty"`
+}
+
+// TargetStatus is the last probe result of a single server group target
+type TargetStatus struct {
+	// Target address:port as listed in the spec
+	Target string `json:"target"`
+	// Healthy is true when the last probe succeeded
+	Healthy bool `json:"healthy"`
+	// LastProbeTime is the time of the last probe
+	LastProbeTime metav1.Time `json:"last_probe_time,omitempty"`
+	// Latency of the last probe
+	Latency metav1.Duration `json:"latency,omitempty"`
+	// LastError is the error returned by the last failed probe
+	LastError string `json:"last_error,omitempty"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Cluster",type=string,JSONPath=`.spec.cluster_name`
+// +kubebuilder:printcolumn:name="Healthy",type=string,JSONPath=`.status.healthy_targets`
+// +kubebuilder:printcolumn:name="Synced",type=string,JSONPath=`.status.conditions[?(@.type=="SecretSynced")].status`
+// +kubebuilder:printcolumn:name="Reloaded",type=string,JSONPath=`.status.conditions[?(@.type=="PromxyReloaded")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // PromxyServerGroup is the Schema for the promxyservergroups API
 type PromxyServerGroup struct {
@@ -24,11 +24,15 @@ import (
 
 // VMStorageConnectionSpec defines the desired state of VMStorageConnection.
 type VMStorageConnectionSpec struct {
-	// ClusterRef references the VictoriaMetrics cluster resource (VTCluster or VLCluster)
+	// ClusterRef references the VictoriaMetrics cluster resource (VTCluster, VLCluster or VMCluster)
 	// that this storage connection should configure.
 	ClusterRef ClusterRef `json:"cluster_ref"`
 	// TargetStorageNode defines the connection details for the storage node.
 	TargetStorageNode TargetStorageNode `json:"target_storage_node"`
+	// ExcludeAfterFailures is the number of consecutive failed probes after which the storage node
+	// is excluded from the cluster until it answers again. Zero keeps the storage node always included.
+	// +kubebuilder:validation:Minimum=0
+	ExcludeAfterFailures int32 `json:"exclude_after_failures,omitempty"`
 }
 
 type TargetStorageNode struct {
@@ -38,8 +42,10 @@ type TargetStorageNode struct {
 	// object, which shall be mounted into the Application container
 	// at /etc/vm/secrets/SECRET_NAME folder. The secret should have keys
 	// "username" and "password" for the respective credentials.
+	// Not supported for VMCluster, the storage node is left out of the cluster when set.
 	Secret SecretRef `json:"secret,omitempty"`
 	// TLSConfig defines the TLS settings for connecting to the storage node.
+	// Not supported for VMCluster, the storage node is left out of the cluster when set.
 	TLSConfig TLSStorageConfig `json:"tls_config,omitempty"`
 }
 
@@ -66,16 +72,45 @@ type ClusterRef struct {
 	Name string `json:"name"`
 	// Namespace of the cluster resource. If not specified, defaults to the same namespace as the VMStorageConnection.
 	Namespace string `json:"namespace,omitempty"`
-	// Kind is the type of cluster resource to configure. Must be either "VTCluster" or "VLCluster".
-	// +kubebuilder:validation:Enum=VTCluster;VLCluster
+	// Kind is the type of cluster resource to configure. Must be one of "VTCluster", "VLCluster" or "VMCluster".
+	// +kubebuilder:validation:Enum=VTCluster;VLCluster;VMCluster
 	Kind string `json:"kind"`
 }
 
+// Condition types reported in VMStorageConnectionStatus.Conditions
+const (
+	// StorageNodeReadyCondition reports whether the storage node is valid, reachable and included in the cluster.
+	StorageNodeReadyCondition = "Ready"
+	// StorageNodeDegradedCondition reports whether the secret of the storage node is invalid or the last probe failed.
+	StorageNodeDegradedCondition = "Degraded"
+)
+
 // VMStorageConnectionStatus defines the observed state of VMStorageConnection.
-type VMStorageConnectionStatus struct{}
+type VMStorageConnectionStatus struct {
+	// ObservedGeneration is the last spec generation processed by the controller
+	ObservedGeneration int64 `json:"observed_generation,omitempty"`
+	// Conditions describe the state of the storage node
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// LastProbeTime is the time of the last probe of the storage node
+	LastProbeTime metav1.Time `json:"last_probe_time,omitempty"`
+	// LastProbeError is the error returned by the last failed probe
+	LastProbeError string `json:"last_probe_error,omitempty"`
+	// ConsecutiveFailures is the number of probes failed in a row
+	ConsecutiveFailures int32 `json:"consecutive_failures,omitempty"`
+	// Included is true when the storage node is configured in the ExtraArgs of the target cluster
+	Included bool `json:"included"`
+}
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Kind",type=string,JSONPath=`.spec.cluster_ref.kind`
+// +kubebuilder:printcolumn:name="Cluster",type=string,JSONPath=`.spec.cluster_ref.name`
+// +kubebuilder:printcolumn:name="Address",type=string,JSONPath=`.spec.target_storage_node.address`
+// +kubebuilder:printcolumn:name="Included",type=boolean,JSONPath=`.status.included`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // VMStorageConnection is the Schema for the vmstorageconnections API.
 type VMStorageConnection struct {
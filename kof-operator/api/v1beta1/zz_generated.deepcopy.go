@@ -21,6 +21,7 @@ limitations under the License.
 package v1beta1
 
 import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -72,13 +73,149 @@ func (in *HTTPClientConfig) DeepCopy() *HTTPClientConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KofCluster) DeepCopyInto(out *KofCluster) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KofCluster.
+func (in *KofCluster) DeepCopy() *KofCluster {
+	if in == nil {
+		return nil
+	}
+	out := new(KofCluster)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *KofCluster) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KofClusterEndpoints) DeepCopyInto(out *KofClusterEndpoints) {
+	*out = *in
+	out.Metrics = in.Metrics
+	out.Logs = in.Logs
+	out.AuditLogs = in.AuditLogs
+	out.Traces = in.Traces
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KofClusterEndpoints.
+func (in *KofClusterEndpoints) DeepCopy() *KofClusterEndpoints {
+	if in == nil {
+		return nil
+	}
+	out := new(KofClusterEndpoints)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KofClusterList) DeepCopyInto(out *KofClusterList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]KofCluster, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KofClusterList.
+func (in *KofClusterList) DeepCopy() *KofClusterList {
+	if in == nil {
+		return nil
+	}
+	out := new(KofClusterList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *KofClusterList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KofClusterSpec) DeepCopyInto(out *KofClusterSpec) {
+	*out = *in
+	if in.Regional != nil {
+		in, out := &in.Regional, &out.Regional
+		*out = new(RegionalClusterRef)
+		**out = **in
+	}
+	if in.Endpoints != nil {
+		in, out := &in.Endpoints, &out.Endpoints
+		*out = new(KofClusterEndpoints)
+		**out = **in
+	}
+	if in.HttpClient != nil {
+		in, out := &in.HttpClient, &out.HttpClient
+		*out = new(HTTPClientConfig)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KofClusterSpec.
+func (in *KofClusterSpec) DeepCopy() *KofClusterSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(KofClusterSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KofClusterStatus) DeepCopyInto(out *KofClusterStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Regional != nil {
+		in, out := &in.Regional, &out.Regional
+		*out = new(RegionalClusterRef)
+		**out = **in
+	}
+	out.Endpoints = in.Endpoints
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KofClusterStatus.
+func (in *KofClusterStatus) DeepCopy() *KofClusterStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KofClusterStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PromxyServerGroup) DeepCopyInto(out *PromxyServerGroup) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PromxyServerGroup.
@@ -155,6 +292,20 @@ func (in *PromxyServerGroupSpec) DeepCopy() *PromxyServerGroupSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PromxyServerGroupStatus) DeepCopyInto(out *PromxyServerGroupStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Targets != nil {
+		in, out := &in.Targets, &out.Targets
+		*out = make([]TargetStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PromxyServerGroupStatus.
@@ -167,6 +318,21 @@ func (in *PromxyServerGroupStatus) DeepCopy() *PromxyServerGroupStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RegionalClusterRef) DeepCopyInto(out *RegionalClusterRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RegionalClusterRef.
+func (in *RegionalClusterRef) DeepCopy() *RegionalClusterRef {
+	if in == nil {
+		return nil
+	}
+	out := new(RegionalClusterRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SecretRef) DeepCopyInto(out *SecretRef) {
 	*out = *in
@@ -182,6 +348,21 @@ func (in *SecretRef) DeepCopy() *SecretRef {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SignalEndpoints) DeepCopyInto(out *SignalEndpoints) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SignalEndpoints.
+func (in *SignalEndpoints) DeepCopy() *SignalEndpoints {
+	if in == nil {
+		return nil
+	}
+	out := new(SignalEndpoints)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TLSConfig) DeepCopyInto(out *TLSConfig) {
 	*out = *in
@@ -212,6 +393,23 @@ func (in *TLSStorageConfig) DeepCopy() *TLSStorageConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TargetStatus) DeepCopyInto(out *TargetStatus) {
+	*out = *in
+	in.LastProbeTime.DeepCopyInto(&out.LastProbeTime)
+	out.Latency = in.Latency
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TargetStatus.
+func (in *TargetStatus) DeepCopy() *TargetStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(TargetStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TargetStorageNode) DeepCopyInto(out *TargetStorageNode) {
 	*out = *in
@@ -235,7 +433,7 @@ func (in *VMStorageConnection) DeepCopyInto(out *VMStorageConnection) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VMStorageConnection.
@@ -308,6 +506,14 @@ func (in *VMStorageConnectionSpec) DeepCopy() *VMStorageConnectionSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VMStorageConnectionStatus) DeepCopyInto(out *VMStorageConnectionStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	in.LastProbeTime.DeepCopyInto(&out.LastProbeTime)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VMStorageConnectionStatus.
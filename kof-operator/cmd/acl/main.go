@@ -6,14 +6,26 @@ import (
 	"flag"
 	"fmt"
 	"net/http"
+	"net/url"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/k0rdent/kof/kof-operator/internal/acl/auditlog"
+	"github.com/k0rdent/kof/kof-operator/internal/acl/authz"
+	"github.com/k0rdent/kof/kof-operator/internal/acl/guardrails"
 	"github.com/k0rdent/kof/kof-operator/internal/acl/handlers"
+	"github.com/k0rdent/kof/kof-operator/internal/acl/ingest"
+	"github.com/k0rdent/kof/kof-operator/internal/acl/querycache"
+	"github.com/k0rdent/kof/kof-operator/internal/acl/tenant"
 	"github.com/k0rdent/kof/kof-operator/internal/server"
+	"github.com/k0rdent/kof/kof-operator/internal/server/auth"
 	srvhandlers "github.com/k0rdent/kof/kof-operator/internal/server/handlers"
 	"github.com/k0rdent/kof/kof-operator/internal/telemetry"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"k8s.io/client-go/kubernetes"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 )
@@ -24,6 +36,8 @@ var (
 
 const (
 	defaultShutdownTimeout = 5 * time.Second
+	// authorizationPolicyReloadInterval is the interval the authorization policy file is checked for changes at.
+	authorizationPolicyReloadInterval = 10 * time.Second
 )
 
 func main() {
@@ -31,24 +45,116 @@ func main() {
 	var enableServerCORS bool
 	var developmentMode bool
 	var httpServerPort string
+	var metricsServerPort string
 	var issuer string
 	var clientId string
 	var promxyHost string
 	var promxyScheme string
 	var logsHost string
 	var logsScheme string
+	var logsTenantStreamField bool
 	var adminEmail string
+	var tenantPolicyFile string
+	var queryLimitsFile string
+	var authorizationPolicyFile string
+	var queryCacheTTL time.Duration
+	var queryCacheMaxFreshness time.Duration
+	var queryCacheMaxBytes int64
 	var tracesHost string
 	var tracesScheme string
+	var alertmanagerHost string
+	var alertmanagerScheme string
+	var auditLogsEndpoint string
+	var ingestURL string
+	var auditLogsFlushInterval time.Duration
+	var auditLogsTrustedProxies string
+	var serviceAccountAuth bool
+	var serviceAccountAudiences string
+	var apiKeysNamespace string
 
 	flag.StringVar(&httpServerPort, "http-server-port", "9091", "The port for the ACL server.")
+	flag.StringVar(
+		&metricsServerPort,
+		"metrics-server-port",
+		"9092",
+		"The port serving the Prometheus metrics of the ACL server, empty to disable.",
+	)
 	flag.StringVar(&adminEmail, "admin-email", "", "The email address of the admin user.")
-	flag.StringVar(&issuer, "issuer", "https://dex.example.com", "The OIDC issuer URL.")
-	flag.StringVar(&clientId, "client-id", "grafana-id", "The OIDC client ID.")
+	flag.StringVar(
+		&tenantPolicyFile,
+		"tenant-policy-file",
+		"",
+		"The path to the YAML policy resolving tenants and admins from the token claims.",
+	)
+	flag.StringVar(
+		&authorizationPolicyFile,
+		"authorization-policy-file",
+		"",
+		"The path to the YAML policy granting users access to some clusters, namespaces and signals of their tenants, "+
+			"reloaded when it changes.",
+	)
+	flag.StringVar(
+		&queryLimitsFile,
+		"query-limits-file",
+		"",
+		"The path to the YAML per-tenant limits enforced on Prometheus queries before they are proxied.",
+	)
+	flag.DurationVar(
+		&queryCacheTTL,
+		"query-cache-ttl",
+		0,
+		"The time query_range results are cached for, 0 to disable the results cache.",
+	)
+	flag.DurationVar(
+		&queryCacheMaxFreshness,
+		"query-cache-max-freshness",
+		time.Minute,
+		"The age under which query_range results are not cached, as recent samples may still be ingested.",
+	)
+	flag.Int64Var(
+		&queryCacheMaxBytes,
+		"query-cache-max-bytes",
+		64<<20,
+		"The maximum size of the in-memory query_range results cache.",
+	)
+	flag.StringVar(&issuer, "issuer", "https://dex.example.com", "The comma-separated OIDC issuer URLs.")
+	flag.StringVar(
+		&clientId,
+		"client-id",
+		"grafana-id",
+		"The comma-separated OIDC client IDs, accepted as the audience of the ID tokens of any issuer.",
+	)
+	flag.BoolVar(
+		&serviceAccountAuth,
+		"service-account-auth",
+		false,
+		"Accept Kubernetes ServiceAccount tokens, validated through TokenReview. Their tenants are resolved by "+
+			"the tenant policy from the system:serviceaccount:<namespace>:<name> email and the "+
+			"system:serviceaccounts:<namespace> groups.",
+	)
+	flag.StringVar(
+		&serviceAccountAudiences,
+		"service-account-audiences",
+		"",
+		"The comma-separated audiences the ServiceAccount tokens must be issued for, the API server audiences when empty.",
+	)
+	flag.StringVar(
+		&apiKeysNamespace,
+		"api-keys-namespace",
+		"",
+		"The namespace of the Secrets labeled "+auth.APIKeyLabel+"=true holding the hashed API keys. "+
+			"API keys are disabled when empty.",
+	)
 	flag.StringVar(&promxyHost, "promxy-host", "kof-mothership-promxy:8082", "The Promxy host.")
 	flag.StringVar(&promxyScheme, "promxy-scheme", "http", "The scheme to use when connecting to Promxy (http or https).")
 	flag.StringVar(&logsHost, "logs-host", "vlselect-kof-mothership-logs-multilevel-select.kof.svc:9471", "The Logs host.")
 	flag.StringVar(&logsScheme, "logs-scheme", "http", "The scheme to use when connecting to Logs (http or https).")
+	flag.BoolVar(
+		&logsTenantStreamField,
+		"logs-tenant-stream-field",
+		false,
+		"Whether the tenant is a stream field of the logs, so that tenant queries are also restricted by a stream filter.",
+	)
 	flag.StringVar(
 		&tracesHost,
 		"traces-host",
@@ -56,6 +162,41 @@ func main() {
 		"The Traces backend host.",
 	)
 	flag.StringVar(&tracesScheme, "traces-scheme", "http", "The scheme to use when connecting to Traces (http or https).")
+	flag.StringVar(&alertmanagerHost, "alertmanager-host", "vmalertmanager-cluster:9093", "The Alertmanager host.")
+	flag.StringVar(
+		&alertmanagerScheme,
+		"alertmanager-scheme",
+		"http",
+		"The scheme to use when connecting to Alertmanager (http or https).",
+	)
+	flag.StringVar(
+		&ingestURL,
+		"ingest-url",
+		"",
+		"The base URL of the regional vmauth receiving the metrics, logs and traces written through the ACL server, "+
+			"e.g. https://vmauth.regional.example.com. Its basic auth credentials are read from the "+
+			"KOF_INGEST_USERNAME and KOF_INGEST_PASSWORD environment variables. Ingestion is disabled when empty.",
+	)
+	flag.StringVar(
+		&auditLogsEndpoint,
+		"audit-logs-endpoint",
+		"",
+		"The OTLP/HTTP logs endpoint receiving the access decisions as audit events, "+
+			"e.g. http://vlinsert-audit-logs:9481/insert/opentelemetry/v1/logs. Auditing is disabled when empty.",
+	)
+	flag.DurationVar(
+		&auditLogsFlushInterval,
+		"audit-logs-flush-interval",
+		5*time.Second,
+		"The maximum time audit events are buffered before they are sent.",
+	)
+	flag.StringVar(
+		&auditLogsTrustedProxies,
+		"audit-logs-trusted-proxies",
+		"",
+		"The comma-separated CIDRs or IPs of the reverse proxies whose X-Forwarded-For header is trusted "+
+			"for the client IP of the audit events, e.g. 10.0.0.0/8. The IP of the peer is recorded when empty.",
+	)
 	flag.DurationVar(
 		&shutdownTimeout,
 		"shutdown-timeout",
@@ -99,17 +240,91 @@ func main() {
 		},
 	})
 
-	oidcProvider, err := oidc.NewProvider(oidcCtx, issuer)
+	oidcAuthenticator, err := auth.NewOIDCAuthenticator(oidcCtx, splitList(issuer), splitList(clientId))
 	if err != nil {
-		serverLog.Error(err, "Failed to initialize OIDC provider")
+		serverLog.Error(err, "Failed to initialize OIDC authentication")
 		os.Exit(1)
 	}
+	authenticators := []server.Authenticator{oidcAuthenticator}
+
+	if serviceAccountAuth || apiKeysNamespace != "" {
+		kubeClient, err := newKubeClient()
+		if err != nil {
+			serverLog.Error(err, "Failed to create Kubernetes client")
+			os.Exit(1)
+		}
+		if apiKeysNamespace != "" {
+			authenticators = append(authenticators,
+				auth.NewAPIKeyAuthenticator(kubeClient, apiKeysNamespace, auth.DefaultAPIKeyCacheTTL))
+		}
+		if serviceAccountAuth {
+			authenticators = append(authenticators, auth.NewServiceAccountAuthenticator(
+				kubeClient, splitList(serviceAccountAudiences), auth.DefaultTokenReviewCacheTTL))
+		}
+	}
+
+	tenantPolicy := tenant.DefaultPolicy()
+	if tenantPolicyFile != "" {
+		if tenantPolicy, err = tenant.LoadPolicy(tenantPolicyFile); err != nil {
+			serverLog.Error(err, "Failed to load tenant policy")
+			os.Exit(1)
+		}
+	}
+	if adminEmail != "" {
+		tenantPolicy.AdminEmails = append(tenantPolicy.AdminEmails, adminEmail)
+	}
+
+	var authorizer authz.Authorizer
+	if authorizationPolicyFile != "" {
+		watcher, err := authz.NewWatcher(authorizationPolicyFile, serverLog.WithName("authz"))
+		if err != nil {
+			serverLog.Error(err, "Failed to load authorization policy")
+			os.Exit(1)
+		}
+		watchCtx, stopWatching := context.WithCancel(context.Background())
+		defer stopWatching()
+		go watcher.Run(watchCtx, authorizationPolicyReloadInterval)
+		authorizer = watcher
+	}
+
+	var queryGuard *guardrails.Guard
+	if queryLimitsFile != "" {
+		queryLimits, err := guardrails.LoadPolicy(queryLimitsFile)
+		if err != nil {
+			serverLog.Error(err, "Failed to load query limits")
+			os.Exit(1)
+		}
+		queryGuard = guardrails.NewGuard(queryLimits, prometheus.DefaultRegisterer)
+	}
+
+	var queryCache *querycache.Cache
+	if queryCacheTTL > 0 {
+		queryCache = querycache.New(
+			querycache.NewMemoryBackend(queryCacheMaxBytes, prometheus.DefaultRegisterer),
+			querycache.Options{TTL: queryCacheTTL, MaxFreshness: queryCacheMaxFreshness},
+			prometheus.DefaultRegisterer,
+		)
+	}
+
+	var auditRecorder auditlog.Recorder
+	var auditEmitter *auditlog.Emitter
+	if auditLogsEndpoint != "" {
+		auditEmitter = auditlog.NewEmitter(auditlog.Options{
+			Endpoint:      auditLogsEndpoint,
+			ServiceName:   "kof-acl-server",
+			FlushInterval: auditLogsFlushInterval,
+		}, serverLog.WithName("audit"), prometheus.DefaultRegisterer)
+		auditRecorder = auditEmitter
+	}
 
 	promxyConfig := handlers.Config{
-		Host:       promxyHost,
-		Scheme:     promxyScheme,
-		DevMode:    developmentMode,
-		AdminEmail: adminEmail,
+		Host:           promxyHost,
+		Scheme:         promxyScheme,
+		DevMode:        developmentMode,
+		TenantResolver: tenantPolicy,
+		Authorizer:     authorizer,
+		Guard:          queryGuard,
+		Cache:          queryCache,
 	}
 
 	promxyQueryHandler := handlers.NewPromxyQueryHandler(promxyConfig)
@@ -117,30 +332,70 @@ func main() {
 	promxyRulesHandler := handlers.NewPromxyRulesHandler(promxyConfig)
 
 	logsHandler := handlers.NewLogsHandler(handlers.Config{
-		Host:       logsHost,
-		Scheme:     logsScheme,
-		DevMode:    developmentMode,
-		AdminEmail: adminEmail,
+		Host:              logsHost,
+		Scheme:            logsScheme,
+		DevMode:           developmentMode,
+		TenantResolver:    tenantPolicy,
+		Authorizer:        authorizer,
+		TenantStreamField: logsTenantStreamField,
 	})
 
 	tracesConfig := handlers.Config{
-		Host:       tracesHost,
-		Scheme:     tracesScheme,
-		DevMode:    developmentMode,
-		AdminEmail: adminEmail,
+		Host:           tracesHost,
+		Scheme:         tracesScheme,
+		DevMode:        developmentMode,
+		TenantResolver: tenantPolicy,
+		Authorizer:     authorizer,
 	}
 
 	jaegerAPITraceHandler := handlers.NewJaegerTraceHandler(tracesConfig)
 	jaegerAPITracesHandler := handlers.NewJaegerTracesHandler(tracesConfig)
 	jaegerAPIServiceHandler := handlers.NewJaegerServicesHandler(tracesConfig)
+	jaegerAPIOperationsHandler := handlers.NewJaegerOperationsHandler(tracesConfig)
+	jaegerAPIDependenciesHandler := handlers.NewJaegerDependenciesHandler(tracesConfig)
+	tempoSearchHandler := handlers.NewTempoSearchHandler(tracesConfig)
+	tempoTraceHandler := handlers.NewTempoTraceHandler(tracesConfig)
+
+	alertmanagerHandler := handlers.NewAlertmanagerHandler(handlers.Config{
+		Host:           alertmanagerHost,
+		Scheme:         alertmanagerScheme,
+		DevMode:        developmentMode,
+		TenantResolver: tenantPolicy,
+		Authorizer:     authorizer,
+	})
+
+	var ingestConfig handlers.Config
+	if ingestURL != "" {
+		parsedIngestURL, err := url.Parse(ingestURL)
+		if err == nil && parsedIngestURL.Host == "" {
+			err = fmt.Errorf("missing host")
+		}
+		if err != nil {
+			serverLog.Error(err, "Invalid ingest URL", "url", ingestURL)
+			os.Exit(1)
+		}
+		ingestConfig = handlers.Config{
+			Host:           parsedIngestURL.Host,
+			Scheme:         parsedIngestURL.Scheme,
+			DevMode:        developmentMode,
+			TenantResolver: tenantPolicy,
+			Username:       os.Getenv("KOF_INGEST_USERNAME"),
+			Password:       os.Getenv("KOF_INGEST_PASSWORD"),
+		}
+	}
 
 	httpServer := server.NewServer(fmt.Sprintf(":%s", httpServerPort), &serverLog)
 	httpServer.Use(server.SpanNameMiddleware)
 	httpServer.Use(server.RecoveryMiddleware)
 	httpServer.Use(server.LoggingMiddleware)
+	trustedProxies, err := auditlog.ParseTrustedProxies(auditLogsTrustedProxies)
+	if err != nil {
+		serverLog.Error(err, "Invalid audit logs trusted proxies")
+		os.Exit(1)
+	}
+	httpServer.Use(auditlog.Middleware(auditRecorder, trustedProxies))
 	httpServer.Use(server.AuthenticationMiddleware(server.AuthConfig{
-		Provider:         oidcProvider,
-		ClientID:         clientId,
+		Authenticators:   authenticators,
 		SkipOnEmptyToken: developmentMode,
 	}))
 
@@ -226,6 +481,28 @@ func main() {
 		handlers.AdminProxy(res, req, promxyQueryHandler)
 	})
 
+	httpServer.Router.GET("/alertmanager/api/v2/alerts", func(res *server.Response, req *http.Request) {
+		handlers.ACLProxy(res, req, alertmanagerHandler)
+	})
+	httpServer.Router.GET("/alertmanager/api/v2/alerts/groups", func(res *server.Response, req *http.Request) {
+		handlers.ACLProxy(res, req, alertmanagerHandler)
+	})
+	httpServer.Router.GET("/alertmanager/api/v2/silences", func(res *server.Response, req *http.Request) {
+		handlers.ACLProxy(res, req, alertmanagerHandler)
+	})
+	httpServer.Router.POST("/alertmanager/api/v2/silences", func(res *server.Response, req *http.Request) {
+		handlers.ACLProxy(res, req, alertmanagerHandler)
+	})
+	httpServer.Router.GET("/alertmanager/api/v2/silence/*", func(res *server.Response, req *http.Request) {
+		handlers.ACLProxy(res, req, alertmanagerHandler)
+	})
+	httpServer.Router.DELETE("/alertmanager/api/v2/silence/*", func(res *server.Response, req *http.Request) {
+		handlers.ACLProxy(res, req, alertmanagerHandler)
+	})
+	httpServer.Router.GET("/alertmanager/api/v2/status", func(res *server.Response, req *http.Request) {
+		handlers.ACLProxy(res, req, alertmanagerHandler)
+	})
+
 	httpServer.Router.GET("/logs/*", func(res *server.Response, req *http.Request) {
 		handlers.ACLProxy(res, req, logsHandler)
 	})
@@ -236,6 +513,15 @@ func main() {
 	httpServer.Router.GET("/traces/select/jaeger/api/services", func(res *server.Response, req *http.Request) {
 		handlers.ACLProxy(res, req, jaegerAPIServiceHandler)
 	})
+	httpServer.Router.GET("/traces/select/jaeger/api/services/*/operations", func(res *server.Response, req *http.Request) {
+		handlers.ACLProxy(res, req, jaegerAPIOperationsHandler)
+	})
+	httpServer.Router.GET("/traces/select/jaeger/api/operations", func(res *server.Response, req *http.Request) {
+		handlers.ACLProxy(res, req, jaegerAPIOperationsHandler)
+	})
+	httpServer.Router.GET("/traces/select/jaeger/api/dependencies", func(res *server.Response, req *http.Request) {
+		handlers.ACLProxy(res, req, jaegerAPIDependenciesHandler)
+	})
 	httpServer.Router.GET("/traces/select/jaeger/api/traces/*", func(res *server.Response, req *http.Request) {
 		handlers.ACLProxy(res, req, jaegerAPITraceHandler)
 	})
@@ -243,8 +529,43 @@ func main() {
 		handlers.ACLProxy(res, req, jaegerAPITracesHandler)
 	})
 
+	// Tempo API subset, so that a Tempo datasource can query VictoriaTraces.
+	httpServer.Router.GET("/traces/api/echo", func(res *server.Response, req *http.Request) {
+		res.Send([]byte("echo"), http.StatusOK)
+	})
+	httpServer.Router.GET("/traces/api/search", func(res *server.Response, req *http.Request) {
+		handlers.ACLProxy(res, req, tempoSearchHandler)
+	})
+	httpServer.Router.GET("/traces/api/traces/*", func(res *server.Response, req *http.Request) {
+		handlers.ACLProxy(res, req, tempoTraceHandler)
+	})
+
+	if ingestURL != "" {
+		httpServer.Router.POST("/metrics/api/v1/write", handlers.NewRemoteWriteHandler(ingestConfig).Serve)
+		httpServer.Router.POST("/v1/metrics", handlers.NewOTLPHandler(ingestConfig, ingest.SignalMetrics).Serve)
+		httpServer.Router.POST("/v1/logs", handlers.NewOTLPHandler(ingestConfig, ingest.SignalLogs).Serve)
+		httpServer.Router.POST("/v1/traces", handlers.NewOTLPHandler(ingestConfig, ingest.SignalTraces).Serve)
+	}
+
 	httpServer.Router.NotFound(srvhandlers.NotFoundHandler)
 
+	// The metrics are served on a separate port, without authentication.
+	var metricsServer *server.Server
+	if metricsServerPort != "" {
+		metricsHandler := promhttp.Handler()
+		metricsServer = server.NewServer(fmt.Sprintf(":%s", metricsServerPort), &serverLog)
+		metricsServer.Router.GET("/metrics", func(res *server.Response, req *http.Request) {
+			metricsHandler.ServeHTTP(res.Writer, req)
+		})
+
+		go func() {
+			serverLog.Info(fmt.Sprintf("Starting metrics server on :%s", metricsServerPort))
+			if err := metricsServer.Run(); err != nil && err != http.ErrServerClosed {
+				serverLog.Error(err, "Error starting metrics server")
+			}
+		}()
+	}
+
 	serverLog.Info(fmt.Sprintf("Starting http server on :%s", httpServerPort))
 
 	if err := httpServer.Run(); err != nil {
@@ -260,4 +581,33 @@ func main() {
 		serverLog.Error(err, "Http server forced to shutdown")
 		os.Exit(1)
 	}
+	if auditEmitter != nil {
+		if err := auditEmitter.Shutdown(ctx); err != nil {
+			serverLog.Error(err, "Failed to send the remaining audit events")
+		}
+	}
+	if metricsServer != nil {
+		if err := metricsServer.Shutdown(ctx); err != nil {
+			serverLog.Error(err, "Metrics server forced to shutdown")
+		}
+	}
+}
+
+func newKubeClient() (kubernetes.Interface, error) {
+	restConfig, err := ctrl.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(restConfig)
+}
+
+// splitList splits a comma-separated flag value, skipping empty items.
+func splitList(value string) []string {
+	var items []string
+	for item := range strings.SplitSeq(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
@@ -16,17 +16,38 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/k0rdent/kof/kof-operator/internal/audit"
+	"github.com/k0rdent/kof/kof-operator/internal/retention"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "verify":
+			os.Exit(runVerify(os.Args[2:]))
+		case "retention":
+			os.Exit(runRetention(os.Args[2:]))
+		}
+	}
+
+	var printPublicKey bool
+	flag.BoolVar(&printPublicKey, "print-public-key", false,
+		"Print the PEM-encoded public key of the configured KMS signer and exit.")
+
 	opts := zap.Options{Development: false}
+	opts.BindFlags(flag.CommandLine)
+	flag.Parse()
+
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 	log := ctrl.Log.WithName("audit-logs-exporter")
 
@@ -39,7 +60,15 @@ func main() {
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
-	exporter, err := audit.NewExporter(cfg, log)
+	if printPublicKey {
+		if err := writePublicKey(ctx, cfg); err != nil {
+			log.Error(err, "failed to print public key")
+			os.Exit(1)
+		}
+		return
+	}
+
+	exporter, err := audit.NewExporter(ctx, cfg, log)
 	if err != nil {
 		log.Error(err, "failed to initialise exporter")
 		os.Exit(1)
@@ -50,3 +79,174 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// writePublicKey prints the public key used to verify manifest signatures.
+func writePublicKey(ctx context.Context, cfg *audit.Config) error {
+	signer, err := audit.NewSigner(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	pkSigner, ok := signer.(audit.PublicKeySigner)
+	if !ok {
+		return fmt.Errorf("signing algorithm %s has no public key", signer.Algorithm())
+	}
+	pub, err := pkSigner.PublicKey(ctx)
+	if err != nil {
+		return err
+	}
+	data, err := audit.MarshalPublicKeyPEM(pub)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(data)
+	return err
+}
+
+// runVerify implements the "verify" subcommand: it checks the archived windows
+// of one stream and tenant and writes the JSON report. It returns exit code 0
+// when every window is intact, 2 when integrity problems were found and 1 on errors.
+func runVerify(args []string) int {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	var (
+		stream, tenant, from, to  string
+		publicKeyPath, reportPath string
+	)
+	fs.StringVar(&stream, "stream", audit.StreamTenantAuditLog, "Stream to verify.")
+	fs.StringVar(&tenant, "tenant", "", "Tenant to verify, PLATFORM for the platform-audit-log stream.")
+	fs.StringVar(&from, "from", "", "Start of the range (RFC3339), truncated to the hour.")
+	fs.StringVar(&to, "to", "", "Exclusive end of the range (RFC3339), default: now.")
+	fs.StringVar(&publicKeyPath, "public-key", "",
+		"PEM public key file to verify signatures offline, default: the public key of the configured signer.")
+	fs.StringVar(&reportPath, "report", "", "Path of the JSON report, default: stdout.")
+
+	opts := zap.Options{Development: false}
+	opts.BindFlags(fs)
+	_ = fs.Parse(args)
+
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+	log := ctrl.Log.WithName("audit-logs-verify")
+
+	verifyOpts := audit.VerifyOptions{Stream: stream, Tenant: tenant, To: time.Now().UTC()}
+	if tenant == "" || from == "" {
+		log.Error(nil, "--tenant and --from are required")
+		return 1
+	}
+	var err error
+	if verifyOpts.From, err = time.Parse(time.RFC3339, from); err != nil {
+		log.Error(err, "invalid --from")
+		return 1
+	}
+	if to != "" {
+		if verifyOpts.To, err = time.Parse(time.RFC3339, to); err != nil {
+			log.Error(err, "invalid --to")
+			return 1
+		}
+	}
+
+	cfg, err := audit.LoadConfig()
+	if err != nil {
+		log.Error(err, "invalid configuration")
+		return 1
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	var publicKeyPEM []byte
+	if publicKeyPath != "" {
+		if publicKeyPEM, err = os.ReadFile(publicKeyPath); err != nil {
+			log.Error(err, "failed to read public key")
+			return 1
+		}
+	}
+	verifier, err := audit.NewVerifier(ctx, cfg, publicKeyPEM)
+	if err != nil {
+		log.Error(err, "failed to initialise signature verifier")
+		return 1
+	}
+	s3client, err := audit.NewS3Client(cfg)
+	if err != nil {
+		log.Error(err, "failed to create S3 client")
+		return 1
+	}
+
+	report, err := audit.NewArchiveVerifier(cfg, s3client, verifier, log).Verify(ctx, verifyOpts)
+	if err != nil {
+		log.Error(err, "verification failed")
+		return 1
+	}
+
+	out := os.Stdout
+	if reportPath != "" {
+		if out, err = os.Create(reportPath); err != nil {
+			log.Error(err, "failed to create report file")
+			return 1
+		}
+		defer func() { _ = out.Close() }()
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		log.Error(err, "failed to write report")
+		return 1
+	}
+
+	log.Info("verification complete", "windows", report.Summary.Windows, "ok", report.Summary.OK)
+	if !report.OK() {
+		return 2
+	}
+	return 0
+}
+
+// runRetention implements the "retention" subcommand: it deletes the archived
+// windows older than RETENTION_POLICY, keeping object-locked windows, and
+// writes the JSON summary to stdout.
+func runRetention(args []string) int {
+	fs := flag.NewFlagSet("retention", flag.ExitOnError)
+	var (
+		dryRun bool
+		policy string
+	)
+	fs.BoolVar(&dryRun, "dry-run", false, "Report the windows that would be deleted without deleting them.")
+	fs.StringVar(&policy, "policy", "", "Retention policy overriding RETENTION_POLICY, e.g. tenant-audit-log=7y.")
+
+	opts := zap.Options{Development: false}
+	opts.BindFlags(fs)
+	_ = fs.Parse(args)
+
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+	log := ctrl.Log.WithName("audit-logs-retention")
+
+	cfg, err := audit.LoadConfig()
+	if err != nil {
+		log.Error(err, "invalid configuration")
+		return 1
+	}
+	if policy != "" {
+		if cfg.Retention, err = retention.ParsePolicy(policy, audit.StreamTenantAuditLog, audit.StreamPlatformAuditLog); err != nil {
+			log.Error(err, "invalid --policy")
+			return 1
+		}
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	s3client, err := audit.NewS3Client(cfg)
+	if err != nil {
+		log.Error(err, "failed to create S3 client")
+		return 1
+	}
+
+	layout := retention.AuditLayout{Prefix: cfg.S3Prefix}
+	summary, err := retention.NewRetainer(s3client.Client, layout, cfg.Retention, log).
+		Run(ctx, retention.RunOptions{DryRun: dryRun})
+	if encErr := json.NewEncoder(os.Stdout).Encode(summary); encErr != nil {
+		log.Error(encErr, "failed to write summary")
+	}
+	if err != nil {
+		log.Error(err, "retention run failed")
+		return 1
+	}
+	return 0
+}
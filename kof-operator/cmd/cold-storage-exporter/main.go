@@ -16,16 +16,30 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"flag"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/k0rdent/kof/kof-operator/internal/coldstorage"
+	"github.com/k0rdent/kof/kof-operator/internal/retention"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "restore":
+			os.Exit(runRestore(os.Args[2:]))
+		case "retention":
+			os.Exit(runRetention(os.Args[2:]))
+		}
+	}
+
 	opts := zap.Options{Development: false}
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 	log := ctrl.Log.WithName("cold-storage-exporter")
@@ -50,3 +64,131 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// runRestore implements the "restore" subcommand: it pushes the exported
+// partitions of one tenant and cluster back into Victoria* targets.
+func runRestore(args []string) int {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	var (
+		opts     coldstorage.RestoreOptions
+		sources  string
+		from, to string
+	)
+	fs.StringVar(&opts.Tenant, "tenant", "", "Tenant to restore, \"default\" for data exported without a tenant label.")
+	fs.StringVar(&opts.Cluster, "cluster", "", "Cluster to restore.")
+	fs.StringVar(&from, "from", "", "Start of the range (RFC3339), truncated to the hour.")
+	fs.StringVar(&to, "to", "", "Exclusive end of the range (RFC3339), default: now.")
+	fs.StringVar(&sources, "sources", strings.Join([]string{
+		coldstorage.SourceMetrics, coldstorage.SourceLogs, coldstorage.SourceTraces,
+	}, ","), "Comma-separated list of sources to restore; sources without a target URL are skipped.")
+	fs.StringVar(&opts.VMURL, "vm-url", "",
+		"Prometheus API prefix of the target VictoriaMetrics, e.g. http://vminsert:8480/insert/0/prometheus.")
+	fs.StringVar(&opts.VLogsURL, "vlogs-url", "", "Target VictoriaLogs insert endpoint, e.g. http://vlinsert:9481.")
+	fs.StringVar(&opts.VTracesURL, "vtraces-url", "", "Target VictoriaTraces insert endpoint, e.g. http://vtinsert:10481.")
+	fs.StringVar(&opts.RestoreID, "restore-id", "",
+		"Name of the restore progress used to resume an interrupted restore, default: derived from the target URLs.")
+
+	zapOpts := zap.Options{Development: false}
+	zapOpts.BindFlags(fs)
+	_ = fs.Parse(args)
+
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&zapOpts)))
+	log := ctrl.Log.WithName("cold-storage-restore")
+
+	for _, s := range strings.Split(sources, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			opts.Sources = append(opts.Sources, s)
+		}
+	}
+	if from == "" {
+		log.Error(nil, "--from is required")
+		return 1
+	}
+	var err error
+	if opts.From, err = time.Parse(time.RFC3339, from); err != nil {
+		log.Error(err, "invalid --from")
+		return 1
+	}
+	opts.To = time.Now().UTC()
+	if to != "" {
+		if opts.To, err = time.Parse(time.RFC3339, to); err != nil {
+			log.Error(err, "invalid --to")
+			return 1
+		}
+	}
+
+	cfg, err := coldstorage.LoadConfig()
+	if err != nil {
+		log.Error(err, "invalid configuration")
+		return 1
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	s3client, err := coldstorage.NewS3Client(cfg)
+	if err != nil {
+		log.Error(err, "failed to create S3 client")
+		return 1
+	}
+
+	if _, err := coldstorage.NewRestorer(cfg, s3client, opts, log).Run(ctx, opts); err != nil {
+		log.Error(err, "restore run failed")
+		return 1
+	}
+	return 0
+}
+
+// runRetention implements the "retention" subcommand: it deletes the exported
+// partitions older than RETENTION_POLICY and writes the JSON summary to stdout.
+func runRetention(args []string) int {
+	fs := flag.NewFlagSet("retention", flag.ExitOnError)
+	var (
+		dryRun bool
+		policy string
+	)
+	fs.BoolVar(&dryRun, "dry-run", false, "Report the partitions that would be deleted without deleting them.")
+	fs.StringVar(&policy, "policy", "", "Retention policy overriding RETENTION_POLICY, e.g. metrics=400d,logs=90d.")
+
+	zapOpts := zap.Options{Development: false}
+	zapOpts.BindFlags(fs)
+	_ = fs.Parse(args)
+
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&zapOpts)))
+	log := ctrl.Log.WithName("cold-storage-retention")
+
+	cfg, err := coldstorage.LoadConfig()
+	if err != nil {
+		log.Error(err, "invalid configuration")
+		return 1
+	}
+	if policy != "" {
+		cfg.Retention, err = retention.ParsePolicy(policy,
+			coldstorage.SourceMetrics, coldstorage.SourceLogs, coldstorage.SourceTraces)
+		if err != nil {
+			log.Error(err, "invalid --policy")
+			return 1
+		}
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	s3client, err := coldstorage.NewS3Client(cfg)
+	if err != nil {
+		log.Error(err, "failed to create S3 client")
+		return 1
+	}
+
+	layout := retention.ColdStorageLayout{Prefix: cfg.S3Prefix}
+	summary, err := retention.NewRetainer(s3client, layout, cfg.Retention, log).
+		Run(ctx, retention.RunOptions{DryRun: dryRun})
+	if encErr := json.NewEncoder(os.Stdout).Encode(summary); encErr != nil {
+		log.Error(encErr, "failed to write summary")
+	}
+	if err != nil {
+		log.Error(err, "retention run failed")
+		return 1
+	}
+	return 0
+}
@@ -283,6 +283,13 @@ func main() {
 		setupLog.Error(err, "unable to create controller", "controller", "PromxyServerGroup")
 		os.Exit(1)
 	}
+	if err = mgr.Add(&controller.PromxyTargetProber{
+		Client: mgr.GetClient(),
+		Probe:  controller.ProbePromxyTarget,
+	}); err != nil {
+		setupLog.Error(err, "unable to add runnable", "runnable", "PromxyTargetProber")
+		os.Exit(1)
+	}
 	if err = (&controller.ClusterDeploymentReconciler{
 		Client: mgr.GetClient(),
 		Scheme: mgr.GetScheme(),
@@ -306,8 +313,9 @@ func main() {
 		os.Exit(1)
 	}
 	if err := (&controller.VMStorageConnectionReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:           mgr.GetClient(),
+		Scheme:           mgr.GetScheme(),
+		StorageNodeProbe: controller.ProbeStorageNode,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "VMStorageConnection")
 		os.Exit(1)
@@ -0,0 +1,271 @@
+// Package auditlog records the access decisions of the ACL server as audit
+// events of the platform audit-log stream, archived by the audit exporter.
+//
+// Middleware assigns each request a correlation ID, propagated to the backend
+// requests, and Record emits the decisions made while handling it.
+package auditlog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/k0rdent/kof/kof-operator/internal/audit"
+	"github.com/k0rdent/kof/kof-operator/internal/server"
+)
+
+// CorrelationIDHeader carries the correlation ID of a request, both from the
+// client when set and to the backends.
+const CorrelationIDHeader = "X-Correlation-ID"
+
+// maxCorrelationIDLength bounds the client-provided correlation IDs.
+const maxCorrelationIDLength = 128
+
+// Authorization decisions.
+const (
+	DecisionAllow = "allow"
+	DecisionDeny  = "deny"
+)
+
+// Policies making the access decisions of the ACL server.
+const (
+	// PolicyTenant restricts the request to the tenants of the user.
+	PolicyTenant = "acl/tenant"
+	// PolicyAuthorization restricts the request to the clusters, namespaces and signals
+	// granted by the authorization policy.
+	PolicyAuthorization = "acl/authorization"
+	// PolicySilence restricts the Alertmanager silences of a user to the alerts of its tenants.
+	PolicySilence = "acl/silence"
+	// PolicyAdminBypass lets admins access the data of all tenants.
+	PolicyAdminBypass = "acl/admin-bypass"
+	// PolicyAdminRequired restricts the request to admins.
+	PolicyAdminRequired = "acl/admin-required"
+	// PolicyDevMode lets unauthenticated requests through in development mode.
+	PolicyDevMode = "acl/dev-mode"
+	// PolicyAuthentication denies requests without a valid identity.
+	PolicyAuthentication = "acl/authentication"
+)
+
+// Recorder receives the audit events of the access decisions.
+type Recorder interface {
+	Record(event audit.AuditEvent)
+}
+
+// Decision is an access decision of the ACL server.
+type Decision struct {
+	Allowed bool
+	Policy  string
+	// Reason explains a denial.
+	Reason string
+	// Actor is the email or subject of the user, empty when unauthenticated.
+	Actor string
+	// Tenants are the tenants whose data the request reads.
+	Tenants []string
+}
+
+type contextKey struct{}
+
+// requestInfo is the audit context of a request.
+type requestInfo struct {
+	recorder      Recorder
+	correlationID string
+	method        string
+	path          string
+	ip            string
+	userAgent     string
+	// recorded is set once a decision was recorded for the request.
+	recorded bool
+}
+
+// Middleware assigns the request a correlation ID, echoed in the response, and
+// lets the decisions made while handling it be recorded with recorder, which
+// may be nil to only propagate correlation IDs. It must run before the
+// authentication middleware: requests it rejects are recorded as denied.
+// X-Forwarded-For is only trusted from the peers in trustedProxies.
+func Middleware(recorder Recorder, trustedProxies []netip.Prefix) server.Middleware {
+	return func(next server.Handler) server.Handler {
+		return func(res *server.Response, req *http.Request) {
+			correlationID := req.Header.Get(CorrelationIDHeader)
+			if !validCorrelationID(correlationID) {
+				correlationID = newID()
+			}
+			res.Writer.Header().Set(CorrelationIDHeader, correlationID)
+
+			info := &requestInfo{
+				recorder:      recorder,
+				correlationID: correlationID,
+				method:        req.Method,
+				path:          req.URL.Path,
+				ip:            clientIP(req, trustedProxies),
+				userAgent:     req.UserAgent(),
+			}
+			next(res, req.WithContext(context.WithValue(req.Context(), contextKey{}, info)))
+
+			if !info.recorded && res.Status == http.StatusUnauthorized && recorder != nil {
+				recorder.Record(info.event(Decision{Policy: PolicyAuthentication, Reason: "missing or invalid token"}))
+			}
+		}
+	}
+}
+
+// CorrelationID returns the correlation ID of the request of ctx, empty outside of Middleware.
+func CorrelationID(ctx context.Context) string {
+	if info, ok := ctx.Value(contextKey{}).(*requestInfo); ok {
+		return info.correlationID
+	}
+	return ""
+}
+
+// Record records an access decision made for the request of ctx. It is a
+// no-op outside of Middleware or when auditing is disabled.
+func Record(ctx context.Context, decision Decision) {
+	info, ok := ctx.Value(contextKey{}).(*requestInfo)
+	if !ok || info.recorder == nil {
+		return
+	}
+	info.recorded = true
+	info.recorder.Record(info.event(decision))
+}
+
+// event returns the audit event of a decision. Events go to the platform
+// stream; the tenants whose data is read are recorded as acl.tenants.
+func (info *requestInfo) event(decision Decision) audit.AuditEvent {
+	event := audit.AuditEvent{
+		Action: audit.Action{
+			Outcome: "success",
+			Verb:    strings.ToLower(info.method),
+		},
+		Actor: audit.Actor{
+			ID:   decision.Actor,
+			Type: "user",
+		},
+		Authorization: audit.Authorization{
+			Decision: DecisionAllow,
+			Policy:   decision.Policy,
+		},
+		CorrelationID: info.correlationID,
+		EventID:       newID(),
+		SchemaVersion: audit.EventSchemaVersion,
+		Source: audit.Source{
+			IP:        info.ip,
+			Origin:    "api",
+			UserAgent: info.userAgent,
+		},
+		Target: audit.Target{
+			Kind: targetKind(info.path),
+			Name: info.path,
+		},
+		Tenant: audit.TenantPlatform,
+	}
+	event.Time.Time = time.Now().UTC()
+	if decision.Actor == "" {
+		event.Actor.Type = "unknown"
+	}
+	if !decision.Allowed {
+		event.Action.Outcome = "denied"
+		event.Authorization.Decision = DecisionDeny
+		event.Authorization.Reason = decision.Reason
+	}
+	if len(decision.Tenants) > 0 {
+		tenants, _ := json.Marshal(map[string]string{"tenants": strings.Join(decision.Tenants, ",")})
+		event.Extra = map[string]json.RawMessage{"acl": tenants}
+	}
+	return event
+}
+
+// targetKind returns the kind of data a path of the ACL server reads:
+// metrics, logs or traces.
+func targetKind(path string) string {
+	kind, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	return kind
+}
+
+// ParseTrustedProxies parses a comma-separated list of the CIDRs or IPs of
+// the reverse proxies whose X-Forwarded-For headers are trusted.
+func ParseTrustedProxies(value string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for item := range strings.SplitSeq(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(item)
+			if addrErr != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", item, err)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// clientIP returns the IP of the client. Any client can set X-Forwarded-For,
+// so it is only read when the peer is a trusted proxy: its hops are walked
+// from the nearest one, and the first hop that is not a trusted proxy is the
+// client. Otherwise the IP of the peer is returned.
+func clientIP(req *http.Request, trustedProxies []netip.Prefix) string {
+	ip := req.RemoteAddr
+	if host, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+		ip = host
+	}
+	if !isTrustedProxy(ip, trustedProxies) {
+		return ip
+	}
+
+	hops := strings.Split(strings.Join(req.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		ip = hop
+		if !isTrustedProxy(hop, trustedProxies) {
+			break
+		}
+	}
+	return ip
+}
+
+func isTrustedProxy(ip string, trustedProxies []netip.Prefix) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func validCorrelationID(id string) bool {
+	if id == "" || len(id) > maxCorrelationIDLength {
+		return false
+	}
+	for _, c := range id {
+		if c <= ' ' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// newID returns a UUIDv7, time-ordered as the event IDs of the audit schema.
+func newID() string {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return uuid.NewString()
+	}
+	return id.String()
+}
@@ -0,0 +1,227 @@
+package auditlog
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/k0rdent/kof/kof-operator/internal/audit"
+	"github.com/k0rdent/kof/kof-operator/internal/server"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/collector/pdata/plog"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+type fakeRecorder struct {
+	events []audit.AuditEvent
+}
+
+func (r *fakeRecorder) Record(event audit.AuditEvent) {
+	r.events = append(r.events, event)
+}
+
+var _ = Describe("Middleware", func() {
+	var (
+		recorder       *fakeRecorder
+		trustedProxies []netip.Prefix
+		logger         = ctrl.Log.WithName("test")
+	)
+
+	BeforeEach(func() {
+		recorder = &fakeRecorder{}
+		trustedProxies = nil
+	})
+
+	serve := func(req *http.Request, handler server.Handler) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		Middleware(recorder, trustedProxies)(handler)(&server.Response{Writer: w, Logger: &logger}, req)
+		return w
+	}
+
+	It("records the decisions with the correlation ID of the request", func() {
+		var err error
+		// httptest requests come from 192.0.2.1, the ingress proxy here.
+		trustedProxies, err = ParseTrustedProxies("192.0.2.0/24, 10.0.0.2")
+		Expect(err).NotTo(HaveOccurred())
+		req := httptest.NewRequest(http.MethodGet, "/metrics/api/v1/query?query=up", nil)
+		req.Header.Set(CorrelationIDHeader, "abc-123")
+		req.Header.Set("X-Forwarded-For", "10.0.0.1, 10.0.0.2")
+		req.Header.Set("User-Agent", "Grafana/12.0")
+
+		w := serve(req, func(res *server.Response, req *http.Request) {
+			Expect(CorrelationID(req.Context())).To(Equal("abc-123"))
+			Record(req.Context(), Decision{Allowed: true, Policy: PolicyTenant, Actor: "user@example.com", Tenants: []string{"a", "b"}})
+			res.Send(nil, http.StatusOK)
+		})
+
+		Expect(w.Header().Get(CorrelationIDHeader)).To(Equal("abc-123"))
+		Expect(recorder.events).To(HaveLen(1))
+		event := recorder.events[0]
+		Expect(event.CorrelationID).To(Equal("abc-123"))
+		Expect(event.EventID).NotTo(BeEmpty())
+		Expect(event.Tenant).To(Equal(audit.TenantPlatform))
+		Expect(event.Action).To(Equal(audit.Action{Outcome: "success", Verb: "get"}))
+		Expect(event.Actor).To(Equal(audit.Actor{ID: "user@example.com", Type: "user"}))
+		Expect(event.Authorization).To(Equal(audit.Authorization{Decision: DecisionAllow, Policy: PolicyTenant}))
+		Expect(event.Source).To(Equal(audit.Source{IP: "10.0.0.1", Origin: "api", UserAgent: "Grafana/12.0"}))
+		Expect(event.Target).To(Equal(audit.Target{Kind: "metrics", Name: "/metrics/api/v1/query"}))
+		Expect(event.Time.IsZero()).To(BeFalse())
+		Expect(event.Flatten()).To(HaveKeyWithValue("acl.tenants", "a,b"))
+	})
+
+	It("only trusts X-Forwarded-For from trusted proxies", func() {
+		record := func(req *http.Request) string {
+			recorder.events = nil
+			serve(req, func(res *server.Response, req *http.Request) {
+				Record(req.Context(), Decision{Allowed: true, Policy: PolicyTenant})
+				res.Send(nil, http.StatusOK)
+			})
+			Expect(recorder.events).To(HaveLen(1))
+			return recorder.events[0].Source.IP
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/metrics/api/v1/query?query=up", nil)
+		req.Header.Set("X-Forwarded-For", "203.0.113.7")
+		Expect(record(req)).To(Equal("192.0.2.1"))
+
+		var err error
+		trustedProxies, err = ParseTrustedProxies("192.0.2.1")
+		Expect(err).NotTo(HaveOccurred())
+		// A spoofed first hop is skipped: the nearest untrusted hop is the client.
+		req.Header.Set("X-Forwarded-For", "198.51.100.1, 203.0.113.7")
+		Expect(record(req)).To(Equal("203.0.113.7"))
+
+		_, err = ParseTrustedProxies("10.0.0.0/8, not-an-ip")
+		Expect(err).To(MatchError(ContainSubstring("not-an-ip")))
+	})
+
+	It("generates a correlation ID when the request has no valid one", func() {
+		req := httptest.NewRequest(http.MethodGet, "/logs/select/logsql/query", nil)
+		req.Header.Set(CorrelationIDHeader, "has spaces")
+
+		w := serve(req, func(res *server.Response, req *http.Request) {
+			Record(req.Context(), Decision{Policy: PolicyTenant, Reason: "tenant not permitted", Actor: "user@example.com"})
+			res.Fail("forbidden", http.StatusForbidden)
+		})
+
+		id := w.Header().Get(CorrelationIDHeader)
+		Expect(id).To(HaveLen(36))
+		Expect(recorder.events).To(HaveLen(1))
+		Expect(recorder.events[0].CorrelationID).To(Equal(id))
+		Expect(recorder.events[0].Action.Outcome).To(Equal("denied"))
+		Expect(recorder.events[0].Authorization).To(Equal(audit.Authorization{
+			Decision: DecisionDeny, Policy: PolicyTenant, Reason: "tenant not permitted",
+		}))
+	})
+
+	It("records the requests rejected by the authentication middleware", func() {
+		req := httptest.NewRequest(http.MethodGet, "/traces/api/search", nil)
+
+		serve(req, func(res *server.Response, _ *http.Request) {
+			res.Fail("Unauthorized", http.StatusUnauthorized)
+		})
+
+		Expect(recorder.events).To(HaveLen(1))
+		Expect(recorder.events[0].Authorization.Policy).To(Equal(PolicyAuthentication))
+		Expect(recorder.events[0].Actor.Type).To(Equal("unknown"))
+	})
+
+	It("ignores decisions outside of the middleware", func() {
+		Record(context.Background(), Decision{Allowed: true})
+		Expect(CorrelationID(context.Background())).To(BeEmpty())
+	})
+})
+
+var _ = Describe("Emitter", func() {
+	var (
+		mu       sync.Mutex
+		received []plog.Logs
+		status   int
+		backend  *httptest.Server
+	)
+
+	BeforeEach(func() {
+		received = nil
+		status = http.StatusNoContent
+		backend = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.URL.Path).To(Equal("/insert/opentelemetry/v1/logs"))
+			Expect(r.Header.Get("Content-Type")).To(Equal("application/x-protobuf"))
+			body, err := io.ReadAll(r.Body)
+			Expect(err).NotTo(HaveOccurred())
+			logs, err := new(plog.ProtoUnmarshaler).UnmarshalLogs(body)
+			Expect(err).NotTo(HaveOccurred())
+			mu.Lock()
+			received = append(received, logs)
+			mu.Unlock()
+			w.WriteHeader(status)
+		}))
+	})
+
+	AfterEach(func() {
+		backend.Close()
+	})
+
+	newEmitter := func(batchSize int) *Emitter {
+		return NewEmitter(Options{
+			Endpoint:      backend.URL + "/insert/opentelemetry/v1/logs",
+			ServiceName:   "kof-acl-server",
+			FlushInterval: time.Hour,
+			BatchSize:     batchSize,
+		}, ctrl.Log.WithName("test"), prometheus.NewRegistry())
+	}
+
+	event := func(id string) audit.AuditEvent {
+		return audit.AuditEvent{
+			Action:        audit.Action{Outcome: "success", Verb: "get"},
+			Actor:         audit.Actor{ID: "user@example.com", Type: "user"},
+			Authorization: audit.Authorization{Decision: DecisionAllow, Policy: PolicyTenant},
+			EventID:       id,
+			Tenant:        audit.TenantPlatform,
+		}
+	}
+
+	It("sends the events as OTLP log records with their flat fields as attributes", func() {
+		emitter := newEmitter(2)
+		emitter.Record(event("e1"))
+		emitter.Record(event("e2"))
+		emitter.Record(event("e3"))
+
+		Expect(emitter.Shutdown(context.Background())).To(Succeed())
+
+		mu.Lock()
+		defer mu.Unlock()
+		Expect(received).To(HaveLen(2))
+		Expect(received[0].LogRecordCount()).To(Equal(2))
+		Expect(received[1].LogRecordCount()).To(Equal(1))
+
+		resourceLogs := received[0].ResourceLogs().At(0)
+		serviceName, ok := resourceLogs.Resource().Attributes().Get("service.name")
+		Expect(ok).To(BeTrue())
+		Expect(serviceName.Str()).To(Equal("kof-acl-server"))
+
+		record := resourceLogs.ScopeLogs().At(0).LogRecords().At(0)
+		Expect(record.Timestamp()).NotTo(BeZero())
+		attrs := record.Attributes().AsRaw()
+		Expect(attrs).To(HaveKeyWithValue("event_id", "e1"))
+		Expect(attrs).To(HaveKeyWithValue("authorization.decision", DecisionAllow))
+		Expect(attrs).To(HaveKeyWithValue("tenant", audit.TenantPlatform))
+		Expect(attrs).NotTo(HaveKey("authorization.reason"))
+	})
+
+	It("counts the events it fails to send as dropped", func() {
+		status = http.StatusServiceUnavailable
+		emitter := newEmitter(10)
+		emitter.Record(event("e1"))
+
+		Expect(emitter.Shutdown(context.Background())).To(Succeed())
+		Expect(testutil.ToFloat64(emitter.dropped)).To(Equal(1.0))
+		Expect(testutil.ToFloat64(emitter.errors)).To(Equal(1.0))
+	})
+})
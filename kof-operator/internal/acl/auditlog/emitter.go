@@ -0,0 +1,236 @@
+package auditlog
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/k0rdent/kof/kof-operator/internal/audit"
+	"github.com/k0rdent/kof/kof-operator/internal/telemetry"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+const (
+	metricsNamespace = "kof_acl"
+
+	defaultFlushInterval = 5 * time.Second
+	defaultBatchSize     = 512
+	defaultQueueSize     = 8192
+
+	scopeName = "github.com/k0rdent/kof/kof-operator/internal/acl/auditlog"
+)
+
+// Options configure an Emitter.
+type Options struct {
+	// Endpoint is the OTLP/HTTP logs endpoint, e.g. the
+	// /insert/opentelemetry/v1/logs endpoint of the audit VictoriaLogs.
+	Endpoint string
+	// ServiceName is the service.name resource attribute of the log records.
+	ServiceName string
+	// FlushInterval is the maximum time an event is buffered for.
+	FlushInterval time.Duration
+	// BatchSize is the number of events sent per request.
+	BatchSize int
+	// QueueSize is the number of events buffered before new ones are dropped.
+	QueueSize int
+}
+
+// Emitter sends audit events as OTLP log records in batches. Record never
+// blocks the request: events are dropped and counted when the queue is full.
+type Emitter struct {
+	opts   Options
+	client *http.Client
+	log    logr.Logger
+
+	queue chan audit.AuditEvent
+	stop  chan struct{}
+	done  chan struct{}
+	once  sync.Once
+
+	events  *prometheus.CounterVec
+	dropped prometheus.Counter
+	errors  prometheus.Counter
+}
+
+// NewEmitter creates an Emitter, starts its flush loop and registers its metrics with reg.
+func NewEmitter(opts Options, log logr.Logger, reg prometheus.Registerer) *Emitter {
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = defaultFlushInterval
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultBatchSize
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = defaultQueueSize
+	}
+
+	e := &Emitter{
+		opts:   opts,
+		client: &http.Client{Transport: telemetry.NewTransport(nil), Timeout: 30 * time.Second},
+		log:    log,
+		queue:  make(chan audit.AuditEvent, opts.QueueSize),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+		events: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "audit_events_total",
+			Help:      "Number of access decisions recorded as audit events, by decision.",
+		}, []string{"decision"}),
+		dropped: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "audit_events_dropped_total",
+			Help:      "Number of audit events dropped, as the queue was full or they could not be sent.",
+		}),
+		errors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "audit_export_errors_total",
+			Help:      "Number of failed requests sending audit events.",
+		}),
+	}
+	reg.MustRegister(e.events, e.dropped, e.errors)
+
+	go e.run()
+	return e
+}
+
+// Record queues an audit event.
+func (e *Emitter) Record(event audit.AuditEvent) {
+	e.events.WithLabelValues(event.Authorization.Decision).Inc()
+	select {
+	case e.queue <- event:
+	default:
+		e.dropped.Inc()
+	}
+}
+
+// Shutdown stops the flush loop and sends the queued events, until ctx is done.
+func (e *Emitter) Shutdown(ctx context.Context) error {
+	e.once.Do(func() { close(e.stop) })
+	select {
+	case <-e.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (e *Emitter) run() {
+	defer close(e.done)
+
+	ticker := time.NewTicker(e.opts.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]audit.AuditEvent, 0, e.opts.BatchSize)
+	flush := func() {
+		if len(batch) > 0 {
+			e.send(batch)
+			batch = batch[:0]
+		}
+	}
+
+	for {
+		select {
+		case event := <-e.queue:
+			if batch = append(batch, event); len(batch) >= e.opts.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-e.stop:
+			for {
+				select {
+				case event := <-e.queue:
+					if batch = append(batch, event); len(batch) >= e.opts.BatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (e *Emitter) send(batch []audit.AuditEvent) {
+	body, err := new(plog.ProtoMarshaler).MarshalLogs(e.logs(batch, time.Now()))
+	if err == nil {
+		err = e.post(body)
+	}
+	if err != nil {
+		e.errors.Inc()
+		e.dropped.Add(float64(len(batch)))
+		e.log.Error(err, "failed to send audit events", "count", len(batch))
+	}
+}
+
+func (e *Emitter) post(body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), e.client.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.opts.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer func() {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("received non-OK response: %s", resp.Status)
+	}
+	return nil
+}
+
+// logs converts the events into OTLP log records whose attributes are the
+// flat fields of the events, as the audit exporter reads them back.
+func (e *Emitter) logs(batch []audit.AuditEvent, observed time.Time) plog.Logs {
+	logs := plog.NewLogs()
+	resourceLogs := logs.ResourceLogs().AppendEmpty()
+	if e.opts.ServiceName != "" {
+		resourceLogs.Resource().Attributes().PutStr("service.name", e.opts.ServiceName)
+	}
+	scopeLogs := resourceLogs.ScopeLogs().AppendEmpty()
+	scopeLogs.Scope().SetName(scopeName)
+
+	records := scopeLogs.LogRecords()
+	records.EnsureCapacity(len(batch))
+	for _, event := range batch {
+		record := records.AppendEmpty()
+		record.SetTimestamp(pcommon.NewTimestampFromTime(eventTime(event, observed)))
+		record.SetObservedTimestamp(pcommon.NewTimestampFromTime(observed))
+		record.SetSeverityNumber(plog.SeverityNumberInfo)
+		record.SetSeverityText("INFO")
+		record.Body().SetStr(fmt.Sprintf("%s %s %s by %q: %s",
+			event.Authorization.Decision, event.Action.Verb, event.Target.Name, event.Actor.ID, event.Authorization.Policy))
+		attrs := record.Attributes()
+		for k, v := range event.Flatten() {
+			if v != "" {
+				attrs.PutStr(k, v)
+			}
+		}
+	}
+	return logs
+}
+
+// eventTime returns the time of the event, the observed time when unset.
+func eventTime(event audit.AuditEvent, observed time.Time) time.Time {
+	if event.Time.IsZero() {
+		return observed
+	}
+	return event.Time.Time
+}
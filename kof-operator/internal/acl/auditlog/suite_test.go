@@ -0,0 +1,13 @@
+package auditlog
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestAuditLog(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "ACL Audit Log Suite")
+}
@@ -0,0 +1,153 @@
+// Package authz enforces cluster-level authorization on top of tenant
+// isolation: a policy of rules selecting users by email or group grants them
+// access to some of their tenants, clusters, namespaces and signal types.
+package authz
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/k0rdent/kof/kof-operator/internal/acl/tenant"
+	"sigs.k8s.io/yaml"
+)
+
+// Signal is a type of telemetry data.
+type Signal string
+
+const (
+	SignalMetrics Signal = "metrics"
+	SignalLogs    Signal = "logs"
+	SignalTraces  Signal = "traces"
+)
+
+// ErrDenied is returned when the policy grants no access to the requested data.
+var ErrDenied = errors.New("forbidden: denied by the authorization policy")
+
+// Authorizer restricts the access of an identity to the data of its tenants.
+type Authorizer interface {
+	// Authorize returns the scope of the signal data of tenants the identity may read.
+	Authorize(identity *tenant.Identity, signal Signal, tenants []string) (*Scope, error)
+}
+
+// Policy is a declarative authorization policy. The users matched by no rule
+// are only restricted to their tenants, unless DefaultDeny is set. The access
+// of the users matched by rules is the union of the grants of these rules.
+type Policy struct {
+	// DefaultDeny denies all data to the users matched by no rule.
+	DefaultDeny bool `json:"defaultDeny,omitempty"`
+	// Rules grant access to the users they match.
+	Rules []Rule `json:"rules,omitempty"`
+}
+
+// Rule grants the users it matches access to the signals of some clusters and
+// namespaces of their tenants. Tenants, Clusters and Namespaces are patterns
+// where "*" matches any sequence of characters; all of them are granted when
+// the list is empty.
+type Rule struct {
+	// Name identifies the rule in errors.
+	Name string `json:"name,omitempty"`
+	// Emails and Groups select the users the rule applies to. A rule without
+	// them applies to every user.
+	Emails []string `json:"emails,omitempty"`
+	Groups []string `json:"groups,omitempty"`
+	// Tenants narrows the tenants of the user the rule grants.
+	Tenants []string `json:"tenants,omitempty"`
+	// Clusters and Namespaces are the clusters and namespaces granted.
+	Clusters   []string `json:"clusters,omitempty"`
+	Namespaces []string `json:"namespaces,omitempty"`
+	// Signals are the signal types granted, all of them when empty.
+	Signals []Signal `json:"signals,omitempty"`
+}
+
+// LoadPolicy reads the YAML policy file and validates it.
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read authorization policy file: %w", err)
+	}
+	return ParsePolicy(data)
+}
+
+// ParsePolicy parses the YAML policy and validates it.
+func ParsePolicy(data []byte) (*Policy, error) {
+	p := new(Policy)
+	if err := yaml.UnmarshalStrict(data, p); err != nil {
+		return nil, fmt.Errorf("failed to parse authorization policy: %w", err)
+	}
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *Policy) validate() error {
+	for i, rule := range p.Rules {
+		for _, signal := range rule.Signals {
+			switch signal {
+			case SignalMetrics, SignalLogs, SignalTraces:
+			default:
+				return fmt.Errorf("rules[%d]: unknown signal %q", i, signal)
+			}
+		}
+		for _, patterns := range [][]string{rule.Tenants, rule.Clusters, rule.Namespaces} {
+			if slices.Contains(patterns, "") {
+				return fmt.Errorf("rules[%d]: empty pattern", i)
+			}
+		}
+	}
+	return nil
+}
+
+// Authorize implements Authorizer. A nil policy grants all tenants.
+func (p *Policy) Authorize(identity *tenant.Identity, signal Signal, tenants []string) (*Scope, error) {
+	if p == nil {
+		return Unrestricted(tenants), nil
+	}
+
+	matched := false
+	scope := &Scope{}
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		if !rule.appliesTo(identity) {
+			continue
+		}
+		matched = true
+		if len(rule.Signals) > 0 && !slices.Contains(rule.Signals, signal) {
+			continue
+		}
+
+		granted := make([]string, 0, len(tenants))
+		for _, tenantID := range tenants {
+			if matchAny(rule.Tenants, tenantID) {
+				granted = append(granted, tenantID)
+			}
+		}
+		if len(granted) > 0 {
+			scope.add(Grant{Tenants: granted, Clusters: rule.Clusters, Namespaces: rule.Namespaces}, tenants)
+		}
+	}
+
+	switch {
+	case !matched && p.DefaultDeny:
+		return nil, fmt.Errorf("%w: no rule applies to %q", ErrDenied, identity.Email)
+	case !matched:
+		return Unrestricted(tenants), nil
+	case len(scope.Grants) == 0:
+		return nil, fmt.Errorf("%w: no rule grants the %s of tenants %q", ErrDenied, signal, tenants)
+	}
+	return scope, nil
+}
+
+func (r *Rule) appliesTo(identity *tenant.Identity) bool {
+	if len(r.Emails) == 0 && len(r.Groups) == 0 {
+		return true
+	}
+	if slices.Contains(r.Emails, identity.Email) {
+		return true
+	}
+	return slices.ContainsFunc(r.Groups, func(group string) bool {
+		return slices.Contains(identity.Groups, group)
+	})
+}
@@ -0,0 +1,193 @@
+package authz
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/k0rdent/kof/kof-operator/internal/acl/tenant"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const testPolicy = `
+rules:
+- name: team-a-prod
+  groups: [team-a]
+  tenants: [acme]
+  clusters: ["prod-*"]
+- name: team-a-y
+  groups: [team-a]
+  tenants: [globex]
+- name: contractors
+  groups: [contractors]
+  signals: [metrics, traces]
+- name: auditor
+  emails: [auditor@example.com]
+  namespaces: [audit, "kube-*"]
+- name: auditor-clusters
+  emails: [auditor@example.com]
+  tenants: [acme]
+  clusters: [mgmt]
+  namespaces: [audit, "kube-*"]
+`
+
+var _ = Describe("Policy", func() {
+	var policy *Policy
+
+	BeforeEach(func() {
+		var err error
+		policy, err = ParsePolicy([]byte(testPolicy))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	identity := func(email string, groups ...string) *tenant.Identity {
+		return &tenant.Identity{Email: email, Groups: groups}
+	}
+
+	It("should restrict the clusters of a tenant and keep the others unrestricted", func() {
+		scope, err := policy.Authorize(identity("dev@example.com", "team-a"), SignalLogs, []string{"acme", "globex", "initech"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(scope.Grants).To(ConsistOf(
+			Grant{Tenants: []string{"acme"}, Clusters: []string{"prod-*"}},
+			Grant{Tenants: []string{"globex"}},
+		))
+		Expect(scope.Tenants()).To(Equal([]string{"acme", "globex"}))
+		Expect(scope.Restricted()).To(BeTrue())
+
+		Expect(scope.Allows("acme", "prod-eu", "")).To(BeTrue())
+		Expect(scope.Allows("acme", "dev-eu", "")).To(BeFalse())
+		Expect(scope.Allows("globex", "dev-eu", "default")).To(BeTrue())
+		Expect(scope.Allows("initech", "prod-eu", "")).To(BeFalse())
+	})
+
+	It("should deny the tenants no rule grants", func() {
+		_, err := policy.Authorize(identity("dev@example.com", "team-a"), SignalMetrics, []string{"initech"})
+		Expect(err).To(MatchError(ErrDenied))
+	})
+
+	It("should deny the signals no rule grants", func() {
+		scope, err := policy.Authorize(identity("c@example.com", "contractors"), SignalMetrics, []string{"acme"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(scope).To(Equal(Unrestricted([]string{"acme"})))
+
+		_, err = policy.Authorize(identity("c@example.com", "contractors"), SignalLogs, []string{"acme"})
+		Expect(err).To(MatchError(ErrDenied))
+	})
+
+	It("should merge the grants covered by other grants", func() {
+		scope, err := policy.Authorize(identity("auditor@example.com"), SignalTraces, []string{"acme", "globex"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(scope.Grants).To(Equal([]Grant{
+			{Tenants: []string{"acme", "globex"}, Namespaces: []string{"audit", "kube-*"}},
+		}))
+	})
+
+	It("should only restrict the tenants of users matched by no rule", func() {
+		scope, err := policy.Authorize(identity("other@example.com", "team-b"), SignalLogs, []string{"acme"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(scope).To(Equal(Unrestricted([]string{"acme"})))
+
+		policy.DefaultDeny = true
+		_, err = policy.Authorize(identity("other@example.com", "team-b"), SignalLogs, []string{"acme"})
+		Expect(err).To(MatchError(ErrDenied))
+	})
+
+	It("should grant all tenants without a policy", func() {
+		var policy *Policy
+		scope, err := policy.Authorize(identity("dev@example.com"), SignalLogs, []string{"acme"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(scope.Restricted()).To(BeFalse())
+		Expect(scope.Tenants()).To(Equal([]string{"acme"}))
+	})
+
+	DescribeTable("should reject invalid policies",
+		func(data, message string) {
+			_, err := ParsePolicy([]byte(data))
+			Expect(err).To(MatchError(ContainSubstring(message)))
+		},
+		Entry("unknown signal", "rules:\n- signals: [profiles]\n", `unknown signal "profiles"`),
+		Entry("empty pattern", "rules:\n- clusters: [\"\"]\n", "empty pattern"),
+		Entry("unknown field", "rules:\n- cluster: [a]\n", "unknown field"),
+	)
+})
+
+var _ = Describe("Scope", func() {
+	DescribeTable("should merge grants differing in a single dimension",
+		func(a, b Grant, expected []Grant) {
+			scope := &Scope{}
+			scope.add(a, []string{"acme", "globex"})
+			scope.add(b, []string{"acme", "globex"})
+			Expect(scope.Grants).To(Equal(expected))
+		},
+		Entry("tenants",
+			Grant{Tenants: []string{"globex"}, Clusters: []string{"a"}},
+			Grant{Tenants: []string{"acme"}, Clusters: []string{"a"}},
+			[]Grant{{Tenants: []string{"acme", "globex"}, Clusters: []string{"a"}}},
+		),
+		Entry("clusters",
+			Grant{Tenants: []string{"acme"}, Clusters: []string{"a"}},
+			Grant{Tenants: []string{"acme"}, Clusters: []string{"b"}},
+			[]Grant{{Tenants: []string{"acme"}, Clusters: []string{"a", "b"}}},
+		),
+		Entry("namespaces",
+			Grant{Tenants: []string{"acme"}, Namespaces: []string{"a"}},
+			Grant{Tenants: []string{"acme"}, Namespaces: []string{"b"}},
+			[]Grant{{Tenants: []string{"acme"}, Namespaces: []string{"a", "b"}}},
+		),
+		Entry("covered grant",
+			Grant{Tenants: []string{"acme"}, Clusters: []string{"a"}, Namespaces: []string{"b"}},
+			Grant{Tenants: []string{"acme", "globex"}},
+			[]Grant{{Tenants: []string{"acme", "globex"}}},
+		),
+		Entry("several dimensions",
+			Grant{Tenants: []string{"acme"}, Clusters: []string{"a"}},
+			Grant{Tenants: []string{"acme"}, Namespaces: []string{"b"}},
+			[]Grant{
+				{Tenants: []string{"acme"}, Clusters: []string{"a"}},
+				{Tenants: []string{"acme"}, Namespaces: []string{"b"}},
+			},
+		),
+	)
+
+	DescribeTable("MatchPattern",
+		func(pattern, value string, expected bool) {
+			Expect(MatchPattern(pattern, value)).To(Equal(expected))
+		},
+		Entry("exact", "prod", "prod", true),
+		Entry("exact mismatch", "prod", "prod-1", false),
+		Entry("prefix", "prod-*", "prod-1", true),
+		Entry("prefix mismatch", "prod-*", "dev-1", false),
+		Entry("any", "*", "", true),
+		Entry("infix", "*-eu-*", "prod-eu-1", true),
+		Entry("overlapping prefix and suffix", "a*a", "a", false),
+		Entry("regexp characters", "a.b*", "axb", false),
+	)
+
+	It("should translate the patterns to a regular expression", func() {
+		Expect(PatternsRegexp([]string{"prod-*", "a.b"})).To(Equal(`prod-.*|a\.b`))
+	})
+})
+
+var _ = Describe("Watcher", func() {
+	It("should reload the policy file and keep the previous policy when it is invalid", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "policy.yaml")
+		Expect(os.WriteFile(path, []byte(testPolicy), 0o600)).To(Succeed())
+		watcher, err := NewWatcher(path, ctrl.Log.WithName("test"))
+		Expect(err).NotTo(HaveOccurred())
+
+		contractor := &tenant.Identity{Email: "c@example.com", Groups: []string{"contractors"}}
+		_, err = watcher.Authorize(contractor, SignalLogs, []string{"acme"})
+		Expect(err).To(MatchError(ErrDenied))
+
+		Expect(os.WriteFile(path, []byte("rules: []\n"), 0o600)).To(Succeed())
+		watcher.reload()
+		_, err = watcher.Authorize(contractor, SignalLogs, []string{"acme"})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(os.WriteFile(path, []byte("rules: {"), 0o600)).To(Succeed())
+		watcher.reload()
+		_, err = watcher.Authorize(contractor, SignalLogs, []string{"acme"})
+		Expect(err).NotTo(HaveOccurred())
+	})
+})
@@ -0,0 +1,182 @@
+package authz
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Scope is the data of a request the policy grants: the union of its grants.
+type Scope struct {
+	Grants []Grant
+}
+
+// Grant grants the data of some clusters and namespaces of tenants.
+// Clusters and Namespaces are patterns, all of them are granted when empty.
+type Grant struct {
+	Tenants    []string
+	Clusters   []string
+	Namespaces []string
+}
+
+// Unrestricted returns the scope of all data of tenants.
+func Unrestricted(tenants []string) *Scope {
+	return &Scope{Grants: []Grant{{Tenants: tenants}}}
+}
+
+// Tenants returns the tenants of the scope.
+func (s *Scope) Tenants() []string {
+	if len(s.Grants) == 1 {
+		return s.Grants[0].Tenants
+	}
+	var tenants []string
+	for _, grant := range s.Grants {
+		for _, tenantID := range grant.Tenants {
+			if !slices.Contains(tenants, tenantID) {
+				tenants = append(tenants, tenantID)
+			}
+		}
+	}
+	return tenants
+}
+
+// Restricted reports whether the scope restricts the clusters or namespaces of its tenants.
+func (s *Scope) Restricted() bool {
+	return slices.ContainsFunc(s.Grants, func(g Grant) bool {
+		return len(g.Clusters) > 0 || len(g.Namespaces) > 0
+	})
+}
+
+// Allows reports whether the scope grants the data of the tenant, cluster and namespace.
+func (s *Scope) Allows(tenantID, cluster, namespace string) bool {
+	return slices.ContainsFunc(s.Grants, func(g Grant) bool {
+		return slices.Contains(g.Tenants, tenantID) && matchAny(g.Clusters, cluster) && matchAny(g.Namespaces, namespace)
+	})
+}
+
+// add adds a grant to the scope, merging it with the grants it differs from in
+// a single dimension, so that a scope is rendered by as few filters as
+// possible. order is the order of the merged tenants.
+func (s *Scope) add(grant Grant, order []string) {
+	for {
+		i := slices.IndexFunc(s.Grants, func(other Grant) bool {
+			_, ok := merge(other, grant, order)
+			return ok
+		})
+		if i < 0 {
+			s.Grants = append(s.Grants, grant)
+			return
+		}
+		grant, _ = merge(s.Grants[i], grant, order)
+		s.Grants = slices.Delete(s.Grants, i, i+1)
+	}
+}
+
+// merge returns the grant equal to the union of a and b, if there is one.
+func merge(a, b Grant, order []string) (Grant, bool) {
+	switch {
+	case a.covers(b):
+		return a, true
+	case b.covers(a):
+		return b, true
+	}
+
+	sameTenants := sameSet(a.Tenants, b.Tenants)
+	sameClusters := sameSet(a.Clusters, b.Clusters)
+	sameNamespaces := sameSet(a.Namespaces, b.Namespaces)
+	switch {
+	case sameClusters && sameNamespaces:
+		tenants := make([]string, 0, len(a.Tenants)+len(b.Tenants))
+		for _, tenantID := range order {
+			if slices.Contains(a.Tenants, tenantID) || slices.Contains(b.Tenants, tenantID) {
+				tenants = append(tenants, tenantID)
+			}
+		}
+		return Grant{Tenants: tenants, Clusters: a.Clusters, Namespaces: a.Namespaces}, true
+	case sameTenants && sameClusters:
+		return Grant{Tenants: a.Tenants, Clusters: a.Clusters, Namespaces: union(a.Namespaces, b.Namespaces)}, true
+	case sameTenants && sameNamespaces:
+		return Grant{Tenants: a.Tenants, Clusters: union(a.Clusters, b.Clusters), Namespaces: a.Namespaces}, true
+	}
+	return Grant{}, false
+}
+
+// covers reports whether g grants all data of other.
+func (g Grant) covers(other Grant) bool {
+	return subset(other.Tenants, g.Tenants) &&
+		patternsCover(g.Clusters, other.Clusters) &&
+		patternsCover(g.Namespaces, other.Namespaces)
+}
+
+func patternsCover(patterns, other []string) bool {
+	return len(patterns) == 0 || (len(other) > 0 && subset(other, patterns))
+}
+
+func subset(a, b []string) bool {
+	for _, item := range a {
+		if !slices.Contains(b, item) {
+			return false
+		}
+	}
+	return true
+}
+
+func sameSet(a, b []string) bool {
+	return subset(a, b) && subset(b, a)
+}
+
+func union(a, b []string) []string {
+	result := slices.Clone(a)
+	for _, item := range b {
+		if !slices.Contains(result, item) {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// matchAny reports whether value matches one of the patterns, or there is none.
+func matchAny(patterns []string, value string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(patterns, func(pattern string) bool {
+		return MatchPattern(pattern, value)
+	})
+}
+
+// MatchPattern reports whether value matches the pattern, where "*" matches
+// any sequence of characters.
+func MatchPattern(pattern, value string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == value
+	}
+
+	first, last := parts[0], parts[len(parts)-1]
+	if !strings.HasPrefix(value, first) {
+		return false
+	}
+	value = value[len(first):]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(value, part)
+		if i < 0 {
+			return false
+		}
+		value = value[i+len(part):]
+	}
+	return strings.HasSuffix(value, last)
+}
+
+// PatternsRegexp returns the unanchored regular expression matching any of the patterns.
+func PatternsRegexp(patterns []string) string {
+	alternatives := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		parts := strings.Split(pattern, "*")
+		for i, part := range parts {
+			parts[i] = regexp.QuoteMeta(part)
+		}
+		alternatives = append(alternatives, strings.Join(parts, ".*"))
+	}
+	return strings.Join(alternatives, "|")
+}
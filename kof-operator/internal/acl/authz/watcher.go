@@ -0,0 +1,79 @@
+package authz
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/k0rdent/kof/kof-operator/internal/acl/tenant"
+)
+
+// Watcher is an Authorizer enforcing the policy of a file, such as a mounted
+// ConfigMap, reloaded when the file changes. An invalid policy is logged and
+// the previous one kept.
+type Watcher struct {
+	path string
+	log  logr.Logger
+
+	policy atomic.Pointer[Policy]
+	data   []byte
+}
+
+// NewWatcher loads the policy file, which must be valid.
+func NewWatcher(path string, log logr.Logger) (*Watcher, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := ParsePolicy(data)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Watcher{path: path, log: log, data: data}
+	w.policy.Store(policy)
+	return w, nil
+}
+
+// Authorize implements Authorizer with the current policy.
+func (w *Watcher) Authorize(identity *tenant.Identity, signal Signal, tenants []string) (*Scope, error) {
+	return w.policy.Load().Authorize(identity, signal, tenants)
+}
+
+// Run reloads the policy file every interval until ctx is done.
+func (w *Watcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.reload()
+		}
+	}
+}
+
+func (w *Watcher) reload() {
+	data, err := os.ReadFile(w.path)
+	if err != nil {
+		w.log.Error(err, "Failed to read the authorization policy, keeping the previous one")
+		return
+	}
+	if bytes.Equal(data, w.data) {
+		return
+	}
+	w.data = data
+
+	policy, err := ParsePolicy(data)
+	if err != nil {
+		w.log.Error(err, "Invalid authorization policy, keeping the previous one")
+		return
+	}
+	w.policy.Store(policy)
+	w.log.Info("Reloaded the authorization policy", "rules", len(policy.Rules))
+}
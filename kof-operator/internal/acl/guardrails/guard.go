@@ -0,0 +1,174 @@
+// Package guardrails enforces per-tenant query cost limits in the ACL proxy:
+// time range, resolution step, selector and series counts of PromQL queries,
+// and token-bucket request rate and concurrency limits.
+package guardrails
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+)
+
+const metricsNamespace = "kof_acl"
+
+// Guard admits the requests of tenants according to a Policy. It is safe for
+// concurrent use; a nil Guard admits every request.
+type Guard struct {
+	policy *Policy
+	now    func() time.Time
+
+	mu      sync.Mutex
+	tenants map[string]*tenantState
+
+	requests   *prometheus.CounterVec
+	rejections *prometheus.CounterVec
+	inflight   *prometheus.GaugeVec
+}
+
+type tenantState struct {
+	limiter  *rate.Limiter
+	inflight int
+}
+
+// NewGuard creates a Guard enforcing policy and registers its metrics with reg.
+func NewGuard(policy *Policy, reg prometheus.Registerer) *Guard {
+	g := &Guard{
+		policy:  policy,
+		now:     time.Now,
+		tenants: make(map[string]*tenantState),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "guardrail_admitted_requests_total",
+			Help:      "Number of tenant queries admitted by the guardrails.",
+		}, []string{"tenant"}),
+		rejections: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "guardrail_rejections_total",
+			Help:      "Number of tenant queries rejected by the guardrails, by reason.",
+		}, []string{"tenant", "reason"}),
+		inflight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "guardrail_inflight_requests",
+			Help:      "Number of tenant queries in flight.",
+		}, []string{"tenant"}),
+	}
+	reg.MustRegister(g.requests, g.rejections, g.inflight)
+	return g
+}
+
+// QueryLimits returns the limits on the shape of a query reading the data of tenants.
+func (g *Guard) QueryLimits(tenants []string) Limits {
+	if g == nil {
+		return Limits{}
+	}
+	return g.policy.QueryLimits(tenants)
+}
+
+// Admit charges a request reading the data of tenants against the token
+// bucket and the concurrency limit of each of them. Either all tenants admit
+// the request or none is charged. The returned release func must be called
+// when the request completes.
+func (g *Guard) Admit(tenants []string) (func(), *Rejection) {
+	if g == nil {
+		return func() {}, nil
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	states := make([]*tenantState, len(tenants))
+	for i, tenantID := range tenants {
+		states[i] = g.state(tenantID)
+		if maxConcurrent := g.policy.TenantLimits(tenantID).MaxConcurrent; maxConcurrent > 0 && states[i].inflight >= maxConcurrent {
+			return nil, g.reject(tenants, &Rejection{
+				Status:     http.StatusTooManyRequests,
+				ErrorType:  ErrorTypeUnavailable,
+				Reason:     ReasonConcurrencyLimited,
+				Message:    fmt.Sprintf("too many concurrent queries for tenant %q, the limit is %d", tenantID, maxConcurrent),
+				RetryAfter: time.Second,
+			})
+		}
+	}
+
+	now := g.now()
+	reservations := make([]*rate.Reservation, 0, len(tenants))
+	cancel := func() {
+		for _, r := range reservations {
+			r.CancelAt(now)
+		}
+	}
+	for i, tenantID := range tenants {
+		if states[i].limiter == nil {
+			continue
+		}
+		r := states[i].limiter.ReserveN(now, 1)
+		if delay := r.DelayFrom(now); !r.OK() || delay > 0 {
+			r.CancelAt(now)
+			cancel()
+			return nil, g.reject(tenants, &Rejection{
+				Status:     http.StatusTooManyRequests,
+				ErrorType:  ErrorTypeUnavailable,
+				Reason:     ReasonRateLimited,
+				Message:    fmt.Sprintf("query rate limit of tenant %q exceeded", tenantID),
+				RetryAfter: max(delay, time.Second),
+			})
+		}
+		reservations = append(reservations, r)
+	}
+
+	for i, tenantID := range tenants {
+		states[i].inflight++
+		g.inflight.WithLabelValues(tenantID).Inc()
+		g.requests.WithLabelValues(tenantID).Inc()
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			g.mu.Lock()
+			defer g.mu.Unlock()
+			for i, tenantID := range tenants {
+				states[i].inflight--
+				g.inflight.WithLabelValues(tenantID).Dec()
+			}
+		})
+	}, nil
+}
+
+// Reject records the rejection of a request reading the data of tenants.
+func (g *Guard) Reject(tenants []string, rejection *Rejection) *Rejection {
+	if g == nil {
+		return rejection
+	}
+	return g.reject(tenants, rejection)
+}
+
+func (g *Guard) reject(tenants []string, rejection *Rejection) *Rejection {
+	for _, tenantID := range tenants {
+		g.rejections.WithLabelValues(tenantID, rejection.Reason).Inc()
+	}
+	return rejection
+}
+
+// state returns the state of a tenant, creating its token bucket from the
+// policy on first use; g.mu must be held.
+func (g *Guard) state(tenantID string) *tenantState {
+	if s, ok := g.tenants[tenantID]; ok {
+		return s
+	}
+	s := &tenantState{}
+	if limits := g.policy.TenantLimits(tenantID); limits.RequestsPerSecond > 0 {
+		burst := limits.Burst
+		if burst == 0 {
+			burst = int(math.Ceil(limits.RequestsPerSecond))
+		}
+		s.limiter = rate.NewLimiter(rate.Limit(limits.RequestsPerSecond), burst)
+	}
+	g.tenants[tenantID] = s
+	return s
+}
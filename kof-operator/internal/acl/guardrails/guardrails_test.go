@@ -0,0 +1,248 @@
+package guardrails
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+)
+
+var _ = Describe("Policy", func() {
+	It("should merge the tenant overrides with the default", func() {
+		p, err := ParsePolicy([]byte(`
+default:
+  maxRange: 7d
+  minStep: 15s
+  maxSeries: 1000
+tenants:
+  acme:
+    maxRange: 30d
+    maxConcurrent: 2
+`))
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(p.TenantLimits("other")).To(Equal(Limits{
+			MaxRange:  model.Duration(7 * 24 * time.Hour),
+			MinStep:   model.Duration(15 * time.Second),
+			MaxSeries: 1000,
+		}))
+		Expect(p.TenantLimits("acme")).To(Equal(Limits{
+			MaxRange:      model.Duration(30 * 24 * time.Hour),
+			MinStep:       model.Duration(15 * time.Second),
+			MaxSeries:     1000,
+			MaxConcurrent: 2,
+		}))
+	})
+
+	It("should apply the strictest query limits of several tenants", func() {
+		p, err := ParsePolicy([]byte(`
+tenants:
+  a: {maxRange: 1d, minStep: 10s}
+  b: {maxRange: 2d, minStep: 1m, maxSelectors: 5}
+`))
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(p.QueryLimits([]string{"a", "b"})).To(Equal(Limits{
+			MaxRange:     model.Duration(24 * time.Hour),
+			MinStep:      model.Duration(time.Minute),
+			MaxSelectors: 5,
+		}))
+	})
+
+	It("should reject unknown fields and negative limits", func() {
+		_, err := ParsePolicy([]byte("default: {maxRanges: 1d}"))
+		Expect(err).To(HaveOccurred())
+
+		_, err = ParsePolicy([]byte("tenants: {acme: {maxSeries: -1}}"))
+		Expect(err).To(MatchError(ContainSubstring("tenants[acme]")))
+	})
+})
+
+var _ = Describe("ParseRequest", func() {
+	now := time.Unix(100000, 0).UTC()
+
+	parse := func(path, rawQuery string) (*Request, *Rejection) {
+		params, err := url.ParseQuery(rawQuery)
+		Expect(err).NotTo(HaveOccurred())
+		return ParseRequest(path, params, 0, now)
+	}
+
+	It("should compute the data range of a range query", func() {
+		r, rej := parse("/metrics/api/v1/query_range", "query=rate(up[10m])&start=1000&end=4600&step=30")
+		Expect(rej).To(BeNil())
+
+		Expect(r.Kind).To(Equal(KindRange))
+		Expect(r.Step).To(Equal(30 * time.Second))
+		Expect(r.MinTime).To(Equal(time.Unix(400, 0).UTC()))
+		Expect(r.MaxTime).To(Equal(time.Unix(4600, 0).UTC()))
+		Expect(r.Selectors).To(Equal([]string{`{__name__="up"}`}))
+	})
+
+	It("should widen the data range by subqueries and offsets", func() {
+		r, rej := parse("/metrics/api/v1/query", "query=max_over_time(up[1h:1m] offset 1d)&time=100000")
+		Expect(rej).To(BeNil())
+
+		Expect(r.MaxTime).To(Equal(now.Add(-24 * time.Hour)))
+		Expect(r.MinTime).To(Equal(now.Add(-24*time.Hour - time.Hour - lookbackDelta)))
+	})
+
+	It("should deduplicate the selectors", func() {
+		r, rej := parse("/metrics/api/v1/query", `query=up{job="a"} / up{job="a"} %2B down`)
+		Expect(rej).To(BeNil())
+
+		Expect(r.Selectors).To(HaveLen(2))
+	})
+
+	It("should set the start of a series request to the maximum range", func() {
+		params := url.Values{"match[]": {`{job="a"}`}}
+		r, rej := ParseRequest("/metrics/api/v1/series", params, time.Hour, now)
+		Expect(rej).To(BeNil())
+
+		Expect(r.Kind).To(Equal(KindMatch))
+		Expect(r.Range()).To(Equal(time.Hour))
+		Expect(params.Get("start")).To(Equal("96400"))
+	})
+
+	It("should reject invalid parameters", func() {
+		_, rej := parse("/metrics/api/v1/query_range", "query=up&start=10&end=0&step=1")
+		Expect(rej.ErrorType).To(Equal(ErrorTypeBadData))
+		Expect(rej.Message).To(ContainSubstring("end timestamp must not be before start time"))
+
+		_, rej = parse("/metrics/api/v1/query_range", "query=up&start=0&end=10&step=0")
+		Expect(rej.Status).To(Equal(http.StatusBadRequest))
+
+		_, rej = parse("/metrics/api/v1/query", "query=up{")
+		Expect(rej.Reason).To(Equal(ReasonInvalidRequest))
+	})
+
+	It("should check the request against the limits", func() {
+		r, rej := parse("/metrics/api/v1/query_range", "query=up %2B down&start=0&end=86400&step=1")
+		Expect(rej).To(BeNil())
+
+		Expect(r.Check(Limits{})).To(BeNil())
+		Expect(r.Check(Limits{MaxSelectors: 1}).Reason).To(Equal(ReasonMaxSelectors))
+		Expect(r.Check(Limits{MaxRange: model.Duration(time.Hour)}).Reason).To(Equal(ReasonMaxRange))
+		Expect(r.Check(Limits{MinStep: model.Duration(time.Second * 15)}).Reason).To(Equal(ReasonMinStep))
+		Expect(r.NeedsSeriesPreflight(Limits{MaxSeries: 10})).To(BeTrue())
+	})
+})
+
+var _ = Describe("Guard", func() {
+	var (
+		reg   *prometheus.Registry
+		guard *Guard
+		now   time.Time
+	)
+
+	newGuard := func(policy string) {
+		p, err := ParsePolicy([]byte(policy))
+		Expect(err).NotTo(HaveOccurred())
+		reg = prometheus.NewRegistry()
+		guard = NewGuard(p, reg)
+		now = time.Unix(0, 0)
+		guard.now = func() time.Time { return now }
+	}
+
+	It("should admit every request when nil", func() {
+		var g *Guard
+		release, rej := g.Admit([]string{"acme"})
+		Expect(rej).To(BeNil())
+		release()
+		Expect(g.QueryLimits([]string{"acme"})).To(Equal(Limits{}))
+	})
+
+	It("should enforce the token bucket of each tenant", func() {
+		newGuard("default: {requestsPerSecond: 1, burst: 2}")
+
+		for range 2 {
+			release, rej := guard.Admit([]string{"acme"})
+			Expect(rej).To(BeNil())
+			release()
+		}
+		_, rej := guard.Admit([]string{"acme"})
+		Expect(rej.Reason).To(Equal(ReasonRateLimited))
+		Expect(rej.Status).To(Equal(http.StatusTooManyRequests))
+		Expect(rej.RetryAfter).To(Equal(time.Second))
+
+		_, rej = guard.Admit([]string{"other"})
+		Expect(rej).To(BeNil())
+
+		now = now.Add(time.Second)
+		_, rej = guard.Admit([]string{"acme"})
+		Expect(rej).To(BeNil())
+	})
+
+	It("should not charge any tenant when one of them is rate limited", func() {
+		newGuard(`
+tenants:
+  a: {requestsPerSecond: 1, burst: 1}
+  b: {requestsPerSecond: 1, burst: 1}
+`)
+		_, rej := guard.Admit([]string{"b"})
+		Expect(rej).To(BeNil())
+
+		_, rej = guard.Admit([]string{"a", "b"})
+		Expect(rej.Reason).To(Equal(ReasonRateLimited))
+
+		_, rej = guard.Admit([]string{"a"})
+		Expect(rej).To(BeNil())
+	})
+
+	It("should limit the concurrent requests of a tenant", func() {
+		newGuard("default: {maxConcurrent: 1}")
+
+		release, rej := guard.Admit([]string{"acme"})
+		Expect(rej).To(BeNil())
+		Expect(testutil.ToFloat64(guard.inflight.WithLabelValues("acme"))).To(Equal(1.0))
+
+		_, rej = guard.Admit([]string{"acme"})
+		Expect(rej.Reason).To(Equal(ReasonConcurrencyLimited))
+
+		release()
+		release()
+		Expect(testutil.ToFloat64(guard.inflight.WithLabelValues("acme"))).To(BeZero())
+		_, rej = guard.Admit([]string{"acme"})
+		Expect(rej).To(BeNil())
+	})
+
+	It("should export the admitted and rejected requests", func() {
+		newGuard("default: {maxConcurrent: 1}")
+
+		_, _ = guard.Admit([]string{"acme"})
+		_, _ = guard.Admit([]string{"acme"})
+		guard.Reject([]string{"acme"}, badData(ReasonMaxRange, "too long"))
+
+		Expect(testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP kof_acl_guardrail_admitted_requests_total Number of tenant queries admitted by the guardrails.
+# TYPE kof_acl_guardrail_admitted_requests_total counter
+kof_acl_guardrail_admitted_requests_total{tenant="acme"} 1
+# HELP kof_acl_guardrail_rejections_total Number of tenant queries rejected by the guardrails, by reason.
+# TYPE kof_acl_guardrail_rejections_total counter
+kof_acl_guardrail_rejections_total{reason="concurrency_limited",tenant="acme"} 1
+kof_acl_guardrail_rejections_total{reason="max_range",tenant="acme"} 1
+`), "kof_acl_guardrail_admitted_requests_total", "kof_acl_guardrail_rejections_total")).To(Succeed())
+	})
+})
+
+var _ = Describe("Rejection", func() {
+	It("should write a Prometheus API error", func() {
+		recorder := httptest.NewRecorder()
+		(&Rejection{
+			Status:     http.StatusTooManyRequests,
+			ErrorType:  ErrorTypeUnavailable,
+			Message:    "slow down",
+			RetryAfter: 1500 * time.Millisecond,
+		}).Write(recorder)
+
+		Expect(recorder.Code).To(Equal(http.StatusTooManyRequests))
+		Expect(recorder.Header().Get("Retry-After")).To(Equal("2"))
+		Expect(recorder.Body.String()).To(MatchJSON(`{"status":"error","errorType":"unavailable","error":"slow down"}`))
+	})
+})
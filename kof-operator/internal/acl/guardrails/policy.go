@@ -0,0 +1,143 @@
+package guardrails
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"sigs.k8s.io/yaml"
+)
+
+// Limits are the query guardrails applied to the requests of a tenant.
+// A zero value disables the corresponding limit.
+type Limits struct {
+	// MaxRange is the maximum time range a query may select data from: the
+	// range of a query_range, series or labels request widened by the range
+	// selectors, subqueries, offsets and @ modifiers of the query.
+	MaxRange model.Duration `json:"maxRange,omitempty"`
+	// MinStep is the minimum resolution step of a query_range request.
+	MinStep model.Duration `json:"minStep,omitempty"`
+	// MaxSelectors is the maximum number of series selectors of a query.
+	MaxSelectors int `json:"maxSelectors,omitempty"`
+	// MaxSeries is the maximum number of series the selectors of a query may
+	// match, checked through /api/v1/series before the query is proxied.
+	MaxSeries int `json:"maxSeries,omitempty"`
+	// RequestsPerSecond is the refill rate of the token bucket of the tenant.
+	RequestsPerSecond float64 `json:"requestsPerSecond,omitempty"`
+	// Burst is the size of the token bucket of the tenant,
+	// RequestsPerSecond rounded up by default.
+	Burst int `json:"burst,omitempty"`
+	// MaxConcurrent is the maximum number of requests of the tenant in flight.
+	MaxConcurrent int `json:"maxConcurrent,omitempty"`
+}
+
+// Policy holds the default limits and their per-tenant overrides.
+type Policy struct {
+	// Default limits apply to every tenant.
+	Default Limits `json:"default,omitempty"`
+	// Tenants override the default limits by tenant ID; unset fields inherit
+	// the default.
+	Tenants map[string]Limits `json:"tenants,omitempty"`
+}
+
+// LoadPolicy reads and validates the YAML policy file.
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read query limits file: %w", err)
+	}
+	return ParsePolicy(data)
+}
+
+// ParsePolicy parses and validates the YAML policy.
+func ParsePolicy(data []byte) (*Policy, error) {
+	p := new(Policy)
+	if err := yaml.UnmarshalStrict(data, p); err != nil {
+		return nil, fmt.Errorf("failed to parse query limits: %w", err)
+	}
+	if err := p.Default.validate(); err != nil {
+		return nil, fmt.Errorf("default: %w", err)
+	}
+	for tenantID, limits := range p.Tenants {
+		if err := limits.validate(); err != nil {
+			return nil, fmt.Errorf("tenants[%s]: %w", tenantID, err)
+		}
+	}
+	return p, nil
+}
+
+func (l Limits) validate() error {
+	switch {
+	case l.MaxRange < 0, l.MinStep < 0:
+		return fmt.Errorf("durations must not be negative")
+	case l.MaxSelectors < 0, l.MaxSeries < 0, l.Burst < 0, l.MaxConcurrent < 0:
+		return fmt.Errorf("counts must not be negative")
+	case l.RequestsPerSecond < 0 || math.IsNaN(l.RequestsPerSecond) || math.IsInf(l.RequestsPerSecond, 0):
+		return fmt.Errorf("requestsPerSecond must be a non-negative number")
+	}
+	return nil
+}
+
+// TenantLimits returns the limits of a tenant: its overrides on top of the default.
+func (p *Policy) TenantLimits(tenantID string) Limits {
+	limits := p.Default
+	override, ok := p.Tenants[tenantID]
+	if !ok {
+		return limits
+	}
+	if override.MaxRange != 0 {
+		limits.MaxRange = override.MaxRange
+	}
+	if override.MinStep != 0 {
+		limits.MinStep = override.MinStep
+	}
+	if override.MaxSelectors != 0 {
+		limits.MaxSelectors = override.MaxSelectors
+	}
+	if override.MaxSeries != 0 {
+		limits.MaxSeries = override.MaxSeries
+	}
+	if override.RequestsPerSecond != 0 {
+		limits.RequestsPerSecond = override.RequestsPerSecond
+	}
+	if override.Burst != 0 {
+		limits.Burst = override.Burst
+	}
+	if override.MaxConcurrent != 0 {
+		limits.MaxConcurrent = override.MaxConcurrent
+	}
+	return limits
+}
+
+// QueryLimits returns the limits on the shape of a query reading the data of
+// tenants: the strictest limit of any of them.
+func (p *Policy) QueryLimits(tenants []string) Limits {
+	var limits Limits
+	for i, tenantID := range tenants {
+		l := p.TenantLimits(tenantID)
+		if i == 0 {
+			limits = l
+			continue
+		}
+		limits.MaxRange = model.Duration(strictest(time.Duration(limits.MaxRange), time.Duration(l.MaxRange)))
+		limits.MaxSelectors = strictest(limits.MaxSelectors, l.MaxSelectors)
+		limits.MaxSeries = strictest(limits.MaxSeries, l.MaxSeries)
+		// A larger step is the stricter one.
+		limits.MinStep = max(limits.MinStep, l.MinStep)
+	}
+	return limits
+}
+
+// strictest returns the smaller of two limits where 0 means unlimited.
+func strictest[T int | time.Duration](a, b T) T {
+	switch {
+	case a == 0:
+		return b
+	case b == 0:
+		return a
+	default:
+		return min(a, b)
+	}
+}
@@ -0,0 +1,65 @@
+package guardrails
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Prometheus API error types used in rejections.
+const (
+	ErrorTypeBadData     = "bad_data"
+	ErrorTypeExecution   = "execution"
+	ErrorTypeUnavailable = "unavailable"
+)
+
+// Rejection reasons, exported as the "reason" label of the rejection metrics.
+const (
+	ReasonInvalidRequest     = "invalid_request"
+	ReasonRateLimited        = "rate_limited"
+	ReasonConcurrencyLimited = "concurrency_limited"
+	ReasonMaxRange           = "max_range"
+	ReasonMinStep            = "min_step"
+	ReasonMaxSelectors       = "max_selectors"
+	ReasonMaxSeries          = "max_series"
+	ReasonPreflightFailed    = "preflight_failed"
+)
+
+// Rejection is a request refused by the guardrails.
+type Rejection struct {
+	// Status is the HTTP status code of the response.
+	Status int
+	// ErrorType is the Prometheus API error type of the response.
+	ErrorType string
+	// Reason identifies the guardrail in metrics.
+	Reason string
+	// Message is the error returned to the client.
+	Message string
+	// RetryAfter is set when the request may succeed later.
+	RetryAfter time.Duration
+}
+
+func (r *Rejection) Error() string {
+	return r.Message
+}
+
+// Write writes the rejection as a Prometheus API error response, so that
+// Grafana and other Prometheus clients display the message.
+func (r *Rejection) Write(w http.ResponseWriter) {
+	if r.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(r.RetryAfter.Seconds()))))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(r.Status)
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"status":    "error",
+		"errorType": r.ErrorType,
+		"error":     r.Message,
+	})
+}
+
+func badData(reason, message string) *Rejection {
+	return &Rejection{Status: http.StatusBadRequest, ErrorType: ErrorTypeBadData, Reason: reason, Message: message}
+}
@@ -0,0 +1,318 @@
+package guardrails
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+// lookbackDelta is the default Prometheus lookback of instant vector selectors.
+const lookbackDelta = 5 * time.Minute
+
+var promqlParser = parser.NewParser(parser.Options{})
+
+// Kind is the kind of a Prometheus API request, deciding which guardrails apply.
+type Kind int
+
+const (
+	// KindOther requests (format_query, parse_query) do not read data.
+	KindOther Kind = iota
+	// KindInstant is an instant query (/api/v1/query).
+	KindInstant
+	// KindRange is a range query (/api/v1/query_range).
+	KindRange
+	// KindExemplars is an exemplars query (/api/v1/query_exemplars).
+	KindExemplars
+	// KindMatch requests (series, labels, label values) select series by match[].
+	KindMatch
+)
+
+// RequestKind returns the kind of the Prometheus API request with the given path.
+func RequestKind(path string) Kind {
+	switch {
+	case strings.HasSuffix(path, "/query_range"):
+		return KindRange
+	case strings.HasSuffix(path, "/query_exemplars"):
+		return KindExemplars
+	case strings.HasSuffix(path, "/format_query"), strings.HasSuffix(path, "/parse_query"):
+		return KindOther
+	case strings.HasSuffix(path, "/query"):
+		return KindInstant
+	default:
+		return KindMatch
+	}
+}
+
+// Request is a Prometheus API request analysed for the guardrails.
+type Request struct {
+	Kind Kind
+	// Start and End are the evaluation range of the request; both are the
+	// evaluation time of an instant query.
+	Start, End time.Time
+	// Step is the resolution step of a range query.
+	Step time.Duration
+	// MinTime and MaxTime bound the data selected by the request.
+	MinTime, MaxTime time.Time
+	// Selectors are the deduplicated series selectors of the request.
+	Selectors []string
+}
+
+// Range returns the time range of the data selected by the request.
+func (r *Request) Range() time.Duration {
+	return r.MaxTime.Sub(r.MinTime)
+}
+
+// ParseRequest analyses the parameters of a Prometheus API request, after the
+// tenant matchers were injected. When maxRange is set, the start parameter of
+// a series, labels or exemplars request without one is set to end minus
+// maxRange, as Prometheus would otherwise read all data. now is the default
+// evaluation time.
+func ParseRequest(path string, params url.Values, maxRange time.Duration, now time.Time) (*Request, *Rejection) {
+	r := &Request{Kind: RequestKind(path)}
+
+	var err error
+	switch r.Kind {
+	case KindOther:
+		return r, nil
+
+	case KindInstant:
+		r.Start = now
+		if params.Has("time") {
+			if r.Start, err = parseTime(params.Get("time")); err != nil {
+				return nil, badData(ReasonInvalidRequest, fmt.Sprintf("invalid parameter \"time\": %v", err))
+			}
+		}
+		r.End = r.Start
+
+	case KindRange:
+		if r.Start, err = parseTime(params.Get("start")); err != nil {
+			return nil, badData(ReasonInvalidRequest, fmt.Sprintf("invalid parameter \"start\": %v", err))
+		}
+		if r.End, err = parseTime(params.Get("end")); err != nil {
+			return nil, badData(ReasonInvalidRequest, fmt.Sprintf("invalid parameter \"end\": %v", err))
+		}
+		if r.Step, err = parseDuration(params.Get("step")); err != nil {
+			return nil, badData(ReasonInvalidRequest, fmt.Sprintf("invalid parameter \"step\": %v", err))
+		}
+		if r.Step <= 0 {
+			return nil, badData(ReasonInvalidRequest,
+				"zero or negative query resolution step widths are not accepted. Try a positive integer")
+		}
+
+	case KindExemplars, KindMatch:
+		if r.End, r.Start, err = parseRange(params, maxRange, now); err != nil {
+			return nil, badData(ReasonInvalidRequest, err.Error())
+		}
+		if !params.Has("start") {
+			params.Set("start", formatTime(r.Start))
+		}
+	}
+	if r.End.Before(r.Start) {
+		return nil, badData(ReasonInvalidRequest, "end timestamp must not be before start time")
+	}
+
+	if r.Kind == KindMatch {
+		r.MinTime, r.MaxTime = r.Start, r.End
+		for _, match := range params["match[]"] {
+			if _, err := promqlParser.ParseMetricSelector(match); err != nil {
+				return nil, badData(ReasonInvalidRequest, fmt.Sprintf("invalid parameter \"match[]\": %v", err))
+			}
+			r.addSelector(match)
+		}
+		return r, nil
+	}
+
+	expr, err := promqlParser.ParseExpr(params.Get("query"))
+	if err != nil {
+		return nil, badData(ReasonInvalidRequest, fmt.Sprintf("invalid parameter \"query\": %v", err))
+	}
+	r.inspect(expr)
+	return r, nil
+}
+
+// parseRange returns the end and start of a series, labels or exemplars
+// request; end defaults to now and start to end minus maxRange.
+func parseRange(params url.Values, maxRange time.Duration, now time.Time) (end, start time.Time, err error) {
+	end = now
+	if params.Has("end") {
+		if end, err = parseTime(params.Get("end")); err != nil {
+			return end, start, fmt.Errorf("invalid parameter \"end\": %w", err)
+		}
+	}
+	switch {
+	case params.Has("start"):
+		if start, err = parseTime(params.Get("start")); err != nil {
+			return end, start, fmt.Errorf("invalid parameter \"start\": %w", err)
+		}
+	case maxRange > 0:
+		start = end.Add(-maxRange)
+	default:
+		start = time.Unix(0, 0).UTC()
+	}
+	return end, start, nil
+}
+
+// inspect records the selectors of expr and the bounds of the data they select.
+func (r *Request) inspect(expr parser.Expr) {
+	parser.Inspect(expr, func(node parser.Node, path []parser.Node) error {
+		vs, ok := node.(*parser.VectorSelector)
+		if !ok {
+			return nil
+		}
+
+		// Evaluation interval of the selector, narrowed or moved by the
+		// subqueries it is nested in, from the outermost one.
+		from, to := r.Start, r.End
+		for _, n := range path {
+			sq, ok := n.(*parser.SubqueryExpr)
+			if !ok {
+				continue
+			}
+			from, to = modifiedInterval(from, to, r, sq.Timestamp, sq.StartOrEnd)
+			from = from.Add(-sq.OriginalOffset - sq.Range)
+			to = to.Add(-sq.OriginalOffset)
+		}
+		from, to = modifiedInterval(from, to, r, vs.Timestamp, vs.StartOrEnd)
+		from, to = from.Add(-vs.OriginalOffset), to.Add(-vs.OriginalOffset)
+
+		if ms, ok := parentMatrix(path); ok {
+			from = from.Add(-ms.Range)
+		} else {
+			from = from.Add(-lookbackDelta)
+		}
+
+		if r.MinTime.IsZero() || from.Before(r.MinTime) {
+			r.MinTime = from
+		}
+		if r.MaxTime.IsZero() || to.After(r.MaxTime) {
+			r.MaxTime = to
+		}
+		r.addSelector(selectorString(vs))
+		return nil
+	})
+}
+
+// modifiedInterval applies an @ modifier to the evaluation interval.
+func modifiedInterval(from, to time.Time, r *Request, ts *int64, startOrEnd parser.ItemType) (time.Time, time.Time) {
+	switch {
+	case ts != nil:
+		t := time.UnixMilli(*ts).UTC()
+		return t, t
+	case startOrEnd == parser.START:
+		return r.Start, r.Start
+	case startOrEnd == parser.END:
+		return r.End, r.End
+	}
+	return from, to
+}
+
+func parentMatrix(path []parser.Node) (*parser.MatrixSelector, bool) {
+	if len(path) == 0 {
+		return nil, false
+	}
+	ms, ok := path[len(path)-1].(*parser.MatrixSelector)
+	return ms, ok
+}
+
+// selectorString returns the plain series selector of vs, without modifiers.
+func selectorString(vs *parser.VectorSelector) string {
+	matchers := make([]string, 0, len(vs.LabelMatchers))
+	for _, m := range vs.LabelMatchers {
+		matchers = append(matchers, m.String())
+	}
+	return "{" + strings.Join(matchers, ",") + "}"
+}
+
+func (r *Request) addSelector(selector string) {
+	if !slices.Contains(r.Selectors, selector) {
+		r.Selectors = append(r.Selectors, selector)
+	}
+}
+
+// Check checks the request against the query limits.
+func (r *Request) Check(limits Limits) *Rejection {
+	if r.Kind == KindOther {
+		return nil
+	}
+	if limits.MaxSelectors > 0 && len(r.Selectors) > limits.MaxSelectors {
+		return badData(ReasonMaxSelectors, fmt.Sprintf(
+			"query has %d series selectors, the limit is %d", len(r.Selectors), limits.MaxSelectors))
+	}
+	if maxRange := time.Duration(limits.MaxRange); maxRange > 0 && r.Range() > maxRange {
+		return badData(ReasonMaxRange, fmt.Sprintf(
+			"query selects data over %s, the limit is %s", model.Duration(r.Range()), limits.MaxRange))
+	}
+	if minStep := time.Duration(limits.MinStep); r.Kind == KindRange && minStep > 0 && r.Step < minStep {
+		return badData(ReasonMinStep, fmt.Sprintf(
+			"query resolution step %s is below the minimum of %s", model.Duration(r.Step), limits.MinStep))
+	}
+	return nil
+}
+
+// NeedsSeriesPreflight reports whether the series count of the request must
+// be checked before it is proxied. Series and labels requests are not
+// checked, as the preflight would cost as much as the request itself.
+func (r *Request) NeedsSeriesPreflight(limits Limits) bool {
+	return limits.MaxSeries > 0 && len(r.Selectors) > 0 && (r.Kind == KindInstant || r.Kind == KindRange)
+}
+
+// SeriesLimitExceeded returns the rejection of a request whose selectors match
+// more than the maximum number of series.
+func SeriesLimitExceeded(limits Limits) *Rejection {
+	return &Rejection{
+		Status:    http.StatusUnprocessableEntity,
+		ErrorType: ErrorTypeExecution,
+		Reason:    ReasonMaxSeries,
+		Message:   fmt.Sprintf("query selects more than %d series, narrow down its selectors or time range", limits.MaxSeries),
+	}
+}
+
+// PreflightFailed returns the rejection of a request whose series count could not be checked.
+func PreflightFailed(err error) *Rejection {
+	return &Rejection{
+		Status:    http.StatusServiceUnavailable,
+		ErrorType: ErrorTypeUnavailable,
+		Reason:    ReasonPreflightFailed,
+		Message:   fmt.Sprintf("failed to check the series count of the query: %v", err),
+	}
+}
+
+// parseTime parses a Prometheus API timestamp: Unix seconds or RFC 3339.
+func parseTime(s string) (time.Time, error) {
+	if t, err := strconv.ParseFloat(s, 64); err == nil {
+		sec, frac := math.Modf(t)
+		return time.Unix(int64(sec), int64(math.Round(frac*1000))*int64(time.Millisecond)).UTC(), nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("cannot parse %q to a valid timestamp", s)
+}
+
+// parseDuration parses a Prometheus API duration: seconds or a duration string.
+func parseDuration(s string) (time.Duration, error) {
+	if d, err := strconv.ParseFloat(s, 64); err == nil {
+		ts := d * float64(time.Second)
+		if ts > float64(math.MaxInt64) || ts < float64(math.MinInt64) {
+			return 0, fmt.Errorf("cannot parse %q to a valid duration. It overflows int64", s)
+		}
+		return time.Duration(ts), nil
+	}
+	if d, err := model.ParseDuration(s); err == nil {
+		return time.Duration(d), nil
+	}
+	return 0, fmt.Errorf("cannot parse %q to a valid duration", s)
+}
+
+// formatTime formats t as a Prometheus API timestamp.
+func formatTime(t time.Time) string {
+	return strconv.FormatFloat(float64(t.UnixMilli())/1000, 'f', -1, 64)
+}
@@ -0,0 +1,13 @@
+package guardrails
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestGuardrails(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "ACL Guardrails Suite")
+}
@@ -0,0 +1,397 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/go-openapi/strfmt"
+	"github.com/k0rdent/kof/kof-operator/internal/acl/auditlog"
+	"github.com/k0rdent/kof/kof-operator/internal/acl/authz"
+	"github.com/k0rdent/kof/kof-operator/internal/acl/tenant"
+	"github.com/k0rdent/kof/kof-operator/internal/server"
+	"github.com/prometheus/alertmanager/api/v2/models"
+)
+
+const (
+	alertmanagerPathPrefix  = "/alertmanager"
+	alertmanagerSilencePath = "/api/v2/silence/"
+)
+
+var (
+	// errSilenceTenantRequired is returned when the tenant of a new silence is ambiguous.
+	errSilenceTenantRequired = errors.New("the silence must have a " + TenantLabelName + "=\"<tenant>\" matcher, " +
+		"add it or select a single tenant with the " + TenantHeaderName + " header")
+	// errSilenceNotPermitted is returned when a silence may mute the alerts of data the user may not read.
+	errSilenceNotPermitted = errors.New("forbidden: the silence must only mute the alerts of the permitted tenants")
+)
+
+// AlertmanagerHandler handles Alertmanager v2 API requests with tenant isolation.
+// Alerts and silences are filtered by their tenant, and the silences of a tenant
+// always have a tenant="<tenant>" matcher, so that they cannot mute the alerts
+// of other tenants.
+type AlertmanagerHandler struct {
+	config Config
+}
+
+func NewAlertmanagerHandler(cfg Config) Proxy {
+	return &AlertmanagerHandler{config: cfg}
+}
+
+func (h *AlertmanagerHandler) TenantResolver() tenant.Resolver { return h.config.TenantResolver }
+func (h *AlertmanagerHandler) IsDevMode() bool                 { return h.config.DevMode }
+func (h *AlertmanagerHandler) Schema() string                  { return h.config.Scheme }
+func (h *AlertmanagerHandler) Host() string                    { return h.config.Host }
+
+func (h *AlertmanagerHandler) HandleTenantInjection(res *server.Response, req *http.Request, identity *tenant.Identity) {
+	path := strings.TrimPrefix(req.URL.Path, alertmanagerPathPrefix)
+	silenceID, isSilencePath := strings.CutPrefix(path, alertmanagerSilencePath)
+
+	var handle func(res *server.Response, req *http.Request, path string, identity *tenant.Identity, scope *authz.Scope)
+	switch {
+	case req.Method == http.MethodGet && path == "/api/v2/status":
+		handle = h.getStatus
+	case req.Method == http.MethodGet && path == "/api/v2/alerts":
+		handle = h.getAlerts
+	case req.Method == http.MethodGet && path == "/api/v2/alerts/groups":
+		handle = h.getAlertGroups
+	case req.Method == http.MethodGet && path == "/api/v2/silences":
+		handle = h.getSilences
+	case req.Method == http.MethodPost && path == "/api/v2/silences":
+		handle = h.postSilence
+	case isSilencePath && silenceID != "" && !strings.Contains(silenceID, "/") &&
+		(req.Method == http.MethodGet || req.Method == http.MethodDelete):
+		handle = h.handleSilence
+	default:
+		res.Fail(fmt.Sprintf("Forbidden: %s %s is not available to tenants", req.Method, path), http.StatusForbidden)
+		return
+	}
+
+	scope, ok := requestScope(res, req, identity, h.config.Authorizer, authz.SignalMetrics)
+	if !ok {
+		return
+	}
+	handle(res, req, path, identity, scope)
+}
+
+// getStatus returns the status of Alertmanager without its configuration, which
+// has the receivers and routes of all tenants.
+func (h *AlertmanagerHandler) getStatus(
+	res *server.Response,
+	req *http.Request,
+	path string,
+	identity *tenant.Identity,
+	scope *authz.Scope,
+) {
+	status := new(models.AlertmanagerStatus)
+	if !h.getJSON(res, req, path, nil, status) {
+		return
+	}
+	original := ""
+	status.Config = &models.AlertmanagerConfig{Original: &original}
+	res.SendObj(status, http.StatusOK)
+}
+
+func (h *AlertmanagerHandler) getAlerts(
+	res *server.Response,
+	req *http.Request,
+	path string,
+	identity *tenant.Identity,
+	scope *authz.Scope,
+) {
+	query, ok := alertsFilterQuery(res, req, scope)
+	if !ok {
+		return
+	}
+
+	var alerts models.GettableAlerts
+	if !h.getJSON(res, req, path, query, &alerts) {
+		return
+	}
+	res.SendObj(filterGettableAlertsByScope(alerts, scope), http.StatusOK)
+}
+
+func (h *AlertmanagerHandler) getAlertGroups(
+	res *server.Response,
+	req *http.Request,
+	path string,
+	identity *tenant.Identity,
+	scope *authz.Scope,
+) {
+	query, ok := alertsFilterQuery(res, req, scope)
+	if !ok {
+		return
+	}
+
+	var groups models.AlertGroups
+	if !h.getJSON(res, req, path, query, &groups) {
+		return
+	}
+
+	filteredGroups := make(models.AlertGroups, 0, len(groups))
+	for _, group := range groups {
+		if group == nil {
+			continue
+		}
+		if group.Alerts = filterGettableAlertsByScope(group.Alerts, scope); len(group.Alerts) > 0 {
+			filteredGroups = append(filteredGroups, group)
+		}
+	}
+	res.SendObj(filteredGroups, http.StatusOK)
+}
+
+func (h *AlertmanagerHandler) getSilences(
+	res *server.Response,
+	req *http.Request,
+	path string,
+	identity *tenant.Identity,
+	scope *authz.Scope,
+) {
+	var silences models.GettableSilences
+	if !h.getJSON(res, req, path, req.URL.Query(), &silences) {
+		return
+	}
+
+	filteredSilences := make(models.GettableSilences, 0, len(silences))
+	for _, silence := range silences {
+		if silence != nil && silenceAllowed(silence.Matchers, scope) {
+			filteredSilences = append(filteredSilences, silence)
+		}
+	}
+	res.SendObj(filteredSilences, http.StatusOK)
+}
+
+func (h *AlertmanagerHandler) postSilence(
+	res *server.Response,
+	req *http.Request,
+	path string,
+	identity *tenant.Identity,
+	scope *authz.Scope,
+) {
+	req.Body = http.MaxBytesReader(res.Writer, req.Body, MaxBodySize)
+	silence := new(models.PostableSilence)
+	if err := json.NewDecoder(req.Body).Decode(silence); err != nil {
+		res.Fail(fmt.Sprintf("failed to decode silence: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := silence.Validate(strfmt.Default); err != nil {
+		res.Fail(fmt.Sprintf("invalid silence: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := scopeSilence(silence, scope); err != nil {
+		auditlog.Record(req.Context(), auditlog.Decision{
+			Policy: auditlog.PolicySilence, Reason: err.Error(), Actor: identity.Email, Tenants: scope.Tenants(),
+		})
+		if errors.Is(err, errSilenceTenantRequired) {
+			res.Fail(err.Error(), http.StatusBadRequest)
+		} else {
+			res.Fail(err.Error(), http.StatusForbidden)
+		}
+		return
+	}
+
+	// Updating a silence replaces it, so it must be a silence of the user too.
+	if silence.ID != "" {
+		if _, ok := h.getSilence(res, req, silence.ID, scope); !ok {
+			return
+		}
+	}
+
+	body, err := json.Marshal(silence)
+	if err != nil {
+		res.Fail(fmt.Sprintf("failed to encode silence: %v", err), http.StatusInternalServerError)
+		return
+	}
+	h.forward(res, req, http.MethodPost, path, body)
+}
+
+func (h *AlertmanagerHandler) handleSilence(
+	res *server.Response,
+	req *http.Request,
+	path string,
+	identity *tenant.Identity,
+	scope *authz.Scope,
+) {
+	silenceID := strings.TrimPrefix(path, alertmanagerSilencePath)
+	silence, ok := h.getSilence(res, req, silenceID, scope)
+	if !ok {
+		return
+	}
+
+	if req.Method == http.MethodDelete {
+		h.forward(res, req, http.MethodDelete, path, nil)
+		return
+	}
+	res.SendObj(silence, http.StatusOK)
+}
+
+// getSilence returns the silence, failing the response when the scope does not
+// grant it, as if it did not exist.
+func (h *AlertmanagerHandler) getSilence(
+	res *server.Response,
+	req *http.Request,
+	silenceID string,
+	scope *authz.Scope,
+) (*models.GettableSilence, bool) {
+	silence := new(models.GettableSilence)
+	if !h.getJSON(res, req, alertmanagerSilencePath+url.PathEscape(silenceID), nil, silence) {
+		return nil, false
+	}
+	if !silenceAllowed(silence.Matchers, scope) {
+		res.Fail(fmt.Sprintf("silence %s not found", silenceID), http.StatusNotFound)
+		return nil, false
+	}
+	return silence, true
+}
+
+// getJSON decodes the response of an Alertmanager API GET request, failing the response otherwise.
+func (h *AlertmanagerHandler) getJSON(
+	res *server.Response,
+	req *http.Request,
+	path string,
+	query url.Values,
+	v any,
+) bool {
+	backendURL := BuildURL(h.config.Scheme, h.config.Host, path, query.Encode())
+	resp, err := ProxyRequest(req.Context(), backendURL, http.MethodGet, nil)
+	if err != nil {
+		res.Fail(fmt.Sprintf("failed to proxy request: %v", err), http.StatusInternalServerError)
+		return false
+	}
+
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			res.Logger.Error(err, "failed to close response body")
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		res.Fail(fmt.Sprintf("received non-OK response: %s", resp.Status), resp.StatusCode)
+		return false
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		res.Fail(fmt.Sprintf("failed to decode response: %v", err), http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
+
+// forward sends the request to Alertmanager and copies its response, so that
+// the validation errors of Alertmanager reach the client.
+func (h *AlertmanagerHandler) forward(res *server.Response, req *http.Request, method, path string, body []byte) {
+	var contentType string
+	var reader io.Reader
+	if body != nil {
+		contentType = "application/json"
+		reader = bytes.NewReader(body)
+	}
+
+	backendURL := BuildURL(h.config.Scheme, h.config.Host, path, "")
+	resp, err := proxyRequest(req.Context(), backendURL, method, contentType, reader)
+	if err != nil {
+		res.Fail(fmt.Sprintf("failed to proxy request: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			res.Logger.Error(err, "failed to close response body")
+		}
+	}()
+
+	if contentType := resp.Header.Get("Content-Type"); contentType != "" {
+		res.SetContentType(contentType)
+	}
+	res.SetStatus(resp.StatusCode)
+	if _, err := io.Copy(res.Writer, resp.Body); err != nil {
+		res.Logger.Error(err, "failed to proxy response body")
+	}
+}
+
+// alertsFilterQuery returns the query of an alerts request, filtered by the
+// tenants of the scope so that Alertmanager does not send the alerts of others.
+func alertsFilterQuery(res *server.Response, req *http.Request, scope *authz.Scope) (url.Values, bool) {
+	matcher, err := tenantMatcher(scope.Tenants())
+	if err != nil {
+		res.Fail(fmt.Sprintf("failed to create tenant matcher: %v", err), http.StatusInternalServerError)
+		return nil, false
+	}
+	query := req.URL.Query()
+	query.Add("filter", matcher.String())
+	return query, true
+}
+
+// filterGettableAlertsByScope returns the alerts whose tenant, cluster and
+// namespace labels are granted by the scope.
+func filterGettableAlertsByScope(alerts []*models.GettableAlert, scope *authz.Scope) []*models.GettableAlert {
+	matchingAlerts := make([]*models.GettableAlert, 0, len(alerts))
+	for _, alert := range alerts {
+		if alert == nil || len(alert.Labels) == 0 {
+			continue
+		}
+		if scope.Allows(alert.Labels[TenantLabelName], alert.Labels[ClusterLabelName], alert.Labels[NamespaceLabelName]) {
+			matchingAlerts = append(matchingAlerts, alert)
+		}
+	}
+	return matchingAlerts
+}
+
+// scopeSilence adds the tenant matcher of the single tenant of the scope to a
+// silence without tenant matchers, and checks the silence only mutes alerts of
+// the scope.
+func scopeSilence(silence *models.PostableSilence, scope *authz.Scope) error {
+	hasTenantMatcher := slices.ContainsFunc(silence.Matchers, func(matcher *models.Matcher) bool {
+		return matcher != nil && matcher.Name != nil && *matcher.Name == TenantLabelName
+	})
+	if !hasTenantMatcher {
+		tenants := scope.Tenants()
+		if len(tenants) != 1 {
+			return errSilenceTenantRequired
+		}
+		isEqual, isRegex := true, false
+		name, value := TenantLabelName, tenants[0]
+		silence.Matchers = append(silence.Matchers, &models.Matcher{
+			Name: &name, Value: &value, IsEqual: &isEqual, IsRegex: &isRegex,
+		})
+	}
+
+	if !silenceAllowed(silence.Matchers, scope) {
+		return errSilenceNotPermitted
+	}
+	return nil
+}
+
+// silenceAllowed reports whether the silence only mutes alerts of the scope:
+// its tenant matcher selects a tenant of the scope and, when the scope restricts
+// the clusters or namespaces of the tenant, its cluster and namespace matchers
+// select granted ones.
+func silenceAllowed(matchers models.Matchers, scope *authz.Scope) bool {
+	tenantID, ok := equalMatcherValue(matchers, TenantLabelName)
+	if !ok {
+		return false
+	}
+	cluster, _ := equalMatcherValue(matchers, ClusterLabelName)
+	namespace, _ := equalMatcherValue(matchers, NamespaceLabelName)
+	return scope.Allows(tenantID, cluster, namespace)
+}
+
+// equalMatcherValue returns the value of the first name="value" matcher.
+func equalMatcherValue(matchers models.Matchers, name string) (string, bool) {
+	for _, matcher := range matchers {
+		if matcher == nil || matcher.Name == nil || *matcher.Name != name || matcher.Value == nil {
+			continue
+		}
+		if (matcher.IsRegex != nil && *matcher.IsRegex) || (matcher.IsEqual != nil && !*matcher.IsEqual) {
+			continue
+		}
+		return *matcher.Value, true
+	}
+	return "", false
+}
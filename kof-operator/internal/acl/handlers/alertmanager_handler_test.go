@@ -0,0 +1,303 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/k0rdent/kof/kof-operator/internal/acl/tenant"
+	"github.com/k0rdent/kof/kof-operator/internal/server"
+	"github.com/k0rdent/kof/kof-operator/internal/server/helper"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// alertmanagerStandIn serves the Alertmanager v2 API endpoints proxied by the
+// ACL server from memory.
+type alertmanagerStandIn struct {
+	mu       sync.Mutex
+	alerts   []map[string]any
+	silences map[string]map[string]any
+	nextID   int
+	filters  []string
+}
+
+func (am *alertmanagerStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	send := func(v any) {
+		w.Header().Set("Content-Type", "application/json")
+		Expect(json.NewEncoder(w).Encode(v)).To(Succeed())
+	}
+	silenceID, isSilencePath := strings.CutPrefix(r.URL.Path, "/api/v2/silence/")
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v2/status":
+		send(map[string]any{
+			"cluster":     map[string]any{"status": "ready"},
+			"config":      map[string]any{"original": "route:\n  receiver: tenant-b-pager\n"},
+			"uptime":      "2026-10-18T10:00:00.000Z",
+			"versionInfo": map[string]any{"version": "0.27.0"},
+		})
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v2/alerts":
+		am.filters = r.URL.Query()["filter"]
+		send(am.alerts)
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v2/alerts/groups":
+		am.filters = r.URL.Query()["filter"]
+		send([]map[string]any{
+			{"labels": map[string]any{"alertname": "HighLatency"}, "receiver": map[string]any{"name": "default"},
+				"alerts": am.alerts},
+			{"labels": map[string]any{"alertname": "DiskFull"}, "receiver": map[string]any{"name": "default"},
+				"alerts": []map[string]any{}},
+		})
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v2/silences":
+		silences := make([]map[string]any, 0, len(am.silences))
+		for _, silence := range am.silences {
+			silences = append(silences, silence)
+		}
+		send(silences)
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v2/silences":
+		if r.Header.Get("Content-Type") != "application/json" {
+			http.Error(w, "unsupported content type", http.StatusUnsupportedMediaType)
+			return
+		}
+		silence := map[string]any{}
+		Expect(json.NewDecoder(r.Body).Decode(&silence)).To(Succeed())
+		id, _ := silence["id"].(string)
+		if id == "" {
+			am.nextID++
+			id = fmt.Sprintf("new-%d", am.nextID)
+		}
+		silence["id"] = id
+		am.silences[id] = silence
+		send(map[string]any{"silenceID": id})
+	case isSilencePath && am.silences[silenceID] == nil:
+		http.Error(w, "silence not found", http.StatusNotFound)
+	case isSilencePath && r.Method == http.MethodGet:
+		send(am.silences[silenceID])
+	case isSilencePath && r.Method == http.MethodDelete:
+		delete(am.silences, silenceID)
+		w.WriteHeader(http.StatusOK)
+	default:
+		http.Error(w, "unexpected request", http.StatusTeapot)
+	}
+}
+
+func testAlert(tenantID, cluster string) map[string]any {
+	return map[string]any{
+		"labels":      map[string]any{"alertname": "HighLatency", "tenant": tenantID, "cluster": cluster},
+		"annotations": map[string]any{},
+		"fingerprint": tenantID + "-" + cluster,
+		"receivers":   []map[string]any{{"name": "default"}},
+		"startsAt":    "2026-10-18T10:00:00.000Z",
+		"endsAt":      "2026-10-18T11:00:00.000Z",
+		"updatedAt":   "2026-10-18T10:00:00.000Z",
+		"status":      map[string]any{"state": "active", "inhibitedBy": []string{}, "silencedBy": []string{}},
+	}
+}
+
+func testSilence(id string, matchers ...map[string]any) map[string]any {
+	silence := map[string]any{
+		"comment":   "maintenance",
+		"createdBy": "sre@example.com",
+		"startsAt":  "2026-10-18T10:00:00.000Z",
+		"endsAt":    "2026-10-18T12:00:00.000Z",
+		"matchers":  matchers,
+	}
+	if id != "" {
+		silence["id"] = id
+		silence["status"] = map[string]any{"state": "active"}
+		silence["updatedAt"] = "2026-10-18T10:00:00.000Z"
+	}
+	return silence
+}
+
+func testMatcher(name, value string, isRegex bool) map[string]any {
+	return map[string]any{"name": name, "value": value, "isRegex": isRegex, "isEqual": true}
+}
+
+var _ = Describe("AlertmanagerHandler", func() {
+	var (
+		res          *server.Response
+		alertmanager *alertmanagerStandIn
+		mockBackend  *httptest.Server
+		handler      *AlertmanagerHandler
+		logger       = ctrl.Log.WithName("test")
+	)
+
+	BeforeEach(func() {
+		alertmanager = &alertmanagerStandIn{
+			alerts: []map[string]any{testAlert("a", "prod"), testAlert("b", "prod")},
+			silences: map[string]map[string]any{
+				"silence-a":     testSilence("silence-a", testMatcher("tenant", "a", false)),
+				"silence-b":     testSilence("silence-b", testMatcher("tenant", "b", false)),
+				"silence-regex": testSilence("silence-regex", testMatcher("tenant", "a|b", true)),
+			},
+		}
+		mockBackend = httptest.NewServer(alertmanager)
+
+		parsedURL, err := url.Parse(mockBackend.URL)
+		Expect(err).NotTo(HaveOccurred())
+
+		handler = &AlertmanagerHandler{config: Config{Host: parsedURL.Host, Scheme: "http"}}
+		res = &server.Response{
+			Writer: httptest.NewRecorder(),
+			Logger: &logger,
+		}
+	})
+
+	AfterEach(func() {
+		mockBackend.Close()
+	})
+
+	request := func(method, path string, body any, groups ...any) *http.Request {
+		var req *http.Request
+		if body != nil {
+			data, err := json.Marshal(body)
+			Expect(err).NotTo(HaveOccurred())
+			req = httptest.NewRequest(method, "/alertmanager"+path, strings.NewReader(string(data)))
+			req.Header.Set("Content-Type", "application/json")
+		} else {
+			req = httptest.NewRequest(method, "/alertmanager"+path, nil)
+		}
+		idToken := MockIDToken(map[string]any{
+			"email":  "dev@example.com",
+			"groups": groups,
+		})
+		return req.WithContext(context.WithValue(req.Context(), helper.IdTokenContextKey, idToken))
+	}
+
+	decode := func(v any) {
+		recorder := res.Writer.(*httptest.ResponseRecorder)
+		Expect(recorder.Code).To(Equal(http.StatusOK), recorder.Body.String())
+		Expect(json.Unmarshal(recorder.Body.Bytes(), v)).To(Succeed())
+	}
+
+	It("should filter the alerts by tenant", func() {
+		ACLProxy(res, request(http.MethodGet, "/api/v2/alerts", nil, "tenant:a"), handler)
+
+		var alerts []map[string]any
+		decode(&alerts)
+		Expect(alerts).To(HaveLen(1))
+		Expect(alerts[0]["fingerprint"]).To(Equal("a-prod"))
+		Expect(alertmanager.filters).To(Equal([]string{`tenant="a"`}))
+	})
+
+	It("should filter the alerts of the alert groups and drop the empty groups", func() {
+		ACLProxy(res, request(http.MethodGet, "/api/v2/alerts/groups", nil, "tenant:b"), handler)
+
+		var groups []struct {
+			Alerts []map[string]any `json:"alerts"`
+		}
+		decode(&groups)
+		Expect(groups).To(HaveLen(1))
+		Expect(groups[0].Alerts).To(HaveLen(1))
+		Expect(groups[0].Alerts[0]["fingerprint"]).To(Equal("b-prod"))
+	})
+
+	It("should only list the silences with a tenant matcher of the user", func() {
+		ACLProxy(res, request(http.MethodGet, "/api/v2/silences", nil, "tenant:a"), handler)
+
+		var silences []map[string]any
+		decode(&silences)
+		Expect(silences).To(HaveLen(1))
+		Expect(silences[0]["id"]).To(Equal("silence-a"))
+	})
+
+	It("should add the tenant matcher to a silence of a single tenant", func() {
+		silence := testSilence("", testMatcher("alertname", "HighLatency", false))
+
+		ACLProxy(res, request(http.MethodPost, "/api/v2/silences", silence, "tenant:a"), handler)
+
+		var created map[string]string
+		decode(&created)
+		Expect(created["silenceID"]).To(Equal("new-1"))
+		Expect(alertmanager.silences["new-1"]["matchers"]).To(ConsistOf(
+			HaveKeyWithValue("name", "alertname"),
+			And(HaveKeyWithValue("name", "tenant"), HaveKeyWithValue("value", "a"), HaveKeyWithValue("isRegex", false)),
+		))
+	})
+
+	It("should require a tenant matcher when the user has several tenants", func() {
+		silence := testSilence("", testMatcher("alertname", "HighLatency", false))
+
+		ACLProxy(res, request(http.MethodPost, "/api/v2/silences", silence, "tenant:a", "tenant:b"), handler)
+
+		Expect(res.Writer.(*httptest.ResponseRecorder).Code).To(Equal(http.StatusBadRequest))
+		Expect(alertmanager.silences).To(HaveLen(3))
+	})
+
+	DescribeTable("should forbid silences muting the alerts of other tenants",
+		func(matchers ...map[string]any) {
+			silence := testSilence("", matchers...)
+
+			ACLProxy(res, request(http.MethodPost, "/api/v2/silences", silence, "tenant:a", "tenant:c"), handler)
+
+			Expect(res.Writer.(*httptest.ResponseRecorder).Code).To(Equal(http.StatusForbidden))
+			Expect(alertmanager.silences).To(HaveLen(3))
+		},
+		Entry("other tenant", testMatcher("tenant", "b", false)),
+		Entry("regex tenant", testMatcher("tenant", "a|b", true)),
+		Entry("negative tenant", map[string]any{"name": "tenant", "value": "a", "isRegex": false, "isEqual": false}),
+	)
+
+	It("should not update the silences of other tenants", func() {
+		silence := testSilence("silence-b", testMatcher("tenant", "a", false))
+
+		ACLProxy(res, request(http.MethodPost, "/api/v2/silences", silence, "tenant:a"), handler)
+
+		Expect(res.Writer.(*httptest.ResponseRecorder).Code).To(Equal(http.StatusNotFound))
+		Expect(alertmanager.silences["silence-b"]["matchers"]).To(ConsistOf(HaveKeyWithValue("value", "b")))
+	})
+
+	It("should only get and delete the silences of the user", func() {
+		ACLProxy(res, request(http.MethodGet, "/api/v2/silence/silence-a", nil, "tenant:a"), handler)
+		var silence map[string]any
+		decode(&silence)
+		Expect(silence["id"]).To(Equal("silence-a"))
+
+		res.Writer = httptest.NewRecorder()
+		ACLProxy(res, request(http.MethodDelete, "/api/v2/silence/silence-b", nil, "tenant:a"), handler)
+		Expect(res.Writer.(*httptest.ResponseRecorder).Code).To(Equal(http.StatusNotFound))
+		Expect(alertmanager.silences).To(HaveKey("silence-b"))
+
+		res.Writer = httptest.NewRecorder()
+		ACLProxy(res, request(http.MethodDelete, "/api/v2/silence/silence-a", nil, "tenant:a"), handler)
+		Expect(res.Writer.(*httptest.ResponseRecorder).Code).To(Equal(http.StatusOK))
+		Expect(alertmanager.silences).NotTo(HaveKey("silence-a"))
+	})
+
+	It("should hide the configuration in the status", func() {
+		ACLProxy(res, request(http.MethodGet, "/api/v2/status", nil, "tenant:a"), handler)
+
+		var status map[string]any
+		decode(&status)
+		Expect(status["config"]).To(Equal(map[string]any{"original": ""}))
+		Expect(status["versionInfo"]).To(HaveKeyWithValue("version", "0.27.0"))
+	})
+
+	It("should deny the endpoints that are not available to tenants", func() {
+		ACLProxy(res, request(http.MethodPost, "/api/v2/alerts", []any{}, "tenant:a"), handler)
+
+		Expect(res.Writer.(*httptest.ResponseRecorder).Code).To(Equal(http.StatusForbidden))
+	})
+
+	It("should let admins create any silence with its JSON content type", func() {
+		policy, err := tenant.ParsePolicy([]byte("adminGroups: [kof-admins]\n"))
+		Expect(err).NotTo(HaveOccurred())
+		handler.config.TenantResolver = policy
+		silence := testSilence("", testMatcher("alertname", "HighLatency", false))
+
+		ACLProxy(res, request(http.MethodPost, "/api/v2/silences", silence, "kof-admins"), handler)
+
+		Expect(res.Writer.(*httptest.ResponseRecorder).Code).To(Equal(http.StatusOK))
+		Expect(alertmanager.silences["new-1"]["matchers"]).To(ConsistOf(HaveKeyWithValue("name", "alertname")))
+	})
+})
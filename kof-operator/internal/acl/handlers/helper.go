@@ -2,20 +2,31 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 
 	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/k0rdent/kof/kof-operator/internal/acl/auditlog"
+	"github.com/k0rdent/kof/kof-operator/internal/acl/authz"
+	"github.com/k0rdent/kof/kof-operator/internal/acl/tenant"
 	"github.com/k0rdent/kof/kof-operator/internal/server"
 	"github.com/k0rdent/kof/kof-operator/internal/server/helper"
 	"github.com/k0rdent/kof/kof-operator/internal/telemetry"
 )
 
-const TenantLabelName = "tenant"
+const (
+	TenantLabelName = "tenant"
+	// TenantHeaderName is the optional request header narrowing the query to one of the permitted tenants.
+	TenantHeaderName = "X-Kof-Tenant"
+)
+
+var defaultTenantPolicy = tenant.DefaultPolicy()
 
 const (
 	KBytes = 1024
@@ -26,13 +37,13 @@ const (
 )
 
 type Proxy interface {
-	// HandleTenantInjection processes the incoming request, extracts tenant information from the authenticated user's token,
+	// HandleTenantInjection processes the incoming request using the tenants resolved from the authenticated user's token,
 	// and modifies the request to include tenant-specific filters before proxying it to the backend service.
-	HandleTenantInjection(res *server.Response, req *http.Request, idToken *oidc.IDToken)
+	HandleTenantInjection(res *server.Response, req *http.Request, identity *tenant.Identity)
 	// IsDevMode reports whether development mode is enabled, allowing requests to bypass tenant and admin checks.
 	IsDevMode() bool
-	// AdminEmail returns the configured admin email for access control checks.
-	AdminEmail() string
+	// TenantResolver returns the resolver of tenants and admin privileges used for access control checks.
+	TenantResolver() tenant.Resolver
 	// Schema returns the URL scheme (http or https) to be used when constructing the backend service URL.
 	Schema() string
 	// Host returns the backend service host (including port if necessary) to which the request should be proxied.
@@ -47,23 +58,33 @@ func ACLProxy(res *server.Response, req *http.Request, proxy Proxy) {
 		}
 	}()
 
-	// Check for authenticated user with ID token
-	if idToken, ok := helper.GetIDToken(ctx); ok {
-		if isAdminUser(idToken, proxy.AdminEmail()) {
+	// Check for authenticated user or machine client
+	if identity, actor, ok, err := requestIdentity(ctx, proxy.TenantResolver()); ok {
+		if err != nil {
+			reason := fmt.Sprintf("failed to resolve user identity: %v", err)
+			auditlog.Record(ctx, auditlog.Decision{Policy: auditlog.PolicyAuthentication, Reason: reason, Actor: actor})
+			res.Fail(reason, http.StatusUnauthorized)
+			return
+		}
+
+		if identity.Admin {
+			auditlog.Record(ctx, auditlog.Decision{Allowed: true, Policy: auditlog.PolicyAdminBypass, Actor: identity.Email})
 			ProxyBypass(res, req, proxy)
 			return
 		}
 
-		proxy.HandleTenantInjection(res, req, idToken)
+		proxy.HandleTenantInjection(res, req, identity)
 		return
 	}
 
 	// Allow unrestricted access in development mode
 	if proxy.IsDevMode() {
+		auditlog.Record(ctx, auditlog.Decision{Allowed: true, Policy: auditlog.PolicyDevMode})
 		ProxyBypass(res, req, proxy)
 		return
 	}
 
+	auditlog.Record(ctx, auditlog.Decision{Policy: auditlog.PolicyAuthentication, Reason: "authentication required"})
 	res.Fail("Unauthorized: authentication required", http.StatusUnauthorized)
 }
 
@@ -75,18 +96,20 @@ func AdminProxy(res *server.Response, req *http.Request, proxy Proxy) {
 		}
 	}()
 
-	if idToken, ok := helper.GetIDToken(ctx); ok {
-		if isAdminUser(idToken, proxy.AdminEmail()) {
-			ProxyBypass(res, req, proxy)
-			return
-		}
+	identity, actor, ok, err := requestIdentity(ctx, proxy.TenantResolver())
+	if ok && err == nil && identity.Admin {
+		auditlog.Record(ctx, auditlog.Decision{Allowed: true, Policy: auditlog.PolicyAdminBypass, Actor: identity.Email})
+		ProxyBypass(res, req, proxy)
+		return
 	}
 
 	if proxy.IsDevMode() {
+		auditlog.Record(ctx, auditlog.Decision{Allowed: true, Policy: auditlog.PolicyDevMode, Actor: actor})
 		ProxyBypass(res, req, proxy)
 		return
 	}
 
+	auditlog.Record(ctx, auditlog.Decision{Policy: auditlog.PolicyAdminRequired, Reason: "admin access required", Actor: actor})
 	res.Fail("Forbidden: admin access required", http.StatusForbidden)
 }
 
@@ -103,7 +126,8 @@ func ProxyBypass(res *server.Response, req *http.Request, proxy Proxy) {
 
 	targetURL := BuildURL(proxy.Schema(), proxy.Host(), path, req.URL.Query().Encode())
 
-	if err := StreamProxyRequest(req.Context(), targetURL, req.Method, body, res.Writer); err != nil {
+	contentType := req.Header.Get("Content-Type")
+	if err := streamProxyRequest(req.Context(), targetURL, req.Method, contentType, body, res.Writer); err != nil {
 		res.Logger.Error(err, "failed to proxy request: "+targetURL)
 		http.Error(res.Writer, "unable to make request", http.StatusInternalServerError)
 		return
@@ -111,7 +135,16 @@ func ProxyBypass(res *server.Response, req *http.Request, proxy Proxy) {
 }
 
 func StreamProxyRequest(ctx context.Context, url, method string, body io.Reader, writer http.ResponseWriter) error {
-	resp, err := ProxyRequest(ctx, url, method, body)
+	return streamProxyRequest(ctx, url, method, "", body, writer)
+}
+
+func streamProxyRequest(
+	ctx context.Context,
+	url, method, contentType string,
+	body io.Reader,
+	writer http.ResponseWriter,
+) error {
+	resp, err := proxyRequest(ctx, url, method, contentType, body)
 	if err != nil {
 		return fmt.Errorf("failed to proxy request: %w", err)
 	}
@@ -137,13 +170,24 @@ func StreamProxyRequest(ctx context.Context, url, method string, body io.Reader,
 
 // ProxyRequest creates and executes an HTTP request to a backend service.
 func ProxyRequest(ctx context.Context, promxyURL, method string, body io.Reader) (*http.Response, error) {
+	return proxyRequest(ctx, promxyURL, method, "", body)
+}
+
+// proxyRequest is ProxyRequest sending the body with the content type, a form by default.
+func proxyRequest(ctx context.Context, promxyURL, method, contentType string, body io.Reader) (*http.Response, error) {
 	proxyReq, err := http.NewRequestWithContext(ctx, method, promxyURL, body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create proxy request: %w", err)
 	}
 
-	if method == http.MethodPost {
-		proxyReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if contentType == "" && method == http.MethodPost {
+		contentType = "application/x-www-form-urlencoded"
+	}
+	if contentType != "" {
+		proxyReq.Header.Set("Content-Type", contentType)
+	}
+	if correlationID := auditlog.CorrelationID(ctx); correlationID != "" {
+		proxyReq.Header.Set(auditlog.CorrelationIDHeader, correlationID)
 	}
 
 	client := &http.Client{Transport: telemetry.NewTransport(nil)}
@@ -155,22 +199,106 @@ func ProxyRequest(ctx context.Context, promxyURL, method string, body io.Reader)
 	return proxyResp, nil
 }
 
-func ExtractTenantIDFromToken(idToken *oidc.IDToken) (string, error) {
-	claims := new(Claims)
-	if err := idToken.Claims(claims); err != nil {
-		return "", fmt.Errorf("failed to parse claims: %w", err)
+// requestIdentity resolves the identity of the user or machine client authenticated by
+// server.AuthenticationMiddleware. ok is false when the request is not authenticated;
+// actor identifies the client in audit events even when its identity cannot be resolved.
+func requestIdentity(ctx context.Context, resolver tenant.Resolver) (identity *tenant.Identity, actor string, ok bool, err error) {
+	if idToken, ok := helper.GetIDToken(ctx); ok {
+		identity, err := ResolveIdentity(idToken, resolver)
+		if err != nil {
+			return nil, idToken.Subject, true, err
+		}
+		return identity, identity.Email, true, nil
+	}
+
+	principal, ok := helper.GetPrincipal(ctx)
+	if !ok {
+		return nil, "", false, nil
+	}
+	// The tenants of API keys are fixed by their secret, not resolved by the policy.
+	if len(principal.Tenants) > 0 {
+		return &tenant.Identity{Email: principal.Name, Tenants: principal.Tenants}, principal.Name, true, nil
+	}
+	if resolver == nil {
+		resolver = defaultTenantPolicy
+	}
+	if identity, err = resolver.Resolve(principal.Claims); err != nil {
+		return nil, principal.Name, true, err
+	}
+	return identity, principal.Name, true, nil
+}
+
+// ResolveIdentity resolves the tenants and admin privileges of the user from the ID token claims.
+// Admins bypass tenant filtering and get unrestricted access to all data.
+func ResolveIdentity(idToken *oidc.IDToken, resolver tenant.Resolver) (*tenant.Identity, error) {
+	claims := make(map[string]any)
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("failed to parse claims: %w", err)
 	}
 
-	if claims.TenantID != "" {
-		return claims.TenantID, nil
+	if resolver == nil {
+		resolver = defaultTenantPolicy
+	}
+
+	return resolver.Resolve(claims)
+}
+
+// requestTenants returns the tenants the request is allowed to access, either all tenants
+// of the user or the one selected by the TenantHeaderName header. It fails the response otherwise.
+func requestTenants(res *server.Response, req *http.Request, identity *tenant.Identity) ([]string, bool) {
+	scope, ok := requestScope(res, req, identity, nil, "")
+	if !ok {
+		return nil, false
 	}
+	return scope.Tenants(), true
+}
 
-	tenantID := getTenantIDFromGroups(claims.Groups)
-	if tenantID != "" {
-		return tenantID, nil
+// requestScope returns the data of the signal the request is allowed to read: the tenants
+// returned by requestTenants, restricted by the authorizer when it is set. It fails the
+// response otherwise.
+func requestScope(
+	res *server.Response,
+	req *http.Request,
+	identity *tenant.Identity,
+	authorizer authz.Authorizer,
+	signal authz.Signal,
+) (*authz.Scope, bool) {
+	policy := auditlog.PolicyTenant
+	tenants, err := identity.Narrow(req.Header.Get(TenantHeaderName))
+	scope := authz.Unrestricted(tenants)
+	if err == nil && authorizer != nil {
+		policy = auditlog.PolicyAuthorization
+		scope, err = authorizer.Authorize(identity, signal, tenants)
 	}
 
-	return "", fmt.Errorf("unauthorized: user has no tenant group (expected %s prefix)", TenantGroupPrefix)
+	if err != nil {
+		auditlog.Record(req.Context(), auditlog.Decision{Policy: policy, Reason: err.Error(), Actor: identity.Email})
+	}
+	switch {
+	case errors.Is(err, tenant.ErrTenantNotPermitted), errors.Is(err, authz.ErrDenied):
+		res.Fail(err.Error(), http.StatusForbidden)
+		return nil, false
+	case err != nil:
+		res.Fail(fmt.Sprintf("failed to extract tenant ID: %v", err), http.StatusUnauthorized)
+		return nil, false
+	}
+	auditlog.Record(req.Context(), auditlog.Decision{
+		Allowed: true, Policy: policy, Actor: identity.Email, Tenants: scope.Tenants(),
+	})
+	return scope, true
+}
+
+// logsQLTenantFilter returns the LogsQL filter matching any of the tenants in the given field.
+func logsQLTenantFilter(field string, tenants []string) string {
+	if len(tenants) == 1 {
+		return fmt.Sprintf("%s:=%s", field, strconv.Quote(tenants[0]))
+	}
+
+	values := make([]string, 0, len(tenants))
+	for _, tenantID := range tenants {
+		values = append(values, strconv.Quote(tenantID))
+	}
+	return fmt.Sprintf("%s:in(%s)", field, strings.Join(values, ","))
 }
 
 func extractQuery(req *http.Request, writer http.ResponseWriter) (url.Values, error) {
@@ -194,32 +322,6 @@ func extractQuery(req *http.Request, writer http.ResponseWriter) (url.Values, er
 	return query, nil
 }
 
-// getTenantIDFromGroups scans user groups for tenant membership and returns the tenant ID.
-// Returns empty string if no tenant group is found.
-func getTenantIDFromGroups(groups []string) string {
-	for _, group := range groups {
-		if id, ok := strings.CutPrefix(group, TenantGroupPrefix); ok && id != "" {
-			return id
-		}
-	}
-	return ""
-}
-
-// isAdminUser checks if the authenticated user has admin privileges based on email.
-// Admins bypass tenant filtering and get unrestricted access to all metrics.
-func isAdminUser(idToken *oidc.IDToken, adminEmail string) bool {
-	if adminEmail == "" {
-		return false
-	}
-
-	claims := new(Claims)
-	if err := idToken.Claims(claims); err != nil {
-		return false
-	}
-
-	return claims.Email == adminEmail
-}
-
 func BuildURL(scheme, host, path, query string) string {
 	return (&url.URL{
 		Scheme:   scheme,
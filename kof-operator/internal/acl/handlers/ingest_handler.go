@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/k0rdent/kof/kof-operator/internal/acl/auditlog"
+	"github.com/k0rdent/kof/kof-operator/internal/acl/ingest"
+	"github.com/k0rdent/kof/kof-operator/internal/server"
+	"github.com/k0rdent/kof/kof-operator/internal/telemetry"
+)
+
+// Write endpoints of the regional vmauth the ingested data is forwarded to.
+const (
+	RemoteWritePath = "/vm/insert/0/prometheus/api/v1/write"
+	OTLPMetricsPath = "/vm/insert/0/opentelemetry/v1/metrics"
+	OTLPLogsPath    = "/vli/insert/opentelemetry/v1/logs"
+	OTLPTracesPath  = "/vti/insert/opentelemetry/v1/traces"
+)
+
+var otlpPaths = map[ingest.Signal]string{
+	ingest.SignalMetrics: OTLPMetricsPath,
+	ingest.SignalLogs:    OTLPLogsPath,
+	ingest.SignalTraces:  OTLPTracesPath,
+}
+
+// IngestHandler authenticates the writers of metrics, logs and traces, stamps
+// their tenant on the payload and forwards it to the regional write endpoint.
+type IngestHandler struct {
+	config Config
+	path   string
+	// signal is the OTLP signal ingested, empty for Prometheus remote write.
+	signal ingest.Signal
+}
+
+// NewRemoteWriteHandler creates a handler of Prometheus remote-write (v1) requests.
+func NewRemoteWriteHandler(config Config) *IngestHandler {
+	return &IngestHandler{config: config, path: RemoteWritePath}
+}
+
+// NewOTLPHandler creates a handler of OTLP/HTTP export requests of a signal.
+func NewOTLPHandler(config Config, signal ingest.Signal) *IngestHandler {
+	return &IngestHandler{config: config, path: otlpPaths[signal], signal: signal}
+}
+
+// Serve handles an ingest request.
+func (h *IngestHandler) Serve(res *server.Response, req *http.Request) {
+	defer func() {
+		if err := req.Body.Close(); err != nil {
+			res.Logger.Error(err, "failed to close request body")
+		}
+	}()
+
+	tenantID, ok := h.writeTenant(res, req)
+	if !ok {
+		return
+	}
+
+	body, err := readIngestBody(res.Writer, req)
+	if err != nil {
+		res.Fail(fmt.Sprintf("failed to read request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	isJSON := strings.HasPrefix(req.Header.Get("Content-Type"), "application/json")
+	header := http.Header{}
+	if h.signal == "" {
+		if encoding := req.Header.Get("Content-Encoding"); encoding != "" && encoding != "snappy" {
+			res.Fail(fmt.Sprintf("unsupported content encoding %q, remote write requires snappy", encoding), http.StatusUnsupportedMediaType)
+			return
+		}
+		if strings.Contains(req.Header.Get("Content-Type"), "io.prometheus.write.v2.Request") {
+			res.Fail("remote write 2.0 is not supported", http.StatusUnsupportedMediaType)
+			return
+		}
+		if body, err = ingest.StampRemoteWrite(body, tenantID); err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, ingest.ErrTooLarge) {
+				status = http.StatusRequestEntityTooLarge
+			}
+			res.Fail(err.Error(), status)
+			return
+		}
+		header.Set("Content-Encoding", "snappy")
+		header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	} else {
+		encoding := ingest.EncodingProtobuf
+		if isJSON {
+			encoding = ingest.EncodingJSON
+		}
+		if body, err = ingest.StampOTLP(h.signal, encoding, body, tenantID); err != nil {
+			res.Fail(err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	header.Set("Content-Type", "application/x-protobuf")
+
+	status, message, err := h.forward(req.Context(), body, header)
+	if err != nil {
+		res.Logger.Error(err, "failed to forward ingest request", "path", h.path)
+		http.Error(res.Writer, "unable to make request", http.StatusBadGateway)
+		return
+	}
+	if status/100 != 2 {
+		res.Fail(fmt.Sprintf("write endpoint responded with %d: %s", status, message), status)
+		return
+	}
+
+	// OTLP clients get an empty export response: the whole payload was accepted.
+	switch {
+	case h.signal == "":
+		res.Send(nil, http.StatusNoContent)
+	case isJSON:
+		res.SendJson("{}", http.StatusOK)
+	default:
+		res.SetContentType("application/x-protobuf")
+		res.Send(nil, http.StatusOK)
+	}
+}
+
+// writeTenant returns the tenant the request writes the data of: the only
+// tenant of the user or the one selected by the TenantHeaderName header.
+// Admins may write the data of any tenant selected by the header.
+func (h *IngestHandler) writeTenant(res *server.Response, req *http.Request) (string, bool) {
+	ctx := req.Context()
+	selected := req.Header.Get(TenantHeaderName)
+
+	identity, actor, ok, err := requestIdentity(ctx, h.config.TenantResolver)
+	if !ok {
+		if !h.config.DevMode {
+			auditlog.Record(ctx, auditlog.Decision{Policy: auditlog.PolicyAuthentication, Reason: "authentication required"})
+			res.Fail("Unauthorized: authentication required", http.StatusUnauthorized)
+			return "", false
+		}
+		if selected == "" {
+			res.Fail(fmt.Sprintf("the %s header is required in development mode", TenantHeaderName), http.StatusBadRequest)
+			return "", false
+		}
+		auditlog.Record(ctx, auditlog.Decision{Allowed: true, Policy: auditlog.PolicyDevMode, Tenants: []string{selected}})
+		return selected, true
+	}
+
+	if err != nil {
+		reason := fmt.Sprintf("failed to resolve user identity: %v", err)
+		auditlog.Record(ctx, auditlog.Decision{Policy: auditlog.PolicyAuthentication, Reason: reason, Actor: actor})
+		res.Fail(reason, http.StatusUnauthorized)
+		return "", false
+	}
+
+	if identity.Admin && selected != "" {
+		auditlog.Record(ctx, auditlog.Decision{
+			Allowed: true, Policy: auditlog.PolicyAdminBypass, Actor: identity.Email, Tenants: []string{selected},
+		})
+		return selected, true
+	}
+
+	if selected == "" && len(identity.Tenants) > 1 {
+		reason := fmt.Sprintf("the token permits several tenants, select the one to write with the %s header", TenantHeaderName)
+		auditlog.Record(ctx, auditlog.Decision{Policy: auditlog.PolicyTenant, Reason: reason, Actor: identity.Email})
+		res.Fail(reason, http.StatusBadRequest)
+		return "", false
+	}
+
+	tenants, ok := requestTenants(res, req, identity)
+	if !ok {
+		return "", false
+	}
+	return tenants[0], true
+}
+
+// readIngestBody reads the request body, decompressing gzip-encoded bodies,
+// up to MaxBodySize bytes.
+func readIngestBody(writer http.ResponseWriter, req *http.Request) ([]byte, error) {
+	var body io.Reader = http.MaxBytesReader(writer, req.Body, MaxBodySize)
+	if req.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			_ = gz.Close()
+		}()
+		body = gz
+	}
+
+	data, err := io.ReadAll(io.LimitReader(body, MaxBodySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxBodySize {
+		return nil, errors.New("request body too large")
+	}
+	return data, nil
+}
+
+// forward sends the stamped payload to the write endpoint and returns its
+// status and the beginning of its response body.
+func (h *IngestHandler) forward(ctx context.Context, body []byte, header http.Header) (int, string, error) {
+	forwardReq, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		BuildURL(h.config.Scheme, h.config.Host, h.path, ""), bytes.NewReader(body))
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to create request: %w", err)
+	}
+	forwardReq.Header = header
+	if h.config.Username != "" {
+		forwardReq.SetBasicAuth(h.config.Username, h.config.Password)
+	}
+	if correlationID := auditlog.CorrelationID(ctx); correlationID != "" {
+		forwardReq.Header.Set(auditlog.CorrelationIDHeader, correlationID)
+	}
+
+	client := &http.Client{Transport: telemetry.NewTransport(nil)}
+	resp, err := client.Do(forwardReq)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to send request: %w", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.Printf("failed to close response body: %v\n", err)
+		}
+	}()
+
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return resp.StatusCode, strings.TrimSpace(string(message)), nil
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	"github.com/golang/snappy"
+	"github.com/k0rdent/kof/kof-operator/internal/acl/ingest"
+	"github.com/k0rdent/kof/kof-operator/internal/server"
+	"github.com/k0rdent/kof/kof-operator/internal/server/helper"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/prometheus/prompb"
+	"go.opentelemetry.io/collector/pdata/plog"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("IngestHandler", func() {
+	var (
+		mockBackend    *httptest.Server
+		backendStatus  int
+		receivedPath   string
+		receivedHeader http.Header
+		receivedBody   []byte
+		logger         = ctrl.Log.WithName("test")
+	)
+
+	BeforeEach(func() {
+		backendStatus = http.StatusNoContent
+		receivedPath = ""
+		mockBackend = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			receivedPath = r.URL.Path
+			receivedHeader = r.Header
+			var err error
+			receivedBody, err = io.ReadAll(r.Body)
+			Expect(err).NotTo(HaveOccurred())
+			w.WriteHeader(backendStatus)
+		}))
+	})
+
+	AfterEach(func() {
+		mockBackend.Close()
+	})
+
+	config := func() Config {
+		parsedURL, err := url.Parse(mockBackend.URL)
+		Expect(err).NotTo(HaveOccurred())
+		return Config{Host: parsedURL.Host, Scheme: "http", Username: "writer", Password: "secret"}
+	}
+
+	serve := func(handler *IngestHandler, req *http.Request, groups ...any) *httptest.ResponseRecorder {
+		if len(groups) > 0 {
+			idToken := MockIDToken(map[string]any{"email": "user@example.com", "groups": groups})
+			req = req.WithContext(context.WithValue(req.Context(), helper.IdTokenContextKey, idToken))
+		}
+		recorder := httptest.NewRecorder()
+		handler.Serve(&server.Response{Writer: recorder, Logger: &logger}, req)
+		return recorder
+	}
+
+	remoteWriteBody := func(labels ...prompb.Label) []byte {
+		data, err := (&prompb.WriteRequest{Timeseries: []prompb.TimeSeries{{
+			Labels:  labels,
+			Samples: []prompb.Sample{{Value: 1, Timestamp: 1000}},
+		}}}).Marshal()
+		Expect(err).NotTo(HaveOccurred())
+		return snappy.Encode(nil, data)
+	}
+
+	It("stamps the tenant of the token on remote-write series and forwards them", func() {
+		req := httptest.NewRequest(http.MethodPost, "/metrics/api/v1/write",
+			bytes.NewReader(remoteWriteBody(prompb.Label{Name: "__name__", Value: "up"}, prompb.Label{Name: "tenant", Value: "other"})))
+		req.Header.Set("Content-Encoding", "snappy")
+
+		recorder := serve(NewRemoteWriteHandler(config()), req, "tenant:acme")
+
+		Expect(recorder.Code).To(Equal(http.StatusNoContent))
+		Expect(receivedPath).To(Equal(RemoteWritePath))
+		username, password, ok := (&http.Request{Header: receivedHeader}).BasicAuth()
+		Expect(ok).To(BeTrue())
+		Expect(username).To(Equal("writer"))
+		Expect(password).To(Equal("secret"))
+		Expect(receivedHeader.Get("Content-Encoding")).To(Equal("snappy"))
+
+		data, err := snappy.Decode(nil, receivedBody)
+		Expect(err).NotTo(HaveOccurred())
+		var wr prompb.WriteRequest
+		Expect(wr.Unmarshal(data)).To(Succeed())
+		Expect(wr.Timeseries[0].Labels).To(ContainElement(prompb.Label{Name: "tenant", Value: "acme"}))
+		Expect(wr.Timeseries[0].Labels).NotTo(ContainElement(prompb.Label{Name: "tenant", Value: "other"}))
+	})
+
+	It("requires users of several tenants to select the tenant to write", func() {
+		req := httptest.NewRequest(http.MethodPost, "/metrics/api/v1/write", bytes.NewReader(remoteWriteBody()))
+
+		recorder := serve(NewRemoteWriteHandler(config()), req, "tenant:a", "tenant:b")
+
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		Expect(receivedPath).To(BeEmpty())
+	})
+
+	It("rejects tenants the user is not permitted to write", func() {
+		req := httptest.NewRequest(http.MethodPost, "/metrics/api/v1/write", bytes.NewReader(remoteWriteBody()))
+		req.Header.Set(TenantHeaderName, "b")
+
+		recorder := serve(NewRemoteWriteHandler(config()), req, "tenant:a")
+
+		Expect(recorder.Code).To(Equal(http.StatusForbidden))
+		Expect(receivedPath).To(BeEmpty())
+	})
+
+	It("rejects remote-write requests decompressing above the maximum size", func() {
+		body := binary.AppendUvarint(nil, ingest.MaxRemoteWriteSize+1)
+		req := httptest.NewRequest(http.MethodPost, "/metrics/api/v1/write", bytes.NewReader(body))
+
+		recorder := serve(NewRemoteWriteHandler(config()), req, "tenant:acme")
+
+		Expect(recorder.Code).To(Equal(http.StatusRequestEntityTooLarge))
+		Expect(receivedPath).To(BeEmpty())
+	})
+
+	It("rejects unauthenticated requests", func() {
+		req := httptest.NewRequest(http.MethodPost, "/metrics/api/v1/write", bytes.NewReader(remoteWriteBody()))
+
+		recorder := serve(NewRemoteWriteHandler(config()), req)
+
+		Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+	})
+
+	It("stamps gzip-compressed OTLP JSON logs and forwards them as protobuf", func() {
+		logs := plog.NewLogs()
+		logs.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty().Body().SetStr("hello")
+		data, err := new(plog.JSONMarshaler).MarshalLogs(logs)
+		Expect(err).NotTo(HaveOccurred())
+		var compressed bytes.Buffer
+		gz := gzip.NewWriter(&compressed)
+		_, err = gz.Write(data)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(gz.Close()).To(Succeed())
+
+		req := httptest.NewRequest(http.MethodPost, "/v1/logs", &compressed)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Content-Encoding", "gzip")
+
+		recorder := serve(NewOTLPHandler(config(), ingest.SignalLogs), req, "tenant:acme")
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Body.String()).To(MatchJSON("{}"))
+		Expect(receivedPath).To(Equal(OTLPLogsPath))
+		Expect(receivedHeader.Get("Content-Type")).To(Equal("application/x-protobuf"))
+		received, err := new(plog.ProtoUnmarshaler).UnmarshalLogs(receivedBody)
+		Expect(err).NotTo(HaveOccurred())
+		tenantAttr, ok := received.ResourceLogs().At(0).Resource().Attributes().Get("tenant")
+		Expect(ok).To(BeTrue())
+		Expect(tenantAttr.Str()).To(Equal("acme"))
+	})
+
+	It("returns the status of the write endpoint when it fails", func() {
+		backendStatus = http.StatusTooManyRequests
+		body, err := new(plog.ProtoMarshaler).MarshalLogs(plog.NewLogs())
+		Expect(err).NotTo(HaveOccurred())
+		req := httptest.NewRequest(http.MethodPost, "/v1/logs", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-protobuf")
+
+		recorder := serve(NewOTLPHandler(config(), ingest.SignalLogs), req, "tenant:acme")
+
+		Expect(recorder.Code).To(Equal(http.StatusTooManyRequests))
+	})
+})
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/k0rdent/kof/kof-operator/internal/acl/authz"
+	"github.com/k0rdent/kof/kof-operator/internal/acl/tenant"
+	"github.com/k0rdent/kof/kof-operator/internal/server"
+)
+
+// defaultJaegerDependenciesLookback is the lookback of a dependencies request without one.
+const defaultJaegerDependenciesLookback = 24 * time.Hour
+
+type JaegerDependencyLink struct {
+	Parent    string `json:"parent"`
+	Child     string `json:"child"`
+	CallCount uint64 `json:"callCount"`
+}
+
+type JaegerDependenciesResponse struct {
+	Data   []JaegerDependencyLink `json:"data"`
+	Errors any                    `json:"errors"`
+	Limit  int                    `json:"limit"`
+	Offset int                    `json:"offset"`
+	Total  int                    `json:"total"`
+}
+
+// JaegerDependenciesHandler serves the service dependencies of the tenants,
+// computed from the parent-child relations of their spans. The dependencies
+// stored by the backend are not used, as they are not scoped by tenant.
+type JaegerDependenciesHandler struct {
+	config Config
+}
+
+func NewJaegerDependenciesHandler(config Config) Proxy {
+	return &JaegerDependenciesHandler{config: config}
+}
+
+func (h *JaegerDependenciesHandler) TenantResolver() tenant.Resolver { return h.config.TenantResolver }
+func (h *JaegerDependenciesHandler) IsDevMode() bool                 { return h.config.DevMode }
+func (h *JaegerDependenciesHandler) Schema() string                  { return h.config.Scheme }
+func (h *JaegerDependenciesHandler) Host() string                    { return h.config.Host }
+
+func (h *JaegerDependenciesHandler) HandleTenantInjection(res *server.Response, req *http.Request, identity *tenant.Identity) {
+	scope, ok := requestScope(res, req, identity, h.config.Authorizer, authz.SignalTraces)
+	if !ok {
+		return
+	}
+
+	end, lookback, err := jaegerDependenciesRange(req.URL.Query(), time.Now())
+	if err != nil {
+		res.Fail(err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rows, err := queryTracesLogsQL(req.Context(), h.config, scope, jaegerDependenciesQuery(scope, end.Add(-lookback), end))
+	if err != nil {
+		failTracesQuery(res, err)
+		return
+	}
+
+	links := make([]JaegerDependencyLink, 0, len(rows))
+	for _, row := range rows {
+		callCount, err := strconv.ParseUint(row["callCount"], 10, 64)
+		if err != nil {
+			res.Logger.Error(err, "failed to parse dependency call count")
+			http.Error(res.Writer, "unable to parse response", http.StatusInternalServerError)
+			return
+		}
+		links = append(links, JaegerDependencyLink{Parent: row["parent"], Child: row["child"], CallCount: callCount})
+	}
+
+	res.SendObj(JaegerDependenciesResponse{Data: links, Total: len(links)}, http.StatusOK)
+}
+
+// jaegerDependenciesRange returns the end and lookback of a dependencies
+// request from its endTs and lookback parameters in milliseconds.
+func jaegerDependenciesRange(query url.Values, now time.Time) (time.Time, time.Duration, error) {
+	end, lookback := now, defaultJaegerDependenciesLookback
+	if endTs := query.Get("endTs"); endTs != "" {
+		ms, err := strconv.ParseInt(endTs, 10, 64)
+		if err != nil {
+			return end, lookback, fmt.Errorf("invalid endTs: %w", err)
+		}
+		end = time.UnixMilli(ms)
+	}
+	if raw := query.Get("lookback"); raw != "" {
+		ms, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || ms <= 0 {
+			return end, lookback, fmt.Errorf("invalid lookback: %s", raw)
+		}
+		lookback = time.Duration(ms) * time.Millisecond
+	}
+	return end, lookback, nil
+}
+
+// jaegerDependenciesQuery returns the LogsQL query counting the calls between
+// the services of the scope over [start, end]: each child span is joined
+// with its parent span. The scope filter is repeated in the joined subquery,
+// as extra_filters only apply to the outer query.
+func jaegerDependenciesQuery(scope *authz.Scope, start, end time.Time) string {
+	filter := fmt.Sprintf("%s _time:[%s, %s]",
+		tracesScopeFilter(scope), start.UTC().Format(time.RFC3339Nano), end.UTC().Format(time.RFC3339Nano))
+	service := strconv.Quote(tracesServiceNameField)
+	return fmt.Sprintf(
+		"%[1]s parent_span_id:* | fields trace_id, parent_span_id, %[2]s | rename %[2]s as child"+
+			" | join by (trace_id, parent_span_id) (%[1]s | fields trace_id, span_id, %[2]s"+
+			" | rename span_id as parent_span_id, %[2]s as parent) inner"+
+			" | stats by (parent, child) count() callCount",
+		filter, service,
+	)
+}
@@ -204,6 +204,54 @@ var _ = Describe("JaegerTracesHandler", func() {
 			Expect(recorder.Code).To(Equal(http.StatusOK))
 		})
 
+		It("searches each permitted tenant and merges the traces", func() {
+			mockBackend = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var tags map[string]string
+				Expect(json.Unmarshal([]byte(r.URL.Query().Get("tags")), &tags)).To(Succeed())
+				Expect(tags).To(HaveKeyWithValue("http.status_code", "500"))
+
+				tenantID := tags["resource_attr:"+TenantLabelName]
+				resp := JaegerTraceResponse{Data: []*JaegerTrace{
+					{TraceID: "trace-" + tenantID, Spans: []json.RawMessage{}},
+					{TraceID: "trace-shared", Spans: []json.RawMessage{}},
+				}}
+				w.WriteHeader(http.StatusOK)
+				Expect(json.NewEncoder(w).Encode(resp)).To(Succeed())
+			}))
+
+			parsedURL, err := url.Parse(mockBackend.URL)
+			Expect(err).NotTo(HaveOccurred())
+			handler = &JaegerTracesHandler{config: Config{Host: parsedURL.Host, Scheme: "http"}}
+
+			req = httptest.NewRequest(http.MethodGet,
+				`/traces/api/traces?service=my-svc&tags={"http.status_code":"500"}`, nil)
+			res = &server.Response{
+				Writer: httptest.NewRecorder(),
+				Logger: &logger,
+			}
+
+			idToken := MockIDToken(map[string]any{
+				"email":  "user@example.com",
+				"groups": []any{"tenant:a", "tenant:b"},
+			})
+			ctx := context.WithValue(req.Context(), helper.IdTokenContextKey, idToken)
+			req = req.WithContext(ctx)
+
+			ACLProxy(res, req, handler)
+
+			recorder := res.Writer.(*httptest.ResponseRecorder)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+
+			var resp JaegerTraceResponse
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &resp)).To(Succeed())
+			traceIDs := make([]string, 0, len(resp.Data))
+			for _, trace := range resp.Data {
+				traceIDs = append(traceIDs, trace.TraceID)
+			}
+			Expect(traceIDs).To(ConsistOf("trace-a", "trace-b", "trace-shared"))
+			Expect(resp.Total).To(Equal(3))
+		})
+
 		It("returns 401 when token has no tenant group", func() {
 			mockBackend = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
 				w.WriteHeader(http.StatusOK)
@@ -245,7 +293,7 @@ var _ = Describe("JaegerTraceHandler", func() {
 	buildTrace := func(traceID, tenantValue string) *JaegerTrace {
 		return &JaegerTrace{
 			TraceID: traceID,
-			Spans:   json.RawMessage(`[]`),
+			Spans:   []json.RawMessage{json.RawMessage(`{"spanID":"s1","processID":"p1"}`)},
 			Processes: map[string]Process{
 				"p1": {
 					ServiceName: "svc",
@@ -307,7 +355,7 @@ var _ = Describe("JaegerTraceHandler", func() {
 			Expect(resp.Total).To(Equal(1))
 		})
 
-		It("returns an empty data list when no traces match the tenant", func() {
+		It("returns not found when the trace belongs to another tenant", func() {
 			backendResp := JaegerTraceResponse{
 				Data:  []*JaegerTrace{buildTrace("trace-99", "other-tenant")},
 				Total: 1,
@@ -337,12 +385,53 @@ var _ = Describe("JaegerTraceHandler", func() {
 			ACLProxy(res, req, handler)
 
 			recorder := res.Writer.(*httptest.ResponseRecorder)
-			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Code).To(Equal(http.StatusNotFound))
 
 			var resp JaegerTraceResponse
 			Expect(json.Unmarshal(recorder.Body.Bytes(), &resp)).To(Succeed())
 			Expect(resp.Data).To(BeEmpty())
-			Expect(resp.Total).To(Equal(0))
+			Expect(resp.Errors).NotTo(BeNil())
+		})
+
+		It("keeps only the spans and processes of the tenant", func() {
+			trace := buildTrace("trace-3", "test-tenant")
+			trace.Processes["p2"] = Process{
+				ServiceName: "other-svc",
+				Tags:        []KeyValue{{Key: TenantLabelName, Type: "string", Value: "other-tenant"}},
+			}
+			trace.Spans = append(trace.Spans, json.RawMessage(`{"spanID":"s2","processID":"p2"}`))
+
+			mockBackend = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				Expect(json.NewEncoder(w).Encode(JaegerTraceResponse{Data: []*JaegerTrace{trace}, Total: 1})).To(Succeed())
+			}))
+
+			parsedURL, _ := url.Parse(mockBackend.URL)
+			handler = &JaegerTraceHandler{config: Config{Host: parsedURL.Host, Scheme: "http"}}
+
+			req = httptest.NewRequest(http.MethodGet, "/traces/api/traces/trace-3", nil)
+			res = &server.Response{
+				Writer: httptest.NewRecorder(),
+				Logger: &logger,
+			}
+
+			idToken := MockIDToken(map[string]any{
+				"email":  "user@example.com",
+				"groups": []any{"tenant:test-tenant"},
+			})
+			req = req.WithContext(context.WithValue(req.Context(), helper.IdTokenContextKey, idToken))
+
+			ACLProxy(res, req, handler)
+
+			recorder := res.Writer.(*httptest.ResponseRecorder)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+
+			var resp JaegerTraceResponse
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &resp)).To(Succeed())
+			Expect(resp.Data).To(HaveLen(1))
+			Expect(resp.Data[0].Processes).To(HaveKey("p1"))
+			Expect(resp.Data[0].Processes).NotTo(HaveKey("p2"))
+			Expect(resp.Data[0].Spans).To(HaveLen(1))
+			Expect(resp.Data[0].Spans[0]).To(MatchJSON(`{"spanID":"s1","processID":"p1"}`))
 		})
 
 		It("returns an error when the backend responds with non-OK status", func() {
@@ -400,3 +489,92 @@ var _ = Describe("JaegerTraceHandler", func() {
 		})
 	})
 })
+
+var _ = Describe("JaegerOperationsHandler and JaegerDependenciesHandler", func() {
+	var (
+		mockBackend   *httptest.Server
+		receivedQuery url.Values
+		rows          []map[string]string
+		logger        = ctrl.Log.WithName("test")
+	)
+
+	BeforeEach(func() {
+		receivedQuery = nil
+		mockBackend = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.URL.Path).To(Equal("/select/logsql/query"))
+			receivedQuery = r.URL.Query()
+			enc := json.NewEncoder(w)
+			for _, row := range rows {
+				Expect(enc.Encode(row)).To(Succeed())
+			}
+		}))
+	})
+
+	AfterEach(func() {
+		mockBackend.Close()
+	})
+
+	serve := func(proxy func(Config) Proxy, target string) *httptest.ResponseRecorder {
+		parsedURL, err := url.Parse(mockBackend.URL)
+		Expect(err).NotTo(HaveOccurred())
+
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		idToken := MockIDToken(map[string]any{
+			"email":  "user@example.com",
+			"groups": []any{"tenant:test-tenant"},
+		})
+		req = req.WithContext(context.WithValue(req.Context(), helper.IdTokenContextKey, idToken))
+		recorder := httptest.NewRecorder()
+		ACLProxy(&server.Response{Writer: recorder, Logger: &logger}, req, proxy(Config{Host: parsedURL.Host, Scheme: "http"}))
+		return recorder
+	}
+
+	It("returns the operation names of a service", func() {
+		rows = []map[string]string{{"name": "GET /b", "kind": "2"}, {"name": "GET /a", "kind": "2"}, {"name": "GET /a", "kind": "3"}}
+
+		recorder := serve(NewJaegerOperationsHandler, "/traces/select/jaeger/api/services/svc-a/operations")
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(receivedQuery.Get("extra_filters")).To(Equal(`"resource_attr:tenant":="test-tenant"`))
+		Expect(receivedQuery.Get("query")).To(Equal(`"resource_attr:service.name":="svc-a" | uniq by (name, kind)`))
+
+		var resp JaegerServiceResponse
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &resp)).To(Succeed())
+		Expect(resp.Data).To(Equal([]string{"GET /a", "GET /b"}))
+	})
+
+	It("returns the operations of a service by span kind", func() {
+		rows = []map[string]string{{"name": "GET /a", "kind": "2"}}
+
+		recorder := serve(NewJaegerOperationsHandler, "/traces/select/jaeger/api/operations?service=svc-a&spanKind=server")
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(receivedQuery.Get("query")).To(Equal(`"resource_attr:service.name":="svc-a" kind:="2" | uniq by (name, kind)`))
+
+		var resp JaegerOperationsResponse
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &resp)).To(Succeed())
+		Expect(resp.Data).To(Equal([]JaegerOperation{{Name: "GET /a", SpanKind: "server"}}))
+	})
+
+	It("requires the service of operations", func() {
+		recorder := serve(NewJaegerOperationsHandler, "/traces/select/jaeger/api/operations")
+
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		Expect(receivedQuery).To(BeNil())
+	})
+
+	It("computes the dependencies of the tenant services", func() {
+		rows = []map[string]string{{"parent": "frontend", "child": "backend", "callCount": "7"}}
+
+		recorder := serve(NewJaegerDependenciesHandler, "/traces/select/jaeger/api/dependencies?endTs=86400000&lookback=3600000")
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		query := receivedQuery.Get("query")
+		Expect(query).To(HavePrefix(`"resource_attr:tenant":="test-tenant" _time:[1970-01-01T23:00:00Z, 1970-01-02T00:00:00Z] parent_span_id:*`))
+		Expect(strings.Count(query, `"resource_attr:tenant":="test-tenant"`)).To(Equal(2))
+
+		var resp JaegerDependenciesResponse
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &resp)).To(Succeed())
+		Expect(resp.Data).To(Equal([]JaegerDependencyLink{{Parent: "frontend", Child: "backend", CallCount: 7}}))
+	})
+})
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/k0rdent/kof/kof-operator/internal/acl/authz"
+	"github.com/k0rdent/kof/kof-operator/internal/server"
+)
+
+const (
+	// tracesServiceNameField is the VictoriaTraces field of the service name of a span.
+	tracesServiceNameField = "resource_attr:service.name"
+	// tracesLogsQLPath is the VictoriaTraces LogsQL query endpoint.
+	tracesLogsQLPath = "/select/logsql/query"
+)
+
+// errTracesBackend is returned when the traces backend responds with a non-OK status.
+type errTracesBackend struct {
+	status     string
+	statusCode int
+}
+
+func (e *errTracesBackend) Error() string {
+	return fmt.Sprintf("received non-OK response: %s", e.status)
+}
+
+// tracesScopeFilter returns the LogsQL filter matching the spans of the scope.
+func tracesScopeFilter(scope *authz.Scope) string {
+	return logsQLScopeFilter(scope, tracesScopeFields)
+}
+
+// queryTracesLogsQL runs a LogsQL query restricted to the scope on the
+// traces backend and returns the fields of the resulting rows.
+func queryTracesLogsQL(ctx context.Context, config Config, scope *authz.Scope, query string) ([]map[string]string, error) {
+	params := url.Values{}
+	params.Set("extra_filters", tracesScopeFilter(scope))
+	params.Set("query", query)
+
+	resp, err := ProxyRequest(ctx, BuildURL(config.Scheme, config.Host, tracesLogsQLPath, params.Encode()), http.MethodGet, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.Printf("failed to close response body: %v\n", err)
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &errTracesBackend{status: resp.Status, statusCode: resp.StatusCode}
+	}
+
+	rows := make([]map[string]string, 0)
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var row map[string]string
+		if err := dec.Decode(&row); err != nil {
+			if errors.Is(err, io.EOF) {
+				return rows, nil
+			}
+			return nil, fmt.Errorf("failed to decode response body: %w", err)
+		}
+		rows = append(rows, row)
+	}
+}
+
+// failTracesQuery fails the response after a failed query to the traces backend.
+func failTracesQuery(res *server.Response, err error) {
+	var backendErr *errTracesBackend
+	if errors.As(err, &backendErr) {
+		res.Fail(backendErr.Error(), backendErr.statusCode)
+		return
+	}
+	res.Logger.Error(err, "failed to query traces")
+	http.Error(res.Writer, "unable to make request", http.StatusInternalServerError)
+}
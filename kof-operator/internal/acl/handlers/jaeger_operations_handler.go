@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/k0rdent/kof/kof-operator/internal/acl/authz"
+	"github.com/k0rdent/kof/kof-operator/internal/acl/tenant"
+	"github.com/k0rdent/kof/kof-operator/internal/server"
+)
+
+// jaegerSpanKinds maps the OpenTelemetry span kinds stored by VictoriaTraces to Jaeger span kinds.
+var jaegerSpanKinds = map[string]string{
+	"0": "",
+	"1": "internal",
+	"2": "server",
+	"3": "client",
+	"4": "producer",
+	"5": "consumer",
+}
+
+type JaegerOperation struct {
+	Name     string `json:"name"`
+	SpanKind string `json:"spanKind"`
+}
+
+type JaegerOperationsResponse struct {
+	Data   []JaegerOperation `json:"data"`
+	Errors any               `json:"errors"`
+	Limit  int               `json:"limit"`
+	Offset int               `json:"offset"`
+	Total  int               `json:"total"`
+}
+
+// JaegerOperationsHandler serves the operations of a service, both from
+// /api/services/{service}/operations (operation names) and from
+// /api/operations?service={service}&spanKind={kind} (names and span kinds).
+type JaegerOperationsHandler struct {
+	config Config
+}
+
+func NewJaegerOperationsHandler(config Config) Proxy {
+	return &JaegerOperationsHandler{config: config}
+}
+
+func (h *JaegerOperationsHandler) TenantResolver() tenant.Resolver { return h.config.TenantResolver }
+func (h *JaegerOperationsHandler) IsDevMode() bool                 { return h.config.DevMode }
+func (h *JaegerOperationsHandler) Schema() string                  { return h.config.Scheme }
+func (h *JaegerOperationsHandler) Host() string                    { return h.config.Host }
+
+func (h *JaegerOperationsHandler) HandleTenantInjection(res *server.Response, req *http.Request, identity *tenant.Identity) {
+	scope, ok := requestScope(res, req, identity, h.config.Authorizer, authz.SignalTraces)
+	if !ok {
+		return
+	}
+
+	service, legacy := jaegerPathService(req.URL.Path)
+	spanKind := ""
+	if !legacy {
+		service = req.URL.Query().Get("service")
+		spanKind = req.URL.Query().Get("spanKind")
+	}
+	if service == "" {
+		res.Fail("service name is required", http.StatusBadRequest)
+		return
+	}
+
+	query := fmt.Sprintf("%s:=%s", strconv.Quote(tracesServiceNameField), strconv.Quote(service))
+	if spanKind != "" {
+		kind, ok := otelSpanKind(spanKind)
+		if !ok {
+			res.Fail(fmt.Sprintf("unsupported span kind: %s", spanKind), http.StatusBadRequest)
+			return
+		}
+		query += fmt.Sprintf(" kind:=%s", strconv.Quote(kind))
+	}
+	query += " | uniq by (name, kind)"
+
+	rows, err := queryTracesLogsQL(req.Context(), h.config, scope, query)
+	if err != nil {
+		failTracesQuery(res, err)
+		return
+	}
+
+	operations := make([]JaegerOperation, 0, len(rows))
+	for _, row := range rows {
+		kind, ok := jaegerSpanKinds[row["kind"]]
+		if !ok {
+			kind = row["kind"]
+		}
+		operations = append(operations, JaegerOperation{Name: row["name"], SpanKind: kind})
+	}
+	slices.SortFunc(operations, func(a, b JaegerOperation) int {
+		return strings.Compare(a.Name+"\x00"+a.SpanKind, b.Name+"\x00"+b.SpanKind)
+	})
+
+	if !legacy {
+		res.SendObj(JaegerOperationsResponse{Data: operations, Total: len(operations)}, http.StatusOK)
+		return
+	}
+
+	names := make([]string, 0, len(operations))
+	for _, op := range operations {
+		names = append(names, op.Name)
+	}
+	names = slices.Compact(names)
+	res.SendObj(JaegerServiceResponse{Data: names, Total: len(names)}, http.StatusOK)
+}
+
+// jaegerPathService returns the service of a /api/services/{service}/operations path.
+func jaegerPathService(path string) (string, bool) {
+	_, rest, ok := strings.Cut(path, "/api/services/")
+	if !ok {
+		return "", false
+	}
+	service, ok := strings.CutSuffix(rest, "/operations")
+	return service, ok
+}
+
+// otelSpanKind returns the OpenTelemetry span kind stored by VictoriaTraces for a Jaeger span kind.
+func otelSpanKind(spanKind string) (string, bool) {
+	for kind, name := range jaegerSpanKinds {
+		if name != "" && name == spanKind {
+			return kind, true
+		}
+	}
+	return "", false
+}
@@ -6,7 +6,8 @@ import (
 	"io"
 	"net/http"
 
-	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/k0rdent/kof/kof-operator/internal/acl/authz"
+	"github.com/k0rdent/kof/kof-operator/internal/acl/tenant"
 	"github.com/k0rdent/kof/kof-operator/internal/server"
 )
 
@@ -26,15 +27,14 @@ func NewJaegerServicesHandler(config Config) Proxy {
 	return &JaegerServicesHandler{config: config}
 }
 
-func (h *JaegerServicesHandler) AdminEmail() string { return h.config.AdminEmail }
-func (h *JaegerServicesHandler) IsDevMode() bool    { return h.config.DevMode }
-func (h *JaegerServicesHandler) Schema() string     { return h.config.Scheme }
-func (h *JaegerServicesHandler) Host() string       { return h.config.Host }
+func (h *JaegerServicesHandler) TenantResolver() tenant.Resolver { return h.config.TenantResolver }
+func (h *JaegerServicesHandler) IsDevMode() bool                 { return h.config.DevMode }
+func (h *JaegerServicesHandler) Schema() string                  { return h.config.Scheme }
+func (h *JaegerServicesHandler) Host() string                    { return h.config.Host }
 
-func (h *JaegerServicesHandler) HandleTenantInjection(res *server.Response, req *http.Request, idToken *oidc.IDToken) {
-	tenantID, err := ExtractTenantIDFromToken(idToken)
-	if err != nil {
-		res.Fail(fmt.Sprintf("failed to extract tenant ID: %v", err), http.StatusUnauthorized)
+func (h *JaegerServicesHandler) HandleTenantInjection(res *server.Response, req *http.Request, identity *tenant.Identity) {
+	scope, ok := requestScope(res, req, identity, h.config.Authorizer, authz.SignalTraces)
+	if !ok {
 		return
 	}
 
@@ -44,7 +44,7 @@ func (h *JaegerServicesHandler) HandleTenantInjection(res *server.Response, req
 	}
 
 	query := req.URL.Query()
-	query.Set("extra_filters", fmt.Sprintf("\"resource_attr:%s\":=\"%s\"", TenantLabelName, tenantID))
+	query.Set("extra_filters", tracesScopeFilter(scope))
 	query.Set("query", `* | uniq by ("resource_attr:service.name")`)
 
 	newPath := "/select/logsql/query"
@@ -7,7 +7,8 @@ import (
 	"net/http"
 	"strings"
 
-	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/k0rdent/kof/kof-operator/internal/acl/authz"
+	"github.com/k0rdent/kof/kof-operator/internal/acl/tenant"
 	"github.com/k0rdent/kof/kof-operator/internal/server"
 )
 
@@ -21,10 +22,20 @@ type JaegerTraceResponse struct {
 
 type JaegerTrace struct {
 	TraceID   string             `json:"traceID"`
-	Spans     json.RawMessage    `json:"spans"`
+	Spans     []json.RawMessage  `json:"spans"`
 	Processes map[string]Process `json:"processes"`
 }
 
+// jaegerSpanProcess is the reference of a span to the process it belongs to.
+type jaegerSpanProcess struct {
+	ProcessID string `json:"processID"`
+}
+
+type JaegerError struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+}
+
 type Process struct {
 	ServiceName string     `json:"serviceName"`
 	Tags        []KeyValue `json:"tags"`
@@ -44,16 +55,14 @@ func NewJaegerTraceHandler(config Config) Proxy {
 	return &JaegerTraceHandler{config: config}
 }
 
-func (h *JaegerTraceHandler) AdminEmail() string { return h.config.AdminEmail }
-func (h *JaegerTraceHandler) IsDevMode() bool    { return h.config.DevMode }
-func (h *JaegerTraceHandler) Schema() string     { return h.config.Scheme }
-func (h *JaegerTraceHandler) Host() string       { return h.config.Host }
+func (h *JaegerTraceHandler) TenantResolver() tenant.Resolver { return h.config.TenantResolver }
+func (h *JaegerTraceHandler) IsDevMode() bool                 { return h.config.DevMode }
+func (h *JaegerTraceHandler) Schema() string                  { return h.config.Scheme }
+func (h *JaegerTraceHandler) Host() string                    { return h.config.Host }
 
-func (h *JaegerTraceHandler) HandleTenantInjection(res *server.Response, req *http.Request, idToken *oidc.IDToken) {
-	// Extract tenant ID from authenticated user's token
-	tenantID, err := ExtractTenantIDFromToken(idToken)
-	if err != nil {
-		res.Fail(fmt.Sprintf("failed to extract tenant ID: %v", err), http.StatusUnauthorized)
+func (h *JaegerTraceHandler) HandleTenantInjection(res *server.Response, req *http.Request, identity *tenant.Identity) {
+	scope, ok := requestScope(res, req, identity, h.config.Authorizer, authz.SignalTraces)
+	if !ok {
 		return
 	}
 
@@ -100,11 +109,26 @@ func (h *JaegerTraceHandler) HandleTenantInjection(res *server.Response, req *ht
 
 	filteredData := make([]*JaegerTrace, 0, len(result.Data))
 	for _, trace := range result.Data {
-		if hasProcessTagWithValue(trace, TenantLabelName, tenantID) {
-			filteredData = append(filteredData, trace)
+		filtered, err := filterScopeSpans(trace, scope)
+		if err != nil {
+			res.Logger.Error(err, "failed to filter trace spans")
+			http.Error(res.Writer, "unable to parse response", http.StatusInternalServerError)
+			return
+		}
+		if filtered != nil {
+			filteredData = append(filteredData, filtered)
 		}
 	}
 
+	// A trace of another tenant is reported as missing, as Jaeger does for
+	// unknown trace IDs, so that its existence is not disclosed.
+	if len(filteredData) == 0 {
+		res.SendObj(JaegerTraceResponse{
+			Errors: []JaegerError{{Code: http.StatusNotFound, Msg: "trace not found"}},
+		}, http.StatusNotFound)
+		return
+	}
+
 	result.Data = filteredData
 	result.Total = len(filteredData)
 	if err := json.NewEncoder(res.Writer).Encode(result); err != nil {
@@ -114,13 +138,44 @@ func (h *JaegerTraceHandler) HandleTenantInjection(res *server.Response, req *ht
 	}
 }
 
-func hasProcessTagWithValue(trace *JaegerTrace, key string, value any) bool {
-	for _, proc := range trace.Processes {
-		for _, tag := range proc.Tags {
-			if tag.Key == key && tag.Value == value {
-				return true
-			}
+// filterScopeSpans returns the trace with only the processes whose tenant,
+// cluster and namespace tags are granted by the scope and their spans, or nil
+// when no span is left.
+func filterScopeSpans(trace *JaegerTrace, scope *authz.Scope) (*JaegerTrace, error) {
+	processes := make(map[string]Process, len(trace.Processes))
+	for id, proc := range trace.Processes {
+		if scope.Allows(
+			stringTag(proc.Tags, TenantLabelName),
+			stringTag(proc.Tags, ClusterAttributeName),
+			stringTag(proc.Tags, NamespaceAttributeName),
+		) {
+			processes[id] = proc
+		}
+	}
+
+	spans := make([]json.RawMessage, 0, len(trace.Spans))
+	for _, span := range trace.Spans {
+		var ref jaegerSpanProcess
+		if err := json.Unmarshal(span, &ref); err != nil {
+			return nil, fmt.Errorf("failed to decode span: %w", err)
+		}
+		if _, ok := processes[ref.ProcessID]; ok {
+			spans = append(spans, span)
+		}
+	}
+	if len(spans) == 0 {
+		return nil, nil
+	}
+
+	return &JaegerTrace{TraceID: trace.TraceID, Spans: spans, Processes: processes}, nil
+}
+
+// stringTag returns the string value of the tag with the key, empty when there is none.
+func stringTag(tags []KeyValue, key string) string {
+	for _, tag := range tags {
+		if value, ok := tag.Value.(string); ok && tag.Key == key {
+			return value
 		}
 	}
-	return false
+	return ""
 }
@@ -1,13 +1,16 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"strings"
 
-	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/k0rdent/kof/kof-operator/internal/acl/authz"
+	"github.com/k0rdent/kof/kof-operator/internal/acl/tenant"
 	"github.com/k0rdent/kof/kof-operator/internal/server"
 )
 
@@ -19,16 +22,18 @@ func NewJaegerTracesHandler(config Config) Proxy {
 	return &JaegerTracesHandler{config: config}
 }
 
-func (h *JaegerTracesHandler) AdminEmail() string { return h.config.AdminEmail }
-func (h *JaegerTracesHandler) IsDevMode() bool    { return h.config.DevMode }
-func (h *JaegerTracesHandler) Schema() string     { return h.config.Scheme }
-func (h *JaegerTracesHandler) Host() string       { return h.config.Host }
+func (h *JaegerTracesHandler) TenantResolver() tenant.Resolver { return h.config.TenantResolver }
+func (h *JaegerTracesHandler) IsDevMode() bool                 { return h.config.DevMode }
+func (h *JaegerTracesHandler) Schema() string                  { return h.config.Scheme }
+func (h *JaegerTracesHandler) Host() string                    { return h.config.Host }
 
-func (h *JaegerTracesHandler) HandleTenantInjection(res *server.Response, req *http.Request, idToken *oidc.IDToken) {
-	// Extract tenant ID from authenticated user's token
-	tenantID, err := ExtractTenantIDFromToken(idToken)
-	if err != nil {
-		res.Fail(fmt.Sprintf("failed to extract tenant ID: %v", err), http.StatusUnauthorized)
+// HandleTenantInjection adds the tenant tag to the Jaeger search. Jaeger tags can only
+// match a single value, so the search is sent once per permitted tenant and merged.
+// The spans of the clusters and namespaces not granted by the authorization policy
+// are removed from the results.
+func (h *JaegerTracesHandler) HandleTenantInjection(res *server.Response, req *http.Request, identity *tenant.Identity) {
+	scope, ok := requestScope(res, req, identity, h.config.Authorizer, authz.SignalTraces)
+	if !ok {
 		return
 	}
 
@@ -43,27 +48,87 @@ func (h *JaegerTracesHandler) HandleTenantInjection(res *server.Response, req *h
 		}
 	}
 
-	tags["resource_attr:"+TenantLabelName] = tenantID
+	tenants := scope.Tenants()
+	path := strings.TrimPrefix(req.URL.Path, "/traces")
+	urls := make([]string, 0, len(tenants))
+	for _, tenantID := range tenants {
+		tags["resource_attr:"+TenantLabelName] = tenantID
 
-	tagsJSON, err := json.Marshal(tags)
-	if err != nil {
-		res.Fail(fmt.Sprintf("failed to marshal tags: %v", err), http.StatusInternalServerError)
+		tagsJSON, err := json.Marshal(tags)
+		if err != nil {
+			res.Fail(fmt.Sprintf("failed to marshal tags: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		query.Set("tags", string(tagsJSON))
+		urls = append(urls, BuildURL(h.config.Scheme, h.config.Host, path, query.Encode()))
+	}
+
+	if len(urls) == 1 && !scope.Restricted() {
+		var body io.Reader
+		if req.Method == http.MethodPost {
+			body = req.Body
+		}
+
+		if err := StreamProxyRequest(req.Context(), urls[0], req.Method, body, res.Writer); err != nil {
+			res.Logger.Error(err, "failed to proxy request to traces")
+			http.Error(res.Writer, "unable to make request", http.StatusInternalServerError)
+		}
 		return
 	}
 
-	query.Set("tags", string(tagsJSON))
+	result := &JaegerTraceResponse{Data: make([]*JaegerTrace, 0)}
+	seen := make(map[string]struct{})
+	for _, url := range urls {
+		tenantResult, err := fetchJaegerTraces(req.Context(), url)
+		if err != nil {
+			res.Logger.Error(err, "failed to proxy request to traces")
+			http.Error(res.Writer, "unable to make request", http.StatusInternalServerError)
+			return
+		}
+
+		for _, trace := range tenantResult.Data {
+			if _, ok := seen[trace.TraceID]; ok {
+				continue
+			}
+			if scope.Restricted() {
+				if trace, err = filterScopeSpans(trace, scope); err != nil {
+					res.Logger.Error(err, "failed to filter trace spans")
+					http.Error(res.Writer, "unable to parse response", http.StatusInternalServerError)
+					return
+				}
+				if trace == nil {
+					continue
+				}
+			}
+			seen[trace.TraceID] = struct{}{}
+			result.Data = append(result.Data, trace)
+		}
+	}
+
+	result.Total = len(result.Data)
+	res.SendObj(result, http.StatusOK)
+}
+
+func fetchJaegerTraces(ctx context.Context, url string) (*JaegerTraceResponse, error) {
+	resp, err := ProxyRequest(ctx, url, http.MethodGet, nil)
+	if err != nil {
+		return nil, err
+	}
 
-	path := strings.TrimPrefix(req.URL.Path, "/traces")
-	url := BuildURL(h.config.Scheme, h.config.Host, path, query.Encode())
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.Printf("failed to close response body: %v\n", err)
+		}
+	}()
 
-	var body io.Reader
-	if req.Method == http.MethodPost {
-		body = req.Body
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("received non-OK response: %s", resp.Status)
 	}
 
-	if err := StreamProxyRequest(req.Context(), url, req.Method, body, res.Writer); err != nil {
-		res.Logger.Error(err, "failed to proxy request to traces")
-		http.Error(res.Writer, "unable to make request", http.StatusInternalServerError)
-		return
+	result := new(JaegerTraceResponse)
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return nil, fmt.Errorf("failed to decode traces: %w", err)
 	}
+	return result, nil
 }
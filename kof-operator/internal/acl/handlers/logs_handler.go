@@ -1,15 +1,49 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
 
-	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/k0rdent/kof/kof-operator/internal/acl/authz"
+	"github.com/k0rdent/kof/kof-operator/internal/acl/tenant"
 	"github.com/k0rdent/kof/kof-operator/internal/server"
 )
 
+// logsInjector restricts the parameters of a Logs API request to the tenants.
+type logsInjector func(params url.Values, filters logsTenantFilters) error
+
+// logsTenantFilters are the LogsQL filters matching the tenants of a request.
+type logsTenantFilters struct {
+	// filter matches the tenant field of the logs.
+	filter string
+	// streamFilter matches the tenant stream field, empty when the tenant is not a stream field.
+	streamFilter string
+}
+
+// logsEndpoints are the VictoriaLogs select endpoints available to tenants,
+// with the injection of the tenant filters each of them supports. Other
+// endpoints are only available to admins.
+var logsEndpoints = map[string]logsInjector{
+	"/select/logsql/query":             injectLogsExtraFilters,
+	"/select/logsql/stats_query":       injectLogsExtraFilters,
+	"/select/logsql/stats_query_range": injectLogsExtraFilters,
+	"/select/logsql/facets":            injectLogsExtraFilters,
+	// These endpoints do not apply extra_filters in every VictoriaLogs
+	// version, the tenant filter is added to their query as well.
+	"/select/logsql/hits":                injectLogsQueryFilter,
+	"/select/logsql/tail":                injectLogsQueryFilter,
+	"/select/logsql/field_names":         injectLogsQueryFilter,
+	"/select/logsql/field_values":        injectLogsQueryFilter,
+	"/select/logsql/streams":             injectLogsQueryFilter,
+	"/select/logsql/stream_ids":          injectLogsQueryFilter,
+	"/select/logsql/stream_field_names":  injectLogsQueryFilter,
+	"/select/logsql/stream_field_values": injectLogsQueryFilter,
+}
+
 // logsHandler handles Logs API requests with tenant isolation.
 type logsHandler struct {
 	config Config
@@ -19,28 +53,54 @@ func NewLogsHandler(cfg Config) Proxy {
 	return &logsHandler{config: cfg}
 }
 
-func (h *logsHandler) AdminEmail() string { return h.config.AdminEmail }
-func (h *logsHandler) IsDevMode() bool    { return h.config.DevMode }
-func (h *logsHandler) Schema() string     { return h.config.Scheme }
-func (h *logsHandler) Host() string       { return h.config.Host }
+func (h *logsHandler) TenantResolver() tenant.Resolver { return h.config.TenantResolver }
+func (h *logsHandler) IsDevMode() bool                 { return h.config.DevMode }
+func (h *logsHandler) Schema() string                  { return h.config.Scheme }
+func (h *logsHandler) Host() string                    { return h.config.Host }
 
-func (h *logsHandler) HandleTenantInjection(res *server.Response, req *http.Request, idToken *oidc.IDToken) {
-	tenantID, err := ExtractTenantIDFromToken(idToken)
-	if err != nil {
-		res.Fail(fmt.Sprintf("failed to extract tenant ID: %v", err), http.StatusUnauthorized)
+func (h *logsHandler) HandleTenantInjection(res *server.Response, req *http.Request, identity *tenant.Identity) {
+	path := strings.TrimPrefix(req.URL.Path, "/logs")
+	inject, ok := logsEndpoints[path]
+	if !ok {
+		res.Fail(fmt.Sprintf("Forbidden: %s is not available to tenants", path), http.StatusForbidden)
 		return
 	}
 
-	query := req.URL.Query()
-	path := strings.TrimPrefix(req.URL.Path, "/logs")
+	scope, ok := requestScope(res, req, identity, h.config.Authorizer, authz.SignalLogs)
+	if !ok {
+		return
+	}
+
+	query, err := extractQuery(req, res.Writer)
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			res.Fail(fmt.Sprintf("request body too large: %v", err), http.StatusRequestEntityTooLarge)
+			return
+		}
+		res.Fail(fmt.Sprintf("failed to extract query: %v", err), http.StatusBadRequest)
+		return
+	}
 
-	query.Set("extra_filters", fmt.Sprintf("%s:=\"%s\"", TenantLabelName, tenantID))
-	logsURL := BuildURL(h.config.Scheme, h.config.Host, path, query.Encode())
+	filters := logsTenantFilters{filter: logsQLScopeFilter(scope, logsScopeFields)}
+	if h.config.TenantStreamField {
+		// The clusters and namespaces are not stream fields, they are only restricted by the filter.
+		filters.streamFilter = logsQLTenantStreamFilter(TenantLabelName, scope.Tenants())
+	}
+	if err := inject(query, filters); err != nil {
+		res.Fail(fmt.Sprintf("failed to inject tenant filter into query: %v", err), http.StatusBadRequest)
+		return
+	}
 
 	var body io.Reader
+	rawQuery := query.Encode()
 	if req.Method == http.MethodPost {
-		body = req.Body
+		// The rewritten form is sent in the body only, so that no parameter
+		// of the original URL reaches the backend unchecked.
+		body = strings.NewReader(rawQuery)
+		rawQuery = ""
 	}
+	logsURL := BuildURL(h.config.Scheme, h.config.Host, path, rawQuery)
 
 	if err := StreamProxyRequest(req.Context(), logsURL, req.Method, body, res.Writer); err != nil {
 		res.Logger.Error(err, "failed to proxy request")
@@ -48,3 +108,24 @@ func (h *logsHandler) HandleTenantInjection(res *server.Response, req *http.Requ
 		return
 	}
 }
+
+// injectLogsExtraFilters sets the tenant filters as the extra_filters and
+// extra_stream_filters parameters, replacing the ones of the request.
+func injectLogsExtraFilters(params url.Values, filters logsTenantFilters) error {
+	params.Set("extra_filters", filters.filter)
+	if filters.streamFilter != "" {
+		params.Set("extra_stream_filters", filters.streamFilter)
+	}
+	return nil
+}
+
+// injectLogsQueryFilter restricts the query parameter to the tenants in
+// addition to the extra filters.
+func injectLogsQueryFilter(params url.Values, filters logsTenantFilters) error {
+	query, err := restrictLogsQL(params.Get("query"), filters.filter)
+	if err != nil {
+		return err
+	}
+	params.Set("query", query)
+	return injectLogsExtraFilters(params, filters)
+}
@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	"github.com/k0rdent/kof/kof-operator/internal/acl/auditlog"
+	"github.com/k0rdent/kof/kof-operator/internal/acl/tenant"
+	"github.com/k0rdent/kof/kof-operator/internal/audit"
+	"github.com/k0rdent/kof/kof-operator/internal/server"
+	"github.com/k0rdent/kof/kof-operator/internal/server/helper"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+type auditRecorder struct {
+	events []audit.AuditEvent
+}
+
+func (r *auditRecorder) Record(event audit.AuditEvent) {
+	r.events = append(r.events, event)
+}
+
+var _ = Describe("LogsHandler", func() {
+	var (
+		req           *http.Request
+		res           *server.Response
+		mockBackend   *httptest.Server
+		handler       Proxy
+		receivedQuery url.Values
+		receivedForm  url.Values
+		logger        = ctrl.Log.WithName("test")
+	)
+
+	BeforeEach(func() {
+		receivedQuery = nil
+		receivedForm = nil
+		mockBackend = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			receivedQuery = r.URL.Query()
+			Expect(r.ParseForm()).To(Succeed())
+			receivedForm = r.PostForm
+			w.WriteHeader(http.StatusOK)
+		}))
+
+		parsedURL, err := url.Parse(mockBackend.URL)
+		Expect(err).NotTo(HaveOccurred())
+
+		handler = NewLogsHandler(Config{Host: parsedURL.Host, Scheme: "http"})
+		req = httptest.NewRequest(http.MethodGet, "/logs/select/logsql/query?query=error", nil)
+		res = &server.Response{
+			Writer: httptest.NewRecorder(),
+			Logger: &logger,
+		}
+	})
+
+	AfterEach(func() {
+		mockBackend.Close()
+	})
+
+	DescribeTable("should inject the tenant extra filter",
+		func(groups []any, header, expected string) {
+			idToken := MockIDToken(map[string]any{
+				"email":  "user@example.com",
+				"groups": groups,
+			})
+			req = req.WithContext(context.WithValue(req.Context(), helper.IdTokenContextKey, idToken))
+			if header != "" {
+				req.Header.Set(TenantHeaderName, header)
+			}
+
+			ACLProxy(res, req, handler)
+
+			recorder := res.Writer.(*httptest.ResponseRecorder)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(receivedQuery.Get("query")).To(Equal("error"))
+			Expect(receivedQuery.Get("extra_filters")).To(Equal(expected))
+		},
+		Entry("single tenant", []any{"tenant:a"}, "", `tenant:="a"`),
+		Entry("several tenants", []any{"tenant:a", "tenant:b"}, "", `tenant:in("a","b")`),
+		Entry("tenant selected by header", []any{"tenant:a", "tenant:b"}, "b", `tenant:="b"`),
+	)
+
+	authenticate := func(groups ...any) {
+		idToken := MockIDToken(map[string]any{
+			"email":  "user@example.com",
+			"groups": groups,
+		})
+		req = req.WithContext(context.WithValue(req.Context(), helper.IdTokenContextKey, idToken))
+	}
+
+	It("should add the tenant filter to the query of endpoints ignoring extra_filters", func() {
+		req = httptest.NewRequest(http.MethodGet, `/logs/select/logsql/hits?query=error+or+warn+|+stats+count()`, nil)
+		authenticate("tenant:a")
+
+		ACLProxy(res, req, handler)
+
+		Expect(res.Writer.(*httptest.ResponseRecorder).Code).To(Equal(http.StatusOK))
+		Expect(receivedQuery.Get("query")).To(Equal(`tenant:="a" (error or warn) | stats count()`))
+		Expect(receivedQuery.Get("extra_filters")).To(Equal(`tenant:="a"`))
+	})
+
+	It("should rewrite the form of POST requests", func() {
+		form := url.Values{"query": {"*"}, "extra_filters": {`tenant:="b"`}}
+		req = httptest.NewRequest(http.MethodPost, "/logs/select/logsql/field_names?extra_filters=x", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		authenticate("tenant:a")
+
+		ACLProxy(res, req, handler)
+
+		Expect(res.Writer.(*httptest.ResponseRecorder).Code).To(Equal(http.StatusOK))
+		Expect(receivedQuery).To(BeEmpty())
+		Expect(receivedForm.Get("query")).To(Equal(`tenant:="a" (*)`))
+		Expect(receivedForm.Get("extra_filters")).To(Equal(`tenant:="a"`))
+	})
+
+	It("should inject the tenant stream filter when the tenant is a stream field", func() {
+		parsedURL, err := url.Parse(mockBackend.URL)
+		Expect(err).NotTo(HaveOccurred())
+		handler = NewLogsHandler(Config{Host: parsedURL.Host, Scheme: "http", TenantStreamField: true})
+		authenticate("tenant:a", "tenant:b")
+
+		ACLProxy(res, req, handler)
+
+		Expect(res.Writer.(*httptest.ResponseRecorder).Code).To(Equal(http.StatusOK))
+		Expect(receivedQuery.Get("extra_stream_filters")).To(Equal(`{tenant in("a","b")}`))
+	})
+
+	It("should propagate the correlation ID and record the access decision", func() {
+		var receivedCorrelationID string
+		mockBackend.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			receivedCorrelationID = r.Header.Get(auditlog.CorrelationIDHeader)
+			w.WriteHeader(http.StatusOK)
+		})
+		recorder := &auditRecorder{}
+		req.Header.Set(auditlog.CorrelationIDHeader, "req-1")
+		authenticate("tenant:a", "tenant:b")
+
+		auditlog.Middleware(recorder, nil)(func(res *server.Response, req *http.Request) {
+			ACLProxy(res, req, handler)
+		})(res, req)
+
+		Expect(res.Writer.(*httptest.ResponseRecorder).Code).To(Equal(http.StatusOK))
+		Expect(receivedCorrelationID).To(Equal("req-1"))
+		Expect(recorder.events).To(HaveLen(1))
+		Expect(recorder.events[0].Authorization.Decision).To(Equal(auditlog.DecisionAllow))
+		Expect(recorder.events[0].Actor.ID).To(Equal("user@example.com"))
+		Expect(recorder.events[0].Flatten()).To(HaveKeyWithValue("acl.tenants", "a,b"))
+	})
+
+	It("should record the denial of a tenant that is not permitted", func() {
+		recorder := &auditRecorder{}
+		req.Header.Set(TenantHeaderName, "c")
+		authenticate("tenant:a")
+
+		auditlog.Middleware(recorder, nil)(func(res *server.Response, req *http.Request) {
+			ACLProxy(res, req, handler)
+		})(res, req)
+
+		Expect(res.Writer.(*httptest.ResponseRecorder).Code).To(Equal(http.StatusForbidden))
+		Expect(recorder.events).To(HaveLen(1))
+		Expect(recorder.events[0].Authorization.Decision).To(Equal(auditlog.DecisionDeny))
+		Expect(recorder.events[0].Authorization.Policy).To(Equal(auditlog.PolicyTenant))
+	})
+
+	It("should inject the fixed tenant of an API key", func() {
+		req = req.WithContext(context.WithValue(req.Context(), helper.PrincipalContextKey, &helper.Principal{
+			Name:    "apikey:kof/ci",
+			Tenants: []string{"a"},
+		}))
+
+		ACLProxy(res, req, handler)
+
+		Expect(res.Writer.(*httptest.ResponseRecorder).Code).To(Equal(http.StatusOK))
+		Expect(receivedQuery.Get("extra_filters")).To(Equal(`tenant:="a"`))
+	})
+
+	It("should resolve the tenants of a ServiceAccount by the tenant policy", func() {
+		policy, err := tenant.ParsePolicy([]byte(`groupMappings:
+- pattern: "system:serviceaccounts:team-(.+)"
+  tenant: "$1"
+`))
+		Expect(err).NotTo(HaveOccurred())
+		parsedURL, err := url.Parse(mockBackend.URL)
+		Expect(err).NotTo(HaveOccurred())
+		handler = NewLogsHandler(Config{Host: parsedURL.Host, Scheme: "http", TenantResolver: policy})
+		req = req.WithContext(context.WithValue(req.Context(), helper.PrincipalContextKey, &helper.Principal{
+			Name: "system:serviceaccount:team-b:ci",
+			Claims: map[string]any{
+				"email":  "system:serviceaccount:team-b:ci",
+				"groups": []any{"system:serviceaccounts", "system:serviceaccounts:team-b"},
+			},
+		}))
+
+		ACLProxy(res, req, handler)
+
+		Expect(res.Writer.(*httptest.ResponseRecorder).Code).To(Equal(http.StatusOK))
+		Expect(receivedQuery.Get("extra_filters")).To(Equal(`tenant:="b"`))
+	})
+
+	It("should deny endpoints that are not allowlisted", func() {
+		req = httptest.NewRequest(http.MethodGet, "/logs/select/logsql/delete?query=*", nil)
+		authenticate("tenant:a")
+
+		ACLProxy(res, req, handler)
+
+		Expect(res.Writer.(*httptest.ResponseRecorder).Code).To(Equal(http.StatusForbidden))
+		Expect(receivedQuery).To(BeNil())
+	})
+
+	It("should reject pipes running subqueries", func() {
+		req = httptest.NewRequest(http.MethodGet, `/logs/select/logsql/streams?query=*+|+join+by+(x)+(tenant:=b)`, nil)
+		authenticate("tenant:a")
+
+		ACLProxy(res, req, handler)
+
+		recorder := res.Writer.(*httptest.ResponseRecorder)
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		Expect(recorder.Body.String()).To(ContainSubstring("pipe is not allowed"))
+		Expect(receivedQuery).To(BeNil())
+	})
+})
+
+var _ = Describe("splitLogsQL", func() {
+	DescribeTable("should split the filters and pipes at top-level separators",
+		func(query, filters string, pipes ...string) {
+			f, p, err := splitLogsQL(query)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(f).To(Equal(filters))
+			Expect(p).To(HaveExactElements(pipes))
+		},
+		Entry("filters only", `error`, `error`),
+		Entry("pipes", `error | stats count() | limit 5`, `error`, " stats count() ", " limit 5"),
+		Entry("quoted separator", `"a|b" | limit 1`, `"a|b"`, " limit 1"),
+		Entry("regexp separator", `app:~'x|y'`, `app:~'x|y'`),
+		Entry("nested separator", `user:in(* | fields user) | limit 1`, `user:in(* | fields user)`, " limit 1"),
+		Entry("half-open time range", `_time:[2024-01-01, 2024-02-01) | limit 1`, `_time:[2024-01-01, 2024-02-01)`, " limit 1"),
+	)
+
+	It("should reject unterminated strings and parentheses", func() {
+		_, _, err := splitLogsQL(`"abc`)
+		Expect(err).To(HaveOccurred())
+		_, _, err = splitLogsQL(`(abc`)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// logsQLForbiddenPipes run subqueries that the tenant filter of the outer query does not apply to.
+var logsQLForbiddenPipes = []string{"join", "union"}
+
+// splitLogsQL splits a LogsQL query into its filters and pipes at the top-level
+// '|' separators, outside quoted strings, parentheses, brackets and braces.
+func splitLogsQL(query string) (string, []string, error) {
+	var parts []string
+	depth := 0
+	start := 0
+	for i := 0; i < len(query); i++ {
+		switch c := query[i]; c {
+		case '"', '\'', '`':
+			end, err := skipLogsQLString(query, i)
+			if err != nil {
+				return "", nil, err
+			}
+			i = end
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			// Time ranges such as _time:[start, end) mix bracket kinds.
+			depth--
+			if depth < 0 {
+				return "", nil, fmt.Errorf("unexpected %q at position %d", c, i)
+			}
+		case '|':
+			if depth == 0 {
+				parts = append(parts, query[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return "", nil, fmt.Errorf("unbalanced parentheses")
+	}
+	parts = append(parts, query[start:])
+	return strings.TrimSpace(parts[0]), parts[1:], nil
+}
+
+// skipLogsQLString returns the position of the quote closing the string starting at i.
+func skipLogsQLString(query string, i int) (int, error) {
+	quote := query[i]
+	for j := i + 1; j < len(query); j++ {
+		switch query[j] {
+		case '\\':
+			if quote != '`' {
+				j++
+			}
+		case quote:
+			return j, nil
+		}
+	}
+	return 0, fmt.Errorf("unterminated string at position %d", i)
+}
+
+// restrictLogsQL returns the LogsQL query with its filters restricted by the
+// tenant filter. Pipes running subqueries are rejected.
+func restrictLogsQL(query, tenantFilter string) (string, error) {
+	filters, pipes, err := splitLogsQL(query)
+	if err != nil {
+		return "", fmt.Errorf("invalid LogsQL query: %w", err)
+	}
+
+	for _, pipe := range pipes {
+		name, _, _ := strings.Cut(strings.TrimSpace(pipe), " ")
+		for _, forbidden := range logsQLForbiddenPipes {
+			if strings.EqualFold(name, forbidden) {
+				return "", fmt.Errorf("the %q pipe is not allowed", forbidden)
+			}
+		}
+	}
+
+	if filters == "" {
+		filters = "*"
+	}
+	restricted := fmt.Sprintf("%s (%s)", tenantFilter, filters)
+	if len(pipes) > 0 {
+		restricted += " |" + strings.Join(pipes, "|")
+	}
+	return restricted, nil
+}
+
+// logsQLTenantStreamFilter returns the LogsQL stream filter matching any of the tenants in the given field.
+func logsQLTenantStreamFilter(field string, tenants []string) string {
+	if len(tenants) == 1 {
+		return fmt.Sprintf("{%s=%s}", field, strconv.Quote(tenants[0]))
+	}
+
+	values := make([]string, 0, len(tenants))
+	for _, tenantID := range tenants {
+		values = append(values, strconv.Quote(tenantID))
+	}
+	return fmt.Sprintf("{%s in(%s)}", field, strings.Join(values, ","))
+}
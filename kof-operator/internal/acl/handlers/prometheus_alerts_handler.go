@@ -6,7 +6,8 @@ import (
 	"net/http"
 	"strings"
 
-	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/k0rdent/kof/kof-operator/internal/acl/authz"
+	"github.com/k0rdent/kof/kof-operator/internal/acl/tenant"
 	"github.com/k0rdent/kof/kof-operator/internal/server"
 	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
 )
@@ -29,17 +30,16 @@ func NewPromxyAlertsHandler(cfg Config) Proxy {
 	return &PromxyAlertsHandler{config: cfg}
 }
 
-func (h *PromxyAlertsHandler) AdminEmail() string { return h.config.AdminEmail }
-func (h *PromxyAlertsHandler) IsDevMode() bool    { return h.config.DevMode }
-func (h *PromxyAlertsHandler) Schema() string     { return h.config.Scheme }
-func (h *PromxyAlertsHandler) Host() string       { return h.config.Host }
+func (h *PromxyAlertsHandler) TenantResolver() tenant.Resolver { return h.config.TenantResolver }
+func (h *PromxyAlertsHandler) IsDevMode() bool                 { return h.config.DevMode }
+func (h *PromxyAlertsHandler) Schema() string                  { return h.config.Scheme }
+func (h *PromxyAlertsHandler) Host() string                    { return h.config.Host }
 
-func (h *PromxyAlertsHandler) HandleTenantInjection(res *server.Response, req *http.Request, idToken *oidc.IDToken) {
+func (h *PromxyAlertsHandler) HandleTenantInjection(res *server.Response, req *http.Request, identity *tenant.Identity) {
 	ctx := req.Context()
 
-	tenantID, err := ExtractTenantIDFromToken(idToken)
-	if err != nil {
-		res.Fail(fmt.Sprintf("failed to extract tenant ID: %v", err), http.StatusUnauthorized)
+	scope, ok := requestScope(res, req, identity, h.config.Authorizer, authz.SignalMetrics)
+	if !ok {
 		return
 	}
 
@@ -68,12 +68,14 @@ func (h *PromxyAlertsHandler) HandleTenantInjection(res *server.Response, req *h
 		return
 	}
 
-	filteredAlerts := filterAlertsByTenant(alerts.Data.Alerts, tenantID)
+	filteredAlerts := filterAlertsByScope(alerts.Data.Alerts, scope)
 	alerts.Data.Alerts = filteredAlerts
 	res.SendObj(alerts, http.StatusOK)
 }
 
-func filterAlertsByTenant(alerts []*v1.Alert, tenantID string) []*v1.Alert {
+// filterAlertsByScope returns the alerts whose tenant, cluster and namespace
+// labels are granted by the scope.
+func filterAlertsByScope(alerts []*v1.Alert, scope *authz.Scope) []*v1.Alert {
 	matchingAlerts := make([]*v1.Alert, 0, len(alerts))
 
 	if len(alerts) == 0 {
@@ -85,7 +87,11 @@ func filterAlertsByTenant(alerts []*v1.Alert, tenantID string) []*v1.Alert {
 			continue
 		}
 
-		if val, ok := alert.Labels[TenantLabelName]; ok && string(val) == tenantID {
+		if scope.Allows(
+			string(alert.Labels[TenantLabelName]),
+			string(alert.Labels[ClusterLabelName]),
+			string(alert.Labels[NamespaceLabelName]),
+		) {
 			matchingAlerts = append(matchingAlerts, alert)
 		}
 	}
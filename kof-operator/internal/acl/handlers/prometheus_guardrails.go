@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/k0rdent/kof/kof-operator/internal/acl/guardrails"
+	"github.com/k0rdent/kof/kof-operator/internal/server"
+)
+
+// PrometheusSeriesPath is the promxy endpoint used for the series-count preflight.
+const PrometheusSeriesPath = "/api/v1/series"
+
+// applyGuardrails checks the tenant-injected query against the limits of the
+// tenants and admits it. It returns the func releasing the query slot, to call
+// once the query is proxied, or writes the rejection and returns false.
+func (h *PromxyQueryHandler) applyGuardrails(
+	res *server.Response,
+	req *http.Request,
+	query url.Values,
+	tenants []string,
+) (func(), bool) {
+	guard := h.config.Guard
+	if guard == nil {
+		return func() {}, true
+	}
+
+	limits := guard.QueryLimits(tenants)
+	request, rejection := guardrails.ParseRequest(req.URL.Path, query, time.Duration(limits.MaxRange), time.Now())
+	if rejection == nil {
+		rejection = request.Check(limits)
+	}
+	if rejection != nil {
+		guard.Reject(tenants, rejection).Write(res.Writer)
+		return nil, false
+	}
+
+	release, rejection := guard.Admit(tenants)
+	if rejection != nil {
+		rejection.Write(res.Writer)
+		return nil, false
+	}
+
+	if request.NeedsSeriesPreflight(limits) {
+		count, err := h.countSeries(req, request, limits.MaxSeries+1)
+		switch {
+		case err != nil:
+			res.Logger.Error(err, "series count preflight failed")
+			rejection = guardrails.PreflightFailed(err)
+		case count > limits.MaxSeries:
+			rejection = guardrails.SeriesLimitExceeded(limits)
+		}
+		if rejection != nil {
+			release()
+			guard.Reject(tenants, rejection).Write(res.Writer)
+			return nil, false
+		}
+	}
+
+	return release, true
+}
+
+// countSeries counts the series matched by the selectors of the request over
+// the data it selects, stopping at limit.
+func (h *PromxyQueryHandler) countSeries(req *http.Request, request *guardrails.Request, limit int) (int, error) {
+	params := url.Values{}
+	for _, selector := range request.Selectors {
+		params.Add(PrometheusMatchParamName, selector)
+	}
+	params.Set("start", request.MinTime.Format(time.RFC3339Nano))
+	params.Set("end", request.MaxTime.Format(time.RFC3339Nano))
+	params.Set("limit", strconv.Itoa(limit))
+
+	seriesURL := BuildURL(h.config.Scheme, h.config.Host, PrometheusSeriesPath, params.Encode())
+	resp, err := ProxyRequest(req.Context(), seriesURL, http.MethodGet, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.Printf("failed to close response body: %v\n", err)
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("received non-OK response: %s", resp.Status)
+	}
+
+	return countSeriesResponse(resp.Body, limit)
+}
+
+// countSeriesResponse counts the entries of the "data" array of a series API
+// response without decoding them, stopping at limit.
+func countSeriesResponse(body io.Reader, limit int) (int, error) {
+	dec := json.NewDecoder(body)
+	if err := expectDelim(dec, '{'); err != nil {
+		return 0, err
+	}
+
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return 0, fmt.Errorf("failed to decode series response: %w", err)
+		}
+		if key != "data" {
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return 0, fmt.Errorf("failed to decode series response: %w", err)
+			}
+			continue
+		}
+
+		if err := expectDelim(dec, '['); err != nil {
+			return 0, err
+		}
+		count := 0
+		for dec.More() && count < limit {
+			var series json.RawMessage
+			if err := dec.Decode(&series); err != nil {
+				return 0, fmt.Errorf("failed to decode series response: %w", err)
+			}
+			count++
+		}
+		return count, nil
+	}
+
+	return 0, fmt.Errorf("series response has no data")
+}
+
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("failed to decode series response: %w", err)
+	}
+	if tok != delim {
+		return fmt.Errorf("unexpected series response: got %v, want %v", tok, delim)
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	"github.com/k0rdent/kof/kof-operator/internal/acl/guardrails"
+	"github.com/k0rdent/kof/kof-operator/internal/server"
+	"github.com/k0rdent/kof/kof-operator/internal/server/helper"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("PromxyQueryHandler guardrails", func() {
+	var (
+		mockPromxy  *httptest.Server
+		seriesCount int
+		seriesReqs  []url.Values
+		queryReqs   int
+		logger      = ctrl.Log.WithName("test")
+	)
+
+	BeforeEach(func() {
+		seriesCount = 0
+		seriesReqs = nil
+		queryReqs = 0
+		mockPromxy = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			if r.URL.Path == PrometheusSeriesPath {
+				seriesReqs = append(seriesReqs, r.URL.Query())
+				series := make([]map[string]string, seriesCount)
+				for i := range series {
+					series[i] = map[string]string{"__name__": "up", "tenant": "test-tenant"}
+				}
+				Expect(json.NewEncoder(w).Encode(map[string]any{"status": "success", "data": series})).To(Succeed())
+				return
+			}
+			queryReqs++
+			Expect(json.NewEncoder(w).Encode(map[string]any{"status": "success", "data": map[string]any{}})).To(Succeed())
+		}))
+	})
+
+	AfterEach(func() {
+		mockPromxy.Close()
+	})
+
+	newHandler := func(policy string) *PromxyQueryHandler {
+		p, err := guardrails.ParsePolicy([]byte(policy))
+		Expect(err).NotTo(HaveOccurred())
+		parsedURL, err := url.Parse(mockPromxy.URL)
+		Expect(err).NotTo(HaveOccurred())
+		return &PromxyQueryHandler{config: Config{
+			Host:   parsedURL.Host,
+			Scheme: "http",
+			Guard:  guardrails.NewGuard(p, prometheus.NewRegistry()),
+		}}
+	}
+
+	do := func(handler *PromxyQueryHandler, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		idToken := MockIDToken(map[string]any{
+			"email":          "user@example.com",
+			"groups":         []any{"tenant:test-tenant"},
+			"email_verified": true,
+		})
+		req = req.WithContext(context.WithValue(req.Context(), helper.IdTokenContextKey, idToken))
+		recorder := httptest.NewRecorder()
+		ACLProxy(&server.Response{Writer: recorder, Logger: &logger}, req, handler)
+		return recorder
+	}
+
+	expectRejection := func(recorder *httptest.ResponseRecorder, status int, errorType, message string) {
+		Expect(recorder.Code).To(Equal(status))
+		var response map[string]string
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &response)).To(Succeed())
+		Expect(response).To(HaveKeyWithValue("status", "error"))
+		Expect(response).To(HaveKeyWithValue("errorType", errorType))
+		Expect(response["error"]).To(ContainSubstring(message))
+	}
+
+	It("should reject a range query over the maximum range", func() {
+		handler := newHandler("default: {maxRange: 1d}")
+
+		recorder := do(handler, "/metrics/api/v1/query_range?query=up&start=0&end=172800&step=60")
+
+		expectRejection(recorder, http.StatusBadRequest, guardrails.ErrorTypeBadData, "the limit is 1d")
+		Expect(queryReqs).To(BeZero())
+	})
+
+	It("should reject a range query below the minimum step", func() {
+		handler := newHandler("default: {minStep: 15s}")
+
+		recorder := do(handler, "/metrics/api/v1/query_range?query=up&start=0&end=3600&step=1")
+
+		expectRejection(recorder, http.StatusBadRequest, guardrails.ErrorTypeBadData, "below the minimum of 15s")
+	})
+
+	It("should rate limit the requests of a tenant", func() {
+		handler := newHandler("default: {requestsPerSecond: 0.001, burst: 1}")
+
+		Expect(do(handler, "/metrics/api/v1/query?query=up").Code).To(Equal(http.StatusOK))
+		recorder := do(handler, "/metrics/api/v1/query?query=up")
+
+		expectRejection(recorder, http.StatusTooManyRequests, guardrails.ErrorTypeUnavailable, "rate limit")
+		Expect(recorder.Header().Get("Retry-After")).NotTo(BeEmpty())
+		Expect(queryReqs).To(Equal(1))
+	})
+
+	It("should check the series count of the injected selectors before proxying", func() {
+		handler := newHandler("default: {maxSeries: 2}")
+		seriesCount = 2
+
+		recorder := do(handler, "/metrics/api/v1/query?query=rate(up[5m])&time=3600")
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(queryReqs).To(Equal(1))
+		Expect(seriesReqs).To(HaveLen(1))
+		Expect(seriesReqs[0]["match[]"]).To(ConsistOf(`{tenant="test-tenant",__name__="up"}`))
+		Expect(seriesReqs[0].Get("limit")).To(Equal("3"))
+		Expect(seriesReqs[0].Get("start")).To(Equal("1970-01-01T00:55:00Z"))
+		Expect(seriesReqs[0].Get("end")).To(Equal("1970-01-01T01:00:00Z"))
+	})
+
+	It("should reject a query selecting too many series", func() {
+		handler := newHandler("default: {maxSeries: 2}")
+		seriesCount = 5
+
+		recorder := do(handler, "/metrics/api/v1/query?query=up")
+
+		expectRejection(recorder, http.StatusUnprocessableEntity, guardrails.ErrorTypeExecution, "more than 2 series")
+		Expect(queryReqs).To(BeZero())
+	})
+
+	It("should not limit queries without a guard", func() {
+		handler := newHandler("")
+		handler.config.Guard = nil
+
+		recorder := do(handler, "/metrics/api/v1/query_range?query=up&start=0&end=172800&step=1")
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(seriesReqs).To(BeEmpty())
+	})
+})
+
+var _ = Describe("countSeriesResponse", func() {
+	It("should count the series up to the limit", func() {
+		body := `{"status":"success","data":[{"a":"1"},{"a":"2"},{"a":"3"}]}`
+
+		Expect(countSeriesResponse(strings.NewReader(body), 10)).To(Equal(3))
+		Expect(countSeriesResponse(strings.NewReader(body), 2)).To(Equal(2))
+	})
+
+	It("should skip the fields before data", func() {
+		body := `{"status":"success","warnings":["w"],"data":[]}`
+
+		Expect(countSeriesResponse(strings.NewReader(body), 10)).To(BeZero())
+	})
+
+	It("should fail on a response without data", func() {
+		_, err := countSeriesResponse(strings.NewReader(`{"status":"error"}`), 10)
+		Expect(err).To(HaveOccurred())
+	})
+})
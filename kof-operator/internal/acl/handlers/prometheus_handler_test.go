@@ -7,6 +7,7 @@ import (
 	"net/http/httptest"
 	"net/url"
 
+	"github.com/k0rdent/kof/kof-operator/internal/acl/tenant"
 	"github.com/k0rdent/kof/kof-operator/internal/server"
 	"github.com/k0rdent/kof/kof-operator/internal/server/helper"
 	. "github.com/onsi/ginkgo/v2"
@@ -57,10 +58,9 @@ var _ = Describe("HandleQueryWithTenant", func() {
 		Expect(err).NotTo(HaveOccurred())
 
 		handler = &PromxyQueryHandler{config: Config{
-			Host:       parsedURL.Host,
-			Scheme:     "http",
-			DevMode:    false,
-			AdminEmail: "",
+			Host:    parsedURL.Host,
+			Scheme:  "http",
+			DevMode: false,
 		}}
 
 		req = httptest.NewRequest(http.MethodGet, "/api/v1/query?query=up", nil)
@@ -220,7 +220,27 @@ var _ = Describe("HandleQueryWithTenant", func() {
 
 	Context("when user is authenticated as admin", func() {
 		BeforeEach(func() {
-			handler.config.AdminEmail = "admin@example.com"
+			policy, err := tenant.ParsePolicy([]byte(`
+adminEmails: [admin@example.com, sre@example.com]
+adminGroups: [kof-admins]
+`))
+			Expect(err).NotTo(HaveOccurred())
+			handler.config.TenantResolver = policy
+		})
+
+		It("should bypass tenant filtering for admin group member", func() {
+			idToken := MockIDToken(map[string]any{
+				"email":  "someone@example.com",
+				"groups": []any{"kof-admins"},
+			})
+
+			ctx := context.WithValue(req.Context(), helper.IdTokenContextKey, idToken)
+			req = req.WithContext(ctx)
+
+			ACLProxy(res, req, handler)
+
+			recorder := res.Writer.(*httptest.ResponseRecorder)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
 		})
 
 		It("should bypass tenant filtering for admin user", func() {
@@ -265,40 +285,90 @@ var _ = Describe("HandleQueryWithTenant", func() {
 	})
 })
 
-var _ = Describe("Tenant ID Extraction", func() {
-	It("should extract tenant ID from groups with correct prefix", func() {
-		groups := []string{"tenant:production", "admin", "developers"}
-		tenantID := getTenantIDFromGroups(groups)
-		Expect(tenantID).To(Equal("production"))
+var _ = Describe("Multi-tenant queries", func() {
+	var (
+		req           *http.Request
+		res           *server.Response
+		mockPromxy    *httptest.Server
+		handler       *PromxyQueryHandler
+		receivedQuery string
+		logger        = ctrl.Log.WithName("test")
+	)
+
+	BeforeEach(func() {
+		receivedQuery = ""
+		mockPromxy = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			receivedQuery = r.URL.Query().Get("query")
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`))
+		}))
+
+		parsedURL, err := url.Parse(mockPromxy.URL)
+		Expect(err).NotTo(HaveOccurred())
+
+		handler = &PromxyQueryHandler{config: Config{Host: parsedURL.Host, Scheme: "http"}}
+		res = &server.Response{
+			Writer: httptest.NewRecorder(),
+			Logger: &logger,
+		}
 	})
 
-	It("should return first matching tenant group when multiple exist", func() {
-		groups := []string{"tenant:prod", "tenant:staging", "admin"}
-		tenantID := getTenantIDFromGroups(groups)
-		Expect(tenantID).To(Equal("prod"))
+	AfterEach(func() {
+		mockPromxy.Close()
 	})
 
-	It("should return empty string when no tenant group exists", func() {
-		groups := []string{"admin", "developers", "viewers"}
-		tenantID := getTenantIDFromGroups(groups)
-		Expect(tenantID).To(BeEmpty())
+	withToken := func(r *http.Request, groups ...any) *http.Request {
+		idToken := MockIDToken(map[string]any{
+			"email":  "sre@example.com",
+			"groups": groups,
+		})
+		return r.WithContext(context.WithValue(r.Context(), helper.IdTokenContextKey, idToken))
+	}
+
+	It("should inject a regex set matcher for all permitted tenants", func() {
+		req = withToken(httptest.NewRequest(http.MethodGet, "/api/v1/query?query=up", nil),
+			"tenant:a", "tenant:b.c", "tenant:d")
+
+		ACLProxy(res, req, handler)
+
+		recorder := res.Writer.(*httptest.ResponseRecorder)
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(receivedQuery).To(Equal(`up{tenant=~"a|b\\.c|d"}`))
 	})
 
-	It("should return empty string for empty groups", func() {
-		groups := []string{}
-		tenantID := getTenantIDFromGroups(groups)
-		Expect(tenantID).To(BeEmpty())
+	It("should keep the tenant matcher of the query intersected with the permitted set", func() {
+		req = withToken(httptest.NewRequest(http.MethodGet, `/api/v1/query?query=up{tenant="x"}`, nil),
+			"tenant:a", "tenant:b")
+
+		ACLProxy(res, req, handler)
+
+		recorder := res.Writer.(*httptest.ResponseRecorder)
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(receivedQuery).To(Equal(`up{tenant="x",tenant=~"a|b"}`))
 	})
 
-	It("should handle tenant groups with special characters", func() {
-		groups := []string{"tenant:my-tenant-123", "admin"}
-		tenantID := getTenantIDFromGroups(groups)
-		Expect(tenantID).To(Equal("my-tenant-123"))
+	It("should narrow the query to the tenant selected by the header", func() {
+		req = withToken(httptest.NewRequest(http.MethodGet, "/api/v1/query?query=up", nil),
+			"tenant:a", "tenant:b")
+		req.Header.Set(TenantHeaderName, "b")
+
+		ACLProxy(res, req, handler)
+
+		recorder := res.Writer.(*httptest.ResponseRecorder)
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(receivedQuery).To(Equal(`up{tenant="b"}`))
 	})
 
-	It("should not match partial prefix", func() {
-		groups := []string{"tenants:production", "mytenant:test"}
-		tenantID := getTenantIDFromGroups(groups)
-		Expect(tenantID).To(BeEmpty())
+	It("should forbid a tenant selected by the header that is not permitted", func() {
+		req = withToken(httptest.NewRequest(http.MethodGet, "/api/v1/query?query=up", nil),
+			"tenant:a", "tenant:b")
+		req.Header.Set(TenantHeaderName, "c")
+
+		ACLProxy(res, req, handler)
+
+		recorder := res.Writer.(*httptest.ResponseRecorder)
+		Expect(recorder.Code).To(Equal(http.StatusForbidden))
+		Expect(receivedQuery).To(BeEmpty())
 	})
 })
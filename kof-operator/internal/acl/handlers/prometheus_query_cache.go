@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/k0rdent/kof/kof-operator/internal/acl/guardrails"
+	"github.com/k0rdent/kof/kof-operator/internal/acl/querycache"
+	"github.com/k0rdent/kof/kof-operator/internal/server"
+)
+
+// serveCachedRange serves a tenant-injected query_range request through the
+// results cache, fetching the uncached parts of its range from promxy.
+func (h *PromxyQueryHandler) serveCachedRange(res *server.Response, req *http.Request, query url.Values, tenants []string) {
+	request, rejection := guardrails.ParseRequest(req.URL.Path, query, 0, time.Now())
+	if rejection != nil {
+		rejection.Write(res.Writer)
+		return
+	}
+
+	path := strings.TrimPrefix(req.URL.Path, "/metrics")
+	resp, err := h.config.Cache.QueryRange(req.Context(), querycache.Request{
+		Tenants: tenants,
+		Query:   query.Get(PrometheusQueryParamName),
+		Start:   request.Start,
+		End:     request.End,
+		Step:    request.Step,
+	}, func(ctx context.Context, start, end time.Time) (*querycache.Response, error) {
+		return h.fetchRange(ctx, path, query, start, end)
+	})
+	var upstreamErr *upstreamError
+	if errors.As(err, &upstreamErr) {
+		upstreamErr.write(res.Writer)
+		return
+	}
+	if err != nil {
+		res.Logger.Error(err, "failed to proxy request to promxy")
+		http.Error(res.Writer, "unable to make request", http.StatusInternalServerError)
+		return
+	}
+
+	res.Writer.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(res.Writer).Encode(resp); err != nil {
+		res.Logger.Error(err, "failed to write cached query response")
+	}
+}
+
+// maxUpstreamErrorBytes bounds the body of a non-OK promxy response passed through to the client.
+const maxUpstreamErrorBytes = 1 << 20
+
+// upstreamError is a non-OK response of promxy, e.g. the 400 of an invalid
+// query or the 503 of an unavailable backend, passed through to the client
+// unchanged.
+type upstreamError struct {
+	statusCode  int
+	contentType string
+	body        []byte
+}
+
+func (e *upstreamError) Error() string {
+	return fmt.Sprintf("received non-OK response: %d %s", e.statusCode, http.StatusText(e.statusCode))
+}
+
+func (e *upstreamError) write(w http.ResponseWriter) {
+	if e.contentType != "" {
+		w.Header().Set("Content-Type", e.contentType)
+	}
+	w.WriteHeader(e.statusCode)
+	if _, err := w.Write(e.body); err != nil {
+		log.Printf("failed to write upstream error response: %v\n", err)
+	}
+}
+
+// fetchRange runs the query_range request over [start, end] on promxy.
+// A non-OK response is returned as an upstreamError.
+func (h *PromxyQueryHandler) fetchRange(
+	ctx context.Context,
+	path string,
+	query url.Values,
+	start, end time.Time,
+) (*querycache.Response, error) {
+	params := maps.Clone(query)
+	params.Set("start", strconv.FormatFloat(float64(start.UnixMilli())/1000, 'f', -1, 64))
+	params.Set("end", strconv.FormatFloat(float64(end.UnixMilli())/1000, 'f', -1, 64))
+
+	// The query is sent in the body, as URLs of long queries may be rejected.
+	promxyURL := BuildURL(h.config.Scheme, h.config.Host, path, "")
+	resp, err := ProxyRequest(ctx, promxyURL, http.MethodPost, strings.NewReader(params.Encode()))
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.Printf("failed to close response body: %v\n", err)
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxUpstreamErrorBytes))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s response: %w", resp.Status, err)
+		}
+		return nil, &upstreamError{
+			statusCode:  resp.StatusCode,
+			contentType: resp.Header.Get("Content-Type"),
+			body:        body,
+		}
+	}
+
+	result := new(querycache.Response)
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return nil, fmt.Errorf("failed to decode query_range response: %w", err)
+	}
+	return result, nil
+}
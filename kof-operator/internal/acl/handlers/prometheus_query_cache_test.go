@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	"github.com/k0rdent/kof/kof-operator/internal/acl/querycache"
+	"github.com/k0rdent/kof/kof-operator/internal/server"
+	"github.com/k0rdent/kof/kof-operator/internal/server/helper"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("PromxyQueryHandler query cache", func() {
+	var (
+		mockPromxy *httptest.Server
+		handler    *PromxyQueryHandler
+		received   []url.Values
+		logger     = ctrl.Log.WithName("test")
+	)
+
+	BeforeEach(func() {
+		received = nil
+		mockPromxy = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.ParseForm()).To(Succeed())
+			received = append(received, r.PostForm)
+			if r.PostForm.Get("query") == `invalid{tenant="test-tenant"}` {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				_, err := w.Write([]byte(`{"status":"error","errorType":"execution","error":"query failed"}`))
+				Expect(err).NotTo(HaveOccurred())
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_, err := w.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[` +
+				`{"metric":{"__name__":"up","tenant":"test-tenant"},"values":[[` + r.PostForm.Get("start") + `,"1"]]}]}}`))
+			Expect(err).NotTo(HaveOccurred())
+		}))
+
+		parsedURL, err := url.Parse(mockPromxy.URL)
+		Expect(err).NotTo(HaveOccurred())
+		reg := prometheus.NewRegistry()
+		handler = &PromxyQueryHandler{config: Config{
+			Host:   parsedURL.Host,
+			Scheme: "http",
+			Cache:  querycache.New(querycache.NewMemoryBackend(1<<20, reg), querycache.Options{TTL: time.Hour}, reg),
+		}}
+	})
+
+	AfterEach(func() {
+		mockPromxy.Close()
+	})
+
+	do := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		idToken := MockIDToken(map[string]any{
+			"email":          "user@example.com",
+			"groups":         []any{"tenant:test-tenant"},
+			"email_verified": true,
+		})
+		req = req.WithContext(context.WithValue(req.Context(), helper.IdTokenContextKey, idToken))
+		recorder := httptest.NewRecorder()
+		ACLProxy(&server.Response{Writer: recorder, Logger: &logger}, req, handler)
+		return recorder
+	}
+
+	It("should fetch the tenant-injected query once and serve it from the cache", func() {
+		for range 2 {
+			recorder := do("/metrics/api/v1/query_range?query=up&start=65&end=600&step=60")
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+
+			var response querycache.Response
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &response)).To(Succeed())
+			Expect(response.Status).To(Equal("success"))
+			Expect(response.Data.Result).To(HaveLen(1))
+			Expect(response.Data.Result[0].Values[0].T).To(Equal(int64(60000)))
+		}
+
+		Expect(received).To(HaveLen(1))
+		Expect(received[0].Get("query")).To(Equal(`up{tenant="test-tenant"}`))
+		Expect(received[0].Get("start")).To(Equal("60"))
+		Expect(received[0].Get("end")).To(Equal("600"))
+	})
+
+	It("should pass non-OK promxy responses through unchanged", func() {
+		recorder := do("/metrics/api/v1/query_range?query=invalid&start=65&end=600&step=60")
+
+		Expect(recorder.Code).To(Equal(http.StatusUnprocessableEntity))
+		Expect(recorder.Header().Get("Content-Type")).To(Equal("application/json"))
+		Expect(recorder.Body.String()).To(Equal(`{"status":"error","errorType":"execution","error":"query failed"}`))
+	})
+
+	It("should proxy instant queries uncached", func() {
+		recorder := do("/metrics/api/v1/query?query=up")
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(received).To(HaveLen(1))
+	})
+})
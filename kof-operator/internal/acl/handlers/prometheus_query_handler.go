@@ -5,25 +5,19 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"regexp"
 	"strings"
 
-	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/k0rdent/kof/kof-operator/internal/acl/authz"
+	"github.com/k0rdent/kof/kof-operator/internal/acl/guardrails"
+	"github.com/k0rdent/kof/kof-operator/internal/acl/querycache"
+	"github.com/k0rdent/kof/kof-operator/internal/acl/tenant"
 	"github.com/k0rdent/kof/kof-operator/internal/server"
 	"github.com/prometheus-community/prom-label-proxy/injectproxy"
 	"github.com/prometheus/prometheus/model/labels"
 )
 
-type Claims struct {
-	Email    string   `json:"email"`
-	Name     string   `json:"name"`
-	Groups   []string `json:"groups"`
-	Verified bool     `json:"email_verified"`
-	TenantID string   `json:"tenant,omitempty"`
-}
-
 const (
-	TenantGroupPrefix = "tenant:"
-
 	// Query parameter name.
 	PrometheusQueryParamName = "query"
 	// Match parameter name used in series and labels endpoints.
@@ -36,10 +30,28 @@ const (
 )
 
 type Config struct {
-	Host       string
-	Scheme     string
-	DevMode    bool
-	AdminEmail string
+	Host    string
+	Scheme  string
+	DevMode bool
+	// TenantResolver resolves tenants and admin privileges from the token claims.
+	// The default tenant policy is used when it is not set.
+	TenantResolver tenant.Resolver
+	// TenantStreamField reports whether the tenant is a stream field of the
+	// logs, so that Logs API requests are also restricted by a stream filter.
+	TenantStreamField bool
+	// Authorizer restricts the clusters, namespaces and signals of the tenants
+	// a user may read. Tenants are not restricted further when it is not set.
+	Authorizer authz.Authorizer
+	// Guard enforces the per-tenant query limits of the Prometheus query API.
+	// Queries are not limited when it is not set.
+	Guard *guardrails.Guard
+	// Cache serves tenant-scoped query_range requests from a results cache.
+	// Requests are proxied uncached when it is not set.
+	Cache *querycache.Cache
+	// Username and Password authenticate the requests to the backend, such as
+	// the regional vmauth receiving the ingested data, when set.
+	Username string
+	Password string
 }
 
 // PromxyQueryHandler handles Prometheus query API requests with tenant isolation.
@@ -52,12 +64,12 @@ func NewPromxyQueryHandler(cfg Config) Proxy {
 	return &PromxyQueryHandler{config: cfg}
 }
 
-func (h *PromxyQueryHandler) AdminEmail() string { return h.config.AdminEmail }
-func (h *PromxyQueryHandler) IsDevMode() bool    { return h.config.DevMode }
-func (h *PromxyQueryHandler) Schema() string     { return h.config.Scheme }
-func (h *PromxyQueryHandler) Host() string       { return h.config.Host }
+func (h *PromxyQueryHandler) TenantResolver() tenant.Resolver { return h.config.TenantResolver }
+func (h *PromxyQueryHandler) IsDevMode() bool                 { return h.config.DevMode }
+func (h *PromxyQueryHandler) Schema() string                  { return h.config.Scheme }
+func (h *PromxyQueryHandler) Host() string                    { return h.config.Host }
 
-func (h *PromxyQueryHandler) HandleTenantInjection(res *server.Response, req *http.Request, idToken *oidc.IDToken) {
+func (h *PromxyQueryHandler) HandleTenantInjection(res *server.Response, req *http.Request, identity *tenant.Identity) {
 	var paramName string
 	var body io.Reader
 
@@ -88,13 +100,17 @@ func (h *PromxyQueryHandler) HandleTenantInjection(res *server.Response, req *ht
 		paramName = PrometheusMatchParamName
 	}
 
-	tenantID, err := ExtractTenantIDFromToken(idToken)
-	if err != nil {
-		res.Fail(fmt.Sprintf("failed to extract tenant ID: %v", err), http.StatusUnauthorized)
+	scope, ok := requestScope(res, req, identity, h.config.Authorizer, authz.SignalMetrics)
+	if !ok {
 		return
 	}
+	tenants := scope.Tenants()
 
-	modifiedQuery, err := injectTenantIDLabel(tenantID, query.Get(paramName))
+	modifiedQuery, err := injectScopeMatchers(scope, query.Get(paramName))
+	if errors.Is(err, errScopeMatchers) {
+		res.Fail(err.Error(), http.StatusForbidden)
+		return
+	}
 	if err != nil {
 		res.Fail(fmt.Sprintf("failed to inject tenant ID label into query: %v", err), http.StatusBadRequest)
 		return
@@ -102,6 +118,17 @@ func (h *PromxyQueryHandler) HandleTenantInjection(res *server.Response, req *ht
 
 	query.Set(paramName, modifiedQuery)
 
+	release, ok := h.applyGuardrails(res, req, query, tenants)
+	if !ok {
+		return
+	}
+	defer release()
+
+	if h.config.Cache != nil && guardrails.RequestKind(req.URL.Path) == guardrails.KindRange {
+		h.serveCachedRange(res, req, query, tenants)
+		return
+	}
+
 	if req.Method == http.MethodPost {
 		body = strings.NewReader(query.Encode())
 		query = req.URL.Query() // Clear query parameters from URL for POST requests, as they are sent in the body
@@ -123,16 +150,27 @@ func isQueryEndpoint(path string) bool {
 	return strings.Contains(path, "query")
 }
 
-// injectTenantIDLabel adds a tenant label matcher to a PromQL query using prom-label-proxy.
-// This ensures queries only access metrics belonging to the specified tenant.
-func injectTenantIDLabel(tenantID, originalQuery string) (string, error) {
-	enforcer := injectproxy.NewPromQLEnforcer(
-		false,
-		&labels.Matcher{
-			Name:  TenantLabelName,
-			Value: tenantID,
-			Type:  labels.MatchEqual,
-		},
-	)
-	return enforcer.Enforce(originalQuery)
+// injectScopeMatchers adds the label matchers of the scope to a PromQL query using prom-label-proxy.
+// This ensures queries only access metrics belonging to the specified tenants:
+// a single tenant is matched by equality, several tenants by an anchored regex set,
+// and the clusters and namespaces granted by the authorization policy by regexes.
+// Matchers of the same labels already present in the query are kept and intersected with them.
+func injectScopeMatchers(scope *authz.Scope, originalQuery string) (string, error) {
+	matchers, err := scopeMatchers(scope)
+	if err != nil {
+		return "", err
+	}
+	return injectproxy.NewPromQLEnforcer(false, matchers...).Enforce(originalQuery)
+}
+
+func tenantMatcher(tenants []string) (*labels.Matcher, error) {
+	if len(tenants) == 1 {
+		return labels.NewMatcher(labels.MatchEqual, TenantLabelName, tenants[0])
+	}
+
+	values := make([]string, 0, len(tenants))
+	for _, tenantID := range tenants {
+		values = append(values, regexp.QuoteMeta(tenantID))
+	}
+	return labels.NewMatcher(labels.MatchRegexp, TenantLabelName, strings.Join(values, "|"))
 }
@@ -7,7 +7,8 @@ import (
 	"slices"
 	"strings"
 
-	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/k0rdent/kof/kof-operator/internal/acl/authz"
+	"github.com/k0rdent/kof/kof-operator/internal/acl/tenant"
 	"github.com/k0rdent/kof/kof-operator/internal/server"
 	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
 )
@@ -54,17 +55,16 @@ func NewPromxyRulesHandler(cfg Config) Proxy {
 	return &PromxyRulesHandler{config: cfg}
 }
 
-func (h *PromxyRulesHandler) AdminEmail() string { return h.config.AdminEmail }
-func (h *PromxyRulesHandler) IsDevMode() bool    { return h.config.DevMode }
-func (h *PromxyRulesHandler) Schema() string     { return h.config.Scheme }
-func (h *PromxyRulesHandler) Host() string       { return h.config.Host }
+func (h *PromxyRulesHandler) TenantResolver() tenant.Resolver { return h.config.TenantResolver }
+func (h *PromxyRulesHandler) IsDevMode() bool                 { return h.config.DevMode }
+func (h *PromxyRulesHandler) Schema() string                  { return h.config.Scheme }
+func (h *PromxyRulesHandler) Host() string                    { return h.config.Host }
 
-func (h *PromxyRulesHandler) HandleTenantInjection(res *server.Response, req *http.Request, idToken *oidc.IDToken) {
+func (h *PromxyRulesHandler) HandleTenantInjection(res *server.Response, req *http.Request, identity *tenant.Identity) {
 	ctx := req.Context()
 
-	tenantID, err := ExtractTenantIDFromToken(idToken)
-	if err != nil {
-		res.Fail(fmt.Sprintf("failed to extract tenant ID: %v", err), http.StatusUnauthorized)
+	scope, ok := requestScope(res, req, identity, h.config.Authorizer, authz.SignalMetrics)
+	if !ok {
 		return
 	}
 
@@ -100,7 +100,7 @@ func (h *PromxyRulesHandler) HandleTenantInjection(res *server.Response, req *ht
 				continue
 			}
 
-			matchingAlerts := filterAlertsByTenant(rule.Alerts, tenantID)
+			matchingAlerts := filterAlertsByScope(rule.Alerts, scope)
 			isFiring := slices.ContainsFunc(matchingAlerts, func(alert *v1.Alert) bool {
 				return alert.State == v1.AlertStateFiring
 			})
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/k0rdent/kof/kof-operator/internal/acl/authz"
+	"github.com/prometheus/prometheus/model/labels"
+)
+
+const (
+	// ClusterLabelName is the label of the cluster of the metrics.
+	ClusterLabelName = "cluster"
+	// NamespaceLabelName is the label of the namespace of the metrics.
+	NamespaceLabelName = "namespace"
+	// ClusterAttributeName is the resource attribute of the cluster of the logs and spans.
+	ClusterAttributeName = "k8s.cluster.name"
+	// NamespaceAttributeName is the resource attribute of the namespace of the logs and spans.
+	NamespaceAttributeName = "k8s.namespace.name"
+)
+
+// errScopeMatchers is returned when the scope of a metrics request cannot be
+// enforced by a single set of label matchers.
+var errScopeMatchers = errors.New("forbidden: the authorization policy grants different clusters or " +
+	"namespaces of the tenants, select a single tenant with the " + TenantHeaderName + " header")
+
+// scopeFields are the LogsQL fields of the tenant, cluster and namespace of a signal.
+type scopeFields struct {
+	tenant    string
+	cluster   string
+	namespace string
+}
+
+var (
+	logsScopeFields = scopeFields{
+		tenant:    TenantLabelName,
+		cluster:   ClusterAttributeName,
+		namespace: NamespaceAttributeName,
+	}
+	tracesScopeFields = scopeFields{
+		tenant:    strconv.Quote("resource_attr:" + TenantLabelName),
+		cluster:   strconv.Quote("resource_attr:" + ClusterAttributeName),
+		namespace: strconv.Quote("resource_attr:" + NamespaceAttributeName),
+	}
+)
+
+// logsQLScopeFilter returns the LogsQL filter matching the data of the scope.
+func logsQLScopeFilter(scope *authz.Scope, fields scopeFields) string {
+	filters := make([]string, 0, len(scope.Grants))
+	for _, grant := range scope.Grants {
+		filter := logsQLTenantFilter(fields.tenant, grant.Tenants)
+		if len(grant.Clusters) > 0 {
+			filter += " " + logsQLPatternFilter(fields.cluster, grant.Clusters)
+		}
+		if len(grant.Namespaces) > 0 {
+			filter += " " + logsQLPatternFilter(fields.namespace, grant.Namespaces)
+		}
+		filters = append(filters, filter)
+	}
+
+	if len(filters) == 1 {
+		return filters[0]
+	}
+	return "((" + strings.Join(filters, ") or (") + "))"
+}
+
+// logsQLPatternFilter returns the LogsQL filter matching the values of field
+// matching any of the authorization policy patterns.
+func logsQLPatternFilter(field string, patterns []string) string {
+	return fmt.Sprintf("%s:~%s", field, strconv.Quote("^(?:"+authz.PatternsRegexp(patterns)+")$"))
+}
+
+// scopeMatchers returns the label matchers restricting metrics to the scope.
+func scopeMatchers(scope *authz.Scope) ([]*labels.Matcher, error) {
+	if len(scope.Grants) != 1 {
+		return nil, errScopeMatchers
+	}
+	grant := scope.Grants[0]
+
+	matcher, err := tenantMatcher(grant.Tenants)
+	if err != nil {
+		return nil, err
+	}
+	matchers := []*labels.Matcher{matcher}
+	if len(grant.Clusters) > 0 {
+		matchers = append(matchers, labels.MustNewMatcher(labels.MatchRegexp, ClusterLabelName, authz.PatternsRegexp(grant.Clusters)))
+	}
+	if len(grant.Namespaces) > 0 {
+		matchers = append(matchers, labels.MustNewMatcher(labels.MatchRegexp, NamespaceLabelName, authz.PatternsRegexp(grant.Namespaces)))
+	}
+	return matchers, nil
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	"github.com/k0rdent/kof/kof-operator/internal/acl/authz"
+	"github.com/k0rdent/kof/kof-operator/internal/server"
+	"github.com/k0rdent/kof/kof-operator/internal/server/helper"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const testAuthorizationPolicy = `
+rules:
+- name: team-a-prod
+  groups: [team-a]
+  tenants: [a]
+  clusters: ["prod-*"]
+- name: team-a-b
+  groups: [team-a]
+  tenants: [b]
+  namespaces: [web]
+  signals: [logs]
+`
+
+var _ = Describe("Authorization policy", func() {
+	var (
+		res           *server.Response
+		mockBackend   *httptest.Server
+		receivedQuery url.Values
+		config        Config
+		logger        = ctrl.Log.WithName("test")
+	)
+
+	BeforeEach(func() {
+		receivedQuery = nil
+		mockBackend = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			receivedQuery = r.URL.Query()
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`))
+		}))
+
+		parsedURL, err := url.Parse(mockBackend.URL)
+		Expect(err).NotTo(HaveOccurred())
+		policy, err := authz.ParsePolicy([]byte(testAuthorizationPolicy))
+		Expect(err).NotTo(HaveOccurred())
+
+		config = Config{Host: parsedURL.Host, Scheme: "http", Authorizer: policy}
+		res = &server.Response{
+			Writer: httptest.NewRecorder(),
+			Logger: &logger,
+		}
+	})
+
+	AfterEach(func() {
+		mockBackend.Close()
+	})
+
+	withToken := func(r *http.Request, groups ...any) *http.Request {
+		idToken := MockIDToken(map[string]any{
+			"email":  "dev@example.com",
+			"groups": groups,
+		})
+		return r.WithContext(context.WithValue(r.Context(), helper.IdTokenContextKey, idToken))
+	}
+
+	It("should inject the cluster matcher of the granted clusters into metrics queries", func() {
+		req := withToken(httptest.NewRequest(http.MethodGet, "/api/v1/query?query=up", nil),
+			"team-a", "tenant:a", "tenant:b")
+
+		ACLProxy(res, req, &PromxyQueryHandler{config: config})
+
+		Expect(res.Writer.(*httptest.ResponseRecorder).Code).To(Equal(http.StatusOK))
+		Expect(receivedQuery.Get("query")).To(Equal(`up{cluster=~"prod-.*",tenant="a"}`))
+	})
+
+	It("should forbid metrics queries of tenants with different grants", func() {
+		policy, err := authz.ParsePolicy([]byte(`
+rules:
+- groups: [team-a]
+  tenants: [a]
+  clusters: ["prod-*"]
+- groups: [team-a]
+  tenants: [b]
+`))
+		Expect(err).NotTo(HaveOccurred())
+		config.Authorizer = policy
+		req := withToken(httptest.NewRequest(http.MethodGet, "/api/v1/query?query=up", nil),
+			"team-a", "tenant:a", "tenant:b")
+
+		ACLProxy(res, req, &PromxyQueryHandler{config: config})
+
+		recorder := res.Writer.(*httptest.ResponseRecorder)
+		Expect(recorder.Code).To(Equal(http.StatusForbidden))
+		Expect(recorder.Body.String()).To(ContainSubstring(TenantHeaderName))
+		Expect(receivedQuery).To(BeNil())
+	})
+
+	It("should add the filter of every grant to logs queries", func() {
+		req := withToken(httptest.NewRequest(http.MethodGet, "/logs/select/logsql/query?query=error", nil),
+			"team-a", "tenant:a", "tenant:b")
+
+		ACLProxy(res, req, NewLogsHandler(config))
+
+		Expect(res.Writer.(*httptest.ResponseRecorder).Code).To(Equal(http.StatusOK))
+		Expect(receivedQuery.Get("extra_filters")).To(Equal(
+			`((tenant:="a" k8s.cluster.name:~"^(?:prod-.*)$") or (tenant:="b" k8s.namespace.name:~"^(?:web)$"))`))
+	})
+
+	It("should forbid the signals no rule grants", func() {
+		req := withToken(httptest.NewRequest(http.MethodGet, "/api/v1/query?query=up", nil),
+			"team-a", "tenant:b")
+
+		ACLProxy(res, req, &PromxyQueryHandler{config: config})
+
+		Expect(res.Writer.(*httptest.ResponseRecorder).Code).To(Equal(http.StatusForbidden))
+		Expect(receivedQuery).To(BeNil())
+	})
+
+	It("should filter the alerts outside of the granted clusters", func() {
+		alerts := []*v1.Alert{
+			{Labels: model.LabelSet{"tenant": "a", "cluster": "prod-eu"}},
+			{Labels: model.LabelSet{"tenant": "a", "cluster": "dev-eu"}},
+			{Labels: model.LabelSet{"tenant": "c", "cluster": "prod-eu"}},
+		}
+		scope := &authz.Scope{Grants: []authz.Grant{{Tenants: []string{"a"}, Clusters: []string{"prod-*"}}}}
+
+		Expect(filterAlertsByScope(alerts, scope)).To(Equal(alerts[:1]))
+	})
+})
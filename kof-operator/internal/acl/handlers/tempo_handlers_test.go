@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	"github.com/k0rdent/kof/kof-operator/internal/server"
+	"github.com/k0rdent/kof/kof-operator/internal/server/helper"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("TempoSearchHandler and TempoTraceHandler", func() {
+	var (
+		mockBackend *httptest.Server
+		queries     []string
+		rows        func(query string) []map[string]string
+		logger      = ctrl.Log.WithName("test")
+	)
+
+	BeforeEach(func() {
+		queries = nil
+		rows = func(string) []map[string]string { return nil }
+		mockBackend = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.URL.Path).To(Equal("/select/logsql/query"))
+			Expect(r.URL.Query().Get("extra_filters")).To(Equal(`"resource_attr:tenant":="test-tenant"`))
+			query := r.URL.Query().Get("query")
+			queries = append(queries, query)
+			enc := json.NewEncoder(w)
+			for _, row := range rows(query) {
+				Expect(enc.Encode(row)).To(Succeed())
+			}
+		}))
+	})
+
+	AfterEach(func() {
+		mockBackend.Close()
+	})
+
+	serve := func(proxy func(Config) Proxy, target string) *httptest.ResponseRecorder {
+		parsedURL, err := url.Parse(mockBackend.URL)
+		Expect(err).NotTo(HaveOccurred())
+
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		idToken := MockIDToken(map[string]any{
+			"email":  "user@example.com",
+			"groups": []any{"tenant:test-tenant"},
+		})
+		req = req.WithContext(context.WithValue(req.Context(), helper.IdTokenContextKey, idToken))
+		recorder := httptest.NewRecorder()
+		ACLProxy(&server.Response{Writer: recorder, Logger: &logger}, req, proxy(Config{Host: parsedURL.Host, Scheme: "http"}))
+		return recorder
+	}
+
+	It("searches the traces of the tenant matching the TraceQL query", func() {
+		rows = func(query string) []map[string]string {
+			switch {
+			case strings.Contains(query, "| stats by (trace_id)"):
+				return []map[string]string{{"trace_id": "t1", "matched": "2", "start_time": "1000"}}
+			case strings.Contains(query, `parent_span_id:=""`):
+				return []map[string]string{{
+					"trace_id": "t1", "name": "GET /", "resource_attr:service.name": "frontend",
+					"start_time_unix_nano": "900", "duration": "5000000",
+				}}
+			default:
+				return []map[string]string{
+					{"trace_id": "t1", "span_id": "s1", "name": "db", "start_time_unix_nano": "1000", "duration": "10"},
+					{"trace_id": "t1", "span_id": "s2", "name": "db", "start_time_unix_nano": "1100", "duration": "20"},
+				}
+			}
+		}
+
+		recorder := serve(NewTempoSearchHandler,
+			"/traces/api/search?"+url.Values{"q": {`{ span.db.system = "redis" && status = error }`}, "limit": {"5"}, "spss": {"1"}}.Encode())
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(queries).To(HaveLen(3))
+		Expect(queries[0]).To(Equal(`"resource_attr:tenant":="test-tenant" "span_attr:db.system":="redis" "status_code":="2"` +
+			` | stats by (trace_id) count() matched, min(start_time_unix_nano) start_time | sort by (start_time desc) | limit 5`))
+		Expect(queries[1]).To(HavePrefix(`"resource_attr:tenant":="test-tenant" trace_id:in("t1") parent_span_id:=""`))
+		Expect(queries[2]).To(HaveSuffix("| limit 5"))
+
+		var resp TempoSearchResponse
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &resp)).To(Succeed())
+		Expect(resp.Traces).To(HaveLen(1))
+		Expect(*resp.Traces[0]).To(Equal(TempoTraceMetadata{
+			TraceID:           "t1",
+			RootServiceName:   "frontend",
+			RootTraceName:     "GET /",
+			StartTimeUnixNano: "900",
+			DurationMs:        5,
+			SpanSets: []TempoSpanSet{{
+				Matched: 2,
+				Spans:   []TempoSpan{{SpanID: "s1", Name: "db", StartTimeUnixNano: "1000", DurationNanos: "10"}},
+			}},
+		}))
+	})
+
+	It("restricts the search to the time range", func() {
+		recorder := serve(NewTempoSearchHandler, "/traces/api/search?start=0&end=3600")
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(queries).To(HaveLen(1))
+		Expect(queries[0]).To(HavePrefix(`"resource_attr:tenant":="test-tenant" * _time:[1970-01-01T00:00:00Z, 1970-01-01T01:00:00Z] |`))
+		Expect(recorder.Body.String()).To(ContainSubstring(`"traces":[]`))
+	})
+
+	It("rejects unsupported TraceQL", func() {
+		recorder := serve(NewTempoSearchHandler, "/traces/api/search?"+url.Values{"q": {`{ name = "a" } >> { name = "b" }`}}.Encode())
+
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		Expect(queries).To(BeEmpty())
+	})
+
+	It("returns the spans of the tenant as OTLP JSON", func() {
+		rows = func(string) []map[string]string {
+			return []map[string]string{
+				{
+					"trace_id": "00000000000000000000000000000abc", "span_id": "0000000000000002", "parent_span_id": "0000000000000001",
+					"name": "child", "kind": "3", "status_code": "2", "status_message": "boom",
+					"start_time_unix_nano": "20", "end_time_unix_nano": "30",
+					"resource_attr:service.name": "api", "span_attr:http.method": "GET",
+				},
+				{
+					"trace_id": "00000000000000000000000000000abc", "span_id": "0000000000000001", "parent_span_id": "",
+					"name": "root", "kind": "2", "status_code": "0",
+					"start_time_unix_nano": "10", "end_time_unix_nano": "40",
+					"resource_attr:service.name": "api",
+				},
+			}
+		}
+
+		recorder := serve(NewTempoTraceHandler, "/traces/api/traces/ABC")
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(queries).To(Equal([]string{`"resource_attr:tenant":="test-tenant" trace_id:="00000000000000000000000000000abc"`}))
+
+		var resp TempoTraceResponse
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &resp)).To(Succeed())
+		Expect(resp.Batches).To(HaveLen(1))
+		Expect(resp.Batches[0].Resource.Attributes).To(Equal([]OTLPKeyValue{{Key: "service.name", Value: OTLPAnyValue{StringValue: "api"}}}))
+		Expect(resp.Batches[0].ScopeSpans).To(HaveLen(1))
+		spans := resp.Batches[0].ScopeSpans[0].Spans
+		Expect(spans).To(HaveLen(2))
+		Expect(spans[0].Name).To(Equal("root"))
+		Expect(spans[0].ParentSpanID).To(BeEmpty())
+		Expect(spans[0].Kind).To(Equal("SPAN_KIND_SERVER"))
+		Expect(*spans[1]).To(Equal(OTLPSpan{
+			TraceID:           "AAAAAAAAAAAAAAAAAAAKvA==",
+			SpanID:            "AAAAAAAAAAI=",
+			ParentSpanID:      "AAAAAAAAAAE=",
+			Name:              "child",
+			Kind:              "SPAN_KIND_CLIENT",
+			StartTimeUnixNano: "20",
+			EndTimeUnixNano:   "30",
+			Attributes:        []OTLPKeyValue{{Key: "http.method", Value: OTLPAnyValue{StringValue: "GET"}}},
+			Status:            OTLPStatus{Code: "STATUS_CODE_ERROR", Message: "boom"},
+		}))
+	})
+
+	It("returns not found when the trace has no spans of the tenant", func() {
+		recorder := serve(NewTempoTraceHandler, "/traces/api/traces/abc")
+
+		Expect(recorder.Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("rejects invalid trace IDs", func() {
+		recorder := serve(NewTempoTraceHandler, "/traces/api/traces/xyz")
+
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		Expect(queries).To(BeEmpty())
+	})
+})
@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/k0rdent/kof/kof-operator/internal/acl/authz"
+	"github.com/k0rdent/kof/kof-operator/internal/acl/tenant"
+	"github.com/k0rdent/kof/kof-operator/internal/acl/traceql"
+	"github.com/k0rdent/kof/kof-operator/internal/server"
+)
+
+const (
+	defaultTempoSearchLimit = 20
+	maxTempoSearchLimit     = 1000
+	// defaultTempoSpansPerSpanSet is the number of matched spans returned per trace.
+	defaultTempoSpansPerSpanSet = 3
+	// tempoMissingRootService is the root service name Tempo reports for traces without a root span.
+	tempoMissingRootService = "<root span not yet received>"
+)
+
+type TempoSearchResponse struct {
+	Traces  []*TempoTraceMetadata `json:"traces"`
+	Metrics TempoSearchMetrics    `json:"metrics"`
+}
+
+type TempoSearchMetrics struct {
+	InspectedTraces int `json:"inspectedTraces"`
+}
+
+type TempoTraceMetadata struct {
+	TraceID           string         `json:"traceID"`
+	RootServiceName   string         `json:"rootServiceName"`
+	RootTraceName     string         `json:"rootTraceName,omitempty"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	DurationMs        int64          `json:"durationMs,omitempty"`
+	SpanSets          []TempoSpanSet `json:"spanSets"`
+}
+
+type TempoSpanSet struct {
+	Spans   []TempoSpan `json:"spans"`
+	Matched int         `json:"matched"`
+}
+
+type TempoSpan struct {
+	SpanID            string `json:"spanID"`
+	Name              string `json:"name"`
+	StartTimeUnixNano string `json:"startTimeUnixNano"`
+	DurationNanos     string `json:"durationNanos"`
+}
+
+// TempoSearchHandler serves the Tempo search API over VictoriaTraces: the
+// TraceQL subset of the q parameter is translated to LogsQL and restricted to
+// the tenants.
+type TempoSearchHandler struct {
+	config Config
+}
+
+func NewTempoSearchHandler(config Config) Proxy {
+	return &TempoSearchHandler{config: config}
+}
+
+func (h *TempoSearchHandler) TenantResolver() tenant.Resolver { return h.config.TenantResolver }
+func (h *TempoSearchHandler) IsDevMode() bool                 { return h.config.DevMode }
+func (h *TempoSearchHandler) Schema() string                  { return h.config.Scheme }
+func (h *TempoSearchHandler) Host() string                    { return h.config.Host }
+
+func (h *TempoSearchHandler) HandleTenantInjection(res *server.Response, req *http.Request, identity *tenant.Identity) {
+	scope, ok := requestScope(res, req, identity, h.config.Authorizer, authz.SignalTraces)
+	if !ok {
+		return
+	}
+
+	params := req.URL.Query()
+	search, err := parseTempoSearch(params)
+	if err != nil {
+		res.Fail(err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tenantFilter := tracesScopeFilter(scope)
+	matches, err := queryTracesLogsQL(req.Context(), h.config, scope, fmt.Sprintf(
+		"%s %s | stats by (trace_id) count() matched, min(start_time_unix_nano) start_time"+
+			" | sort by (start_time desc) | limit %d",
+		tenantFilter, search.filter, search.limit,
+	))
+	if err != nil {
+		failTracesQuery(res, err)
+		return
+	}
+
+	result := &TempoSearchResponse{Traces: make([]*TempoTraceMetadata, 0, len(matches))}
+	if len(matches) == 0 {
+		res.SendObj(result, http.StatusOK)
+		return
+	}
+
+	traces := make(map[string]*TempoTraceMetadata, len(matches))
+	ids := make([]string, 0, len(matches))
+	for _, row := range matches {
+		matched, _ := strconv.Atoi(row["matched"])
+		trace := &TempoTraceMetadata{
+			TraceID:           row["trace_id"],
+			RootServiceName:   tempoMissingRootService,
+			StartTimeUnixNano: row["start_time"],
+			SpanSets:          []TempoSpanSet{{Spans: []TempoSpan{}, Matched: matched}},
+		}
+		traces[trace.TraceID] = trace
+		result.Traces = append(result.Traces, trace)
+		ids = append(ids, strconv.Quote(trace.TraceID))
+	}
+	idFilter := fmt.Sprintf("trace_id:in(%s)", strings.Join(ids, ","))
+
+	roots, err := queryTracesLogsQL(req.Context(), h.config, scope, fmt.Sprintf(
+		`%s %s parent_span_id:="" | fields trace_id, name, %s, start_time_unix_nano, duration`,
+		tenantFilter, idFilter, strconv.Quote(tracesServiceNameField),
+	))
+	if err != nil {
+		failTracesQuery(res, err)
+		return
+	}
+	for _, row := range roots {
+		if trace, ok := traces[row["trace_id"]]; ok {
+			trace.RootServiceName = row[tracesServiceNameField]
+			trace.RootTraceName = row["name"]
+			trace.StartTimeUnixNano = row["start_time_unix_nano"]
+			durationNanos, _ := strconv.ParseInt(row["duration"], 10, 64)
+			trace.DurationMs = durationNanos / 1e6
+		}
+	}
+
+	spans, err := queryTracesLogsQL(req.Context(), h.config, scope, fmt.Sprintf(
+		"%s %s %s | fields trace_id, span_id, name, start_time_unix_nano, duration | limit %d",
+		tenantFilter, idFilter, search.filter, search.limit*search.spansPerSpanSet,
+	))
+	if err != nil {
+		failTracesQuery(res, err)
+		return
+	}
+	for _, row := range spans {
+		trace, ok := traces[row["trace_id"]]
+		if !ok || len(trace.SpanSets[0].Spans) >= search.spansPerSpanSet {
+			continue
+		}
+		trace.SpanSets[0].Spans = append(trace.SpanSets[0].Spans, TempoSpan{
+			SpanID:            row["span_id"],
+			Name:              row["name"],
+			StartTimeUnixNano: row["start_time_unix_nano"],
+			DurationNanos:     row["duration"],
+		})
+	}
+
+	result.Metrics.InspectedTraces = len(result.Traces)
+	res.SendObj(result, http.StatusOK)
+}
+
+// tempoSearch is a parsed Tempo search request.
+type tempoSearch struct {
+	// filter is the LogsQL filter of the TraceQL query and time range.
+	filter          string
+	limit           int
+	spansPerSpanSet int
+}
+
+// parseTempoSearch parses the q, limit, spss, start and end parameters of a Tempo search.
+func parseTempoSearch(params url.Values) (*tempoSearch, error) {
+	query, err := traceql.Parse(params.Get("q"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid TraceQL query: %w", err)
+	}
+
+	search := &tempoSearch{filter: query.LogsQL()}
+	if search.limit, err = positiveParam(params, "limit", defaultTempoSearchLimit); err != nil {
+		return nil, err
+	}
+	search.limit = min(search.limit, maxTempoSearchLimit)
+	if search.spansPerSpanSet, err = positiveParam(params, "spss", defaultTempoSpansPerSpanSet); err != nil {
+		return nil, err
+	}
+
+	start, end := params.Get("start"), params.Get("end")
+	if start != "" || end != "" {
+		startSec, err := strconv.ParseInt(start, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid start: %q", start)
+		}
+		endSec, err := strconv.ParseInt(end, 10, 64)
+		if err != nil || endSec < startSec {
+			return nil, fmt.Errorf("invalid end: %q", end)
+		}
+		search.filter += fmt.Sprintf(" _time:[%s, %s]",
+			time.Unix(startSec, 0).UTC().Format(time.RFC3339), time.Unix(endSec, 0).UTC().Format(time.RFC3339))
+	}
+	return search, nil
+}
+
+func positiveParam(params url.Values, name string, defaultValue int) (int, error) {
+	raw := params.Get(name)
+	if raw == "" {
+		return defaultValue, nil
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("invalid %s: %q", name, raw)
+	}
+	return value, nil
+}
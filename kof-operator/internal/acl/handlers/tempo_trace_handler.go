@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/k0rdent/kof/kof-operator/internal/acl/authz"
+	"github.com/k0rdent/kof/kof-operator/internal/acl/tenant"
+	"github.com/k0rdent/kof/kof-operator/internal/acl/traceql"
+	"github.com/k0rdent/kof/kof-operator/internal/server"
+)
+
+// otlpSpanKinds and otlpStatusCodes map the OpenTelemetry enums stored by
+// VictoriaTraces to their OTLP JSON names.
+var (
+	otlpSpanKinds = map[string]string{
+		"0": "SPAN_KIND_UNSPECIFIED",
+		"1": "SPAN_KIND_INTERNAL",
+		"2": "SPAN_KIND_SERVER",
+		"3": "SPAN_KIND_CLIENT",
+		"4": "SPAN_KIND_PRODUCER",
+		"5": "SPAN_KIND_CONSUMER",
+	}
+	otlpStatusCodes = map[string]string{
+		"0": "STATUS_CODE_UNSET",
+		"1": "STATUS_CODE_OK",
+		"2": "STATUS_CODE_ERROR",
+	}
+)
+
+// TempoTraceResponse is a trace in the OTLP JSON format of the Tempo trace by ID API.
+type TempoTraceResponse struct {
+	Batches []*OTLPResourceSpans `json:"batches"`
+}
+
+type OTLPResourceSpans struct {
+	Resource   OTLPResource      `json:"resource"`
+	ScopeSpans []*OTLPScopeSpans `json:"scopeSpans"`
+}
+
+type OTLPResource struct {
+	Attributes []OTLPKeyValue `json:"attributes"`
+}
+
+type OTLPScopeSpans struct {
+	Scope OTLPScope   `json:"scope"`
+	Spans []*OTLPSpan `json:"spans"`
+}
+
+type OTLPScope struct {
+	Name    string `json:"name,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+type OTLPSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              string         `json:"kind,omitempty"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []OTLPKeyValue `json:"attributes,omitempty"`
+	Status            OTLPStatus     `json:"status"`
+}
+
+type OTLPStatus struct {
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type OTLPKeyValue struct {
+	Key   string       `json:"key"`
+	Value OTLPAnyValue `json:"value"`
+}
+
+type OTLPAnyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+// TempoTraceHandler serves the Tempo trace by ID API over VictoriaTraces,
+// returning only the spans of the tenants.
+type TempoTraceHandler struct {
+	config Config
+}
+
+func NewTempoTraceHandler(config Config) Proxy {
+	return &TempoTraceHandler{config: config}
+}
+
+func (h *TempoTraceHandler) TenantResolver() tenant.Resolver { return h.config.TenantResolver }
+func (h *TempoTraceHandler) IsDevMode() bool                 { return h.config.DevMode }
+func (h *TempoTraceHandler) Schema() string                  { return h.config.Scheme }
+func (h *TempoTraceHandler) Host() string                    { return h.config.Host }
+
+func (h *TempoTraceHandler) HandleTenantInjection(res *server.Response, req *http.Request, identity *tenant.Identity) {
+	scope, ok := requestScope(res, req, identity, h.config.Authorizer, authz.SignalTraces)
+	if !ok {
+		return
+	}
+
+	traceID, err := normalizeTraceID(path.Base(req.URL.Path))
+	if err != nil {
+		res.Fail(err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rows, err := queryTracesLogsQL(req.Context(), h.config, scope,
+		fmt.Sprintf("%s trace_id:=%s", tracesScopeFilter(scope), strconv.Quote(traceID)))
+	if err != nil {
+		failTracesQuery(res, err)
+		return
+	}
+	if len(rows) == 0 {
+		res.Fail("trace not found", http.StatusNotFound)
+		return
+	}
+
+	res.SendObj(TempoTraceResponse{Batches: otlpBatches(rows)}, http.StatusOK)
+}
+
+// normalizeTraceID returns the 32 hex digits trace ID VictoriaTraces stores,
+// left-padding shorter IDs with zeros as Tempo does.
+func normalizeTraceID(id string) (string, error) {
+	id = strings.ToLower(id)
+	if len(id) == 0 || len(id) > 32 {
+		return "", fmt.Errorf("invalid trace ID %q", id)
+	}
+	if _, err := hex.DecodeString(strings.Repeat("0", len(id)%2) + id); err != nil {
+		return "", fmt.Errorf("invalid trace ID %q", id)
+	}
+	return strings.Repeat("0", 32-len(id)) + id, nil
+}
+
+// otlpBatches groups the span rows of VictoriaTraces by resource and scope.
+func otlpBatches(rows []map[string]string) []*OTLPResourceSpans {
+	resources := make(map[string]*OTLPResourceSpans)
+	scopes := make(map[string]*OTLPScopeSpans)
+	var resourceKeys []string
+
+	for _, row := range rows {
+		var resourceAttrs, spanAttrs []OTLPKeyValue
+		for field, value := range row {
+			if name, ok := strings.CutPrefix(field, traceql.ResourceAttrPrefix); ok {
+				resourceAttrs = append(resourceAttrs, OTLPKeyValue{Key: name, Value: OTLPAnyValue{StringValue: value}})
+			} else if name, ok := strings.CutPrefix(field, traceql.SpanAttrPrefix); ok {
+				spanAttrs = append(spanAttrs, OTLPKeyValue{Key: name, Value: OTLPAnyValue{StringValue: value}})
+			}
+		}
+		sortKeyValues(resourceAttrs)
+		sortKeyValues(spanAttrs)
+
+		resourceKey := keyValuesKey(resourceAttrs)
+		resource, ok := resources[resourceKey]
+		if !ok {
+			resource = &OTLPResourceSpans{Resource: OTLPResource{Attributes: resourceAttrs}}
+			resources[resourceKey] = resource
+			resourceKeys = append(resourceKeys, resourceKey)
+		}
+
+		scope := OTLPScope{Name: row["scope_name"], Version: row["scope_version"]}
+		scopeKey := resourceKey + "\x01" + scope.Name + "\x00" + scope.Version
+		scopeSpans, ok := scopes[scopeKey]
+		if !ok {
+			scopeSpans = &OTLPScopeSpans{Scope: scope}
+			scopes[scopeKey] = scopeSpans
+			resource.ScopeSpans = append(resource.ScopeSpans, scopeSpans)
+		}
+
+		scopeSpans.Spans = append(scopeSpans.Spans, &OTLPSpan{
+			TraceID:           hexToBase64(row["trace_id"]),
+			SpanID:            hexToBase64(row["span_id"]),
+			ParentSpanID:      hexToBase64(row["parent_span_id"]),
+			Name:              row[traceql.FieldName],
+			Kind:              otlpSpanKinds[row[traceql.FieldKind]],
+			StartTimeUnixNano: row["start_time_unix_nano"],
+			EndTimeUnixNano:   row["end_time_unix_nano"],
+			Attributes:        spanAttrs,
+			Status: OTLPStatus{
+				Code:    otlpStatusCodes[row[traceql.FieldStatusCode]],
+				Message: row["status_message"],
+			},
+		})
+	}
+
+	slices.Sort(resourceKeys)
+	batches := make([]*OTLPResourceSpans, 0, len(resourceKeys))
+	for _, key := range resourceKeys {
+		for _, scopeSpans := range resources[key].ScopeSpans {
+			slices.SortFunc(scopeSpans.Spans, func(a, b *OTLPSpan) int {
+				return cmp.Or(
+					cmp.Compare(len(a.StartTimeUnixNano), len(b.StartTimeUnixNano)),
+					cmp.Compare(a.StartTimeUnixNano, b.StartTimeUnixNano),
+					cmp.Compare(a.SpanID, b.SpanID),
+				)
+			})
+		}
+		batches = append(batches, resources[key])
+	}
+	return batches
+}
+
+func sortKeyValues(kvs []OTLPKeyValue) {
+	slices.SortFunc(kvs, func(a, b OTLPKeyValue) int { return cmp.Compare(a.Key, b.Key) })
+}
+
+func keyValuesKey(kvs []OTLPKeyValue) string {
+	var b strings.Builder
+	for _, kv := range kvs {
+		b.WriteString(kv.Key)
+		b.WriteByte(0)
+		b.WriteString(kv.Value.StringValue)
+		b.WriteByte(0)
+	}
+	return b.String()
+}
+
+// hexToBase64 converts a hex ID of VictoriaTraces to the base64 encoding of OTLP JSON.
+func hexToBase64(id string) string {
+	if id == "" {
+		return ""
+	}
+	raw, err := hex.DecodeString(id)
+	if err != nil {
+		return id
+	}
+	return base64.StdEncoding.EncodeToString(raw)
+}
@@ -0,0 +1,199 @@
+// Package ingest stamps the tenant of the writer on the metrics, logs and
+// traces pushed through the ACL server: the tenant label of remote-write
+// series and the tenant resource attribute of OTLP payloads are set from the
+// token, overwriting any value sent by the client.
+package ingest
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/prompb"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// TenantKey is the label of the series and the resource attribute stamped with the tenant.
+const TenantKey = "tenant"
+
+// MaxRemoteWriteSize is the maximum decompressed size of a remote-write
+// request, the limit of the other ingest request bodies.
+const MaxRemoteWriteSize = 10 << 20
+
+// ErrTooLarge is returned for requests above their maximum decompressed size.
+var ErrTooLarge = errors.New("request too large")
+
+// Signal is the kind of telemetry of an OTLP payload.
+type Signal string
+
+const (
+	SignalMetrics Signal = "metrics"
+	SignalLogs    Signal = "logs"
+	SignalTraces  Signal = "traces"
+)
+
+// Encoding is the encoding of an OTLP payload.
+type Encoding int
+
+const (
+	EncodingProtobuf Encoding = iota
+	EncodingJSON
+)
+
+// StampRemoteWrite sets the tenant label of every series of a snappy
+// compressed Prometheus remote-write (v1) request, and returns the request
+// compressed again. Requests decompressing to more than MaxRemoteWriteSize
+// bytes are rejected with ErrTooLarge before they are decompressed.
+func StampRemoteWrite(body []byte, tenantID string) ([]byte, error) {
+	size, err := snappy.DecodedLen(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress remote-write request: %w", err)
+	}
+	if size > MaxRemoteWriteSize {
+		return nil, fmt.Errorf("%w: remote-write request decompresses to %d bytes, above %d",
+			ErrTooLarge, size, MaxRemoteWriteSize)
+	}
+
+	data, err := snappy.Decode(nil, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress remote-write request: %w", err)
+	}
+
+	var req prompb.WriteRequest
+	if err := req.Unmarshal(data); err != nil {
+		return nil, fmt.Errorf("failed to decode remote-write request: %w", err)
+	}
+
+	for i := range req.Timeseries {
+		req.Timeseries[i].Labels = stampLabels(req.Timeseries[i].Labels, tenantID)
+	}
+
+	if data, err = req.Marshal(); err != nil {
+		return nil, fmt.Errorf("failed to encode remote-write request: %w", err)
+	}
+	return snappy.Encode(nil, data), nil
+}
+
+// stampLabels replaces the tenant labels with tenantID, keeping the labels sorted.
+func stampLabels(labels []prompb.Label, tenantID string) []prompb.Label {
+	labels = slices.DeleteFunc(labels, func(l prompb.Label) bool { return l.Name == TenantKey })
+	i, _ := slices.BinarySearchFunc(labels, TenantKey, func(l prompb.Label, name string) int {
+		return strings.Compare(l.Name, name)
+	})
+	return slices.Insert(labels, i, prompb.Label{Name: TenantKey, Value: tenantID})
+}
+
+// StampOTLP sets the tenant resource attribute of every resource of an OTLP
+// export request, and returns the request encoded as protobuf. The tenant
+// attribute of scopes, data points, log records and spans is removed, as the
+// backends may store it along the resource attributes.
+func StampOTLP(signal Signal, encoding Encoding, body []byte, tenantID string) ([]byte, error) {
+	switch signal {
+	case SignalMetrics:
+		return stampMetrics(encoding, body, tenantID)
+	case SignalLogs:
+		return stampLogs(encoding, body, tenantID)
+	case SignalTraces:
+		return stampTraces(encoding, body, tenantID)
+	default:
+		return nil, fmt.Errorf("unsupported signal %q", signal)
+	}
+}
+
+func stampMetrics(encoding Encoding, body []byte, tenantID string) ([]byte, error) {
+	var unmarshaler pmetric.Unmarshaler = &pmetric.ProtoUnmarshaler{}
+	if encoding == EncodingJSON {
+		unmarshaler = &pmetric.JSONUnmarshaler{}
+	}
+	metrics, err := unmarshaler.UnmarshalMetrics(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode OTLP metrics: %w", err)
+	}
+	for _, rm := range metrics.ResourceMetrics().All() {
+		stampResource(rm.Resource(), tenantID)
+		for _, sm := range rm.ScopeMetrics().All() {
+			sm.Scope().Attributes().Remove(TenantKey)
+			for _, m := range sm.Metrics().All() {
+				removeDataPointsTenant(m)
+			}
+		}
+	}
+	return new(pmetric.ProtoMarshaler).MarshalMetrics(metrics)
+}
+
+func stampLogs(encoding Encoding, body []byte, tenantID string) ([]byte, error) {
+	var unmarshaler plog.Unmarshaler = &plog.ProtoUnmarshaler{}
+	if encoding == EncodingJSON {
+		unmarshaler = &plog.JSONUnmarshaler{}
+	}
+	logs, err := unmarshaler.UnmarshalLogs(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode OTLP logs: %w", err)
+	}
+	for _, rl := range logs.ResourceLogs().All() {
+		stampResource(rl.Resource(), tenantID)
+		for _, sl := range rl.ScopeLogs().All() {
+			sl.Scope().Attributes().Remove(TenantKey)
+			for _, record := range sl.LogRecords().All() {
+				record.Attributes().Remove(TenantKey)
+			}
+		}
+	}
+	return new(plog.ProtoMarshaler).MarshalLogs(logs)
+}
+
+func stampTraces(encoding Encoding, body []byte, tenantID string) ([]byte, error) {
+	var unmarshaler ptrace.Unmarshaler = &ptrace.ProtoUnmarshaler{}
+	if encoding == EncodingJSON {
+		unmarshaler = &ptrace.JSONUnmarshaler{}
+	}
+	traces, err := unmarshaler.UnmarshalTraces(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode OTLP traces: %w", err)
+	}
+	for _, rs := range traces.ResourceSpans().All() {
+		stampResource(rs.Resource(), tenantID)
+		for _, ss := range rs.ScopeSpans().All() {
+			ss.Scope().Attributes().Remove(TenantKey)
+			for _, span := range ss.Spans().All() {
+				span.Attributes().Remove(TenantKey)
+			}
+		}
+	}
+	return new(ptrace.ProtoMarshaler).MarshalTraces(traces)
+}
+
+func stampResource(resource pcommon.Resource, tenantID string) {
+	resource.Attributes().PutStr(TenantKey, tenantID)
+}
+
+// removeDataPointsTenant removes the tenant attribute of the data points of a metric.
+func removeDataPointsTenant(m pmetric.Metric) {
+	switch m.Type() {
+	case pmetric.MetricTypeGauge:
+		for _, dp := range m.Gauge().DataPoints().All() {
+			dp.Attributes().Remove(TenantKey)
+		}
+	case pmetric.MetricTypeSum:
+		for _, dp := range m.Sum().DataPoints().All() {
+			dp.Attributes().Remove(TenantKey)
+		}
+	case pmetric.MetricTypeHistogram:
+		for _, dp := range m.Histogram().DataPoints().All() {
+			dp.Attributes().Remove(TenantKey)
+		}
+	case pmetric.MetricTypeExponentialHistogram:
+		for _, dp := range m.ExponentialHistogram().DataPoints().All() {
+			dp.Attributes().Remove(TenantKey)
+		}
+	case pmetric.MetricTypeSummary:
+		for _, dp := range m.Summary().DataPoints().All() {
+			dp.Attributes().Remove(TenantKey)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package ingest
+
+import (
+	"encoding/binary"
+
+	"github.com/golang/snappy"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/prometheus/prompb"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+var _ = Describe("StampRemoteWrite", func() {
+	encode := func(req *prompb.WriteRequest) []byte {
+		data, err := req.Marshal()
+		Expect(err).NotTo(HaveOccurred())
+		return snappy.Encode(nil, data)
+	}
+
+	decode := func(body []byte) *prompb.WriteRequest {
+		data, err := snappy.Decode(nil, body)
+		Expect(err).NotTo(HaveOccurred())
+		req := &prompb.WriteRequest{}
+		Expect(req.Unmarshal(data)).To(Succeed())
+		return req
+	}
+
+	It("overwrites the tenant label of every series and keeps labels sorted", func() {
+		body := encode(&prompb.WriteRequest{Timeseries: []prompb.TimeSeries{
+			{
+				Labels:  []prompb.Label{{Name: "__name__", Value: "up"}, {Name: "tenant", Value: "spoofed"}, {Name: "zone", Value: "a"}},
+				Samples: []prompb.Sample{{Value: 1, Timestamp: 1000}},
+			},
+			{
+				Labels:  []prompb.Label{{Name: "__name__", Value: "up"}, {Name: "job", Value: "x"}},
+				Samples: []prompb.Sample{{Value: 0, Timestamp: 1000}},
+			},
+		}})
+
+		stamped, err := StampRemoteWrite(body, "acme")
+		Expect(err).NotTo(HaveOccurred())
+
+		req := decode(stamped)
+		Expect(req.Timeseries[0].Labels).To(Equal([]prompb.Label{
+			{Name: "__name__", Value: "up"}, {Name: "tenant", Value: "acme"}, {Name: "zone", Value: "a"},
+		}))
+		Expect(req.Timeseries[1].Labels).To(Equal([]prompb.Label{
+			{Name: "__name__", Value: "up"}, {Name: "job", Value: "x"}, {Name: "tenant", Value: "acme"},
+		}))
+		Expect(req.Timeseries[0].Samples).To(Equal([]prompb.Sample{{Value: 1, Timestamp: 1000}}))
+	})
+
+	It("rejects payloads that are not snappy-compressed protobuf", func() {
+		_, err := StampRemoteWrite([]byte("not snappy"), "acme")
+		Expect(err).To(HaveOccurred())
+		_, err = StampRemoteWrite(snappy.Encode(nil, []byte{0xff, 0xff}), "acme")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("rejects payloads decompressing above the maximum size without decompressing them", func() {
+		// A snappy block starts with its decoded length as a uvarint:
+		// this header claims 1 GiB followed by no data.
+		header := binary.AppendUvarint(nil, 1<<30)
+		_, err := StampRemoteWrite(header, "acme")
+		Expect(err).To(MatchError(ErrTooLarge))
+	})
+})
+
+var _ = Describe("StampOTLP", func() {
+	It("sets the tenant resource attribute of metrics and removes spoofed data point attributes", func() {
+		metrics := pmetric.NewMetrics()
+		rm := metrics.ResourceMetrics().AppendEmpty()
+		rm.Resource().Attributes().PutStr("tenant", "spoofed")
+		gauge := rm.ScopeMetrics().AppendEmpty().Metrics().AppendEmpty().SetEmptyGauge()
+		gauge.DataPoints().AppendEmpty().Attributes().PutStr("tenant", "spoofed")
+		body, err := new(pmetric.JSONMarshaler).MarshalMetrics(metrics)
+		Expect(err).NotTo(HaveOccurred())
+
+		stamped, err := StampOTLP(SignalMetrics, EncodingJSON, body, "acme")
+		Expect(err).NotTo(HaveOccurred())
+
+		metrics, err = new(pmetric.ProtoUnmarshaler).UnmarshalMetrics(stamped)
+		Expect(err).NotTo(HaveOccurred())
+		rm = metrics.ResourceMetrics().At(0)
+		Expect(rm.Resource().Attributes().AsRaw()).To(Equal(map[string]any{"tenant": "acme"}))
+		dp := rm.ScopeMetrics().At(0).Metrics().At(0).Gauge().DataPoints().At(0)
+		Expect(dp.Attributes().Len()).To(BeZero())
+	})
+
+	It("sets the tenant resource attribute of logs", func() {
+		logs := plog.NewLogs()
+		rl := logs.ResourceLogs().AppendEmpty()
+		rl.Resource().Attributes().PutStr("service.name", "api")
+		record := rl.ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+		record.Attributes().PutStr("tenant", "spoofed")
+		record.Body().SetStr("hello")
+		body, err := new(plog.ProtoMarshaler).MarshalLogs(logs)
+		Expect(err).NotTo(HaveOccurred())
+
+		stamped, err := StampOTLP(SignalLogs, EncodingProtobuf, body, "acme")
+		Expect(err).NotTo(HaveOccurred())
+
+		logs, err = new(plog.ProtoUnmarshaler).UnmarshalLogs(stamped)
+		Expect(err).NotTo(HaveOccurred())
+		rl = logs.ResourceLogs().At(0)
+		Expect(rl.Resource().Attributes().AsRaw()).To(Equal(map[string]any{"service.name": "api", "tenant": "acme"}))
+		record = rl.ScopeLogs().At(0).LogRecords().At(0)
+		Expect(record.Attributes().Len()).To(BeZero())
+		Expect(record.Body().Str()).To(Equal("hello"))
+	})
+
+	It("sets the tenant resource attribute of traces", func() {
+		traces := ptrace.NewTraces()
+		traces.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty().SetName("GET /")
+		body, err := new(ptrace.ProtoMarshaler).MarshalTraces(traces)
+		Expect(err).NotTo(HaveOccurred())
+
+		stamped, err := StampOTLP(SignalTraces, EncodingProtobuf, body, "acme")
+		Expect(err).NotTo(HaveOccurred())
+
+		traces, err = new(ptrace.ProtoUnmarshaler).UnmarshalTraces(stamped)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(traces.ResourceSpans().At(0).Resource().Attributes().AsRaw()).To(Equal(map[string]any{"tenant": "acme"}))
+		Expect(traces.SpanCount()).To(Equal(1))
+	})
+
+	It("rejects malformed payloads", func() {
+		_, err := StampOTLP(SignalLogs, EncodingJSON, []byte("{"), "acme")
+		Expect(err).To(HaveOccurred())
+	})
+})
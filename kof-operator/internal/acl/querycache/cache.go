@@ -0,0 +1,265 @@
+// Package querycache caches the results of tenant-scoped Prometheus
+// query_range requests in the ACL proxy. Results are stored per query as
+// step-aligned extents, so that a dashboard refresh only fetches the part of
+// its range that is not cached yet.
+package querycache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+const metricsNamespace = "kof_acl"
+
+// Results of a cached request, exported as the "result" label of the request metric.
+const (
+	// ResultHit requests were served from the cache only.
+	ResultHit = "hit"
+	// ResultPartial requests fetched part of their range.
+	ResultPartial = "partial"
+	// ResultMiss requests fetched their whole range.
+	ResultMiss = "miss"
+	// ResultBypass requests were fetched uncached, as their results depend
+	// on the exact requested range.
+	ResultBypass = "bypass"
+)
+
+var promqlParser = parser.NewParser(parser.Options{})
+
+// Backend stores the cache entries. It is implemented in memory by
+// MemoryBackend; external stores implement it to share the cache between
+// replicas of the ACL server.
+type Backend interface {
+	// Get returns the value stored for key, or false when there is none.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores the value for key, expiring after ttl.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// Options configure a Cache.
+type Options struct {
+	// TTL is the time cached extents are served for.
+	TTL time.Duration
+	// MaxFreshness is the age under which results are not cached, as recent
+	// samples may still be ingested.
+	MaxFreshness time.Duration
+}
+
+// Request is a tenant-scoped query_range request.
+type Request struct {
+	// Tenants are the tenants whose data the query reads.
+	Tenants []string
+	// Query is the PromQL query with the tenant matchers injected.
+	Query string
+	Start time.Time
+	End   time.Time
+	Step  time.Duration
+}
+
+// FetchFunc fetches the result of the query of a request over [start, end].
+type FetchFunc func(ctx context.Context, start, end time.Time) (*Response, error)
+
+// Cache is a results cache of query_range requests. It is safe for concurrent use.
+type Cache struct {
+	backend Backend
+	opts    Options
+	now     func() time.Time
+
+	requests      *prometheus.CounterVec
+	fetches       prometheus.Counter
+	backendErrors *prometheus.CounterVec
+}
+
+// New creates a Cache storing its entries in backend and registers its metrics with reg.
+func New(backend Backend, opts Options, reg prometheus.Registerer) *Cache {
+	c := &Cache{
+		backend: backend,
+		opts:    opts,
+		now:     time.Now,
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "query_cache_requests_total",
+			Help:      "Number of query_range requests served through the results cache, by result (hit, partial, miss or bypass).",
+		}, []string{"result"}),
+		fetches: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "query_cache_fetched_extents_total",
+			Help:      "Number of uncached extents fetched from the query backend.",
+		}),
+		backendErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "query_cache_backend_errors_total",
+			Help:      "Number of failed results cache backend operations, by operation.",
+		}, []string{"operation"}),
+	}
+	reg.MustRegister(c.requests, c.fetches, c.backendErrors)
+	return c
+}
+
+// QueryRange serves a query_range request from the cache, fetching the
+// uncached parts of its step-aligned range. Responses with warnings or infos
+// are returned without being cached. Queries that are not cacheable are
+// fetched over their requested range as is.
+func (c *Cache) QueryRange(ctx context.Context, req Request, fetch FetchFunc) (*Response, error) {
+	if !cacheable(req.Query) {
+		c.requests.WithLabelValues(ResultBypass).Inc()
+		return fetch(ctx, req.Start, req.End)
+	}
+
+	step := req.Step.Milliseconds()
+	if step <= 0 {
+		step = 1
+	}
+	start := alignDown(req.Start.UnixMilli(), step)
+	end := alignDown(req.End.UnixMilli(), step)
+	now := c.now()
+	key := cacheKey(req)
+
+	cached := c.load(ctx, key, now)
+	missing := gaps(cached, start, end, step)
+
+	fetched := make([]Extent, 0, len(missing))
+	var warnings, infos []string
+	for _, gap := range missing {
+		resp, err := fetch(ctx, time.UnixMilli(gap[0]).UTC(), time.UnixMilli(gap[1]).UTC())
+		if err != nil {
+			return nil, err
+		}
+		c.fetches.Inc()
+		if resp.Status != "success" {
+			return resp, nil
+		}
+		warnings = append(warnings, resp.Warnings...)
+		infos = append(infos, resp.Infos...)
+		fetched = append(fetched, Extent{Start: gap[0], End: gap[1], FetchedAt: now, Series: resp.Data.Result})
+	}
+
+	switch {
+	case len(missing) == 0:
+		c.requests.WithLabelValues(ResultHit).Inc()
+	case len(cached) == 0 || covers(missing, start, end):
+		c.requests.WithLabelValues(ResultMiss).Inc()
+	default:
+		c.requests.WithLabelValues(ResultPartial).Inc()
+	}
+
+	extents := mergeExtents(slices.Concat(cached, fetched), step)
+	if len(fetched) > 0 && len(warnings) == 0 && len(infos) == 0 {
+		c.store(ctx, key, extents, alignDown(now.Add(-c.opts.MaxFreshness).UnixMilli(), step))
+	}
+
+	result := extract(extents, start, end)
+	if result == nil {
+		result = []Series{}
+	}
+	return &Response{
+		Status:   "success",
+		Data:     Data{ResultType: "matrix", Result: result},
+		Warnings: warnings,
+		Infos:    infos,
+	}, nil
+}
+
+// cacheable reports whether the results of query can be cached as extents.
+// A query with an @ start() or @ end() modifier, on a selector or a subquery,
+// is not: every sample is evaluated at the start or end of the requested
+// range, so the results of different ranges cannot be merged. A query that
+// does not parse is not cached either, and gets its error from the backend.
+func cacheable(query string) bool {
+	expr, err := promqlParser.ParseExpr(query)
+	if err != nil {
+		return false
+	}
+	cacheable := true
+	parser.Inspect(expr, func(node parser.Node, _ []parser.Node) error {
+		switch n := node.(type) {
+		case *parser.VectorSelector:
+			cacheable = cacheable && n.StartOrEnd == 0
+		case *parser.SubqueryExpr:
+			cacheable = cacheable && n.StartOrEnd == 0
+		}
+		return nil
+	})
+	return cacheable
+}
+
+// load returns the unexpired cached extents of key.
+func (c *Cache) load(ctx context.Context, key string, now time.Time) []Extent {
+	data, ok, err := c.backend.Get(ctx, key)
+	if err != nil {
+		c.backendErrors.WithLabelValues("get").Inc()
+		return nil
+	}
+	if !ok {
+		return nil
+	}
+
+	var e entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		c.backendErrors.WithLabelValues("decode").Inc()
+		return nil
+	}
+	return slices.DeleteFunc(e.Extents, func(ext Extent) bool {
+		return now.Sub(ext.FetchedAt) >= c.opts.TTL
+	})
+}
+
+// store stores the extents of key, trimmed to the cacheable range ending at cacheEnd.
+func (c *Cache) store(ctx context.Context, key string, extents []Extent, cacheEnd int64) {
+	var e entry
+	for _, ext := range extents {
+		if ext.Start > cacheEnd {
+			continue
+		}
+		if ext.End > cacheEnd {
+			ext.End = cacheEnd
+			ext.Series = trimSeries(ext.Series, ext.Start, cacheEnd)
+		}
+		e.Extents = append(e.Extents, ext)
+	}
+	if len(e.Extents) == 0 {
+		return
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		c.backendErrors.WithLabelValues("encode").Inc()
+		return
+	}
+	if err := c.backend.Set(ctx, key, data, c.opts.TTL); err != nil {
+		c.backendErrors.WithLabelValues("set").Inc()
+	}
+}
+
+// covers reports whether the ranges cover [start, end] entirely.
+func covers(ranges [][2]int64, start, end int64) bool {
+	return len(ranges) == 1 && ranges[0][0] == start && ranges[0][1] == end
+}
+
+func alignDown(t, step int64) int64 {
+	return t - ((t%step)+step)%step
+}
+
+// cacheKey returns the backend key of the query of a request: the step, the
+// tenants and the tenant-injected query.
+func cacheKey(req Request) string {
+	tenants := slices.Clone(req.Tenants)
+	slices.Sort(tenants)
+
+	h := sha256.New()
+	h.Write([]byte(strconv.FormatInt(req.Step.Milliseconds(), 10)))
+	h.Write([]byte{0})
+	h.Write([]byte(strings.Join(tenants, "\x00")))
+	h.Write([]byte{0})
+	h.Write([]byte(req.Query))
+	return "query_range:" + hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,247 @@
+package querycache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// fakeBackend fetches a series with the value of each sample set to its timestamp.
+type fakeBackend struct {
+	fetched  [][2]time.Time
+	warnings []string
+	step     time.Duration
+}
+
+func (f *fakeBackend) fetch(_ context.Context, start, end time.Time) (*Response, error) {
+	f.fetched = append(f.fetched, [2]time.Time{start, end})
+	var values []Sample
+	for t := start; !t.After(end); t = t.Add(f.step) {
+		values = append(values, Sample{T: t.UnixMilli(), V: json.RawMessage(fmt.Sprintf(`"%d"`, t.Unix()))})
+	}
+	return &Response{
+		Status:   "success",
+		Data:     Data{ResultType: "matrix", Result: []Series{{Metric: map[string]string{"__name__": "up"}, Values: values}}},
+		Warnings: f.warnings,
+	}, nil
+}
+
+func timestamps(resp *Response) []int64 {
+	var ts []int64
+	for _, s := range resp.Data.Result {
+		for _, v := range s.Values {
+			ts = append(ts, v.T/1000)
+		}
+	}
+	return ts
+}
+
+var _ = Describe("Cache", func() {
+	var (
+		ctx     = context.Background()
+		reg     *prometheus.Registry
+		cache   *Cache
+		backend *fakeBackend
+		now     time.Time
+	)
+
+	BeforeEach(func() {
+		reg = prometheus.NewRegistry()
+		cache = New(NewMemoryBackend(1<<20, reg), Options{TTL: time.Hour, MaxFreshness: time.Minute}, reg)
+		now = time.Unix(10000, 0)
+		cache.now = func() time.Time { return now }
+		backend = &fakeBackend{step: 60 * time.Second}
+	})
+
+	request := func(start, end int64) Request {
+		return Request{
+			Tenants: []string{"acme"},
+			Query:   `up{tenant="acme"}`,
+			Start:   time.Unix(start, 0),
+			End:     time.Unix(end, 0),
+			Step:    60 * time.Second,
+		}
+	}
+
+	It("should align the range to the step and serve repeated requests from the cache", func() {
+		resp, err := cache.QueryRange(ctx, request(130, 400), backend.fetch)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(timestamps(resp)).To(Equal([]int64{120, 180, 240, 300, 360}))
+		Expect(backend.fetched).To(HaveLen(1))
+
+		resp, err = cache.QueryRange(ctx, request(120, 360), backend.fetch)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(timestamps(resp)).To(Equal([]int64{120, 180, 240, 300, 360}))
+		Expect(backend.fetched).To(HaveLen(1))
+
+		Expect(testutil.ToFloat64(cache.requests.WithLabelValues(ResultMiss))).To(Equal(1.0))
+		Expect(testutil.ToFloat64(cache.requests.WithLabelValues(ResultHit))).To(Equal(1.0))
+	})
+
+	It("should only fetch the uncached parts of the range", func() {
+		_, err := cache.QueryRange(ctx, request(600, 1200), backend.fetch)
+		Expect(err).NotTo(HaveOccurred())
+
+		resp, err := cache.QueryRange(ctx, request(300, 1500), backend.fetch)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(backend.fetched).To(Equal([][2]time.Time{
+			{time.Unix(600, 0).UTC(), time.Unix(1200, 0).UTC()},
+			{time.Unix(300, 0).UTC(), time.Unix(540, 0).UTC()},
+			{time.Unix(1260, 0).UTC(), time.Unix(1500, 0).UTC()},
+		}))
+		Expect(timestamps(resp)).To(HaveLen(21))
+		Expect(timestamps(resp)[0]).To(Equal(int64(300)))
+		Expect(testutil.ToFloat64(cache.requests.WithLabelValues(ResultPartial))).To(Equal(1.0))
+
+		_, err = cache.QueryRange(ctx, request(300, 1500), backend.fetch)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(backend.fetched).To(HaveLen(3))
+	})
+
+	It("should not cache results fresher than the maximum freshness", func() {
+		_, err := cache.QueryRange(ctx, request(9600, 10000), backend.fetch)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = cache.QueryRange(ctx, request(9600, 10000), backend.fetch)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(backend.fetched[1]).To(Equal([2]time.Time{time.Unix(9960, 0).UTC(), time.Unix(9960, 0).UTC()}))
+	})
+
+	It("should expire cached extents after the TTL", func() {
+		_, err := cache.QueryRange(ctx, request(0, 600), backend.fetch)
+		Expect(err).NotTo(HaveOccurred())
+
+		now = now.Add(time.Hour)
+		_, err = cache.QueryRange(ctx, request(0, 600), backend.fetch)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(backend.fetched).To(HaveLen(2))
+	})
+
+	It("should separate the entries of tenants and steps", func() {
+		_, err := cache.QueryRange(ctx, request(0, 600), backend.fetch)
+		Expect(err).NotTo(HaveOccurred())
+
+		other := request(0, 600)
+		other.Tenants = []string{"other"}
+		_, err = cache.QueryRange(ctx, other, backend.fetch)
+		Expect(err).NotTo(HaveOccurred())
+
+		coarse := request(0, 600)
+		coarse.Step = 120 * time.Second
+		_, err = cache.QueryRange(ctx, coarse, backend.fetch)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(backend.fetched).To(HaveLen(3))
+	})
+
+	It("should not cache responses with warnings", func() {
+		backend.warnings = []string{"partial response"}
+		resp, err := cache.QueryRange(ctx, request(0, 600), backend.fetch)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.Warnings).To(ConsistOf("partial response"))
+
+		_, err = cache.QueryRange(ctx, request(0, 600), backend.fetch)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(backend.fetched).To(HaveLen(2))
+	})
+
+	It("should bypass the cache for queries evaluated at the start or end of the range", func() {
+		for _, query := range []string{
+			`up{tenant="acme"} @ start()`,
+			`rate(up{tenant="acme"}[5m] @ end())`,
+			`max_over_time(rate(up{tenant="acme"}[1m])[10m:1m] @ start())`,
+		} {
+			req := request(130, 400)
+			req.Query = query
+			for range 2 {
+				_, err := cache.QueryRange(ctx, req, backend.fetch)
+				Expect(err).NotTo(HaveOccurred())
+			}
+		}
+
+		Expect(backend.fetched).To(HaveLen(6))
+		Expect(backend.fetched[0]).To(Equal([2]time.Time{time.Unix(130, 0), time.Unix(400, 0)}))
+		Expect(testutil.ToFloat64(cache.requests.WithLabelValues(ResultBypass))).To(Equal(6.0))
+
+		req := request(130, 400)
+		req.Query = `up{tenant="acme"} @ 100`
+		for range 2 {
+			_, err := cache.QueryRange(ctx, req, backend.fetch)
+			Expect(err).NotTo(HaveOccurred())
+		}
+		Expect(backend.fetched).To(HaveLen(7))
+	})
+
+	It("should return fetch errors", func() {
+		_, err := cache.QueryRange(ctx, request(0, 600), func(context.Context, time.Time, time.Time) (*Response, error) {
+			return nil, fmt.Errorf("promxy is down")
+		})
+		Expect(err).To(MatchError("promxy is down"))
+	})
+})
+
+var _ = Describe("Sample", func() {
+	It("should round-trip Prometheus sample JSON", func() {
+		var s Sample
+		Expect(json.Unmarshal([]byte(`[1700000000.123,"4.5"]`), &s)).To(Succeed())
+		Expect(s.T).To(Equal(int64(1700000000123)))
+
+		data, err := json.Marshal(s)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(data).To(MatchJSON(`[1700000000.123,"4.5"]`))
+	})
+})
+
+var _ = Describe("MemoryBackend", func() {
+	var (
+		ctx     = context.Background()
+		backend *MemoryBackend
+		now     time.Time
+	)
+
+	BeforeEach(func() {
+		backend = NewMemoryBackend(10, prometheus.NewRegistry())
+		now = time.Unix(0, 0)
+		backend.now = func() time.Time { return now }
+	})
+
+	It("should evict the least recently used entries beyond its size", func() {
+		Expect(backend.Set(ctx, "a", []byte("1234"), time.Hour)).To(Succeed())
+		Expect(backend.Set(ctx, "b", []byte("1234"), time.Hour)).To(Succeed())
+		_, ok, _ := backend.Get(ctx, "a")
+		Expect(ok).To(BeTrue())
+
+		Expect(backend.Set(ctx, "c", []byte("1234"), time.Hour)).To(Succeed())
+
+		_, ok, _ = backend.Get(ctx, "b")
+		Expect(ok).To(BeFalse())
+		_, ok, _ = backend.Get(ctx, "a")
+		Expect(ok).To(BeTrue())
+		Expect(testutil.ToFloat64(backend.evictions)).To(Equal(1.0))
+		Expect(testutil.ToFloat64(backend.sizeBytes)).To(Equal(8.0))
+	})
+
+	It("should expire entries after their TTL", func() {
+		Expect(backend.Set(ctx, "a", []byte("1"), time.Minute)).To(Succeed())
+
+		now = now.Add(time.Minute)
+		_, ok, _ := backend.Get(ctx, "a")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("should not store values larger than its size", func() {
+		Expect(backend.Set(ctx, "a", []byte("12345678901"), time.Hour)).To(Succeed())
+
+		_, ok, _ := backend.Get(ctx, "a")
+		Expect(ok).To(BeFalse())
+	})
+})
@@ -0,0 +1,217 @@
+package querycache
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Response is a Prometheus query_range API response.
+type Response struct {
+	Status   string   `json:"status"`
+	Data     Data     `json:"data"`
+	Warnings []string `json:"warnings,omitempty"`
+	Infos    []string `json:"infos,omitempty"`
+}
+
+// Data is the result of a query_range response.
+type Data struct {
+	ResultType string   `json:"resultType"`
+	Result     []Series `json:"result"`
+}
+
+// Series is a series of a matrix result.
+type Series struct {
+	Metric     map[string]string `json:"metric"`
+	Values     []Sample          `json:"values,omitempty"`
+	Histograms []Sample          `json:"histograms,omitempty"`
+}
+
+// Sample is a point of a series: a timestamp in milliseconds and the value as
+// returned by Prometheus, a string for floats or an object for histograms.
+type Sample struct {
+	T int64
+	V json.RawMessage
+}
+
+func (s Sample) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]any{json.Number(strconv.FormatFloat(float64(s.T)/1000, 'f', -1, 64)), s.V})
+}
+
+func (s *Sample) UnmarshalJSON(b []byte) error {
+	var point []json.RawMessage
+	if err := json.Unmarshal(b, &point); err != nil {
+		return err
+	}
+	if len(point) != 2 {
+		return fmt.Errorf("sample has %d elements, want 2", len(point))
+	}
+	var t float64
+	if err := json.Unmarshal(point[0], &t); err != nil {
+		return fmt.Errorf("invalid sample timestamp: %w", err)
+	}
+	s.T = int64(math.Round(t * 1000))
+	s.V = point[1]
+	return nil
+}
+
+// Extent is the cached result of a query over a step-aligned range of
+// evaluation timestamps, in milliseconds, both inclusive.
+type Extent struct {
+	Start     int64     `json:"start"`
+	End       int64     `json:"end"`
+	FetchedAt time.Time `json:"fetchedAt"`
+	Series    []Series  `json:"series"`
+}
+
+// entry is the value stored in the backend for a query.
+type entry struct {
+	Extents []Extent `json:"extents"`
+}
+
+// gaps returns the ranges of [start, end] not covered by the sorted extents.
+func gaps(extents []Extent, start, end, step int64) [][2]int64 {
+	var missing [][2]int64
+	cursor := start
+	for _, e := range extents {
+		if e.End < cursor {
+			continue
+		}
+		if e.Start > end {
+			break
+		}
+		if e.Start > cursor {
+			missing = append(missing, [2]int64{cursor, e.Start - step})
+		}
+		cursor = e.End + step
+	}
+	if cursor <= end {
+		missing = append(missing, [2]int64{cursor, end})
+	}
+	return missing
+}
+
+// mergeExtents merges overlapping and adjacent extents into sorted disjoint
+// ones. Samples of later extents win over earlier ones at the same timestamp.
+func mergeExtents(extents []Extent, step int64) []Extent {
+	slices.SortStableFunc(extents, func(a, b Extent) int { return cmp.Compare(a.Start, b.Start) })
+
+	var merged []Extent
+	for _, e := range extents {
+		last := len(merged) - 1
+		if last < 0 || e.Start > merged[last].End+step {
+			merged = append(merged, e)
+			continue
+		}
+		merged[last] = Extent{
+			Start:     merged[last].Start,
+			End:       max(merged[last].End, e.End),
+			FetchedAt: minTime(merged[last].FetchedAt, e.FetchedAt),
+			Series:    mergeSeries(merged[last].Series, e.Series),
+		}
+	}
+	return merged
+}
+
+func minTime(a, b time.Time) time.Time {
+	if b.Before(a) {
+		return b
+	}
+	return a
+}
+
+// mergeSeries merges the samples of the series of b into those of a.
+func mergeSeries(a, b []Series) []Series {
+	out := make([]Series, 0, len(a)+len(b))
+	index := make(map[string]int, len(a)+len(b))
+	for _, series := range slices.Concat(a, b) {
+		key := seriesKey(series.Metric)
+		i, ok := index[key]
+		if !ok {
+			index[key] = len(out)
+			out = append(out, Series{
+				Metric:     series.Metric,
+				Values:     slices.Clone(series.Values),
+				Histograms: slices.Clone(series.Histograms),
+			})
+			continue
+		}
+		out[i].Values = mergeSamples(out[i].Values, series.Values)
+		out[i].Histograms = mergeSamples(out[i].Histograms, series.Histograms)
+	}
+	return out
+}
+
+// mergeSamples returns the samples of a and b sorted by timestamp, the sample
+// of b winning over the one of a at the same timestamp.
+func mergeSamples(a, b []Sample) []Sample {
+	if len(b) == 0 {
+		return a
+	}
+	out := slices.Concat(b, a)
+	slices.SortStableFunc(out, func(x, y Sample) int { return cmp.Compare(x.T, y.T) })
+	return slices.CompactFunc(out, func(x, y Sample) bool { return x.T == y.T })
+}
+
+func seriesKey(metric map[string]string) string {
+	names := make([]string, 0, len(metric))
+	for name := range metric {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name)
+		b.WriteByte(0)
+		b.WriteString(metric[name])
+		b.WriteByte(0)
+	}
+	return b.String()
+}
+
+// extract returns the series of the extents with their samples in [start, end].
+// Series without samples in the range are omitted.
+func extract(extents []Extent, start, end int64) []Series {
+	var out []Series
+	for _, e := range extents {
+		if e.End < start || e.Start > end {
+			continue
+		}
+		out = mergeSeries(out, trimSeries(e.Series, start, end))
+	}
+	return slices.DeleteFunc(out, func(s Series) bool { return len(s.Values) == 0 && len(s.Histograms) == 0 })
+}
+
+// trimSeries returns the series with their samples in [start, end].
+func trimSeries(series []Series, start, end int64) []Series {
+	out := make([]Series, 0, len(series))
+	for _, s := range series {
+		trimmed := Series{
+			Metric:     s.Metric,
+			Values:     trimSamples(s.Values, start, end),
+			Histograms: trimSamples(s.Histograms, start, end),
+		}
+		if len(trimmed.Values) > 0 || len(trimmed.Histograms) > 0 {
+			out = append(out, trimmed)
+		}
+	}
+	return out
+}
+
+func trimSamples(samples []Sample, start, end int64) []Sample {
+	from, _ := slices.BinarySearchFunc(samples, start, func(s Sample, t int64) int { return cmp.Compare(s.T, t) })
+	to, found := slices.BinarySearchFunc(samples, end, func(s Sample, t int64) int { return cmp.Compare(s.T, t) })
+	if found {
+		to++
+	}
+	if from >= to {
+		return nil
+	}
+	return samples[from:to:to]
+}
@@ -0,0 +1,100 @@
+package querycache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// MemoryBackend is an in-memory Backend bounded by the total size of its
+// values; the least recently used entries are evicted first.
+type MemoryBackend struct {
+	maxBytes int64
+	now      func() time.Time
+
+	mu      sync.Mutex
+	size    int64
+	lru     *list.List
+	entries map[string]*list.Element
+
+	sizeBytes prometheus.Gauge
+	evictions prometheus.Counter
+}
+
+type memoryEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// NewMemoryBackend creates a MemoryBackend holding at most maxBytes of values
+// and registers its metrics with reg.
+func NewMemoryBackend(maxBytes int64, reg prometheus.Registerer) *MemoryBackend {
+	b := &MemoryBackend{
+		maxBytes: maxBytes,
+		now:      time.Now,
+		lru:      list.New(),
+		entries:  make(map[string]*list.Element),
+		sizeBytes: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "query_cache_memory_bytes",
+			Help:      "Size of the values held by the in-memory results cache.",
+		}),
+		evictions: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "query_cache_memory_evictions_total",
+			Help:      "Number of entries evicted from the in-memory results cache to stay within its size.",
+		}),
+	}
+	reg.MustRegister(b.sizeBytes, b.evictions)
+	return b
+}
+
+func (b *MemoryBackend) Get(_ context.Context, key string) ([]byte, bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	el, ok := b.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	e := el.Value.(*memoryEntry)
+	if !b.now().Before(e.expiresAt) {
+		b.remove(el)
+		return nil, false, nil
+	}
+	b.lru.MoveToFront(el)
+	return e.value, true, nil
+}
+
+func (b *MemoryBackend) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if el, ok := b.entries[key]; ok {
+		b.remove(el)
+	}
+	if int64(len(value)) > b.maxBytes {
+		return nil
+	}
+
+	b.entries[key] = b.lru.PushFront(&memoryEntry{key: key, value: value, expiresAt: b.now().Add(ttl)})
+	b.size += int64(len(value))
+	for b.size > b.maxBytes {
+		b.remove(b.lru.Back())
+		b.evictions.Inc()
+	}
+	b.sizeBytes.Set(float64(b.size))
+	return nil
+}
+
+// remove removes an entry; b.mu must be held.
+func (b *MemoryBackend) remove(el *list.Element) {
+	e := b.lru.Remove(el).(*memoryEntry)
+	delete(b.entries, e.key)
+	b.size -= int64(len(e.value))
+	b.sizeBytes.Set(float64(b.size))
+}
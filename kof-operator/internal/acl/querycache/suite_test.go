@@ -0,0 +1,13 @@
+package querycache
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestQueryCache(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "ACL Query Cache Suite")
+}
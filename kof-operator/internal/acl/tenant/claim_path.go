@@ -0,0 +1,147 @@
+package tenant
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// claimPath is a compiled JSONPath-style claim path, e.g. `tenant`, `$.org.tenants[*]`,
+// `resource_access["kof"].roles` or `$["https://example.com/claims"].tenant`.
+type claimPath struct {
+	raw      string
+	segments []pathSegment
+}
+
+type pathSegment struct {
+	// key is the object key to descend into, empty for index and wildcard segments.
+	key string
+	// index is the array index to descend into when isIndex is set.
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// parseClaimPath compiles a claim path. Supported syntax is a subset of JSONPath:
+// an optional leading `$`, dot-separated keys, bracketed quoted keys for names
+// containing dots, `[N]` array indexes and `[*]` wildcards.
+func parseClaimPath(raw string) (*claimPath, error) {
+	path := strings.TrimSpace(raw)
+	path = strings.TrimPrefix(path, "$")
+	path = strings.TrimPrefix(path, ".")
+	if path == "" {
+		return nil, fmt.Errorf("empty claim path %q", raw)
+	}
+
+	cp := &claimPath{raw: raw}
+	for i := 0; i < len(path); {
+		switch path[i] {
+		case '.':
+			i++
+			if i >= len(path) || path[i] == '.' || path[i] == '[' {
+				return nil, fmt.Errorf("invalid claim path %q: empty key at %d", raw, i)
+			}
+		case '[':
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid claim path %q: unclosed bracket at %d", raw, i)
+			}
+			segment, err := parseBracket(path[i+1 : i+end])
+			if err != nil {
+				return nil, fmt.Errorf("invalid claim path %q: %w", raw, err)
+			}
+			cp.segments = append(cp.segments, segment)
+			i += end + 1
+		default:
+			end := strings.IndexAny(path[i:], ".[")
+			if end < 0 {
+				end = len(path) - i
+			}
+			cp.segments = append(cp.segments, pathSegment{key: path[i : i+end]})
+			i += end
+		}
+	}
+
+	return cp, nil
+}
+
+func parseBracket(content string) (pathSegment, error) {
+	content = strings.TrimSpace(content)
+	switch {
+	case content == "*":
+		return pathSegment{wildcard: true}, nil
+	case len(content) >= 2 && (content[0] == '"' || content[0] == '\'') && content[len(content)-1] == content[0]:
+		key := content[1 : len(content)-1]
+		if key == "" {
+			return pathSegment{}, fmt.Errorf("empty bracket key")
+		}
+		return pathSegment{key: key}, nil
+	default:
+		index, err := strconv.Atoi(content)
+		if err != nil || index < 0 {
+			return pathSegment{}, fmt.Errorf("unsupported bracket expression [%s]", content)
+		}
+		return pathSegment{index: index, isIndex: true}, nil
+	}
+}
+
+// strings evaluates the path against the claims and returns all string values found.
+// Arrays found at the end of the path are flattened, non-string values are ignored.
+func (p *claimPath) strings(claims map[string]any) []string {
+	nodes := []any{claims}
+	for _, segment := range p.segments {
+		next := make([]any, 0, len(nodes))
+		for _, node := range nodes {
+			next = append(next, segment.apply(node)...)
+		}
+		nodes = next
+	}
+
+	values := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		switch v := node.(type) {
+		case string:
+			values = append(values, v)
+		case []any:
+			for _, item := range v {
+				if s, ok := item.(string); ok {
+					values = append(values, s)
+				}
+			}
+		}
+	}
+	return values
+}
+
+func (s pathSegment) apply(node any) []any {
+	switch {
+	case s.wildcard:
+		switch v := node.(type) {
+		case []any:
+			return v
+		case map[string]any:
+			values := make([]any, 0, len(v))
+			for _, key := range slices.Sorted(maps.Keys(v)) {
+				values = append(values, v[key])
+			}
+			return values
+		}
+	case s.isIndex:
+		if v, ok := node.([]any); ok && s.index < len(v) {
+			return []any{v[s.index]}
+		}
+	default:
+		if v, ok := node.(map[string]any); ok {
+			if item, ok := v[s.key]; ok {
+				return []any{item}
+			}
+		}
+	}
+	return nil
+}
+
+func (p *claimPath) String() string {
+	return p.raw
+}
@@ -0,0 +1,207 @@
+package tenant
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	DefaultEmailClaim  = "email"
+	DefaultGroupsClaim = "groups"
+	DefaultTenantClaim = "tenant"
+	// DefaultGroupPrefix is the prefix of groups granting access to a tenant, e.g. "tenant:acme".
+	DefaultGroupPrefix = "tenant:"
+)
+
+var (
+	// ErrNoTenant is returned when the token of a non-admin user resolves to no tenant.
+	ErrNoTenant = errors.New("unauthorized: user has no tenant group or tenant claim")
+	// ErrTenantNotPermitted is returned when the user requests a tenant it does not belong to.
+	ErrTenantNotPermitted = errors.New("forbidden: tenant is not permitted")
+)
+
+// Resolver resolves the identity of an authenticated user from the verified ID token claims.
+type Resolver interface {
+	Resolve(claims map[string]any) (*Identity, error)
+}
+
+// Identity is the resolved identity of an authenticated user.
+type Identity struct {
+	Email  string
+	Groups []string
+	// Tenants the user belongs to, in resolution order and without duplicates.
+	Tenants []string
+	// Admin users bypass tenant isolation.
+	Admin bool
+}
+
+// Narrow returns the tenants the request is allowed to access. All permitted tenants
+// are returned when requested is empty, otherwise requested must be one of them.
+func (i *Identity) Narrow(requested string) ([]string, error) {
+	if len(i.Tenants) == 0 {
+		return nil, ErrNoTenant
+	}
+	if requested == "" {
+		return i.Tenants, nil
+	}
+	if !slices.Contains(i.Tenants, requested) {
+		return nil, fmt.Errorf("%w: %q", ErrTenantNotPermitted, requested)
+	}
+	return []string{requested}, nil
+}
+
+// Policy describes how the tenants and admin privileges of a user are resolved from the token claims.
+type Policy struct {
+	// EmailClaim is the claim path of the user email, "email" by default.
+	EmailClaim string `json:"emailClaim,omitempty"`
+	// GroupsClaim is the claim path of the user groups, "groups" by default.
+	GroupsClaim string `json:"groupsClaim,omitempty"`
+	// TenantClaims are claim paths holding tenant IDs either as a string or a list of strings.
+	TenantClaims []string `json:"tenantClaims,omitempty"`
+	// GroupMappings map user groups to tenant IDs.
+	GroupMappings []GroupMapping `json:"groupMappings,omitempty"`
+	// AdminEmails are emails of users bypassing tenant isolation.
+	AdminEmails []string `json:"adminEmails,omitempty"`
+	// AdminGroups are groups of users bypassing tenant isolation.
+	AdminGroups []string `json:"adminGroups,omitempty"`
+
+	emailClaim   *claimPath
+	groupsClaim  *claimPath
+	tenantClaims []*claimPath
+}
+
+// GroupMapping maps the groups matching a regular expression to a tenant ID.
+type GroupMapping struct {
+	// Pattern is a regular expression matched against the whole group name.
+	Pattern string `json:"pattern"`
+	// Tenant is the tenant ID template, it may reference capture groups of the pattern, e.g. "$1".
+	Tenant string `json:"tenant"`
+
+	re *regexp.Regexp
+}
+
+// DefaultPolicy returns the policy reading the "tenant" claim and "tenant:<id>" groups.
+func DefaultPolicy() *Policy {
+	p := &Policy{}
+	if err := p.compile(); err != nil {
+		panic(fmt.Sprintf("invalid default tenant policy: %v", err))
+	}
+	return p
+}
+
+// LoadPolicy reads the YAML policy file, fills the defaults and validates it.
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tenant policy file: %w", err)
+	}
+	return ParsePolicy(data)
+}
+
+// ParsePolicy parses the YAML policy, fills the defaults and validates it.
+func ParsePolicy(data []byte) (*Policy, error) {
+	p := new(Policy)
+	if err := yaml.UnmarshalStrict(data, p); err != nil {
+		return nil, fmt.Errorf("failed to parse tenant policy: %w", err)
+	}
+	if err := p.compile(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *Policy) compile() error {
+	var err error
+
+	if p.EmailClaim == "" {
+		p.EmailClaim = DefaultEmailClaim
+	}
+	if p.emailClaim, err = parseClaimPath(p.EmailClaim); err != nil {
+		return fmt.Errorf("emailClaim: %w", err)
+	}
+
+	if p.GroupsClaim == "" {
+		p.GroupsClaim = DefaultGroupsClaim
+	}
+	if p.groupsClaim, err = parseClaimPath(p.GroupsClaim); err != nil {
+		return fmt.Errorf("groupsClaim: %w", err)
+	}
+
+	if len(p.TenantClaims) == 0 && len(p.GroupMappings) == 0 {
+		p.TenantClaims = []string{DefaultTenantClaim}
+		p.GroupMappings = []GroupMapping{{
+			Pattern: "^" + regexp.QuoteMeta(DefaultGroupPrefix) + "(.+)$",
+			Tenant:  "$1",
+		}}
+	}
+
+	p.tenantClaims = make([]*claimPath, 0, len(p.TenantClaims))
+	for _, raw := range p.TenantClaims {
+		path, err := parseClaimPath(raw)
+		if err != nil {
+			return fmt.Errorf("tenantClaims: %w", err)
+		}
+		p.tenantClaims = append(p.tenantClaims, path)
+	}
+
+	for i := range p.GroupMappings {
+		mapping := &p.GroupMappings[i]
+		if mapping.Pattern == "" || mapping.Tenant == "" {
+			return fmt.Errorf("groupMappings[%d]: both pattern and tenant are required", i)
+		}
+		if mapping.re, err = regexp.Compile("^(?:" + mapping.Pattern + ")$"); err != nil {
+			return fmt.Errorf("groupMappings[%d]: invalid pattern: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// Resolve implements Resolver. Tenant claims are evaluated first, then the group mappings.
+func (p *Policy) Resolve(claims map[string]any) (*Identity, error) {
+	if p.emailClaim == nil {
+		return nil, fmt.Errorf("tenant policy is not initialized")
+	}
+
+	identity := &Identity{
+		Groups:  p.groupsClaim.strings(claims),
+		Tenants: make([]string, 0),
+	}
+	if emails := p.emailClaim.strings(claims); len(emails) > 0 {
+		identity.Email = emails[0]
+	}
+
+	for _, path := range p.tenantClaims {
+		for _, tenantID := range path.strings(claims) {
+			identity.addTenant(tenantID)
+		}
+	}
+
+	for _, group := range identity.Groups {
+		for _, mapping := range p.GroupMappings {
+			match := mapping.re.FindStringSubmatchIndex(group)
+			if match == nil {
+				continue
+			}
+			identity.addTenant(string(mapping.re.ExpandString(nil, mapping.Tenant, group, match)))
+		}
+	}
+
+	identity.Admin = identity.Email != "" && slices.Contains(p.AdminEmails, identity.Email) ||
+		slices.ContainsFunc(identity.Groups, func(group string) bool {
+			return slices.Contains(p.AdminGroups, group)
+		})
+
+	return identity, nil
+}
+
+func (i *Identity) addTenant(tenantID string) {
+	if tenantID != "" && !slices.Contains(i.Tenants, tenantID) {
+		i.Tenants = append(i.Tenants, tenantID)
+	}
+}
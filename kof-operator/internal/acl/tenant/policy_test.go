@@ -0,0 +1,179 @@
+package tenant
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Claim path", func() {
+	claims := map[string]any{
+		"tenant": "acme",
+		"org": map[string]any{
+			"tenants": []any{"a", "b", 42},
+		},
+		"resource_access": map[string]any{
+			"kof": map[string]any{"roles": []any{"tenant-x", "tenant-y"}},
+		},
+		"https://example.com/claims": map[string]any{"tenant": "dotted"},
+		"projects": []any{
+			map[string]any{"id": "p1"},
+			map[string]any{"id": "p2"},
+		},
+	}
+
+	DescribeTable("should evaluate supported expressions",
+		func(raw string, expected []string) {
+			path, err := parseClaimPath(raw)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(path.strings(claims)).To(Equal(expected))
+		},
+		Entry("plain key", "tenant", []string{"acme"}),
+		Entry("root prefix", "$.tenant", []string{"acme"}),
+		Entry("nested array is flattened", "org.tenants", []string{"a", "b"}),
+		Entry("wildcard", "$.org.tenants[*]", []string{"a", "b"}),
+		Entry("index", "org.tenants[1]", []string{"b"}),
+		Entry("quoted key", `resource_access["kof"].roles`, []string{"tenant-x", "tenant-y"}),
+		Entry("quoted key with dots", `$["https://example.com/claims"].tenant`, []string{"dotted"}),
+		Entry("wildcard over objects", "projects[*].id", []string{"p1", "p2"}),
+		Entry("missing key", "org.missing", []string{}),
+		Entry("out of range index", "org.tenants[5]", []string{}),
+	)
+
+	DescribeTable("should reject invalid expressions",
+		func(raw string) {
+			_, err := parseClaimPath(raw)
+			Expect(err).To(HaveOccurred())
+		},
+		Entry("empty", "$"),
+		Entry("empty key", "org..tenants"),
+		Entry("unclosed bracket", "org[0"),
+		Entry("unsupported bracket", "org[?(@.x)]"),
+		Entry("negative index", "org[-1]"),
+	)
+})
+
+var _ = Describe("Policy", func() {
+	It("should resolve tenant from groups with the default prefix", func() {
+		identity, err := DefaultPolicy().Resolve(map[string]any{
+			"email":  "user@example.com",
+			"groups": []any{"admin", "tenant:production", "developers"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(identity.Email).To(Equal("user@example.com"))
+		Expect(identity.Tenants).To(Equal([]string{"production"}))
+		Expect(identity.Admin).To(BeFalse())
+	})
+
+	It("should prefer the tenant claim over groups by default", func() {
+		identity, err := DefaultPolicy().Resolve(map[string]any{
+			"tenant": "claimed",
+			"groups": []any{"tenant:grouped"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(identity.Tenants).To(Equal([]string{"claimed", "grouped"}))
+	})
+
+	It("should not match partial prefix", func() {
+		identity, err := DefaultPolicy().Resolve(map[string]any{
+			"groups": []any{"tenants:production", "mytenant:test", "tenant:"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(identity.Tenants).To(BeEmpty())
+		_, err = identity.Narrow("")
+		Expect(err).To(MatchError(ErrNoTenant))
+	})
+
+	It("should resolve several tenants from nested claims and regex group mappings", func() {
+		policy, err := ParsePolicy([]byte(`
+emailClaim: profile.mail
+groupsClaim: resource_access["kof"].roles
+tenantClaims:
+- org.tenants[*]
+groupMappings:
+- pattern: "team-(.+)-(viewer|editor)"
+  tenant: "$1"
+- pattern: "ops"
+  tenant: "platform"
+`))
+		Expect(err).NotTo(HaveOccurred())
+
+		identity, err := policy.Resolve(map[string]any{
+			"profile": map[string]any{"mail": "sre@example.com"},
+			"org":     map[string]any{"tenants": []any{"acme", "globex"}},
+			"resource_access": map[string]any{
+				"kof": map[string]any{"roles": []any{"team-acme-viewer", "team-initech-editor", "ops", "xops"}},
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(identity.Email).To(Equal("sre@example.com"))
+		Expect(identity.Tenants).To(Equal([]string{"acme", "globex", "initech", "platform"}))
+	})
+
+	It("should grant admin by any of the emails or groups", func() {
+		policy, err := ParsePolicy([]byte(`
+adminEmails: [alice@example.com, bob@example.com]
+adminGroups: [kof-admins]
+`))
+		Expect(err).NotTo(HaveOccurred())
+
+		for _, claims := range []map[string]any{
+			{"email": "alice@example.com"},
+			{"email": "bob@example.com"},
+			{"email": "carol@example.com", "groups": []any{"kof-admins"}},
+		} {
+			identity, err := policy.Resolve(claims)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(identity.Admin).To(BeTrue(), "claims: %v", claims)
+		}
+
+		identity, err := policy.Resolve(map[string]any{"email": "eve@example.com", "groups": []any{"tenant:a"}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(identity.Admin).To(BeFalse())
+		Expect(identity.Tenants).To(Equal([]string{"a"}))
+	})
+
+	It("should load the policy from a file", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "policy.yaml")
+		Expect(os.WriteFile(path, []byte("tenantClaims: [kof_tenants]\n"), 0o600)).To(Succeed())
+
+		policy, err := LoadPolicy(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(policy.GroupMappings).To(BeEmpty())
+
+		identity, err := policy.Resolve(map[string]any{
+			"kof_tenants": []any{"a", "b", "a"},
+			"groups":      []any{"tenant:c"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(identity.Tenants).To(Equal([]string{"a", "b"}))
+	})
+
+	It("should narrow the request to one of the permitted tenants", func() {
+		identity := &Identity{Tenants: []string{"a", "b", "c"}}
+
+		tenants, err := identity.Narrow("")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tenants).To(Equal([]string{"a", "b", "c"}))
+
+		tenants, err = identity.Narrow("b")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tenants).To(Equal([]string{"b"}))
+
+		_, err = identity.Narrow("d")
+		Expect(err).To(MatchError(ErrTenantNotPermitted))
+	})
+
+	DescribeTable("should reject invalid policies",
+		func(data string) {
+			_, err := ParsePolicy([]byte(data))
+			Expect(err).To(HaveOccurred())
+		},
+		Entry("unknown field", "adminEmail: admin@example.com"),
+		Entry("invalid claim path", "tenantClaims: ['org[']"),
+		Entry("invalid pattern", "groupMappings: [{pattern: '(', tenant: x}]"),
+		Entry("mapping without tenant", "groupMappings: [{pattern: 'x'}]"),
+	)
+})
@@ -0,0 +1,111 @@
+package traceql
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenOpenBrace
+	tokenCloseBrace
+	tokenAnd
+	tokenOperator
+	tokenString
+	tokenWord
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	value string
+	pos   int
+}
+
+// operators are the comparison operators of the supported subset, longest first.
+var operators = []string{"!=", ">=", "<=", "=~", "!~", "=", ">", "<"}
+
+// lex splits a TraceQL query into tokens.
+func lex(query string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case unicode.IsSpace(rune(c)):
+			i++
+		case c == '{':
+			tokens = append(tokens, token{kind: tokenOpenBrace, text: "{", pos: i})
+			i++
+		case c == '}':
+			tokens = append(tokens, token{kind: tokenCloseBrace, text: "}", pos: i})
+			i++
+		case strings.HasPrefix(query[i:], "&&"):
+			tokens = append(tokens, token{kind: tokenAnd, text: "&&", pos: i})
+			i += 2
+		case c == '"' || c == '`':
+			value, end, err := lexString(query, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokenString, text: query[i:end], value: value, pos: i})
+			i = end
+		case isWordByte(c):
+			end := i
+			for end < len(query) && isWordByte(query[end]) {
+				end++
+			}
+			tokens = append(tokens, token{kind: tokenWord, text: query[i:end], value: query[i:end], pos: i})
+			i = end
+		default:
+			op := ""
+			for _, candidate := range operators {
+				if strings.HasPrefix(query[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unsupported TraceQL syntax %q at position %d", query[i:], i)
+			}
+			tokens = append(tokens, token{kind: tokenOperator, text: op, value: op, pos: i})
+			i += len(op)
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(query)}), nil
+}
+
+// isWordByte reports whether c may be part of an attribute name, a number,
+// a duration or a keyword.
+func isWordByte(c byte) bool {
+	return c == '.' || c == '_' || c == '-' || c == ':' || c == '/' ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// lexString returns the value of the string starting at i and the position after it.
+func lexString(query string, i int) (string, int, error) {
+	quote := query[i]
+	var b strings.Builder
+	for j := i + 1; j < len(query); j++ {
+		c := query[j]
+		switch {
+		case c == quote:
+			return b.String(), j + 1, nil
+		case c == '\\' && quote == '"' && j+1 < len(query):
+			j++
+			switch query[j] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			default:
+				b.WriteByte(query[j])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string at position %d", i)
+}
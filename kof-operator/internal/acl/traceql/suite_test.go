@@ -0,0 +1,13 @@
+package traceql
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestTraceQL(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "ACL TraceQL Suite")
+}
@@ -0,0 +1,256 @@
+// Package traceql translates a subset of TraceQL to VictoriaTraces LogsQL.
+//
+// The subset is a single spanset of conditions joined by &&, such as
+//
+//	{ resource.service.name = "api" && span.http.status_code >= 500 && duration > 1s && status = error }
+//
+// Conditions compare resource, span and unscoped attributes, the span name,
+// kind, status and duration. Other TraceQL constructs are rejected.
+package traceql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+// VictoriaTraces fields of the span intrinsics.
+const (
+	FieldName       = "name"
+	FieldKind       = "kind"
+	FieldStatusCode = "status_code"
+	FieldDuration   = "duration"
+
+	// ResourceAttrPrefix and SpanAttrPrefix prefix the fields of the resource and span attributes.
+	ResourceAttrPrefix = "resource_attr:"
+	SpanAttrPrefix     = "span_attr:"
+)
+
+// statusCodes are the OpenTelemetry status codes stored by VictoriaTraces.
+var statusCodes = map[string]string{
+	"unset": "0",
+	"ok":    "1",
+	"error": "2",
+}
+
+// spanKinds are the OpenTelemetry span kinds stored by VictoriaTraces.
+var spanKinds = map[string]string{
+	"unspecified": "0",
+	"internal":    "1",
+	"server":      "2",
+	"client":      "3",
+	"producer":    "4",
+	"consumer":    "5",
+}
+
+// Condition is a comparison of a span field with a value.
+type Condition struct {
+	// Fields are the VictoriaTraces fields compared, any of which may match:
+	// unscoped attributes are looked up both in the span and the resource.
+	Fields []string
+	// Op is the TraceQL comparison operator.
+	Op string
+	// Value is the value compared with, converted to its stored form.
+	Value string
+	// Numeric is true when Value is a number compared by range operators.
+	Numeric bool
+}
+
+// Query is a parsed TraceQL query: the conditions a span must all match.
+type Query struct {
+	Conditions []Condition
+}
+
+// Parse parses a TraceQL query of the supported subset. An empty query or
+// spanset matches every span.
+func Parse(query string) (*Query, error) {
+	tokens, err := lex(query)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &Query{}
+	if tokens[0].kind == tokenEOF {
+		return q, nil
+	}
+
+	p := &parser{tokens: tokens}
+	if _, err := p.expect(tokenOpenBrace); err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokenCloseBrace {
+		for {
+			cond, err := p.condition()
+			if err != nil {
+				return nil, err
+			}
+			q.Conditions = append(q.Conditions, cond)
+			if p.peek().kind != tokenAnd {
+				break
+			}
+			p.next()
+		}
+	}
+	if _, err := p.expect(tokenCloseBrace); err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(tokenEOF); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) expect(kind tokenKind) (token, error) {
+	t := p.next()
+	if t.kind != kind {
+		return t, unexpected(t)
+	}
+	return t, nil
+}
+
+func unexpected(t token) error {
+	if t.kind == tokenEOF {
+		return fmt.Errorf("unexpected end of query, only a single spanset of conditions joined by && is supported")
+	}
+	return fmt.Errorf("unsupported TraceQL %q at position %d, only a single spanset of conditions joined by && is supported",
+		t.text, t.pos)
+}
+
+// condition parses "<field> <op> <value>".
+func (p *parser) condition() (Condition, error) {
+	field, err := p.expect(tokenWord)
+	if err != nil {
+		return Condition{}, err
+	}
+	op, err := p.expect(tokenOperator)
+	if err != nil {
+		return Condition{}, err
+	}
+	value := p.next()
+	if value.kind != tokenWord && value.kind != tokenString {
+		return Condition{}, unexpected(value)
+	}
+
+	switch name := field.value; {
+	case name == "duration":
+		return durationCondition(op.value, value)
+	case name == "status":
+		return enumCondition(FieldStatusCode, op.value, value, statusCodes)
+	case name == "kind":
+		return enumCondition(FieldKind, op.value, value, spanKinds)
+	case name == "name":
+		return attributeCondition([]string{FieldName}, op.value, value)
+	case strings.HasPrefix(name, "resource."):
+		return attributeCondition([]string{ResourceAttrPrefix + strings.TrimPrefix(name, "resource.")}, op.value, value)
+	case strings.HasPrefix(name, "span."):
+		return attributeCondition([]string{SpanAttrPrefix + strings.TrimPrefix(name, "span.")}, op.value, value)
+	case strings.HasPrefix(name, ".") && len(name) > 1:
+		attr := strings.TrimPrefix(name, ".")
+		return attributeCondition([]string{SpanAttrPrefix + attr, ResourceAttrPrefix + attr}, op.value, value)
+	default:
+		return Condition{}, fmt.Errorf("unsupported TraceQL field %q at position %d", name, field.pos)
+	}
+}
+
+func durationCondition(op string, value token) (Condition, error) {
+	if value.kind != tokenWord || op == "=~" || op == "!~" {
+		return Condition{}, fmt.Errorf("duration must be compared with a duration such as 100ms")
+	}
+	d, err := model.ParseDuration(value.value)
+	if err != nil {
+		// Sub-millisecond durations such as 500us are not supported by model.ParseDuration.
+		td, tdErr := time.ParseDuration(value.value)
+		if tdErr != nil {
+			return Condition{}, fmt.Errorf("invalid duration %q: %w", value.value, err)
+		}
+		d = model.Duration(td)
+	}
+	return Condition{
+		Fields:  []string{FieldDuration},
+		Op:      op,
+		Value:   strconv.FormatInt(time.Duration(d).Nanoseconds(), 10),
+		Numeric: true,
+	}, nil
+}
+
+func enumCondition(field, op string, value token, values map[string]string) (Condition, error) {
+	stored, ok := values[value.value]
+	if value.kind != tokenWord || !ok || (op != "=" && op != "!=") {
+		return Condition{}, fmt.Errorf("unsupported comparison %s %s %s", strings.TrimSuffix(field, "_code"), op, value.text)
+	}
+	return Condition{Fields: []string{field}, Op: op, Value: stored}, nil
+}
+
+func attributeCondition(fields []string, op string, value token) (Condition, error) {
+	cond := Condition{Fields: fields, Op: op, Value: value.value}
+	switch op {
+	case ">", ">=", "<", "<=":
+		if _, err := strconv.ParseFloat(value.value, 64); err != nil || value.kind != tokenWord {
+			return Condition{}, fmt.Errorf("%s must be compared with a number", value.text)
+		}
+		cond.Numeric = true
+	}
+	return cond, nil
+}
+
+// LogsQL returns the LogsQL filter matching the spans of the query; "*" when
+// the query matches every span.
+func (q *Query) LogsQL() string {
+	if len(q.Conditions) == 0 {
+		return "*"
+	}
+	filters := make([]string, 0, len(q.Conditions))
+	for _, cond := range q.Conditions {
+		filters = append(filters, cond.logsQL())
+	}
+	return strings.Join(filters, " ")
+}
+
+func (c Condition) logsQL() string {
+	negate := c.Op == "!=" || c.Op == "!~"
+	filters := make([]string, 0, len(c.Fields))
+	for _, field := range c.Fields {
+		filters = append(filters, c.fieldFilter(strconv.Quote(field)))
+	}
+
+	filter := filters[0]
+	if len(filters) > 1 {
+		filter = "(" + strings.Join(filters, " or ") + ")"
+	}
+	if negate {
+		return "!" + filter
+	}
+	return filter
+}
+
+func (c Condition) fieldFilter(field string) string {
+	switch c.Op {
+	case "=", "!=":
+		return fmt.Sprintf("%s:=%s", field, strconv.Quote(c.Value))
+	case "=~", "!~":
+		// TraceQL regular expressions are anchored, LogsQL ones are not.
+		return fmt.Sprintf("%s:~%s", field, strconv.Quote("^(?:"+c.Value+")$"))
+	default:
+		return fmt.Sprintf("%s:%s%s", field, c.Op, c.Value)
+	}
+}
@@ -0,0 +1,41 @@
+package traceql
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Parse", func() {
+	DescribeTable("should translate the query to LogsQL",
+		func(query, expected string) {
+			q, err := Parse(query)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(q.LogsQL()).To(Equal(expected))
+		},
+		Entry("empty query", "", "*"),
+		Entry("empty spanset", "{ }", "*"),
+		Entry("resource attribute", `{ resource.service.name = "api" }`, `"resource_attr:service.name":="api"`),
+		Entry("span attribute range", `{ span.http.status_code >= 500 }`, `"span_attr:http.status_code":>=500`),
+		Entry("unscoped attribute", `{ .region != "eu" }`, `!("span_attr:region":="eu" or "resource_attr:region":="eu")`),
+		Entry("anchored regexp", `{ name =~ "GET .*" }`, `"name":~"^(?:GET .*)$"`),
+		Entry("duration", `{ duration > 1.5s }`, `"duration":>1500000000`),
+		Entry("sub-millisecond duration", `{ duration < 500us }`, `"duration":<500000`),
+		Entry("status and kind", `{ status = error && kind = server }`, `"status_code":="2" "kind":="2"`),
+	)
+
+	DescribeTable("should reject unsupported syntax",
+		func(query string) {
+			_, err := Parse(query)
+			Expect(err).To(HaveOccurred())
+		},
+		Entry("several spansets", `{ name = "a" } && { name = "b" }`),
+		Entry("or", `{ name = "a" || name = "b" }`),
+		Entry("pipeline", `{ name = "a" } | count() > 1`),
+		Entry("unknown intrinsic", `{ rootName = "a" }`),
+		Entry("unknown status", `{ status = failed }`),
+		Entry("duration regexp", `{ duration =~ "1s" }`),
+		Entry("non-numeric range", `{ span.user > "bob" }`),
+		Entry("unterminated spanset", `{ name = "a"`),
+		Entry("unterminated string", `{ name = "a }`),
+	)
+})
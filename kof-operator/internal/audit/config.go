@@ -21,6 +21,7 @@ import (
 	"time"
 
 	"github.com/k0rdent/kof/kof-operator/internal/env"
+	"github.com/k0rdent/kof/kof-operator/internal/retention"
 )
 
 // Config holds all exporter configuration sourced from environment variables.
@@ -42,10 +43,25 @@ type Config struct {
 	ComplianceMode bool // COMPLIANCE_MODE: if true, WORM/object-lock is required
 
 	// KMS signing
+	// KMS_PROVIDER: signer backend, one of local (default), aws, vault.
+	KMSProvider string
 	// KMS_KEY_ID:     key reference passed to the Signer implementation.
 	//   - For the built-in LocalSigner: base64-encoded HMAC key material.
-	//   - For AWS KMS: the key ARN / alias.
+	//   - For AWS KMS: the key ID, ARN or alias of an asymmetric signing key.
+	//   - For Vault Transit: the Transit key name.
 	KMSKeyID string
+	// KMS_REGION: AWS KMS region (default: S3_REGION).
+	KMSRegion string
+	// KMS_ENDPOINT: AWS KMS endpoint override, e.g. a VPC endpoint (optional).
+	KMSEndpoint string
+	// KMS_SIGNING_ALGORITHM: AWS KMS signing algorithm (default: ECDSA_SHA_256).
+	KMSSigningAlgorithm string
+
+	// Vault Transit
+	VaultAddr         string // VAULT_ADDR
+	VaultToken        string // VAULT_TOKEN
+	VaultNamespace    string // VAULT_NAMESPACE (optional)
+	VaultTransitMount string // VAULT_TRANSIT_MOUNT (default: transit)
 
 	// Export behaviour
 	// STREAMS: comma-separated list of streams to export.
@@ -66,6 +82,17 @@ type Config struct {
 	// Default: 24
 	CatchUpHours int
 
+	// Run reporting
+	PushgatewayURL string // PUSHGATEWAY_URL: push run metrics to this Pushgateway (optional)
+	RemoteWriteURL string // REMOTE_WRITE_URL: push run metrics to this remote-write endpoint (optional)
+	RunReport      bool   // RUN_REPORT: write a JSON run report to <S3_PREFIX>/_reports/ (default: true)
+
+	// RETENTION_POLICY: comma-separated "<stream>[/<tenant>]=<age>" rules
+	// applied by the retention subcommand, e.g. "tenant-audit-log=7y".
+	// Windows under an object-lock retention are never deleted.
+	// Default: empty (keep forever).
+	Retention *retention.Policy
+
 	// Producer metadata included in the manifest.
 	ProducerName    string // PRODUCER_NAME
 	ProducerVersion string // PRODUCER_VERSION
@@ -84,13 +111,25 @@ func LoadConfig() (*Config, error) {
 		S3UsePathStyle:  env.GetEnvBool("S3_USE_PATH_STYLE", true),
 		S3ForceHTTP:     env.GetEnvBool("S3_FORCE_HTTP", false),
 		ComplianceMode:  env.GetEnvBool("COMPLIANCE_MODE", false),
+		KMSProvider:     env.GetEnvOrDefault("KMS_PROVIDER", KMSProviderLocal),
 		KMSKeyID:        env.GetEnvOrDefault("KMS_KEY_ID", "local-dev-key"),
+		KMSEndpoint:     env.GetEnvOrDefault("KMS_ENDPOINT", ""),
+		VaultAddr:       env.GetEnvOrDefault("VAULT_ADDR", ""),
+		VaultToken:      env.GetEnvOrDefault("VAULT_TOKEN", ""),
+		VaultNamespace:  env.GetEnvOrDefault("VAULT_NAMESPACE", ""),
 		ExportDelay:     env.GetEnvDuration("EXPORT_DELAY", 5*time.Minute),
 		CatchUpHours:    env.GetEnvInt("CATCHUP_HOURS", 24),
 		ProducerName:    env.GetEnvOrDefault("PRODUCER_NAME", "audit-logs-exporter"),
 		ProducerVersion: env.GetEnvOrDefault("PRODUCER_VERSION", "v0.1.0"),
+		PushgatewayURL:  env.GetEnvOrDefault("PUSHGATEWAY_URL", ""),
+		RemoteWriteURL:  env.GetEnvOrDefault("REMOTE_WRITE_URL", ""),
+		RunReport:       env.GetEnvBool("RUN_REPORT", true),
 	}
 
+	cfg.KMSRegion = env.GetEnvOrDefault("KMS_REGION", cfg.S3Region)
+	cfg.KMSSigningAlgorithm = env.GetEnvOrDefault("KMS_SIGNING_ALGORITHM", SigningAlgorithmECDSASHA256)
+	cfg.VaultTransitMount = env.GetEnvOrDefault("VAULT_TRANSIT_MOUNT", DefaultVaultTransitMount)
+
 	// Streams
 	streamsRaw := env.GetEnvOrDefault("STREAMS", StreamTenantAuditLog+","+StreamPlatformAuditLog)
 	for _, s := range strings.Split(streamsRaw, ",") {
@@ -111,6 +150,12 @@ func LoadConfig() (*Config, error) {
 		}
 	}
 
+	var err error
+	cfg.Retention, err = retention.ParsePolicy(os.Getenv("RETENTION_POLICY"), StreamTenantAuditLog, StreamPlatformAuditLog)
+	if err != nil {
+		return nil, fmt.Errorf("RETENTION_POLICY: %w", err)
+	}
+
 	// Required fields
 	if cfg.S3Bucket == "" {
 		return nil, fmt.Errorf("S3_BUCKET is required")
@@ -123,6 +168,17 @@ func LoadConfig() (*Config, error) {
 		return nil, fmt.Errorf("S3_ACCESS_KEY and S3_SECRET_KEY must both be set or both be absent")
 	}
 
+	switch cfg.KMSProvider {
+	case KMSProviderLocal, KMSProviderAWS:
+	case KMSProviderVault:
+		if cfg.VaultAddr == "" || cfg.VaultToken == "" {
+			return nil, fmt.Errorf("VAULT_ADDR and VAULT_TOKEN are required when KMS_PROVIDER is %q", KMSProviderVault)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported KMS_PROVIDER %q (expected %s, %s or %s)",
+			cfg.KMSProvider, KMSProviderLocal, KMSProviderAWS, KMSProviderVault)
+	}
+
 	return cfg, nil
 }
 
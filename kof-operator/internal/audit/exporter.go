@@ -22,8 +22,16 @@ import (
 	"time"
 
 	"github.com/go-logr/logr"
+	"github.com/k0rdent/kof/kof-operator/internal/runreport"
 )
 
+// JobName names the exporter in run metrics and run report keys.
+const JobName = "audit-logs-exporter"
+
+// publishTimeout bounds publishing the run report, which also happens after
+// the run context was cancelled.
+const publishTimeout = 30 * time.Second
+
 // vlogsQuerier is the subset of VLogsClient used by the Exporter.
 // Extracted as an interface to allow unit-testing without a real VictoriaLogs
 // instance.
@@ -43,10 +51,14 @@ type Exporter struct {
 	s3     *S3Client
 	signer Signer
 	log    logr.Logger
+
+	publisher *runreport.Publisher
+	// report records the window outcomes of the current run.
+	report *runreport.Recorder
 }
 
 // NewExporter constructs and validates an Exporter.
-func NewExporter(cfg *Config, log logr.Logger) (*Exporter, error) {
+func NewExporter(ctx context.Context, cfg *Config, log logr.Logger) (*Exporter, error) {
 	vlogs := NewVLogsClient(cfg.VLogsURL)
 
 	s3client, err := NewS3Client(cfg)
@@ -54,7 +66,7 @@ func NewExporter(cfg *Config, log logr.Logger) (*Exporter, error) {
 		return nil, fmt.Errorf("create S3 client: %w", err)
 	}
 
-	signer, err := NewSigner(cfg)
+	signer, err := NewSigner(ctx, cfg)
 	if err != nil {
 		return nil, fmt.Errorf("create signer: %w", err)
 	}
@@ -65,12 +77,32 @@ func NewExporter(cfg *Config, log logr.Logger) (*Exporter, error) {
 		s3:     s3client,
 		signer: signer,
 		log:    log,
+		publisher: runreport.NewPublisher(runreport.Config{
+			Job:            JobName,
+			S3Prefix:       cfg.S3Prefix,
+			WriteReport:    cfg.RunReport,
+			PushgatewayURL: cfg.PushgatewayURL,
+			RemoteWriteURL: cfg.RemoteWriteURL,
+		}, s3client.Client, log),
 	}, nil
 }
 
 // Run executes the export job. It is intended to be called once per CronJob
 // invocation and exits when all pending windows have been processed.
+// The outcome is published as run metrics and a run report, also on failure.
 func (e *Exporter) Run(ctx context.Context) error {
+	e.report = runreport.NewRecorder(JobName, time.Now())
+	err := e.run(ctx)
+
+	publishCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), publishTimeout)
+	defer cancel()
+	if pubErr := e.publisher.Publish(publishCtx, e.report.Finish(time.Now(), err)); pubErr != nil {
+		e.log.Error(pubErr, "failed to publish run report")
+	}
+	return err
+}
+
+func (e *Exporter) run(ctx context.Context) error {
 	log := e.log
 
 	// ── Bucket preflight ──────────────────────────────────────────────────
@@ -115,6 +147,7 @@ func (e *Exporter) Run(ctx context.Context) error {
 			return ctx.Err()
 		}
 		if err := e.exportWindow(ctx, w); err != nil {
+			e.report.Failed(w.Stream, w.Tenant)
 			var srcErr *SourceUnavailableError
 			if errors.As(err, &srcErr) {
 				// Source outage — alert and abort; the next CronJob run will catch up.
@@ -164,8 +197,10 @@ func (e *Exporter) collectWindows(ctx context.Context, from, upToEnd time.Time)
 					return nil, fmt.Errorf("check manifest %q: %w", manifestKey, err)
 				}
 				if exists {
+					e.report.Skipped(stream, tenant, wStart)
 					continue
 				}
+				e.report.Pending(stream, tenant, wStart)
 				windows = append(windows, w)
 			}
 		}
@@ -16,10 +16,25 @@ package audit
 
 import (
 	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/hmac"
+	"crypto/rsa"
 	"crypto/sha256"
+	"crypto/x509"
 	"encoding/base64"
+	"encoding/pem"
+	"errors"
 	"fmt"
+	"strings"
+)
+
+// KMS providers selected by KMS_PROVIDER.
+const (
+	KMSProviderLocal = "local"
+	KMSProviderAWS   = "aws"
+	KMSProviderVault = "vault"
 )
 
 // Signer is a pluggable KMS-style interface for signing manifest bytes.
@@ -33,10 +48,24 @@ type Signer interface {
 	Algorithm() string
 }
 
+// PublicKeySigner is implemented by asymmetric signers whose signatures can be
+// verified with a public key, without access to the signing key.
+type PublicKeySigner interface {
+	Signer
+	// PublicKey returns the public key of the signing key.
+	PublicKey(ctx context.Context) (crypto.PublicKey, error)
+}
+
+// Verifier checks manifest signatures produced by a Signer.
+type Verifier interface {
+	// Verify checks the base64-encoded signature over data made with algorithm.
+	Verify(ctx context.Context, algorithm string, data, signature []byte) error
+}
+
 // ---------------------------------------------------------------------------
 // LocalSigner — HMAC-SHA256 with a static secret.
-// Suitable for development and testing.  In production, replace with an
-// AWS KMS, HashiCorp Vault, or PKCS#11-backed implementation.
+// Suitable for development and testing.  In production, use the AWS KMS
+// (KMS_PROVIDER=aws) or HashiCorp Vault Transit (KMS_PROVIDER=vault) signer.
 // ---------------------------------------------------------------------------
 
 // LocalSigner implements Signer using HMAC-SHA256 with a static key.
@@ -64,18 +93,184 @@ func (s *LocalSigner) Sign(_ context.Context, data []byte) ([]byte, error) {
 	if _, err := mac.Write(data); err != nil {
 		return nil, fmt.Errorf("HMAC write: %w", err)
 	}
-	sig := mac.Sum(nil)
-	dst := make([]byte, base64.StdEncoding.EncodedLen(len(sig)))
-	base64.StdEncoding.Encode(dst, sig)
-	return dst, nil
+	return encodeSignature(mac.Sum(nil)), nil
+}
+
+// Verify implements Verifier by recomputing the HMAC with the same key.
+func (s *LocalSigner) Verify(ctx context.Context, algorithm string, data, signature []byte) error {
+	if algorithm != SigningAlgorithmHMAC {
+		return fmt.Errorf("unsupported signing algorithm %q for the local HMAC key", algorithm)
+	}
+	expected, err := s.Sign(ctx, data)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(expected, []byte(strings.TrimSpace(string(signature)))) {
+		return errors.New("invalid signature")
+	}
+	return nil
 }
 
 func (s *LocalSigner) KeyID() string     { return s.keyID }
 func (s *LocalSigner) Algorithm() string { return SigningAlgorithmHMAC }
 
-// NewSigner returns the appropriate Signer based on configuration.
-// Currently only LocalSigner is implemented; extend here to support AWS KMS.
-func NewSigner(cfg *Config) (Signer, error) {
-	// Future: if cfg.KMSProvider == "aws", return NewAWSKMSSigner(cfg)
-	return NewLocalSigner(cfg.KMSKeyID), nil
+// NewSigner returns the Signer selected by cfg.KMSProvider.
+// Remote signers look up their key on creation, so misconfiguration fails fast.
+func NewSigner(ctx context.Context, cfg *Config) (Signer, error) {
+	switch cfg.KMSProvider {
+	case "", KMSProviderLocal:
+		return NewLocalSigner(cfg.KMSKeyID), nil
+	case KMSProviderAWS:
+		return NewAWSKMSSigner(ctx, AWSKMSSignerConfig{
+			KeyID:            cfg.KMSKeyID,
+			Region:           cfg.KMSRegion,
+			Endpoint:         cfg.KMSEndpoint,
+			SigningAlgorithm: cfg.KMSSigningAlgorithm,
+		})
+	case KMSProviderVault:
+		return NewVaultTransitSigner(ctx, VaultTransitSignerConfig{
+			Address:   cfg.VaultAddr,
+			Token:     cfg.VaultToken,
+			Namespace: cfg.VaultNamespace,
+			Mount:     cfg.VaultTransitMount,
+			KeyName:   cfg.KMSKeyID,
+		})
+	default:
+		return nil, fmt.Errorf("unsupported KMS provider %q", cfg.KMSProvider)
+	}
+}
+
+// PublicKeyVerifier implements Verifier for asymmetric signatures with a public key.
+type PublicKeyVerifier struct {
+	Key crypto.PublicKey
+}
+
+func (v *PublicKeyVerifier) Verify(_ context.Context, algorithm string, data, signature []byte) error {
+	return VerifySignature(v.Key, algorithm, data, signature)
+}
+
+// NewVerifier returns the Verifier of manifest signatures. When publicKeyPEM is
+// set, signatures are checked offline with that key; otherwise the configured
+// signer is used: its public key for asymmetric signers, the HMAC key for the local one.
+func NewVerifier(ctx context.Context, cfg *Config, publicKeyPEM []byte) (Verifier, error) {
+	if len(publicKeyPEM) > 0 {
+		key, err := ParsePublicKeyPEM(publicKeyPEM)
+		if err != nil {
+			return nil, err
+		}
+		return &PublicKeyVerifier{Key: key}, nil
+	}
+
+	signer, err := NewSigner(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	switch s := signer.(type) {
+	case Verifier:
+		return s, nil
+	case PublicKeySigner:
+		key, err := s.PublicKey(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("get public key: %w", err)
+		}
+		return &PublicKeyVerifier{Key: key}, nil
+	default:
+		return nil, fmt.Errorf("signer %T cannot verify signatures", signer)
+	}
+}
+
+// VerifySignature checks a base64-encoded signature produced by an asymmetric
+// signer over data using the public key and the manifest signing algorithm.
+func VerifySignature(pub crypto.PublicKey, algorithm string, data, signature []byte) error {
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+	if err != nil {
+		return fmt.Errorf("decode signature: %w", err)
+	}
+
+	if algorithm == SigningAlgorithmEd25519 {
+		key, ok := pub.(ed25519.PublicKey)
+		if !ok {
+			return fmt.Errorf("%s requires an Ed25519 public key, got %T", algorithm, pub)
+		}
+		if !ed25519.Verify(key, data, sig) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	}
+
+	hash, err := signingHash(algorithm)
+	if err != nil {
+		return err
+	}
+	h := hash.New()
+	_, _ = h.Write(data)
+	digest := h.Sum(nil)
+
+	switch {
+	case strings.HasPrefix(algorithm, "ECDSA_"):
+		key, ok := pub.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%s requires an ECDSA public key, got %T", algorithm, pub)
+		}
+		if !ecdsa.VerifyASN1(key, digest, sig) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	case strings.HasPrefix(algorithm, "RSASSA_PSS_"):
+		key, ok := pub.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%s requires an RSA public key, got %T", algorithm, pub)
+		}
+		return rsa.VerifyPSS(key, hash, digest, sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto})
+	case strings.HasPrefix(algorithm, "RSASSA_PKCS1_V1_5_"):
+		key, ok := pub.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%s requires an RSA public key, got %T", algorithm, pub)
+		}
+		return rsa.VerifyPKCS1v15(key, hash, digest, sig)
+	default:
+		return fmt.Errorf("unsupported signing algorithm %q", algorithm)
+	}
+}
+
+// MarshalPublicKeyPEM encodes a public key as a PEM "PUBLIC KEY" block (PKIX).
+func MarshalPublicKeyPEM(pub crypto.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, fmt.Errorf("marshal public key: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+// ParsePublicKeyPEM decodes a PEM "PUBLIC KEY" block (PKIX).
+func ParsePublicKeyPEM(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found in public key")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse public key: %w", err)
+	}
+	return key, nil
+}
+
+// signingHash returns the digest algorithm of an asymmetric signing algorithm.
+func signingHash(algorithm string) (crypto.Hash, error) {
+	switch {
+	case strings.HasSuffix(algorithm, "_SHA_256"):
+		return crypto.SHA256, nil
+	case strings.HasSuffix(algorithm, "_SHA_384"):
+		return crypto.SHA384, nil
+	case strings.HasSuffix(algorithm, "_SHA_512"):
+		return crypto.SHA512, nil
+	default:
+		return 0, fmt.Errorf("unsupported signing algorithm %q", algorithm)
+	}
+}
+
+func encodeSignature(sig []byte) []byte {
+	dst := make([]byte, base64.StdEncoding.EncodedLen(len(sig)))
+	base64.StdEncoding.Encode(dst, sig)
+	return dst
 }
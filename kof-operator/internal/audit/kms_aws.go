@@ -0,0 +1,137 @@
+// Copyright 2025
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	kmstypes "github.com/aws/aws-sdk-go-v2/service/kms/types"
+)
+
+// AWSKMSSignerConfig configures the AWS KMS signer.
+type AWSKMSSignerConfig struct {
+	// KeyID is the key ID, ARN, alias name or alias ARN of an asymmetric
+	// SIGN_VERIFY KMS key.
+	KeyID string
+	// Region of the KMS key.
+	Region string
+	// Endpoint overrides the regional KMS endpoint, e.g. a VPC endpoint.
+	Endpoint string
+	// SigningAlgorithm is the KMS signing algorithm, default ECDSA_SHA_256.
+	SigningAlgorithm string
+	// Credentials overrides the default AWS credential chain.
+	Credentials aws.CredentialsProvider
+	// HTTPClient overrides the default HTTP client.
+	HTTPClient *http.Client
+}
+
+// AWSKMSSigner implements PublicKeySigner with the AWS KMS Sign API.
+// The manifest digest is computed locally and sent with MessageType DIGEST,
+// so the manifest size is not limited by the 4 KiB KMS message limit.
+type AWSKMSSigner struct {
+	cfg       AWSKMSSignerConfig
+	hash      crypto.Hash
+	client    *kms.Client
+	publicKey crypto.PublicKey
+}
+
+// NewAWSKMSSigner creates an AWSKMSSigner. It fetches the public key of the
+// key and checks that the key supports the configured signing algorithm.
+func NewAWSKMSSigner(ctx context.Context, cfg AWSKMSSignerConfig) (*AWSKMSSigner, error) {
+	if cfg.KeyID == "" {
+		return nil, fmt.Errorf("AWS KMS key ID is required")
+	}
+	if cfg.SigningAlgorithm == "" {
+		cfg.SigningAlgorithm = SigningAlgorithmECDSASHA256
+	}
+	hash, err := signingHash(cfg.SigningAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	loadOpts := []func(*awsconfig.LoadOptions) error{
+		awsconfig.WithRegion(cfg.Region),
+	}
+	if cfg.Credentials != nil {
+		loadOpts = append(loadOpts, awsconfig.WithCredentialsProvider(cfg.Credentials))
+	}
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, loadOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("load AWS config: %w", err)
+	}
+
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	client := kms.NewFromConfig(awsCfg, func(o *kms.Options) {
+		o.HTTPClient = httpClient
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+		}
+	})
+
+	s := &AWSKMSSigner{cfg: cfg, hash: hash, client: client}
+
+	out, err := client.GetPublicKey(ctx, &kms.GetPublicKeyInput{KeyId: aws.String(cfg.KeyID)})
+	if err != nil {
+		return nil, fmt.Errorf("get public key of %q: %w", cfg.KeyID, err)
+	}
+	algorithm := kmstypes.SigningAlgorithmSpec(cfg.SigningAlgorithm)
+	if !slices.Contains(out.SigningAlgorithms, algorithm) {
+		return nil, fmt.Errorf("KMS key %q does not support %s (supported: %v)",
+			cfg.KeyID, cfg.SigningAlgorithm, out.SigningAlgorithms)
+	}
+	if s.publicKey, err = x509.ParsePKIXPublicKey(out.PublicKey); err != nil {
+		return nil, fmt.Errorf("parse public key of %q: %w", cfg.KeyID, err)
+	}
+
+	return s, nil
+}
+
+func (s *AWSKMSSigner) Sign(ctx context.Context, data []byte) ([]byte, error) {
+	h := s.hash.New()
+	_, _ = h.Write(data)
+
+	out, err := s.client.Sign(ctx, &kms.SignInput{
+		KeyId:            aws.String(s.cfg.KeyID),
+		Message:          h.Sum(nil),
+		MessageType:      kmstypes.MessageTypeDigest,
+		SigningAlgorithm: kmstypes.SigningAlgorithmSpec(s.cfg.SigningAlgorithm),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("KMS sign: %w", err)
+	}
+	if len(out.Signature) == 0 {
+		return nil, fmt.Errorf("KMS sign: empty signature")
+	}
+	return encodeSignature(out.Signature), nil
+}
+
+func (s *AWSKMSSigner) KeyID() string     { return s.cfg.KeyID }
+func (s *AWSKMSSigner) Algorithm() string { return s.cfg.SigningAlgorithm }
+
+func (s *AWSKMSSigner) PublicKey(_ context.Context) (crypto.PublicKey, error) {
+	return s.publicKey, nil
+}
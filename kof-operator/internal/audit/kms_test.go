@@ -0,0 +1,314 @@
+// Copyright 2025
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	awsKMSContentType = "application/x-amz-json-1.1"
+	awsKMSTargetSign  = "TrentService.Sign"
+	awsKMSTargetKey   = "TrentService.GetPublicKey"
+)
+
+// fakeKMS is a local stand-in for the AWS KMS JSON API backed by an in-memory ECDSA key.
+type fakeKMS struct {
+	key      *ecdsa.PrivateKey
+	keyID    string
+	requests []string
+}
+
+func (f *fakeKMS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	Expect(r.Method).To(Equal(http.MethodPost))
+	Expect(r.Header.Get("Content-Type")).To(Equal(awsKMSContentType))
+	Expect(r.Header.Get("Authorization")).To(HavePrefix("AWS4-HMAC-SHA256 Credential=AKIDTEST/"))
+	Expect(r.Header.Get("Authorization")).To(ContainSubstring("/eu-west-1/kms/aws4_request"))
+
+	target := r.Header.Get("X-Amz-Target")
+	f.requests = append(f.requests, target)
+
+	var in map[string]any
+	Expect(json.NewDecoder(r.Body).Decode(&in)).To(Succeed())
+	if in["KeyId"] != f.keyID {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"__type":"NotFoundException","message":"Key not found"}`))
+		return
+	}
+
+	switch target {
+	case awsKMSTargetKey:
+		der, err := x509.MarshalPKIXPublicKey(&f.key.PublicKey)
+		Expect(err).NotTo(HaveOccurred())
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"KeyId":             f.keyID,
+			"KeyUsage":          "SIGN_VERIFY",
+			"PublicKey":         der,
+			"SigningAlgorithms": []string{SigningAlgorithmECDSASHA256},
+		})
+	case awsKMSTargetSign:
+		Expect(in["MessageType"]).To(Equal("DIGEST"))
+		Expect(in["SigningAlgorithm"]).To(Equal(SigningAlgorithmECDSASHA256))
+		digest, err := base64.StdEncoding.DecodeString(in["Message"].(string))
+		Expect(err).NotTo(HaveOccurred())
+		sig, err := ecdsa.SignASN1(rand.Reader, f.key, digest)
+		Expect(err).NotTo(HaveOccurred())
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"KeyId":            f.keyID,
+			"Signature":        sig,
+			"SigningAlgorithm": SigningAlgorithmECDSASHA256,
+		})
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// fakeVault is a local stand-in for the Vault Transit API backed by an in-memory key.
+type fakeVault struct {
+	keyType string
+	signer  crypto.Signer
+	paths   []string
+}
+
+func (f *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Vault-Token") != "root" {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"errors":["permission denied"]}`))
+		return
+	}
+	f.paths = append(f.paths, r.Method+" "+r.URL.Path)
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/v1/transit/keys/audit":
+		var publicKey string
+		if f.keyType == "ed25519" {
+			publicKey = base64.StdEncoding.EncodeToString(f.signer.Public().(ed25519.PublicKey))
+		} else {
+			der, err := x509.MarshalPKIXPublicKey(f.signer.Public())
+			Expect(err).NotTo(HaveOccurred())
+			publicKey = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{
+			"type":           f.keyType,
+			"latest_version": 2,
+			"keys":           map[string]any{"1": map[string]any{}, "2": map[string]any{"public_key": publicKey}},
+		}})
+	case r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/v1/transit/sign/audit"):
+		var in vaultTransitSignRequest
+		Expect(json.NewDecoder(r.Body).Decode(&in)).To(Succeed())
+		Expect(in.KeyVersion).To(Equal(2))
+		input, err := base64.StdEncoding.DecodeString(in.Input)
+		Expect(err).NotTo(HaveOccurred())
+
+		var sig []byte
+		switch key := f.signer.(type) {
+		case ed25519.PrivateKey:
+			sig = ed25519.Sign(key, input)
+		case *rsa.PrivateKey:
+			Expect(in.SignatureAlgorithm).To(Equal("pss"))
+			digest := sha256.Sum256(input)
+			sig, err = rsa.SignPSS(rand.Reader, key, crypto.SHA256, digest[:], nil)
+		case *ecdsa.PrivateKey:
+			Expect(in.MarshalingAlgorithm).To(Equal("asn1"))
+			digest := sha256.Sum256(input)
+			sig, err = ecdsa.SignASN1(rand.Reader, key, digest[:])
+		}
+		Expect(err).NotTo(HaveOccurred())
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{
+			"signature":   "vault:v2:" + base64.StdEncoding.EncodeToString(sig),
+			"key_version": 2,
+		}})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"errors":[]}`))
+	}
+}
+
+var _ = Describe("LocalSigner", func() {
+	It("produces a verifiable HMAC-SHA256 signature", func() {
+		signer := NewLocalSigner("local-dev-key")
+		sig, err := signer.Sign(context.Background(), []byte("manifest"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(signer.Algorithm()).To(Equal(SigningAlgorithmHMAC))
+
+		again, err := NewLocalSigner("local-dev-key").Sign(context.Background(), []byte("manifest"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(sig).To(Equal(again))
+	})
+})
+
+var _ = Describe("NewSigner", func() {
+	It("returns the LocalSigner by default", func() {
+		signer, err := NewSigner(context.Background(), &Config{KMSKeyID: "k"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(signer).To(BeAssignableToTypeOf(&LocalSigner{}))
+	})
+
+	It("rejects unknown providers", func() {
+		_, err := NewSigner(context.Background(), &Config{KMSProvider: "gcp"})
+		Expect(err).To(MatchError(ContainSubstring("unsupported KMS provider")))
+	})
+})
+
+var _ = Describe("AWSKMSSigner", func() {
+	var (
+		kms    *fakeKMS
+		server *httptest.Server
+		cfg    AWSKMSSignerConfig
+	)
+
+	BeforeEach(func() {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+		kms = &fakeKMS{key: key, keyID: "alias/audit"}
+		server = httptest.NewServer(kms)
+		cfg = AWSKMSSignerConfig{
+			KeyID:       "alias/audit",
+			Region:      "eu-west-1",
+			Endpoint:    server.URL,
+			Credentials: credentials.NewStaticCredentialsProvider("AKIDTEST", "secret", ""),
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("signs the manifest digest and the signature verifies with the public key", func() {
+		signer, err := NewAWSKMSSigner(context.Background(), cfg)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(signer.KeyID()).To(Equal("alias/audit"))
+		Expect(signer.Algorithm()).To(Equal(SigningAlgorithmECDSASHA256))
+
+		data := []byte(`{"event_count":1}`)
+		sig, err := signer.Sign(context.Background(), data)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(kms.requests).To(Equal([]string{awsKMSTargetKey, awsKMSTargetSign}))
+
+		pub, err := signer.PublicKey(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(VerifySignature(pub, signer.Algorithm(), data, sig)).To(Succeed())
+		Expect(VerifySignature(pub, signer.Algorithm(), []byte("tampered"), sig)).NotTo(Succeed())
+
+		pemBytes, err := MarshalPublicKeyPEM(pub)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(pemBytes)).To(HavePrefix("-----BEGIN PUBLIC KEY-----"))
+	})
+
+	It("fails when the key does not support the signing algorithm", func() {
+		cfg.SigningAlgorithm = SigningAlgorithmRSAPSSSHA256
+		_, err := NewAWSKMSSigner(context.Background(), cfg)
+		Expect(err).To(MatchError(ContainSubstring("does not support RSASSA_PSS_SHA_256")))
+	})
+
+	It("surfaces KMS errors", func() {
+		cfg.KeyID = "alias/missing"
+		_, err := NewAWSKMSSigner(context.Background(), cfg)
+		Expect(err).To(MatchError(ContainSubstring("NotFoundException: Key not found")))
+	})
+})
+
+var _ = Describe("VaultTransitSigner", func() {
+	var (
+		vault  *fakeVault
+		server *httptest.Server
+	)
+
+	newSigner := func() (*VaultTransitSigner, error) {
+		return NewVaultTransitSigner(context.Background(), VaultTransitSignerConfig{
+			Address: server.URL,
+			Token:   "root",
+			KeyName: "audit",
+		})
+	}
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	DescribeTable("signs with the pinned key version and the signature verifies with the public key",
+		func(keyType string, generate func() crypto.Signer, algorithm, signPath string) {
+			vault = &fakeVault{keyType: keyType, signer: generate()}
+			server = httptest.NewServer(vault)
+
+			signer, err := newSigner()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(signer.KeyID()).To(Equal("transit/keys/audit:v2"))
+			Expect(signer.Algorithm()).To(Equal(algorithm))
+
+			data := []byte(`{"event_count":1}`)
+			sig, err := signer.Sign(context.Background(), data)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(vault.paths).To(ContainElement("POST " + signPath))
+
+			pub, err := signer.PublicKey(context.Background())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(VerifySignature(pub, signer.Algorithm(), data, sig)).To(Succeed())
+			Expect(VerifySignature(pub, signer.Algorithm(), []byte("tampered"), sig)).NotTo(Succeed())
+		},
+		Entry("ECDSA P-256", "ecdsa-p256", func() crypto.Signer {
+			key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+			return key
+		}, SigningAlgorithmECDSASHA256, "/v1/transit/sign/audit/sha2-256"),
+		Entry("RSA", "rsa-2048", func() crypto.Signer {
+			key, _ := rsa.GenerateKey(rand.Reader, 2048)
+			return key
+		}, SigningAlgorithmRSAPSSSHA256, "/v1/transit/sign/audit/sha2-256"),
+		Entry("Ed25519", "ed25519", func() crypto.Signer {
+			_, key, _ := ed25519.GenerateKey(rand.Reader)
+			return key
+		}, SigningAlgorithmEd25519, "/v1/transit/sign/audit"),
+	)
+
+	It("rejects keys that cannot sign", func() {
+		key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		vault = &fakeVault{keyType: "aes256-gcm96", signer: key}
+		server = httptest.NewServer(vault)
+
+		_, err := newSigner()
+		Expect(err).To(MatchError(ContainSubstring("does not support signing")))
+	})
+
+	It("surfaces Vault errors", func() {
+		key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		vault = &fakeVault{keyType: "ecdsa-p256", signer: key}
+		server = httptest.NewServer(vault)
+
+		_, err := NewVaultTransitSigner(context.Background(), VaultTransitSignerConfig{
+			Address: server.URL,
+			Token:   "wrong",
+			KeyName: "audit",
+		})
+		Expect(err).To(MatchError(ContainSubstring("permission denied (HTTP 403)")))
+	})
+})
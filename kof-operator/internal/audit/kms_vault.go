@@ -0,0 +1,260 @@
+// Copyright 2025
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultVaultTransitMount is the default mount path of the Transit secrets engine.
+const DefaultVaultTransitMount = "transit"
+
+// VaultTransitSignerConfig configures the HashiCorp Vault Transit signer.
+type VaultTransitSignerConfig struct {
+	// Address of the Vault server, e.g. https://vault.example.com:8200.
+	Address string
+	// Token used to authenticate against Vault.
+	Token string
+	// Namespace is the Vault Enterprise namespace (optional).
+	Namespace string
+	// Mount is the mount path of the Transit secrets engine, default "transit".
+	Mount string
+	// KeyName is the name of the Transit key.
+	KeyName string
+	// HTTPClient overrides the default HTTP client.
+	HTTPClient *http.Client
+}
+
+// VaultTransitSigner implements PublicKeySigner with the Vault Transit sign endpoint.
+// The signing algorithm is derived from the Transit key type: ECDSA keys sign
+// with the hash matching their curve, RSA keys use PSS with SHA-256.
+type VaultTransitSigner struct {
+	cfg        VaultTransitSignerConfig
+	algorithm  string
+	hashAlg    string // Transit hash_algorithm, empty for Ed25519
+	sigAlg     string // Transit signature_algorithm, RSA only
+	keyVersion int
+	publicKey  crypto.PublicKey
+}
+
+type vaultResponse[T any] struct {
+	Data   T        `json:"data"`
+	Errors []string `json:"errors"`
+}
+
+type vaultTransitKey struct {
+	Type          string                            `json:"type"`
+	LatestVersion int                               `json:"latest_version"`
+	Keys          map[string]vaultTransitKeyVersion `json:"keys"`
+}
+
+type vaultTransitKeyVersion struct {
+	PublicKey string `json:"public_key"`
+}
+
+type vaultTransitSignRequest struct {
+	Input               string `json:"input"`
+	KeyVersion          int    `json:"key_version,omitempty"`
+	SignatureAlgorithm  string `json:"signature_algorithm,omitempty"`
+	MarshalingAlgorithm string `json:"marshaling_algorithm,omitempty"`
+}
+
+type vaultTransitSignature struct {
+	Signature string `json:"signature"`
+}
+
+// NewVaultTransitSigner creates a VaultTransitSigner. It reads the Transit key
+// to determine the signing algorithm and the public key of its latest version,
+// which is then pinned for all signatures of this signer.
+func NewVaultTransitSigner(ctx context.Context, cfg VaultTransitSignerConfig) (*VaultTransitSigner, error) {
+	if cfg.Address == "" || cfg.Token == "" {
+		return nil, fmt.Errorf("vault address and token are required")
+	}
+	if cfg.KeyName == "" {
+		return nil, fmt.Errorf("vault transit key name is required")
+	}
+	if cfg.Mount == "" {
+		cfg.Mount = DefaultVaultTransitMount
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	s := &VaultTransitSigner{cfg: cfg}
+
+	key := new(vaultResponse[vaultTransitKey])
+	if err := s.call(ctx, http.MethodGet, path.Join(cfg.Mount, "keys", cfg.KeyName), nil, key); err != nil {
+		return nil, fmt.Errorf("read transit key %q: %w", cfg.KeyName, err)
+	}
+
+	switch key.Data.Type {
+	case "ecdsa-p256":
+		s.algorithm, s.hashAlg = SigningAlgorithmECDSASHA256, "sha2-256"
+	case "ecdsa-p384":
+		s.algorithm, s.hashAlg = SigningAlgorithmECDSASHA384, "sha2-384"
+	case "ecdsa-p521":
+		s.algorithm, s.hashAlg = SigningAlgorithmECDSASHA512, "sha2-512"
+	case "rsa-2048", "rsa-3072", "rsa-4096":
+		s.algorithm, s.hashAlg, s.sigAlg = SigningAlgorithmRSAPSSSHA256, "sha2-256", "pss"
+	case "ed25519":
+		s.algorithm = SigningAlgorithmEd25519
+	default:
+		return nil, fmt.Errorf("transit key %q of type %q does not support signing", cfg.KeyName, key.Data.Type)
+	}
+
+	s.keyVersion = key.Data.LatestVersion
+	version, ok := key.Data.Keys[strconv.Itoa(s.keyVersion)]
+	if !ok {
+		return nil, fmt.Errorf("transit key %q has no version %d", cfg.KeyName, s.keyVersion)
+	}
+	publicKey, err := parseVaultPublicKey(key.Data.Type, version.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("parse public key of %q: %w", cfg.KeyName, err)
+	}
+	s.publicKey = publicKey
+
+	return s, nil
+}
+
+func (s *VaultTransitSigner) Sign(ctx context.Context, data []byte) ([]byte, error) {
+	signPath := path.Join(s.cfg.Mount, "sign", s.cfg.KeyName)
+	if s.hashAlg != "" {
+		signPath = path.Join(signPath, s.hashAlg)
+	}
+
+	req := vaultTransitSignRequest{
+		Input:              base64.StdEncoding.EncodeToString(data),
+		KeyVersion:         s.keyVersion,
+		SignatureAlgorithm: s.sigAlg,
+	}
+	if strings.HasPrefix(s.algorithm, "ECDSA_") {
+		req.MarshalingAlgorithm = "asn1"
+	}
+
+	out := new(vaultResponse[vaultTransitSignature])
+	if err := s.call(ctx, http.MethodPost, signPath, req, out); err != nil {
+		return nil, fmt.Errorf("vault sign: %w", err)
+	}
+
+	// Transit signatures are formatted as "vault:v<version>:<base64>".
+	parts := strings.SplitN(out.Data.Signature, ":", 3)
+	if len(parts) != 3 || parts[0] != "vault" {
+		return nil, fmt.Errorf("vault sign: unexpected signature format")
+	}
+	sig, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("vault sign: decode signature: %w", err)
+	}
+	return encodeSignature(sig), nil
+}
+
+// KeyID returns the Transit key reference including the pinned key version,
+// e.g. "transit/keys/audit:v3".
+func (s *VaultTransitSigner) KeyID() string {
+	return fmt.Sprintf("%s:v%d", path.Join(s.cfg.Mount, "keys", s.cfg.KeyName), s.keyVersion)
+}
+
+func (s *VaultTransitSigner) Algorithm() string { return s.algorithm }
+
+func (s *VaultTransitSigner) PublicKey(_ context.Context) (crypto.PublicKey, error) {
+	return s.publicKey, nil
+}
+
+// call sends a request to the Vault HTTP API and decodes the response into out.
+func (s *VaultTransitSigner) call(ctx context.Context, method, apiPath string, in any, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("marshal request: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	reqURL, err := url.JoinPath(s.cfg.Address, "v1", apiPath)
+	if err != nil {
+		return fmt.Errorf("build URL: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, reqURL, body)
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", s.cfg.Token)
+	if s.cfg.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", s.cfg.Namespace)
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := s.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		vaultErr := new(vaultResponse[json.RawMessage])
+		if json.Unmarshal(respBody, vaultErr) == nil && len(vaultErr.Errors) > 0 {
+			return fmt.Errorf("%s (HTTP %d)", strings.Join(vaultErr.Errors, "; "), resp.StatusCode)
+		}
+		return fmt.Errorf("unexpected response status: %s", resp.Status)
+	}
+
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+// parseVaultPublicKey parses a Transit public key: PEM-encoded PKIX for ECDSA
+// and RSA keys, base64-encoded raw bytes for Ed25519 keys.
+func parseVaultPublicKey(keyType, raw string) (crypto.PublicKey, error) {
+	if keyType == "ed25519" {
+		key, err := base64.StdEncoding.DecodeString(raw)
+		if err != nil {
+			return nil, err
+		}
+		if len(key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 public key size %d", len(key))
+		}
+		return ed25519.PublicKey(key), nil
+	}
+
+	block, _ := pem.Decode([]byte(raw))
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
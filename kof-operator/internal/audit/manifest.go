@@ -58,6 +58,7 @@ func (e *Exporter) exportWindow(ctx context.Context, w ExportWindow) error {
 	}
 	if exists {
 		log.Info("window already exported, skipping")
+		e.report.Skipped(w.Stream, w.Tenant, w.Start)
 		return nil
 	}
 
@@ -150,6 +151,7 @@ func (e *Exporter) exportWindow(ctx context.Context, w ExportWindow) error {
 	}
 
 	log.Info("window exported successfully", "events", stats.eventCount, "size_bytes", stats.sizeBytes)
+	e.report.Exported(w.Stream, w.Tenant, w.Start, int64(stats.eventCount), stats.sizeBytes)
 	return nil
 }
 
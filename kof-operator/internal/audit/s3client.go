@@ -16,20 +16,16 @@ package audit
 
 import (
 	"context"
-	"errors"
 	"fmt"
-	"io"
-	"net/http"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
-	smithyhttp "github.com/aws/smithy-go/transport/http"
 	s3pkg "github.com/k0rdent/kof/kof-operator/internal/s3"
 )
 
 // S3Client extends s3pkg.Client with audit-specific operations
-// (compliance / WORM checking and direct object retrieval).
+// (compliance / WORM checking).
 type S3Client struct {
 	*s3pkg.Client
 }
@@ -84,30 +80,3 @@ func (c *S3Client) PreflightBucket(ctx context.Context, complianceMode bool) (wa
 	}
 	return "", nil
 }
-
-// GetObject downloads an object and returns its raw bytes.
-// Returns (nil, nil) when the object does not exist.
-func (c *S3Client) GetObject(ctx context.Context, key string) ([]byte, error) {
-	out, err := c.Raw().GetObject(ctx, &s3.GetObjectInput{
-		Bucket: aws.String(c.Bucket()),
-		Key:    aws.String(key),
-	})
-	if err != nil {
-		var nsk *types.NoSuchKey
-		if errors.As(err, &nsk) {
-			return nil, nil
-		}
-		var notFound *types.NotFound
-		if errors.As(err, &notFound) {
-			return nil, nil
-		}
-		// S3-compatible backends may surface a missing key as an untyped HTTP 404.
-		var respErr *smithyhttp.ResponseError
-		if errors.As(err, &respErr) && respErr.HTTPStatusCode() == http.StatusNotFound {
-			return nil, nil
-		}
-		return nil, fmt.Errorf("GetObject %q: %w", key, err)
-	}
-	defer func() { _ = out.Body.Close() }()
-	return io.ReadAll(out.Body)
-}
@@ -60,6 +60,15 @@ func (s *stubS3) GetObject(_ context.Context, _ *s3.GetObjectInput, _ ...func(*s
 func (s *stubS3) PutObject(_ context.Context, _ *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
 	panic("not implemented")
 }
+func (s *stubS3) ListObjectsV2(_ context.Context, _ *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	panic("not implemented")
+}
+func (s *stubS3) ListObjectVersions(_ context.Context, _ *s3.ListObjectVersionsInput, _ ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error) {
+	panic("not implemented")
+}
+func (s *stubS3) DeleteObjects(_ context.Context, _ *s3.DeleteObjectsInput, _ ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+	panic("not implemented")
+}
 
 // Compile-time checks.
 var _ s3pkg.RawAPI = (*stubS3)(nil)
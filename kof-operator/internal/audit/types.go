@@ -28,9 +28,22 @@ const (
 	// TenantPlatform is the tenant value used for the platform audit log stream.
 	TenantPlatform = "PLATFORM"
 
-	ManifestVersion      = "1"
-	EventSchemaVersion   = "1"
-	SigningAlgorithmHMAC = "HMAC-SHA256"
+	ManifestVersion    = "1"
+	EventSchemaVersion = "1"
+
+	// Signing algorithms embedded in the manifest. Asymmetric algorithms use the
+	// AWS KMS names; the Vault Transit signer maps its key types onto them.
+	SigningAlgorithmHMAC              = "HMAC-SHA256"
+	SigningAlgorithmECDSASHA256       = "ECDSA_SHA_256"
+	SigningAlgorithmECDSASHA384       = "ECDSA_SHA_384"
+	SigningAlgorithmECDSASHA512       = "ECDSA_SHA_512"
+	SigningAlgorithmRSAPSSSHA256      = "RSASSA_PSS_SHA_256"
+	SigningAlgorithmRSAPSSSHA384      = "RSASSA_PSS_SHA_384"
+	SigningAlgorithmRSAPSSSHA512      = "RSASSA_PSS_SHA_512"
+	SigningAlgorithmRSAPKCS1v15SHA256 = "RSASSA_PKCS1_V1_5_SHA_256"
+	SigningAlgorithmRSAPKCS1v15SHA384 = "RSASSA_PKCS1_V1_5_SHA_384"
+	SigningAlgorithmRSAPKCS1v15SHA512 = "RSASSA_PKCS1_V1_5_SHA_512"
+	SigningAlgorithmEd25519           = "ED25519"
 )
 
 // msTime wraps time.Time and serialises to UTC millisecond precision.
@@ -194,12 +207,14 @@ type ExportWindow struct {
 // S3Key returns the S3 key prefix for this window.
 // Layout: <prefix>/<stream>/<tenant>/YYYY/MM/DD/HH/
 func (w ExportWindow) S3KeyPrefix(prefix string) string {
+	return w.tenantPrefix(prefix) + w.Start.UTC().Format("2006/01/02/15") + "/"
+}
+
+// tenantPrefix returns the S3 key prefix of all windows of the stream and tenant.
+// Layout: <prefix>/<stream>/<tenant>/
+func (w ExportWindow) tenantPrefix(prefix string) string {
 	if prefix != "" {
-		return fmt.Sprintf("%s/%s/%s/%s/",
-			prefix, w.Stream, w.Tenant,
-			w.Start.UTC().Format("2006/01/02/15"))
+		return fmt.Sprintf("%s/%s/%s/", prefix, w.Stream, w.Tenant)
 	}
-	return fmt.Sprintf("%s/%s/%s/",
-		w.Stream, w.Tenant,
-		w.Start.UTC().Format("2006/01/02/15"))
+	return fmt.Sprintf("%s/%s/", w.Stream, w.Tenant)
 }
@@ -0,0 +1,390 @@
+// Copyright 2025
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/k0rdent/kof/kof-operator/internal/retention"
+)
+
+// Window verification statuses, in increasing order of severity.
+const (
+	WindowStatusOK         = "ok"
+	WindowStatusMissing    = "missing"
+	WindowStatusIncomplete = "incomplete"
+	WindowStatusBrokenLink = "broken_link"
+	WindowStatusTampered   = "tampered"
+)
+
+var windowStatusSeverity = map[string]int{
+	WindowStatusOK:         0,
+	WindowStatusMissing:    1,
+	WindowStatusIncomplete: 2,
+	WindowStatusBrokenLink: 3,
+	WindowStatusTampered:   4,
+}
+
+// VerifyOptions selects the archived windows to verify.
+type VerifyOptions struct {
+	Stream string
+	Tenant string
+	// From is the start of the first window (truncated to the hour).
+	From time.Time
+	// To is the exclusive end of the range.
+	To time.Time
+}
+
+// VerifyReport is the machine-readable result of an archive verification.
+type VerifyReport struct {
+	Stream     string          `json:"stream"`
+	Tenant     string          `json:"tenant"`
+	From       msTime          `json:"from"`
+	To         msTime          `json:"to"`
+	VerifiedAt msTime          `json:"verified_at"`
+	Summary    VerifySummary   `json:"summary"`
+	Windows    []WindowVerdict `json:"windows"`
+	// RetentionAnchor is set when the window preceding the range was deleted
+	// by retention and its recorded manifest hash was used as the first link.
+	RetentionAnchor *retention.Anchor `json:"retention_anchor,omitempty"`
+}
+
+// VerifySummary counts the verified windows by status.
+type VerifySummary struct {
+	Windows    int `json:"windows"`
+	OK         int `json:"ok"`
+	Missing    int `json:"missing"`
+	Incomplete int `json:"incomplete"`
+	BrokenLink int `json:"broken_link"`
+	Tampered   int `json:"tampered"`
+}
+
+// WindowVerdict is the verification result of a single hourly window.
+type WindowVerdict struct {
+	WindowStart    msTime   `json:"window_start"`
+	Prefix         string   `json:"prefix"`
+	Status         string   `json:"status"`
+	EventCount     int      `json:"event_count"`
+	ManifestSHA256 string   `json:"manifest_sha256,omitempty"`
+	Problems       []string `json:"problems,omitempty"`
+}
+
+// OK reports whether every window in the range is present and intact.
+func (r *VerifyReport) OK() bool {
+	return r.Summary.Windows == r.Summary.OK
+}
+
+// ArchiveVerifier reads exported windows back from S3 and checks them against
+// their manifests, signatures and the previous_manifest_sha256 chain.
+type ArchiveVerifier struct {
+	cfg      *Config
+	s3       *S3Client
+	verifier Verifier
+	log      logr.Logger
+}
+
+// NewArchiveVerifier creates an ArchiveVerifier checking signatures with verifier.
+func NewArchiveVerifier(cfg *Config, s3client *S3Client, verifier Verifier, log logr.Logger) *ArchiveVerifier {
+	return &ArchiveVerifier{cfg: cfg, s3: s3client, verifier: verifier, log: log}
+}
+
+// Verify checks every window of the stream and tenant in [opts.From, opts.To).
+// The window preceding the range is read as well to check the first chain link.
+// When that window was deleted by retention, the manifest hash recorded in
+// the retention anchor of the stream and tenant is used instead, so the
+// oldest retained window still verifies.
+// An error is returned only when the archive cannot be read; integrity
+// problems are reported per window.
+func (v *ArchiveVerifier) Verify(ctx context.Context, opts VerifyOptions) (*VerifyReport, error) {
+	from := opts.From.UTC().Truncate(time.Hour)
+	to := opts.To.UTC()
+	if !from.Before(to) {
+		return nil, fmt.Errorf("empty time range [%s, %s)", from.Format(time.RFC3339), to.Format(time.RFC3339))
+	}
+
+	objects, err := v.listWindowObjects(ctx, opts.Stream, opts.Tenant, from.Add(-time.Hour), to)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &VerifyReport{
+		Stream:     opts.Stream,
+		Tenant:     opts.Tenant,
+		From:       msTime{from},
+		To:         msTime{to},
+		VerifiedAt: msTime{time.Now().UTC()},
+		Windows:    make([]WindowVerdict, 0),
+	}
+
+	prev := ExportWindow{Stream: opts.Stream, Tenant: opts.Tenant, Start: from.Add(-time.Hour), End: from}
+	prevManifestSHA256, err := v.manifestSHA256(ctx, prev, objects)
+	if err != nil {
+		return nil, err
+	}
+	if prevManifestSHA256 == "" {
+		anchorKey := prev.tenantPrefix(v.cfg.S3Prefix) + retention.AnchorName
+		anchor, err := retention.GetAnchor(ctx, v.s3.Client, anchorKey)
+		if err != nil {
+			return nil, err
+		}
+		if anchor != nil && anchor.WindowEnd.Equal(from) {
+			report.RetentionAnchor = anchor
+			prevManifestSHA256 = anchor.MarkerSHA256
+		}
+	}
+
+	for start := from; start.Before(to); start = start.Add(time.Hour) {
+		w := ExportWindow{Stream: opts.Stream, Tenant: opts.Tenant, Start: start, End: start.Add(time.Hour)}
+		verdict, err := v.verifyWindow(ctx, w, objects[start], prevManifestSHA256)
+		if err != nil {
+			return nil, err
+		}
+		v.log.V(1).Info("verified window", "window", start.Format("2006-01-02T15:04Z"), "status", verdict.Status)
+
+		report.Windows = append(report.Windows, verdict)
+		report.Summary.add(verdict.Status)
+		prevManifestSHA256 = verdict.ManifestSHA256
+	}
+
+	return report, nil
+}
+
+// listWindowObjects lists the objects of every window in [from, to), keyed by
+// window start and then by file name. Listing is done one day at a time.
+func (v *ArchiveVerifier) listWindowObjects(
+	ctx context.Context, stream, tenant string, from, to time.Time,
+) (map[time.Time]map[string]bool, error) {
+	objects := make(map[time.Time]map[string]bool)
+	tenantPrefix := ExportWindow{Stream: stream, Tenant: tenant}.tenantPrefix(v.cfg.S3Prefix)
+
+	for day := from.Truncate(24 * time.Hour); day.Before(to); day = day.Add(24 * time.Hour) {
+		dayPrefix := tenantPrefix + day.Format("2006/01/02") + "/"
+		keys, err := v.s3.ListKeys(ctx, dayPrefix)
+		if err != nil {
+			return nil, fmt.Errorf("list %q: %w", dayPrefix, err)
+		}
+		for _, key := range keys {
+			hour, name, ok := strings.Cut(strings.TrimPrefix(key, dayPrefix), "/")
+			if !ok || strings.Contains(name, "/") {
+				continue
+			}
+			start, err := time.Parse("2006/01/02/15", day.Format("2006/01/02/")+hour)
+			if err != nil {
+				continue
+			}
+			if objects[start] == nil {
+				objects[start] = make(map[string]bool)
+			}
+			objects[start][name] = true
+		}
+	}
+	return objects, nil
+}
+
+// manifestSHA256 returns the SHA-256 of the window manifest, or "" when it does not exist.
+func (v *ArchiveVerifier) manifestSHA256(
+	ctx context.Context, w ExportWindow, objects map[time.Time]map[string]bool,
+) (string, error) {
+	if !objects[w.Start]["manifest.json"] {
+		return "", nil
+	}
+	data, err := v.s3.GetObject(ctx, w.S3KeyPrefix(v.cfg.S3Prefix)+"manifest.json")
+	if err != nil {
+		return "", err
+	}
+	if data == nil {
+		return "", nil
+	}
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:]), nil
+}
+
+func (v *ArchiveVerifier) verifyWindow(
+	ctx context.Context, w ExportWindow, files map[string]bool, prevManifestSHA256 string,
+) (WindowVerdict, error) {
+	prefix := w.S3KeyPrefix(v.cfg.S3Prefix)
+	verdict := WindowVerdict{
+		WindowStart: msTime{w.Start},
+		Prefix:      prefix,
+		Status:      WindowStatusOK,
+	}
+
+	if len(files) == 0 {
+		verdict.fail(WindowStatusMissing, "window was not exported")
+		return verdict, nil
+	}
+	if !files["manifest.json"] {
+		verdict.fail(WindowStatusIncomplete, "manifest.json is missing, the export was interrupted")
+		return verdict, nil
+	}
+
+	manifestBytes, err := v.s3.GetObject(ctx, prefix+"manifest.json")
+	if err != nil {
+		return verdict, err
+	}
+	manifestHash := sha256.Sum256(manifestBytes)
+	verdict.ManifestSHA256 = hex.EncodeToString(manifestHash[:])
+
+	manifest := new(Manifest)
+	if err := json.Unmarshal(manifestBytes, manifest); err != nil {
+		verdict.fail(WindowStatusTampered, fmt.Sprintf("manifest.json is not valid: %v", err))
+		return verdict, nil
+	}
+	verdict.EventCount = manifest.EventCount
+
+	if manifest.Stream != w.Stream || manifest.Tenant != w.Tenant ||
+		!manifest.WindowStart.Equal(w.Start) || !manifest.WindowEnd.Equal(w.End) {
+		verdict.fail(WindowStatusTampered, fmt.Sprintf(
+			"manifest describes window %s/%s [%s, %s)", manifest.Stream, manifest.Tenant,
+			manifest.WindowStart.Format(time.RFC3339), manifest.WindowEnd.Format(time.RFC3339)))
+	}
+
+	if err := v.verifySignature(ctx, prefix, files, manifest, manifestBytes); err != nil {
+		verdict.fail(WindowStatusTampered, err.Error())
+	}
+
+	if err := v.verifyDataFile(ctx, prefix, files, manifest); err != nil {
+		verdict.fail(WindowStatusTampered, err.Error())
+	}
+
+	if manifest.PreviousManifestSHA256 != prevManifestSHA256 {
+		switch {
+		case prevManifestSHA256 == "":
+			verdict.fail(WindowStatusBrokenLink, "previous_manifest_sha256 points to a window that does not exist")
+		case manifest.PreviousManifestSHA256 == "":
+			verdict.fail(WindowStatusBrokenLink, "previous_manifest_sha256 is empty but the previous window exists")
+		default:
+			verdict.fail(WindowStatusBrokenLink, fmt.Sprintf(
+				"previous_manifest_sha256 %s does not match the previous manifest %s",
+				manifest.PreviousManifestSHA256, prevManifestSHA256))
+		}
+	}
+
+	return verdict, nil
+}
+
+func (v *ArchiveVerifier) verifySignature(
+	ctx context.Context, prefix string, files map[string]bool, manifest *Manifest, manifestBytes []byte,
+) error {
+	if !files["manifest.json.sig"] {
+		return fmt.Errorf("manifest.json.sig is missing")
+	}
+	sig, err := v.s3.GetObject(ctx, prefix+"manifest.json.sig")
+	if err != nil {
+		return fmt.Errorf("read manifest.json.sig: %w", err)
+	}
+	if err := v.verifier.Verify(ctx, manifest.SigningAlgorithm, manifestBytes, sig); err != nil {
+		return fmt.Errorf("signature of manifest.json (key %s, %s): %w",
+			manifest.KMSKeyID, manifest.SigningAlgorithm, err)
+	}
+	return nil
+}
+
+func (v *ArchiveVerifier) verifyDataFile(ctx context.Context, prefix string, files map[string]bool, manifest *Manifest) error {
+	name := manifest.DataFile.Name
+	if name == "" || path.Base(name) != name {
+		return fmt.Errorf("manifest data file name %q is invalid", name)
+	}
+	if !files[name] {
+		return fmt.Errorf("%s is missing", name)
+	}
+
+	data, err := v.s3.GetObject(ctx, prefix+name)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", name, err)
+	}
+
+	var problems []string
+	if size := int64(len(data)); size != manifest.DataFile.SizeBytes {
+		problems = append(problems, fmt.Sprintf("size %d != manifest %d", size, manifest.DataFile.SizeBytes))
+	}
+	h := sha256.Sum256(data)
+	if sum := hex.EncodeToString(h[:]); sum != manifest.DataFile.SHA256 {
+		problems = append(problems, fmt.Sprintf("sha256 %s != manifest %s", sum, manifest.DataFile.SHA256))
+	}
+	count, err := countEvents(data)
+	if err != nil {
+		problems = append(problems, err.Error())
+	} else if count != manifest.EventCount {
+		problems = append(problems, fmt.Sprintf("event count %d != manifest %d", count, manifest.EventCount))
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%s: %s", name, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// countEvents returns the number of non-empty JSON lines in gzip-compressed data.
+func countEvents(data []byte) (int, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return 0, fmt.Errorf("open gzip: %w", err)
+	}
+	defer func() { _ = gz.Close() }()
+
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	count := 0
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if !json.Valid(line) {
+			return 0, fmt.Errorf("line %d is not valid JSON", count+1)
+		}
+		count++
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("read gzip: %w", err)
+	}
+	return count, nil
+}
+
+// fail records a problem and raises the verdict status to at least status.
+func (v *WindowVerdict) fail(status, problem string) {
+	if windowStatusSeverity[status] > windowStatusSeverity[v.Status] {
+		v.Status = status
+	}
+	v.Problems = append(v.Problems, problem)
+}
+
+func (s *VerifySummary) add(status string) {
+	s.Windows++
+	switch status {
+	case WindowStatusOK:
+		s.OK++
+	case WindowStatusMissing:
+		s.Missing++
+	case WindowStatusIncomplete:
+		s.Incomplete++
+	case WindowStatusBrokenLink:
+		s.BrokenLink++
+	case WindowStatusTampered:
+		s.Tampered++
+	}
+}
@@ -0,0 +1,294 @@
+// Copyright 2025
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/go-logr/logr"
+	"github.com/k0rdent/kof/kof-operator/internal/retention"
+	s3pkg "github.com/k0rdent/kof/kof-operator/internal/s3"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// memS3 implements s3pkg.RawAPI with an in-memory object store.
+type memS3 struct {
+	objects map[string][]byte
+}
+
+func newMemS3() *memS3 {
+	return &memS3{objects: make(map[string][]byte)}
+}
+
+func (m *memS3) GetObjectLockConfiguration(_ context.Context, _ *s3.GetObjectLockConfigurationInput, _ ...func(*s3.Options)) (*s3.GetObjectLockConfigurationOutput, error) {
+	return &s3.GetObjectLockConfigurationOutput{}, nil
+}
+func (m *memS3) HeadObject(_ context.Context, params *s3.HeadObjectInput, _ ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	if _, ok := m.objects[aws.ToString(params.Key)]; ok {
+		return &s3.HeadObjectOutput{}, nil
+	}
+	return nil, &types.NotFound{}
+}
+func (m *memS3) GetObject(_ context.Context, params *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	data, ok := m.objects[aws.ToString(params.Key)]
+	if !ok {
+		return nil, &types.NoSuchKey{}
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(data))}, nil
+}
+func (m *memS3) PutObject(_ context.Context, params *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	data, err := io.ReadAll(params.Body)
+	if err != nil {
+		return nil, err
+	}
+	m.objects[aws.ToString(params.Key)] = data
+	return &s3.PutObjectOutput{}, nil
+}
+func (m *memS3) ListObjectsV2(_ context.Context, params *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	keys := make([]string, 0)
+	for key := range m.objects {
+		if strings.HasPrefix(key, aws.ToString(params.Prefix)) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	out := &s3.ListObjectsV2Output{IsTruncated: aws.Bool(false)}
+	for _, key := range keys {
+		out.Contents = append(out.Contents, types.Object{Key: aws.String(key)})
+	}
+	return out, nil
+}
+
+func (m *memS3) ListObjectVersions(_ context.Context, _ *s3.ListObjectVersionsInput, _ ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error) {
+	panic("not implemented")
+}
+func (m *memS3) DeleteObjects(_ context.Context, params *s3.DeleteObjectsInput, _ ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+	for _, obj := range params.Delete.Objects {
+		delete(m.objects, aws.ToString(obj.Key))
+	}
+	return &s3.DeleteObjectsOutput{}, nil
+}
+
+var _ s3pkg.RawAPI = (*memS3)(nil)
+
+var _ = Describe("ArchiveVerifier", func() {
+	const (
+		stream = StreamTenantAuditLog
+		tenant = "acme"
+	)
+
+	var (
+		ctx    context.Context
+		cfg    *Config
+		store  *memS3
+		signer Signer
+		h10    = time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	)
+
+	window := func(start time.Time) ExportWindow {
+		return ExportWindow{Stream: stream, Tenant: tenant, Start: start, End: start.Add(time.Hour)}
+	}
+
+	// putWindow writes a window the way exportWindow does and returns the
+	// SHA-256 of its manifest for chaining the next window.
+	putWindow := func(start time.Time, prevManifestSHA256 string, events int) string {
+		var lines strings.Builder
+		for i := range events {
+			lines.WriteString(`{"time":"2025-01-01T10:00:00.000Z","event_id":"e` + string(rune('a'+i)) + `","tenant":"acme"}` + "\n")
+		}
+		var data bytes.Buffer
+		stats, err := compressEvents(strings.NewReader(lines.String()), &data)
+		Expect(err).NotTo(HaveOccurred())
+
+		w := window(start)
+		manifestBytes, err := marshalCanonical(Manifest{
+			DataFile:               DataFileMeta{Name: "data.jsonl.gz", SHA256: stats.sha256hex, SizeBytes: stats.sizeBytes},
+			EventCount:             stats.eventCount,
+			KMSKeyID:               signer.KeyID(),
+			PreviousManifestSHA256: prevManifestSHA256,
+			SigningAlgorithm:       signer.Algorithm(),
+			Stream:                 w.Stream,
+			Tenant:                 w.Tenant,
+			WindowEnd:              msTime{w.End},
+			WindowStart:            msTime{w.Start},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		sig, err := signer.Sign(ctx, manifestBytes)
+		Expect(err).NotTo(HaveOccurred())
+
+		prefix := w.S3KeyPrefix(cfg.S3Prefix)
+		store.objects[prefix+"data.jsonl.gz"] = data.Bytes()
+		store.objects[prefix+"manifest.json.sig"] = sig
+		store.objects[prefix+"manifest.json"] = manifestBytes
+
+		h := sha256.Sum256(manifestBytes)
+		return hex.EncodeToString(h[:])
+	}
+
+	verify := func(verifier Verifier, from, to time.Time) *VerifyReport {
+		client := &S3Client{Client: s3pkg.NewClientFromRaw(store, cfg.S3Bucket)}
+		v := NewArchiveVerifier(cfg, client, verifier, logr.Discard())
+		report, err := v.Verify(ctx, VerifyOptions{Stream: stream, Tenant: tenant, From: from, To: to})
+		Expect(err).NotTo(HaveOccurred())
+		return report
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		cfg = baseConfig([]string{stream}, []string{tenant})
+		store = newMemS3()
+		signer = NewLocalSigner("test-key")
+	})
+
+	It("reports an intact chain as ok", func() {
+		prev := putWindow(h10, "", 2)
+		prev = putWindow(h10.Add(time.Hour), prev, 3)
+		putWindow(h10.Add(2*time.Hour), prev, 0)
+
+		report := verify(signer.(Verifier), h10, h10.Add(3*time.Hour))
+		Expect(report.OK()).To(BeTrue())
+		Expect(report.Summary).To(Equal(VerifySummary{Windows: 3, OK: 3}))
+		Expect(report.Windows[1].EventCount).To(Equal(3))
+		Expect(report.Windows[1].Prefix).To(Equal("audit/tenant-audit-log/acme/2025/01/01/11/"))
+	})
+
+	It("verifies asymmetric signatures with a public key", func() {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+		signer = &testECDSASigner{key: key}
+		putWindow(h10, "", 1)
+
+		report := verify(&PublicKeyVerifier{Key: &key.PublicKey}, h10, h10.Add(time.Hour))
+		Expect(report.OK()).To(BeTrue())
+
+		other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+		report = verify(&PublicKeyVerifier{Key: &other.PublicKey}, h10, h10.Add(time.Hour))
+		Expect(report.Windows[0].Status).To(Equal(WindowStatusTampered))
+	})
+
+	It("links the first window to the manifest preceding the range", func() {
+		prev := putWindow(h10, "", 1)
+		putWindow(h10.Add(time.Hour), prev, 1)
+
+		report := verify(signer.(Verifier), h10.Add(time.Hour), h10.Add(2*time.Hour))
+		Expect(report.OK()).To(BeTrue())
+		Expect(report.Windows).To(HaveLen(1))
+	})
+
+	It("links the oldest retained window to the retention anchor", func() {
+		prev := putWindow(h10, "", 1)
+		putWindow(h10.Add(time.Hour), prev, 1)
+		for name := range store.objects {
+			if strings.HasPrefix(name, window(h10).S3KeyPrefix(cfg.S3Prefix)) {
+				delete(store.objects, name)
+			}
+		}
+
+		report := verify(signer.(Verifier), h10.Add(time.Hour), h10.Add(2*time.Hour))
+		Expect(report.Windows[0].Status).To(Equal(WindowStatusBrokenLink))
+
+		anchor, err := json.Marshal(retention.Anchor{WindowEnd: h10.Add(time.Hour), MarkerSHA256: prev})
+		Expect(err).NotTo(HaveOccurred())
+		store.objects["audit/tenant-audit-log/acme/"+retention.AnchorName] = anchor
+
+		report = verify(signer.(Verifier), h10.Add(time.Hour), h10.Add(2*time.Hour))
+		Expect(report.OK()).To(BeTrue())
+		Expect(report.RetentionAnchor).NotTo(BeNil())
+		Expect(report.RetentionAnchor.MarkerSHA256).To(Equal(prev))
+	})
+
+	It("reports missing and incomplete windows", func() {
+		putWindow(h10, "", 1)
+		w12 := window(h10.Add(2 * time.Hour))
+		store.objects[w12.S3KeyPrefix(cfg.S3Prefix)+"data.jsonl.gz"] = []byte("partial")
+
+		report := verify(signer.(Verifier), h10, h10.Add(3*time.Hour))
+		Expect(report.OK()).To(BeFalse())
+		Expect(report.Summary).To(Equal(VerifySummary{Windows: 3, OK: 1, Missing: 1, Incomplete: 1}))
+		Expect(report.Windows[1].Status).To(Equal(WindowStatusMissing))
+		Expect(report.Windows[2].Status).To(Equal(WindowStatusIncomplete))
+	})
+
+	It("reports tampered data files", func() {
+		putWindow(h10, "", 2)
+		key := window(h10).S3KeyPrefix(cfg.S3Prefix) + "data.jsonl.gz"
+		var data bytes.Buffer
+		_, err := compressEvents(strings.NewReader(`{"time":"2025-01-01T10:00:00.000Z","event_id":"x"}`+"\n"), &data)
+		Expect(err).NotTo(HaveOccurred())
+		store.objects[key] = data.Bytes()
+
+		report := verify(signer.(Verifier), h10, h10.Add(time.Hour))
+		Expect(report.Windows[0].Status).To(Equal(WindowStatusTampered))
+		Expect(report.Windows[0].Problems).To(ContainElement(And(
+			ContainSubstring("sha256"), ContainSubstring("event count 1 != manifest 2"))))
+	})
+
+	It("reports tampered manifests through the signature", func() {
+		putWindow(h10, "", 2)
+		key := window(h10).S3KeyPrefix(cfg.S3Prefix) + "manifest.json"
+		store.objects[key] = bytes.Replace(store.objects[key], []byte(`"event_count":2`), []byte(`"event_count":3`), 1)
+
+		report := verify(signer.(Verifier), h10, h10.Add(time.Hour))
+		Expect(report.Windows[0].Status).To(Equal(WindowStatusTampered))
+		Expect(report.Windows[0].Problems).To(ContainElement(ContainSubstring("signature")))
+	})
+
+	It("reports broken chain links", func() {
+		putWindow(h10, "", 1)
+		putWindow(h10.Add(time.Hour), strings.Repeat("0", 64), 1)
+		putWindow(h10.Add(3*time.Hour), strings.Repeat("1", 64), 1)
+
+		report := verify(signer.(Verifier), h10, h10.Add(4*time.Hour))
+		Expect(report.Summary).To(Equal(VerifySummary{Windows: 4, OK: 1, Missing: 1, BrokenLink: 2}))
+		Expect(report.Windows[1].Status).To(Equal(WindowStatusBrokenLink))
+		Expect(report.Windows[3].Problems).To(ConsistOf(ContainSubstring("does not exist")))
+
+		out, err := json.Marshal(report)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(out)).To(ContainSubstring(`"status":"broken_link"`))
+	})
+})
+
+// testECDSASigner signs manifests with an in-memory ECDSA P-256 key.
+type testECDSASigner struct {
+	key *ecdsa.PrivateKey
+}
+
+func (s *testECDSASigner) Sign(_ context.Context, data []byte) ([]byte, error) {
+	digest := sha256.Sum256(data)
+	sig, err := ecdsa.SignASN1(rand.Reader, s.key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+	return encodeSignature(sig), nil
+}
+func (s *testECDSASigner) KeyID() string     { return "test-ecdsa" }
+func (s *testECDSASigner) Algorithm() string { return SigningAlgorithmECDSASHA256 }
@@ -208,6 +208,67 @@ func flatToAuditEvent(f map[string]string) (AuditEvent, error) {
 	}, nil
 }
 
+// Flatten converts the event into the flat dot-notation fields VictoriaLogs
+// stores, the inverse of flatToAuditEvent: producers emit them as the
+// attributes of OTLP log records. The time is omitted when zero, as the
+// timestamp of the log record is stored as _time.
+func (e AuditEvent) Flatten() map[string]string {
+	f := map[string]string{
+		"action.outcome":               e.Action.Outcome,
+		"action.verb":                  e.Action.Verb,
+		"actor.id":                     e.Actor.ID,
+		"actor.type":                   e.Actor.Type,
+		"audit_policy_version":         e.AuditPolicyVersion,
+		"authorization.decision":       e.Authorization.Decision,
+		"authorization.policy":         e.Authorization.Policy,
+		"authorization.reason":         e.Authorization.Reason,
+		"cluster_audit_policy_version": e.ClusterAuditPolicyVersion,
+		"correlation_id":               e.CorrelationID,
+		"event_id":                     e.EventID,
+		"justification":                e.Justification,
+		"schema_version":               e.SchemaVersion,
+		"source.ip":                    e.Source.IP,
+		"source.origin":                e.Source.Origin,
+		"source.user_agent":            e.Source.UserAgent,
+		"target.apiVersion":            e.Target.APIVersion,
+		"target.cluster":               e.Target.Cluster,
+		"target.kind":                  e.Target.Kind,
+		"target.name":                  e.Target.Name,
+		"target.namespace":             e.Target.Namespace,
+		"target.subresource":           e.Target.Subresource,
+		"tenant":                       e.Tenant,
+	}
+	if !e.Time.IsZero() {
+		b, _ := e.Time.MarshalJSON()
+		f["time"] = string(b[1 : len(b)-1])
+	}
+	for k, v := range e.Extra {
+		flattenExtra(f, k, v)
+	}
+	return f
+}
+
+// flattenExtra adds an extra field to f: objects are flattened into
+// dot-notation keys, strings are stored as is and other values as JSON.
+func flattenExtra(f map[string]string, key string, value json.RawMessage) {
+	if _, known := knownFlatKeys[key]; known {
+		return
+	}
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(value, &obj); err == nil {
+		for k, v := range obj {
+			flattenExtra(f, key+"."+k, v)
+		}
+		return
+	}
+	var s string
+	if err := json.Unmarshal(value, &s); err == nil {
+		f[key] = s
+		return
+	}
+	f[key] = string(value)
+}
+
 // unflattenExtra converts dot-notation keys absent from known back into a
 // nested map[string]json.RawMessage for AuditEvent.Extra.
 // e.g. {"custom.field": "v"} → {"custom": {"field": "v"}} as raw JSON.
@@ -168,3 +168,35 @@ var _ = Describe("flatToAuditEvent tenant normalisation", func() {
 		Entry("regular tenant is preserved", "acme", "acme"),
 	)
 })
+
+var _ = Describe("AuditEvent.Flatten", func() {
+	It("round-trips through flatToAuditEvent", func() {
+		event := AuditEvent{
+			Action:        Action{Outcome: "denied", Verb: "get"},
+			Actor:         Actor{ID: "user@example.com", Type: "user"},
+			Authorization: Authorization{Decision: "deny", Policy: "acl/tenant", Reason: "tenant not permitted"},
+			CorrelationID: "c1",
+			EventID:       "e1",
+			SchemaVersion: EventSchemaVersion,
+			Source:        Source{IP: "10.0.0.1", Origin: "api", UserAgent: "Grafana"},
+			Target:        Target{Kind: "metrics", Name: "/metrics/api/v1/query"},
+			Tenant:        TenantPlatform,
+			Time:          msTime{time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)},
+			Extra:         map[string]json.RawMessage{"acl": json.RawMessage(`{"tenants":"a,b"}`)},
+		}
+
+		flat := event.Flatten()
+		Expect(flat).To(HaveKeyWithValue("acl.tenants", "a,b"))
+		Expect(flat).To(HaveKeyWithValue("time", "2025-01-01T10:00:00.000Z"))
+
+		parsed, err := flatToAuditEvent(flat)
+		Expect(err).NotTo(HaveOccurred())
+		expected, err := event.MarshalJSON()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(parsed.MarshalJSON()).To(MatchJSON(expected))
+	})
+
+	It("omits a zero time", func() {
+		Expect(AuditEvent{}.Flatten()).NotTo(HaveKey("time"))
+	})
+})
@@ -17,10 +17,12 @@ package coldstorage
 import (
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/k0rdent/kof/kof-operator/internal/env"
+	"github.com/k0rdent/kof/kof-operator/internal/retention"
 )
 
 // Config holds all exporter configuration sourced from environment variables.
@@ -57,6 +59,34 @@ type Config struct {
 	// CATCHUP_HOURS: how many hours back to look for un-exported windows.
 	// Default: 24
 	CatchUpHours int
+
+	// Concurrency is the number of (tenant, cluster) series exported in
+	// parallel per source. Windows of a series are always exported in order.
+	// EXPORT_CONCURRENCY sets it for all sources (default: 1);
+	// EXPORT_CONCURRENCY_METRICS, _LOGS and _TRACES override it per source.
+	Concurrency map[string]int
+
+	// RateLimits caps the export queries per second sent to the upstream of
+	// each source: VM_RATE_LIMIT, VLOGS_RATE_LIMIT, VTRACES_RATE_LIMIT.
+	// Default: 0 (unlimited).
+	RateLimits map[string]float64
+
+	// Run reporting
+	PushgatewayURL string // PUSHGATEWAY_URL: push run metrics to this Pushgateway (optional)
+	RemoteWriteURL string // REMOTE_WRITE_URL: push run metrics to this remote-write endpoint (optional)
+	RunReport      bool   // RUN_REPORT: write a JSON run report to <S3_PREFIX>/_reports/ (default: true)
+
+	// RETENTION_POLICY: comma-separated "<source>[/<tenant>]=<age>" rules
+	// applied by the retention subcommand, e.g. "metrics=400d,logs=90d".
+	// Default: empty (keep forever).
+	Retention *retention.Policy
+}
+
+// rateLimitEnv maps each source to the variable holding its upstream rate limit.
+var rateLimitEnv = map[string]string{
+	SourceMetrics: "VM_RATE_LIMIT",
+	SourceLogs:    "VLOGS_RATE_LIMIT",
+	SourceTraces:  "VTRACES_RATE_LIMIT",
 }
 
 // LoadConfig reads configuration from environment variables.
@@ -75,6 +105,9 @@ func LoadConfig() (*Config, error) {
 		S3ForceHTTP:    env.GetEnvBool("S3_FORCE_HTTP", false),
 		ExportDelay:    env.GetEnvDuration("EXPORT_DELAY", 5*time.Minute),
 		CatchUpHours:   env.GetEnvInt("CATCHUP_HOURS", 24),
+		PushgatewayURL: env.GetEnvOrDefault("PUSHGATEWAY_URL", ""),
+		RemoteWriteURL: env.GetEnvOrDefault("REMOTE_WRITE_URL", ""),
+		RunReport:      env.GetEnvBool("RUN_REPORT", true),
 	}
 
 	// Sources (what to export)
@@ -113,6 +146,30 @@ func LoadConfig() (*Config, error) {
 		}
 	}
 
+	// Parallelism and upstream rate limits
+	concurrency := env.GetEnvInt("EXPORT_CONCURRENCY", 1)
+	cfg.Concurrency = make(map[string]int, len(cfg.Sources))
+	cfg.RateLimits = make(map[string]float64, len(cfg.Sources))
+	for _, s := range []string{SourceMetrics, SourceLogs, SourceTraces} {
+		key := "EXPORT_CONCURRENCY_" + strings.ToUpper(s)
+		cfg.Concurrency[s] = env.GetEnvInt(key, concurrency)
+		if cfg.Concurrency[s] < 1 {
+			return nil, fmt.Errorf("%s must be at least 1, got %d", key, cfg.Concurrency[s])
+		}
+		key = rateLimitEnv[s]
+		limit, err := strconv.ParseFloat(env.GetEnvOrDefault(key, "0"), 64)
+		if err != nil || limit < 0 {
+			return nil, fmt.Errorf("%s must be a non-negative number of queries per second, got %q", key, os.Getenv(key))
+		}
+		cfg.RateLimits[s] = limit
+	}
+
+	var err error
+	cfg.Retention, err = retention.ParsePolicy(os.Getenv("RETENTION_POLICY"), SourceMetrics, SourceLogs, SourceTraces)
+	if err != nil {
+		return nil, fmt.Errorf("RETENTION_POLICY: %w", err)
+	}
+
 	// Required fields
 	if cfg.S3Bucket == "" {
 		return nil, fmt.Errorf("S3_BUCKET is required")
@@ -19,9 +19,12 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"sync"
 	"time"
 
 	"github.com/go-logr/logr"
+	"github.com/k0rdent/kof/kof-operator/internal/runreport"
+	"golang.org/x/time/rate"
 )
 
 // defaultTenant is the synthetic tenant name used as a fallback when no tenant
@@ -29,11 +32,18 @@ import (
 // against this value so it is defined once here.
 const defaultTenant = "default"
 
+// JobName names the exporter in run metrics and run report keys.
+const JobName = "cold-storage-exporter"
+
+// publishTimeout bounds publishing the run report, which also happens after
+// the run context was cancelled.
+const publishTimeout = 30 * time.Second
+
 // Exporter orchestrates the full cold-storage export run:
 //  1. Determine all (source, tenant, cluster, hour) windows in [catchUpStart, prevHourEnd]
 //     that have not yet been exported.
 //  2. For each window, extract data from the appropriate source, convert to Parquet, and
-//     upload to S3.
+//     upload to S3. Series of windows are exported in parallel by per-source worker pools.
 type Exporter struct {
 	cfg     *Config
 	vm      *VMClient
@@ -41,6 +51,13 @@ type Exporter struct {
 	vtraces *VTracesClient
 	s3      *S3Client
 	log     logr.Logger
+
+	// limiters pace the export queries per source; nil means unlimited.
+	limiters map[string]*rate.Limiter
+
+	publisher *runreport.Publisher
+	// report records the window outcomes of the current run.
+	report *runreport.Recorder
 }
 
 // NewExporter constructs and validates an Exporter.
@@ -50,19 +67,47 @@ func NewExporter(cfg *Config, log logr.Logger) (*Exporter, error) {
 		return nil, fmt.Errorf("create S3 client: %w", err)
 	}
 
+	limiters := make(map[string]*rate.Limiter)
+	for source, limit := range cfg.RateLimits {
+		if limit > 0 {
+			limiters[source] = rate.NewLimiter(rate.Limit(limit), 1)
+		}
+	}
+
 	return &Exporter{
-		cfg:     cfg,
-		vm:      NewVMClient(cfg.VMURL),
-		vlogs:   NewVLogsClient(cfg.VLogsURL),
-		vtraces: NewVTracesClient(cfg.VTracesURL),
-		s3:      s3client,
-		log:     log,
+		cfg:      cfg,
+		vm:       NewVMClient(cfg.VMURL),
+		vlogs:    NewVLogsClient(cfg.VLogsURL),
+		vtraces:  NewVTracesClient(cfg.VTracesURL),
+		s3:       s3client,
+		log:      log,
+		limiters: limiters,
+		publisher: runreport.NewPublisher(runreport.Config{
+			Job:            JobName,
+			S3Prefix:       cfg.S3Prefix,
+			WriteReport:    cfg.RunReport,
+			PushgatewayURL: cfg.PushgatewayURL,
+			RemoteWriteURL: cfg.RemoteWriteURL,
+		}, s3client, log),
 	}, nil
 }
 
 // Run executes the export job once and exits when all pending windows have
 // been processed. It is intended to be called once per CronJob invocation.
+// The outcome is published as run metrics and a run report, also on failure.
 func (e *Exporter) Run(ctx context.Context) error {
+	e.report = runreport.NewRecorder(JobName, time.Now())
+	err := e.run(ctx)
+
+	publishCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), publishTimeout)
+	defer cancel()
+	if pubErr := e.publisher.Publish(publishCtx, e.report.Finish(time.Now(), err)); pubErr != nil {
+		e.log.Error(pubErr, "failed to publish run report")
+	}
+	return err
+}
+
+func (e *Exporter) run(ctx context.Context) error {
 	log := e.log
 
 	// Determine target window (previous complete hour minus export delay).
@@ -86,31 +131,125 @@ func (e *Exporter) Run(ctx context.Context) error {
 	}
 	log.Info("windows to process", "count", len(windows))
 
-	var exportErr error
-	for _, w := range windows {
-		if ctx.Err() != nil {
-			return ctx.Err()
-		}
-		if err := e.exportWindow(ctx, w); err != nil {
+	if err := e.exportAll(ctx, windows, e.exportWindow); err != nil {
+		return err
+	}
+	log.Info("export run complete")
+	return nil
+}
+
+// exportAll exports windows with a worker pool per source. Each worker takes
+// a whole (tenant, cluster) series and exports its windows in chronological
+// order, so scheduling alone never exports a newer window before an older
+// one. Up to cfg.Concurrency[source] series of a source are exported in
+// parallel.
+//
+// A SourceUnavailableError from any worker cancels all workers and halts the
+// run. Other failures are logged and the series continues with its next
+// window, leaving a gap until the failed window is exported by the catch-up
+// of a later run.
+func (e *Exporter) exportAll(ctx context.Context, windows []ExportWindow, export func(context.Context, ExportWindow) error) error {
+	runCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		lastErr error
+	)
+	exportSeries := func(series []ExportWindow) {
+		for _, w := range series {
+			if runCtx.Err() != nil {
+				return
+			}
+			err := export(runCtx, w)
+			if err == nil {
+				continue
+			}
+			if runCtx.Err() != nil {
+				// Interrupted by an outage elsewhere or by shutdown; not a window failure.
+				return
+			}
 			var srcErr *SourceUnavailableError
+			e.report.Failed(w.Source, w.Tenant)
 			if errors.As(err, &srcErr) {
-				log.Error(err, "SOURCE OUTAGE: upstream is unreachable — halting run; next CronJob invocation will catch up")
-				return fmt.Errorf("source outage: %w", err)
+				e.log.Error(err, "SOURCE OUTAGE: upstream is unreachable — halting run; next CronJob invocation will catch up")
+				cancel(fmt.Errorf("source outage: %w", err))
+				return
 			}
-			log.Error(err, "failed to export window",
+			e.log.Error(err, "failed to export window",
 				"source", w.Source, "tenant", w.Tenant, "cluster", w.Cluster,
 				"window", w.Start.Format("2006-01-02T15:04Z"))
-			exportErr = fmt.Errorf("one or more windows failed (last: %w)", err)
+			mu.Lock()
+			lastErr = err
+			mu.Unlock()
 		}
 	}
 
-	if exportErr != nil {
-		return exportErr
+	for _, source := range e.cfg.Sources {
+		series := groupSeries(windows, source)
+		if len(series) == 0 {
+			continue
+		}
+		workers := min(max(e.cfg.Concurrency[source], 1), len(series))
+		e.log.V(1).Info("exporting source", "source", source, "series", len(series), "workers", workers)
+
+		queue := make(chan []ExportWindow)
+		for range workers {
+			wg.Go(func() {
+				for s := range queue {
+					exportSeries(s)
+				}
+			})
+		}
+		wg.Go(func() {
+			defer close(queue)
+			for _, s := range series {
+				select {
+				case queue <- s:
+				case <-runCtx.Done():
+					return
+				}
+			}
+		})
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if cause := context.Cause(runCtx); cause != nil && !errors.Is(cause, context.Canceled) {
+		return cause
+	}
+	if lastErr != nil {
+		return fmt.Errorf("one or more windows failed (last: %w)", lastErr)
 	}
-	log.Info("export run complete")
 	return nil
 }
 
+// groupSeries returns the windows of source grouped by (tenant, cluster), in
+// order of first appearance, keeping the order of windows within each series.
+func groupSeries(windows []ExportWindow, source string) [][]ExportWindow {
+	var (
+		series [][]ExportWindow
+		index  = map[[2]string]int{}
+	)
+	for _, w := range windows {
+		if w.Source != source {
+			continue
+		}
+		key := [2]string{w.Tenant, w.Cluster}
+		i, ok := index[key]
+		if !ok {
+			i = len(series)
+			index[key] = i
+			series = append(series, nil)
+		}
+		series[i] = append(series[i], w)
+	}
+	return series
+}
+
 // collectWindows builds the ordered list of ExportWindow values to process,
 // skipping windows that already have a _SUCCESS marker in S3 (idempotency).
 func (e *Exporter) collectWindows(ctx context.Context, from, upToEnd time.Time) ([]ExportWindow, error) {
@@ -122,6 +261,7 @@ func (e *Exporter) collectWindows(ctx context.Context, from, upToEnd time.Time)
 			var srcErr *SourceUnavailableError
 			if errors.As(err, &srcErr) {
 				e.log.Error(err, "source unreachable during window collection; skipping source for this run", "source", source)
+				e.report.SourceUnavailable(source)
 				continue
 			}
 			return nil, fmt.Errorf("source %q: discover tenants: %w", source, err)
@@ -133,6 +273,7 @@ func (e *Exporter) collectWindows(ctx context.Context, from, upToEnd time.Time)
 				var srcErr *SourceUnavailableError
 				if errors.As(err, &srcErr) {
 					e.log.Error(err, "source unreachable during cluster discovery; skipping source for this run", "source", source, "tenant", tenant)
+					e.report.SourceUnavailable(source)
 					break // skip all tenants for this source
 				}
 				return nil, fmt.Errorf("source %q tenant %q: discover clusters: %w", source, tenant, err)
@@ -154,8 +295,10 @@ func (e *Exporter) collectWindows(ctx context.Context, from, upToEnd time.Time)
 						return nil, fmt.Errorf("check success marker %q: %w", successKey, err)
 					}
 					if exists {
+						e.report.Skipped(source, tenant, wStart)
 						continue
 					}
+					e.report.Pending(source, tenant, wStart)
 					windows = append(windows, w)
 				}
 			}
@@ -272,9 +415,16 @@ func (e *Exporter) exportWindow(ctx context.Context, w ExportWindow) error {
 	}
 	if exists {
 		log.Info("window already exported, skipping")
+		e.report.Skipped(w.Source, w.Tenant, w.Start)
 		return nil
 	}
 
+	if limiter := e.limiters[w.Source]; limiter != nil {
+		if err := limiter.Wait(ctx); err != nil {
+			return err
+		}
+	}
+
 	switch w.Source {
 	case SourceMetrics:
 		return e.exportMetricsWindow(ctx, log, w, prefix, successKey)
@@ -310,6 +460,7 @@ func (e *Exporter) exportMetricsWindow(ctx context.Context, log logr.Logger, w E
 	// Stream: VM export body → Parquet writer → io.Pipe → S3 multipart upload.
 	// The parquet writer and S3 upload run concurrently — no full buffering.
 	pr, pw := io.Pipe()
+	cw := &countingWriter{w: pw}
 	var rowCount int
 	uploadErrCh := make(chan error, 1)
 	writeErrCh := make(chan error, 1)
@@ -325,7 +476,7 @@ func (e *Exporter) exportMetricsWindow(ctx context.Context, log logr.Logger, w E
 
 	// Parquet write goroutine: scan VM export and write rows to the parquet writer.
 	go func() {
-		parquetWriter := NewMetricsParquetWriter(pw)
+		parquetWriter := NewMetricsParquetWriter(cw)
 		writeErr := ScanVMExport(body, func(rows []MetricRow) error {
 			return parquetWriter.Write(rows)
 		})
@@ -356,6 +507,7 @@ func (e *Exporter) exportMetricsWindow(ctx context.Context, log logr.Logger, w E
 	}
 
 	log.Info("window exported successfully", "rows", rowCount)
+	e.report.Exported(w.Source, w.Tenant, w.Start, int64(rowCount), cw.n)
 	return nil
 }
 
@@ -374,6 +526,7 @@ func (e *Exporter) exportLogsWindow(ctx context.Context, log logr.Logger, w Expo
 
 	// Stream: VLogs export body → Parquet writer → io.Pipe → S3 multipart upload.
 	pr, pw := io.Pipe()
+	cw := &countingWriter{w: pw}
 	var rowCount int
 	uploadErrCh := make(chan error, 1)
 	writeErrCh := make(chan error, 1)
@@ -385,7 +538,7 @@ func (e *Exporter) exportLogsWindow(ctx context.Context, log logr.Logger, w Expo
 	}()
 
 	go func() {
-		parquetWriter := NewLogsParquetWriter(pw)
+		parquetWriter := NewLogsParquetWriter(cw)
 		writeErr := ScanVLogsExport(body, w.Tenant, w.Cluster, func(rows []LogRow) error {
 			return parquetWriter.Write(rows)
 		})
@@ -414,6 +567,7 @@ func (e *Exporter) exportLogsWindow(ctx context.Context, log logr.Logger, w Expo
 	}
 
 	log.Info("logs window exported successfully", "rows", rowCount)
+	e.report.Exported(w.Source, w.Tenant, w.Start, int64(rowCount), cw.n)
 	return nil
 }
 
@@ -431,6 +585,7 @@ func (e *Exporter) exportTracesWindow(ctx context.Context, log logr.Logger, w Ex
 	log.Info("streaming traces parquet to S3", "key", tracesKey)
 
 	pr, pw := io.Pipe()
+	cw := &countingWriter{w: pw}
 	var rowCount int
 	uploadErrCh := make(chan error, 1)
 	writeErrCh := make(chan error, 1)
@@ -442,7 +597,7 @@ func (e *Exporter) exportTracesWindow(ctx context.Context, log logr.Logger, w Ex
 	}()
 
 	go func() {
-		parquetWriter := NewTracesParquetWriter(pw)
+		parquetWriter := NewTracesParquetWriter(cw)
 		writeErr := ScanVTracesExport(body, w.Tenant, w.Cluster, func(rows []TraceRow) error {
 			return parquetWriter.Write(rows)
 		})
@@ -471,5 +626,18 @@ func (e *Exporter) exportTracesWindow(ctx context.Context, log logr.Logger, w Ex
 	}
 
 	log.Info("traces window exported successfully", "rows", rowCount)
+	e.report.Exported(w.Source, w.Tenant, w.Start, int64(rowCount), cw.n)
 	return nil
 }
+
+// countingWriter wraps an io.Writer and counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,142 @@
+// Copyright 2025
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package coldstorage
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Exporter.exportAll", func() {
+	var (
+		ctx context.Context
+		h00 = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	)
+
+	// seriesWindows returns hours consecutive windows for each cluster.
+	seriesWindows := func(source string, clusters []string, hours int) []ExportWindow {
+		var windows []ExportWindow
+		for _, cluster := range clusters {
+			for i := range hours {
+				start := h00.Add(time.Duration(i) * time.Hour)
+				windows = append(windows, ExportWindow{
+					Source: source, Tenant: "acme", Cluster: cluster, Start: start, End: start.Add(time.Hour),
+				})
+			}
+		}
+		return windows
+	}
+
+	newExporter := func(concurrency map[string]int) *Exporter {
+		return &Exporter{
+			cfg: &Config{
+				Sources:     []string{SourceMetrics, SourceLogs},
+				Concurrency: concurrency,
+			},
+			log: logr.Discard(),
+		}
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+	})
+
+	It("bounds concurrency per source and keeps each series in order", func() {
+		clusters := []string{"c1", "c2", "c3", "c4", "c5"}
+		windows := append(seriesWindows(SourceMetrics, clusters, 4), seriesWindows(SourceLogs, clusters, 4)...)
+
+		var (
+			mu       sync.Mutex
+			inFlight = map[string]int{}
+			peak     = map[string]int{}
+			exported = map[string][]time.Time{}
+		)
+		export := func(_ context.Context, w ExportWindow) error {
+			mu.Lock()
+			inFlight[w.Source]++
+			peak[w.Source] = max(peak[w.Source], inFlight[w.Source])
+			mu.Unlock()
+
+			time.Sleep(time.Millisecond)
+
+			mu.Lock()
+			inFlight[w.Source]--
+			exported[w.Source+"/"+w.Cluster] = append(exported[w.Source+"/"+w.Cluster], w.Start)
+			mu.Unlock()
+			return nil
+		}
+
+		e := newExporter(map[string]int{SourceMetrics: 3, SourceLogs: 1})
+		Expect(e.exportAll(ctx, windows, export)).To(Succeed())
+
+		Expect(peak[SourceMetrics]).To(BeNumerically("<=", 3))
+		Expect(peak[SourceMetrics]).To(BeNumerically(">", 1))
+		Expect(peak[SourceLogs]).To(Equal(1))
+		Expect(exported).To(HaveLen(10))
+		for series, starts := range exported {
+			Expect(starts).To(HaveLen(4), series)
+			for i := 1; i < len(starts); i++ {
+				Expect(starts[i].After(starts[i-1])).To(BeTrue(), series)
+			}
+		}
+	})
+
+	It("continues a series after a window failure", func() {
+		windows := seriesWindows(SourceMetrics, []string{"c1"}, 3)
+		var exported atomic.Int32
+		export := func(_ context.Context, w ExportWindow) error {
+			if w.Start.Equal(h00) {
+				return errors.New("corrupt export")
+			}
+			exported.Add(1)
+			return nil
+		}
+
+		err := newExporter(map[string]int{SourceMetrics: 2}).exportAll(ctx, windows, export)
+		Expect(err).To(MatchError(ContainSubstring("corrupt export")))
+		Expect(exported.Load()).To(BeEquivalentTo(2))
+	})
+
+	It("halts all workers on a source outage", func() {
+		clusters := []string{"c1", "c2", "c3", "c4"}
+		windows := append(seriesWindows(SourceMetrics, clusters, 24), seriesWindows(SourceLogs, clusters, 24)...)
+
+		var calls atomic.Int32
+		export := func(ctx context.Context, w ExportWindow) error {
+			if calls.Add(1) == 5 {
+				return &SourceUnavailableError{Source: "VictoriaMetrics", Cause: errors.New("connection refused")}
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Millisecond):
+				return nil
+			}
+		}
+
+		err := newExporter(map[string]int{SourceMetrics: 2, SourceLogs: 2}).exportAll(ctx, windows, export)
+		var srcErr *SourceUnavailableError
+		Expect(errors.As(err, &srcErr)).To(BeTrue())
+		Expect(err).To(MatchError(ContainSubstring("source outage")))
+		Expect(calls.Load()).To(BeNumerically("<", len(windows)))
+	})
+})
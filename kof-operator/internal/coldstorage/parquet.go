@@ -227,6 +227,14 @@ type traceParquetRow struct {
 	Events []byte `parquet:"Events,optional"`
 }
 
+// traceEventJSON is the JSON form of a span event in the Events column:
+// [{"ts":<ns>,"name":"...","attrs":{...}}]
+type traceEventJSON struct {
+	Ts    int64             `json:"ts"`
+	Name  string            `json:"name"`
+	Attrs map[string]string `json:"attrs,omitempty"`
+}
+
 // TracesParquetWriter streams TraceRows (spans) into a Parquet file written to w.
 type TracesParquetWriter struct {
 	pw    *parquet.GenericWriter[traceParquetRow]
@@ -280,15 +288,9 @@ func (t *TracesParquetWriter) Write(rows []TraceRow) error {
 			pr.SpanAttributes = b
 		}
 		if len(r.Events) > 0 {
-			// Serialise events as [{"ts":<ns>,"name":"...","attrs":{...}}]
-			type eventJSON struct {
-				Ts    int64             `json:"ts"`
-				Name  string            `json:"name"`
-				Attrs map[string]string `json:"attrs,omitempty"`
-			}
-			evs := make([]eventJSON, len(r.Events))
+			evs := make([]traceEventJSON, len(r.Events))
 			for i, ev := range r.Events {
-				evs[i] = eventJSON{Ts: ev.TimestampNs, Name: ev.Name, Attrs: ev.Attributes}
+				evs[i] = traceEventJSON{Ts: ev.TimestampNs, Name: ev.Name, Attrs: ev.Attributes}
 			}
 			b, err := json.Marshal(evs)
 			if err != nil {
@@ -312,3 +314,150 @@ func (t *TracesParquetWriter) Close() error {
 func (t *TracesParquetWriter) RowCount() int {
 	return t.count
 }
+
+// ──────────────────────────────────────────────────────────────────────────────
+// Readers — used by the restore mode to rehydrate exported partitions
+// ──────────────────────────────────────────────────────────────────────────────
+
+// parquetReadBatchSize is the number of rows decoded per batch by the readers.
+const parquetReadBatchSize = 5000
+
+// readParquet decodes all rows of a Parquet file and calls fn with batches of rows.
+func readParquet[T any](r io.ReaderAt, size int64, fn func([]T) error) error {
+	f, err := parquet.OpenFile(r, size)
+	if err != nil {
+		return fmt.Errorf("open parquet file: %w", err)
+	}
+	pr := parquet.NewGenericReader[T](f)
+	defer func() { _ = pr.Close() }()
+
+	batch := make([]T, parquetReadBatchSize)
+	for {
+		n, err := pr.Read(batch)
+		if n > 0 {
+			if fnErr := fn(batch[:n]); fnErr != nil {
+				return fnErr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read parquet rows: %w", err)
+		}
+	}
+}
+
+// ReadMetricsParquet reads a metrics Parquet file written by MetricsParquetWriter
+// and calls fn with batches of MetricRows.
+func ReadMetricsParquet(r io.ReaderAt, size int64, fn func([]MetricRow) error) error {
+	return readParquet(r, size, func(parquetRows []metricParquetRow) error {
+		rows := make([]MetricRow, 0, len(parquetRows))
+		for _, pr := range parquetRows {
+			row := MetricRow{
+				Timestamp:        pr.Timestamp,
+				MetricName:       pr.MetricName,
+				Value:            pr.Value,
+				Tenant:           pr.Tenant,
+				Cluster:          pr.Cluster,
+				ClusterNamespace: pr.ClusterNamespace,
+				Namespace:        pr.Namespace,
+				Pod:              pr.Pod,
+				Node:             pr.Node,
+				Job:              pr.Job,
+			}
+			if len(pr.LabelsJSON) > 0 {
+				if err := json.Unmarshal(pr.LabelsJSON, &row.Labels); err != nil {
+					return fmt.Errorf("unmarshal labels: %w", err)
+				}
+			}
+			rows = append(rows, row)
+		}
+		return fn(rows)
+	})
+}
+
+// ReadLogsParquet reads a logs Parquet file written by LogsParquetWriter and
+// calls fn with batches of LogRows.
+func ReadLogsParquet(r io.ReaderAt, size int64, fn func([]LogRow) error) error {
+	return readParquet(r, size, func(parquetRows []logParquetRow) error {
+		rows := make([]LogRow, 0, len(parquetRows))
+		for _, pr := range parquetRows {
+			row := LogRow{
+				TimestampNs:      pr.Timestamp,
+				TraceId:          pr.TraceId,
+				SpanId:           pr.SpanId,
+				TraceFlags:       pr.TraceFlags,
+				SeverityText:     pr.SeverityText,
+				SeverityNumber:   pr.SeverityNumber,
+				ServiceName:      pr.ServiceName,
+				Body:             pr.Body,
+				Tenant:           pr.Tenant,
+				Cluster:          pr.Cluster,
+				ClusterNamespace: pr.ClusterNamespace,
+				Namespace:        pr.Namespace,
+				Pod:              pr.Pod,
+				Node:             pr.Node,
+			}
+			if len(pr.LogAttributes) > 0 {
+				if err := json.Unmarshal(pr.LogAttributes, &row.Attributes); err != nil {
+					return fmt.Errorf("unmarshal log attributes: %w", err)
+				}
+			}
+			rows = append(rows, row)
+		}
+		return fn(rows)
+	})
+}
+
+// ReadTracesParquet reads a traces Parquet file written by TracesParquetWriter
+// and calls fn with batches of TraceRows.
+func ReadTracesParquet(r io.ReaderAt, size int64, fn func([]TraceRow) error) error {
+	return readParquet(r, size, func(parquetRows []traceParquetRow) error {
+		rows := make([]TraceRow, 0, len(parquetRows))
+		for _, pr := range parquetRows {
+			row := TraceRow{
+				Timestamp:        pr.Timestamp,
+				TraceId:          pr.TraceId,
+				SpanId:           pr.SpanId,
+				ParentSpanId:     pr.ParentSpanId,
+				TraceState:       pr.TraceState,
+				TraceFlags:       pr.TraceFlags,
+				SpanName:         pr.SpanName,
+				SpanKind:         pr.SpanKind,
+				ServiceName:      pr.ServiceName,
+				Duration:         pr.Duration,
+				StatusCode:       pr.StatusCode,
+				StatusMessage:    pr.StatusMessage,
+				Tenant:           pr.Tenant,
+				Cluster:          pr.Cluster,
+				ClusterNamespace: pr.ClusterNamespace,
+				Namespace:        pr.Namespace,
+				Pod:              pr.Pod,
+				Node:             pr.Node,
+			}
+			if len(pr.ResourceAttributes) > 0 {
+				if err := json.Unmarshal(pr.ResourceAttributes, &row.ResourceAttributes); err != nil {
+					return fmt.Errorf("unmarshal resource attributes: %w", err)
+				}
+			}
+			if len(pr.SpanAttributes) > 0 {
+				if err := json.Unmarshal(pr.SpanAttributes, &row.SpanAttributes); err != nil {
+					return fmt.Errorf("unmarshal span attributes: %w", err)
+				}
+			}
+			if len(pr.Events) > 0 {
+				var evs []traceEventJSON
+				if err := json.Unmarshal(pr.Events, &evs); err != nil {
+					return fmt.Errorf("unmarshal events: %w", err)
+				}
+				row.Events = make([]TraceEvent, len(evs))
+				for i, ev := range evs {
+					row.Events[i] = TraceEvent{TimestampNs: ev.Ts, Name: ev.Name, Attributes: ev.Attrs}
+				}
+			}
+			rows = append(rows, row)
+		}
+		return fn(rows)
+	})
+}
@@ -0,0 +1,280 @@
+// Copyright 2025
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package coldstorage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+// restorePrefix is the S3 key prefix (under the configured S3_PREFIX) holding
+// restore progress markers.
+const restorePrefix = "_restore"
+
+// RestoreOptions selects the partitions to restore and the targets to push them to.
+type RestoreOptions struct {
+	// Sources to restore; a source without a target URL is skipped.
+	Sources []string
+	Tenant  string
+	Cluster string
+	// From is the start of the first partition (truncated to the hour).
+	From time.Time
+	// To is the exclusive end of the range.
+	To time.Time
+
+	// VMURL is the Prometheus API prefix of the target VictoriaMetrics.
+	VMURL string
+	// VLogsURL is the base URL of the target VictoriaLogs insert endpoint.
+	VLogsURL string
+	// VTracesURL is the base URL of the target VictoriaTraces insert endpoint.
+	VTracesURL string
+
+	// RestoreID names the restore progress. Partitions restored under the same
+	// ID are skipped, so re-running an interrupted restore resumes it.
+	// Defaults to a hash of the target URLs.
+	RestoreID string
+}
+
+// DefaultRestoreID returns the restore ID derived from the target URLs, so that
+// repeated restores into the same targets share their progress.
+func (o RestoreOptions) DefaultRestoreID() string {
+	h := sha256.Sum256([]byte(strings.Join([]string{o.VMURL, o.VLogsURL, o.VTracesURL}, "\n")))
+	return hex.EncodeToString(h[:6])
+}
+
+// RestoreSummary counts the partitions processed by a restore run.
+type RestoreSummary struct {
+	// Restored partitions were pushed to the target by this run.
+	Restored int
+	// Resumed partitions were already restored by a previous run.
+	Resumed int
+	// Incomplete partitions have no _SUCCESS marker and were skipped.
+	Incomplete int
+	// Failed partitions could not be read and will be retried by the next run.
+	Failed int
+	// Rows is the number of rows pushed to the targets.
+	Rows int
+}
+
+// Restorer rehydrates exported Parquet partitions back into VictoriaMetrics,
+// VictoriaLogs and VictoriaTraces.
+type Restorer struct {
+	cfg     *Config
+	s3      *S3Client
+	vm      *VMImporter
+	vlogs   *VLogsImporter
+	vtraces *VTracesImporter
+	log     logr.Logger
+}
+
+// NewRestorer creates a Restorer reading partitions with s3client and pushing
+// them to the targets configured in opts.
+func NewRestorer(cfg *Config, s3client *S3Client, opts RestoreOptions, log logr.Logger) *Restorer {
+	r := &Restorer{cfg: cfg, s3: s3client, log: log}
+	if opts.VMURL != "" {
+		r.vm = NewVMImporter(opts.VMURL)
+	}
+	if opts.VLogsURL != "" {
+		r.vlogs = NewVLogsImporter(opts.VLogsURL)
+	}
+	if opts.VTracesURL != "" {
+		r.vtraces = NewVTracesImporter(opts.VTracesURL)
+	}
+	return r
+}
+
+// Run restores every exported partition of the tenant and cluster in
+// [opts.From, opts.To) in chronological order. Partitions are restored whole,
+// so samples outside the range but within its first and last hour are restored too.
+//
+// Partitions without _SUCCESS are skipped. A partition that cannot be read is
+// logged and counted as failed; an unavailable target halts the run. After a
+// partition is pushed, a progress marker is written so the next run with the
+// same RestoreID resumes after it.
+func (r *Restorer) Run(ctx context.Context, opts RestoreOptions) (RestoreSummary, error) {
+	var summary RestoreSummary
+
+	if opts.Tenant == "" || opts.Cluster == "" {
+		return summary, fmt.Errorf("tenant and cluster are required")
+	}
+	from := opts.From.UTC().Truncate(time.Hour)
+	to := opts.To.UTC()
+	if !from.Before(to) {
+		return summary, fmt.Errorf("empty time range [%s, %s)", from.Format(time.RFC3339), to.Format(time.RFC3339))
+	}
+	if opts.RestoreID == "" {
+		opts.RestoreID = opts.DefaultRestoreID()
+	}
+
+	sources := make([]string, 0, len(opts.Sources))
+	for _, source := range opts.Sources {
+		if !r.hasTarget(source) {
+			r.log.Info("no restore target configured, skipping source", "source", source)
+			continue
+		}
+		sources = append(sources, source)
+	}
+	if len(sources) == 0 {
+		return summary, fmt.Errorf("no restore target configured for sources %v", opts.Sources)
+	}
+
+	r.log.Info("restore run started",
+		"restore_id", opts.RestoreID, "tenant", opts.Tenant, "cluster", opts.Cluster,
+		"from", from.Format(time.RFC3339), "to", to.Format(time.RFC3339), "sources", sources)
+
+	for start := from; start.Before(to); start = start.Add(time.Hour) {
+		for _, source := range sources {
+			if ctx.Err() != nil {
+				return summary, ctx.Err()
+			}
+			w := ExportWindow{
+				Source:  source,
+				Tenant:  opts.Tenant,
+				Cluster: opts.Cluster,
+				Start:   start,
+				End:     start.Add(time.Hour),
+			}
+			if err := r.restoreWindow(ctx, opts.RestoreID, w, &summary); err != nil {
+				var targetErr *TargetUnavailableError
+				if errors.As(err, &targetErr) {
+					r.log.Error(err, "restore target is unavailable — halting run; re-run to resume")
+					return summary, fmt.Errorf("target outage: %w", err)
+				}
+				r.log.Error(err, "failed to restore partition",
+					"source", w.Source, "window", w.Start.Format("2006-01-02T15:04Z"))
+				summary.Failed++
+			}
+		}
+	}
+
+	r.log.Info("restore run complete",
+		"restored", summary.Restored, "resumed", summary.Resumed,
+		"incomplete", summary.Incomplete, "failed", summary.Failed, "rows", summary.Rows)
+	if summary.Failed > 0 {
+		return summary, fmt.Errorf("%d partitions failed to restore", summary.Failed)
+	}
+	return summary, nil
+}
+
+// RestoredMarkerKey returns the key of the progress marker of a partition.
+func (r *Restorer) RestoredMarkerKey(restoreID string, w ExportWindow) string {
+	prefix := restorePrefix + "/" + restoreID
+	if r.cfg.S3Prefix != "" {
+		prefix = r.cfg.S3Prefix + "/" + prefix
+	}
+	return w.S3KeyPrefix(prefix) + "_RESTORED"
+}
+
+func (r *Restorer) hasTarget(source string) bool {
+	switch source {
+	case SourceMetrics:
+		return r.vm != nil
+	case SourceLogs:
+		return r.vlogs != nil
+	case SourceTraces:
+		return r.vtraces != nil
+	default:
+		return false
+	}
+}
+
+// restoreWindow restores a single (source, tenant, cluster, hour) partition.
+func (r *Restorer) restoreWindow(ctx context.Context, restoreID string, w ExportWindow, summary *RestoreSummary) error {
+	log := r.log.WithValues(
+		"source", w.Source,
+		"window", w.Start.Format("2006-01-02T15:04Z"),
+	)
+
+	prefix := w.S3KeyPrefix(r.cfg.S3Prefix)
+	markerKey := r.RestoredMarkerKey(restoreID, w)
+
+	restored, err := r.s3.ObjectExists(ctx, markerKey)
+	if err != nil {
+		return fmt.Errorf("check restore marker: %w", err)
+	}
+	if restored {
+		log.V(1).Info("partition already restored, skipping")
+		summary.Resumed++
+		return nil
+	}
+
+	exported, err := r.s3.ObjectExists(ctx, prefix+"_SUCCESS")
+	if err != nil {
+		return fmt.Errorf("check success marker: %w", err)
+	}
+	if !exported {
+		log.V(1).Info("partition has no _SUCCESS marker, skipping")
+		summary.Incomplete++
+		return nil
+	}
+
+	dataKey := prefix + w.Source + ".parquet"
+	data, err := r.s3.GetObject(ctx, dataKey)
+	if err != nil {
+		return err
+	}
+	if data == nil {
+		return fmt.Errorf("%q is missing although the partition is marked as exported", dataKey)
+	}
+
+	rows, err := r.push(ctx, w.Source, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	if err := r.s3.PutObject(ctx, markerKey, []byte{}, "text/plain"); err != nil {
+		return fmt.Errorf("upload restore marker: %w", err)
+	}
+	log.Info("partition restored", "rows", rows)
+	summary.Restored++
+	summary.Rows += rows
+	return nil
+}
+
+// push decodes a partition Parquet file and pushes it to the source target in batches.
+func (r *Restorer) push(ctx context.Context, source string, data *bytes.Reader) (int, error) {
+	var (
+		rows int
+		err  error
+	)
+	switch source {
+	case SourceMetrics:
+		err = ReadMetricsParquet(data, data.Size(), func(batch []MetricRow) error {
+			rows += len(batch)
+			return r.vm.Import(ctx, batch)
+		})
+	case SourceLogs:
+		err = ReadLogsParquet(data, data.Size(), func(batch []LogRow) error {
+			rows += len(batch)
+			return r.vlogs.Import(ctx, batch)
+		})
+	case SourceTraces:
+		err = ReadTracesParquet(data, data.Size(), func(batch []TraceRow) error {
+			rows += len(batch)
+			return r.vtraces.Import(ctx, batch)
+		})
+	default:
+		err = fmt.Errorf("unknown source %q", source)
+	}
+	return rows, err
+}
@@ -0,0 +1,442 @@
+// Copyright 2025
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package coldstorage
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// TargetUnavailableError indicates that a restore target could not be reached
+// or rejected the pushed data. The restore run halts on it; the next run
+// resumes from the first partition that was not fully restored.
+type TargetUnavailableError struct {
+	// Target is a human-readable label for the downstream service.
+	Target string
+	Cause  error
+}
+
+func (e *TargetUnavailableError) Error() string {
+	return e.Target + " target unavailable: " + e.Cause.Error()
+}
+
+func (e *TargetUnavailableError) Unwrap() error { return e.Cause }
+
+// targetClient posts restored data to a Victoria* ingestion endpoint.
+type targetClient struct {
+	name       string
+	baseURL    string
+	httpClient *http.Client
+}
+
+func newTargetClient(name, baseURL string) targetClient {
+	return targetClient{
+		name:       name,
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 5 * time.Minute},
+	}
+}
+
+// post sends body to endpoint and expects a 2xx response.
+func (c targetClient) post(ctx context.Context, endpoint string, params url.Values, contentType string, body []byte) error {
+	reqURL := c.baseURL + endpoint
+	if len(params) > 0 {
+		reqURL += "?" + params.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, reqURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build %s request: %w", c.name, err)
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return &TargetUnavailableError{Target: c.name, Cause: err}
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &TargetUnavailableError{
+			Target: c.name,
+			Cause:  fmt.Errorf("HTTP %d: %s", resp.StatusCode, msg),
+		}
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// ──────────────────────────────────────────────────────────────────────────────
+// VictoriaMetrics — /api/v1/import
+// ──────────────────────────────────────────────────────────────────────────────
+
+// VMImporter pushes metric samples into VictoriaMetrics using the JSON line
+// import API, the counterpart of the /api/v1/export format read by ScanVMExport.
+type VMImporter struct {
+	targetClient
+}
+
+// NewVMImporter creates a VMImporter. baseURL is the Prometheus API prefix of
+// the target, e.g. http://vminsert:8480/insert/0/prometheus for a cluster or
+// http://victoria-metrics:8428 for a single-node instance.
+func NewVMImporter(baseURL string) *VMImporter {
+	return &VMImporter{targetClient: newTargetClient("VictoriaMetrics", baseURL)}
+}
+
+// Import pushes a batch of samples. Samples of the same series are grouped
+// into one import line, preserving their order.
+func (c *VMImporter) Import(ctx context.Context, rows []MetricRow) error {
+	body, err := EncodeVMImport(rows)
+	if err != nil {
+		return err
+	}
+	if len(body) == 0 {
+		return nil
+	}
+	return c.post(ctx, "/api/v1/import", nil, "application/json", body)
+}
+
+// EncodeVMImport converts MetricRows into /api/v1/import JSON lines, one line per series.
+func EncodeVMImport(rows []MetricRow) ([]byte, error) {
+	var (
+		order []string
+		lines = make(map[string]*vmExportLine)
+	)
+	for _, r := range rows {
+		metric := metricLabels(r)
+		key := seriesKey(metric)
+		line, ok := lines[key]
+		if !ok {
+			line = &vmExportLine{Metric: metric}
+			lines[key] = line
+			order = append(order, key)
+		}
+		line.Values = append(line.Values, formatVMValue(r.Value))
+		line.Timestamps = append(line.Timestamps, r.Timestamp)
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, key := range order {
+		if err := enc.Encode(lines[key]); err != nil {
+			return nil, fmt.Errorf("encode import line: %w", err)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// metricLabels rebuilds the full label set of a sample from the promoted
+// columns and the remaining labels.
+func metricLabels(r MetricRow) map[string]string {
+	metric := make(map[string]string, len(r.Labels)+8)
+	for k, v := range r.Labels {
+		metric[k] = v
+	}
+	for k, v := range map[string]string{
+		"__name__":          r.MetricName,
+		"tenant":            r.Tenant,
+		"cluster":           r.Cluster,
+		"cluster_namespace": r.ClusterNamespace,
+		"namespace":         r.Namespace,
+		"pod":               r.Pod,
+		"node":              r.Node,
+		"job":               r.Job,
+	} {
+		if v != "" {
+			metric[k] = v
+		}
+	}
+	return metric
+}
+
+// seriesKey returns a stable identity of a label set.
+func seriesKey(metric map[string]string) string {
+	keys := make([]string, 0, len(metric))
+	for k := range metric {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	var sb strings.Builder
+	for _, k := range keys {
+		sb.WriteString(k)
+		sb.WriteByte(0)
+		sb.WriteString(metric[k])
+		sb.WriteByte(0)
+	}
+	return sb.String()
+}
+
+// formatVMValue is the inverse of parseVMValue: non-finite values are written
+// as the strings VictoriaMetrics uses in its export format.
+func formatVMValue(v float64) json.RawMessage {
+	switch {
+	case math.IsNaN(v):
+		return json.RawMessage(`"NaN"`)
+	case math.IsInf(v, 1):
+		return json.RawMessage(`"Infinity"`)
+	case math.IsInf(v, -1):
+		return json.RawMessage(`"-Infinity"`)
+	default:
+		b, _ := json.Marshal(v)
+		return b
+	}
+}
+
+// ──────────────────────────────────────────────────────────────────────────────
+// VictoriaLogs — /insert/jsonline
+// ──────────────────────────────────────────────────────────────────────────────
+
+// VLogsImporter pushes log entries into VictoriaLogs using JSON lines ingestion.
+type VLogsImporter struct {
+	targetClient
+}
+
+// NewVLogsImporter creates a VLogsImporter. baseURL is the insert endpoint of
+// the target, e.g. http://vlinsert:9481.
+func NewVLogsImporter(baseURL string) *VLogsImporter {
+	return &VLogsImporter{targetClient: newTargetClient("VictoriaLogs", baseURL)}
+}
+
+// vlogsStreamFields are the fields that identify a log stream on ingestion.
+var vlogsStreamFields = []string{ClusterField, "k8s.namespace.name", "k8s.pod.name", "service.name"}
+
+// Import pushes a batch of log entries.
+func (c *VLogsImporter) Import(ctx context.Context, rows []LogRow) error {
+	body, err := EncodeVLogsJSONLines(rows)
+	if err != nil {
+		return err
+	}
+	if len(body) == 0 {
+		return nil
+	}
+	params := url.Values{}
+	params.Set("_time_field", "_time")
+	params.Set("_msg_field", "_msg")
+	params.Set("_stream_fields", strings.Join(vlogsStreamFields, ","))
+	return c.post(ctx, "/insert/jsonline", params, "application/stream+json", body)
+}
+
+// EncodeVLogsJSONLines converts LogRows into flat VictoriaLogs JSON lines,
+// the inverse of parseVLogsLine.
+func EncodeVLogsJSONLines(rows []LogRow) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, r := range rows {
+		fields := make(map[string]string, len(r.Attributes)+12)
+		for k, v := range r.Attributes {
+			fields[k] = v
+		}
+		setNonEmpty(fields, "_time", time.Unix(0, r.TimestampNs).UTC().Format(time.RFC3339Nano))
+		setNonEmpty(fields, "_msg", r.Body)
+		setNonEmpty(fields, "severity", r.SeverityText)
+		setNonEmpty(fields, "service.name", r.ServiceName)
+		setNonEmpty(fields, "trace_id", r.TraceId)
+		setNonEmpty(fields, "span_id", r.SpanId)
+		setNonEmpty(fields, ClusterField, r.Cluster)
+		setNonEmpty(fields, "k8s.cluster.namespace", r.ClusterNamespace)
+		setNonEmpty(fields, "k8s.namespace.name", r.Namespace)
+		setNonEmpty(fields, "k8s.pod.name", r.Pod)
+		setNonEmpty(fields, "k8s.node.name", r.Node)
+		if r.Tenant != defaultTenant {
+			setNonEmpty(fields, "tenant", r.Tenant)
+		}
+		if err := enc.Encode(fields); err != nil {
+			return nil, fmt.Errorf("encode log line: %w", err)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func setNonEmpty(m map[string]string, key, value string) {
+	if value != "" {
+		m[key] = value
+	}
+}
+
+// ──────────────────────────────────────────────────────────────────────────────
+// VictoriaTraces — OTLP/HTTP
+// ──────────────────────────────────────────────────────────────────────────────
+
+// VTracesImporter pushes spans into VictoriaTraces over OTLP/HTTP (protobuf).
+type VTracesImporter struct {
+	targetClient
+}
+
+// NewVTracesImporter creates a VTracesImporter. baseURL is the insert endpoint
+// of the target, e.g. http://vtinsert:10481.
+func NewVTracesImporter(baseURL string) *VTracesImporter {
+	return &VTracesImporter{targetClient: newTargetClient("VictoriaTraces", baseURL)}
+}
+
+// Import pushes a batch of spans.
+func (c *VTracesImporter) Import(ctx context.Context, rows []TraceRow) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	body, err := new(ptrace.ProtoMarshaler).MarshalTraces(TraceRowsToOTLP(rows))
+	if err != nil {
+		return fmt.Errorf("marshal OTLP traces: %w", err)
+	}
+	return c.post(ctx, "/insert/opentelemetry/v1/traces", nil, "application/x-protobuf", body)
+}
+
+// TraceRowsToOTLP converts TraceRows into OTLP traces, the inverse of
+// parseVTracesLine. Spans sharing the same resource and instrumentation scope
+// are grouped into one ResourceSpans/ScopeSpans entry.
+func TraceRowsToOTLP(rows []TraceRow) ptrace.Traces {
+	traces := ptrace.NewTraces()
+	resources := make(map[string]ptrace.ResourceSpans)
+	scopes := make(map[string]ptrace.ScopeSpans)
+
+	for _, r := range rows {
+		resAttrs := traceResourceAttributes(r)
+		resKey := seriesKey(resAttrs)
+		rs, ok := resources[resKey]
+		if !ok {
+			rs = traces.ResourceSpans().AppendEmpty()
+			putStringAttributes(rs.Resource().Attributes(), resAttrs)
+			resources[resKey] = rs
+		}
+
+		spanAttrs := make(map[string]string, len(r.SpanAttributes))
+		for k, v := range r.SpanAttributes {
+			spanAttrs[k] = v
+		}
+		scopeName, scopeVersion := spanAttrs["otel.scope.name"], spanAttrs["otel.scope.version"]
+		delete(spanAttrs, "otel.scope.name")
+		delete(spanAttrs, "otel.scope.version")
+
+		scopeKey := resKey + "\x01" + scopeName + "\x00" + scopeVersion
+		ss, ok := scopes[scopeKey]
+		if !ok {
+			ss = rs.ScopeSpans().AppendEmpty()
+			ss.Scope().SetName(scopeName)
+			ss.Scope().SetVersion(scopeVersion)
+			scopes[scopeKey] = ss
+		}
+
+		span := ss.Spans().AppendEmpty()
+		var traceID pcommon.TraceID
+		var spanID, parentSpanID pcommon.SpanID
+		decodeHexID(r.TraceId, traceID[:])
+		decodeHexID(r.SpanId, spanID[:])
+		decodeHexID(r.ParentSpanId, parentSpanID[:])
+		span.SetTraceID(traceID)
+		span.SetSpanID(spanID)
+		span.SetParentSpanID(parentSpanID)
+		span.TraceState().FromRaw(r.TraceState)
+		span.SetFlags(r.TraceFlags)
+		span.SetName(r.SpanName)
+		span.SetKind(spanKindFromName(r.SpanKind))
+		span.SetStartTimestamp(pcommon.Timestamp(r.Timestamp))
+		span.SetEndTimestamp(pcommon.Timestamp(r.Timestamp + r.Duration))
+		span.Status().SetCode(statusCodeFromName(r.StatusCode))
+		span.Status().SetMessage(r.StatusMessage)
+		putStringAttributes(span.Attributes(), spanAttrs)
+
+		for _, ev := range r.Events {
+			event := span.Events().AppendEmpty()
+			event.SetTimestamp(pcommon.Timestamp(ev.TimestampNs))
+			event.SetName(ev.Name)
+			putStringAttributes(event.Attributes(), ev.Attributes)
+		}
+	}
+	return traces
+}
+
+// traceResourceAttributes rebuilds the resource attributes of a span from the
+// promoted columns and the remaining resource attributes.
+func traceResourceAttributes(r TraceRow) map[string]string {
+	attrs := make(map[string]string, len(r.ResourceAttributes)+7)
+	for k, v := range r.ResourceAttributes {
+		attrs[k] = v
+	}
+	setNonEmpty(attrs, "service.name", r.ServiceName)
+	setNonEmpty(attrs, "k8s.cluster.name", r.Cluster)
+	setNonEmpty(attrs, "k8s.cluster.namespace", r.ClusterNamespace)
+	setNonEmpty(attrs, "k8s.namespace.name", r.Namespace)
+	setNonEmpty(attrs, "k8s.pod.name", r.Pod)
+	setNonEmpty(attrs, "k8s.node.name", r.Node)
+	if _, ok := attrs["tenant"]; !ok && r.Tenant != defaultTenant {
+		setNonEmpty(attrs, "tenant", r.Tenant)
+	}
+	return attrs
+}
+
+// putStringAttributes copies attrs into m in sorted key order.
+func putStringAttributes(m pcommon.Map, attrs map[string]string) {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	m.EnsureCapacity(len(keys))
+	for _, k := range keys {
+		m.PutStr(k, attrs[k])
+	}
+}
+
+// decodeHexID decodes a hex trace or span ID into id. Invalid IDs leave id
+// all zeroes, which OTLP treats as empty.
+func decodeHexID(s string, id []byte) {
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != len(id) {
+		return
+	}
+	copy(id, b)
+}
+
+// spanKindFromName is the inverse of spanKindName.
+func spanKindFromName(s string) ptrace.SpanKind {
+	switch s {
+	case "SPAN_KIND_INTERNAL":
+		return ptrace.SpanKindInternal
+	case "SPAN_KIND_SERVER":
+		return ptrace.SpanKindServer
+	case "SPAN_KIND_CLIENT":
+		return ptrace.SpanKindClient
+	case "SPAN_KIND_PRODUCER":
+		return ptrace.SpanKindProducer
+	case "SPAN_KIND_CONSUMER":
+		return ptrace.SpanKindConsumer
+	default:
+		return ptrace.SpanKindUnspecified
+	}
+}
+
+// statusCodeFromName is the inverse of statusCodeName.
+func statusCodeFromName(s string) ptrace.StatusCode {
+	switch s {
+	case "STATUS_CODE_OK":
+		return ptrace.StatusCodeOk
+	case "STATUS_CODE_ERROR":
+		return ptrace.StatusCodeError
+	default:
+		return ptrace.StatusCodeUnset
+	}
+}
@@ -0,0 +1,358 @@
+// Copyright 2025
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package coldstorage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/go-logr/logr"
+	s3pkg "github.com/k0rdent/kof/kof-operator/internal/s3"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// memS3 implements s3pkg.RawAPI with an in-memory object store.
+type memS3 struct {
+	objects map[string][]byte
+}
+
+func (m *memS3) GetObjectLockConfiguration(_ context.Context, _ *s3.GetObjectLockConfigurationInput, _ ...func(*s3.Options)) (*s3.GetObjectLockConfigurationOutput, error) {
+	panic("not implemented")
+}
+func (m *memS3) HeadObject(_ context.Context, params *s3.HeadObjectInput, _ ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	if _, ok := m.objects[aws.ToString(params.Key)]; ok {
+		return &s3.HeadObjectOutput{}, nil
+	}
+	return nil, &types.NotFound{}
+}
+func (m *memS3) GetObject(_ context.Context, params *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	data, ok := m.objects[aws.ToString(params.Key)]
+	if !ok {
+		return nil, &types.NoSuchKey{}
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(data))}, nil
+}
+func (m *memS3) PutObject(_ context.Context, params *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	data, err := io.ReadAll(params.Body)
+	if err != nil {
+		return nil, err
+	}
+	m.objects[aws.ToString(params.Key)] = data
+	return &s3.PutObjectOutput{}, nil
+}
+func (m *memS3) ListObjectsV2(_ context.Context, params *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	keys := make([]string, 0)
+	for key := range m.objects {
+		if strings.HasPrefix(key, aws.ToString(params.Prefix)) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	out := &s3.ListObjectsV2Output{IsTruncated: aws.Bool(false)}
+	for _, key := range keys {
+		out.Contents = append(out.Contents, types.Object{Key: aws.String(key)})
+	}
+	return out, nil
+}
+
+func (m *memS3) ListObjectVersions(_ context.Context, _ *s3.ListObjectVersionsInput, _ ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error) {
+	panic("not implemented")
+}
+func (m *memS3) DeleteObjects(_ context.Context, params *s3.DeleteObjectsInput, _ ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+	for _, obj := range params.Delete.Objects {
+		delete(m.objects, aws.ToString(obj.Key))
+	}
+	return &s3.DeleteObjectsOutput{}, nil
+}
+
+var _ s3pkg.RawAPI = (*memS3)(nil)
+
+// importRecorder is a target server recording the bodies posted per path.
+type importRecorder struct {
+	mu     sync.Mutex
+	bodies map[string][]string
+	status int
+}
+
+func (rec *importRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if rec.status != 0 {
+		w.WriteHeader(rec.status)
+		return
+	}
+	rec.bodies[r.URL.Path] = append(rec.bodies[r.URL.Path], string(body))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func metricRowsFrom(lines ...string) []MetricRow {
+	var rows []MetricRow
+	Expect(ScanVMExport(strings.NewReader(strings.Join(lines, "\n")), func(batch []MetricRow) error {
+		rows = append(rows, batch...)
+		return nil
+	})).To(Succeed())
+	return rows
+}
+
+var _ = Describe("Parquet readers", func() {
+	It("reads back metric rows written by MetricsParquetWriter", func() {
+		rows := metricRowsFrom(metricsLine1, metricsLineTenanted)
+		var buf bytes.Buffer
+		w := NewMetricsParquetWriter(&buf)
+		Expect(w.Write(rows)).To(Succeed())
+		Expect(w.Close()).To(Succeed())
+
+		var read []MetricRow
+		Expect(ReadMetricsParquet(bytes.NewReader(buf.Bytes()), int64(buf.Len()), func(batch []MetricRow) error {
+			read = append(read, batch...)
+			return nil
+		})).To(Succeed())
+		Expect(read).To(Equal(rows))
+	})
+
+	It("reads back trace rows with events written by TracesParquetWriter", func() {
+		var rows []TraceRow
+		Expect(ScanVTracesExport(strings.NewReader(tracesLine1), "acme", "mothership", func(batch []TraceRow) error {
+			rows = append(rows, batch...)
+			return nil
+		})).To(Succeed())
+		rows[0].Events = []TraceEvent{{TimestampNs: 42, Name: "retry", Attributes: map[string]string{"attempt": "2"}}}
+
+		var buf bytes.Buffer
+		w := NewTracesParquetWriter(&buf)
+		Expect(w.Write(rows)).To(Succeed())
+		Expect(w.Close()).To(Succeed())
+
+		var read []TraceRow
+		Expect(ReadTracesParquet(bytes.NewReader(buf.Bytes()), int64(buf.Len()), func(batch []TraceRow) error {
+			read = append(read, batch...)
+			return nil
+		})).To(Succeed())
+		Expect(read).To(Equal(rows))
+	})
+})
+
+var _ = Describe("Restore encoders", func() {
+	It("groups samples per series and keeps non-finite values", func() {
+		body, err := EncodeVMImport(metricRowsFrom(metricsLineNaN, metricsLine2))
+		Expect(err).NotTo(HaveOccurred())
+
+		lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+		Expect(lines).To(HaveLen(2))
+		rows := metricRowsFrom(lines...)
+		Expect(rows).To(HaveLen(5))
+		Expect(math.IsNaN(rows[0].Value)).To(BeTrue())
+		Expect(math.IsInf(rows[1].Value, 1)).To(BeTrue())
+		Expect(rows[4].Pod).To(Equal("kof-collectors-opencost-5bf6d8cd6f-m7x9w"))
+		Expect(rows[4].Labels).To(HaveKeyWithValue("service", "kof-collectors-opencost"))
+	})
+
+	It("encodes log rows as VictoriaLogs JSON lines parsed back to the same rows", func() {
+		row := LogRow{
+			TimestampNs:  time.Date(2026, 5, 27, 10, 0, 0, 123, time.UTC).UnixNano(),
+			Body:         "hello",
+			SeverityText: "INFO",
+			ServiceName:  "api",
+			Tenant:       "acme",
+			Cluster:      "prod",
+			Namespace:    "default",
+			Pod:          "api-1",
+			Attributes:   map[string]string{"http.method": "GET"},
+		}
+		body, err := EncodeVLogsJSONLines([]LogRow{row})
+		Expect(err).NotTo(HaveOccurred())
+
+		var raw map[string]string
+		Expect(json.Unmarshal(body, &raw)).To(Succeed())
+		Expect(raw).To(HaveKeyWithValue("tenant", "acme"))
+		Expect(raw).To(HaveKeyWithValue(ClusterField, "prod"))
+		Expect(parseVLogsLine(raw, "acme", "prod")).To(Equal(row))
+	})
+
+	It("does not write the synthetic default tenant", func() {
+		body, err := EncodeVLogsJSONLines([]LogRow{{Tenant: defaultTenant, Cluster: "prod"}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(body)).NotTo(ContainSubstring(`"tenant"`))
+	})
+
+	It("converts trace rows into OTLP spans grouped by resource and scope", func() {
+		var rows []TraceRow
+		Expect(ScanVTracesExport(strings.NewReader(tracesLine1+"\n"+tracesLine2+"\n"+tracesLineServer),
+			"acme", "mothership", func(batch []TraceRow) error {
+				rows = append(rows, batch...)
+				return nil
+			})).To(Succeed())
+
+		traces := TraceRowsToOTLP(rows)
+		Expect(traces.SpanCount()).To(Equal(3))
+		Expect(traces.ResourceSpans().Len()).To(Equal(3))
+
+		rs := traces.ResourceSpans().At(0)
+		res := rs.Resource().Attributes().AsRaw()
+		Expect(res).To(HaveKeyWithValue("service.name", "kof-operator"))
+		Expect(res).To(HaveKeyWithValue("k8s.cluster.name", "mothership"))
+		Expect(res).To(HaveKeyWithValue("tenant", "acme"))
+
+		ss := rs.ScopeSpans().At(0)
+		Expect(ss.Scope().Name()).To(Equal("go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"))
+		span := ss.Spans().At(0)
+		Expect(span.TraceID().String()).To(Equal("1163d809514711c4503f981a9634c021"))
+		Expect(span.SpanID().String()).To(Equal("7e3fd52994e44de2"))
+		Expect(span.ParentSpanID().String()).To(Equal("d2e4efe260bfb927"))
+		Expect(span.Kind()).To(Equal(ptrace.SpanKindClient))
+		Expect(span.Flags()).To(Equal(uint32(1)))
+		Expect(int64(span.EndTimestamp() - span.StartTimestamp())).To(Equal(int64(6019458)))
+		Expect(span.Attributes().AsRaw()).To(HaveKeyWithValue("http.request.method", "GET"))
+		Expect(span.Attributes().AsRaw()).NotTo(HaveKey("otel.scope.name"))
+	})
+})
+
+var _ = Describe("Restorer", func() {
+	const (
+		tenant  = "acme"
+		cluster = "prod"
+	)
+
+	var (
+		ctx    context.Context
+		cfg    *Config
+		store  *memS3
+		rec    *importRecorder
+		server *httptest.Server
+		h10    = time.Date(2026, 5, 27, 10, 0, 0, 0, time.UTC)
+	)
+
+	putPartition := func(start time.Time, success bool, lines ...string) {
+		var buf bytes.Buffer
+		w := NewMetricsParquetWriter(&buf)
+		Expect(w.Write(metricRowsFrom(lines...))).To(Succeed())
+		Expect(w.Close()).To(Succeed())
+
+		prefix := ExportWindow{Source: SourceMetrics, Tenant: tenant, Cluster: cluster, Start: start}.S3KeyPrefix(cfg.S3Prefix)
+		store.objects[prefix+"metrics.parquet"] = buf.Bytes()
+		if success {
+			store.objects[prefix+"_SUCCESS"] = []byte{}
+		}
+	}
+
+	newRestorer := func(opts RestoreOptions) *Restorer {
+		return NewRestorer(cfg, s3pkg.NewClientFromRaw(store, "test-bucket"), opts, logr.Discard())
+	}
+
+	restoreOpts := func() RestoreOptions {
+		return RestoreOptions{
+			Sources: []string{SourceMetrics, SourceLogs},
+			Tenant:  tenant,
+			Cluster: cluster,
+			From:    h10,
+			To:      h10.Add(3 * time.Hour),
+			VMURL:   server.URL,
+		}
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		cfg = &Config{S3Bucket: "test-bucket", S3Prefix: "telemetry"}
+		store = &memS3{objects: make(map[string][]byte)}
+		rec = &importRecorder{bodies: make(map[string][]string)}
+		server = httptest.NewServer(rec)
+		DeferCleanup(server.Close)
+	})
+
+	It("restores exported partitions and skips those without _SUCCESS", func() {
+		putPartition(h10, true, metricsLineTenanted)
+		putPartition(h10.Add(time.Hour), false, metricsLineTenanted)
+		putPartition(h10.Add(2*time.Hour), true, metricsLine1)
+
+		opts := restoreOpts()
+		summary, err := newRestorer(opts).Run(ctx, opts)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(summary).To(Equal(RestoreSummary{Restored: 2, Incomplete: 1, Rows: 3}))
+
+		Expect(rec.bodies["/api/v1/import"]).To(HaveLen(2))
+		scanner := bufio.NewScanner(strings.NewReader(rec.bodies["/api/v1/import"][0]))
+		Expect(scanner.Scan()).To(BeTrue())
+		Expect(scanner.Text()).To(ContainSubstring(`"tenant":"acme"`))
+
+		marker := newRestorer(opts).RestoredMarkerKey(opts.DefaultRestoreID(),
+			ExportWindow{Source: SourceMetrics, Tenant: tenant, Cluster: cluster, Start: h10})
+		Expect(marker).To(HavePrefix("telemetry/_restore/" + opts.DefaultRestoreID() + "/tenant=acme/"))
+		Expect(store.objects).To(HaveKey(marker))
+	})
+
+	It("resumes after the partitions restored by a previous run", func() {
+		putPartition(h10, true, metricsLineTenanted)
+		opts := restoreOpts()
+		_, err := newRestorer(opts).Run(ctx, opts)
+		Expect(err).NotTo(HaveOccurred())
+
+		putPartition(h10.Add(time.Hour), true, metricsLine1)
+		summary, err := newRestorer(opts).Run(ctx, opts)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(summary).To(Equal(RestoreSummary{Restored: 1, Resumed: 1, Incomplete: 1, Rows: 2}))
+		Expect(rec.bodies["/api/v1/import"]).To(HaveLen(2))
+	})
+
+	It("halts without marking the partition restored when the target fails", func() {
+		putPartition(h10, true, metricsLineTenanted)
+		putPartition(h10.Add(time.Hour), true, metricsLineTenanted)
+		rec.status = http.StatusServiceUnavailable
+
+		opts := restoreOpts()
+		summary, err := newRestorer(opts).Run(ctx, opts)
+		var targetErr *TargetUnavailableError
+		Expect(errors.As(err, &targetErr)).To(BeTrue())
+		Expect(summary.Restored).To(BeZero())
+		for key := range store.objects {
+			Expect(key).NotTo(ContainSubstring("_RESTORED"))
+		}
+	})
+
+	It("counts unreadable partitions as failed and continues", func() {
+		prefix := ExportWindow{Source: SourceMetrics, Tenant: tenant, Cluster: cluster, Start: h10}.S3KeyPrefix(cfg.S3Prefix)
+		store.objects[prefix+"metrics.parquet"] = []byte("not parquet")
+		store.objects[prefix+"_SUCCESS"] = []byte{}
+		putPartition(h10.Add(time.Hour), true, metricsLineTenanted)
+
+		opts := restoreOpts()
+		summary, err := newRestorer(opts).Run(ctx, opts)
+		Expect(err).To(HaveOccurred())
+		Expect(summary).To(Equal(RestoreSummary{Restored: 1, Incomplete: 1, Failed: 1, Rows: 1}))
+	})
+
+	It("requires a target for at least one source", func() {
+		opts := restoreOpts()
+		opts.VMURL = ""
+		_, err := newRestorer(opts).Run(ctx, opts)
+		Expect(err).To(MatchError(ContainSubstring("no restore target")))
+	})
+})
@@ -19,7 +19,7 @@ import (
 )
 
 // S3Client is a type alias for s3pkg.Client.
-// It provides ObjectExists, PutObject, and UploadStream.
+// It provides ObjectExists, GetObject, ListKeys, PutObject, and UploadStream.
 type S3Client = s3pkg.Client
 
 // NewS3Client creates an S3Client configured for an S3-compatible endpoint.
@@ -22,6 +22,7 @@ import (
 	"time"
 
 	kcmv1beta1 "github.com/K0rdent/kcm/api/v1beta1"
+	kofv1beta1 "github.com/k0rdent/kof/kof-operator/api/v1beta1"
 	"github.com/k0rdent/kof/kof-operator/internal/k8s"
 	"github.com/k0rdent/kof/kof-operator/internal/models/labels"
 	"github.com/k0rdent/kof/kof-operator/internal/telemetry"
@@ -31,9 +32,12 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/util/workqueue"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
@@ -48,6 +52,9 @@ type ClusterDeploymentReconciler struct {
 
 // +kubebuilder:rbac:groups=k0rdent.mirantis.com,resources=clusterdeployments,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=k0rdent.mirantis.com,resources=clusterdeployments/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=kof.k0rdent.mirantis.com,resources=kofclusters,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=kof.k0rdent.mirantis.com,resources=kofclusters/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=kof.k0rdent.mirantis.com,resources=kofclusters/finalizers,verbs=update
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -70,6 +77,9 @@ func (r *ClusterDeploymentReconciler) Reconcile(ctx context.Context, req ctrl.Re
 		Namespace: req.Namespace,
 	}, clusterDeployment); err != nil {
 		if errors.IsNotFound(err) {
+			if err := CleanupRegionalMigration(ctx, r.Client, req.Name, req.Namespace); err != nil {
+				return ctrl.Result{}, err
+			}
 			return CleanupChildConfigMapMcsPropagation(ctx, r.Client, req.Name)
 		}
 
@@ -81,17 +91,20 @@ func (r *ClusterDeploymentReconciler) Reconcile(ctx context.Context, req ctrl.Re
 		return ctrl.Result{}, addClusterNameLabel(ctx, r.Client, clusterDeployment)
 	}
 
-	if err := r.ReconcileKofClusterRole(ctx, clusterDeployment); err != nil {
-		return ctrl.Result{}, err
-	}
-
-	return ctrl.Result{}, nil
+	return r.ReconcileKofClusterRole(ctx, clusterDeployment)
 }
 
 // SetupWithManager sets up the controller with the Manager.
+// A KofCluster is reconciled with the ClusterDeployment of the same name and namespace,
+// its status updates do not trigger reconciliation.
 func (r *ClusterDeploymentReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&kcmv1beta1.ClusterDeployment{}).
+		Watches(
+			&kofv1beta1.KofCluster{},
+			&handler.EnqueueRequestForObject{},
+			builder.WithPredicates(predicate.GenerationChangedPredicate{}),
+		).
 		WithOptions(controller.Options{
 			RateLimiter: workqueue.NewTypedItemExponentialFailureRateLimiter[reconcile.Request](
 				MinRetryDelay,
@@ -0,0 +1,384 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	kcmv1beta1 "github.com/K0rdent/kcm/api/v1beta1"
+	"github.com/k0rdent/kof/kof-operator/internal/controller/record"
+	"github.com/k0rdent/kof/kof-operator/internal/controller/vmuser"
+	"github.com/k0rdent/kof/kof-operator/internal/env"
+	"github.com/k0rdent/kof/kof-operator/internal/models/labels"
+	"github.com/k0rdent/kof/kof-operator/internal/names"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// DefaultRegionalMigrationOverlap is how long the previous regional cluster keeps the VMUser credentials
+// of a migrated child cluster after its write endpoints are switched to the new regional cluster.
+const DefaultRegionalMigrationOverlap = 24 * time.Hour
+
+// Phases of the regional cluster migration reported by KofRegionalMigrationPhaseAnnotation.
+const (
+	// RegionalMigrationCredentialsCreated means the VMUser credentials are propagated to the new regional cluster,
+	// while the child cluster still writes to the previous one.
+	RegionalMigrationCredentialsCreated = "CredentialsCreated"
+	// RegionalMigrationEndpointsSwitched means the child cluster writes to the new regional cluster,
+	// while the previous one keeps the credentials until the end of the overlap period.
+	RegionalMigrationEndpointsSwitched = "EndpointsSwitched"
+	// RegionalMigrationCompleted means the credentials are removed from the previous regional cluster.
+	RegionalMigrationCompleted = "Completed"
+)
+
+// clusterNamespaceLabel is the label used by the child cluster MultiClusterServices
+// to tell apart child clusters with the same name.
+const clusterNamespaceLabel = "cluster-namespace"
+
+// ReconcileRegionalMigration moves the child cluster to the regional cluster set by
+// KofRegionalMigrationAnnotation, one step per reconcile. The previous regional cluster keeps
+// the credentials of the child cluster for the overlap period, and stays readable through its own
+// PromxyServerGroup. The annotation keeps the child cluster on the new regional cluster afterwards.
+func (c *ChildClusterRole) ReconcileRegionalMigration() (ctrl.Result, error) {
+	if env.RegionlessEnabled() {
+		err := fmt.Errorf("regional cluster migration is not supported when regionless is enabled")
+		c.logRegionalMigrationFailure(err)
+		return ctrl.Result{}, err
+	}
+
+	// A running migration continues to the target it was started for, see reportRegionalMigrationTargetChange.
+	phase := c.clusterDeployment.Annotations[KofRegionalMigrationPhaseAnnotation]
+	_, hasRunningTarget := c.clusterDeployment.Annotations[KofRegionalMigrationTargetAnnotation]
+	targetAnnotation := KofRegionalMigrationAnnotation
+	if hasRunningTarget && (phase == RegionalMigrationCredentialsCreated || phase == RegionalMigrationEndpointsSwitched) {
+		targetAnnotation = KofRegionalMigrationTargetAnnotation
+	}
+
+	target, err := c.getRegionalMigrationTarget(targetAnnotation)
+	if err != nil {
+		c.logRegionalMigrationFailure(err)
+		return ctrl.Result{}, err
+	}
+	if targetAnnotation == KofRegionalMigrationTargetAnnotation {
+		c.reportRegionalMigrationTargetChange(target)
+	}
+
+	overlap, err := getRegionalMigrationOverlap(c.clusterDeployment)
+	if err != nil {
+		c.logRegionalMigrationFailure(err)
+		return ctrl.Result{}, err
+	}
+
+	switch phase {
+	case RegionalMigrationCredentialsCreated:
+		return ctrl.Result{}, c.switchRegionalMigrationEndpoints(target)
+	case RegionalMigrationEndpointsSwitched:
+		return c.finishRegionalMigration(target, overlap)
+	}
+
+	configMap, err := c.GetConfigMap()
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to get ConfigMap: %v", err)
+	}
+
+	// A child cluster without ConfigMap has nothing to migrate yet,
+	// so the annotation only pins it to the regional cluster.
+	if configMap == nil || isPreviouslyUsedRegionalCluster(configMap, target.configData) {
+		if err := c.reconcileRegional(target); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, c.setRegionalMigrationPhase(RegionalMigrationCompleted, target, map[string]string{
+			KofRegionalMigrationTargetAnnotation: "",
+		})
+	}
+
+	return ctrl.Result{}, c.startRegionalMigration(configMap.Data, target)
+}
+
+// startRegionalMigration keeps the credentials on the previous regional cluster
+// with a dedicated MultiClusterService, and propagates them to the new regional cluster.
+func (c *ChildClusterRole) startRegionalMigration(childConfigData map[string]string, target *RegionalClusterConfigMap) error {
+	sourceName := childConfigData[RegionalClusterNameKey]
+	sourceNamespace := childConfigData[RegionalClusterNamespaceKey]
+	if sourceNamespace == "" {
+		sourceNamespace = c.clusterNamespace
+	}
+
+	if err := c.vmUserManager.Propagate(c.ctx, c.getRegionalMigrationVMUserOptions(sourceName, sourceNamespace)); err != nil {
+		c.logRegionalMigrationFailure(err)
+		return fmt.Errorf("failed to keep VMUser credentials on the previous regional cluster: %v", err)
+	}
+
+	if err := c.CreateVMUserCredentials(target.clusterName); err != nil {
+		c.logRegionalMigrationFailure(err)
+		return fmt.Errorf("failed to create VM user credentials: %v", err)
+	}
+
+	return c.setRegionalMigrationPhase(RegionalMigrationCredentialsCreated, target, map[string]string{
+		KofRegionalMigrationSourceAnnotation:     sourceNamespace + "/" + sourceName,
+		KofRegionalMigrationTargetAnnotation:     target.clusterNamespace + "/" + target.clusterName,
+		KofRegionalMigrationSwitchedAtAnnotation: "",
+	})
+}
+
+// switchRegionalMigrationEndpoints updates the child ConfigMap with the endpoints of the new regional cluster.
+func (c *ChildClusterRole) switchRegionalMigrationEndpoints(target *RegionalClusterConfigMap) error {
+	if err := c.reconcileRegional(target); err != nil {
+		c.logRegionalMigrationFailure(err)
+		return err
+	}
+
+	return c.setRegionalMigrationPhase(RegionalMigrationEndpointsSwitched, target, map[string]string{
+		KofRegionalMigrationSwitchedAtAnnotation: time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+// finishRegionalMigration waits for the end of the overlap period
+// and removes the credentials from the previous regional cluster.
+func (c *ChildClusterRole) finishRegionalMigration(target *RegionalClusterConfigMap, overlap time.Duration) (ctrl.Result, error) {
+	log := log.FromContext(c.ctx)
+
+	if err := c.reconcileRegional(target); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	annotations := c.clusterDeployment.Annotations
+	switchedAt, err := time.Parse(time.RFC3339, annotations[KofRegionalMigrationSwitchedAtAnnotation])
+	if err != nil {
+		err = fmt.Errorf("invalid %q annotation: %v", KofRegionalMigrationSwitchedAtAnnotation, err)
+		c.logRegionalMigrationFailure(err)
+		return ctrl.Result{}, err
+	}
+
+	if remaining := time.Until(switchedAt.Add(overlap)); remaining > 0 {
+		log.Info("Waiting for the end of the regional cluster migration overlap",
+			"childClusterDeploymentName", c.clusterName,
+			"childClusterDeploymentNamespace", c.clusterNamespace,
+			"previousRegionalCluster", annotations[KofRegionalMigrationSourceAnnotation],
+			"remaining", remaining.String(),
+		)
+		return ctrl.Result{RequeueAfter: remaining}, nil
+	}
+
+	sourceNamespace, sourceName, _ := strings.Cut(annotations[KofRegionalMigrationSourceAnnotation], "/")
+	if err := c.vmUserManager.DeletePropagation(
+		c.ctx,
+		GetRegionalMigrationMCSName(c.clusterName, c.clusterNamespace, sourceName, sourceNamespace),
+	); err != nil {
+		c.logRegionalMigrationFailure(err)
+		return ctrl.Result{}, err
+	}
+
+	if err := c.withdrawPreviousRegionalCredentials(sourceName, sourceNamespace); err != nil {
+		c.logRegionalMigrationFailure(err)
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{}, c.setRegionalMigrationPhase(RegionalMigrationCompleted, target, map[string]string{
+		KofRegionalMigrationSourceAnnotation:     "",
+		KofRegionalMigrationTargetAnnotation:     "",
+		KofRegionalMigrationSwitchedAtAnnotation: "",
+	})
+}
+
+// withdrawPreviousRegionalCredentials deletes the VMUser and Secret propagated to the previous regional cluster.
+// The VMUser MultiClusterService keeps its services on selector mismatch,
+// so the copies stay on the previous regional cluster when it is no longer selected.
+func (c *ChildClusterRole) withdrawPreviousRegionalCredentials(sourceName, sourceNamespace string) error {
+	regionalCD := &kcmv1beta1.ClusterDeployment{}
+	if err := c.client.Get(c.ctx, types.NamespacedName{Name: sourceName, Namespace: sourceNamespace}, regionalCD); err != nil {
+		if apierrors.IsNotFound(err) {
+			log.FromContext(c.ctx).Info("Previous regional cluster not found, no credentials to withdraw",
+				"regionalClusterName", sourceName,
+				"regionalClusterNamespace", sourceNamespace,
+			)
+			return nil
+		}
+		return fmt.Errorf("failed to get previous regional cluster: %v", err)
+	}
+
+	regionalClient, err := c.newRegionalClient(regionalCD)
+	if err != nil {
+		return fmt.Errorf("failed to create client of previous regional cluster: %v", err)
+	}
+
+	vmUserName := GetVMUserName(GetConfigMapName(c.clusterName), c.clusterNamespace)
+	if err := vmuser.NewManager(regionalClient).DeletePropagated(c.ctx, vmUserName, c.clusterNamespace); err != nil {
+		return fmt.Errorf("failed to withdraw VMUser credentials from the previous regional cluster: %v", err)
+	}
+	return nil
+}
+
+// getRegionalMigrationTarget reads the regional cluster from the migration annotation,
+// formatted as "name" or "namespace/name".
+func (c *ChildClusterRole) getRegionalMigrationTarget(annotation string) (*RegionalClusterConfigMap, error) {
+	value := c.clusterDeployment.Annotations[annotation]
+	regionalClusterNamespace, regionalClusterName := c.parseRegionalMigrationTarget(value)
+	if regionalClusterName == "" || regionalClusterNamespace == "" {
+		return nil, fmt.Errorf("invalid %q annotation %q, expected name or namespace/name", annotation, value)
+	}
+	if regionalClusterNamespace != c.clusterNamespace && !env.CrossNamespaceEnabled() {
+		return nil, fmt.Errorf(
+			"regional cluster %s/%s is not in the child cluster namespace and crossNamespace is disabled",
+			regionalClusterNamespace,
+			regionalClusterName,
+		)
+	}
+
+	regionalClusterConfigMap, err := c.DiscoverRegionalClusterCm(regionalClusterName, regionalClusterNamespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get regional cluster ConfigMap of migration target: %v", err)
+	}
+	return NewRegionalClusterConfigMap(c.ctx, regionalClusterConfigMap, c.client)
+}
+
+// parseRegionalMigrationTarget returns the namespace and name of the regional cluster
+// from "name" or "namespace/name", the namespace defaults to the one of the child cluster.
+func (c *ChildClusterRole) parseRegionalMigrationTarget(value string) (string, string) {
+	regionalClusterNamespace, regionalClusterName, found := strings.Cut(value, "/")
+	if !found {
+		return c.clusterNamespace, value
+	}
+	return regionalClusterNamespace, regionalClusterName
+}
+
+// reportRegionalMigrationTargetChange warns when KofRegionalMigrationAnnotation was changed
+// after the running migration started. The running migration completes first, so the previous
+// regional cluster keeps the credentials for the overlap period and they are withdrawn afterwards.
+// The requested regional cluster is migrated to next, from the target of the running migration.
+func (c *ChildClusterRole) reportRegionalMigrationTargetChange(target *RegionalClusterConfigMap) {
+	namespace, name := c.parseRegionalMigrationTarget(c.clusterDeployment.Annotations[KofRegionalMigrationAnnotation])
+	if namespace == target.clusterNamespace && name == target.clusterName {
+		return
+	}
+
+	record.LogEvent(
+		c.ctx,
+		"RegionalMigrationTargetChanged",
+		"Regional cluster migration target changed, the running migration is completed first",
+		c.clusterDeployment,
+		nil,
+		"childClusterDeploymentName", c.clusterName,
+		"childClusterDeploymentNamespace", c.clusterNamespace,
+		"runningTarget", target.clusterNamespace+"/"+target.clusterName,
+		"requestedTarget", namespace+"/"+name,
+		"phase", c.clusterDeployment.Annotations[KofRegionalMigrationPhaseAnnotation],
+	)
+}
+
+// getRegionalMigrationVMUserOptions propagates the existing VMUser credentials of the child cluster
+// to the previous regional cluster, and withdraws them when the MultiClusterService is deleted.
+func (c *ChildClusterRole) getRegionalMigrationVMUserOptions(sourceName, sourceNamespace string) *vmuser.CreateOptions {
+	opts := c.getVMUserCreateOptions(sourceName)
+	opts.ExtraLabels[clusterNamespaceLabel] = c.clusterNamespace
+	opts.ExtraLabels[KofRegionalMigrationSourceLabel] = sourceName
+	opts.MCSConfig.Name = GetRegionalMigrationMCSName(c.clusterName, c.clusterNamespace, sourceName, sourceNamespace)
+	opts.MCSConfig.WithdrawServicesOnSelectorMismatch = true
+	return opts
+}
+
+// setRegionalMigrationPhase reports the phase on the child ClusterDeployment
+// and applies the other annotations, removing the ones with empty values.
+func (c *ChildClusterRole) setRegionalMigrationPhase(
+	phase string,
+	target *RegionalClusterConfigMap,
+	annotations map[string]string,
+) error {
+	cd := c.clusterDeployment
+	// Patch the annotations only, as the labels and config may carry the KofCluster applied in memory.
+	patch := client.MergeFrom(cd.DeepCopy())
+	changed := cd.Annotations[KofRegionalMigrationPhaseAnnotation] != phase
+	cd.Annotations[KofRegionalMigrationPhaseAnnotation] = phase
+	for key, value := range annotations {
+		if cd.Annotations[key] != value {
+			changed = true
+		}
+		if value == "" {
+			delete(cd.Annotations, key)
+		} else {
+			cd.Annotations[key] = value
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	if err := c.client.Patch(c.ctx, cd, patch); err != nil {
+		return fmt.Errorf("failed to update regional cluster migration phase: %v", err)
+	}
+
+	record.LogEvent(
+		c.ctx,
+		"RegionalMigration"+phase,
+		fmt.Sprintf("Regional cluster migration of child cluster: %s", phase),
+		cd,
+		nil,
+		"childClusterDeploymentName", c.clusterName,
+		"childClusterDeploymentNamespace", c.clusterNamespace,
+		"regionalClusterName", target.clusterName,
+		"regionalClusterNamespace", target.clusterNamespace,
+		"previousRegionalCluster", cd.Annotations[KofRegionalMigrationSourceAnnotation],
+	)
+	return nil
+}
+
+func (c *ChildClusterRole) logRegionalMigrationFailure(err error) {
+	record.LogEvent(
+		c.ctx,
+		"RegionalMigrationFailed",
+		"Failed to migrate child cluster to another regional cluster",
+		c.clusterDeployment,
+		err,
+		"childClusterDeploymentName", c.clusterName,
+		"childClusterDeploymentNamespace", c.clusterNamespace,
+		"phase", c.clusterDeployment.Annotations[KofRegionalMigrationPhaseAnnotation],
+	)
+}
+
+func getRegionalMigrationOverlap(cd *kcmv1beta1.ClusterDeployment) (time.Duration, error) {
+	value, ok := cd.Annotations[KofRegionalMigrationOverlapAnnotation]
+	if !ok {
+		return DefaultRegionalMigrationOverlap, nil
+	}
+
+	overlap, err := time.ParseDuration(value)
+	if err != nil || overlap < 0 {
+		return 0, fmt.Errorf("invalid %q annotation %q, expected a non-negative duration", KofRegionalMigrationOverlapAnnotation, value)
+	}
+	return overlap, nil
+}
+
+// CleanupRegionalMigration deletes the MultiClusterServices keeping the credentials
+// of the deleted child cluster on its previous regional clusters.
+func CleanupRegionalMigration(ctx context.Context, k8sClient client.Client, clusterName, clusterNamespace string) error {
+	mcsList := &kcmv1beta1.MultiClusterServiceList{}
+	if err := k8sClient.List(ctx, mcsList,
+		client.MatchingLabels{
+			labels.ClusterNameLabel: clusterName,
+			clusterNamespaceLabel:   clusterNamespace,
+		},
+		client.HasLabels{KofRegionalMigrationSourceLabel},
+	); err != nil {
+		return fmt.Errorf("failed to list regional cluster migration MultiClusterServices: %v", err)
+	}
+
+	for _, mcs := range mcsList.Items {
+		if err := k8sClient.Delete(ctx, &mcs); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to delete regional cluster migration MultiClusterService %s: %v", mcs.Name, err)
+		}
+	}
+	return nil
+}
+
+// GetRegionalMigrationMCSName returns the name of the MultiClusterService keeping
+// the credentials of the child cluster on its previous regional cluster.
+func GetRegionalMigrationMCSName(clusterName, clusterNamespace, regionalClusterName, regionalClusterNamespace string) string {
+	return names.FNVName(
+		"kof-vmuser-migration",
+		fmt.Sprintf("%s/%s/%s/%s", clusterNamespace, clusterName, regionalClusterNamespace, regionalClusterName),
+	)
+}
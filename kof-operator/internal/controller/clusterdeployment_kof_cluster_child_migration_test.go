@@ -0,0 +1,401 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	kcmv1beta1 "github.com/K0rdent/kcm/api/v1beta1"
+	vmv1beta1 "github.com/VictoriaMetrics/operator/api/operator/v1beta1"
+	kofv1beta1 "github.com/k0rdent/kof/kof-operator/api/v1beta1"
+	"github.com/k0rdent/kof/kof-operator/internal/controller/record"
+	"github.com/k0rdent/kof/kof-operator/internal/controller/vmuser"
+	"github.com/k0rdent/kof/kof-operator/internal/models/labels"
+	addoncontrollerv1beta1 "github.com/projectsveltos/addon-controller/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	k8sevents "k8s.io/client-go/tools/events"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const migrationNamespace = "migration"
+
+func newMigrationTestClient(t *testing.T, objects ...client.Object) client.Client {
+	t.Helper()
+
+	s := runtime.NewScheme()
+	for _, addToScheme := range []func(*runtime.Scheme) error{
+		clientgoscheme.AddToScheme,
+		kcmv1beta1.AddToScheme,
+		kofv1beta1.AddToScheme,
+		vmv1beta1.AddToScheme,
+		addoncontrollerv1beta1.AddToScheme,
+	} {
+		if err := addToScheme(s); err != nil {
+			t.Fatalf("failed to build scheme: %v", err)
+		}
+	}
+	return fake.NewClientBuilder().
+		WithScheme(s).
+		WithObjects(objects...).
+		WithStatusSubresource(&kofv1beta1.KofCluster{}).
+		Build()
+}
+
+func newMigrationRegionalConfigMap(name, domain string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      GetRegionalClusterConfigMapName(name),
+			Namespace: migrationNamespace,
+			Labels:    map[string]string{KofClusterRoleLabel: KofRoleRegional},
+		},
+		Data: map[string]string{
+			RegionalClusterNameKey:      name,
+			RegionalClusterNamespaceKey: migrationNamespace,
+			WriteMetricsKey:             "https://vmauth." + domain + "/vm/insert/0/prometheus/api/v1/write",
+		},
+	}
+}
+
+// reconcileMigration runs one reconcile of the child cluster and returns its refreshed ClusterDeployment.
+// The regionalClient stands for the clusters the VMUser credentials are propagated to.
+func reconcileMigration(
+	t *testing.T,
+	ctx context.Context,
+	k8sClient client.Client,
+	regionalClient client.Client,
+	name string,
+) (*kcmv1beta1.ClusterDeployment, ctrl.Result) {
+	t.Helper()
+
+	cd := &kcmv1beta1.ClusterDeployment{}
+	key := types.NamespacedName{Name: name, Namespace: migrationNamespace}
+	if err := k8sClient.Get(ctx, key, cd); err != nil {
+		t.Fatalf("failed to get ClusterDeployment: %v", err)
+	}
+
+	childClusterRole, err := NewChildClusterRole(ctx, cd, k8sClient)
+	if err != nil {
+		t.Fatalf("failed to create child cluster role: %v", err)
+	}
+	childClusterRole.newRegionalClient = func(*kcmv1beta1.ClusterDeployment) (client.Client, error) {
+		return regionalClient, nil
+	}
+	result, err := childClusterRole.Reconcile()
+	if err != nil {
+		t.Fatalf("failed to reconcile child cluster: %v", err)
+	}
+
+	if err := k8sClient.Get(ctx, key, cd); err != nil {
+		t.Fatalf("failed to get ClusterDeployment: %v", err)
+	}
+	return cd, result
+}
+
+func TestRegionalMigration(t *testing.T) {
+	t.Setenv("RELEASE_NAMESPACE", migrationNamespace)
+	t.Setenv("KOF_REGIONLESS_ENABLED", "false")
+	t.Setenv("CROSS_NAMESPACE", "false")
+	record.DefaultRecorder = new(k8sevents.FakeRecorder)
+
+	const childName = "child"
+	ctx := context.Background()
+
+	child := &kcmv1beta1.ClusterDeployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      childName,
+			Namespace: migrationNamespace,
+			Labels: map[string]string{
+				KofClusterRoleLabel:     KofRoleChild,
+				labels.ClusterNameLabel: childName,
+			},
+			Annotations: map[string]string{
+				KofRegionalMigrationAnnotation:        "new",
+				KofRegionalMigrationOverlapAnnotation: "1h",
+			},
+		},
+		Spec: kcmv1beta1.ClusterDeploymentSpec{
+			Config: &apiextensionsv1.JSON{Raw: []byte(`{}`)},
+		},
+	}
+	oldRegional := newMigrationRegionalConfigMap("old", "old.example.com")
+	newRegional := newMigrationRegionalConfigMap("new", "new.example.com")
+	childConfigMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      GetConfigMapName(childName),
+			Namespace: migrationNamespace,
+			Labels:    map[string]string{KofClusterRoleLabel: KofRoleChild},
+		},
+		Data: oldRegional.Data,
+	}
+	oldRegionalCD := &kcmv1beta1.ClusterDeployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "old", Namespace: migrationNamespace},
+	}
+	k8sClient := newMigrationTestClient(t, child, oldRegionalCD, oldRegional, newRegional, childConfigMap)
+
+	vmUserName := GetVMUserName(GetConfigMapName(childName), migrationNamespace)
+	// The old regional cluster has the copies propagated by the VMUser MultiClusterService.
+	propagatedVMUser := &vmv1beta1.VMUser{
+		ObjectMeta: metav1.ObjectMeta{Name: vmuser.BuildVMUserName(vmUserName), Namespace: migrationNamespace},
+	}
+	propagatedSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: vmuser.BuildSecretName(vmUserName), Namespace: migrationNamespace},
+	}
+	regionalClient := newMigrationTestClient(t, propagatedVMUser, propagatedSecret)
+	migrationMCSName := GetRegionalMigrationMCSName(childName, migrationNamespace, "old", migrationNamespace)
+
+	getMCS := func(name string) (*kcmv1beta1.MultiClusterService, error) {
+		mcs := &kcmv1beta1.MultiClusterService{}
+		return mcs, k8sClient.Get(ctx, types.NamespacedName{Name: name}, mcs)
+	}
+	getChildConfigMap := func() *corev1.ConfigMap {
+		configMap := &corev1.ConfigMap{}
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(childConfigMap), configMap); err != nil {
+			t.Fatalf("failed to get child ConfigMap: %v", err)
+		}
+		return configMap
+	}
+
+	// Credentials are propagated to the new regional cluster and kept on the old one.
+	cd, _ := reconcileMigration(t, ctx, k8sClient, regionalClient, childName)
+	if phase := cd.Annotations[KofRegionalMigrationPhaseAnnotation]; phase != RegionalMigrationCredentialsCreated {
+		t.Fatalf("expected phase %q, got %q", RegionalMigrationCredentialsCreated, phase)
+	}
+	if source := cd.Annotations[KofRegionalMigrationSourceAnnotation]; source != migrationNamespace+"/old" {
+		t.Fatalf("unexpected migration source %q", source)
+	}
+	if target := cd.Annotations[KofRegionalMigrationTargetAnnotation]; target != migrationNamespace+"/new" {
+		t.Fatalf("unexpected migration target %q", target)
+	}
+	mcs, err := getMCS(vmuser.BuildMCSName(vmUserName))
+	if err != nil {
+		t.Fatalf("failed to get VMUser MultiClusterService: %v", err)
+	}
+	if regional := mcs.Spec.ClusterSelector.MatchLabels[labels.ClusterNameLabel]; regional != "new" {
+		t.Fatalf("expected VMUser to be propagated to the new regional cluster, got %q", regional)
+	}
+	mcs, err = getMCS(migrationMCSName)
+	if err != nil {
+		t.Fatalf("failed to get migration MultiClusterService: %v", err)
+	}
+	if regional := mcs.Spec.ClusterSelector.MatchLabels[labels.ClusterNameLabel]; regional != "old" {
+		t.Fatalf("expected VMUser to be kept on the old regional cluster, got %q", regional)
+	}
+	if mcs.Spec.KeepServicesOnSelectorMismatch {
+		t.Fatal("expected migration MultiClusterService to withdraw its services")
+	}
+	if name := getChildConfigMap().Data[RegionalClusterNameKey]; name != "old" {
+		t.Fatalf("expected child to keep writing to the old regional cluster, got %q", name)
+	}
+
+	// Write endpoints are switched to the new regional cluster.
+	cd, _ = reconcileMigration(t, ctx, k8sClient, regionalClient, childName)
+	if phase := cd.Annotations[KofRegionalMigrationPhaseAnnotation]; phase != RegionalMigrationEndpointsSwitched {
+		t.Fatalf("expected phase %q, got %q", RegionalMigrationEndpointsSwitched, phase)
+	}
+	if data := getChildConfigMap().Data; data[WriteMetricsKey] != newRegional.Data[WriteMetricsKey] {
+		t.Fatalf("expected write endpoints of the new regional cluster, got %q", data[WriteMetricsKey])
+	}
+
+	// The old regional cluster keeps the credentials during the overlap.
+	cd, result := reconcileMigration(t, ctx, k8sClient, regionalClient, childName)
+	if phase := cd.Annotations[KofRegionalMigrationPhaseAnnotation]; phase != RegionalMigrationEndpointsSwitched {
+		t.Fatalf("expected phase %q during overlap, got %q", RegionalMigrationEndpointsSwitched, phase)
+	}
+	if result.RequeueAfter <= 0 || result.RequeueAfter > time.Hour {
+		t.Fatalf("expected requeue at the end of the overlap, got %v", result.RequeueAfter)
+	}
+	if _, err := getMCS(migrationMCSName); err != nil {
+		t.Fatalf("expected migration MultiClusterService during overlap: %v", err)
+	}
+	if err := regionalClient.Get(ctx, client.ObjectKeyFromObject(propagatedVMUser), &vmv1beta1.VMUser{}); err != nil {
+		t.Fatalf("expected VMUser on the old regional cluster during overlap: %v", err)
+	}
+
+	// After the overlap the credentials are removed from the old regional cluster.
+	cd.Annotations[KofRegionalMigrationSwitchedAtAnnotation] = time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339)
+	if err := k8sClient.Update(ctx, cd); err != nil {
+		t.Fatalf("failed to update ClusterDeployment: %v", err)
+	}
+	cd, _ = reconcileMigration(t, ctx, k8sClient, regionalClient, childName)
+	if phase := cd.Annotations[KofRegionalMigrationPhaseAnnotation]; phase != RegionalMigrationCompleted {
+		t.Fatalf("expected phase %q, got %q", RegionalMigrationCompleted, phase)
+	}
+	if _, ok := cd.Annotations[KofRegionalMigrationSourceAnnotation]; ok {
+		t.Fatal("expected migration source annotation to be removed")
+	}
+	if _, ok := cd.Annotations[KofRegionalMigrationTargetAnnotation]; ok {
+		t.Fatal("expected migration target annotation to be removed")
+	}
+	if _, err := getMCS(migrationMCSName); !apierrors.IsNotFound(err) {
+		t.Fatalf("expected migration MultiClusterService to be deleted, got %v", err)
+	}
+	if err := regionalClient.Get(ctx, client.ObjectKeyFromObject(propagatedVMUser), &vmv1beta1.VMUser{}); !apierrors.IsNotFound(err) {
+		t.Fatalf("expected VMUser to be withdrawn from the old regional cluster, got %v", err)
+	}
+	if err := regionalClient.Get(ctx, client.ObjectKeyFromObject(propagatedSecret), &corev1.Secret{}); !apierrors.IsNotFound(err) {
+		t.Fatalf("expected Secret to be withdrawn from the old regional cluster, got %v", err)
+	}
+
+	// The annotation keeps the child cluster on the new regional cluster.
+	cd, _ = reconcileMigration(t, ctx, k8sClient, regionalClient, childName)
+	if phase := cd.Annotations[KofRegionalMigrationPhaseAnnotation]; phase != RegionalMigrationCompleted {
+		t.Fatalf("expected phase %q, got %q", RegionalMigrationCompleted, phase)
+	}
+	if name := getChildConfigMap().Data[RegionalClusterNameKey]; name != "new" {
+		t.Fatalf("expected child to stay on the new regional cluster, got %q", name)
+	}
+}
+
+func TestRegionalMigrationTargetChange(t *testing.T) {
+	t.Setenv("RELEASE_NAMESPACE", migrationNamespace)
+	t.Setenv("KOF_REGIONLESS_ENABLED", "false")
+	t.Setenv("CROSS_NAMESPACE", "false")
+	record.DefaultRecorder = new(k8sevents.FakeRecorder)
+
+	const childName = "child"
+
+	for _, phase := range []string{RegionalMigrationCredentialsCreated, RegionalMigrationEndpointsSwitched} {
+		t.Run(phase, func(t *testing.T) {
+			ctx := context.Background()
+			child := &kcmv1beta1.ClusterDeployment{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      childName,
+					Namespace: migrationNamespace,
+					Labels: map[string]string{
+						KofClusterRoleLabel:     KofRoleChild,
+						labels.ClusterNameLabel: childName,
+					},
+					Annotations: map[string]string{
+						KofRegionalMigrationAnnotation:        "new",
+						KofRegionalMigrationOverlapAnnotation: "1h",
+					},
+				},
+				Spec: kcmv1beta1.ClusterDeploymentSpec{
+					Config: &apiextensionsv1.JSON{Raw: []byte(`{}`)},
+				},
+			}
+			oldRegional := newMigrationRegionalConfigMap("old", "old.example.com")
+			newRegional := newMigrationRegionalConfigMap("new", "new.example.com")
+			otherRegional := newMigrationRegionalConfigMap("other", "other.example.com")
+			childConfigMap := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      GetConfigMapName(childName),
+					Namespace: migrationNamespace,
+					Labels:    map[string]string{KofClusterRoleLabel: KofRoleChild},
+				},
+				Data: oldRegional.Data,
+			}
+			k8sClient := newMigrationTestClient(t, child, oldRegional, newRegional, otherRegional, childConfigMap)
+			regionalClient := newMigrationTestClient(t)
+			vmUserName := GetVMUserName(GetConfigMapName(childName), migrationNamespace)
+
+			getRegionals := func() (string, string) {
+				t.Helper()
+				configMap := &corev1.ConfigMap{}
+				if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(childConfigMap), configMap); err != nil {
+					t.Fatalf("failed to get child ConfigMap: %v", err)
+				}
+				mcs := &kcmv1beta1.MultiClusterService{}
+				if err := k8sClient.Get(ctx, types.NamespacedName{Name: vmuser.BuildMCSName(vmUserName)}, mcs); err != nil {
+					t.Fatalf("failed to get VMUser MultiClusterService: %v", err)
+				}
+				return configMap.Data[RegionalClusterNameKey], mcs.Spec.ClusterSelector.MatchLabels[labels.ClusterNameLabel]
+			}
+			retarget := func(cd *kcmv1beta1.ClusterDeployment, annotations map[string]string) {
+				t.Helper()
+				for key, value := range annotations {
+					cd.Annotations[key] = value
+				}
+				if err := k8sClient.Update(ctx, cd); err != nil {
+					t.Fatalf("failed to update ClusterDeployment: %v", err)
+				}
+			}
+
+			cd, _ := reconcileMigration(t, ctx, k8sClient, regionalClient, childName)
+			if phase == RegionalMigrationEndpointsSwitched {
+				cd, _ = reconcileMigration(t, ctx, k8sClient, regionalClient, childName)
+			}
+			if got := cd.Annotations[KofRegionalMigrationPhaseAnnotation]; got != phase {
+				t.Fatalf("expected phase %q, got %q", phase, got)
+			}
+
+			// The running migration continues to the regional cluster it was started for.
+			retarget(cd, map[string]string{KofRegionalMigrationAnnotation: "other"})
+			cd, _ = reconcileMigration(t, ctx, k8sClient, regionalClient, childName)
+			if got := cd.Annotations[KofRegionalMigrationPhaseAnnotation]; got != RegionalMigrationEndpointsSwitched {
+				t.Fatalf("expected phase %q, got %q", RegionalMigrationEndpointsSwitched, got)
+			}
+			if target := cd.Annotations[KofRegionalMigrationTargetAnnotation]; target != migrationNamespace+"/new" {
+				t.Fatalf("expected migration target to be kept, got %q", target)
+			}
+			if configMapRegional, vmUserRegional := getRegionals(); configMapRegional != "new" || vmUserRegional != "new" {
+				t.Fatalf("expected child to migrate to the new regional cluster, got ConfigMap %q and VMUser %q",
+					configMapRegional, vmUserRegional)
+			}
+
+			// After the overlap the running migration completes.
+			retarget(cd, map[string]string{
+				KofRegionalMigrationSwitchedAtAnnotation: time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339),
+			})
+			cd, _ = reconcileMigration(t, ctx, k8sClient, regionalClient, childName)
+			if got := cd.Annotations[KofRegionalMigrationPhaseAnnotation]; got != RegionalMigrationCompleted {
+				t.Fatalf("expected phase %q, got %q", RegionalMigrationCompleted, got)
+			}
+
+			// Then the requested regional cluster is migrated to, from the new one.
+			cd, _ = reconcileMigration(t, ctx, k8sClient, regionalClient, childName)
+			if got := cd.Annotations[KofRegionalMigrationPhaseAnnotation]; got != RegionalMigrationCredentialsCreated {
+				t.Fatalf("expected phase %q, got %q", RegionalMigrationCredentialsCreated, got)
+			}
+			if source := cd.Annotations[KofRegionalMigrationSourceAnnotation]; source != migrationNamespace+"/new" {
+				t.Fatalf("unexpected migration source %q", source)
+			}
+			if target := cd.Annotations[KofRegionalMigrationTargetAnnotation]; target != migrationNamespace+"/other" {
+				t.Fatalf("unexpected migration target %q", target)
+			}
+			if configMapRegional, vmUserRegional := getRegionals(); configMapRegional != "new" || vmUserRegional != "other" {
+				t.Fatalf("expected credentials on the other regional cluster, got ConfigMap %q and VMUser %q",
+					configMapRegional, vmUserRegional)
+			}
+		})
+	}
+}
+
+func TestRegionalMigrationCleanup(t *testing.T) {
+	ctx := context.Background()
+	migrationMCS := &kcmv1beta1.MultiClusterService{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: GetRegionalMigrationMCSName("child", migrationNamespace, "old", migrationNamespace),
+			Labels: map[string]string{
+				labels.ClusterNameLabel:         "child",
+				clusterNamespaceLabel:           migrationNamespace,
+				KofRegionalMigrationSourceLabel: "old",
+			},
+		},
+	}
+	vmUserMCS := &kcmv1beta1.MultiClusterService{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   vmuser.BuildMCSName("child"),
+			Labels: map[string]string{labels.ClusterNameLabel: "child"},
+		},
+	}
+	k8sClient := newMigrationTestClient(t, migrationMCS, vmUserMCS)
+
+	if err := CleanupRegionalMigration(ctx, k8sClient, "child", migrationNamespace); err != nil {
+		t.Fatalf("failed to cleanup regional migration: %v", err)
+	}
+	if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(migrationMCS), migrationMCS); !apierrors.IsNotFound(err) {
+		t.Fatalf("expected migration MultiClusterService to be deleted, got %v", err)
+	}
+	if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(vmUserMCS), vmUserMCS); err != nil {
+		t.Fatalf("expected VMUser MultiClusterService to be kept: %v", err)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"slices"
 
 	kcmv1beta1 "github.com/K0rdent/kcm/api/v1beta1"
+	kofv1beta1 "github.com/k0rdent/kof/kof-operator/api/v1beta1"
 	"github.com/k0rdent/kof/kof-operator/internal/controller/record"
 	"github.com/k0rdent/kof/kof-operator/internal/controller/vmuser"
 	"github.com/k0rdent/kof/kof-operator/internal/env"
@@ -20,6 +21,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	k8slabels "k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/log"
@@ -35,6 +37,9 @@ type ChildClusterRole struct {
 	clusterDeploymentConfig *ClusterDeploymentConfig
 	ownerReference          *metav1.OwnerReference
 	vmUserManager           *vmuser.Manager
+	// newRegionalClient returns the client of a regional cluster, used to withdraw
+	// the credentials propagated to the previous regional cluster after a migration.
+	newRegionalClient func(cd *kcmv1beta1.ClusterDeployment) (client.Client, error)
 }
 
 func NewChildClusterRole(ctx context.Context, cd *kcmv1beta1.ClusterDeployment, client client.Client) (*ChildClusterRole, error) {
@@ -58,15 +63,36 @@ func NewChildClusterRole(ctx context.Context, cd *kcmv1beta1.ClusterDeployment,
 		ownerReference:          ownerReference,
 		isClusterInRegion:       k8s.CreatedInKCMRegion(cd),
 		vmUserManager:           vmuser.NewManager(client),
+		newRegionalClient:       regionalClientFactory(ctx, client),
 	}, nil
 }
 
-func (c *ChildClusterRole) Reconcile() error {
+// regionalClientFactory returns a function creating the client of a regional cluster from its kubeconfig secret.
+func regionalClientFactory(ctx context.Context, k8sClient client.Client) func(*kcmv1beta1.ClusterDeployment) (client.Client, error) {
+	return func(regionalCD *kcmv1beta1.ClusterDeployment) (client.Client, error) {
+		kubeClient, err := k8s.NewKubeClientFromClusterDeployment(ctx, k8sClient, regionalCD)
+		if err != nil {
+			return nil, err
+		}
+		return kubeClient.Client, nil
+	}
+}
+
+func (c *ChildClusterRole) Reconcile() (ctrl.Result, error) {
+	if _, ok := c.clusterDeployment.Annotations[KofRegionalMigrationAnnotation]; ok {
+		return c.ReconcileRegionalMigration()
+	}
+
 	regionalConfigMap, err := c.GetRegionalConfigMap()
 	if err != nil {
-		return fmt.Errorf("failed to get regional cluster: %v", err)
+		return ctrl.Result{}, fmt.Errorf("failed to get regional cluster: %v", err)
 	}
 
+	return ctrl.Result{}, c.reconcileRegional(regionalConfigMap)
+}
+
+// reconcileRegional points the child ConfigMap and VMUser credentials to the regional cluster.
+func (c *ChildClusterRole) reconcileRegional(regionalConfigMap *RegionalClusterConfigMap) error {
 	if err := c.CreateOrUpdateConfigMap(regionalConfigMap.configMap.Data); err != nil {
 		return fmt.Errorf("failed to create or update config map: %v", err)
 	}
@@ -79,6 +105,10 @@ func (c *ChildClusterRole) Reconcile() error {
 }
 
 func (c *ChildClusterRole) CreateVMUserCredentials(regionalClusterName string) error {
+	return c.vmUserManager.Create(c.ctx, c.getVMUserCreateOptions(regionalClusterName))
+}
+
+func (c *ChildClusterRole) getVMUserCreateOptions(regionalClusterName string) *vmuser.CreateOptions {
 	opts := &vmuser.CreateOptions{
 		Name:           GetVMUserName(GetConfigMapName(c.clusterName), c.clusterNamespace),
 		Namespace:      c.clusterNamespace,
@@ -109,7 +139,7 @@ func (c *ChildClusterRole) CreateVMUserCredentials(regionalClusterName string) e
 		ExtraLabel:   &vmuser.ExtraLabel{Key: "tenant", Value: tenantID},
 	}
 
-	return c.vmUserManager.Create(c.ctx, opts)
+	return opts
 }
 
 func (c *ChildClusterRole) GetConfigMap() (*corev1.ConfigMap, error) {
@@ -161,7 +191,6 @@ func (c *ChildClusterRole) DiscoverRegionalClusterCmByLabel(regionalClusterName
 	log := log.FromContext(c.ctx)
 	crossNamespace := env.CrossNamespaceEnabled()
 	regionalClusterNamespace := c.clusterNamespace
-	regionalClusterConfigMap := new(corev1.ConfigMap)
 
 	if crossNamespace {
 		regionalClusterNamespace, ok = c.clusterDeployment.Labels[KofRegionalClusterNamespaceLabel]
@@ -177,6 +206,19 @@ func (c *ChildClusterRole) DiscoverRegionalClusterCmByLabel(regionalClusterName
 		}
 	}
 
+	return c.DiscoverRegionalClusterCm(regionalClusterName, regionalClusterNamespace)
+}
+
+// DiscoverRegionalClusterCm returns the ConfigMap of the regional cluster,
+// checking that it is in the KCM region of the child cluster if any.
+func (c *ChildClusterRole) DiscoverRegionalClusterCm(
+	regionalClusterName string,
+	regionalClusterNamespace string,
+) (*corev1.ConfigMap, error) {
+	log := log.FromContext(c.ctx)
+	crossNamespace := env.CrossNamespaceEnabled()
+	regionalClusterConfigMap := new(corev1.ConfigMap)
+
 	if c.isClusterInRegion {
 		isSameRegion, err := k8s.IsClusterInSameKcmRegion(c.ctx, c.client,
 			c.clusterName, c.clusterNamespace,
@@ -254,34 +296,122 @@ func (c *ChildClusterRole) DiscoverRegionalClusterConfigMapByLocation() (*corev1
 		return nil, fmt.Errorf("regionless is enabled but no regionless ConfigMap was found")
 	}
 
-	childCloud, err := getCloud(c.ctx, c.client, c.clusterDeployment)
+	child := &placementChild{
+		config:       c.clusterDeploymentConfig,
+		inKcmRegion:  c.isClusterInRegion,
+		affinity:     c.clusterDeployment.Labels[KofRegionalAffinityLabel],
+		antiAffinity: c.clusterDeployment.Labels[KofRegionalAntiAffinityLabel],
+	}
+
+	// Child clusters of unknown cloud are placed by the rest of the score, without location match.
+	childCloud, cloudErr := getCloud(c.ctx, c.client, c.clusterDeployment)
+	if cloudErr != nil {
+		if _, ok := cloudErr.(*UnknownCloudProviderError); !ok {
+			return nil, fmt.Errorf("failed to get child cluster cloud: %v", cloudErr)
+		}
+		log.Info("Discovering regional cluster of child cluster without location",
+			"reason", cloudErr.Error(),
+			"childClusterDeploymentName", c.clusterName,
+			"childClusterDeploymentNamespace", c.clusterNamespace,
+		)
+	}
+	child.cloud = childCloud
+
+	configMap, err := c.GetConfigMap()
 	if err != nil {
-		if _, ok := err.(*UnknownCloudProviderError); ok {
-			if crossNamespace {
-				return nil, fmt.Errorf(
-					"cannot discover regional cluster by location: %v; please set .metadata.labels[%q] and .metadata.labels[%q] explicitly",
-					err,
-					KofRegionalClusterNameLabel,
-					KofRegionalClusterNamespaceLabel,
-				)
-			}
-			return nil, fmt.Errorf(
-				"cannot discover regional cluster by location: %v; please set .metadata.labels[%q] explicitly",
+		return nil, fmt.Errorf("failed to get ConfigMap: %v", err)
+	}
+	child.configMap = configMap
+
+	candidates, err := c.getRegionalCandidates(regionalClusterConfigMapList.Items, opts.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	for _, candidate := range candidates {
+		candidate.evaluate(child)
+	}
+	selected := pickRegionalCandidate(candidates, child)
+	breakdown := describeRegionalCandidates(candidates)
+
+	if configMap != nil && (selected == nil || !isPreviouslyUsedRegionalCluster(configMap, selected.configData)) {
+		err := fmt.Errorf(
+			"previously used regional cluster is not eligible anymore (candidates: %s), "+
+				`please set .metadata.labels["%s"] explicitly`,
+			breakdown,
+			KofRegionalClusterNameLabel,
+		)
+		record.LogEvent(
+			c.ctx,
+			"RegionalClusterConfigMapDiscoveryFailed",
+			"Failed to discover regional cluster ConfigMap",
+			c.clusterDeployment,
+			err,
+			"childClusterDeploymentName", c.clusterName,
+			"childClusterDeploymentNamespace", c.clusterNamespace,
+			"oldRegionalClusterName", configMap.Data[RegionalClusterNameKey],
+			"oldRegionalClusterNamespace", configMap.Data[RegionalClusterNamespaceKey],
+		)
+		return nil, err
+	}
+
+	if selected == nil {
+		if cloudErr != nil {
+			err = fmt.Errorf("cannot discover regional cluster by location: %v", cloudErr)
+		} else {
+			err = fmt.Errorf("regional cluster ConfigMap with matching location is not found")
+		}
+		if len(candidates) > 0 {
+			err = fmt.Errorf("%v (candidates: %s)", err, breakdown)
+		}
+		if crossNamespace {
+			err = fmt.Errorf(
+				"%v; please set .metadata.labels[%q] and .metadata.labels[%q] explicitly",
 				err,
 				KofRegionalClusterNameLabel,
+				KofRegionalClusterNamespaceLabel,
 			)
+		} else {
+			err = fmt.Errorf("%v; please set .metadata.labels[%q] explicitly", err, KofRegionalClusterNameLabel)
 		}
-		return nil, fmt.Errorf("failed to get child cluster cloud: %v", err)
+		record.LogEvent(
+			c.ctx,
+			"RegionalClusterDiscoveryFailed",
+			"Failed to discover regional cluster",
+			c.clusterDeployment,
+			err,
+			"childClusterDeploymentName", c.clusterName,
+			"childClusterDeploymentNamespace", c.clusterNamespace,
+			"crossNamespace", crossNamespace,
+		)
+		return nil, err
 	}
 
-	configMap, err := c.GetConfigMap()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get ConfigMap: %v", err)
+	if configMap == nil {
+		record.LogEvent(
+			c.ctx,
+			"RegionalClusterSelected",
+			fmt.Sprintf("Selected regional cluster %s with score %s, candidates: %s", selected.key(), selected.score, breakdown),
+			c.clusterDeployment,
+			nil,
+			"childClusterDeploymentName", c.clusterName,
+			"childClusterDeploymentNamespace", c.clusterNamespace,
+			"regionalClusterName", selected.configData.RegionalClusterName,
+			"regionalClusterNamespace", selected.configData.RegionalClusterNamespace,
+		)
 	}
+	return selected.configMap, nil
+}
 
-	candidates := make([]*corev1.ConfigMap, 0)
-	cdsInSameRegion := make([]*kcmv1beta1.ClusterDeployment, 0)
+// getRegionalCandidates collects the facts about the regional clusters needed to score them.
+// The namespace limits the count of child clusters and is empty for all namespaces.
+func (c *ChildClusterRole) getRegionalCandidates(
+	regionalConfigMaps []corev1.ConfigMap,
+	namespace string,
+) ([]*regionalCandidate, error) {
+	log := log.FromContext(c.ctx)
 
+	var err error
+	var cdsInSameRegion []*kcmv1beta1.ClusterDeployment
 	if c.isClusterInRegion {
 		cdsInSameRegion, err = k8s.GetClusterDeploymentsInSameKcmRegion(c.ctx, c.client, c.clusterDeployment)
 		if err != nil {
@@ -289,98 +419,95 @@ func (c *ChildClusterRole) DiscoverRegionalClusterConfigMapByLocation() (*corev1
 		}
 	}
 
-	for _, regionalClusterConfigmap := range regionalClusterConfigMapList.Items {
-		regionalConfigData, err := NewConfigDataFromConfigMap(&regionalClusterConfigmap)
+	childCounts, err := c.countRegionalChildClusters(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := make([]*regionalCandidate, 0, len(regionalConfigMaps))
+	for i := range regionalConfigMaps {
+		regionalConfigMap := &regionalConfigMaps[i]
+		regionalConfigData, err := NewConfigDataFromConfigMap(regionalConfigMap)
 		if err != nil {
 			log.Error(err, "failed to create new configmap data",
-				"regionalClusterConfigmapName", regionalClusterConfigmap.Name,
-				"regionalClusterConfigmapNamespace", regionalClusterConfigmap.Namespace,
+				"regionalClusterConfigmapName", regionalConfigMap.Name,
+				"regionalClusterConfigmapNamespace", regionalConfigMap.Namespace,
 			)
 			continue
 		}
 
-		if c.isClusterInRegion {
-			contain := slices.ContainsFunc(cdsInSameRegion, func(cd *kcmv1beta1.ClusterDeployment) bool {
-				return cd.Name == regionalConfigData.RegionalClusterName
-			})
+		candidate := &regionalCandidate{
+			configMap:  regionalConfigMap,
+			configData: regionalConfigData,
+		}
+		candidate.children = childCounts[candidate.key()]
 
-			if !contain {
-				continue
+		regionalCd, err := k8s.GetClusterDeployment(
+			c.ctx,
+			c.client,
+			regionalConfigData.RegionalClusterName,
+			regionalConfigData.RegionalClusterNamespace,
+		)
+		if err != nil {
+			if !errors.IsNotFound(err) {
+				return nil, fmt.Errorf("failed to get regional ClusterDeployment: %v", err)
 			}
+			regionalCd = nil
+		} else {
+			candidate.group = regionalCd.Labels[KofRegionalGroupLabel]
+			candidate.capacity = regionalCapacity(regionalCd)
 		}
 
-		if childCloud != regionalConfigData.RegionalClusterCloud {
-			log.Info("Discovering regional cluster by location of child cluster: cloud doesn't match",
-				"childCloud", childCloud,
-				"regionalCloud", regionalConfigData.RegionalClusterCloud,
-				"childClusterDeploymentName", c.clusterName,
-				"childClusterDeploymentNamespace", c.clusterNamespace,
-				"regionalClusterConfigmapName", regionalClusterConfigmap.Name,
-				"regionalClusterConfigmapNamespace", regionalClusterConfigmap.Namespace,
-			)
-			continue
+		if c.isClusterInRegion {
+			candidate.sameKcmRegion = slices.ContainsFunc(cdsInSameRegion, func(cd *kcmv1beta1.ClusterDeployment) bool {
+				return cd.Name == regionalConfigData.RegionalClusterName
+			})
+		} else {
+			candidate.sameKcmRegion = regionalCd != nil && !k8s.CreatedInKCMRegion(regionalCd)
 		}
 
-		if locationIsTheSame(
-			childCloud,
-			c.clusterDeploymentConfig,
-			regionalConfigData.ToClusterDeploymentConfig(),
-		) {
-			if isPreviouslyUsedRegionalCluster(configMap, regionalConfigData) {
-				return &regionalClusterConfigmap, nil
+		promxyServerGroup := &kofv1beta1.PromxyServerGroup{}
+		if err := c.client.Get(c.ctx, types.NamespacedName{
+			Name:      GetPromxyServerGroupName(regionalConfigMap.Name, regionalConfigMap.Namespace),
+			Namespace: regionalConfigMap.Namespace,
+		}, promxyServerGroup); err != nil {
+			if !errors.IsNotFound(err) {
+				return nil, fmt.Errorf("failed to get regional PromxyServerGroup: %v", err)
 			}
-
-			candidates = append(candidates, &regionalClusterConfigmap)
-		} else {
-			log.Info("Discovering regional cluster by location of child cluster: cloud matches, location doesn't match",
-				"childClusterDeploymentName", c.clusterName,
-				"childClusterDeploymentNamespace", c.clusterNamespace,
-				"regionalClusterConfigmapName", regionalClusterConfigmap.Name,
-				"regionalClusterConfigmapNamespace", regionalClusterConfigmap.Namespace,
-			)
+			promxyServerGroup = nil
 		}
-	}
+		candidate.healthy = regionalHealth(regionalCd, promxyServerGroup)
 
-	if configMap != nil {
-		err := fmt.Errorf(
-			"previously used regional cluster is not discovered in the same location"+
-				`please set .metadata.labels["%s"] explicitly`,
-			KofRegionalClusterNameLabel,
-		)
-		record.LogEvent(
-			c.ctx,
-			"RegionalClusterConfigMapDiscoveryFailed",
-			"Failed to discover regional cluster ConfigMap",
-			c.clusterDeployment,
-			err,
-			"childClusterDeploymentName", c.clusterName,
-			"childClusterDeploymentNamespace", c.clusterNamespace,
-			"oldRegionalClusterName", configMap.Data[RegionalClusterNameKey],
-			"oldRegionalClusterNamespace", configMap.Data[RegionalClusterNamespaceKey],
-		)
-		return nil, err
+		candidates = append(candidates, candidate)
 	}
+	return candidates, nil
+}
 
-	if len(candidates) > 0 {
-		return candidates[0], nil
+// countRegionalChildClusters returns the number of other child clusters placed on each regional cluster,
+// keyed by the regional cluster namespace and name as in regionalCandidate.key().
+func (c *ChildClusterRole) countRegionalChildClusters(namespace string) (map[string]int, error) {
+	childConfigMapList := &corev1.ConfigMapList{}
+	if err := c.client.List(
+		c.ctx,
+		childConfigMapList,
+		client.InNamespace(namespace),
+		client.MatchingLabels{KofClusterRoleLabel: KofRoleChild},
+	); err != nil {
+		return nil, fmt.Errorf("failed to list child cluster ConfigMaps: %v", err)
 	}
 
-	err = fmt.Errorf(
-		"regional cluster ConfigMap with matching location is not found, "+
-			`please set .metadata.labels["%s"] explicitly`,
-		KofRegionalClusterNameLabel,
-	)
-	record.LogEvent(
-		c.ctx,
-		"RegionalClusterDiscoveryFailed",
-		"Failed to discover regional cluster",
-		c.clusterDeployment,
-		err,
-		"childClusterDeploymentName", c.clusterName,
-		"childClusterDeploymentNamespace", c.clusterNamespace,
-		"crossNamespace", crossNamespace,
-	)
-	return nil, err
+	counts := make(map[string]int, len(childConfigMapList.Items))
+	for _, childConfigMap := range childConfigMapList.Items {
+		if childConfigMap.Name == GetConfigMapName(c.clusterName) && childConfigMap.Namespace == c.clusterNamespace {
+			continue
+		}
+		regionalClusterNamespace := childConfigMap.Data[RegionalClusterNamespaceKey]
+		if regionalClusterNamespace == "" {
+			regionalClusterNamespace = childConfigMap.Namespace
+		}
+		counts[regionalClusterNamespace+"/"+childConfigMap.Data[RegionalClusterNameKey]]++
+	}
+	return counts, nil
 }
 
 func isPreviouslyUsedRegionalCluster(
@@ -11,6 +11,7 @@ import (
 	"github.com/k0rdent/kof/kof-operator/internal/k8s"
 	"github.com/k0rdent/kof/kof-operator/internal/models/labels"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 )
@@ -22,10 +23,21 @@ const KofClusterRoleLabel = prefix + "kof-cluster-role"
 const KofRegionalClusterNameLabel = prefix + "kof-regional-cluster-name"
 const KofRegionalClusterNamespaceLabel = prefix + "kof-regional-cluster-namespace"
 const KofRegionlessLabel = prefix + "kof-regionless"
+const KofRegionalGroupLabel = prefix + "kof-regional-group"
+const KofRegionalAffinityLabel = prefix + "kof-regional-affinity"
+const KofRegionalAntiAffinityLabel = prefix + "kof-regional-anti-affinity"
+const KofRegionalMigrationSourceLabel = prefix + "kof-regional-migration-source"
 
 // Annotations:
 const KofRegionalDomainAnnotation = prefix + "kof-regional-domain"
 const KofRegionalHTTPClientConfigAnnotation = prefix + "kof-http-config"
+const KofRegionalCapacityAnnotation = prefix + "kof-regional-capacity"
+const KofRegionalMigrationAnnotation = prefix + "kof-regional-migration"
+const KofRegionalMigrationOverlapAnnotation = prefix + "kof-regional-migration-overlap"
+const KofRegionalMigrationPhaseAnnotation = prefix + "kof-regional-migration-phase"
+const KofRegionalMigrationSourceAnnotation = prefix + "kof-regional-migration-source"
+const KofRegionalMigrationSwitchedAtAnnotation = prefix + "kof-regional-migration-switched-at"
+const KofRegionalMigrationTargetAnnotation = prefix + "kof-regional-migration-target"
 const WriteMetricsAnnotation = prefix + "kof-write-metrics-endpoint"
 const ReadMetricsAnnotation = prefix + "kof-read-metrics-endpoint"
 const WriteLogsAnnotation = prefix + "kof-write-logs-endpoint"
@@ -59,6 +71,12 @@ var regionlessEndpoints = map[string]string{
 	ReadTracesAnnotation:    "http://vmauth-cluster:8427/vts",
 }
 
+// Addresses of the clusternative listener of the regional vmselect,
+// used as the storage nodes of the top-level vmselect:
+const vmSelectClusterNativePort = "8401"
+const istioVMSelectClusterNativeAddress = "%s-vmselect:" + vmSelectClusterNativePort
+const regionlessVMSelectClusterNativeAddress = "vmselect-cluster:" + vmSelectClusterNativePort
+
 // Child cluster ConfigMap data keys:
 const RegionalClusterNameKey = "regional_cluster_name"
 const RegionalClusterNamespaceKey = "regional_cluster_namespace"
@@ -91,26 +109,57 @@ func (e *UnknownCloudProviderError) Error() string {
 	return fmt.Sprintf("unknown infrastructure provider in ClusterTemplate %q", e.ClusterTemplate)
 }
 
+// ReconcileKofClusterRole reconciles the KOF role of the ClusterDeployment, set either by
+// the KofCluster with the same name and namespace, or by the labels and annotations.
+// The effective endpoints and the result are reported in the KofCluster status.
 func (r *ClusterDeploymentReconciler) ReconcileKofClusterRole(
 	ctx context.Context,
 	clusterDeployment *kcmv1beta1.ClusterDeployment,
-) error {
+) (ctrl.Result, error) {
+	kofCluster, err := getKofCluster(ctx, r.Client, clusterDeployment.Name, clusterDeployment.Namespace)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if kofCluster == nil {
+		return r.reconcileKofClusterRole(ctx, clusterDeployment)
+	}
+
+	result, err := ctrl.Result{}, setKofClusterRoleLabel(ctx, r.Client, clusterDeployment, kofCluster)
+	if err == nil {
+		err = applyKofCluster(clusterDeployment, kofCluster)
+	}
+	if err == nil {
+		result, err = r.reconcileKofClusterRole(ctx, clusterDeployment)
+	}
+	if statusErr := updateKofClusterStatus(ctx, r.Client, kofCluster, clusterDeployment, err); statusErr != nil {
+		log.FromContext(ctx).Error(statusErr, "failed to update KofCluster status")
+		if err == nil {
+			return result, statusErr
+		}
+	}
+	return result, err
+}
+
+func (r *ClusterDeploymentReconciler) reconcileKofClusterRole(
+	ctx context.Context,
+	clusterDeployment *kcmv1beta1.ClusterDeployment,
+) (ctrl.Result, error) {
 	role := clusterDeployment.Labels[KofClusterRoleLabel]
 	switch role {
 	case KofRoleChild:
 		childClusterRole, err := NewChildClusterRole(ctx, clusterDeployment, r.Client)
 		if err != nil {
-			return err
+			return ctrl.Result{}, err
 		}
 		return childClusterRole.Reconcile()
 	case KofRoleRegional:
 		regionalClusterRole, err := NewRegionalClusterRole(ctx, clusterDeployment, r.Client)
 		if err != nil {
-			return err
+			return ctrl.Result{}, err
 		}
-		return regionalClusterRole.Reconcile()
+		return ctrl.Result{}, regionalClusterRole.Reconcile()
 	}
-	return nil
+	return ctrl.Result{}, nil
 }
 
 func getCloud(ctx context.Context, client client.Client, cd *kcmv1beta1.ClusterDeployment) (string, error) {
@@ -17,7 +17,9 @@ import (
 	"github.com/k0rdent/kof/kof-operator/internal/strutil"
 	addoncontrollerv1beta1 "github.com/projectsveltos/addon-controller/api/v1beta1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/log"
@@ -114,6 +116,10 @@ func (c *RegionalClusterConfigMap) Reconcile() error {
 		return fmt.Errorf("failed to create or update TracesStorageConnection: %v", err)
 	}
 
+	if err := c.CreateOrUpdateMetricsStorageConnection(); err != nil {
+		return fmt.Errorf("failed to create or update MetricsStorageConnection: %v", err)
+	}
+
 	if err := c.CreateOrUpdateLogsStorageConnection(); err != nil {
 		return fmt.Errorf("failed to create or update LogsStorageConnection: %v", err)
 	}
@@ -258,19 +264,17 @@ func (c *RegionalClusterConfigMap) UpdateChildConfigMap() error {
 
 func (c *RegionalClusterConfigMap) GetChildClusters() ([]*ChildClusterRole, error) {
 	log := log.FromContext(c.ctx)
-	regionalCloud := c.configData.RegionalClusterCloud
 	childClusterRoleList := make([]*ChildClusterRole, 0)
-	opts := []client.ListOption{client.MatchingLabels{KofClusterRoleLabel: "child"}}
 
-	childClusterDeploymentsList, err := k8s.GetClusterDeployments(c.ctx, c.client, opts...)
+	childClusterDeployments, err := getKofClusterDeployments(c.ctx, c.client, KofRoleChild)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get ClusterDeployments list: %v", err)
+		return nil, err
 	}
 
 	if isRegionlessConfigMap(c.configMap) {
-		for _, childClusterDeployment := range childClusterDeploymentsList.Items {
+		for _, childClusterDeployment := range childClusterDeployments {
 			var childClusterRole *ChildClusterRole
-			childClusterRole, err = NewChildClusterRole(c.ctx, &childClusterDeployment, c.client)
+			childClusterRole, err = NewChildClusterRole(c.ctx, childClusterDeployment, c.client)
 			if err != nil {
 				return nil, fmt.Errorf("failed to create child cluster: %v", err)
 			}
@@ -279,60 +283,29 @@ func (c *RegionalClusterConfigMap) GetChildClusters() ([]*ChildClusterRole, erro
 		return childClusterRoleList, nil
 	}
 
-	// Clusters in unknown clouds cannot be matched by location,
-	// so only explicitly labeled child clusters
-	// can be associated with the regional cluster.
-	if regionalCloud == "" {
-		for _, childClusterDeployment := range childClusterDeploymentsList.Items {
-			regionalClusterName := childClusterDeployment.Labels[KofRegionalClusterNameLabel]
-			regionalClusterNamespace := childClusterDeployment.Labels[KofRegionalClusterNamespaceLabel]
-			if regionalClusterName != c.clusterName || regionalClusterNamespace != c.clusterNamespace {
-				continue
-			}
-
-			childClusterRole, err := NewChildClusterRole(c.ctx, &childClusterDeployment, c.client)
-			if err != nil {
-				return nil, fmt.Errorf("failed to create child cluster: %v", err)
+	// Child clusters are placed on the regional cluster by score, not only by location,
+	// so the child ConfigMap is the source of truth of the placement.
+	for _, childClusterDeployment := range childClusterDeployments {
+		childConfigMap := &corev1.ConfigMap{}
+		if err := c.client.Get(c.ctx, types.NamespacedName{
+			Name:      GetConfigMapName(childClusterDeployment.Name),
+			Namespace: childClusterDeployment.Namespace,
+		}, childConfigMap); err != nil {
+			if !errors.IsNotFound(err) {
+				log.Error(err, "failed to get child cluster ConfigMap", "childClusterDeployment", childClusterDeployment.Name)
 			}
-
-			childClusterRoleList = append(childClusterRoleList, childClusterRole)
-		}
-		return childClusterRoleList, nil
-	}
-
-	regionalClusterDeploymentConfig := c.configData.ToClusterDeploymentConfig()
-
-	for _, childClusterDeployment := range childClusterDeploymentsList.Items {
-		childCloud, err := getCloud(c.ctx, c.client, &childClusterDeployment)
-		if err != nil {
-			log.Error(err, "failed to get cloud for child cluster deployment", "childClusterDeployment", childClusterDeployment.Name)
 			continue
 		}
 
-		if regionalCloud != childCloud {
+		if !isPreviouslyUsedRegionalCluster(childConfigMap, c.configData) {
 			continue
 		}
 
-		childClusterDeploymentConfig, err := ReadClusterDeploymentConfig(
-			childClusterDeployment.Spec.Config.Raw,
-		)
+		childClusterRole, err := NewChildClusterRole(c.ctx, childClusterDeployment, c.client)
 		if err != nil {
-			continue
-		}
-
-		if locationIsTheSame(
-			regionalCloud,
-			regionalClusterDeploymentConfig,
-			childClusterDeploymentConfig,
-		) && (childClusterDeployment.Labels[KofRegionalClusterNameLabel] == "" ||
-			childClusterDeployment.Labels[KofRegionalClusterNameLabel] == c.clusterName) {
-			childClusterRole, err := NewChildClusterRole(c.ctx, &childClusterDeployment, c.client)
-			if err != nil {
-				return nil, fmt.Errorf("failed to create child cluster: %v", err)
-			}
-
-			childClusterRoleList = append(childClusterRoleList, childClusterRole)
+			return nil, fmt.Errorf("failed to create child cluster: %v", err)
 		}
+		childClusterRoleList = append(childClusterRoleList, childClusterRole)
 	}
 	return childClusterRoleList, nil
 }
@@ -543,6 +516,88 @@ func (c *RegionalClusterConfigMap) CreateOrUpdateLogsStorageConnection() error {
 	return err
 }
 
+// CreateOrUpdateMetricsStorageConnection creates or updates a VMStorageConnection that registers
+// the clusternative listener of the regional vmselect with the vmselect of the VMCluster
+// named by KOF_VM_CLUSTER_NAME. When KOF_VM_CLUSTER_NAME is not set the step is skipped.
+// The regional clusters outside of the Istio mesh are skipped too and their connection is deleted,
+// see GetMetricsStorageNodeAddress.
+func (c *RegionalClusterConfigMap) CreateOrUpdateMetricsStorageConnection() error {
+	log := log.FromContext(c.ctx)
+
+	vmClusterName := env.GetVMClusterName()
+	if vmClusterName == "" {
+		log.Info("Skipping VMStorageConnection creation because KOF_VM_CLUSTER_NAME is not set")
+		return nil
+	}
+
+	address := c.GetMetricsStorageNodeAddress()
+	if address == "" {
+		log.Info("Skipping metrics VMStorageConnection creation because the regional cluster is not in the Istio mesh",
+			"regionalClusterName", c.clusterName,
+		)
+		return c.DeleteMetricsStorageConnection()
+	}
+
+	conn := &kofv1beta1.VMStorageConnection{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      GetMetricsStorageConnectionName(c.configMap.Name, c.configMap.Namespace),
+			Namespace: c.clusterNamespace,
+		},
+	}
+
+	_, err := controllerutil.CreateOrUpdate(c.ctx, c.client, conn, func() error {
+		if conn.Labels == nil {
+			conn.Labels = map[string]string{}
+		}
+		conn.Labels[labels.KofGeneratedLabel] = strutil.True
+		conn.Labels[labels.ClusterNameLabel] = c.clusterName
+		conn.Labels[labels.ManagedByLabel] = k8s.ManagedByValue
+		conn.OwnerReferences = []metav1.OwnerReference{*c.ownerReference}
+		conn.Spec = kofv1beta1.VMStorageConnectionSpec{
+			ClusterRef: kofv1beta1.ClusterRef{
+				Kind:      "VMCluster",
+				Name:      vmClusterName,
+				Namespace: c.releaseNamespace,
+			},
+			TargetStorageNode: kofv1beta1.TargetStorageNode{
+				Address: address,
+			},
+		}
+		return nil
+	})
+	return err
+}
+
+// DeleteMetricsStorageConnection deletes the metrics VMStorageConnection of the regional cluster, if any.
+func (c *RegionalClusterConfigMap) DeleteMetricsStorageConnection() error {
+	conn := &kofv1beta1.VMStorageConnection{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      GetMetricsStorageConnectionName(c.configMap.Name, c.configMap.Namespace),
+			Namespace: c.clusterNamespace,
+		},
+	}
+	if err := c.client.Delete(c.ctx, conn); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete metrics VMStorageConnection: %v", err)
+	}
+	return nil
+}
+
+// GetMetricsStorageNodeAddress returns the host:port of the clusternative listener of the regional vmselect,
+// or "" when the regional cluster is neither regionless nor in the Istio mesh.
+// The listener has no authentication and TLS, so it is never exposed on the public endpoints.
+func (c *RegionalClusterConfigMap) GetMetricsStorageNodeAddress() string {
+	if isRegionlessConfigMap(c.configMap) {
+		if isIstio {
+			return fmt.Sprintf(istioVMSelectClusterNativeAddress, c.clusterName)
+		}
+		return regionlessVMSelectClusterNativeAddress
+	}
+	if c.IsIstioCluster() {
+		return fmt.Sprintf(istioVMSelectClusterNativeAddress, c.clusterName)
+	}
+	return ""
+}
+
 // CreateOrUpdateTracesStorageConnection creates or updates a VMStorageConnection that registers
 // the regional cluster's storage node with the VTCluster named by KOF_VT_CLUSTER_NAME.
 // When KOF_VT_CLUSTER_NAME is not set the step is skipped.
@@ -680,6 +735,10 @@ func GetTracesStorageConnectionName(cmName, cmNamespace string) string {
 	return names.FNVName("kof-traces-storage-connection", cmName+"/"+cmNamespace)
 }
 
+func GetMetricsStorageConnectionName(cmName, cmNamespace string) string {
+	return names.FNVName("kof-metrics-storage-connection", cmName+"/"+cmNamespace)
+}
+
 func GetLogsStorageConnectionName(cmName, cmNamespace string) string {
 	return names.FNVName("kof-logs-storage-connection", cmName+"/"+cmNamespace)
 }
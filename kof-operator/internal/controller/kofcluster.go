@@ -0,0 +1,253 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	kcmv1beta1 "github.com/K0rdent/kcm/api/v1beta1"
+	kofv1beta1 "github.com/k0rdent/kof/kof-operator/api/v1beta1"
+	"github.com/k0rdent/kof/kof-operator/internal/k8s"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// getKofCluster returns the KofCluster with the name and namespace of the ClusterDeployment,
+// or nil if the ClusterDeployment is onboarded by labels and annotations only.
+func getKofCluster(ctx context.Context, k8sClient client.Client, name, namespace string) (*kofv1beta1.KofCluster, error) {
+	kofCluster := &kofv1beta1.KofCluster{}
+	if err := k8sClient.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, kofCluster); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get KofCluster %s/%s: %v", namespace, name, err)
+	}
+	return kofCluster, nil
+}
+
+// setKofClusterRoleLabel writes the role of the KofCluster to the label of the ClusterDeployment,
+// so the lookups by label, like the ones of the UI server, find the clusters onboarded by a KofCluster.
+// Only the label is patched, the rest of the KofCluster is applied in memory.
+func setKofClusterRoleLabel(
+	ctx context.Context,
+	k8sClient client.Client,
+	cd *kcmv1beta1.ClusterDeployment,
+	kofCluster *kofv1beta1.KofCluster,
+) error {
+	if cd.Labels[KofClusterRoleLabel] == kofCluster.Spec.Role {
+		return nil
+	}
+
+	patch := client.MergeFrom(cd.DeepCopy())
+	if cd.Labels == nil {
+		cd.Labels = make(map[string]string)
+	}
+	cd.Labels[KofClusterRoleLabel] = kofCluster.Spec.Role
+	if err := k8sClient.Patch(ctx, cd, patch); err != nil {
+		return fmt.Errorf("failed to set role label of ClusterDeployment %s/%s: %v", cd.Namespace, cd.Name, err)
+	}
+	return nil
+}
+
+// applyKofCluster overlays the spec of the KofCluster on the labels, annotations and config of the ClusterDeployment,
+// so the spec takes precedence while the fields left empty fall back to the labels and annotations.
+// The ClusterDeployment is changed in memory only, it must never be updated as a whole after this.
+func applyKofCluster(cd *kcmv1beta1.ClusterDeployment, kofCluster *kofv1beta1.KofCluster) error {
+	spec := kofCluster.Spec
+
+	if cd.Labels == nil {
+		cd.Labels = make(map[string]string)
+	}
+	cd.Labels[KofClusterRoleLabel] = spec.Role
+	if spec.Regional != nil {
+		cd.Labels[KofRegionalClusterNameLabel] = spec.Regional.Name
+		if spec.Regional.Namespace != "" {
+			cd.Labels[KofRegionalClusterNamespaceLabel] = spec.Regional.Namespace
+		}
+	}
+
+	if spec.HttpClient != nil {
+		httpClientConfig := spec.HttpClient.DeepCopy()
+		if httpClientConfig.DialTimeout.Duration == 0 {
+			httpClientConfig.DialTimeout = defaultDialTimeout
+		}
+		httpConfigJson, err := json.Marshal(httpClientConfig)
+		if err != nil {
+			return fmt.Errorf("failed to marshal http client config: %v", err)
+		}
+		if cd.Annotations == nil {
+			cd.Annotations = make(map[string]string)
+		}
+		cd.Annotations[KofRegionalHTTPClientConfigAnnotation] = string(httpConfigJson)
+	}
+
+	clusterAnnotations := make(map[string]string)
+	if spec.Domain != "" {
+		clusterAnnotations[KofRegionalDomainAnnotation] = spec.Domain
+	}
+	if spec.Endpoints != nil {
+		for annotation, endpoint := range getEndpointAnnotations(spec.Endpoints) {
+			if endpoint != "" {
+				clusterAnnotations[annotation] = endpoint
+			}
+		}
+	}
+	return setClusterAnnotations(cd, clusterAnnotations)
+}
+
+// setClusterAnnotations adds the annotations to the clusterAnnotations of the ClusterDeployment config,
+// where the endpoints and the domain of a regional cluster are read from.
+func setClusterAnnotations(cd *kcmv1beta1.ClusterDeployment, annotations map[string]string) error {
+	if len(annotations) == 0 {
+		return nil
+	}
+
+	config := make(map[string]any)
+	if cd.Spec.Config != nil && len(cd.Spec.Config.Raw) > 0 {
+		if err := json.Unmarshal(cd.Spec.Config.Raw, &config); err != nil {
+			return fmt.Errorf("failed to unmarshal ClusterDeployment config: %v", err)
+		}
+	}
+
+	clusterAnnotations, _ := config["clusterAnnotations"].(map[string]any)
+	if clusterAnnotations == nil {
+		clusterAnnotations = make(map[string]any, len(annotations))
+	}
+	for key, value := range annotations {
+		clusterAnnotations[key] = value
+	}
+	config["clusterAnnotations"] = clusterAnnotations
+
+	raw, err := json.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to marshal ClusterDeployment config: %v", err)
+	}
+	cd.Spec.Config = &apiextensionsv1.JSON{Raw: raw}
+	return nil
+}
+
+func getEndpointAnnotations(endpoints *kofv1beta1.KofClusterEndpoints) map[string]string {
+	return map[string]string{
+		ReadMetricsAnnotation:    endpoints.Metrics.Read,
+		WriteMetricsAnnotation:   endpoints.Metrics.Write,
+		ReadLogsAnnotation:       endpoints.Logs.Read,
+		WriteLogsAnnotation:      endpoints.Logs.Write,
+		ReadAuditLogsAnnotation:  endpoints.AuditLogs.Read,
+		WriteAuditLogsAnnotation: endpoints.AuditLogs.Write,
+		ReadTracesAnnotation:     endpoints.Traces.Read,
+		WriteTracesAnnotation:    endpoints.Traces.Write,
+	}
+}
+
+func getEndpointsFromConfigMap(configMap *corev1.ConfigMap) kofv1beta1.KofClusterEndpoints {
+	data := configMap.Data
+	return kofv1beta1.KofClusterEndpoints{
+		Metrics:   kofv1beta1.SignalEndpoints{Read: data[ReadMetricsKey], Write: data[WriteMetricsKey]},
+		Logs:      kofv1beta1.SignalEndpoints{Read: data[ReadLogsKey], Write: data[WriteLogsKey]},
+		AuditLogs: kofv1beta1.SignalEndpoints{Read: data[ReadAuditLogsKey], Write: data[WriteAuditLogsKey]},
+		Traces:    kofv1beta1.SignalEndpoints{Read: data[ReadTracesKey], Write: data[WriteTracesKey]},
+	}
+}
+
+// getKofClusterDeployments returns the ClusterDeployments with the role, set either by the label or by the KofCluster,
+// with the KofCluster applied.
+func getKofClusterDeployments(
+	ctx context.Context,
+	k8sClient client.Client,
+	role string,
+) ([]*kcmv1beta1.ClusterDeployment, error) {
+	cdList, err := k8s.GetClusterDeployments(ctx, k8sClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ClusterDeployments list: %v", err)
+	}
+
+	kofClusterList := &kofv1beta1.KofClusterList{}
+	if err := k8sClient.List(ctx, kofClusterList); err != nil {
+		return nil, fmt.Errorf("failed to get KofClusters list: %v", err)
+	}
+	kofClusters := make(map[types.NamespacedName]*kofv1beta1.KofCluster, len(kofClusterList.Items))
+	for i := range kofClusterList.Items {
+		kofCluster := &kofClusterList.Items[i]
+		kofClusters[client.ObjectKeyFromObject(kofCluster)] = kofCluster
+	}
+
+	cds := make([]*kcmv1beta1.ClusterDeployment, 0, len(cdList.Items))
+	for i := range cdList.Items {
+		cd := &cdList.Items[i]
+		if kofCluster, ok := kofClusters[client.ObjectKeyFromObject(cd)]; ok {
+			if err := applyKofCluster(cd, kofCluster); err != nil {
+				return nil, fmt.Errorf("failed to apply KofCluster %s/%s: %v", kofCluster.Namespace, kofCluster.Name, err)
+			}
+		}
+		if cd.Labels[KofClusterRoleLabel] == role {
+			cds = append(cds, cd)
+		}
+	}
+	return cds, nil
+}
+
+// updateKofClusterStatus reports the result of the reconcile and the effective endpoints,
+// read from the ConfigMap written for the role of the cluster.
+func updateKofClusterStatus(
+	ctx context.Context,
+	k8sClient client.Client,
+	kofCluster *kofv1beta1.KofCluster,
+	cd *kcmv1beta1.ClusterDeployment,
+	reconcileErr error,
+) error {
+	status := kofCluster.Status.DeepCopy()
+	status.ObservedGeneration = kofCluster.Generation
+	status.Regional = nil
+	status.Endpoints = kofv1beta1.KofClusterEndpoints{}
+
+	configMapName := GetConfigMapName(cd.Name)
+	if kofCluster.Spec.Role == KofRoleRegional {
+		configMapName = GetRegionalClusterConfigMapName(cd.Name)
+	}
+	configMap := &corev1.ConfigMap{}
+	if err := k8sClient.Get(ctx, types.NamespacedName{
+		Name:      configMapName,
+		Namespace: cd.Namespace,
+	}, configMap); err != nil {
+		if !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to get ConfigMap %s/%s: %v", cd.Namespace, configMapName, err)
+		}
+	} else {
+		status.Endpoints = getEndpointsFromConfigMap(configMap)
+		if regionalClusterName := configMap.Data[RegionalClusterNameKey]; kofCluster.Spec.Role == KofRoleChild && regionalClusterName != "" {
+			status.Regional = &kofv1beta1.RegionalClusterRef{
+				Name:      regionalClusterName,
+				Namespace: configMap.Data[RegionalClusterNamespaceKey],
+			}
+		}
+	}
+
+	condition := metav1.Condition{
+		Type:               kofv1beta1.KofClusterReadyCondition,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Reconciled",
+		Message:            fmt.Sprintf("The %s cluster is reconciled", kofCluster.Spec.Role),
+		ObservedGeneration: kofCluster.Generation,
+	}
+	if reconcileErr != nil {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "ReconcileFailed"
+		condition.Message = reconcileErr.Error()
+	}
+	meta.SetStatusCondition(&status.Conditions, condition)
+
+	if equality.Semantic.DeepEqual(&kofCluster.Status, status) {
+		return nil
+	}
+	kofCluster.Status = *status
+	if err := k8sClient.Status().Update(ctx, kofCluster); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to update KofCluster %s/%s status: %v", kofCluster.Namespace, kofCluster.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	kcmv1beta1 "github.com/K0rdent/kcm/api/v1beta1"
+	kofv1beta1 "github.com/k0rdent/kof/kof-operator/api/v1beta1"
+	"github.com/k0rdent/kof/kof-operator/internal/controller/record"
+	"github.com/k0rdent/kof/kof-operator/internal/k8s"
+	"github.com/k0rdent/kof/kof-operator/internal/models/labels"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sevents "k8s.io/client-go/tools/events"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestApplyKofCluster(t *testing.T) {
+	cd := &kcmv1beta1.ClusterDeployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "regional",
+			Namespace:   migrationNamespace,
+			Labels:      map[string]string{KofClusterRoleLabel: KofRoleChild},
+			Annotations: map[string]string{KofRegionalHTTPClientConfigAnnotation: `{"dial_timeout":"1s"}`},
+		},
+		Spec: kcmv1beta1.ClusterDeploymentSpec{
+			Config: &apiextensionsv1.JSON{Raw: []byte(`{
+				"region": "us-east-2",
+				"clusterAnnotations": {
+					"` + KofRegionalDomainAnnotation + `": "old.example.com",
+					"` + WriteLogsAnnotation + `": "https://logs.example.com/insert"
+				}
+			}`)},
+		},
+	}
+	kofCluster := &kofv1beta1.KofCluster{
+		Spec: kofv1beta1.KofClusterSpec{
+			Role:   KofRoleRegional,
+			Domain: "new.example.com",
+			Endpoints: &kofv1beta1.KofClusterEndpoints{
+				Metrics: kofv1beta1.SignalEndpoints{Read: "https://metrics.example.com/select"},
+			},
+			HttpClient: &kofv1beta1.HTTPClientConfig{
+				TLSConfig: kofv1beta1.TLSConfig{InsecureSkipVerify: true},
+			},
+		},
+	}
+
+	if err := applyKofCluster(cd, kofCluster); err != nil {
+		t.Fatalf("failed to apply KofCluster: %v", err)
+	}
+
+	if role := cd.Labels[KofClusterRoleLabel]; role != KofRoleRegional {
+		t.Fatalf("expected role %q of the KofCluster, got %q", KofRoleRegional, role)
+	}
+	config, err := ReadClusterDeploymentConfig(cd.Spec.Config.Raw)
+	if err != nil {
+		t.Fatalf("failed to read ClusterDeployment config: %v", err)
+	}
+	if config.Region != "us-east-2" {
+		t.Fatalf("expected the rest of the config to be kept, got region %q", config.Region)
+	}
+	for annotation, expected := range map[string]string{
+		KofRegionalDomainAnnotation: "new.example.com",
+		ReadMetricsAnnotation:       "https://metrics.example.com/select",
+		WriteLogsAnnotation:         "https://logs.example.com/insert",
+	} {
+		if value := config.ClusterAnnotations[annotation]; value != expected {
+			t.Fatalf("expected %s %q, got %q", annotation, expected, value)
+		}
+	}
+
+	httpClientConfig := &kofv1beta1.HTTPClientConfig{}
+	if err := json.Unmarshal([]byte(cd.Annotations[KofRegionalHTTPClientConfigAnnotation]), httpClientConfig); err != nil {
+		t.Fatalf("failed to parse http client config: %v", err)
+	}
+	if !httpClientConfig.TLSConfig.InsecureSkipVerify || httpClientConfig.DialTimeout != defaultDialTimeout {
+		t.Fatalf("unexpected http client config %+v", httpClientConfig)
+	}
+}
+
+func TestReconcileKofClusterRole(t *testing.T) {
+	t.Setenv("RELEASE_NAMESPACE", migrationNamespace)
+	t.Setenv("KOF_REGIONLESS_ENABLED", "false")
+	t.Setenv("CROSS_NAMESPACE", "false")
+	record.DefaultRecorder = new(k8sevents.FakeRecorder)
+
+	const childName = "child"
+	ctx := context.Background()
+
+	// The ClusterDeployment has no KOF labels, the KofCluster onboards it.
+	child := &kcmv1beta1.ClusterDeployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      childName,
+			Namespace: migrationNamespace,
+			Labels:    map[string]string{labels.ClusterNameLabel: childName},
+		},
+		Spec: kcmv1beta1.ClusterDeploymentSpec{
+			Config: &apiextensionsv1.JSON{Raw: []byte(`{}`)},
+		},
+	}
+	kofCluster := &kofv1beta1.KofCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       childName,
+			Namespace:  migrationNamespace,
+			Generation: 2,
+		},
+		Spec: kofv1beta1.KofClusterSpec{
+			Role:     KofRoleChild,
+			Regional: &kofv1beta1.RegionalClusterRef{Name: "regional"},
+		},
+	}
+	regional := newMigrationRegionalConfigMap("regional", "regional.example.com")
+	other := newMigrationRegionalConfigMap("other", "other.example.com")
+	k8sClient := newMigrationTestClient(t, child, kofCluster, regional, other)
+
+	reconciler := &ClusterDeploymentReconciler{Client: k8sClient}
+	if _, err := reconciler.ReconcileKofClusterRole(ctx, child.DeepCopy()); err != nil {
+		t.Fatalf("failed to reconcile KOF cluster role: %v", err)
+	}
+
+	if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(kofCluster), kofCluster); err != nil {
+		t.Fatalf("failed to get KofCluster: %v", err)
+	}
+	status := kofCluster.Status
+	if status.ObservedGeneration != 2 {
+		t.Fatalf("expected observed generation 2, got %d", status.ObservedGeneration)
+	}
+	if !meta.IsStatusConditionTrue(status.Conditions, kofv1beta1.KofClusterReadyCondition) {
+		t.Fatalf("expected Ready condition, got %+v", status.Conditions)
+	}
+	if status.Regional == nil || status.Regional.Name != "regional" {
+		t.Fatalf("expected regional cluster from the KofCluster, got %+v", status.Regional)
+	}
+	if write := status.Endpoints.Metrics.Write; write != regional.Data[WriteMetricsKey] {
+		t.Fatalf("expected write metrics endpoint of the regional cluster, got %q", write)
+	}
+
+	if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(child), child); err != nil {
+		t.Fatalf("failed to get ClusterDeployment: %v", err)
+	}
+	if role := child.Labels[KofClusterRoleLabel]; role != KofRoleChild {
+		t.Fatalf("expected role %q of the KofCluster on the ClusterDeployment, got %q", KofRoleChild, role)
+	}
+	if _, ok := child.Labels[KofRegionalClusterNameLabel]; ok {
+		t.Fatal("expected the rest of the KofCluster not to be written to the ClusterDeployment")
+	}
+	if string(child.Spec.Config.Raw) != `{}` {
+		t.Fatalf("expected the ClusterDeployment config to be kept, got %s", child.Spec.Config.Raw)
+	}
+
+	// The UI server and the other lookups by label find the cluster.
+	cdList, err := k8s.GetKofChildClusterDeployments(ctx, k8sClient)
+	if err != nil {
+		t.Fatalf("failed to get child ClusterDeployments: %v", err)
+	}
+	if len(cdList.Items) != 1 || cdList.Items[0].Name != childName {
+		t.Fatalf("expected the child cluster onboarded by the KofCluster, got %d clusters", len(cdList.Items))
+	}
+}
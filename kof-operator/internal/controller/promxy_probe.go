@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"time"
+
+	kofv1beta1 "github.com/k0rdent/kof/kof-operator/api/v1beta1"
+	"github.com/k0rdent/kof/kof-operator/internal/telemetry"
+)
+
+const (
+	// promxyProbeQuery is a constant PromQL expression answered by every
+	// Prometheus-compatible read endpoint without touching any series.
+	promxyProbeQuery = "1"
+	// PromxyTargetProbeInterval is how often server group targets are re-probed.
+	PromxyTargetProbeInterval = time.Minute
+)
+
+// promxyProbeClients are shared by all probes, one per TLS config,
+// so idle keep-alive connections are reused instead of piling up on every probe.
+var promxyProbeClients = map[bool]*http.Client{
+	false: newPromxyProbeClient(false),
+	true:  newPromxyProbeClient(true),
+}
+
+func newPromxyProbeClient(insecureSkipVerify bool) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: insecureSkipVerify}
+	return &http.Client{Transport: telemetry.NewTransport(transport)}
+}
+
+// PromxyTargetProbe describes a single server group target to probe.
+type PromxyTargetProbe struct {
+	URL                string
+	Username           string
+	Password           string
+	InsecureSkipVerify bool
+	Timeout            time.Duration
+}
+
+type PromxyTargetProbeFunc func(ctx context.Context, probe PromxyTargetProbe) error
+
+// NewPromxyTargetProbe builds the probe of the given target using
+// the scheme, path prefix and http client settings of the server group.
+func NewPromxyTargetProbe(group *kofv1beta1.PromxyServerGroup, target, username, password string) PromxyTargetProbe {
+	scheme := group.Spec.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+
+	timeout := group.Spec.HttpClient.DialTimeout.Duration
+	if timeout == 0 {
+		timeout = DefaultDialTimeout.Duration
+	}
+
+	return PromxyTargetProbe{
+		URL: (&url.URL{
+			Scheme:   scheme,
+			Host:     target,
+			Path:     path.Join("/", group.Spec.PathPrefix, "api/v1/query"),
+			RawQuery: url.Values{"query": []string{promxyProbeQuery}}.Encode(),
+		}).String(),
+		Username:           username,
+		Password:           password,
+		InsecureSkipVerify: group.Spec.HttpClient.TLSConfig.InsecureSkipVerify,
+		Timeout:            timeout,
+	}
+}
+
+// ProbePromxyTarget sends an instant query to the target the same way promxy does
+// and reports an error if the target is unreachable or does not answer with 2xx.
+func ProbePromxyTarget(ctx context.Context, probe PromxyTargetProbe) error {
+	ctx, cancel := context.WithTimeout(ctx, probe.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, probe.URL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create probe request: %w", err)
+	}
+	if probe.Username != "" || probe.Password != "" {
+		req.SetBasicAuth(probe.Username, probe.Password)
+	}
+
+	resp, err := promxyProbeClients[probe.InsecureSkipVerify].Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("unexpected response status: %s", resp.Status)
+	}
+	return nil
+}
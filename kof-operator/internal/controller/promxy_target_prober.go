@@ -0,0 +1,142 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	kofv1beta1 "github.com/k0rdent/kof/kof-operator/api/v1beta1"
+	"github.com/k0rdent/kof/kof-operator/internal/env"
+	"github.com/k0rdent/kof/kof-operator/internal/models/labels"
+)
+
+// PromxyTargetProber periodically probes the targets of every promxy server group once
+// and reports the results in the group status only, without rendering the promxy secret
+// or reloading promxy. It runs on the leader only.
+type PromxyTargetProber struct {
+	client.Client
+	Probe PromxyTargetProbeFunc
+	// Interval between probe rounds, PromxyTargetProbeInterval when zero.
+	Interval time.Duration
+}
+
+// Start implements manager.Runnable.
+func (p *PromxyTargetProber) Start(ctx context.Context) error {
+	interval := p.Interval
+	if interval == 0 {
+		interval = PromxyTargetProbeInterval
+	}
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := p.ProbeAll(ctx); err != nil {
+			log.FromContext(ctx).Error(err, "cannot probe promxy server group targets")
+		}
+	}, interval)
+	return nil
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable.
+func (p *PromxyTargetProber) NeedLeaderElection() bool {
+	return true
+}
+
+// ProbeAll probes the targets of the server groups rendered into a promxy secret.
+func (p *PromxyTargetProber) ProbeAll(ctx context.Context) error {
+	log := log.FromContext(ctx)
+	releaseNamespace, err := env.GetReleaseNamespace()
+	if err != nil {
+		return fmt.Errorf("failed to get release namespace: %v", err)
+	}
+
+	promxyServerGroupsList := &kofv1beta1.PromxyServerGroupList{}
+	if err := p.List(ctx, promxyServerGroupsList); err != nil {
+		return fmt.Errorf("cannot get promxy server group list: %v", err)
+	}
+
+	for i := range promxyServerGroupsList.Items {
+		group := &promxyServerGroupsList.Items[i]
+		if _, ok := group.Labels[labels.SecretNameLabel]; !ok {
+			continue
+		}
+		// A missing credentials secret is reported in the ConfigRendered condition by the reconciler.
+		username, password, err := getPromxyServerGroupCredentials(ctx, p.Client, group, releaseNamespace)
+		if err != nil {
+			log.Info("Skipping probe of promxy server group without credentials", "promxyServerGroup", group.Name, "err", err)
+			continue
+		}
+		if err := p.probeGroup(ctx, group, username, password); err != nil {
+			log.Error(err, "cannot update promxy server group target status", "promxyServerGroup", group.Name)
+		}
+	}
+	return nil
+}
+
+// probeGroup probes every target of the group and writes the results to the group status.
+func (p *PromxyTargetProber) probeGroup(
+	ctx context.Context,
+	group *kofv1beta1.PromxyServerGroup,
+	username, password string,
+) error {
+	targets := make([]kofv1beta1.TargetStatus, 0, len(group.Spec.Targets))
+	for _, target := range group.Spec.Targets {
+		start := time.Now()
+		err := p.Probe(ctx, NewPromxyTargetProbe(group, target, username, password))
+		status := kofv1beta1.TargetStatus{
+			Target:        target,
+			Healthy:       err == nil,
+			LastProbeTime: metav1.NewTime(start),
+			Latency:       metav1.Duration{Duration: time.Since(start).Round(time.Millisecond)},
+		}
+		if err != nil {
+			log.FromContext(ctx).Info("Promxy server group target is unhealthy", "promxyServerGroup", group.Name, "target", target, "err", err)
+			status.LastError = err.Error()
+		}
+		targets = append(targets, status)
+	}
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		latest := &kofv1beta1.PromxyServerGroup{}
+		if err := p.Get(ctx, client.ObjectKeyFromObject(group), latest); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		setPromxyServerGroupTargets(latest, targets)
+		if err := p.Status().Update(ctx, latest); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		return nil
+	})
+}
+
+// setPromxyServerGroupTargets stores the probe results and the TargetsHealthy condition in the group status.
+func setPromxyServerGroupTargets(group *kofv1beta1.PromxyServerGroup, targets []kofv1beta1.TargetStatus) {
+	healthy := 0
+	for _, target := range targets {
+		if target.Healthy {
+			healthy++
+		}
+	}
+
+	group.Status.Targets = targets
+	group.Status.HealthyTargets = fmt.Sprintf("%d/%d", healthy, len(targets))
+
+	condition := metav1.Condition{
+		Type:               kofv1beta1.TargetsHealthyCondition,
+		Status:             metav1.ConditionTrue,
+		Reason:             "AllTargetsHealthy",
+		Message:            "All targets answered the probe",
+		ObservedGeneration: group.Generation,
+	}
+	if healthy < len(targets) {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "TargetsUnhealthy"
+		condition.Message = fmt.Sprintf("%d of %d targets failed the probe", len(targets)-healthy, len(targets))
+	}
+	meta.SetStatusCondition(&group.Status.Conditions, condition)
+}
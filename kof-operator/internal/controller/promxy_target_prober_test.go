@@ -0,0 +1,131 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	k8sevents "k8s.io/client-go/tools/events"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	kofv1beta1 "github.com/k0rdent/kof/kof-operator/api/v1beta1"
+	"github.com/k0rdent/kof/kof-operator/internal/controller/record"
+	"github.com/k0rdent/kof/kof-operator/internal/models/labels"
+)
+
+func TestPromxyServerGroupReloadAndProbe(t *testing.T) {
+	t.Setenv("RELEASE_NAMESPACE", "kof")
+	record.DefaultRecorder = new(k8sevents.FakeRecorder)
+	ctx := context.Background()
+
+	s := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	if err := kofv1beta1.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	group := &kofv1beta1.PromxyServerGroup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "group",
+			Namespace: "kof",
+			Labels:    map[string]string{labels.SecretNameLabel: "promxy"},
+		},
+		Spec: kofv1beta1.PromxyServerGroupSpec{
+			ClusterName: "regional",
+			Targets:     []string{"a.example.net:443", "b.example.net:443"},
+			Scheme:      "https",
+		},
+	}
+	k8sClient := fake.NewClientBuilder().
+		WithScheme(s).
+		WithObjects(group).
+		WithStatusSubresource(&kofv1beta1.PromxyServerGroup{}).
+		Build()
+
+	reloads := 0
+	reloadErr := fmt.Errorf("reload failed")
+	reconciler := &PromxyServerGroupReconciler{
+		Client: k8sClient,
+		PromxyConfigReload: func(_ context.Context) error {
+			reloads++
+			return reloadErr
+		},
+	}
+	reconcile := func() error {
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(group)})
+		return err
+	}
+
+	if err := reconcile(); err == nil {
+		t.Fatal("expected the failed reload to be returned")
+	}
+	// The failed reload is retried although the config is unchanged.
+	reloadErr = nil
+	if err := reconcile(); err != nil {
+		t.Fatalf("failed to reconcile: %v", err)
+	}
+	if reloads != 2 {
+		t.Fatalf("expected the failed reload to be retried, got %d reloads", reloads)
+	}
+	if err := reconcile(); err != nil {
+		t.Fatalf("failed to reconcile: %v", err)
+	}
+	if reloads != 2 {
+		t.Fatalf("expected no reload of an unchanged config, got %d reloads", reloads)
+	}
+	secret := &corev1.Secret{}
+	if err := k8sClient.Get(ctx, client.ObjectKey{Name: "promxy", Namespace: "kof"}, secret); err != nil {
+		t.Fatalf("failed to get promxy secret: %v", err)
+	}
+	resourceVersion := secret.ResourceVersion
+	if err := reconcile(); err != nil {
+		t.Fatalf("failed to reconcile: %v", err)
+	}
+	if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(secret), secret); err != nil {
+		t.Fatalf("failed to get promxy secret: %v", err)
+	}
+	if secret.ResourceVersion != resourceVersion {
+		t.Fatal("expected the unchanged promxy secret not to be updated")
+	}
+
+	probes := 0
+	prober := &PromxyTargetProber{
+		Client: k8sClient,
+		Probe: func(_ context.Context, probe PromxyTargetProbe) error {
+			probes++
+			if probe.URL == "https://b.example.net:443/api/v1/query?query=1" {
+				return fmt.Errorf("connection refused")
+			}
+			return nil
+		},
+	}
+	if err := prober.ProbeAll(ctx); err != nil {
+		t.Fatalf("failed to probe: %v", err)
+	}
+	if probes != len(group.Spec.Targets) {
+		t.Fatalf("expected every target to be probed once, got %d probes", probes)
+	}
+	if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(group), group); err != nil {
+		t.Fatalf("failed to get promxy server group: %v", err)
+	}
+	if group.Status.HealthyTargets != "1/2" {
+		t.Fatalf("expected 1/2 healthy targets, got %q", group.Status.HealthyTargets)
+	}
+	if !meta.IsStatusConditionFalse(group.Status.Conditions, kofv1beta1.TargetsHealthyCondition) {
+		t.Fatalf("expected TargetsHealthy condition to be False, got %+v", group.Status.Conditions)
+	}
+	if !meta.IsStatusConditionTrue(group.Status.Conditions, kofv1beta1.PromxyReloadedCondition) {
+		t.Fatalf("expected the probe to keep the PromxyReloaded condition, got %+v", group.Status.Conditions)
+	}
+	if reloads != 2 {
+		t.Fatalf("expected the probe not to reload promxy, got %d reloads", reloads)
+	}
+}
@@ -18,17 +18,21 @@ package controller
 
 import (
 	"context"
+	goerrors "errors"
 	"fmt"
 	"time"
 
 	coreV1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
 	kofv1beta1 "github.com/k0rdent/kof/kof-operator/api/v1beta1"
 	"github.com/k0rdent/kof/kof-operator/internal/controller/record"
@@ -100,132 +104,246 @@ func (r *PromxyServerGroupReconciler) Reconcile(ctx context.Context, req ctrl.Re
 	log.Info("Processing promxy server groups", "promxyServerGroupsBySecretName", promxyServerGroupsBySecretName)
 
 	for name, groups := range promxyServerGroupsBySecretName {
-		secretTemplateData := &PromxyConfig{
-			RemoteWriteUrl: r.RemoteWriteUrl,
-			ServerGroups:   make([]*PromxyConfigServerGroup, 0),
-		}
-
-		for _, group := range groups {
-			credentialsSecret := &coreV1.Secret{}
-			basicAuthEnabled := group.Spec.HttpClient.BasicAuth.CredentialsSecretName != ""
-			if basicAuthEnabled {
-				if err := r.Get(ctx, types.NamespacedName{
-					Name:      group.Spec.HttpClient.BasicAuth.CredentialsSecretName,
-					Namespace: releaseNamespace,
-				}, credentialsSecret); err != nil {
-					log.Error(err, "cannot read auth credentials secret")
-					return ctrl.Result{}, err
-				}
+		syncErr := r.reconcileSecret(ctx, name, releaseNamespace, groups)
+		if err := r.updateStatuses(ctx, groups); err != nil {
+			log.Error(err, "cannot update promxy server group status", "promxySecretName", name)
+			if syncErr == nil {
+				return ctrl.Result{}, err
 			}
-			secretTemplateData.ServerGroups = append(secretTemplateData.ServerGroups, &PromxyConfigServerGroup{
-				Targets:               group.Spec.Targets,
-				PathPrefix:            group.Spec.PathPrefix,
-				Scheme:                group.Spec.Scheme,
-				DialTimeout:           group.Spec.HttpClient.DialTimeout.Duration.String(),
-				TlsInsecureSkipVerify: group.Spec.HttpClient.TLSConfig.InsecureSkipVerify,
-				BasicAuthEnabled:      basicAuthEnabled,
-				Username:              string(credentialsSecret.Data[group.Spec.HttpClient.BasicAuth.UsernameKey]),
-				Password:              string(credentialsSecret.Data[group.Spec.HttpClient.BasicAuth.PasswordKey]),
-				ClusterName:           group.Spec.ClusterName,
-				ClusterNamespace:      group.Namespace,
-			})
 		}
+		if syncErr != nil {
+			return ctrl.Result{}, syncErr
+		}
+	}
+
+	return ctrl.Result{}, nil
+}
 
-		data, err := RenderMetricsSecretTemplate(secretTemplateData)
+// reconcileSecret renders the server groups sharing the same promxy secret,
+// writes the secret, reloads promxy and records the outcome as conditions of the groups.
+// The secret update and the reload are skipped when the rendered config is unchanged
+// and promxy was reloaded successfully before.
+func (r *PromxyServerGroupReconciler) reconcileSecret(
+	ctx context.Context,
+	name, releaseNamespace string,
+	groups []*kofv1beta1.PromxyServerGroup,
+) error {
+	log := log.FromContext(ctx)
+
+	secretTemplateData := &PromxyConfig{
+		RemoteWriteUrl: r.RemoteWriteUrl,
+		ServerGroups:   make([]*PromxyConfigServerGroup, 0),
+	}
+
+	for _, group := range groups {
+		username, password, err := getPromxyServerGroupCredentials(ctx, r.Client, group, releaseNamespace)
 		if err != nil {
-			log.Error(err, "cannot render promxy secret template")
-			return ctrl.Result{}, err
+			log.Error(err, "cannot read auth credentials secret")
+			setPromxyServerGroupsCondition(groups, kofv1beta1.ConfigRenderedCondition, "RenderFailed",
+				fmt.Errorf("promxy server group %s/%s: %w", group.Namespace, group.Name, err))
+			setPromxyServerGroupCondition(group, kofv1beta1.ConfigRenderedCondition, "CredentialsSecretNotFound", err)
+			return err
 		}
+		basicAuthEnabled := group.Spec.HttpClient.BasicAuth.CredentialsSecretName != ""
 
-		secret := &coreV1.Secret{}
-		err = r.Get(ctx, types.NamespacedName{
+		secretTemplateData.ServerGroups = append(secretTemplateData.ServerGroups, &PromxyConfigServerGroup{
+			Targets:               group.Spec.Targets,
+			PathPrefix:            group.Spec.PathPrefix,
+			Scheme:                group.Spec.Scheme,
+			DialTimeout:           group.Spec.HttpClient.DialTimeout.Duration.String(),
+			TlsInsecureSkipVerify: group.Spec.HttpClient.TLSConfig.InsecureSkipVerify,
+			BasicAuthEnabled:      basicAuthEnabled,
+			Username:              username,
+			Password:              password,
+			ClusterName:           group.Spec.ClusterName,
+			ClusterNamespace:      group.Namespace,
+		})
+	}
+
+	data, err := RenderMetricsSecretTemplate(secretTemplateData)
+	if err != nil {
+		log.Error(err, "cannot render promxy secret template")
+		setPromxyServerGroupsCondition(groups, kofv1beta1.ConfigRenderedCondition, "RenderFailed", err)
+		return err
+	}
+	setPromxyServerGroupsCondition(groups, kofv1beta1.ConfigRenderedCondition, "Rendered", nil)
+
+	secret := &coreV1.Secret{}
+	err = r.Get(ctx, types.NamespacedName{
+		Name:      name,
+		Namespace: releaseNamespace,
+	}, secret)
+	if err != nil && errors.IsNotFound(err) {
+		secret.ObjectMeta = metav1.ObjectMeta{
 			Name:      name,
 			Namespace: releaseNamespace,
-		}, secret)
-		if err != nil && errors.IsNotFound(err) {
-			secret.ObjectMeta = metav1.ObjectMeta{
-				Name:      name,
-				Namespace: releaseNamespace,
-			}
-			setSecretOperatorLabels(secret)
-			secret.StringData = map[string]string{
-				"config.yaml": data,
-			}
-			log.Info("Creating promxy config secret", "secretName", name)
-			if err := r.Create(ctx, secret); err != nil {
-				record.LogEvent(
-					ctx,
-					"PromxySecretCreationFailed",
-					"Cannot create promxy secret",
-					secret,
-					err,
-					"promxySecretName", secret.Name,
-				)
-				return ctrl.Result{}, err
-			}
-			if err := r.PromxyConfigReload(ctx); err != nil {
-				record.LogEvent(
-					ctx,
-					"PromxyConfigReloadingFailed",
-					"Cannot reload promxy config",
-					secret,
-					err,
-					"promxySecretName", secret.Name,
-				)
-				return ctrl.Result{}, err
-			}
-			continue
-		}
-		if err != nil {
-			record.LogEvent(
-				ctx,
-				"PromxySecretNotFound",
-				"Cannot get promxy secret",
-				secret,
-				err,
-				"promxySecretName", secret.Name,
-			)
-			return ctrl.Result{}, err
 		}
 		setSecretOperatorLabels(secret)
-		secret.StringData = map[string]string{
-			"config.yaml": data,
+		secret.Data = map[string][]byte{
+			"config.yaml": []byte(data),
 		}
-		log.Info("Updating promxy config secret", "secretName", name)
-		if err := r.Update(ctx, secret); err != nil {
+		log.Info("Creating promxy config secret", "secretName", name)
+		if err := r.Create(ctx, secret); err != nil {
 			record.LogEvent(
 				ctx,
-				"PromxySecretUpdateFailed",
-				"Cannot update promxy secret",
+				"PromxySecretCreationFailed",
+				"Cannot create promxy secret",
 				secret,
 				err,
 				"promxySecretName", secret.Name,
 			)
-			return ctrl.Result{}, err
+			setPromxyServerGroupsCondition(groups, kofv1beta1.SecretSyncedCondition, "SecretCreationFailed", err)
+			return err
 		}
+		setPromxyServerGroupsCondition(groups, kofv1beta1.SecretSyncedCondition, "SecretCreated", nil)
 		if err := r.PromxyConfigReload(ctx); err != nil {
 			record.LogEvent(
 				ctx,
-				"PromxySecretReloadFailed",
+				"PromxyConfigReloadingFailed",
 				"Cannot reload promxy config",
 				secret,
 				err,
 				"promxySecretName", secret.Name,
 			)
-			return ctrl.Result{}, err
+			setPromxyServerGroupsCondition(groups, kofv1beta1.PromxyReloadedCondition, "ReloadFailed", err)
+			return err
 		}
+		setPromxyServerGroupsCondition(groups, kofv1beta1.PromxyReloadedCondition, "Reloaded", nil)
+		return nil
+	}
+	if err != nil {
+		record.LogEvent(
+			ctx,
+			"PromxySecretNotFound",
+			"Cannot get promxy secret",
+			secret,
+			err,
+			"promxySecretName", secret.Name,
+		)
+		setPromxyServerGroupsCondition(groups, kofv1beta1.SecretSyncedCondition, "SecretGetFailed", err)
+		return err
+	}
+	if string(secret.Data["config.yaml"]) == data && isPromxySecretLabeled(secret) &&
+		arePromxyServerGroupsReloaded(groups) {
+		setPromxyServerGroupsCondition(groups, kofv1beta1.SecretSyncedCondition, "SecretUpToDate", nil)
+		return nil
 	}
+	setSecretOperatorLabels(secret)
+	secret.Data = map[string][]byte{
+		"config.yaml": []byte(data),
+	}
+	log.Info("Updating promxy config secret", "secretName", name)
+	if err := r.Update(ctx, secret); err != nil {
+		record.LogEvent(
+			ctx,
+			"PromxySecretUpdateFailed",
+			"Cannot update promxy secret",
+			secret,
+			err,
+			"promxySecretName", secret.Name,
+		)
+		setPromxyServerGroupsCondition(groups, kofv1beta1.SecretSyncedCondition, "SecretUpdateFailed", err)
+		return err
+	}
+	setPromxyServerGroupsCondition(groups, kofv1beta1.SecretSyncedCondition, "SecretUpdated", nil)
+	if err := r.PromxyConfigReload(ctx); err != nil {
+		record.LogEvent(
+			ctx,
+			"PromxySecretReloadFailed",
+			"Cannot reload promxy config",
+			secret,
+			err,
+			"promxySecretName", secret.Name,
+		)
+		setPromxyServerGroupsCondition(groups, kofv1beta1.PromxyReloadedCondition, "ReloadFailed", err)
+		return err
+	}
+	setPromxyServerGroupsCondition(groups, kofv1beta1.PromxyReloadedCondition, "Reloaded", nil)
+	return nil
+}
 
-	return ctrl.Result{}, nil
+// updateStatuses writes the in-memory status of the groups to the API server.
+func (r *PromxyServerGroupReconciler) updateStatuses(ctx context.Context, groups []*kofv1beta1.PromxyServerGroup) error {
+	var errs []error
+	for _, group := range groups {
+		group.Status.ObservedGeneration = group.Generation
+		if err := r.Status().Update(ctx, group); err != nil && !errors.IsNotFound(err) {
+			errs = append(errs, fmt.Errorf("promxy server group %s/%s: %w", group.Namespace, group.Name, err))
+		}
+	}
+	return goerrors.Join(errs...)
+}
+
+// setPromxyServerGroupsCondition sets the same condition on all the groups.
+func setPromxyServerGroupsCondition(groups []*kofv1beta1.PromxyServerGroup, conditionType, reason string, err error) {
+	for _, group := range groups {
+		setPromxyServerGroupCondition(group, conditionType, reason, err)
+	}
+}
+
+// setPromxyServerGroupCondition sets the condition to True when err is nil, and to False with the error message otherwise.
+func setPromxyServerGroupCondition(group *kofv1beta1.PromxyServerGroup, conditionType, reason string, err error) {
+	condition := metav1.Condition{
+		Type:               conditionType,
+		Status:             metav1.ConditionTrue,
+		Reason:             reason,
+		ObservedGeneration: group.Generation,
+	}
+	if err != nil {
+		condition.Status = metav1.ConditionFalse
+		condition.Message = err.Error()
+	}
+	meta.SetStatusCondition(&group.Status.Conditions, condition)
 }
 
 func setSecretOperatorLabels(secret *coreV1.Secret) {
 	secret.Labels = map[string]string{labels.ManagedByLabel: k8s.ManagedByValue}
 }
 
+func isPromxySecretLabeled(secret *coreV1.Secret) bool {
+	return len(secret.Labels) == 1 && secret.Labels[labels.ManagedByLabel] == k8s.ManagedByValue
+}
+
+// arePromxyServerGroupsReloaded reports whether the last promxy reload succeeded for all the groups,
+// so a failed reload is retried even if the rendered config did not change since.
+func arePromxyServerGroupsReloaded(groups []*kofv1beta1.PromxyServerGroup) bool {
+	for _, group := range groups {
+		if !meta.IsStatusConditionTrue(group.Status.Conditions, kofv1beta1.PromxyReloadedCondition) {
+			return false
+		}
+	}
+	return true
+}
+
+// getPromxyServerGroupCredentials returns the basic auth credentials of the group,
+// which are empty when basic auth is not configured.
+func getPromxyServerGroupCredentials(
+	ctx context.Context,
+	k8sClient client.Client,
+	group *kofv1beta1.PromxyServerGroup,
+	releaseNamespace string,
+) (username, password string, err error) {
+	basicAuth := group.Spec.HttpClient.BasicAuth
+	if basicAuth.CredentialsSecretName == "" {
+		return "", "", nil
+	}
+
+	credentialsSecret := &coreV1.Secret{}
+	if err := k8sClient.Get(ctx, types.NamespacedName{
+		Name:      basicAuth.CredentialsSecretName,
+		Namespace: releaseNamespace,
+	}, credentialsSecret); err != nil {
+		return "", "", err
+	}
+	return string(credentialsSecret.Data[basicAuth.UsernameKey]), string(credentialsSecret.Data[basicAuth.PasswordKey]), nil
+}
+
 // SetupWithManager sets up the controller with the Manager.
+// Status updates do not trigger reconciliation, the targets are probed by PromxyTargetProber.
 func (r *PromxyServerGroupReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&kofv1beta1.PromxyServerGroup{}).
+		For(&kofv1beta1.PromxyServerGroup{}, builder.WithPredicates(
+			predicate.Or(predicate.GenerationChangedPredicate{}, predicate.LabelChangedPredicate{}),
+		)).
 		Complete(r)
 }
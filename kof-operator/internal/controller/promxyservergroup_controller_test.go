@@ -18,12 +18,14 @@ package controller
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"gopkg.in/yaml.v3"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
@@ -187,6 +189,111 @@ promxy:
 `))
 		})
 
+		It("should report sync conditions and target health in status", func() {
+			reloads := 0
+			controllerReconciler.PromxyConfigReload = func(_ context.Context) error {
+				reloads++
+				return nil
+			}
+			prober := &PromxyTargetProber{
+				Client: k8sClient,
+				Probe: func(_ context.Context, probe PromxyTargetProbe) error {
+					Expect(probe.URL).To(Equal("https://test.example.net:443/storage/source/api/v1/query?query=1"))
+					Expect(probe.Username).To(Equal("u"))
+					Expect(probe.Password).To(Equal("p"))
+					Expect(probe.InsecureSkipVerify).To(BeTrue())
+					Expect(probe.Timeout).To(Equal(time.Second))
+					return fmt.Errorf("connection refused")
+				},
+			}
+
+			By("Reconciling the created resource")
+			result, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: promxyServerGroupNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeZero())
+			Expect(prober.ProbeAll(ctx)).To(Succeed())
+
+			resource := &kofv1beta1.PromxyServerGroup{}
+			Expect(k8sClient.Get(ctx, promxyServerGroupNamespacedName, resource)).To(Succeed())
+			Expect(resource.Status.ObservedGeneration).To(Equal(resource.Generation))
+			Expect(meta.IsStatusConditionTrue(resource.Status.Conditions, kofv1beta1.ConfigRenderedCondition)).To(BeTrue())
+			Expect(meta.IsStatusConditionTrue(resource.Status.Conditions, kofv1beta1.SecretSyncedCondition)).To(BeTrue())
+			Expect(meta.IsStatusConditionTrue(resource.Status.Conditions, kofv1beta1.PromxyReloadedCondition)).To(BeTrue())
+			Expect(meta.IsStatusConditionFalse(resource.Status.Conditions, kofv1beta1.TargetsHealthyCondition)).To(BeTrue())
+			Expect(resource.Status.HealthyTargets).To(Equal("0/1"))
+			Expect(resource.Status.Targets).To(HaveLen(1))
+			Expect(resource.Status.Targets[0].Target).To(Equal("test.example.net:443"))
+			Expect(resource.Status.Targets[0].Healthy).To(BeFalse())
+			Expect(resource.Status.Targets[0].LastError).To(Equal("connection refused"))
+			Expect(resource.Status.Targets[0].LastProbeTime.IsZero()).To(BeFalse())
+
+			By("Reconciling again with an unchanged config")
+			reloadsBefore := reloads
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: promxyServerGroupNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reloads).To(Equal(reloadsBefore))
+			Expect(k8sClient.Get(ctx, promxyServerGroupNamespacedName, resource)).To(Succeed())
+			synced := meta.FindStatusCondition(resource.Status.Conditions, kofv1beta1.SecretSyncedCondition)
+			Expect(synced).NotTo(BeNil())
+			Expect(synced.Reason).To(Equal("SecretUpToDate"))
+			Expect(resource.Status.HealthyTargets).To(Equal("0/1"))
+
+			By("Reconciling a changed config with a failing promxy reload")
+			resource.Spec.Targets = []string{"test2.example.net:443"}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+			prober.Probe = func(_ context.Context, _ PromxyTargetProbe) error { return nil }
+			controllerReconciler.PromxyConfigReload = func(_ context.Context) error { return fmt.Errorf("reload failed") }
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: promxyServerGroupNamespacedName,
+			})
+			Expect(err).To(HaveOccurred())
+			Expect(prober.ProbeAll(ctx)).To(Succeed())
+
+			Expect(k8sClient.Get(ctx, promxyServerGroupNamespacedName, resource)).To(Succeed())
+			Expect(meta.IsStatusConditionTrue(resource.Status.Conditions, kofv1beta1.SecretSyncedCondition)).To(BeTrue())
+			reloaded := meta.FindStatusCondition(resource.Status.Conditions, kofv1beta1.PromxyReloadedCondition)
+			Expect(reloaded).NotTo(BeNil())
+			Expect(reloaded.Status).To(Equal(metav1.ConditionFalse))
+			Expect(reloaded.Message).To(Equal("reload failed"))
+			Expect(meta.IsStatusConditionTrue(resource.Status.Conditions, kofv1beta1.TargetsHealthyCondition)).To(BeTrue())
+			Expect(resource.Status.HealthyTargets).To(Equal("1/1"))
+
+			By("Retrying the failed reload although the config is unchanged")
+			controllerReconciler.PromxyConfigReload = func(_ context.Context) error { return nil }
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: promxyServerGroupNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, promxyServerGroupNamespacedName, resource)).To(Succeed())
+			Expect(meta.IsStatusConditionTrue(resource.Status.Conditions, kofv1beta1.PromxyReloadedCondition)).To(BeTrue())
+
+			resource.Spec.Targets = []string{"test.example.net:443"}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+		})
+
+		It("should report a missing credentials secret in status", func() {
+			Expect(k8sClient.Delete(ctx, &coreV1.Secret{ObjectMeta: metav1.ObjectMeta{
+				Name:      credentialsSecretName,
+				Namespace: ReleaseNamespace,
+			}})).To(Succeed())
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: promxyServerGroupNamespacedName,
+			})
+			Expect(err).To(HaveOccurred())
+
+			resource := &kofv1beta1.PromxyServerGroup{}
+			Expect(k8sClient.Get(ctx, promxyServerGroupNamespacedName, resource)).To(Succeed())
+			rendered := meta.FindStatusCondition(resource.Status.Conditions, kofv1beta1.ConfigRenderedCondition)
+			Expect(rendered).NotTo(BeNil())
+			Expect(rendered.Status).To(Equal(metav1.ConditionFalse))
+			Expect(rendered.Reason).To(Equal("CredentialsSecretNotFound"))
+		})
+
 		It("should successfully reconcile the resource without auth", func() {
 			resource := &kofv1beta1.PromxyServerGroup{}
 			err := k8sClient.Get(ctx, promxyServerGroupNamespacedName, resource)
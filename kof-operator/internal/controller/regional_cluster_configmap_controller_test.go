@@ -20,6 +20,7 @@ import (
 	"context"
 	"os"
 	"strconv"
+	"testing"
 	"time"
 
 	kcmv1beta1 "github.com/K0rdent/kcm/api/v1beta1"
@@ -32,8 +33,10 @@ import (
 	. "github.com/onsi/gomega"
 	corev1 "k8s.io/api/core/v1"
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
@@ -221,6 +224,7 @@ var _ = Describe("RegionalConfigMap Controller", func() {
 		BeforeEach(func() {
 			Expect(os.Setenv("KOF_VT_CLUSTER_NAME", vtClusterName)).To(Succeed())
 			Expect(os.Setenv("KOF_VL_CLUSTER_NAME", vlClusterName)).To(Succeed())
+			Expect(os.Setenv("KOF_VM_CLUSTER_NAME", vmClusterName)).To(Succeed())
 
 			clusterDeploymentReconciler = &ClusterDeploymentReconciler{
 				Client: k8sClient,
@@ -283,6 +287,14 @@ var _ = Describe("RegionalConfigMap Controller", func() {
 				Namespace: defaultNamespace,
 			}
 
+			metricsConnNamespacedName := types.NamespacedName{
+				Name: GetMetricsStorageConnectionName(
+					regionalClusterConfigmapNamespacedName.Name,
+					regionalClusterConfigmapNamespacedName.Namespace,
+				),
+				Namespace: defaultNamespace,
+			}
+
 			logsConnNamespacedName := types.NamespacedName{
 				Name: GetLogsStorageConnectionName(
 					regionalClusterConfigmapNamespacedName.Name,
@@ -336,6 +348,10 @@ var _ = Describe("RegionalConfigMap Controller", func() {
 			Expect(tracesConn.Spec.ClusterRef.Namespace).To(Equal(ReleaseNamespace))
 			Expect(tracesConn.Spec.TargetStorageNode.Address).To(Equal("vmauth.test-aws-ue2.kof.example.com/vts"))
 
+			By("skipping metrics VMStorageConnection outside of the Istio mesh")
+			err = k8sClient.Get(ctx, metricsConnNamespacedName, &kofv1beta1.VMStorageConnection{})
+			Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
 			By("reading logs VMStorageConnection")
 			logsConn := &kofv1beta1.VMStorageConnection{}
 			err = k8sClient.Get(ctx, logsConnNamespacedName, logsConn)
@@ -504,3 +520,53 @@ var _ = Describe("RegionalConfigMap Controller", func() {
 		)
 	})
 })
+
+func TestCreateOrUpdateMetricsStorageConnection(t *testing.T) {
+	t.Setenv("RELEASE_NAMESPACE", migrationNamespace)
+	t.Setenv("KOF_VM_CLUSTER_NAME", vmClusterName)
+	ctx := context.Background()
+
+	domainRegional := newMigrationRegionalConfigMap("domain", "domain.example.com")
+	domainRegional.Data[ReadMetricsKey] = "https://vmauth.domain.example.com/vm/select/0/prometheus"
+	istioRegional := newMigrationRegionalConfigMap("mesh", "mesh.example.com")
+	istioRegional.Data[RegionalIstioRoleKey] = "member"
+	// The connection to the public endpoint host created by a previous version is removed.
+	staleConn := &kofv1beta1.VMStorageConnection{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      GetMetricsStorageConnectionName(domainRegional.Name, domainRegional.Namespace),
+			Namespace: migrationNamespace,
+		},
+		Spec: kofv1beta1.VMStorageConnectionSpec{
+			TargetStorageNode: kofv1beta1.TargetStorageNode{Address: "vmauth.domain.example.com:8401"},
+		},
+	}
+	k8sClient := newMigrationTestClient(t, domainRegional, istioRegional, staleConn)
+
+	for _, cm := range []*corev1.ConfigMap{domainRegional, istioRegional} {
+		regional, err := NewRegionalClusterConfigMap(ctx, cm, k8sClient)
+		if err != nil {
+			t.Fatalf("failed to create regional cluster ConfigMap: %v", err)
+		}
+		if err := regional.CreateOrUpdateMetricsStorageConnection(); err != nil {
+			t.Fatalf("failed to create or update metrics VMStorageConnection: %v", err)
+		}
+	}
+
+	if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(staleConn), &kofv1beta1.VMStorageConnection{}); !apierrors.IsNotFound(err) {
+		t.Fatalf("expected no metrics VMStorageConnection outside of the Istio mesh, got %v", err)
+	}
+
+	conn := &kofv1beta1.VMStorageConnection{}
+	if err := k8sClient.Get(ctx, types.NamespacedName{
+		Name:      GetMetricsStorageConnectionName(istioRegional.Name, istioRegional.Namespace),
+		Namespace: migrationNamespace,
+	}, conn); err != nil {
+		t.Fatalf("failed to get metrics VMStorageConnection of the Istio regional cluster: %v", err)
+	}
+	if address := conn.Spec.TargetStorageNode.Address; address != "mesh-vmselect:8401" {
+		t.Fatalf("expected the in-mesh vmselect address, got %q", address)
+	}
+	if conn.Spec.ClusterRef.Kind != "VMCluster" || conn.Spec.ClusterRef.Name != vmClusterName {
+		t.Fatalf("unexpected cluster ref %+v", conn.Spec.ClusterRef)
+	}
+}
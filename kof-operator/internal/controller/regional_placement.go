@@ -0,0 +1,238 @@
+package controller
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	kcmv1beta1 "github.com/K0rdent/kcm/api/v1beta1"
+	kofv1beta1 "github.com/k0rdent/kof/kof-operator/api/v1beta1"
+	"github.com/k0rdent/kof/kof-operator/internal/controller/cloud"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Weights of the regional placement score components.
+const (
+	// placementLocationWeight is given to regional clusters in the same cloud and location as the child.
+	placementLocationWeight = 100
+	// placementCloudWeight is given to regional clusters in the same cloud but another location.
+	placementCloudWeight = 40
+	// placementKcmRegionWeight is given to regional clusters in the same KCM region as the child.
+	placementKcmRegionWeight = 50
+	// placementAffinityWeight is given to regional clusters of the group requested by the affinity label of the child.
+	placementAffinityWeight = 200
+	// placementCapacityWeight is scaled by the free share of the regional cluster capacity.
+	placementCapacityWeight = 30
+	// placementHealthyWeight is given to regional clusters that are ready and answer the promxy probe.
+	placementHealthyWeight = 20
+	// placementUnhealthyWeight is given to regional clusters that are not ready or fail the promxy probe.
+	placementUnhealthyWeight = -50
+)
+
+// RegionalPlacementScore is the scoring breakdown of a regional cluster candidate of a child cluster.
+type RegionalPlacementScore struct {
+	Location  int
+	KcmRegion int
+	Affinity  int
+	Capacity  int
+	Health    int
+}
+
+// Total returns the sum of the score components.
+func (s RegionalPlacementScore) Total() int {
+	return s.Location + s.KcmRegion + s.Affinity + s.Capacity + s.Health
+}
+
+func (s RegionalPlacementScore) String() string {
+	return fmt.Sprintf("%d (location=%d kcmRegion=%d affinity=%d capacity=%d health=%d)",
+		s.Total(), s.Location, s.KcmRegion, s.Affinity, s.Capacity, s.Health)
+}
+
+// placementChild holds the facts about the child cluster used to score the regional clusters.
+type placementChild struct {
+	// cloud is empty when the infrastructure provider is unknown.
+	cloud        string
+	config       *ClusterDeploymentConfig
+	inKcmRegion  bool
+	configMap    *corev1.ConfigMap
+	affinity     string
+	antiAffinity string
+}
+
+// regionalCandidate is a regional cluster considered for the placement of a child cluster.
+type regionalCandidate struct {
+	configMap     *corev1.ConfigMap
+	configData    *ConfigData
+	sameKcmRegion bool
+	group         string
+	// capacity is the maximum number of child clusters, zero means unlimited.
+	capacity int
+	children int
+	// healthy is nil when the health of the regional cluster is unknown.
+	healthy *bool
+
+	score    RegionalPlacementScore
+	rejected string
+}
+
+func (r *regionalCandidate) key() string {
+	return r.configData.RegionalClusterNamespace + "/" + r.configData.RegionalClusterName
+}
+
+// evaluate scores the candidate for the child, or records why the child cannot be placed there.
+func (r *regionalCandidate) evaluate(child *placementChild) {
+	r.score = RegionalPlacementScore{}
+	r.rejected = ""
+
+	if child.inKcmRegion && !r.sameKcmRegion {
+		r.rejected = "not in the KCM region of the child cluster"
+		return
+	}
+	if r.sameKcmRegion {
+		r.score.KcmRegion = placementKcmRegionWeight
+	}
+
+	if child.antiAffinity != "" && r.group == child.antiAffinity {
+		r.rejected = fmt.Sprintf("in the anti-affinity group %q", child.antiAffinity)
+		return
+	}
+	affinity := child.affinity != "" && r.group == child.affinity
+	if affinity {
+		r.score.Affinity = placementAffinityWeight
+	}
+
+	sameCloud := child.cloud != "" && child.cloud == r.configData.RegionalClusterCloud
+	switch {
+	case sameCloud && locationIsTheSame(child.cloud, child.config, r.configData.ToClusterDeploymentConfig()):
+		r.score.Location = placementLocationWeight
+	case cloudHasLocation(child.cloud) && !affinity:
+		r.rejected = fmt.Sprintf("cloud %q or location doesn't match", r.configData.RegionalClusterCloud)
+		return
+	case sameCloud:
+		r.score.Location = placementCloudWeight
+	}
+
+	if r.capacity > 0 {
+		if r.children >= r.capacity {
+			r.rejected = fmt.Sprintf("at capacity with %d/%d child clusters", r.children, r.capacity)
+			return
+		}
+		r.score.Capacity = placementCapacityWeight * (r.capacity - r.children) / r.capacity
+	}
+
+	if r.healthy != nil {
+		r.score.Health = placementUnhealthyWeight
+		if *r.healthy {
+			r.score.Health = placementHealthyWeight
+		}
+	}
+}
+
+func (r *regionalCandidate) String() string {
+	if r.rejected != "" {
+		return fmt.Sprintf("%s: rejected, %s", r.key(), r.rejected)
+	}
+	return fmt.Sprintf("%s: %s", r.key(), r.score)
+}
+
+// pickRegionalCandidate returns the eligible candidate with the highest score, preferring
+// the regional cluster the child already uses, then fewer child clusters, then the name.
+// Returns nil when no candidate is eligible.
+func pickRegionalCandidate(candidates []*regionalCandidate, child *placementChild) *regionalCandidate {
+	var best *regionalCandidate
+	for _, candidate := range candidates {
+		if candidate.rejected != "" {
+			continue
+		}
+		if isPreviouslyUsedRegionalCluster(child.configMap, candidate.configData) {
+			return candidate
+		}
+		if best == nil || compareRegionalCandidates(candidate, best) < 0 {
+			best = candidate
+		}
+	}
+	return best
+}
+
+// compareRegionalCandidates orders the candidates from the best to the worst.
+func compareRegionalCandidates(a, b *regionalCandidate) int {
+	return cmp.Or(
+		cmp.Compare(b.score.Total(), a.score.Total()),
+		cmp.Compare(a.children, b.children),
+		strings.Compare(a.key(), b.key()),
+	)
+}
+
+// describeRegionalCandidates returns the scoring breakdown of all the candidates from the best to the worst.
+func describeRegionalCandidates(candidates []*regionalCandidate) string {
+	sorted := slices.Clone(candidates)
+	slices.SortFunc(sorted, func(a, b *regionalCandidate) int {
+		return cmp.Or(
+			strings.Compare(a.rejected, b.rejected),
+			compareRegionalCandidates(a, b),
+		)
+	})
+
+	parts := make([]string, 0, len(sorted))
+	for _, candidate := range sorted {
+		parts = append(parts, candidate.String())
+	}
+	return strings.Join(parts, "; ")
+}
+
+// cloudHasLocation reports whether child clusters of the cloud are placed only in regional clusters of the same location.
+func cloudHasLocation(cloudName string) bool {
+	switch cloudName {
+	case cloud.AWS, cloud.Azure, cloud.Docker, cloud.OpenStack, cloud.VSphere:
+		return true
+	}
+	return false
+}
+
+// regionalCapacity returns the maximum number of child clusters set by the capacity annotation
+// of the regional ClusterDeployment, or zero when it is not set or invalid.
+func regionalCapacity(regional *kcmv1beta1.ClusterDeployment) int {
+	capacity, err := strconv.Atoi(regional.Annotations[KofRegionalCapacityAnnotation])
+	if err != nil || capacity < 0 {
+		return 0
+	}
+	return capacity
+}
+
+// regionalHealth combines the Ready condition of the regional ClusterDeployment
+// with the TargetsHealthy condition of its PromxyServerGroup. Returns nil when both are unknown.
+func regionalHealth(regional *kcmv1beta1.ClusterDeployment, group *kofv1beta1.PromxyServerGroup) *bool {
+	var conditions []*bool
+	if regional != nil {
+		conditions = append(conditions, conditionStatus(regional.Status.Conditions, kcmv1beta1.ReadyCondition))
+	}
+	if group != nil {
+		conditions = append(conditions, conditionStatus(group.Status.Conditions, kofv1beta1.TargetsHealthyCondition))
+	}
+
+	var healthy *bool
+	for _, condition := range conditions {
+		if condition == nil {
+			continue
+		}
+		if !*condition {
+			return condition
+		}
+		healthy = condition
+	}
+	return healthy
+}
+
+// conditionStatus returns the status of the condition, or nil when it is missing or unknown.
+func conditionStatus(conditions []metav1.Condition, conditionType string) *bool {
+	condition := meta.FindStatusCondition(conditions, conditionType)
+	if condition == nil || condition.Status == metav1.ConditionUnknown {
+		return nil
+	}
+	status := condition.Status == metav1.ConditionTrue
+	return &status
+}
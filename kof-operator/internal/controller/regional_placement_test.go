@@ -0,0 +1,184 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	kcmv1beta1 "github.com/K0rdent/kcm/api/v1beta1"
+	kofv1beta1 "github.com/k0rdent/kof/kof-operator/api/v1beta1"
+	"github.com/k0rdent/kof/kof-operator/internal/controller/cloud"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Regional placement", func() {
+	newCandidate := func(name, cloudName, awsRegion string) *regionalCandidate {
+		return &regionalCandidate{
+			configData: &ConfigData{
+				RegionalClusterName:      name,
+				RegionalClusterNamespace: defaultNamespace,
+				RegionalClusterCloud:     cloudName,
+				AWSRegion:                awsRegion,
+			},
+		}
+	}
+
+	newChild := func(cloudName, awsRegion string) *placementChild {
+		return &placementChild{
+			cloud:  cloudName,
+			config: &ClusterDeploymentConfig{Region: awsRegion},
+		}
+	}
+
+	evaluate := func(child *placementChild, candidates ...*regionalCandidate) []*regionalCandidate {
+		for _, candidate := range candidates {
+			candidate.evaluate(child)
+		}
+		return candidates
+	}
+
+	It("should reject regional clusters in another location of a cloud with location", func() {
+		sameLocation := newCandidate("aws-ue2", cloud.AWS, "us-east-2")
+		otherLocation := newCandidate("aws-uw2", cloud.AWS, "us-west-2")
+		otherCloud := newCandidate("azure", cloud.Azure, "")
+
+		candidates := evaluate(newChild(cloud.AWS, "us-east-2"), sameLocation, otherLocation, otherCloud)
+
+		Expect(sameLocation.rejected).To(BeEmpty())
+		Expect(sameLocation.score.Location).To(Equal(placementLocationWeight))
+		Expect(otherLocation.rejected).To(ContainSubstring("location doesn't match"))
+		Expect(otherCloud.rejected).To(ContainSubstring("location doesn't match"))
+		Expect(pickRegionalCandidate(candidates, newChild(cloud.AWS, "us-east-2"))).To(Equal(sameLocation))
+	})
+
+	It("should place child clusters of unknown cloud by the rest of the score", func() {
+		small := newCandidate("small", cloud.AWS, "us-east-2")
+		small.capacity = 2
+		small.children = 1
+		large := newCandidate("large", cloud.Azure, "")
+		large.capacity = 10
+		large.children = 1
+
+		child := newChild("", "")
+		candidates := evaluate(child, small, large)
+
+		Expect(small.rejected).To(BeEmpty())
+		Expect(large.rejected).To(BeEmpty())
+		Expect(large.score.Capacity).To(BeNumerically(">", small.score.Capacity))
+		Expect(pickRegionalCandidate(candidates, child)).To(Equal(large))
+	})
+
+	It("should apply affinity and anti-affinity groups", func() {
+		inGroup := newCandidate("in-group", cloud.AWS, "us-west-2")
+		inGroup.group = "gold"
+		sameLocation := newCandidate("same-location", cloud.AWS, "us-east-2")
+		sameLocation.group = "silver"
+
+		child := newChild(cloud.AWS, "us-east-2")
+		child.affinity = "gold"
+		candidates := evaluate(child, inGroup, sameLocation)
+		Expect(inGroup.rejected).To(BeEmpty())
+		Expect(inGroup.score.Affinity).To(Equal(placementAffinityWeight))
+		Expect(pickRegionalCandidate(candidates, child)).To(Equal(inGroup))
+
+		child.affinity = ""
+		child.antiAffinity = "silver"
+		candidates = evaluate(child, inGroup, sameLocation)
+		Expect(sameLocation.rejected).To(ContainSubstring(`anti-affinity group "silver"`))
+		Expect(pickRegionalCandidate(candidates, child)).To(BeNil())
+	})
+
+	It("should reject regional clusters at capacity or outside of the KCM region", func() {
+		full := newCandidate("full", cloud.AWS, "us-east-2")
+		full.sameKcmRegion = true
+		full.capacity = 1
+		full.children = 1
+		otherRegion := newCandidate("other-region", cloud.AWS, "us-east-2")
+
+		child := newChild(cloud.AWS, "us-east-2")
+		child.inKcmRegion = true
+		evaluate(child, full, otherRegion)
+
+		Expect(full.rejected).To(ContainSubstring("at capacity with 1/1"))
+		Expect(otherRegion.rejected).To(ContainSubstring("not in the KCM region"))
+	})
+
+	It("should prefer healthy regional clusters and break ties deterministically", func() {
+		healthy, unhealthy := true, false
+		a := newCandidate("a", cloud.AWS, "us-east-2")
+		a.healthy = &unhealthy
+		b := newCandidate("b", cloud.AWS, "us-east-2")
+		b.healthy = &healthy
+		c := newCandidate("c", cloud.AWS, "us-east-2")
+		c.healthy = &healthy
+
+		child := newChild(cloud.AWS, "us-east-2")
+		candidates := evaluate(child, c, a, b)
+
+		Expect(pickRegionalCandidate(candidates, child)).To(Equal(b))
+		Expect(describeRegionalCandidates(candidates)).To(Equal(
+			"default/b: 120 (location=100 kcmRegion=0 affinity=0 capacity=0 health=20); " +
+				"default/c: 120 (location=100 kcmRegion=0 affinity=0 capacity=0 health=20); " +
+				"default/a: 50 (location=100 kcmRegion=0 affinity=0 capacity=0 health=-50)",
+		))
+	})
+
+	It("should keep the previously used regional cluster while it is eligible", func() {
+		previous := newCandidate("previous", cloud.AWS, "us-east-2")
+		previous.healthy = new(bool)
+		better := newCandidate("better", cloud.AWS, "us-east-2")
+
+		child := newChild(cloud.AWS, "us-east-2")
+		child.configMap = &corev1.ConfigMap{Data: map[string]string{
+			RegionalClusterNameKey:      "previous",
+			RegionalClusterNamespaceKey: defaultNamespace,
+		}}
+		candidates := evaluate(child, previous, better)
+
+		Expect(pickRegionalCandidate(candidates, child)).To(Equal(previous))
+	})
+
+	It("should combine the health of the regional ClusterDeployment and PromxyServerGroup", func() {
+		condition := func(conditionType string, status metav1.ConditionStatus) []metav1.Condition {
+			return []metav1.Condition{{Type: conditionType, Status: status}}
+		}
+		regional := &kcmv1beta1.ClusterDeployment{}
+		regional.Status.Conditions = condition(kcmv1beta1.ReadyCondition, metav1.ConditionTrue)
+		group := &kofv1beta1.PromxyServerGroup{}
+
+		Expect(regionalHealth(nil, nil)).To(BeNil())
+		Expect(*regionalHealth(regional, group)).To(BeTrue())
+
+		group.Status.Conditions = condition(kofv1beta1.TargetsHealthyCondition, metav1.ConditionFalse)
+		Expect(*regionalHealth(regional, group)).To(BeFalse())
+
+		group.Status.Conditions = condition(kofv1beta1.TargetsHealthyCondition, metav1.ConditionUnknown)
+		Expect(*regionalHealth(regional, group)).To(BeTrue())
+	})
+
+	It("should read the capacity annotation of the regional ClusterDeployment", func() {
+		regional := &kcmv1beta1.ClusterDeployment{}
+		Expect(regionalCapacity(regional)).To(Equal(0))
+
+		regional.Annotations = map[string]string{KofRegionalCapacityAnnotation: "5"}
+		Expect(regionalCapacity(regional)).To(Equal(5))
+
+		regional.Annotations[KofRegionalCapacityAnnotation] = "-1"
+		Expect(regionalCapacity(regional)).To(Equal(0))
+	})
+})
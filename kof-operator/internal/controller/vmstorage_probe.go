@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"strings"
+	"time"
+
+	kofv1beta1 "github.com/k0rdent/kof/kof-operator/api/v1beta1"
+)
+
+// StorageNodeProbeInterval is how often storage nodes are re-probed.
+const StorageNodeProbeInterval = time.Minute
+
+// StorageNodeProbe describes a single storage node to probe.
+type StorageNodeProbe struct {
+	// Address is the host:port to dial.
+	Address            string
+	TLS                bool
+	InsecureSkipVerify bool
+	Timeout            time.Duration
+}
+
+type StorageNodeProbeFunc func(ctx context.Context, probe StorageNodeProbe) error
+
+// NewStorageNodeProbe builds the probe of the storage node of the connection.
+// The path of the address is dropped, and the port defaults to 443 with TLS and to 80 otherwise.
+func NewStorageNodeProbe(conn *kofv1beta1.VMStorageConnection) StorageNodeProbe {
+	node := conn.Spec.TargetStorageNode
+	host, _, _ := strings.Cut(node.Address, "/")
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		port := "80"
+		if node.TLSConfig.Enabled {
+			port = "443"
+		}
+		host = net.JoinHostPort(host, port)
+	}
+
+	return StorageNodeProbe{
+		Address:            host,
+		TLS:                node.TLSConfig.Enabled,
+		InsecureSkipVerify: node.TLSConfig.InsecureSkipVerify,
+		Timeout:            DefaultDialTimeout.Duration,
+	}
+}
+
+// ProbeStorageNode opens a TCP connection to the storage node, completing
+// the TLS handshake when TLS is enabled, and closes it right away.
+func ProbeStorageNode(ctx context.Context, probe StorageNodeProbe) error {
+	ctx, cancel := context.WithTimeout(ctx, probe.Timeout)
+	defer cancel()
+
+	var dialer interface {
+		DialContext(ctx context.Context, network, address string) (net.Conn, error)
+	} = &net.Dialer{}
+	if probe.TLS {
+		dialer = &tls.Dialer{
+			Config: &tls.Config{
+				InsecureSkipVerify: probe.InsecureSkipVerify,
+			},
+		}
+	}
+
+	conn, err := dialer.DialContext(ctx, "tcp", probe.Address)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
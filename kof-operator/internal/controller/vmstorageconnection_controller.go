@@ -18,6 +18,7 @@ package controller
 
 import (
 	"context"
+	goerrors "errors"
 	"fmt"
 	"maps"
 	"reflect"
@@ -25,17 +26,25 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	vmv1 "github.com/VictoriaMetrics/operator/api/operator/v1"
+	vmv1beta1 "github.com/VictoriaMetrics/operator/api/operator/v1beta1"
 	kofv1beta1 "github.com/k0rdent/kof/kof-operator/api/v1beta1"
+	"github.com/k0rdent/kof/kof-operator/internal/controller/record"
 	"github.com/k0rdent/kof/kof-operator/internal/models/labels"
 	"github.com/k0rdent/kof/kof-operator/internal/telemetry"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 )
 
 const (
@@ -59,10 +68,31 @@ var storageNodeOwnedArgs = []string{
 	storageNodeTLSInsecureSkipVerify,
 }
 
+// storageNodeAuthSupported reports whether the select component of the cluster kind accepts
+// the storageNode credential and TLS args. vmselect only accepts the storageNode addresses,
+// which point at the clusternative listeners of the lower-level vmselects.
+func storageNodeAuthSupported(clusterKind string) bool {
+	return clusterKind != "VMCluster"
+}
+
+// unsupportedStorageNodeConfig returns an error when the storage node sets credentials or TLS
+// its cluster kind does not support. Such storage nodes are left out of the cluster.
+func unsupportedStorageNodeConfig(conn *kofv1beta1.VMStorageConnection) error {
+	kind := conn.Spec.ClusterRef.Kind
+	node := conn.Spec.TargetStorageNode
+	if storageNodeAuthSupported(kind) {
+		return nil
+	}
+	if node.Secret.Name != "" || node.TLSConfig.Enabled || node.TLSConfig.InsecureSkipVerify {
+		return fmt.Errorf("%s storage nodes do not support the secret and tls_config settings", kind)
+	}
+	return nil
+}
+
 // storageCluster is the common interface for cluster types managed by VMStorageConnection.
 type storageCluster interface {
 	client.Object
-	// clusterKind returns the resource kind string (e.g. "VTCluster" or "VLCluster").
+	// clusterKind returns the resource kind string (e.g. "VTCluster", "VLCluster" or "VMCluster").
 	clusterKind() string
 	// storageExtraArgs returns the ExtraArgs map used for storageNode configuration.
 	storageExtraArgs() map[string]string
@@ -137,10 +167,38 @@ func (a *vlClusterAdapter) deepCopy() storageCluster {
 }
 func (a *vlClusterAdapter) object() client.Object { return a.VLCluster }
 
+// vmClusterAdapter wraps *vmv1beta1.VMCluster to implement storageCluster.
+// The storageNode args are applied to the vmselect component, its Secrets are left as is.
+type vmClusterAdapter struct{ *vmv1beta1.VMCluster }
+
+func (a *vmClusterAdapter) clusterKind() string { return "VMCluster" }
+
+func (a *vmClusterAdapter) storageExtraArgs() map[string]string {
+	if a.Spec.VMSelect == nil {
+		return nil
+	}
+	return a.Spec.VMSelect.ExtraArgs
+}
+
+func (a *vmClusterAdapter) storageSecrets() []string { return nil }
+
+func (a *vmClusterAdapter) applyStorageConfig(args map[string]string, _ []string) {
+	if a.Spec.VMSelect == nil {
+		a.Spec.VMSelect = &vmv1beta1.VMSelect{}
+	}
+	a.Spec.VMSelect.ExtraArgs = args
+}
+func (a *vmClusterAdapter) deepCopy() storageCluster {
+	return &vmClusterAdapter{a.DeepCopy()}
+}
+func (a *vmClusterAdapter) object() client.Object { return a.VMCluster }
+
 // VMStorageConnectionReconciler reconciles a VMStorageConnection object
 type VMStorageConnectionReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+	// StorageNodeProbe checks the storage nodes, probing is disabled when nil.
+	StorageNodeProbe StorageNodeProbeFunc
 }
 
 // +kubebuilder:rbac:groups=kof.k0rdent.mirantis.com,resources=vmstorageconnections,verbs=get;list;watch;create;update;patch;delete
@@ -148,9 +206,12 @@ type VMStorageConnectionReconciler struct {
 // +kubebuilder:rbac:groups=kof.k0rdent.mirantis.com,resources=vmstorageconnections/finalizers,verbs=update
 // +kubebuilder:rbac:groups=operator.victoriametrics.com,resources=vtclusters,verbs=get;list;watch;update;patch
 // +kubebuilder:rbac:groups=operator.victoriametrics.com,resources=vlclusters,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=operator.victoriametrics.com,resources=vmclusters,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch
 
-// Reconcile fetches the VMStorageConnection and configures the referenced VTCluster or VLCluster
-// resource with the target storage node address.
+// Reconcile fetches the VMStorageConnection and configures the referenced VTCluster, VLCluster
+// or VMCluster resource with the target storage node address, and records the health
+// of the storage node in the VMStorageConnection status.
 func (r *VMStorageConnectionReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	ctx, endSpan := telemetry.StartReconcileSpan(ctx, "VMStorageConnection", req.Name, req.Namespace)
 	defer endSpan()
@@ -168,20 +229,33 @@ func (r *VMStorageConnectionReconciler) Reconcile(ctx context.Context, req ctrl.
 		clusterNS = conn.Namespace
 	}
 
+	var fetch func(context.Context, string, string) (storageCluster, error)
 	switch conn.Spec.ClusterRef.Kind {
 	case "VTCluster":
-		return ctrl.Result{}, r.reconcileCluster(ctx, conn, clusterNS, r.fetchVTCluster)
+		fetch = r.fetchVTCluster
 	case "VLCluster":
-		return ctrl.Result{}, r.reconcileCluster(ctx, conn, clusterNS, r.fetchVLCluster)
+		fetch = r.fetchVLCluster
+	case "VMCluster":
+		fetch = r.fetchVMCluster
 	default:
 		return ctrl.Result{}, fmt.Errorf("unsupported cluster kind: %q", conn.Spec.ClusterRef.Kind)
 	}
+
+	if err := r.reconcileCluster(ctx, conn, clusterNS, fetch); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if conn.DeletionTimestamp.IsZero() && r.StorageNodeProbe != nil {
+		return ctrl.Result{RequeueAfter: StorageNodeProbeInterval}, nil
+	}
+	return ctrl.Result{}, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
+// Status updates do not trigger reconciliation, the storage nodes are re-probed on requeue.
 func (r *VMStorageConnectionReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&kofv1beta1.VMStorageConnection{}).
+		For(&kofv1beta1.VMStorageConnection{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
 		Named("vmstorageconnection").
 		Complete(r)
 }
@@ -220,8 +294,25 @@ func (r *VMStorageConnectionReconciler) reconcileCluster(
 		}
 	}
 
-	if err := r.syncCluster(ctx, cluster); err != nil {
-		return err
+	r.checkStorageNode(ctx, conn, clusterNS)
+	if storageNodeExcluded(conn) {
+		log.Info("Excluding unreachable storage node", "cluster", conn.Spec.ClusterRef.Name, "address", conn.Spec.TargetStorageNode.Address, "failures", conn.Status.ConsecutiveFailures)
+	}
+
+	syncErr := r.syncCluster(ctx, cluster, conn)
+	if syncErr == nil {
+		conn.Status.Included = !storageNodeExcluded(conn) && unsupportedStorageNodeConfig(conn) == nil
+	}
+	setStorageNodeReadyCondition(conn, syncErr)
+
+	if err := r.updateStatus(ctx, conn); err != nil {
+		if syncErr == nil {
+			return err
+		}
+		log.Error(err, "cannot update VMStorageConnection status")
+	}
+	if syncErr != nil {
+		return syncErr
 	}
 
 	log.Info("Reconciled VMStorageConnection", "kind", conn.Spec.ClusterRef.Kind, "cluster", conn.Spec.ClusterRef.Name, "address", conn.Spec.TargetStorageNode.Address)
@@ -236,7 +327,7 @@ func (r *VMStorageConnectionReconciler) handleClusterDeletion(ctx context.Contex
 	}
 
 	if cluster != nil {
-		if err := r.syncCluster(ctx, cluster); err != nil {
+		if err := r.syncCluster(ctx, cluster, conn); err != nil {
 			return fmt.Errorf("failed to sync %s on deletion: %w", cluster.clusterKind(), err)
 		}
 	}
@@ -246,8 +337,15 @@ func (r *VMStorageConnectionReconciler) handleClusterDeletion(ctx context.Contex
 }
 
 // buildStorageNodeConfig lists all active VMStorageConnections for the given cluster
-// and rebuilds the storageNode ExtraArgs and Secrets from scratch.
-func (r *VMStorageConnectionReconciler) buildStorageNodeConfig(ctx context.Context, clusterName, clusterKind string, existingArgs map[string]string) (map[string]string, []string, error) {
+// and rebuilds the storageNode ExtraArgs and Secrets from scratch, skipping the excluded storage nodes.
+// The current connection, when not nil, takes precedence over its listed copy which may be stale.
+// Only the storageNode addresses are set for kinds that do not support credentials and TLS.
+func (r *VMStorageConnectionReconciler) buildStorageNodeConfig(
+	ctx context.Context,
+	clusterName, clusterKind string,
+	existingArgs map[string]string,
+	current *kofv1beta1.VMStorageConnection,
+) (map[string]string, []string, error) {
 	connList := new(kofv1beta1.VMStorageConnectionList)
 	if err := r.List(ctx, connList, client.MatchingLabels{
 		labels.ClusterNameLabelKey: clusterName,
@@ -274,8 +372,12 @@ func (r *VMStorageConnectionReconciler) buildStorageNodeConfig(ctx context.Conte
 		tlsInsecure   = make([]string, 0, len(connList.Items))
 	)
 
-	for _, conn := range connList.Items {
-		if !conn.DeletionTimestamp.IsZero() {
+	for i := range connList.Items {
+		conn := &connList.Items[i]
+		if current != nil && conn.Namespace == current.Namespace && conn.Name == current.Name {
+			conn = current
+		}
+		if !conn.DeletionTimestamp.IsZero() || storageNodeExcluded(conn) || unsupportedStorageNodeConfig(conn) != nil {
 			continue
 		}
 
@@ -291,8 +393,12 @@ func (r *VMStorageConnectionReconciler) buildStorageNodeConfig(ctx context.Conte
 		tlsInsecure = append(tlsInsecure, strconv.FormatBool(node.TLSConfig.InsecureSkipVerify))
 	}
 
-	sort.Strings(secrets)
 	setArg(args, storageNodeArg, addresses)
+	if !storageNodeAuthSupported(clusterKind) {
+		return args, nil, nil
+	}
+
+	sort.Strings(secrets)
 	setArg(args, storageNodeUsernameFileArg, usernameFiles)
 	setArg(args, storageNodePasswordFileArg, passwordFiles)
 	setArg(args, storageNodeTLSArg, tlsEnabled)
@@ -303,10 +409,10 @@ func (r *VMStorageConnectionReconciler) buildStorageNodeConfig(ctx context.Conte
 
 // syncCluster rebuilds a cluster's storageNode ExtraArgs and Secrets from all active
 // VMStorageConnections referencing it, preserving unmanaged ExtraArgs.
-func (r *VMStorageConnectionReconciler) syncCluster(ctx context.Context, cluster storageCluster) error {
+func (r *VMStorageConnectionReconciler) syncCluster(ctx context.Context, cluster storageCluster, current *kofv1beta1.VMStorageConnection) error {
 	updated := cluster.deepCopy()
 
-	args, secrets, err := r.buildStorageNodeConfig(ctx, cluster.GetName(), cluster.clusterKind(), updated.storageExtraArgs())
+	args, secrets, err := r.buildStorageNodeConfig(ctx, cluster.GetName(), cluster.clusterKind(), updated.storageExtraArgs(), current)
 	if err != nil {
 		return err
 	}
@@ -319,6 +425,119 @@ func (r *VMStorageConnectionReconciler) syncCluster(ctx context.Context, cluster
 	return r.Update(ctx, updated.object())
 }
 
+// checkStorageNode validates the secret of the storage node and probes it, recording
+// the outcome in the Degraded condition and the probe fields of the connection status.
+func (r *VMStorageConnectionReconciler) checkStorageNode(ctx context.Context, conn *kofv1beta1.VMStorageConnection, clusterNS string) {
+	condition := metav1.Condition{
+		Type:               kofv1beta1.StorageNodeDegradedCondition,
+		Status:             metav1.ConditionFalse,
+		Reason:             "Healthy",
+		Message:            "The storage node is valid and reachable",
+		ObservedGeneration: conn.Generation,
+	}
+
+	var probeErr error
+	if r.StorageNodeProbe != nil {
+		start := time.Now()
+		probeErr = r.StorageNodeProbe(ctx, NewStorageNodeProbe(conn))
+		conn.Status.LastProbeTime = metav1.NewTime(start)
+		if probeErr != nil {
+			log.FromContext(ctx).Info("Storage node is unreachable", "vmStorageConnection", conn.Name, "address", conn.Spec.TargetStorageNode.Address, "err", probeErr)
+			conn.Status.LastProbeError = probeErr.Error()
+			conn.Status.ConsecutiveFailures++
+			condition.Status = metav1.ConditionTrue
+			condition.Reason = "StorageNodeUnreachable"
+			condition.Message = probeErr.Error()
+		} else {
+			conn.Status.LastProbeError = ""
+			conn.Status.ConsecutiveFailures = 0
+		}
+	}
+
+	if err := unsupportedStorageNodeConfig(conn); err != nil {
+		record.LogEvent(ctx, "UnsupportedStorageNodeConfig", "Storage node is left out of the cluster", conn, err,
+			"vmStorageConnection", conn.Name,
+			"kind", conn.Spec.ClusterRef.Kind,
+		)
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "UnsupportedConfig"
+		condition.Message = err.Error()
+	} else if err := r.validateStorageNodeSecret(ctx, conn, clusterNS); err != nil {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "SecretInvalid"
+		condition.Message = goerrors.Join(err, probeErr).Error()
+	}
+
+	meta.SetStatusCondition(&conn.Status.Conditions, condition)
+}
+
+// validateStorageNodeSecret checks that the secret of the storage node exists
+// in the namespace of the cluster and has the configured keys.
+func (r *VMStorageConnectionReconciler) validateStorageNodeSecret(ctx context.Context, conn *kofv1beta1.VMStorageConnection, clusterNS string) error {
+	ref := conn.Spec.TargetStorageNode.Secret
+	if ref.Name == "" {
+		return nil
+	}
+
+	secret := new(corev1.Secret)
+	if err := r.Get(ctx, client.ObjectKey{Name: ref.Name, Namespace: clusterNS}, secret); err != nil {
+		if apierrors.IsNotFound(err) {
+			return fmt.Errorf("secret %s/%s not found", clusterNS, ref.Name)
+		}
+		return fmt.Errorf("failed to get secret %s/%s: %w", clusterNS, ref.Name, err)
+	}
+
+	for _, key := range []string{ref.UsernameKey, ref.PasswordKey} {
+		if _, ok := secret.Data[key]; key != "" && !ok {
+			return fmt.Errorf("secret %s/%s has no key %q", clusterNS, ref.Name, key)
+		}
+	}
+	return nil
+}
+
+// updateStatus writes the in-memory status of the connection to the API server.
+func (r *VMStorageConnectionReconciler) updateStatus(ctx context.Context, conn *kofv1beta1.VMStorageConnection) error {
+	conn.Status.ObservedGeneration = conn.Generation
+	if err := r.Status().Update(ctx, conn); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to update VMStorageConnection status: %w", err)
+	}
+	return nil
+}
+
+// storageNodeExcluded reports whether the storage node failed enough probes in a row to be left out of the cluster.
+func storageNodeExcluded(conn *kofv1beta1.VMStorageConnection) bool {
+	threshold := conn.Spec.ExcludeAfterFailures
+	return threshold > 0 && conn.Status.ConsecutiveFailures >= threshold
+}
+
+// setStorageNodeReadyCondition sets the Ready condition from the Degraded condition,
+// the exclusion of the storage node and the outcome of the cluster sync.
+func setStorageNodeReadyCondition(conn *kofv1beta1.VMStorageConnection, syncErr error) {
+	condition := metav1.Condition{
+		Type:               kofv1beta1.StorageNodeReadyCondition,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: conn.Generation,
+	}
+
+	degraded := meta.FindStatusCondition(conn.Status.Conditions, kofv1beta1.StorageNodeDegradedCondition)
+	switch {
+	case syncErr != nil:
+		condition.Reason = "ClusterSyncFailed"
+		condition.Message = syncErr.Error()
+	case storageNodeExcluded(conn):
+		condition.Reason = "Excluded"
+		condition.Message = fmt.Sprintf("Excluded from the %s after %d failed probes", conn.Spec.ClusterRef.Kind, conn.Status.ConsecutiveFailures)
+	case degraded != nil && degraded.Status == metav1.ConditionTrue:
+		condition.Reason = degraded.Reason
+		condition.Message = degraded.Message
+	default:
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "Ready"
+		condition.Message = fmt.Sprintf("Configured in the %s", conn.Spec.ClusterRef.Kind)
+	}
+	meta.SetStatusCondition(&conn.Status.Conditions, condition)
+}
+
 // fetchVTCluster returns the named VTCluster as a storageCluster, or (nil, nil) if not found.
 func (r *VMStorageConnectionReconciler) fetchVTCluster(ctx context.Context, name, namespace string) (storageCluster, error) {
 	obj := new(vmv1.VTCluster)
@@ -343,6 +562,18 @@ func (r *VMStorageConnectionReconciler) fetchVLCluster(ctx context.Context, name
 	return &vlClusterAdapter{obj}, nil
 }
 
+// fetchVMCluster returns the named VMCluster as a storageCluster, or (nil, nil) if not found.
+func (r *VMStorageConnectionReconciler) fetchVMCluster(ctx context.Context, name, namespace string) (storageCluster, error) {
+	obj := new(vmv1beta1.VMCluster)
+	if err := r.Get(ctx, client.ObjectKey{Name: name, Namespace: namespace}, obj); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &vmClusterAdapter{obj}, nil
+}
+
 // secretKeyPath returns the mount path for secretName/key inside the pod.
 // Returns "" when key is empty so the caller gets a positional placeholder.
 func secretKeyPath(secretName, key string) string {
@@ -18,15 +18,27 @@ package controller
 
 import (
 	"context"
+	"errors"
+	"net"
+	"net/http/httptest"
 	"os"
+	"reflect"
+	"testing"
+	"time"
 
 	vmv1 "github.com/VictoriaMetrics/operator/api/operator/v1"
+	vmv1beta1 "github.com/VictoriaMetrics/operator/api/operator/v1beta1"
 	kofv1beta1 "github.com/k0rdent/kof/kof-operator/api/v1beta1"
+	"github.com/k0rdent/kof/kof-operator/internal/controller/record"
 	"github.com/k0rdent/kof/kof-operator/internal/models/labels"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	k8sevents "k8s.io/client-go/tools/events"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
@@ -34,6 +46,7 @@ import (
 const (
 	vtClusterName = "vtcluster"
 	vlClusterName = "vlcluster"
+	vmClusterName = "vmcluster"
 	ns            = "default"
 	connName      = "conn"
 	addr          = "vtstorage.example.com:8400"
@@ -249,6 +262,163 @@ var _ = Describe("VMStorageConnection Controller", func() {
 		})
 	})
 
+	Describe("VMCluster", func() {
+		newVMClusterConn := func(name, address string) *kofv1beta1.VMStorageConnection {
+			c := newConnWithFinalizer(name, address)
+			c.Spec.ClusterRef.Name = vmClusterName
+			c.Spec.ClusterRef.Kind = "VMCluster"
+			c.Labels[labels.ClusterNameLabelKey] = vmClusterName
+			c.Labels[labels.ClusterKindLabelKey] = "VMCluster"
+			return c
+		}
+
+		It("builds only the vmselect storageNode args and keeps the vmselect secrets", func() {
+			Expect(k8sClient.Create(ctx, newVMClusterConn(connName, "regional-a-vmselect:8401"))).To(Succeed())
+			Expect(k8sClient.Create(ctx, newVMClusterConn("conn-b", "regional-b-vmselect:8401"))).To(Succeed())
+			// Connections with credentials or TLS are not supported by vmselect and are left out.
+			withSecret := newVMClusterConn("conn-secret", "regional-c-vmselect:8401")
+			withSecret.Spec.TargetStorageNode.Secret = kofv1beta1.SecretRef{Name: "auth-secret"}
+			Expect(k8sClient.Create(ctx, withSecret)).To(Succeed())
+			// Connections of the other kinds with the same cluster name are ignored.
+			Expect(k8sClient.Create(ctx, newConnWithFinalizer("conn-vt", addr))).To(Succeed())
+
+			vmc := &vmClusterAdapter{&vmv1beta1.VMCluster{
+				ObjectMeta: metav1.ObjectMeta{Name: vmClusterName, Namespace: ns},
+				Spec:       vmv1beta1.VMClusterSpec{VMSelect: &vmv1beta1.VMSelect{}},
+			}}
+			vmc.Spec.VMSelect.ExtraArgs = map[string]string{
+				storageNodeUsernameFileArg: "/etc/vm/secrets/stale/username",
+				"search.maxQueryDuration":  "1m",
+			}
+			vmc.Spec.VMSelect.Secrets = []string{"user-secret"}
+
+			r := newVMStorageConnectionReconciler()
+			args, secrets, err := r.buildStorageNodeConfig(ctx, vmClusterName, vmc.clusterKind(), vmc.storageExtraArgs(), nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(secrets).To(BeNil())
+			vmc.applyStorageConfig(args, secrets)
+
+			Expect(vmc.Spec.VMSelect.Secrets).To(ConsistOf("user-secret"))
+			Expect(vmc.Spec.VMSelect.ExtraArgs).To(Equal(map[string]string{
+				storageNodeArg:            "regional-a-vmselect:8401,regional-b-vmselect:8401",
+				"search.maxQueryDuration": "1m",
+			}))
+		})
+	})
+
+	Describe("status", func() {
+		getConn := func(name string) *kofv1beta1.VMStorageConnection {
+			got := &kofv1beta1.VMStorageConnection{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: name, Namespace: ns}, got)).To(Succeed())
+			return got
+		}
+
+		It("reports the missing secret key as Degraded", func() {
+			Expect(k8sClient.Create(ctx, &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "auth-secret", Namespace: ns},
+				Data:       map[string][]byte{"username": []byte("u")},
+			})).To(Succeed())
+			conn := newConnWithFinalizer(connName, addr)
+			conn.Spec.TargetStorageNode.Secret = kofv1beta1.SecretRef{
+				Name:        "auth-secret",
+				UsernameKey: "username",
+				PasswordKey: "password",
+			}
+			Expect(k8sClient.Create(ctx, conn)).To(Succeed())
+			Expect(k8sClient.Create(ctx, newVTCluster())).To(Succeed())
+
+			r := newVMStorageConnectionReconciler()
+			Expect(doReconcile(r, connName)).To(Succeed())
+
+			got := getConn(connName)
+			Expect(got.Status.Included).To(BeTrue())
+			degraded := meta.FindStatusCondition(got.Status.Conditions, kofv1beta1.StorageNodeDegradedCondition)
+			Expect(degraded).NotTo(BeNil())
+			Expect(degraded.Status).To(Equal(metav1.ConditionTrue))
+			Expect(degraded.Reason).To(Equal("SecretInvalid"))
+			Expect(degraded.Message).To(ContainSubstring(`has no key "password"`))
+			Expect(meta.IsStatusConditionFalse(got.Status.Conditions, kofv1beta1.StorageNodeReadyCondition)).To(BeTrue())
+		})
+
+		It("probes the storage node and requeues the next probe", func() {
+			Expect(k8sClient.Create(ctx, newConnWithFinalizer(connName, "vmauth.example.com/vls"))).To(Succeed())
+			Expect(k8sClient.Create(ctx, newVTCluster())).To(Succeed())
+
+			r := newVMStorageConnectionReconciler()
+			r.StorageNodeProbe = func(_ context.Context, probe StorageNodeProbe) error {
+				Expect(probe.Address).To(Equal("vmauth.example.com:80"))
+				Expect(probe.TLS).To(BeFalse())
+				return nil
+			}
+			result, err := r.Reconcile(ctx, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: connName, Namespace: ns},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(StorageNodeProbeInterval))
+
+			got := getConn(connName)
+			Expect(got.Status.Included).To(BeTrue())
+			Expect(got.Status.LastProbeTime.IsZero()).To(BeFalse())
+			Expect(got.Status.LastProbeError).To(BeEmpty())
+			Expect(meta.IsStatusConditionTrue(got.Status.Conditions, kofv1beta1.StorageNodeReadyCondition)).To(BeTrue())
+			Expect(meta.IsStatusConditionFalse(got.Status.Conditions, kofv1beta1.StorageNodeDegradedCondition)).To(BeTrue())
+		})
+
+		It("excludes the storage node after ExcludeAfterFailures failed probes", func() {
+			Expect(k8sClient.Create(ctx, newConnWithFinalizer("conn-a", "storage-a:8400"))).To(Succeed())
+			connB := newConnWithFinalizer("conn-b", "storage-b:8400")
+			connB.Spec.ExcludeAfterFailures = 2
+			Expect(k8sClient.Create(ctx, connB)).To(Succeed())
+			Expect(k8sClient.Create(ctx, newVTCluster())).To(Succeed())
+
+			r := newVMStorageConnectionReconciler()
+			r.StorageNodeProbe = func(_ context.Context, probe StorageNodeProbe) error {
+				if probe.Address == "storage-b:8400" {
+					return errors.New("connection refused")
+				}
+				return nil
+			}
+
+			Expect(doReconcile(r, "conn-b")).To(Succeed())
+			got := getConn("conn-b")
+			Expect(got.Status.ConsecutiveFailures).To(Equal(int32(1)))
+			Expect(got.Status.LastProbeError).To(Equal("connection refused"))
+			Expect(got.Status.Included).To(BeTrue())
+
+			Expect(doReconcile(r, "conn-b")).To(Succeed())
+			got = getConn("conn-b")
+			Expect(got.Status.ConsecutiveFailures).To(Equal(int32(2)))
+			Expect(got.Status.Included).To(BeFalse())
+			ready := meta.FindStatusCondition(got.Status.Conditions, kofv1beta1.StorageNodeReadyCondition)
+			Expect(ready).NotTo(BeNil())
+			Expect(ready.Reason).To(Equal("Excluded"))
+
+			vtc := &vmv1.VTCluster{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: vtClusterName, Namespace: ns}, vtc)).To(Succeed())
+			Expect(vtc.Spec.Select.ExtraArgs[storageNodeArg]).To(Equal("storage-a:8400"))
+		})
+	})
+
+	Describe("ProbeStorageNode", func() {
+		It("dials the storage node", func() {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+			defer func() { _ = listener.Close() }()
+
+			Expect(ProbeStorageNode(ctx, StorageNodeProbe{Address: listener.Addr().String(), Timeout: time.Second})).To(Succeed())
+		})
+
+		It("completes the TLS handshake", func() {
+			server := httptest.NewTLSServer(nil)
+			defer server.Close()
+
+			probe := StorageNodeProbe{Address: server.Listener.Addr().String(), TLS: true, Timeout: time.Second}
+			Expect(ProbeStorageNode(ctx, probe)).NotTo(Succeed())
+			probe.InsecureSkipVerify = true
+			Expect(ProbeStorageNode(ctx, probe)).To(Succeed())
+		})
+	})
+
 	It("removes the storage node from VTCluster and drops the finalizer", func() {
 		r := newVMStorageConnectionReconciler()
 
@@ -303,3 +473,78 @@ var _ = Describe("VMStorageConnection Controller", func() {
 		Expect(err.Error()).To(ContainSubstring("not found"))
 	})
 })
+
+func TestSyncVMClusterStorageNodes(t *testing.T) {
+	record.DefaultRecorder = new(k8sevents.FakeRecorder)
+
+	newConn := func(name, address string) *kofv1beta1.VMStorageConnection {
+		return &kofv1beta1.VMStorageConnection{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: migrationNamespace,
+				Labels: map[string]string{
+					labels.ClusterNameLabelKey: vmClusterName,
+					labels.ClusterKindLabelKey: "VMCluster",
+				},
+			},
+			Spec: kofv1beta1.VMStorageConnectionSpec{
+				ClusterRef:        kofv1beta1.ClusterRef{Kind: "VMCluster", Name: vmClusterName},
+				TargetStorageNode: kofv1beta1.TargetStorageNode{Address: address},
+			},
+		}
+	}
+	vmCluster := &vmv1beta1.VMCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: vmClusterName, Namespace: migrationNamespace},
+		Spec:       vmv1beta1.VMClusterSpec{VMSelect: &vmv1beta1.VMSelect{}},
+	}
+	vmCluster.Spec.VMSelect.ExtraArgs = map[string]string{
+		storageNodeTLSArg:         "true",
+		"search.maxQueryDuration": "1m",
+	}
+	vmCluster.Spec.VMSelect.Secrets = []string{"user-secret"}
+
+	withTLS := newConn("regional-tls", "regional-tls-vmselect:8401")
+	withTLS.Spec.TargetStorageNode.Secret = kofv1beta1.SecretRef{Name: "auth-secret", UsernameKey: "username", PasswordKey: "password"}
+	withTLS.Spec.TargetStorageNode.TLSConfig = kofv1beta1.TLSStorageConfig{Enabled: true}
+
+	ctx := context.Background()
+	k8sClient := newMigrationTestClient(t,
+		vmCluster,
+		newConn("regional-a", "regional-a-vmselect:8401"),
+		newConn("regional-b", "vmselect-cluster:8401"),
+		withTLS,
+	)
+	r := &VMStorageConnectionReconciler{Client: k8sClient, Scheme: k8sClient.Scheme()}
+
+	if err := r.syncCluster(ctx, &vmClusterAdapter{vmCluster}, nil); err != nil {
+		t.Fatalf("failed to sync VMCluster: %v", err)
+	}
+
+	got := &vmv1beta1.VMCluster{}
+	if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(vmCluster), got); err != nil {
+		t.Fatalf("failed to get VMCluster: %v", err)
+	}
+	expectedArgs := map[string]string{
+		storageNodeArg:            "regional-a-vmselect:8401,vmselect-cluster:8401",
+		"search.maxQueryDuration": "1m",
+	}
+	if !reflect.DeepEqual(got.Spec.VMSelect.ExtraArgs, expectedArgs) {
+		t.Fatalf("expected vmselect extra args %v, got %v", expectedArgs, got.Spec.VMSelect.ExtraArgs)
+	}
+	if !reflect.DeepEqual(got.Spec.VMSelect.Secrets, []string{"user-secret"}) {
+		t.Fatalf("expected vmselect secrets to be kept, got %v", got.Spec.VMSelect.Secrets)
+	}
+
+	// The storage node with credentials and TLS is reported instead of being configured without them.
+	r.checkStorageNode(ctx, withTLS, migrationNamespace)
+	setStorageNodeReadyCondition(withTLS, nil)
+	for _, conditionType := range []string{kofv1beta1.StorageNodeDegradedCondition, kofv1beta1.StorageNodeReadyCondition} {
+		condition := meta.FindStatusCondition(withTLS.Status.Conditions, conditionType)
+		if condition == nil || condition.Reason != "UnsupportedConfig" {
+			t.Fatalf("expected %s condition with reason UnsupportedConfig, got %+v", conditionType, condition)
+		}
+	}
+	if meta.IsStatusConditionTrue(withTLS.Status.Conditions, kofv1beta1.StorageNodeReadyCondition) {
+		t.Fatal("expected the storage node with credentials and TLS not to be ready")
+	}
+}
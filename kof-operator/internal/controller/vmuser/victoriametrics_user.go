@@ -49,6 +49,12 @@ type MCSConfig struct {
 	// DependsOn lists dependency identifiers for this MultiClusterService, such as other
 	// MultiClusterService names, and is used to influence dependency/reconciliation ordering.
 	DependsOn []string
+	// Name overrides the MultiClusterService name built from CreateOptions.Name,
+	// so the same VMUser can be propagated by more than one MultiClusterService.
+	Name string
+	// WithdrawServicesOnSelectorMismatch removes the propagated VMUser and Secret
+	// from the clusters that stop matching the ClusterSelector or when the MultiClusterService is deleted.
+	WithdrawServicesOnSelectorMismatch bool
 }
 
 type VMUserConfig struct {
@@ -140,6 +146,42 @@ func (m *Manager) Create(ctx context.Context, opts *CreateOptions) error {
 	return nil
 }
 
+// Propagate creates or updates only the propagation MultiClusterService of the existing VMUser resources.
+func (m *Manager) Propagate(ctx context.Context, opts *CreateOptions) error {
+	if opts.Name == "" {
+		return fmt.Errorf("name cannot be empty")
+	}
+	if opts.MCSConfig == nil {
+		return fmt.Errorf("MCSConfig cannot be empty")
+	}
+	return m.createOrUpdatePropagationMCS(ctx, opts)
+}
+
+// DeletePropagation removes the propagation MultiClusterService with the given name, keeping the VMUser resources.
+func (m *Manager) DeletePropagation(ctx context.Context, mcsName string) error {
+	if err := m.deleteResource(ctx, &kcmv1beta1.MultiClusterService{}, mcsName, ""); err != nil {
+		return fmt.Errorf("failed to delete VMUser propagation MultiClusterService %s: %w", mcsName, err)
+	}
+	return nil
+}
+
+// DeletePropagated removes the VMUser and Secret propagated by the MultiClusterService of the given VMUser.
+// The Manager must be created with the client of the cluster the resources were propagated to.
+func (m *Manager) DeletePropagated(ctx context.Context, name, namespace string) error {
+	if name == "" {
+		return fmt.Errorf("name cannot be empty")
+	}
+
+	if err := m.deleteResource(ctx, &vmv1beta1.VMUser{}, BuildVMUserName(name), namespace); err != nil {
+		return fmt.Errorf("failed to delete propagated VMUser %s: %w", BuildVMUserName(name), err)
+	}
+
+	if err := m.deleteResource(ctx, &corev1.Secret{}, BuildSecretName(name), namespace); err != nil {
+		return fmt.Errorf("failed to delete propagated secret for VMUser %s/%s: %w", BuildSecretName(name), namespace, err)
+	}
+	return nil
+}
+
 // Removes VMUser resources for the given VMUser.
 // Secret and VMUser resources may also be deleted automatically by garbage collection
 // when their owner reference is removed, if it was set.
@@ -224,18 +266,22 @@ func (m *Manager) createSecret(ctx context.Context, opts *CreateOptions) error {
 // CreateOrUpdateVMUser creates or updates the VMUser resource based on the provided options, including setting up target references with query arguments derived from VMUserConfig.
 func (m *Manager) createOrUpdatePropagationMCS(ctx context.Context, opts *CreateOptions) error {
 	log := log.FromContext(ctx)
-	log.Info("Creating or updating MultiClusterService for VMUser propagation", "name", BuildMCSName(opts.Name))
+	mcsName := opts.MCSConfig.Name
+	if mcsName == "" {
+		mcsName = BuildMCSName(opts.Name)
+	}
+	log.Info("Creating or updating MultiClusterService for VMUser propagation", "name", mcsName)
 
 	mcs := &kcmv1beta1.MultiClusterService{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: BuildMCSName(opts.Name),
+			Name: mcsName,
 		},
 	}
 
 	op, err := controllerutil.CreateOrUpdate(ctx, m.client, mcs, func() error {
 		mcs.Labels = getLabels(opts.ExtraLabels)
 		mcs.Spec = kcmv1beta1.MultiClusterServiceSpec{
-			KeepServicesOnSelectorMismatch: true,
+			KeepServicesOnSelectorMismatch: !opts.MCSConfig.WithdrawServicesOnSelectorMismatch,
 			ClusterSelector:                maybeVersionedClusterSelector(opts.MCSConfig.ClusterSelector),
 			DependsOn:                      opts.MCSConfig.DependsOn,
 			ServiceSpec: kcmv1beta1.ServiceSpec{
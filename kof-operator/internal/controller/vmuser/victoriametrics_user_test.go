@@ -604,3 +604,59 @@ var _ = Describe("VMUser Manager - Delete", func() {
 		Expect(fakeClient.Get(ctx, client.ObjectKey{Name: BuildSecretName(opts.Name), Namespace: k8s.KofNamespace}, kofSecret)).To(HaveOccurred())
 	})
 })
+
+var _ = Describe("VMUser Manager - Propagate", func() {
+	var (
+		ctx        context.Context
+		fakeClient client.Client
+		manager    *Manager
+	)
+
+	BeforeEach(func() {
+		fakeClient = fake.NewClientBuilder().
+			WithScheme(newTestScheme()).
+			Build()
+		manager = NewManager(fakeClient)
+		ctx = context.Background()
+	})
+
+	It("returns an error when MCSConfig is empty", func() {
+		err := manager.Propagate(ctx, &CreateOptions{Name: "my-cluster", Namespace: "test-ns"})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("MCSConfig cannot be empty"))
+	})
+
+	It("propagates the same VMUser with a named MCS and deletes only that MCS", func() {
+		opts := &CreateOptions{
+			Name:      "my-cluster",
+			Namespace: "test-ns",
+			MCSConfig: &MCSConfig{
+				ClusterSelector: metav1.LabelSelector{
+					MatchLabels: map[string]string{"role": "regional"},
+				},
+			},
+		}
+		Expect(manager.Create(ctx, opts)).To(Succeed())
+
+		opts.MCSConfig = &MCSConfig{
+			ClusterSelector: metav1.LabelSelector{
+				MatchLabels: map[string]string{"role": "previous-regional"},
+			},
+			Name:                               "previous-regional-propagation",
+			WithdrawServicesOnSelectorMismatch: true,
+		}
+		Expect(manager.Propagate(ctx, opts)).To(Succeed())
+
+		mcs := &kcmv1beta1.MultiClusterService{}
+		Expect(fakeClient.Get(ctx, client.ObjectKey{Name: "previous-regional-propagation"}, mcs)).To(Succeed())
+		Expect(mcs.Spec.KeepServicesOnSelectorMismatch).To(BeFalse())
+		Expect(mcs.Spec.ServiceSpec.TemplateResourceRefs[0].Resource.Name).To(Equal(BuildVMUserName(opts.Name)))
+
+		Expect(manager.DeletePropagation(ctx, "previous-regional-propagation")).To(Succeed())
+		Expect(fakeClient.Get(ctx, client.ObjectKey{Name: "previous-regional-propagation"}, mcs)).NotTo(Succeed())
+		Expect(fakeClient.Get(ctx, client.ObjectKey{Name: BuildMCSName(opts.Name)}, mcs)).To(Succeed())
+
+		vmUser := &vmv1beta1.VMUser{}
+		Expect(fakeClient.Get(ctx, client.ObjectKey{Name: BuildVMUserName(opts.Name), Namespace: opts.Namespace}, vmUser)).To(Succeed())
+	})
+})
@@ -89,6 +89,12 @@ func GetVLClusterName() string {
 	return os.Getenv("KOF_VL_CLUSTER_NAME")
 }
 
+// GetVMClusterName returns the name of the VMCluster to register regional storage nodes with.
+// Returns "" when not configured, in which case VMStorageConnection creation is skipped.
+func GetVMClusterName() string {
+	return os.Getenv("KOF_VM_CLUSTER_NAME")
+}
+
 // GetVLSelectURL returns the URL of the VictoriaLogs select service.
 func GetVLSelectURL() string {
 	return os.Getenv("KOF_VL_SELECT_URL")
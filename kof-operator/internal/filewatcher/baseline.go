@@ -2,15 +2,20 @@ package filewatcher
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"hash/fnv"
 	"io"
 	"io/fs"
 	"log"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
@@ -26,16 +31,42 @@ type BaselineStore interface {
 	Save(ctx context.Context, hashes map[string]string) error
 }
 
+// SnapshotStore persists file snapshots next to the hash baseline, so that
+// drift found on startup can be attributed and diffed as well. A BaselineStore
+// may optionally implement it.
+type SnapshotStore interface {
+	LoadSnapshots(ctx context.Context) (map[string]*FileSnapshot, error)
+	SaveSnapshots(ctx context.Context, snapshots map[string]*FileSnapshot) error
+}
+
+const (
+	// snapshotKeyPrefix prefixes the Secret data keys holding snapshots. Hash
+	// entries are bare hex-encoded paths, so the two never collide.
+	snapshotKeyPrefix = "snapshot."
+
+	// maxSnapshotBytes bounds the encoded size of the snapshots kept in the
+	// Secret, which must stay below the 1MiB Secret size limit together with
+	// the hashes. Each snapshot counts as its data key plus its base64-encoded
+	// JSON value, the way the Secret is serialized. Snapshots beyond the
+	// budget are stored without content, or not at all when even that does
+	// not fit.
+	maxSnapshotBytes = 512 << 10
+)
+
 // secretBaselineStore stores file hashes in a Kubernetes Secret.
 // Each file path is stored as its own data entry (key = hex-encoded path bytes,
-// value = FNV-64a hex digest of the file content).
+// value = FNV-64a hex digest of the file content). Snapshots are stored in the
+// same Secret under snapshotKeyPrefix + hex-encoded path as JSON.
 type secretBaselineStore struct {
 	client     client.Client
 	secretName string
 	namespace  string
 }
 
+var _ SnapshotStore = (*secretBaselineStore)(nil)
+
 // NewSecretBaselineStore returns a BaselineStore backed by a Kubernetes Secret.
+// The returned store also implements SnapshotStore.
 func NewSecretBaselineStore(c client.Client, secretName, namespace string) BaselineStore {
 	return &secretBaselineStore{client: c, secretName: secretName, namespace: namespace}
 }
@@ -43,17 +74,9 @@ func NewSecretBaselineStore(c client.Client, secretName, namespace string) Basel
 // Load reads the stored hashes from the Secret. Returns an empty map when the
 // Secret does not yet exist.
 func (s *secretBaselineStore) Load(ctx context.Context) (map[string]string, error) {
-	secret := &corev1.Secret{}
-	namespacedName := types.NamespacedName{
-		Name:      s.secretName,
-		Namespace: s.namespace,
-	}
-
-	if err := s.client.Get(ctx, namespacedName, secret); err != nil {
-		if apierrors.IsNotFound(err) {
-			return map[string]string{}, nil
-		}
-		return nil, fmt.Errorf("get baseline secret %s/%s: %w", s.namespace, s.secretName, err)
+	secret, err := s.get(ctx)
+	if err != nil {
+		return nil, err
 	}
 
 	hashes := make(map[string]string, len(secret.Data))
@@ -69,12 +92,95 @@ func (s *secretBaselineStore) Load(ctx context.Context) (map[string]string, erro
 
 // Save persists hashes to the Secret, creating it if it does not exist.
 // Each file path is encoded as a hex string to form a valid k8s data key.
+// Stored snapshots are kept.
 func (s *secretBaselineStore) Save(ctx context.Context, hashes map[string]string) error {
 	data := make(map[string][]byte, len(hashes))
 	for path, hash := range hashes {
 		data[hex.EncodeToString([]byte(path))] = []byte(hash)
 	}
+	return s.update(ctx, func(key string) bool { return !strings.HasPrefix(key, snapshotKeyPrefix) }, data)
+}
+
+// LoadSnapshots reads the stored snapshots from the Secret. Returns an empty
+// map when the Secret does not yet exist.
+func (s *secretBaselineStore) LoadSnapshots(ctx context.Context) (map[string]*FileSnapshot, error) {
+	secret, err := s.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := make(map[string]*FileSnapshot)
+	for key, val := range secret.Data {
+		encoded, ok := strings.CutPrefix(key, snapshotKeyPrefix)
+		if !ok {
+			continue
+		}
+		pathBytes, decErr := hex.DecodeString(encoded)
+		if decErr != nil {
+			continue
+		}
+		snapshot := &FileSnapshot{}
+		if err := json.Unmarshal(val, snapshot); err != nil {
+			continue // skip entries written by an incompatible version
+		}
+		snapshots[string(pathBytes)] = snapshot
+	}
+	return snapshots, nil
+}
+
+// SaveSnapshots persists snapshots to the Secret, replacing the stored ones and
+// keeping the hashes. Snapshots beyond maxSnapshotBytes lose their content, in
+// path order, or are dropped, so that the Secret stays within its size limit.
+func (s *secretBaselineStore) SaveSnapshots(ctx context.Context, snapshots map[string]*FileSnapshot) error {
+	data := make(map[string][]byte, len(snapshots))
+	budget := maxSnapshotBytes
+	for _, path := range slices.Sorted(maps.Keys(snapshots)) {
+		key := snapshotKeyPrefix + hex.EncodeToString([]byte(path))
+		snapshot := *snapshots[path]
+		val, err := json.Marshal(&snapshot)
+		if err != nil {
+			return fmt.Errorf("marshal snapshot of %s: %w", path, err)
+		}
+		if encodedSize(key, val) > budget && snapshot.Content != nil {
+			snapshot.Text = false
+			snapshot.Content = nil
+			if val, err = json.Marshal(&snapshot); err != nil {
+				return fmt.Errorf("marshal snapshot of %s: %w", path, err)
+			}
+		}
+		if size := encodedSize(key, val); size <= budget {
+			budget -= size
+			data[key] = val
+		}
+	}
+	return s.update(ctx, func(key string) bool { return strings.HasPrefix(key, snapshotKeyPrefix) }, data)
+}
+
+// encodedSize is the size of a Secret data entry as serialized by the API server.
+func encodedSize(key string, val []byte) int {
+	return len(key) + base64.StdEncoding.EncodedLen(len(val))
+}
+
+// get returns the baseline Secret, or an empty Secret when it does not yet exist.
+func (s *secretBaselineStore) get(ctx context.Context) (*corev1.Secret, error) {
+	secret := &corev1.Secret{}
+	namespacedName := types.NamespacedName{
+		Name:      s.secretName,
+		Namespace: s.namespace,
+	}
 
+	if err := s.client.Get(ctx, namespacedName, secret); err != nil {
+		if apierrors.IsNotFound(err) {
+			return &corev1.Secret{}, nil
+		}
+		return nil, fmt.Errorf("get baseline secret %s/%s: %w", s.namespace, s.secretName, err)
+	}
+	return secret, nil
+}
+
+// update replaces the Secret data entries whose key matches owned with data,
+// creating the Secret if it does not exist.
+func (s *secretBaselineStore) update(ctx context.Context, owned func(key string) bool, data map[string][]byte) error {
 	secret := &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      s.secretName,
@@ -83,7 +189,11 @@ func (s *secretBaselineStore) Save(ctx context.Context, hashes map[string]string
 	}
 
 	if _, err := controllerutil.CreateOrUpdate(ctx, s.client, secret, func() error {
-		secret.Data = data
+		maps.DeleteFunc(secret.Data, func(key string, _ []byte) bool { return owned(key) })
+		if secret.Data == nil {
+			secret.Data = make(map[string][]byte, len(data))
+		}
+		maps.Copy(secret.Data, data)
 		return nil
 	}); err != nil {
 		return fmt.Errorf("create or update baseline secret: %w", err)
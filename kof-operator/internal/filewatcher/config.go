@@ -52,6 +52,13 @@ type Config struct {
 	// BaselineEnabled controls whether baseline persistence is enabled.
 	// Set via --baseline-enabled (default: false).
 	BaselineEnabled bool
+
+	// DiffMaxBytes is the maximum size of a text file whose content is kept
+	// in memory (and in the baseline Secret) to log a unified diff when it
+	// changes. 0 disables content snapshots; hashes and attributes are still
+	// recorded. Diffs are opt-in, as watched paths may hold credentials.
+	// Set via --diff-max-bytes (default: 0).
+	DiffMaxBytes int64
 }
 
 // ParseFlags registers CLI flags on flag.CommandLine, calls flag.Parse(),
@@ -71,6 +78,7 @@ func parseFrom(fs *flag.FlagSet, args []string) (*Config, error) {
 	var baselineSecretName string
 	var baselineNamespace string
 	var baselineEnabled bool
+	var diffMaxBytes int64
 
 	fs.Var(&paths, "watch-path", "Filesystem `path` to watch (repeatable, at least one required).")
 	fs.BoolVar(&recursive, "recursive", true, "Watch subdirectories recursively.")
@@ -78,6 +86,7 @@ func parseFrom(fs *flag.FlagSet, args []string) (*Config, error) {
 	fs.StringVar(&debounceStr, "debounce", "100ms", "Minimum `duration` between two events on the same path (Go duration string).")
 	fs.BoolVar(&baselineEnabled, "baseline-enabled", false, "Enable baseline persistence.")
 	fs.StringVar(&baselineSecretName, "baseline-secret-name", "file-watcher-baseline", "Name of the baseline Secret.")
+	fs.Int64Var(&diffMaxBytes, "diff-max-bytes", 0, "Maximum size in `bytes` of a text file whose content is kept to log a unified diff on change (0 disables diffs).")
 	fs.StringVar(&baselineNamespace, "baseline-namespace", "kof", "Namespace for the baseline Secret (defaults to $POD_NAMESPACE; empty disables baseline persistence).")
 
 	if err := fs.Parse(args); err != nil {
@@ -93,6 +102,10 @@ func parseFrom(fs *flag.FlagSet, args []string) (*Config, error) {
 		return nil, fmt.Errorf("invalid --debounce %q: %w", debounceStr, err)
 	}
 
+	if diffMaxBytes < 0 {
+		return nil, fmt.Errorf("invalid --diff-max-bytes %d: must not be negative", diffMaxBytes)
+	}
+
 	return &Config{
 		WatchPaths:              []string(paths),
 		Recursive:               recursive,
@@ -101,5 +114,6 @@ func parseFrom(fs *flag.FlagSet, args []string) (*Config, error) {
 		BaselineSecretNamespace: baselineNamespace,
 		BaselineSecretName:      baselineSecretName,
 		BaselineEnabled:         baselineEnabled,
+		DiffMaxBytes:            diffMaxBytes,
 	}, nil
 }
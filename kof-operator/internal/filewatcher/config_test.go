@@ -38,6 +38,7 @@ var _ = Describe("parseFrom", func() {
 		Expect(cfg.Recursive).To(BeTrue())
 		Expect(cfg.MetricsAddr).To(Equal(":9090"))
 		Expect(cfg.DebounceDuration).To(Equal(100 * time.Millisecond))
+		Expect(cfg.DiffMaxBytes).To(BeZero())
 	})
 
 	It("returns an error when no --watch-path is supplied", func() {
@@ -53,6 +54,14 @@ var _ = Describe("parseFrom", func() {
 		Expect(err).To(MatchError(ContainSubstring("invalid --debounce")))
 	})
 
+	It("returns an error for a negative --diff-max-bytes value", func() {
+		_, err := parseFrom(newFS(), []string{
+			"--watch-path", "/tmp/x",
+			"--diff-max-bytes", "-1",
+		})
+		Expect(err).To(MatchError(ContainSubstring("invalid --diff-max-bytes")))
+	})
+
 	It("returns an error for an unknown flag", func() {
 		_, err := parseFrom(newFS(), []string{"--unknown-flag", "value"})
 		Expect(err).To(HaveOccurred())
@@ -5,8 +5,9 @@ import "github.com/prometheus/client_golang/prometheus"
 const (
 	metricsNamespace = "file_watcher"
 
-	deletedEvent  = "deleted"
-	modifiedEvent = "modified"
+	deletedEvent    = "deleted"
+	modifiedEvent   = "modified"
+	attributesEvent = "attributes"
 )
 
 type fileWatcherMetrics struct {
@@ -20,7 +21,7 @@ func newMetrics(reg prometheus.Registerer) *fileWatcherMetrics {
 			prometheus.GaugeOpts{
 				Namespace: metricsNamespace,
 				Name:      "drift_detected",
-				Help:      "1 if the file at the given path has drifted from baseline (modified or deleted), 0 otherwise.",
+				Help:      "1 if the file at the given path has drifted from baseline (modified, deleted or attributes changed), 0 otherwise.",
 			},
 			[]string{"path", "event"},
 		),
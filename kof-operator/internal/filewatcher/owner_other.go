@@ -0,0 +1,10 @@
+//go:build !unix
+
+package filewatcher
+
+import "io/fs"
+
+// fileOwner returns -1 for both IDs: file ownership is not tracked on this platform.
+func fileOwner(fs.FileInfo) (uid, gid int) {
+	return -1, -1
+}
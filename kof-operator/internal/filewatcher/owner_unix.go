@@ -0,0 +1,16 @@
+//go:build unix
+
+package filewatcher
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// fileOwner returns the owning user and group IDs of a file, or -1 when unknown.
+func fileOwner(info fs.FileInfo) (uid, gid int) {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return int(st.Uid), int(st.Gid)
+	}
+	return -1, -1
+}
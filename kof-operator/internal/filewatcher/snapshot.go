@@ -0,0 +1,225 @@
+package filewatcher
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// Attributes of a file reported in the "changes" field of a file event.
+const (
+	changeContent = "content"
+	changeMode    = "mode"
+	changeOwner   = "owner"
+	changeMtime   = "mtime"
+)
+
+// FileSnapshot is the recorded state of a watched regular file. Comparing two
+// snapshots attributes a change to the content, ownership, mode or mtime of
+// the file; for small text files it also carries the content a unified diff
+// is computed from.
+type FileSnapshot struct {
+	SHA256  string      `json:"sha256"`
+	Size    int64       `json:"size"`
+	Mode    fs.FileMode `json:"mode"`
+	UID     int         `json:"uid"`
+	GID     int         `json:"gid"`
+	ModTime time.Time   `json:"mtime"`
+
+	// Text is true when Content holds the full content of the file, i.e. the
+	// file is valid UTF-8 without NUL bytes and no larger than
+	// Config.DiffMaxBytes.
+	Text    bool   `json:"text,omitempty"`
+	Content []byte `json:"content,omitempty"`
+}
+
+// takeSnapshot records the state of the regular file at path. The content is
+// kept when the file is text of at most maxContent bytes; maxContent <= 0
+// disables content snapshots.
+func takeSnapshot(path string, maxContent int64) (*FileSnapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+
+	defer func() {
+		if err := f.Close(); err != nil {
+			log.Printf("warning: failed to close file %s: %v", path, err)
+		}
+	}()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("stat %s: %w", path, err)
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s is not a regular file", path)
+	}
+
+	h := sha256.New()
+	var content bytes.Buffer
+	keep := maxContent > 0 && info.Size() <= maxContent
+	if keep {
+		// The file may grow while it is read; read one byte past the limit
+		// to notice it and hash the rest without keeping it.
+		if _, err := io.Copy(io.MultiWriter(h, &content), io.LimitReader(f, maxContent+1)); err != nil {
+			return nil, fmt.Errorf("read %s: %w", path, err)
+		}
+	}
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return nil, fmt.Errorf("hash %s: %w", path, err)
+	}
+
+	s := &FileSnapshot{
+		SHA256:  hex.EncodeToString(h.Sum(nil)),
+		Size:    int64(content.Len()) + n,
+		Mode:    info.Mode(),
+		ModTime: info.ModTime().UTC(),
+	}
+	s.UID, s.GID = fileOwner(info)
+	if keep && s.Size <= maxContent && isText(content.Bytes()) {
+		s.Text = true
+		s.Content = content.Bytes()
+	}
+	return s, nil
+}
+
+// isText reports whether b looks like the content of a text file.
+func isText(b []byte) bool {
+	return !bytes.ContainsRune(b, 0) && utf8.Valid(b)
+}
+
+// snapshotTree records snapshots of all regular files reachable from root in
+// out (keyed by path). When root is a file itself, only that file is
+// recorded; a missing root records nothing.
+func snapshotTree(root string, maxContent int64, out map[string]*FileSnapshot) error {
+	info, err := os.Stat(root)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("stat %s: %w", root, err)
+	}
+
+	if !info.IsDir() {
+		s, err := takeSnapshot(root, maxContent)
+		if err != nil {
+			return err
+		}
+		out[root] = s
+		return nil
+	}
+
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return nil
+		}
+		s, snapErr := takeSnapshot(path, maxContent)
+		if snapErr != nil {
+			return nil
+		}
+		out[path] = s
+		return nil
+	})
+}
+
+// changedAttributes lists the attributes that differ between two snapshots of
+// a file, in a stable order.
+func changedAttributes(before, after *FileSnapshot) []string {
+	var changes []string
+	if before.SHA256 != after.SHA256 {
+		changes = append(changes, changeContent)
+	}
+	if before.Mode != after.Mode {
+		changes = append(changes, changeMode)
+	}
+	if before.UID != after.UID || before.GID != after.GID {
+		changes = append(changes, changeOwner)
+	}
+	if !before.ModTime.Equal(after.ModTime) {
+		changes = append(changes, changeMtime)
+	}
+	return changes
+}
+
+// changeLogValues returns the structured log key/value pairs attributing the
+// change of the file at path from before to after. Either snapshot is nil when
+// unknown, e.g. after is nil for a deleted file. The unified diff is included
+// when the content of both snapshots is known.
+func changeLogValues(path string, before, after *FileSnapshot) []any {
+	var kv []any
+	if before != nil {
+		kv = append(kv,
+			"sha256_before", before.SHA256,
+			"mode_before", before.Mode.String(),
+			"uid_before", before.UID,
+			"gid_before", before.GID,
+			"mtime_before", before.ModTime.Format(time.RFC3339Nano),
+		)
+	}
+	if after != nil {
+		kv = append(kv,
+			"sha256_after", after.SHA256,
+			"mode_after", after.Mode.String(),
+			"uid_after", after.UID,
+			"gid_after", after.GID,
+			"mtime_after", after.ModTime.Format(time.RFC3339Nano),
+		)
+	}
+	if before == nil || after == nil {
+		return kv
+	}
+
+	kv = append(kv, "changes", changedAttributes(before, after))
+	if before.SHA256 != after.SHA256 && before.Text && after.Text {
+		diff, err := unifiedDiff(path, before, after)
+		if err != nil {
+			log.Printf("warning: failed to diff %s: %v", path, err)
+		} else {
+			kv = append(kv, "diff", diff)
+		}
+	}
+	return kv
+}
+
+// unifiedDiff returns the unified diff between the content of two text snapshots.
+func unifiedDiff(path string, before, after *FileSnapshot) (string, error) {
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(before.Content),
+		B:        splitLines(after.Content),
+		FromFile: "a" + path,
+		ToFile:   "b" + path,
+		FromDate: before.ModTime.Format(time.RFC3339Nano),
+		ToDate:   after.ModTime.Format(time.RFC3339Nano),
+		Context:  3,
+	})
+}
+
+// splitLines splits content into newline-terminated lines. A missing final
+// newline is added so that the diff lines stay separated.
+func splitLines(content []byte) []string {
+	if len(content) == 0 {
+		return nil
+	}
+	lines := strings.SplitAfter(string(content), "\n")
+	if last := len(lines) - 1; lines[last] == "" {
+		lines = lines[:last]
+	} else {
+		lines[last] += "\n"
+	}
+	return lines
+}
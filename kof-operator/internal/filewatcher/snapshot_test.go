@@ -0,0 +1,308 @@
+package filewatcher
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// captureLog returns a logger recording each log line as a flat key/value map.
+func captureLog() (logr.Logger, *[]map[string]any) {
+	var lines []map[string]any
+	log := funcr.NewJSON(func(obj string) {
+		var line map[string]any
+		Expect(json.Unmarshal([]byte(obj), &line)).To(Succeed())
+		lines = append(lines, line)
+	}, funcr.Options{})
+	return log, &lines
+}
+
+// findLine returns the first captured line with the given message.
+func findLine(lines []map[string]any, msg string) map[string]any {
+	for _, line := range lines {
+		if line["msg"] == msg {
+			return line
+		}
+	}
+	return nil
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+var _ = Describe("takeSnapshot", func() {
+	var dir string
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+	})
+
+	It("keeps the content of small text files", func() {
+		path := filepath.Join(dir, "policy.yaml")
+		Expect(os.WriteFile(path, []byte("rules: []\n"), 0o640)).To(Succeed())
+
+		s, err := takeSnapshot(path, 1024)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(s.SHA256).To(Equal(sha256Hex("rules: []\n")))
+		Expect(s.Size).To(BeEquivalentTo(10))
+		Expect(s.Mode.Perm()).To(Equal(os.FileMode(0o640)))
+		Expect(s.UID).To(Equal(os.Getuid()))
+		Expect(s.Text).To(BeTrue())
+		Expect(string(s.Content)).To(Equal("rules: []\n"))
+	})
+
+	DescribeTable("hashes without keeping the content",
+		func(content string, maxContent int64) {
+			path := filepath.Join(dir, "file")
+			Expect(os.WriteFile(path, []byte(content), 0o600)).To(Succeed())
+
+			s, err := takeSnapshot(path, maxContent)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(s.SHA256).To(Equal(sha256Hex(content)))
+			Expect(s.Size).To(BeEquivalentTo(len(content)))
+			Expect(s.Text).To(BeFalse())
+			Expect(s.Content).To(BeNil())
+		},
+		Entry("binary file", "\x00\x01\x02", int64(1024)),
+		Entry("invalid UTF-8", "\xff\xfe", int64(1024)),
+		Entry("file larger than the limit", strings.Repeat("a", 2048), int64(1024)),
+		Entry("content snapshots disabled", "rules: []\n", int64(0)),
+	)
+
+	It("rejects directories", func() {
+		_, err := takeSnapshot(dir, 1024)
+		Expect(err).To(MatchError(ContainSubstring("not a regular file")))
+	})
+})
+
+var _ = Describe("changeLogValues", func() {
+	mtime := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	It("attributes changes and includes a unified diff of text files", func() {
+		before := &FileSnapshot{SHA256: "aaa", Mode: 0o644, UID: 0, GID: 0, ModTime: mtime, Text: true,
+			Content: []byte("a: 1\nb: 2\nc: 3\n")}
+		after := &FileSnapshot{SHA256: "bbb", Mode: 0o600, UID: 1000, GID: 0, ModTime: mtime.Add(time.Minute), Text: true,
+			Content: []byte("a: 1\nb: 20\nc: 3\n")}
+
+		kv := changeLogValues("/etc/policy.yaml", before, after)
+		values := map[any]any{}
+		for i := 0; i < len(kv); i += 2 {
+			values[kv[i]] = kv[i+1]
+		}
+		Expect(values).To(HaveKeyWithValue("sha256_before", "aaa"))
+		Expect(values).To(HaveKeyWithValue("sha256_after", "bbb"))
+		Expect(values).To(HaveKeyWithValue("mode_before", "-rw-r--r--"))
+		Expect(values).To(HaveKeyWithValue("mode_after", "-rw-------"))
+		Expect(values).To(HaveKeyWithValue("uid_after", 1000))
+		Expect(values).To(HaveKeyWithValue("changes", []string{changeContent, changeMode, changeOwner, changeMtime}))
+		Expect(values["diff"]).To(Equal("--- a/etc/policy.yaml\t2025-01-01T00:00:00Z\n" +
+			"+++ b/etc/policy.yaml\t2025-01-01T00:01:00Z\n" +
+			"@@ -1,3 +1,3 @@\n" +
+			" a: 1\n" +
+			"-b: 2\n" +
+			"+b: 20\n" +
+			" c: 3\n"))
+	})
+
+	It("omits the diff when the content is unknown", func() {
+		before := &FileSnapshot{SHA256: "aaa", ModTime: mtime}
+		after := &FileSnapshot{SHA256: "bbb", ModTime: mtime, Text: true, Content: []byte("x\n")}
+		Expect(changeLogValues("/f", before, after)).NotTo(ContainElement("diff"))
+		Expect(changeLogValues("/f", before, nil)).To(Equal([]any{
+			"sha256_before", "aaa",
+			"mode_before", "----------",
+			"uid_before", 0,
+			"gid_before", 0,
+			"mtime_before", "2025-01-01T00:00:00Z",
+		}))
+	})
+})
+
+var _ = Describe("Watcher change attribution", func() {
+	var (
+		w     *Watcher
+		dir   string
+		path  string
+		lines *[]map[string]any
+	)
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+		path = filepath.Join(dir, "audit-policy.yaml")
+		Expect(os.WriteFile(path, []byte("level: Metadata\n"), 0o644)).To(Succeed())
+
+		w, _ = newTestWatcher(&Config{
+			WatchPaths:   []string{dir},
+			DiffMaxBytes: 1024,
+		})
+		w.log, lines = captureLog()
+		Expect(w.initBaseline(context.Background())).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(w.fw.Close()).To(Succeed())
+	})
+
+	It("logs the hashes and a diff of a modified file", func() {
+		Expect(os.WriteFile(path, []byte("level: None\n"), 0o644)).To(Succeed())
+		w.handleEvent(fsnotify.Event{Name: path, Op: fsnotify.Write})
+
+		line := findLine(*lines, "file event detected")
+		Expect(line).NotTo(BeNil())
+		Expect(line).To(HaveKeyWithValue("event", "modified"))
+		Expect(line).To(HaveKeyWithValue("sha256_before", sha256Hex("level: Metadata\n")))
+		Expect(line).To(HaveKeyWithValue("sha256_after", sha256Hex("level: None\n")))
+		Expect(line["diff"]).To(ContainSubstring("-level: Metadata\n+level: None\n"))
+		Expect(w.snapshots[path].SHA256).To(Equal(sha256Hex("level: None\n")))
+	})
+
+	It("diffs a file replaced by rename against its previous content", func() {
+		tmp := filepath.Join(dir, ".audit-policy.yaml.tmp")
+		Expect(os.WriteFile(tmp, []byte("level: Request\n"), 0o644)).To(Succeed())
+		Expect(os.Remove(path)).To(Succeed())
+		w.handleEvent(fsnotify.Event{Name: path, Op: fsnotify.Remove})
+		Expect(os.Rename(tmp, path)).To(Succeed())
+		w.handleEvent(fsnotify.Event{Name: path, Op: fsnotify.Create})
+
+		Expect(*lines).To(HaveLen(2))
+		Expect((*lines)[0]).To(HaveKeyWithValue("event", "deleted"))
+		Expect((*lines)[0]).NotTo(HaveKey("sha256_after"))
+		Expect((*lines)[1]).To(HaveKeyWithValue("event", "modified"))
+		Expect((*lines)[1]["diff"]).To(ContainSubstring("+level: Request\n"))
+	})
+
+	It("reports mode changes raised as Chmod", func() {
+		Expect(os.Chmod(path, 0o666)).To(Succeed())
+		w.handleEvent(fsnotify.Event{Name: path, Op: fsnotify.Chmod})
+
+		line := findLine(*lines, "file event detected")
+		Expect(line).To(HaveKeyWithValue("event", "attributes"))
+		Expect(line).To(HaveKeyWithValue("changes", ContainElement("mode")))
+		Expect(line).To(HaveKeyWithValue("mode_after", "-rw-rw-rw-"))
+		Expect(line).NotTo(HaveKey("diff"))
+		Expect(testutil.ToFloat64(w.metrics.driftDetected.WithLabelValues(path, "attributes"))).To(Equal(1.0))
+	})
+
+	It("ignores Chmod events that change nothing", func() {
+		w.handleEvent(fsnotify.Event{Name: path, Op: fsnotify.Chmod})
+		Expect(*lines).To(BeEmpty())
+	})
+})
+
+var _ = Describe("Secret baseline snapshots", func() {
+	var (
+		ctx   context.Context
+		dir   string
+		path  string
+		store BaselineStore
+	)
+
+	newWatcher := func() (*Watcher, *[]map[string]any) {
+		w, _ := newTestWatcher(&Config{WatchPaths: []string{dir}, DiffMaxBytes: 1024})
+		DeferCleanup(w.fw.Close)
+		w.WithBaselineStore(store)
+		var lines *[]map[string]any
+		w.log, lines = captureLog()
+		return w, lines
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		dir = GinkgoT().TempDir()
+		path = filepath.Join(dir, "audit-policy.yaml")
+		Expect(os.WriteFile(path, []byte("level: Metadata\n"), 0o644)).To(Succeed())
+		store = NewSecretBaselineStore(fake.NewClientBuilder().Build(), "file-watcher-baseline", "kof")
+	})
+
+	It("stores snapshots next to the hashes and diffs drift found on startup", func() {
+		w, _ := newWatcher()
+		Expect(w.initBaseline(ctx)).To(Succeed())
+
+		hashes, err := store.Load(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(hashes).To(HaveKey(path))
+		snapshots, err := store.(SnapshotStore).LoadSnapshots(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(snapshots).To(HaveKey(path))
+		Expect(string(snapshots[path].Content)).To(Equal("level: Metadata\n"))
+
+		Expect(os.WriteFile(path, []byte("level: None\n"), 0o644)).To(Succeed())
+		for range 2 {
+			w, lines := newWatcher()
+			Expect(w.initBaseline(ctx)).To(Succeed())
+
+			line := findLine(*lines, "baseline: file differs from original, emitting modified event")
+			Expect(line).NotTo(BeNil())
+			Expect(line["diff"]).To(ContainSubstring("-level: Metadata\n+level: None\n"))
+		}
+
+		// The drifted file keeps its original snapshot and hash.
+		snapshots, err = store.(SnapshotStore).LoadSnapshots(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(snapshots[path].Content)).To(Equal("level: Metadata\n"))
+		hashes, err = store.Load(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(hashes).To(HaveKey(path))
+	})
+
+	It("reports attribute drift of files with an unchanged hash", func() {
+		w, _ := newWatcher()
+		Expect(w.initBaseline(ctx)).To(Succeed())
+
+		Expect(os.Chmod(path, 0o600)).To(Succeed())
+		w, lines := newWatcher()
+		Expect(w.initBaseline(ctx)).To(Succeed())
+
+		line := findLine(*lines, "baseline: file attributes differ from original, emitting attributes event")
+		Expect(line).To(HaveKeyWithValue("changes", ContainElement("mode")))
+		Expect(testutil.ToFloat64(w.metrics.driftDetected.WithLabelValues(path, "attributes"))).To(Equal(1.0))
+	})
+
+	It("bounds the encoded size of the snapshots kept in the Secret", func() {
+		// A third of the budget in raw content exceeds half of it once
+		// base64-encoded in the JSON value and again in the Secret.
+		big := strings.Repeat("x", maxSnapshotBytes/3)
+		snapshots := map[string]*FileSnapshot{
+			"/a": {SHA256: "a", Text: true, Content: []byte(big)},
+			"/b": {SHA256: "b", Text: true, Content: []byte(big)},
+		}
+		Expect(store.(SnapshotStore).SaveSnapshots(ctx, snapshots)).To(Succeed())
+		Expect(store.Save(ctx, map[string]string{"/a": "1"})).To(Succeed())
+
+		loaded, err := store.(SnapshotStore).LoadSnapshots(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(loaded["/a"].Text).To(BeTrue())
+		Expect(loaded["/b"].Text).To(BeFalse())
+		Expect(loaded["/b"].Content).To(BeNil())
+		Expect(loaded["/b"].SHA256).To(Equal("b"))
+
+		secret := &corev1.Secret{}
+		Expect(store.(*secretBaselineStore).client.Get(ctx,
+			types.NamespacedName{Name: "file-watcher-baseline", Namespace: "kof"}, secret)).To(Succeed())
+		Expect(secret.Data).To(HaveLen(3))
+		size := 0
+		for key, val := range secret.Data {
+			if strings.HasPrefix(key, snapshotKeyPrefix) {
+				size += encodedSize(key, val)
+			}
+		}
+		Expect(size).To(BeNumerically("<=", maxSnapshotBytes))
+	})
+})
@@ -29,6 +29,12 @@ type Watcher struct {
 	// skipped entirely. New paths absent from the stored baseline are absorbed
 	// on each startup; only modifications and deletions of known paths are flagged.
 	store BaselineStore
+
+	// snapshots holds the last seen state of every watched file (path →
+	// snapshot); each file event is attributed against it. Only accessed from
+	// the Run goroutine. Snapshots of deleted files are kept so that a file
+	// replaced by rename is diffed against its previous content.
+	snapshots map[string]*FileSnapshot
 }
 
 // NewWatcher constructs and initialises a Watcher using the default Prometheus registry.
@@ -44,11 +50,12 @@ func newWatcherWithRegistry(cfg *Config, log logr.Logger, reg prometheus.Registe
 		return nil, fmt.Errorf("create fsnotify watcher: %w", err)
 	}
 	return &Watcher{
-		cfg:     cfg,
-		fw:      fw,
-		log:     log,
-		last:    make(map[string]time.Time),
-		metrics: newMetrics(reg),
+		cfg:       cfg,
+		fw:        fw,
+		log:       log,
+		last:      make(map[string]time.Time),
+		metrics:   newMetrics(reg),
+		snapshots: make(map[string]*FileSnapshot),
 	}, nil
 }
 
@@ -146,8 +153,11 @@ func (w *Watcher) watch(ctx context.Context) {
 	}
 }
 
-// handleEvent processes a single fsnotify event, emitting a log line and
-// incrementing the appropriate Prometheus counter.
+// handleEvent processes a single fsnotify event, emitting a log line that
+// attributes the change against the last snapshot of the file (hashes, mode,
+// ownership, mtime and a unified diff for small text files) and setting the
+// appropriate Prometheus gauge. Chmod events are reported only when they
+// change an attribute of a known file.
 func (w *Watcher) handleEvent(event fsnotify.Event) {
 	var eventType string
 	switch {
@@ -155,19 +165,38 @@ func (w *Watcher) handleEvent(event fsnotify.Event) {
 		eventType = deletedEvent
 	case event.Has(fsnotify.Write) || event.Has(fsnotify.Create):
 		eventType = modifiedEvent
+	case event.Has(fsnotify.Chmod):
+		eventType = attributesEvent
 	default:
-		// Chmod or other events are ignored.
 		return
 	}
 
+	before := w.snapshots[event.Name]
+	var after *FileSnapshot
+	if eventType == attributesEvent {
+		// Chmod is also raised for no-op attribute updates: only report it
+		// when something actually changed.
+		after = w.snapshot(event.Name)
+		if before == nil || after == nil || len(changedAttributes(before, after)) == 0 {
+			return
+		}
+	}
+
 	if !w.debounce(event.Name) {
 		return
 	}
 
-	w.log.Info("file event detected",
+	if eventType == modifiedEvent {
+		after = w.snapshot(event.Name)
+	}
+	if after != nil {
+		w.snapshots[event.Name] = after
+	}
+
+	w.log.Info("file event detected", append([]any{
 		"event", eventType,
 		"path", event.Name,
-	)
+	}, changeLogValues(event.Name, before, after)...)...)
 	w.metrics.setDriftDetected(event.Name, eventType, true)
 
 	// When a new directory appears and recursive mode is enabled, register it
@@ -184,6 +213,17 @@ func (w *Watcher) handleEvent(event fsnotify.Event) {
 	}
 }
 
+// snapshot records the current state of the file at path, or returns nil when
+// it is not a readable regular file.
+func (w *Watcher) snapshot(path string) *FileSnapshot {
+	s, err := takeSnapshot(path, w.cfg.DiffMaxBytes)
+	if err != nil {
+		w.log.V(1).Info("cannot snapshot path", "path", path, "reason", err.Error())
+		return nil
+	}
+	return s
+}
+
 // debounce returns true only when the given path has not been seen within
 // cfg.DebounceDuration. Rapid bursts of events on the same path are collapsed
 // into a single emission.
@@ -205,12 +245,21 @@ func (w *Watcher) debounce(path string) bool {
 // raising drift. Only modifications and deletions of previously known paths are
 // flagged. When store is nil, all paths are treated as unchanged and the gauge
 // is set to 0.
+//
+// It also records the snapshots file events are attributed against. When the
+// store implements SnapshotStore, drift is attributed against the stored
+// snapshots (including mode, ownership and mtime changes of files with an
+// unchanged hash), and the stored snapshots of drifted files are kept so that
+// they remain the reference until the baseline is reset.
 func (w *Watcher) initBaseline(ctx context.Context) error {
 	current := make(map[string]string, len(w.cfg.WatchPaths))
 	for _, root := range w.cfg.WatchPaths {
 		if err := hashTree(root, current); err != nil {
 			w.log.Error(err, "baseline: failed to hash watch path", "path", root)
 		}
+		if err := snapshotTree(root, w.cfg.DiffMaxBytes, w.snapshots); err != nil {
+			w.log.Error(err, "baseline: failed to snapshot watch path", "path", root)
+		}
 	}
 
 	if w.store == nil {
@@ -227,24 +276,47 @@ func (w *Watcher) initBaseline(ctx context.Context) error {
 		return err
 	}
 
+	snapshotStore, _ := w.store.(SnapshotStore)
+	storedSnapshots := map[string]*FileSnapshot{}
+	if snapshotStore != nil {
+		if storedSnapshots, err = snapshotStore.LoadSnapshots(ctx); err != nil {
+			return err
+		}
+	}
+
 	for path, hash := range current {
 		storedHash, exists := stored[path]
+		before, after := storedSnapshots[path], w.snapshots[path]
+		drifted := true
 		if hash == "" {
-			w.log.Info("baseline: file no longer present, emitting deleted event", "path", path)
+			w.log.Info("baseline: file no longer present, emitting deleted event",
+				append([]any{"path", path}, changeLogValues(path, before, nil)...)...)
 			w.metrics.setDriftDetected(path, deletedEvent, true)
 			w.metrics.setDriftDetected(path, modifiedEvent, false)
 		} else if !exists {
 			stored[path] = hash
+			drifted = false
 			w.log.Info("baseline: new file detected", "path", path)
 			w.metrics.setDriftDetected(path, modifiedEvent, false)
 			w.metrics.setDriftDetected(path, deletedEvent, false)
 		} else if storedHash != hash {
-			w.log.Info("baseline: file differs from original, emitting modified event", "path", path)
+			w.log.Info("baseline: file differs from original, emitting modified event",
+				append([]any{"path", path}, changeLogValues(path, before, after)...)...)
 			w.metrics.setDriftDetected(path, modifiedEvent, true)
 			w.metrics.setDriftDetected(path, deletedEvent, false)
 		} else {
 			w.metrics.setDriftDetected(path, modifiedEvent, false)
 			w.metrics.setDriftDetected(path, deletedEvent, false)
+			drifted = before != nil && after != nil && len(changedAttributes(before, after)) > 0
+			if drifted {
+				w.log.Info("baseline: file attributes differ from original, emitting attributes event",
+					append([]any{"path", path}, changeLogValues(path, before, after)...)...)
+				w.metrics.setDriftDetected(path, attributesEvent, true)
+			}
+		}
+
+		if !drifted && after != nil {
+			storedSnapshots[path] = after
 		}
 	}
 
@@ -252,5 +324,11 @@ func (w *Watcher) initBaseline(ctx context.Context) error {
 		return fmt.Errorf("save initial baseline: %w", err)
 	}
 
+	if snapshotStore != nil {
+		if err := snapshotStore.SaveSnapshots(ctx, storedSnapshots); err != nil {
+			return fmt.Errorf("save initial snapshots: %w", err)
+		}
+	}
+
 	return nil
 }
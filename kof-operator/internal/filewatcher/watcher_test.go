@@ -128,7 +128,7 @@ var _ = Describe("Watcher handleEvent tests", func() {
 		Entry("Rename → deleted", fsnotify.Rename, "deleted"),
 	)
 
-	It("ignores Chmod events", func() {
+	It("ignores Chmod events on unknown paths", func() {
 		path := "/nonexistent/chmod-path"
 		w.handleEvent(fsnotify.Event{Name: path, Op: fsnotify.Chmod})
 
@@ -6,6 +6,7 @@ import (
 	"net/http"
 
 	kcmv1beta1 "github.com/K0rdent/kcm/api/v1beta1"
+	vmv1beta1 "github.com/VictoriaMetrics/operator/api/operator/v1beta1"
 	otel "github.com/k0rdent/kof/kof-operator/internal/otelv1beta1"
 	"github.com/k0rdent/kof/kof-operator/internal/telemetry"
 	addoncontrollerv1beta1 "github.com/projectsveltos/addon-controller/api/v1beta1"
@@ -32,6 +33,7 @@ func init() {
 	utilruntime.Must(addoncontrollerv1beta1.AddToScheme(scheme))
 	utilruntime.Must(libsveltosv1beta1.AddToScheme(scheme))
 	utilruntime.Must(otel.AddToScheme(scheme))
+	utilruntime.Must(vmv1beta1.AddToScheme(scheme))
 }
 
 type KubeClient struct {
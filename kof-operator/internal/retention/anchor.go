@@ -0,0 +1,90 @@
+// Copyright 2025
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retention
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	s3pkg "github.com/k0rdent/kof/kof-operator/internal/s3"
+)
+
+// AnchorName is the name of the retention anchor object, stored next to the
+// partitions of each source and tenant of an AnchoredLayout.
+const AnchorName = "retention-anchor.json"
+
+// AnchoredLayout is a Layout whose partitions are hash-chained through their
+// markers, like the previous_manifest_sha256 of audit manifests. Expiring a
+// partition would leave the next one linking to a marker that no longer
+// exists, so the marker hash of the newest expired partition is kept in an
+// anchor object and accepted as that link by the verifier.
+type AnchoredLayout interface {
+	Layout
+	// Anchor returns the key of the anchor object of a source and tenant.
+	Anchor(source, tenant string) string
+}
+
+// Anchor records the newest expired partition of a source and tenant.
+type Anchor struct {
+	// WindowEnd is the end of the expired window, i.e. the start of the
+	// window whose chain link points to it.
+	WindowEnd time.Time `json:"window_end"`
+	// MarkerSHA256 is the hex SHA-256 of the marker of the expired window.
+	MarkerSHA256 string `json:"marker_sha256"`
+	// ExpiredAt is the time of the retention run that expired the window.
+	ExpiredAt time.Time `json:"expired_at"`
+}
+
+// GetAnchor reads an anchor object, returning nil when it does not exist.
+func GetAnchor(ctx context.Context, s3client *s3pkg.Client, key string) (*Anchor, error) {
+	data, err := s3client.GetObject(ctx, key)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	anchor := new(Anchor)
+	if err := json.Unmarshal(data, anchor); err != nil {
+		return nil, fmt.Errorf("parse retention anchor %q: %w", key, err)
+	}
+	return anchor, nil
+}
+
+// advanceAnchor moves the anchor of the partition's source and tenant to the
+// partition, before the partition is deleted. An anchor already pointing to a
+// newer partition is kept, as is the anchor of a partition without a marker.
+func (r *Retainer) advanceAnchor(ctx context.Context, layout AnchoredLayout, p Partition, now time.Time) error {
+	marker, err := r.s3.GetObject(ctx, p.Prefix+layout.Marker())
+	if err != nil || marker == nil {
+		return err
+	}
+	key := layout.Anchor(p.Source, p.Tenant)
+	current, err := GetAnchor(ctx, r.s3, key)
+	if err != nil {
+		return err
+	}
+	if current != nil && current.WindowEnd.After(p.End) {
+		return nil
+	}
+
+	sum := sha256.Sum256(marker)
+	data, err := json.Marshal(Anchor{WindowEnd: p.End, MarkerSHA256: hex.EncodeToString(sum[:]), ExpiredAt: now})
+	if err != nil {
+		return err
+	}
+	return r.s3.PutObject(ctx, key, data, "application/json")
+}
@@ -0,0 +1,136 @@
+// Copyright 2025
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retention
+
+import (
+	"strings"
+	"time"
+)
+
+// Partition is the unit of retention: one exported window of a source and
+// tenant, stored as the objects under Prefix.
+type Partition struct {
+	Source string
+	Tenant string
+	// Prefix is the key prefix of the partition objects, with a trailing slash.
+	Prefix string
+	// End is the exclusive end of the exported window. A partition expires
+	// once End is older than the retention of its source and tenant.
+	End time.Time
+}
+
+// Layout maps object keys of an exporter to their partitions.
+type Layout interface {
+	// Root is the key prefix holding all partitions of the exporter.
+	Root() string
+	// Partition returns the partition holding key, or false for keys outside
+	// any partition (e.g. restore progress markers).
+	Partition(key string) (Partition, bool)
+	// Marker is the name of the object that marks a partition as complete.
+	// It is deleted first, so a partially deleted partition is never
+	// mistaken for a complete one.
+	Marker() string
+}
+
+// ColdStorageLayout is the layout of the cold-storage exporter:
+// <prefix>/tenant=<t>/cluster=<c>/dt=YYYY-MM-DD/hour=HH/<source>/.
+type ColdStorageLayout struct {
+	Prefix string
+}
+
+var _ Layout = ColdStorageLayout{}
+
+// Root implements Layout.
+func (l ColdStorageLayout) Root() string { return rootPrefix(l.Prefix) }
+
+// Marker implements Layout.
+func (l ColdStorageLayout) Marker() string { return "_SUCCESS" }
+
+// Partition implements Layout.
+func (l ColdStorageLayout) Partition(key string) (Partition, bool) {
+	rest, ok := strings.CutPrefix(key, l.Root())
+	if !ok {
+		return Partition{}, false
+	}
+	parts := strings.SplitN(rest, "/", 6)
+	if len(parts) != 6 || parts[5] == "" {
+		return Partition{}, false
+	}
+	tenant, ok1 := strings.CutPrefix(parts[0], "tenant=")
+	_, ok2 := strings.CutPrefix(parts[1], "cluster=")
+	dt, ok3 := strings.CutPrefix(parts[2], "dt=")
+	hour, ok4 := strings.CutPrefix(parts[3], "hour=")
+	if !ok1 || !ok2 || !ok3 || !ok4 || tenant == "" || parts[4] == "" {
+		return Partition{}, false
+	}
+	start, err := time.Parse("2006-01-02T15", dt+"T"+hour)
+	if err != nil {
+		return Partition{}, false
+	}
+	return Partition{
+		Source: parts[4],
+		Tenant: tenant,
+		Prefix: l.Root() + strings.Join(parts[:5], "/") + "/",
+		End:    start.Add(time.Hour),
+	}, true
+}
+
+// AuditLayout is the layout of the audit-logs exporter:
+// <prefix>/<stream>/<tenant>/YYYY/MM/DD/HH/. The stream is the partition source.
+type AuditLayout struct {
+	Prefix string
+}
+
+var _ AnchoredLayout = AuditLayout{}
+
+// Root implements Layout.
+func (l AuditLayout) Root() string { return rootPrefix(l.Prefix) }
+
+// Marker implements Layout.
+func (l AuditLayout) Marker() string { return "manifest.json" }
+
+// Partition implements Layout.
+func (l AuditLayout) Partition(key string) (Partition, bool) {
+	rest, ok := strings.CutPrefix(key, l.Root())
+	if !ok {
+		return Partition{}, false
+	}
+	parts := strings.SplitN(rest, "/", 7)
+	if len(parts) != 7 || parts[6] == "" || parts[0] == "" || parts[1] == "" {
+		return Partition{}, false
+	}
+	start, err := time.Parse("2006/01/02/15", strings.Join(parts[2:6], "/"))
+	if err != nil {
+		return Partition{}, false
+	}
+	return Partition{
+		Source: parts[0],
+		Tenant: parts[1],
+		Prefix: l.Root() + strings.Join(parts[:6], "/") + "/",
+		End:    start.Add(time.Hour),
+	}, true
+}
+
+// Anchor implements AnchoredLayout: <prefix>/<stream>/<tenant>/retention-anchor.json.
+func (l AuditLayout) Anchor(source, tenant string) string {
+	return l.Root() + source + "/" + tenant + "/" + AnchorName
+}
+
+func rootPrefix(prefix string) string {
+	if prefix == "" {
+		return ""
+	}
+	return prefix + "/"
+}
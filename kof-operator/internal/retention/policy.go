@@ -0,0 +1,169 @@
+// Copyright 2025
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retention
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AnySource matches every source in a policy rule.
+const AnySource = "*"
+
+const (
+	day  = 24 * time.Hour
+	year = 365 * day
+)
+
+// Rule keeps the partitions of a source, optionally of a single tenant,
+// for MaxAge.
+type Rule struct {
+	Source string
+	// Tenant is empty for rules applying to all tenants of the source.
+	Tenant string
+	MaxAge time.Duration
+}
+
+// Policy is the set of retention rules of an exporter.
+// Partitions matching no rule are kept forever.
+type Policy struct {
+	Rules []Rule
+}
+
+// ParsePolicy parses a comma-separated list of "<source>[/<tenant>]=<age>"
+// rules, e.g. "metrics=400d,logs=90d,logs/acme=30d". The source may be "*" to
+// match every source. Ages are Go durations or a number of days ("d") or
+// 365-day years ("y"). When sources is not empty, rules for other sources are
+// rejected.
+func ParsePolicy(raw string, sources ...string) (*Policy, error) {
+	p := &Policy{}
+	for _, entry := range strings.Split(raw, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		selector, age, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("retention rule %q: expected <source>[/<tenant>]=<age>", entry)
+		}
+		source, tenant, _ := strings.Cut(strings.TrimSpace(selector), "/")
+		if source == "" {
+			return nil, fmt.Errorf("retention rule %q: source must not be empty", entry)
+		}
+		if len(sources) > 0 && source != AnySource && !slices.Contains(sources, source) {
+			return nil, fmt.Errorf("retention rule %q: unknown source %q (expected one of %s)",
+				entry, source, strings.Join(sources, ", "))
+		}
+		maxAge, err := ParseAge(strings.TrimSpace(age))
+		if err != nil {
+			return nil, fmt.Errorf("retention rule %q: %w", entry, err)
+		}
+		if slices.ContainsFunc(p.Rules, func(r Rule) bool { return r.Source == source && r.Tenant == tenant }) {
+			return nil, fmt.Errorf("retention rule %q: duplicate rule", entry)
+		}
+		p.Rules = append(p.Rules, Rule{Source: source, Tenant: tenant, MaxAge: maxAge})
+	}
+	return p, nil
+}
+
+// ParseAge parses a retention age: a Go duration ("36h"), a number of days
+// ("400d") or a number of 365-day years ("7y").
+func ParseAge(s string) (time.Duration, error) {
+	var (
+		d   time.Duration
+		err error
+	)
+	switch {
+	case strings.HasSuffix(s, "d"):
+		d, err = parseUnits(strings.TrimSuffix(s, "d"), day)
+	case strings.HasSuffix(s, "y"):
+		d, err = parseUnits(strings.TrimSuffix(s, "y"), year)
+	default:
+		d, err = time.ParseDuration(s)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("invalid age %q: %w", s, err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("invalid age %q: must be positive", s)
+	}
+	return d, nil
+}
+
+func parseUnits(s string, unit time.Duration) (time.Duration, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(n) * unit, nil
+}
+
+// Empty reports whether the policy has no rules.
+func (p *Policy) Empty() bool {
+	return p == nil || len(p.Rules) == 0
+}
+
+// MaxAge returns the retention of a tenant's partitions of a source.
+// The most specific rule wins: source and tenant, then source, then "*" and
+// tenant, then "*". Returns false when no rule matches.
+func (p *Policy) MaxAge(source, tenant string) (time.Duration, bool) {
+	if p == nil {
+		return 0, false
+	}
+	for _, want := range []Rule{
+		{Source: source, Tenant: tenant},
+		{Source: source},
+		{Source: AnySource, Tenant: tenant},
+		{Source: AnySource},
+	} {
+		for _, r := range p.Rules {
+			if r.Source == want.Source && r.Tenant == want.Tenant {
+				return r.MaxAge, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// String formats the policy the way ParsePolicy reads it.
+func (p *Policy) String() string {
+	if p == nil {
+		return ""
+	}
+	parts := make([]string, 0, len(p.Rules))
+	for _, r := range p.Rules {
+		selector := r.Source
+		if r.Tenant != "" {
+			selector += "/" + r.Tenant
+		}
+		parts = append(parts, selector+"="+formatAge(r.MaxAge))
+	}
+	return strings.Join(parts, ",")
+}
+
+func formatAge(d time.Duration) string {
+	switch {
+	case d%year == 0:
+		return strconv.FormatInt(int64(d/year), 10) + "y"
+	case d%day == 0:
+		return strconv.FormatInt(int64(d/day), 10) + "d"
+	case d%time.Hour == 0:
+		return strconv.FormatInt(int64(d/time.Hour), 10) + "h"
+	default:
+		return d.String()
+	}
+}
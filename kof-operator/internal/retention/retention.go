@@ -0,0 +1,230 @@
+// Copyright 2025
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package retention deletes exported partitions older than their retention
+// from the cold-storage and audit S3 prefixes.
+//
+// Expired partitions are deleted explicitly rather than through bucket
+// lifecycle rules: lifecycle filters match key prefixes and tags only, so they
+// cannot express per-tenant ages over the dt=/YYYY/MM/DD partition layout, and
+// they would expire audit windows without checking their object lock.
+package retention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	s3pkg "github.com/k0rdent/kof/kof-operator/internal/s3"
+)
+
+// RunOptions controls a retention run.
+type RunOptions struct {
+	// DryRun reports the partitions that would be deleted without deleting them.
+	DryRun bool
+	// Now is the reference time of the run, default: the current time.
+	Now time.Time
+}
+
+// Summary reports the outcome of a retention run.
+type Summary struct {
+	DryRun bool `json:"dry_run"`
+	// Partitions is the number of expired partitions deleted, or that would
+	// be deleted in a dry run.
+	Partitions int `json:"partitions"`
+	// Objects is the number of object versions in those partitions, not
+	// counting delete markers.
+	Objects int `json:"objects"`
+	// BytesReclaimed is the total size of those object versions.
+	BytesReclaimed int64 `json:"bytes_reclaimed"`
+	// Locked is the number of expired partitions kept because at least one
+	// of their objects is under an object-lock retention or legal hold.
+	Locked int `json:"locked"`
+	// LockedBytes is the total size of the locked partitions.
+	LockedBytes int64 `json:"locked_bytes"`
+	// Failed is the number of expired partitions that could not be deleted.
+	Failed int `json:"failed"`
+}
+
+// Retainer deletes the expired partitions of one exporter layout.
+type Retainer struct {
+	s3     *s3pkg.Client
+	layout Layout
+	policy *Policy
+	log    logr.Logger
+}
+
+// NewRetainer creates a Retainer applying policy to the partitions of layout.
+func NewRetainer(s3client *s3pkg.Client, layout Layout, policy *Policy, log logr.Logger) *Retainer {
+	return &Retainer{s3: s3client, layout: layout, policy: policy, log: log}
+}
+
+// walkedPartition is the partition of the objects being walked.
+type walkedPartition struct {
+	Partition
+	expired bool
+}
+
+// Run walks all partitions of the layout and deletes the expired ones.
+//
+// A partition whose objects are object-locked is kept and counted as locked.
+// A partition that cannot be deleted is logged and counted as failed; the
+// next run retries it.
+func (r *Retainer) Run(ctx context.Context, opts RunOptions) (Summary, error) {
+	summary := Summary{DryRun: opts.DryRun}
+	if r.policy.Empty() {
+		return summary, fmt.Errorf("retention policy is empty")
+	}
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	now = now.UTC()
+
+	r.log.Info("retention run started", "root", r.layout.Root(), "policy", r.policy.String(), "dry_run", opts.DryRun)
+
+	var cur *walkedPartition
+	flush := func() error {
+		if cur == nil || !cur.expired {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := r.expire(ctx, cur, now, opts.DryRun, &summary); err != nil {
+			if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+				return err
+			}
+			r.log.Error(err, "failed to delete expired partition", "prefix", cur.Prefix)
+			summary.Failed++
+		}
+		return nil
+	}
+
+	err := r.s3.WalkObjects(ctx, r.layout.Root(), func(obj s3pkg.ObjectInfo) error {
+		p, ok := r.layout.Partition(obj.Key)
+		if !ok {
+			return nil
+		}
+		if cur == nil || cur.Prefix != p.Prefix {
+			if err := flush(); err != nil {
+				return err
+			}
+			cur = &walkedPartition{Partition: p, expired: r.expired(p, now)}
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		return summary, err
+	}
+
+	r.log.Info("retention run complete",
+		"dry_run", summary.DryRun, "partitions", summary.Partitions, "objects", summary.Objects,
+		"bytes_reclaimed", summary.BytesReclaimed, "locked", summary.Locked,
+		"locked_bytes", summary.LockedBytes, "failed", summary.Failed)
+	if summary.Failed > 0 {
+		return summary, fmt.Errorf("%d expired partitions failed to delete", summary.Failed)
+	}
+	return summary, nil
+}
+
+// expired reports whether the partition is older than its retention at now.
+func (r *Retainer) expired(p Partition, now time.Time) bool {
+	maxAge, ok := r.policy.MaxAge(p.Source, p.Tenant)
+	return ok && !p.End.After(now.Add(-maxAge))
+}
+
+// expire deletes every version of the objects of an expired partition, marker
+// first, unless any of them is object-locked. Versions are listed and deleted
+// one by one, as deleting by key on a versioned bucket only adds a delete
+// marker and keeps the data.
+func (r *Retainer) expire(ctx context.Context, p *walkedPartition, now time.Time, dryRun bool, summary *Summary) error {
+	log := r.log.WithValues("source", p.Source, "tenant", p.Tenant, "prefix", p.Prefix)
+
+	versions, err := r.s3.ListObjectVersions(ctx, p.Prefix)
+	if err != nil {
+		return err
+	}
+
+	var objects int
+	var size int64
+	for _, v := range versions {
+		if !v.DeleteMarker {
+			objects++
+			size += v.Size
+		}
+	}
+
+	for _, v := range versions {
+		if v.DeleteMarker {
+			continue
+		}
+		lock, err := r.s3.GetObjectLock(ctx, v.Key, v.VersionID)
+		if err != nil {
+			return fmt.Errorf("check object lock: %w", err)
+		}
+		if lock.Locked(now) {
+			log.Info("expired partition is object-locked, keeping", "key", v.Key, "version", v.VersionID,
+				"retain_until", lock.RetainUntil, "legal_hold", lock.LegalHold)
+			summary.Locked++
+			summary.LockedBytes += size
+			return nil
+		}
+	}
+
+	marker := p.Prefix + r.layout.Marker()
+	var markerVersions, otherVersions []s3pkg.ObjectVersion
+	for _, v := range versions {
+		if v.Key == marker {
+			markerVersions = append(markerVersions, v)
+		} else {
+			otherVersions = append(otherVersions, v)
+		}
+	}
+
+	if dryRun {
+		for _, v := range versions {
+			log.Info("would delete object", "key", v.Key, "version", v.VersionID, "bytes", v.Size)
+		}
+	} else {
+		if layout, ok := r.layout.(AnchoredLayout); ok {
+			if err := r.advanceAnchor(ctx, layout, p.Partition, now); err != nil {
+				return fmt.Errorf("advance retention anchor: %w", err)
+			}
+		}
+		// Objects within a DeleteObjects batch are deleted in no particular
+		// order, so the marker goes in its own request.
+		for _, batch := range [][]s3pkg.ObjectVersion{markerVersions, otherVersions} {
+			if len(batch) == 0 {
+				continue
+			}
+			if err := r.s3.DeleteObjectVersions(ctx, batch); err != nil {
+				return err
+			}
+		}
+		for _, v := range versions {
+			log.Info("deleted object", "key", v.Key, "version", v.VersionID, "bytes", v.Size)
+		}
+	}
+
+	summary.Partitions++
+	summary.Objects += objects
+	summary.BytesReclaimed += size
+	return nil
+}
@@ -0,0 +1,438 @@
+// Copyright 2025
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retention
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/go-logr/logr"
+	s3pkg "github.com/k0rdent/kof/kof-operator/internal/s3"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// memObject is a version of an object of memS3 with its object-lock metadata.
+type memObject struct {
+	version     string
+	data        []byte
+	size        int64
+	retainUntil time.Time
+	legalHold   bool
+}
+
+// memS3 implements s3pkg.RawAPI with an in-memory versioned object store
+// that records the order of DeleteObjects requests. objects holds the current
+// versions and noncurrent the older ones; versions left empty are "null".
+type memS3 struct {
+	objects    map[string]memObject
+	noncurrent map[string][]memObject
+	deletes    [][]string
+}
+
+func newMemS3() *memS3 {
+	return &memS3{objects: make(map[string]memObject), noncurrent: make(map[string][]memObject)}
+}
+
+func versionID(obj memObject) string {
+	if obj.version == "" {
+		return "null"
+	}
+	return obj.version
+}
+
+// getVersion returns a version of key, or its current version when version is empty.
+func (m *memS3) getVersion(key, version string) (memObject, bool) {
+	obj, ok := m.objects[key]
+	if version == "" || (ok && versionID(obj) == version) {
+		return obj, ok
+	}
+	for _, obj := range m.noncurrent[key] {
+		if versionID(obj) == version {
+			return obj, true
+		}
+	}
+	return memObject{}, false
+}
+
+func (m *memS3) GetObjectLockConfiguration(_ context.Context, _ *s3.GetObjectLockConfigurationInput, _ ...func(*s3.Options)) (*s3.GetObjectLockConfigurationOutput, error) {
+	panic("not implemented")
+}
+func (m *memS3) HeadObject(_ context.Context, params *s3.HeadObjectInput, _ ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	obj, ok := m.getVersion(aws.ToString(params.Key), aws.ToString(params.VersionId))
+	if !ok {
+		return nil, &types.NotFound{}
+	}
+	out := &s3.HeadObjectOutput{ContentLength: aws.Int64(obj.size)}
+	if !obj.retainUntil.IsZero() {
+		out.ObjectLockMode = types.ObjectLockModeCompliance
+		out.ObjectLockRetainUntilDate = aws.Time(obj.retainUntil)
+	}
+	if obj.legalHold {
+		out.ObjectLockLegalHoldStatus = types.ObjectLockLegalHoldStatusOn
+	}
+	return out, nil
+}
+func (m *memS3) GetObject(_ context.Context, params *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	obj, ok := m.objects[aws.ToString(params.Key)]
+	if !ok {
+		return nil, &types.NoSuchKey{}
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(obj.data))}, nil
+}
+func (m *memS3) PutObject(_ context.Context, params *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	data, err := io.ReadAll(params.Body)
+	if err != nil {
+		return nil, err
+	}
+	m.objects[aws.ToString(params.Key)] = memObject{data: data, size: int64(len(data))}
+	return &s3.PutObjectOutput{}, nil
+}
+func (m *memS3) ListObjectsV2(_ context.Context, params *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	keys := make([]string, 0)
+	for key := range m.objects {
+		if strings.HasPrefix(key, aws.ToString(params.Prefix)) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	out := &s3.ListObjectsV2Output{IsTruncated: aws.Bool(false)}
+	for _, key := range keys {
+		out.Contents = append(out.Contents, types.Object{Key: aws.String(key), Size: aws.Int64(m.objects[key].size)})
+	}
+	return out, nil
+}
+func (m *memS3) ListObjectVersions(_ context.Context, params *s3.ListObjectVersionsInput, _ ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error) {
+	keys := make([]string, 0)
+	for key := range m.objects {
+		if strings.HasPrefix(key, aws.ToString(params.Prefix)) {
+			keys = append(keys, key)
+		}
+	}
+	for key := range m.noncurrent {
+		if _, ok := m.objects[key]; !ok && strings.HasPrefix(key, aws.ToString(params.Prefix)) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	out := &s3.ListObjectVersionsOutput{IsTruncated: aws.Bool(false)}
+	for _, key := range keys {
+		versions := m.noncurrent[key]
+		if obj, ok := m.objects[key]; ok {
+			versions = append([]memObject{obj}, versions...)
+		}
+		for _, obj := range versions {
+			out.Versions = append(out.Versions, types.ObjectVersion{
+				Key: aws.String(key), VersionId: aws.String(versionID(obj)), Size: aws.Int64(obj.size),
+			})
+		}
+	}
+	return out, nil
+}
+func (m *memS3) DeleteObjects(_ context.Context, params *s3.DeleteObjectsInput, _ ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+	batch := make([]string, 0, len(params.Delete.Objects))
+	for _, id := range params.Delete.Objects {
+		key, version := aws.ToString(id.Key), aws.ToString(id.VersionId)
+		if obj, ok := m.objects[key]; ok && versionID(obj) == version {
+			delete(m.objects, key)
+		}
+		m.noncurrent[key] = slices.DeleteFunc(m.noncurrent[key], func(obj memObject) bool {
+			return versionID(obj) == version
+		})
+		if len(m.noncurrent[key]) == 0 {
+			delete(m.noncurrent, key)
+		}
+		batch = append(batch, key)
+	}
+	m.deletes = append(m.deletes, batch)
+	return &s3.DeleteObjectsOutput{}, nil
+}
+
+var _ s3pkg.RawAPI = (*memS3)(nil)
+
+var _ = Describe("Policy", func() {
+	It("parses rules and resolves the most specific one", func() {
+		p, err := ParsePolicy("metrics=400d, logs=90d,logs/acme=30d,*=7y,*/beta=36h", "metrics", "logs", "traces")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(p.String()).To(Equal("metrics=400d,logs=90d,logs/acme=30d,*=7y,*/beta=36h"))
+
+		for _, tc := range []struct {
+			source, tenant string
+			want           time.Duration
+		}{
+			{"metrics", "acme", 400 * day},
+			{"logs", "acme", 30 * day},
+			{"logs", "beta", 90 * day},
+			{"traces", "beta", 36 * time.Hour},
+			{"traces", "acme", 7 * year},
+		} {
+			got, ok := p.MaxAge(tc.source, tc.tenant)
+			Expect(ok).To(BeTrue())
+			Expect(got).To(Equal(tc.want), "%s/%s", tc.source, tc.tenant)
+		}
+	})
+
+	It("keeps partitions without a matching rule", func() {
+		p, err := ParsePolicy("metrics=400d")
+		Expect(err).NotTo(HaveOccurred())
+		_, ok := p.MaxAge("logs", "acme")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("rejects invalid rules", func() {
+		for _, raw := range []string{"metrics", "=1d", "metrics=0d", "metrics=1w", "metrics=1d,metrics=2d", "events=1d"} {
+			_, err := ParsePolicy(raw, "metrics", "logs")
+			Expect(err).To(HaveOccurred(), raw)
+		}
+	})
+})
+
+var _ = Describe("Layout", func() {
+	It("maps cold-storage keys to partitions", func() {
+		l := ColdStorageLayout{Prefix: "telemetry"}
+		p, ok := l.Partition("telemetry/tenant=acme/cluster=c1/dt=2025-01-01/hour=10/metrics/metrics.parquet")
+		Expect(ok).To(BeTrue())
+		Expect(p).To(Equal(Partition{
+			Source: "metrics",
+			Tenant: "acme",
+			Prefix: "telemetry/tenant=acme/cluster=c1/dt=2025-01-01/hour=10/metrics/",
+			End:    time.Date(2025, 1, 1, 11, 0, 0, 0, time.UTC),
+		}))
+
+		for _, key := range []string{
+			"telemetry/_restore/abc/tenant=acme/cluster=c1/dt=2025-01-01/hour=10/metrics/_RESTORED",
+			"telemetry/tenant=acme/cluster=c1/dt=2025-13-01/hour=10/metrics/metrics.parquet",
+			"telemetry/tenant=acme/cluster=c1/dt=2025-01-01/hour=10/metrics/",
+			"other/tenant=acme/cluster=c1/dt=2025-01-01/hour=10/metrics/metrics.parquet",
+		} {
+			_, ok := l.Partition(key)
+			Expect(ok).To(BeFalse(), key)
+		}
+	})
+
+	It("maps audit keys to partitions", func() {
+		l := AuditLayout{Prefix: "audit"}
+		p, ok := l.Partition("audit/tenant-audit-log/acme/2025/01/01/10/manifest.json")
+		Expect(ok).To(BeTrue())
+		Expect(p).To(Equal(Partition{
+			Source: "tenant-audit-log",
+			Tenant: "acme",
+			Prefix: "audit/tenant-audit-log/acme/2025/01/01/10/",
+			End:    time.Date(2025, 1, 1, 11, 0, 0, 0, time.UTC),
+		}))
+
+		_, ok = l.Partition("audit/tenant-audit-log/acme/2025/01/01/manifest.json")
+		Expect(ok).To(BeFalse())
+	})
+})
+
+var _ = Describe("Retainer", func() {
+	var (
+		ctx   context.Context
+		store *memS3
+		now   = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	)
+
+	coldKey := func(source, tenant string, start time.Time, file string) string {
+		return "telemetry/tenant=" + tenant + "/cluster=c1/dt=" + start.Format("2006-01-02") +
+			"/hour=" + start.Format("15") + "/" + source + "/" + file
+	}
+	putColdPartition := func(source, tenant string, start time.Time) {
+		store.objects[coldKey(source, tenant, start, source+".parquet")] = memObject{size: 100}
+		store.objects[coldKey(source, tenant, start, "_SUCCESS")] = memObject{}
+	}
+	run := func(layout Layout, policy string, dryRun bool) (Summary, error) {
+		p, err := ParsePolicy(policy)
+		Expect(err).NotTo(HaveOccurred())
+		r := NewRetainer(s3pkg.NewClientFromRaw(store, "bucket"), layout, p, logr.Discard())
+		return r.Run(ctx, RunOptions{DryRun: dryRun, Now: now})
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		store = newMemS3()
+	})
+
+	It("deletes expired partitions per source and tenant, marker first", func() {
+		// The first window ends exactly 10 days before now and is expired.
+		putColdPartition("metrics", "acme", now.Add(-10*day-time.Hour))
+		putColdPartition("metrics", "acme", now.Add(-10*day))
+		putColdPartition("logs", "acme", now.Add(-3*day))
+		putColdPartition("logs", "beta", now.Add(-3*day))
+		putColdPartition("traces", "acme", now.Add(-100*day))
+		store.objects["telemetry/_restore/abc/tenant=acme/cluster=c1/dt=2024-01-01/hour=00/metrics/_RESTORED"] = memObject{}
+
+		summary, err := run(ColdStorageLayout{Prefix: "telemetry"}, "metrics=10d,logs/acme=2d,logs=5d", false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(summary).To(Equal(Summary{Partitions: 2, Objects: 4, BytesReclaimed: 200}))
+
+		Expect(store.objects).NotTo(HaveKey(coldKey("metrics", "acme", now.Add(-10*day-time.Hour), "_SUCCESS")))
+		Expect(store.objects).To(HaveKey(coldKey("metrics", "acme", now.Add(-10*day), "_SUCCESS")))
+		Expect(store.objects).NotTo(HaveKey(coldKey("logs", "acme", now.Add(-3*day), "_SUCCESS")))
+		Expect(store.objects).To(HaveKey(coldKey("logs", "beta", now.Add(-3*day), "_SUCCESS")))
+		Expect(store.objects).To(HaveKey(coldKey("traces", "acme", now.Add(-100*day), "_SUCCESS")))
+		Expect(store.objects).To(HaveLen(7))
+
+		Expect(store.deletes).To(HaveLen(4))
+		for i := 0; i < len(store.deletes); i += 2 {
+			Expect(store.deletes[i]).To(ConsistOf(HaveSuffix("/_SUCCESS")))
+			Expect(store.deletes[i+1]).To(ConsistOf(HaveSuffix(".parquet")))
+		}
+	})
+
+	It("reports without deleting in a dry run", func() {
+		putColdPartition("metrics", "acme", now.Add(-30*day))
+
+		summary, err := run(ColdStorageLayout{Prefix: "telemetry"}, "metrics=10d", true)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(summary).To(Equal(Summary{DryRun: true, Partitions: 1, Objects: 2, BytesReclaimed: 100}))
+		Expect(store.objects).To(HaveLen(2))
+		Expect(store.deletes).To(BeEmpty())
+	})
+
+	It("never deletes object-locked audit windows", func() {
+		auditPrefix := func(start time.Time) string {
+			return "audit/tenant-audit-log/acme/" + start.Format("2006/01/02/15") + "/"
+		}
+		putWindow := func(start time.Time, retainUntil time.Time) {
+			for _, name := range []string{"data.jsonl.gz", "manifest.json.sig", "manifest.json"} {
+				store.objects[auditPrefix(start)+name] = memObject{size: 10, retainUntil: retainUntil}
+			}
+		}
+		old := now.Add(-8 * year)
+		putWindow(old, now.Add(-time.Hour))
+		putWindow(old.Add(time.Hour), now.Add(time.Hour))
+		putWindow(old.Add(2*time.Hour), time.Time{})
+		store.objects[auditPrefix(old.Add(2*time.Hour))+"data.jsonl.gz"] = memObject{size: 10, legalHold: true}
+
+		summary, err := run(AuditLayout{Prefix: "audit"}, "tenant-audit-log=7y", false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(summary).To(Equal(Summary{Partitions: 1, Objects: 3, BytesReclaimed: 30, Locked: 2, LockedBytes: 60}))
+		Expect(store.objects).NotTo(HaveKey(auditPrefix(old) + "manifest.json"))
+		Expect(store.objects).To(HaveKey(auditPrefix(old.Add(time.Hour)) + "manifest.json"))
+		Expect(store.objects).To(HaveKey(auditPrefix(old.Add(2*time.Hour)) + "manifest.json"))
+		Expect(store.deletes[0]).To(Equal([]string{auditPrefix(old) + "manifest.json"}))
+	})
+
+	It("deletes every version of expired partitions on versioned buckets", func() {
+		start := now.Add(-30 * day)
+		putColdPartition("metrics", "acme", start)
+		parquet := coldKey("metrics", "acme", start, "metrics.parquet")
+		store.objects[parquet] = memObject{version: "v2", size: 100}
+		store.noncurrent[parquet] = []memObject{{version: "v1", size: 50}}
+
+		summary, err := run(ColdStorageLayout{Prefix: "telemetry"}, "metrics=10d", false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(summary).To(Equal(Summary{Partitions: 1, Objects: 3, BytesReclaimed: 150}))
+		Expect(store.objects).To(BeEmpty())
+		Expect(store.noncurrent).To(BeEmpty())
+	})
+
+	It("keeps expired partitions with a locked noncurrent version", func() {
+		start := now.Add(-30 * day)
+		putColdPartition("metrics", "acme", start)
+		parquet := coldKey("metrics", "acme", start, "metrics.parquet")
+		store.objects[parquet] = memObject{version: "v2", size: 100}
+		store.noncurrent[parquet] = []memObject{{version: "v1", size: 50, legalHold: true}}
+
+		summary, err := run(ColdStorageLayout{Prefix: "telemetry"}, "metrics=10d", false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(summary).To(Equal(Summary{Locked: 1, LockedBytes: 150}))
+		Expect(store.noncurrent).To(HaveKey(parquet))
+		Expect(store.deletes).To(BeEmpty())
+	})
+
+	It("anchors the manifest chain at the newest expired audit window", func() {
+		auditPrefix := func(start time.Time) string {
+			return "audit/tenant-audit-log/acme/" + start.Format("2006/01/02/15") + "/"
+		}
+		putWindow := func(start time.Time) []byte {
+			manifest := []byte(`{"window_start":"` + start.Format(time.RFC3339) + `"}`)
+			store.objects[auditPrefix(start)+"data.jsonl.gz"] = memObject{size: 10}
+			store.objects[auditPrefix(start)+"manifest.json"] = memObject{data: manifest, size: int64(len(manifest))}
+			return manifest
+		}
+		old := now.Add(-8 * year)
+		putWindow(old)
+		newest := putWindow(old.Add(time.Hour))
+		putWindow(now.Add(-time.Hour))
+
+		summary, err := run(AuditLayout{Prefix: "audit"}, "tenant-audit-log=7y", false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(summary.Partitions).To(Equal(2))
+
+		anchorKey := "audit/tenant-audit-log/acme/" + AnchorName
+		Expect(store.objects).To(HaveKey(anchorKey))
+		anchor := new(Anchor)
+		Expect(json.Unmarshal(store.objects[anchorKey].data, anchor)).To(Succeed())
+		sum := sha256.Sum256(newest)
+		Expect(anchor.WindowEnd).To(BeTemporally("==", old.Add(2*time.Hour)))
+		Expect(anchor.MarkerSHA256).To(Equal(hex.EncodeToString(sum[:])))
+		Expect(anchor.ExpiredAt).To(BeTemporally("==", now))
+
+		// The anchor itself is outside any partition and is never expired.
+		_, ok := AuditLayout{Prefix: "audit"}.Partition(anchorKey)
+		Expect(ok).To(BeFalse())
+	})
+
+	It("counts partitions that fail to delete and continues", func() {
+		putColdPartition("metrics", "acme", now.Add(-30*day))
+		putColdPartition("metrics", "acme", now.Add(-29*day))
+		p, err := ParsePolicy("metrics=10d")
+		Expect(err).NotTo(HaveOccurred())
+		r := NewRetainer(s3pkg.NewClientFromRaw(&holdOnDelete{memS3: store}, "bucket"),
+			ColdStorageLayout{Prefix: "telemetry"}, p, logr.Discard())
+
+		summary, err := r.Run(ctx, RunOptions{Now: now})
+		Expect(err).To(MatchError(ContainSubstring("1 expired partitions failed")))
+		Expect(summary.Partitions).To(Equal(1))
+		Expect(summary.Failed).To(Equal(1))
+	})
+
+	It("rejects an empty policy", func() {
+		_, err := run(ColdStorageLayout{Prefix: "telemetry"}, "", false)
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+// holdOnDelete fails the first DeleteObjects request as if a legal hold had
+// been placed on its objects.
+type holdOnDelete struct {
+	*memS3
+	failed bool
+}
+
+func (h *holdOnDelete) DeleteObjects(ctx context.Context, params *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+	if !h.failed {
+		h.failed = true
+		out := &s3.DeleteObjectsOutput{}
+		for _, obj := range params.Delete.Objects {
+			out.Errors = append(out.Errors, types.Error{Key: obj.Key, Code: aws.String("AccessDenied")})
+		}
+		return out, nil
+	}
+	return h.memS3.DeleteObjects(ctx, params, optFns...)
+}
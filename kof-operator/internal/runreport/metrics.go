@@ -0,0 +1,97 @@
+// Copyright 2025
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runreport
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricsNamespace = "kof_export"
+
+// Window results used as the "result" label of kof_export_windows.
+const (
+	resultExported = "exported"
+	resultSkipped  = "skipped"
+	resultFailed   = "failed"
+)
+
+// NewRegistry returns a registry holding the metrics of a report. The metrics
+// are gauges describing the last run; the job is added by the pusher.
+func NewRegistry(report *Report) *prometheus.Registry {
+	var (
+		windows = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "windows",
+			Help:      "Number of windows processed by the last run, by result (exported, skipped, failed).",
+		}, []string{"source", "tenant", "result"})
+		rows = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "rows",
+			Help:      "Number of rows (samples, log lines, spans or audit events) exported by the last run.",
+		}, []string{"source", "tenant"})
+		bytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "bytes",
+			Help:      "Number of bytes uploaded to S3 by the last run.",
+		}, []string{"source", "tenant"})
+		lag = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "lag_seconds",
+			Help:      "Age of the oldest window not exported at the end of the last run, 0 when caught up.",
+		}, []string{"source", "tenant"})
+		sourceUp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "source_up",
+			Help:      "0 if the source was unreachable while collecting windows in the last run, 1 otherwise.",
+		}, []string{"source"})
+		duration = prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "run_duration_seconds",
+			Help:      "Duration of the last run.",
+		})
+		lastRun = prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "last_run_timestamp_seconds",
+			Help:      "Unix time the last run finished.",
+		})
+		success = prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "last_run_success",
+			Help:      "1 if the last run succeeded, 0 otherwise.",
+		})
+	)
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(windows, rows, bytes, lag, sourceUp, duration, lastRun, success)
+
+	for _, s := range report.Series {
+		windows.WithLabelValues(s.Source, s.Tenant, resultExported).Set(float64(s.Exported))
+		windows.WithLabelValues(s.Source, s.Tenant, resultSkipped).Set(float64(s.Skipped))
+		windows.WithLabelValues(s.Source, s.Tenant, resultFailed).Set(float64(s.Failed))
+		rows.WithLabelValues(s.Source, s.Tenant).Set(float64(s.Rows))
+		bytes.WithLabelValues(s.Source, s.Tenant).Set(float64(s.Bytes))
+		lag.WithLabelValues(s.Source, s.Tenant).Set(s.LagSeconds)
+		sourceUp.WithLabelValues(s.Source).Set(1)
+	}
+	for _, source := range report.UnavailableSources {
+		sourceUp.WithLabelValues(source).Set(0)
+	}
+	duration.Set(report.DurationSeconds)
+	lastRun.Set(float64(report.FinishedAt.Unix()))
+	if report.Status == StatusSuccess {
+		success.Set(1)
+	}
+	return reg
+}
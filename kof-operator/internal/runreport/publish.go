@@ -0,0 +1,224 @@
+// Copyright 2025
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runreport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/golang/snappy"
+	s3pkg "github.com/k0rdent/kof/kof-operator/internal/s3"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// reportsPrefix is the S3 key prefix (under the exporter S3_PREFIX) holding run reports.
+const reportsPrefix = "_reports"
+
+// Config selects where run reports and metrics are published.
+type Config struct {
+	// Job names the exporter in metrics and report keys.
+	Job string
+	// S3Prefix is the exporter key prefix; reports are written under
+	// <S3Prefix>/_reports/<Job>/. Empty disables the report object.
+	S3Prefix string
+	// WriteReport enables the JSON report object in S3.
+	WriteReport bool
+	// PushgatewayURL is the base URL of a Prometheus Pushgateway (optional).
+	PushgatewayURL string
+	// RemoteWriteURL is a Prometheus remote-write endpoint (optional).
+	RemoteWriteURL string
+}
+
+// Pusher sends the metrics of a run to a metrics backend.
+type Pusher interface {
+	Push(ctx context.Context, job string, reg *prometheus.Registry) error
+}
+
+// Publisher writes the report of a run to S3 and pushes its metrics.
+type Publisher struct {
+	cfg     Config
+	s3      *s3pkg.Client
+	pushers []Pusher
+	log     logr.Logger
+}
+
+// NewPublisher creates a Publisher for cfg. s3client may be nil when the
+// report object is disabled.
+func NewPublisher(cfg Config, s3client *s3pkg.Client, log logr.Logger) *Publisher {
+	p := &Publisher{cfg: cfg, s3: s3client, log: log}
+	if cfg.PushgatewayURL != "" {
+		p.pushers = append(p.pushers, &PushgatewayPusher{URL: cfg.PushgatewayURL})
+	}
+	if cfg.RemoteWriteURL != "" {
+		p.pushers = append(p.pushers, &RemoteWritePusher{URL: cfg.RemoteWriteURL})
+	}
+	return p
+}
+
+// ReportKeys returns the keys a report is written to: a per-run object and
+// latest.json, which alerting can poll for the last run.
+func (p *Publisher) ReportKeys(report *Report) []string {
+	prefix := reportsPrefix + "/" + p.cfg.Job + "/"
+	if p.cfg.S3Prefix != "" {
+		prefix = p.cfg.S3Prefix + "/" + prefix
+	}
+	return []string{
+		prefix + report.StartedAt.Format("2006-01-02T15-04-05Z") + ".json",
+		prefix + "latest.json",
+	}
+}
+
+// Publish writes the report object and pushes the report metrics. Every
+// destination is attempted; the errors are joined. A nil Publisher publishes nothing.
+func (p *Publisher) Publish(ctx context.Context, report *Report) error {
+	if p == nil || report == nil {
+		return nil
+	}
+	var errs []error
+
+	if p.cfg.WriteReport && p.s3 != nil {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshal run report: %w", err)
+		}
+		for _, key := range p.ReportKeys(report) {
+			if err := p.s3.PutObject(ctx, key, data, "application/json"); err != nil {
+				errs = append(errs, fmt.Errorf("write run report: %w", err))
+				break
+			}
+		}
+	}
+
+	reg := NewRegistry(report)
+	for _, pusher := range p.pushers {
+		if err := pusher.Push(ctx, p.cfg.Job, reg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	p.log.Info("run report published", "status", report.Status, "pushers", len(p.pushers))
+	return nil
+}
+
+// PushgatewayPusher replaces the metrics of the job group in a Prometheus Pushgateway.
+type PushgatewayPusher struct {
+	URL    string
+	Client *http.Client
+}
+
+// Push implements Pusher.
+func (p *PushgatewayPusher) Push(ctx context.Context, job string, reg *prometheus.Registry) error {
+	pusher := push.New(p.URL, job).Gatherer(reg)
+	if p.Client != nil {
+		pusher = pusher.Client(p.Client)
+	}
+	if err := pusher.PushContext(ctx); err != nil {
+		return fmt.Errorf("push metrics to pushgateway: %w", err)
+	}
+	return nil
+}
+
+// RemoteWritePusher sends the metrics as one sample each to a Prometheus
+// remote-write (v1) endpoint, e.g. vminsert or vmagent.
+type RemoteWritePusher struct {
+	URL    string
+	Client *http.Client
+}
+
+// Push implements Pusher.
+func (p *RemoteWritePusher) Push(ctx context.Context, job string, reg *prometheus.Registry) error {
+	families, err := reg.Gather()
+	if err != nil {
+		return fmt.Errorf("gather metrics: %w", err)
+	}
+	data, err := EncodeRemoteWrite(families, job, time.Now())
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("build remote-write request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+
+	client := p.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("remote-write metrics: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("remote-write metrics: HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// EncodeRemoteWrite encodes gauge and counter families as a snappy-compressed
+// remote-write request with a job label and the timestamp ts.
+func EncodeRemoteWrite(families []*dto.MetricFamily, job string, ts time.Time) ([]byte, error) {
+	var wr prompb.WriteRequest
+	for _, mf := range families {
+		for _, m := range mf.GetMetric() {
+			var value float64
+			switch {
+			case m.GetGauge() != nil:
+				value = m.GetGauge().GetValue()
+			case m.GetCounter() != nil:
+				value = m.GetCounter().GetValue()
+			default:
+				continue
+			}
+			labels := []prompb.Label{
+				{Name: "__name__", Value: mf.GetName()},
+				{Name: "job", Value: job},
+			}
+			for _, l := range m.GetLabel() {
+				labels = append(labels, prompb.Label{Name: l.GetName(), Value: l.GetValue()})
+			}
+			slices.SortFunc(labels, func(a, b prompb.Label) int { return strings.Compare(a.Name, b.Name) })
+			wr.Timeseries = append(wr.Timeseries, prompb.TimeSeries{
+				Labels:  labels,
+				Samples: []prompb.Sample{{Value: value, Timestamp: ts.UnixMilli()}},
+			})
+		}
+	}
+	data, err := wr.Marshal()
+	if err != nil {
+		return nil, fmt.Errorf("marshal remote-write request: %w", err)
+	}
+	return snappy.Encode(nil, data), nil
+}
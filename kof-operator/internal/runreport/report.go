@@ -0,0 +1,209 @@
+// Copyright 2025
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package runreport records the outcome of an exporter CronJob run and
+// publishes it as Prometheus metrics and as a JSON report object in S3, so
+// that failed runs and silent export lag can be alerted on.
+package runreport
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// StatusSuccess is the status of a run that exported every pending window.
+	StatusSuccess = "success"
+	// StatusFailed is the status of a run that returned an error.
+	StatusFailed = "failed"
+)
+
+// Report is the outcome of one export run.
+type Report struct {
+	Job        string    `json:"job"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	// DurationSeconds is the wall-clock duration of the run.
+	DurationSeconds float64 `json:"duration_seconds"`
+	Status          string  `json:"status"`
+	Error           string  `json:"error,omitempty"`
+	// UnavailableSources were unreachable while collecting windows; their
+	// windows were not considered by the run.
+	UnavailableSources []string `json:"unavailable_sources,omitempty"`
+	// Series holds the per-source, per-tenant counters in sorted order.
+	Series []SeriesReport `json:"series"`
+}
+
+// SeriesReport counts the windows of one source and tenant processed by a run.
+type SeriesReport struct {
+	Source string `json:"source"`
+	Tenant string `json:"tenant"`
+	// Exported windows were written by this run.
+	Exported int `json:"exported"`
+	// Skipped windows had already been exported.
+	Skipped int `json:"skipped"`
+	// Failed windows will be retried by the next run.
+	Failed int   `json:"failed"`
+	Rows   int64 `json:"rows"`
+	Bytes  int64 `json:"bytes"`
+	// OldestPendingWindow is the start of the oldest window that is still
+	// not exported at the end of the run, nil when the series is caught up.
+	OldestPendingWindow *time.Time `json:"oldest_pending_window,omitempty"`
+	// LagSeconds is the age of OldestPendingWindow at the end of the run.
+	LagSeconds float64 `json:"lag_seconds"`
+}
+
+type seriesKey struct {
+	source, tenant string
+}
+
+// Recorder collects the window outcomes of a run. It is safe for concurrent
+// use; a nil Recorder discards all records.
+type Recorder struct {
+	job     string
+	started time.Time
+
+	mu          sync.Mutex
+	series      map[seriesKey]*SeriesReport
+	pending     map[seriesKey]map[time.Time]struct{}
+	unavailable []string
+}
+
+// NewRecorder starts recording a run of job.
+func NewRecorder(job string, started time.Time) *Recorder {
+	return &Recorder{
+		job:     job,
+		started: started.UTC(),
+		series:  make(map[seriesKey]*SeriesReport),
+		pending: make(map[seriesKey]map[time.Time]struct{}),
+	}
+}
+
+// get returns the counters of a series; r.mu must be held.
+func (r *Recorder) get(source, tenant string) *SeriesReport {
+	key := seriesKey{source, tenant}
+	s, ok := r.series[key]
+	if !ok {
+		s = &SeriesReport{Source: source, Tenant: tenant}
+		r.series[key] = s
+	}
+	return s
+}
+
+// Pending records a window found not yet exported.
+func (r *Recorder) Pending(source, tenant string, start time.Time) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.get(source, tenant)
+	key := seriesKey{source, tenant}
+	if r.pending[key] == nil {
+		r.pending[key] = make(map[time.Time]struct{})
+	}
+	r.pending[key][start.UTC()] = struct{}{}
+}
+
+// Exported records a window written by this run.
+func (r *Recorder) Exported(source, tenant string, start time.Time, rows, bytes int64) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.get(source, tenant)
+	s.Exported++
+	s.Rows += rows
+	s.Bytes += bytes
+	delete(r.pending[seriesKey{source, tenant}], start.UTC())
+}
+
+// Skipped records a window that had already been exported.
+func (r *Recorder) Skipped(source, tenant string, start time.Time) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.get(source, tenant).Skipped++
+	delete(r.pending[seriesKey{source, tenant}], start.UTC())
+}
+
+// Failed records a window that failed to export.
+func (r *Recorder) Failed(source, tenant string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.get(source, tenant).Failed++
+}
+
+// SourceUnavailable records a source that could not be queried for windows.
+func (r *Recorder) SourceUnavailable(source string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !slices.Contains(r.unavailable, source) {
+		r.unavailable = append(r.unavailable, source)
+	}
+}
+
+// Finish builds the report of the run, failed when err is not nil.
+func (r *Recorder) Finish(finished time.Time, err error) *Report {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	finished = finished.UTC()
+	report := &Report{
+		Job:                r.job,
+		StartedAt:          r.started,
+		FinishedAt:         finished,
+		DurationSeconds:    finished.Sub(r.started).Seconds(),
+		Status:             StatusSuccess,
+		UnavailableSources: slices.Sorted(slices.Values(r.unavailable)),
+		Series:             make([]SeriesReport, 0, len(r.series)),
+	}
+	if err != nil {
+		report.Status = StatusFailed
+		report.Error = err.Error()
+	}
+	for key, s := range r.series {
+		series := *s
+		for start := range r.pending[key] {
+			if series.OldestPendingWindow == nil || start.Before(*series.OldestPendingWindow) {
+				series.OldestPendingWindow = &start
+			}
+		}
+		if series.OldestPendingWindow != nil {
+			series.LagSeconds = finished.Sub(*series.OldestPendingWindow).Seconds()
+		}
+		report.Series = append(report.Series, series)
+	}
+	slices.SortFunc(report.Series, func(a, b SeriesReport) int {
+		if c := strings.Compare(a.Source, b.Source); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Tenant, b.Tenant)
+	})
+	return report
+}
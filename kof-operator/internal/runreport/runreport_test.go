@@ -0,0 +1,226 @@
+// Copyright 2025
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runreport_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/go-logr/logr"
+	"github.com/golang/snappy"
+	"github.com/k0rdent/kof/kof-operator/internal/runreport"
+	s3pkg "github.com/k0rdent/kof/kof-operator/internal/s3"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// putS3 implements s3pkg.RawAPI, recording PutObject calls.
+type putS3 struct {
+	objects map[string][]byte
+}
+
+func (m *putS3) GetObjectLockConfiguration(_ context.Context, _ *s3.GetObjectLockConfigurationInput, _ ...func(*s3.Options)) (*s3.GetObjectLockConfigurationOutput, error) {
+	panic("not implemented")
+}
+func (m *putS3) HeadObject(_ context.Context, _ *s3.HeadObjectInput, _ ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	panic("not implemented")
+}
+func (m *putS3) GetObject(_ context.Context, _ *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	panic("not implemented")
+}
+func (m *putS3) PutObject(_ context.Context, params *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	data, err := io.ReadAll(params.Body)
+	if err != nil {
+		return nil, err
+	}
+	m.objects[aws.ToString(params.Key)] = data
+	return &s3.PutObjectOutput{}, nil
+}
+func (m *putS3) ListObjectsV2(_ context.Context, _ *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	panic("not implemented")
+}
+func (m *putS3) ListObjectVersions(_ context.Context, _ *s3.ListObjectVersionsInput, _ ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error) {
+	panic("not implemented")
+}
+func (m *putS3) DeleteObjects(_ context.Context, _ *s3.DeleteObjectsInput, _ ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+	panic("not implemented")
+}
+
+var _ s3pkg.RawAPI = (*putS3)(nil)
+
+var _ = Describe("Recorder", func() {
+	started := time.Date(2025, 1, 1, 12, 5, 0, 0, time.UTC)
+	h := func(hour int) time.Time { return time.Date(2025, 1, 1, hour, 0, 0, 0, time.UTC) }
+
+	It("counts windows and reports the oldest pending window as lag", func() {
+		r := runreport.NewRecorder("test-job", started)
+		r.Skipped("metrics", "acme", h(8))
+		for hour := 9; hour <= 11; hour++ {
+			r.Pending("metrics", "acme", h(hour))
+		}
+		r.Pending("logs", "acme", h(11))
+
+		var wg sync.WaitGroup
+		wg.Go(func() { r.Exported("metrics", "acme", h(11), 10, 100) })
+		wg.Go(func() { r.Failed("metrics", "acme") })
+		wg.Go(func() { r.Exported("metrics", "acme", h(10), 5, 50) })
+		wg.Go(func() { r.Exported("logs", "acme", h(11), 7, 70) })
+		wg.Wait()
+		r.SourceUnavailable("traces")
+		r.SourceUnavailable("traces")
+
+		report := r.Finish(started.Add(time.Minute), errors.New("one or more windows failed"))
+		Expect(report.Status).To(Equal(runreport.StatusFailed))
+		Expect(report.DurationSeconds).To(Equal(60.0))
+		Expect(report.UnavailableSources).To(Equal([]string{"traces"}))
+		Expect(report.Series).To(HaveLen(2))
+
+		logs, metrics := report.Series[0], report.Series[1]
+		Expect(logs).To(Equal(runreport.SeriesReport{Source: "logs", Tenant: "acme", Exported: 1, Rows: 7, Bytes: 70}))
+		Expect(metrics.Exported).To(Equal(2))
+		Expect(metrics.Skipped).To(Equal(1))
+		Expect(metrics.Failed).To(Equal(1))
+		Expect(metrics.Rows).To(BeEquivalentTo(15))
+		Expect(metrics.Bytes).To(BeEquivalentTo(150))
+		Expect(*metrics.OldestPendingWindow).To(Equal(h(9)))
+		Expect(metrics.LagSeconds).To(Equal(started.Add(time.Minute).Sub(h(9)).Seconds()))
+	})
+
+	It("discards records when nil", func() {
+		var r *runreport.Recorder
+		r.Pending("metrics", "acme", h(9))
+		r.Exported("metrics", "acme", h(9), 1, 1)
+		Expect(r.Finish(started, nil)).To(BeNil())
+	})
+})
+
+var _ = Describe("Publisher", func() {
+	var (
+		ctx    context.Context
+		report *runreport.Report
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		r := runreport.NewRecorder("cold-storage-exporter", time.Date(2025, 1, 1, 12, 5, 0, 0, time.UTC))
+		r.Pending("metrics", "acme", time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC))
+		r.Exported("metrics", "acme", time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC), 5, 50)
+		r.SourceUnavailable("logs")
+		report = r.Finish(time.Date(2025, 1, 1, 12, 6, 0, 0, time.UTC), nil)
+	})
+
+	It("exposes the report as gauges", func() {
+		reg := runreport.NewRegistry(report)
+		Expect(testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP kof_export_lag_seconds Age of the oldest window not exported at the end of the last run, 0 when caught up.
+# TYPE kof_export_lag_seconds gauge
+kof_export_lag_seconds{source="metrics",tenant="acme"} 11160
+# HELP kof_export_source_up 0 if the source was unreachable while collecting windows in the last run, 1 otherwise.
+# TYPE kof_export_source_up gauge
+kof_export_source_up{source="logs"} 0
+kof_export_source_up{source="metrics"} 1
+# HELP kof_export_windows Number of windows processed by the last run, by result (exported, skipped, failed).
+# TYPE kof_export_windows gauge
+kof_export_windows{result="exported",source="metrics",tenant="acme"} 1
+kof_export_windows{result="failed",source="metrics",tenant="acme"} 0
+kof_export_windows{result="skipped",source="metrics",tenant="acme"} 0
+# HELP kof_export_last_run_success 1 if the last run succeeded, 0 otherwise.
+# TYPE kof_export_last_run_success gauge
+kof_export_last_run_success 1
+`), "kof_export_lag_seconds", "kof_export_source_up", "kof_export_windows", "kof_export_last_run_success")).To(Succeed())
+	})
+
+	It("writes the report to S3 and pushes to a Pushgateway and a remote-write endpoint", func() {
+		var (
+			mu       sync.Mutex
+			requests = map[string]*http.Request{}
+			bodies   = map[string][]byte{}
+		)
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			mu.Lock()
+			requests[r.URL.Path] = r
+			bodies[r.URL.Path] = body
+			mu.Unlock()
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer srv.Close()
+
+		store := &putS3{objects: map[string][]byte{}}
+		p := runreport.NewPublisher(runreport.Config{
+			Job:            "cold-storage-exporter",
+			S3Prefix:       "telemetry",
+			WriteReport:    true,
+			PushgatewayURL: srv.URL,
+			RemoteWriteURL: srv.URL + "/api/v1/write",
+		}, s3pkg.NewClientFromRaw(store, "bucket"), logr.Discard())
+		Expect(p.Publish(ctx, report)).To(Succeed())
+
+		Expect(store.objects).To(HaveKey("telemetry/_reports/cold-storage-exporter/2025-01-01T12-05-00Z.json"))
+		Expect(store.objects).To(HaveKey("telemetry/_reports/cold-storage-exporter/latest.json"))
+		var got runreport.Report
+		Expect(json.Unmarshal(store.objects["telemetry/_reports/cold-storage-exporter/latest.json"], &got)).To(Succeed())
+		Expect(got.Status).To(Equal(runreport.StatusSuccess))
+		Expect(got.Series[0].LagSeconds).To(Equal(11160.0))
+
+		pg := requests["/metrics/job/cold-storage-exporter"]
+		Expect(pg).NotTo(BeNil())
+		Expect(pg.Method).To(Equal(http.MethodPut))
+		Expect(string(bodies["/metrics/job/cold-storage-exporter"])).NotTo(BeEmpty())
+
+		rw := requests["/api/v1/write"]
+		Expect(rw).NotTo(BeNil())
+		Expect(rw.Header.Get("Content-Encoding")).To(Equal("snappy"))
+		data, err := snappy.Decode(nil, bodies["/api/v1/write"])
+		Expect(err).NotTo(HaveOccurred())
+		var wr prompb.WriteRequest
+		Expect(wr.Unmarshal(data)).To(Succeed())
+		var lag []prompb.TimeSeries
+		for _, ts := range wr.Timeseries {
+			for _, l := range ts.Labels {
+				if l.Name == "__name__" && l.Value == "kof_export_lag_seconds" {
+					lag = append(lag, ts)
+				}
+			}
+		}
+		Expect(lag).To(HaveLen(1))
+		Expect(lag[0].Labels).To(ContainElements(
+			prompb.Label{Name: "job", Value: "cold-storage-exporter"},
+			prompb.Label{Name: "tenant", Value: "acme"},
+		))
+		Expect(lag[0].Samples[0].Value).To(Equal(11160.0))
+	})
+
+	It("returns push failures", func() {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			http.Error(w, "out of capacity", http.StatusServiceUnavailable)
+		}))
+		defer srv.Close()
+
+		p := runreport.NewPublisher(runreport.Config{Job: "audit-logs-exporter", RemoteWriteURL: srv.URL}, nil, logr.Discard())
+		Expect(p.Publish(ctx, report)).To(MatchError(ContainSubstring("HTTP 503: out of capacity")))
+	})
+})
@@ -22,6 +22,9 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
@@ -42,8 +45,44 @@ type RawAPI interface {
 	HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
 	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
 	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+	ListObjectVersions(ctx context.Context, params *s3.ListObjectVersionsInput, optFns ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error)
+	DeleteObjects(ctx context.Context, params *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error)
 }
 
+// ObjectInfo describes a listed object.
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+// ObjectVersion describes a listed version of an object, or a delete marker.
+type ObjectVersion struct {
+	Key string
+	// VersionID is "null" for objects written while versioning was not enabled.
+	VersionID string
+	Size      int64
+	// DeleteMarker is true for the delete markers of a versioned bucket.
+	DeleteMarker bool
+}
+
+// ObjectLock describes the WORM protection of an object.
+type ObjectLock struct {
+	// RetainUntil is the end of the retention period, zero when none is set.
+	RetainUntil time.Time
+	// LegalHold is true when a legal hold is placed on the object.
+	LegalHold bool
+}
+
+// Locked reports whether the object cannot be deleted at now.
+func (l ObjectLock) Locked(now time.Time) bool {
+	return l.LegalHold || l.RetainUntil.After(now)
+}
+
+// maxDeleteObjects is the maximum number of keys accepted by a DeleteObjects call.
+const maxDeleteObjects = 1000
+
 // Config holds the S3-compatible storage configuration shared by all exporters.
 type Config struct {
 	Endpoint     string // base URL of the S3-compatible endpoint (empty → AWS default)
@@ -151,6 +190,157 @@ func (c *Client) ObjectExists(ctx context.Context, key string) (bool, error) {
 	return false, fmt.Errorf("HeadObject %q: %w", key, err)
 }
 
+// GetObject downloads an object and returns its raw bytes.
+// Returns (nil, nil) when the object does not exist.
+func (c *Client) GetObject(ctx context.Context, key string) ([]byte, error) {
+	out, err := c.rawClient.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var nsk *types.NoSuchKey
+		if errors.As(err, &nsk) {
+			return nil, nil
+		}
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) {
+			return nil, nil
+		}
+		// S3-compatible backends may surface a missing key as an untyped HTTP 404.
+		var respErr *smithyhttp.ResponseError
+		if errors.As(err, &respErr) && respErr.HTTPStatusCode() == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("GetObject %q: %w", key, err)
+	}
+	defer func() { _ = out.Body.Close() }()
+	return io.ReadAll(out.Body)
+}
+
+// ListKeys returns the keys of all objects under prefix in lexical order.
+func (c *Client) ListKeys(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	err := c.WalkObjects(ctx, prefix, func(obj ObjectInfo) error {
+		keys = append(keys, obj.Key)
+		return nil
+	})
+	return keys, err
+}
+
+// WalkObjects calls fn for every object under prefix in lexical key order,
+// one listing page at a time.
+func (c *Client) WalkObjects(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	paginator := s3.NewListObjectsV2Paginator(c.rawClient, &s3.ListObjectsV2Input{
+		Bucket: aws.String(c.bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("ListObjectsV2 %q: %w", prefix, err)
+		}
+		for _, obj := range page.Contents {
+			info := ObjectInfo{
+				Key:          aws.ToString(obj.Key),
+				Size:         aws.ToInt64(obj.Size),
+				LastModified: aws.ToTime(obj.LastModified),
+			}
+			if err := fn(info); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ListObjectVersions returns every version and delete marker of the objects
+// under prefix, in lexical key order. An unversioned bucket has a single
+// "null" version per object.
+func (c *Client) ListObjectVersions(ctx context.Context, prefix string) ([]ObjectVersion, error) {
+	var versions []ObjectVersion
+	input := &s3.ListObjectVersionsInput{
+		Bucket: aws.String(c.bucket),
+		Prefix: aws.String(prefix),
+	}
+	for {
+		page, err := c.rawClient.ListObjectVersions(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("ListObjectVersions %q: %w", prefix, err)
+		}
+		for _, v := range page.Versions {
+			versions = append(versions, ObjectVersion{
+				Key:       aws.ToString(v.Key),
+				VersionID: aws.ToString(v.VersionId),
+				Size:      aws.ToInt64(v.Size),
+			})
+		}
+		for _, m := range page.DeleteMarkers {
+			versions = append(versions, ObjectVersion{
+				Key:          aws.ToString(m.Key),
+				VersionID:    aws.ToString(m.VersionId),
+				DeleteMarker: true,
+			})
+		}
+		if !aws.ToBool(page.IsTruncated) {
+			break
+		}
+		input.KeyMarker = page.NextKeyMarker
+		input.VersionIdMarker = page.NextVersionIdMarker
+	}
+	slices.SortStableFunc(versions, func(a, b ObjectVersion) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+	return versions, nil
+}
+
+// GetObjectLock returns the object-lock retention and legal hold of a version
+// of an object, or of its current version when versionID is empty.
+func (c *Client) GetObjectLock(ctx context.Context, key, versionID string) (ObjectLock, error) {
+	input := &s3.HeadObjectInput{
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(key),
+	}
+	if versionID != "" {
+		input.VersionId = aws.String(versionID)
+	}
+	out, err := c.rawClient.HeadObject(ctx, input)
+	if err != nil {
+		return ObjectLock{}, fmt.Errorf("HeadObject %q: %w", key, err)
+	}
+	return ObjectLock{
+		RetainUntil: aws.ToTime(out.ObjectLockRetainUntilDate),
+		LegalHold:   out.ObjectLockLegalHoldStatus == types.ObjectLockLegalHoldStatusOn,
+	}, nil
+}
+
+// DeleteObjectVersions permanently deletes the given object versions and
+// delete markers in batches of up to 1000. Versions are deleted in the given
+// order, so callers can delete completion markers first. Deleting by version
+// is required on versioned buckets, where deleting by key only adds a delete
+// marker and keeps the data. The first per-version failure is returned as an
+// error.
+func (c *Client) DeleteObjectVersions(ctx context.Context, versions []ObjectVersion) error {
+	for batch := range slices.Chunk(versions, maxDeleteObjects) {
+		objects := make([]types.ObjectIdentifier, 0, len(batch))
+		for _, v := range batch {
+			objects = append(objects, types.ObjectIdentifier{Key: aws.String(v.Key), VersionId: aws.String(v.VersionID)})
+		}
+		out, err := c.rawClient.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(c.bucket),
+			Delete: &types.Delete{Objects: objects, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			return fmt.Errorf("DeleteObjects: %w", err)
+		}
+		if len(out.Errors) > 0 {
+			e := out.Errors[0]
+			return fmt.Errorf("DeleteObjects %q (version %s): %s: %s (%d versions failed)",
+				aws.ToString(e.Key), aws.ToString(e.VersionId), aws.ToString(e.Code), aws.ToString(e.Message), len(out.Errors))
+		}
+	}
+	return nil
+}
+
 // PutObject uploads data to the given key with the specified content type.
 func (c *Client) PutObject(ctx context.Context, key string, data []byte, contentType string) error {
 	_, err := c.rawClient.PutObject(ctx, &s3.PutObjectInput{
@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/k0rdent/kof/kof-operator/internal/server"
+	"github.com/k0rdent/kof/kof-operator/internal/server/helper"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// APIKeyPrefix starts the API keys, formatted as "kof_<secret name>_<random string>".
+	APIKeyPrefix = "kof_"
+	// APIKeyLabel marks the Secrets holding an API key, with the "true" value.
+	APIKeyLabel = "k0rdent.mirantis.com/kof-acl-api-key"
+
+	// APIKeyHashKey is the Secret key of the SHA-256 hex digest of the whole API key.
+	APIKeyHashKey = "hash"
+	// APIKeyTenantKey is the Secret key of the tenant the API key grants access to.
+	APIKeyTenantKey = "tenant"
+	// APIKeyExpiresKey is the optional Secret key of the RFC 3339 expiry time of the API key.
+	APIKeyExpiresKey = "expires"
+	// APIKeyPathPrefixesKey is the optional Secret key of the comma-separated
+	// path prefixes the API key is restricted to, e.g. "/v1/,/metrics/api/v1/write".
+	APIKeyPathPrefixesKey = "pathPrefixes"
+
+	// DefaultAPIKeyCacheTTL is the time the API key Secrets are cached.
+	DefaultAPIKeyCacheTTL = 30 * time.Second
+)
+
+// APIKeyAuthenticator verifies API keys against the hashes stored in the
+// labeled Secrets of a namespace. An API key grants access to a single tenant,
+// until it expires and to the allowed path prefixes only.
+type APIKeyAuthenticator struct {
+	client    kubernetes.Interface
+	namespace string
+	now       func() time.Time
+	cache     *ttlCache[*apiKey]
+}
+
+type apiKey struct {
+	hash         string
+	tenant       string
+	expires      time.Time
+	pathPrefixes []string
+}
+
+// NewAPIKeyAuthenticator creates an APIKeyAuthenticator reading the Secrets of namespace.
+func NewAPIKeyAuthenticator(client kubernetes.Interface, namespace string, cacheTTL time.Duration) *APIKeyAuthenticator {
+	return &APIKeyAuthenticator{
+		client:    client,
+		namespace: namespace,
+		now:       time.Now,
+		cache:     newTTLCache[*apiKey](cacheTTL),
+	}
+}
+
+// HashAPIKey returns the hash of an API key stored under APIKeyHashKey.
+func HashAPIKey(key string) string {
+	return tokenHash(key)
+}
+
+// Authenticate verifies an API key and stores its tenant in the context under
+// helper.PrincipalContextKey.
+func (a *APIKeyAuthenticator) Authenticate(req *http.Request, token string) (context.Context, error) {
+	rest, ok := strings.CutPrefix(token, APIKeyPrefix)
+	if !ok {
+		return nil, server.ErrUnsupportedToken
+	}
+	name, _, ok := strings.Cut(rest, "_")
+	if !ok || name == "" {
+		return nil, fmt.Errorf("malformed API key")
+	}
+
+	ctx := req.Context()
+	key, ok := a.cache.get(name)
+	if !ok {
+		var err error
+		if key, err = a.lookup(ctx, name); err != nil {
+			return nil, err
+		}
+		a.cache.set(name, key)
+	}
+
+	if key == nil || subtle.ConstantTimeCompare([]byte(key.hash), []byte(HashAPIKey(token))) != 1 {
+		return nil, fmt.Errorf("invalid API key %q", name)
+	}
+	if !key.expires.IsZero() && !a.now().Before(key.expires) {
+		return nil, fmt.Errorf("API key %q expired at %s", name, key.expires.Format(time.RFC3339))
+	}
+	if !key.allows(req.URL.Path) {
+		return nil, fmt.Errorf("%w: API key %q does not allow the path %q", server.ErrForbidden, name, req.URL.Path)
+	}
+
+	return context.WithValue(ctx, helper.PrincipalContextKey, &helper.Principal{
+		Name:    "apikey:" + a.namespace + "/" + name,
+		Tenants: []string{key.tenant},
+	}), nil
+}
+
+// lookup reads the API key stored in the named Secret, nil when there is no
+// such API key Secret.
+func (a *APIKeyAuthenticator) lookup(ctx context.Context, name string) (*apiKey, error) {
+	secret, err := a.client.CoreV1().Secrets(a.namespace).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API key secret %q: %w", name, err)
+	}
+	if secret.Labels[APIKeyLabel] != "true" {
+		return nil, nil
+	}
+	return parseAPIKey(secret)
+}
+
+func parseAPIKey(secret *corev1.Secret) (*apiKey, error) {
+	key := &apiKey{
+		hash:   strings.ToLower(strings.TrimSpace(string(secret.Data[APIKeyHashKey]))),
+		tenant: strings.TrimSpace(string(secret.Data[APIKeyTenantKey])),
+	}
+	if key.hash == "" || key.tenant == "" {
+		return nil, fmt.Errorf("API key secret %q must have the %q and %q keys", secret.Name, APIKeyHashKey, APIKeyTenantKey)
+	}
+	if expires := strings.TrimSpace(string(secret.Data[APIKeyExpiresKey])); expires != "" {
+		var err error
+		if key.expires, err = time.Parse(time.RFC3339, expires); err != nil {
+			return nil, fmt.Errorf("invalid %q of API key secret %q: %w", APIKeyExpiresKey, secret.Name, err)
+		}
+	}
+	for prefix := range strings.SplitSeq(string(secret.Data[APIKeyPathPrefixesKey]), ",") {
+		if prefix = strings.TrimSpace(prefix); prefix != "" {
+			key.pathPrefixes = append(key.pathPrefixes, prefix)
+		}
+	}
+	return key, nil
+}
+
+// allows reports whether the API key may access path; any path is allowed
+// when no prefix is set. Prefixes match whole path segments: the prefix
+// /metrics/api/v1/write allows that path and the paths below it, but not
+// /metrics/api/v1/write_other.
+func (k *apiKey) allows(requestPath string) bool {
+	if len(k.pathPrefixes) == 0 {
+		return true
+	}
+	requestPath = path.Clean("/" + requestPath)
+	for _, prefix := range k.pathPrefixes {
+		rest, ok := strings.CutPrefix(requestPath, prefix)
+		if ok && (rest == "" || strings.HasSuffix(prefix, "/") || strings.HasPrefix(rest, "/")) {
+			return true
+		}
+	}
+	return false
+}
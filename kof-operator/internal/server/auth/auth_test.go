@@ -0,0 +1,303 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/k0rdent/kof/kof-operator/internal/server"
+	"github.com/k0rdent/kof/kof-operator/internal/server/helper"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// testIssuer is an OIDC issuer serving its discovery document and signing keys.
+type testIssuer struct {
+	*httptest.Server
+	signer jose.Signer
+}
+
+func newTestIssuer() *testIssuer {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	Expect(err).NotTo(HaveOccurred())
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.RS256, Key: jose.JSONWebKey{Key: privateKey, KeyID: "test"}},
+		(&jose.SignerOptions{}).WithType("JWT"),
+	)
+	Expect(err).NotTo(HaveOccurred())
+
+	issuer := &testIssuer{signer: signer}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                                issuer.URL,
+			"jwks_uri":                              issuer.URL + "/keys",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+			{Key: &privateKey.PublicKey, KeyID: "test", Algorithm: "RS256", Use: "sig"},
+		}})
+	})
+	issuer.Server = httptest.NewServer(mux)
+	return issuer
+}
+
+func (i *testIssuer) token(audience string) string {
+	now := time.Now()
+	raw, err := jwt.Signed(i.signer).Claims(jwt.Claims{
+		Subject:  "user",
+		Issuer:   i.URL,
+		Audience: jwt.Audience{audience},
+		Expiry:   jwt.NewNumericDate(now.Add(time.Hour)),
+		IssuedAt: jwt.NewNumericDate(now),
+	}).Claims(map[string]any{"email": "user@example.com"}).Serialize()
+	Expect(err).NotTo(HaveOccurred())
+	return raw
+}
+
+var _ = Describe("OIDCAuthenticator", func() {
+	var (
+		first, second *testIssuer
+		authenticator *OIDCAuthenticator
+	)
+
+	BeforeEach(func() {
+		first, second = newTestIssuer(), newTestIssuer()
+		var err error
+		authenticator, err = NewOIDCAuthenticator(context.Background(),
+			[]string{first.URL, second.URL}, []string{"grafana-id", "ci"})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		first.Close()
+		second.Close()
+	})
+
+	It("should verify the ID tokens of every issuer and accepted audience", func() {
+		for _, token := range []string{first.token("grafana-id"), second.token("ci")} {
+			ctx, err := authenticator.Authenticate(httptest.NewRequest(http.MethodGet, "/", nil), token)
+			Expect(err).NotTo(HaveOccurred())
+			idToken, ok := helper.GetIDToken(ctx)
+			Expect(ok).To(BeTrue())
+			Expect(idToken.Subject).To(Equal("user"))
+		}
+	})
+
+	It("should reject the ID tokens of other audiences", func() {
+		_, err := authenticator.Authenticate(httptest.NewRequest(http.MethodGet, "/", nil), first.token("other"))
+		Expect(err).To(MatchError(ContainSubstring("not accepted")))
+	})
+
+	It("should not support the tokens of other issuers or non-JWT tokens", func() {
+		other := newTestIssuer()
+		defer other.Close()
+
+		_, err := authenticator.Authenticate(httptest.NewRequest(http.MethodGet, "/", nil), other.token("ci"))
+		Expect(err).To(MatchError(server.ErrUnsupportedToken))
+		_, err = authenticator.Authenticate(httptest.NewRequest(http.MethodGet, "/", nil), "kof_key_secret")
+		Expect(err).To(MatchError(server.ErrUnsupportedToken))
+	})
+})
+
+var _ = Describe("ServiceAccountAuthenticator", func() {
+	var (
+		client  *fake.Clientset
+		reviews int
+	)
+
+	BeforeEach(func() {
+		reviews = 0
+		client = fake.NewClientset()
+		client.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			reviews++
+			review := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+			switch review.Spec.Token {
+			case "sa.token.valid":
+				review.Status = authenticationv1.TokenReviewStatus{
+					Authenticated: true,
+					Audiences:     []string{"kof-acl"},
+					User: authenticationv1.UserInfo{
+						Username: "system:serviceaccount:team-a:ci",
+						UID:      "uid",
+						Groups:   []string{"system:serviceaccounts", "system:serviceaccounts:team-a"},
+					},
+				}
+			case "user.token.valid":
+				review.Status = authenticationv1.TokenReviewStatus{
+					Authenticated: true,
+					Audiences:     []string{"kof-acl"},
+					User:          authenticationv1.UserInfo{Username: "admin"},
+				}
+			default:
+				review.Status = authenticationv1.TokenReviewStatus{Error: "invalid bearer token"}
+			}
+			return true, review, nil
+		})
+	})
+
+	It("should resolve and cache the ServiceAccount of a token", func() {
+		authenticator := NewServiceAccountAuthenticator(client, []string{"kof-acl"}, time.Minute)
+
+		for range 2 {
+			ctx, err := authenticator.Authenticate(httptest.NewRequest(http.MethodGet, "/", nil), "sa.token.valid")
+			Expect(err).NotTo(HaveOccurred())
+			principal, ok := helper.GetPrincipal(ctx)
+			Expect(ok).To(BeTrue())
+			Expect(principal.Name).To(Equal("system:serviceaccount:team-a:ci"))
+			Expect(principal.Claims).To(HaveKeyWithValue("email", "system:serviceaccount:team-a:ci"))
+			Expect(principal.Claims).To(HaveKeyWithValue("groups", ContainElement("system:serviceaccounts:team-a")))
+		}
+		Expect(reviews).To(Equal(1))
+	})
+
+	It("should reject unauthenticated tokens, other users and other audiences", func() {
+		authenticator := NewServiceAccountAuthenticator(client, []string{"kof-acl"}, time.Minute)
+		_, err := authenticator.Authenticate(httptest.NewRequest(http.MethodGet, "/", nil), "sa.token.invalid")
+		Expect(err).To(MatchError(ContainSubstring("invalid bearer token")))
+		_, err = authenticator.Authenticate(httptest.NewRequest(http.MethodGet, "/", nil), "user.token.valid")
+		Expect(err).To(MatchError(ContainSubstring("not a ServiceAccount token")))
+
+		authenticator = NewServiceAccountAuthenticator(client, []string{"other"}, time.Minute)
+		_, err = authenticator.Authenticate(httptest.NewRequest(http.MethodGet, "/", nil), "sa.token.valid")
+		Expect(err).To(MatchError(ContainSubstring("not accepted")))
+	})
+
+	It("should not support non-JWT tokens", func() {
+		authenticator := NewServiceAccountAuthenticator(client, nil, time.Minute)
+		_, err := authenticator.Authenticate(httptest.NewRequest(http.MethodGet, "/", nil), "kof_key_secret")
+		Expect(err).To(MatchError(server.ErrUnsupportedToken))
+		Expect(reviews).To(BeZero())
+	})
+})
+
+var _ = Describe("APIKeyAuthenticator", func() {
+	const key = "kof_ci-key_s3cr3t_value"
+
+	var authenticator *APIKeyAuthenticator
+
+	apiKeySecret := func(name string, data map[string]string) *corev1.Secret {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "kof",
+				Labels:    map[string]string{APIKeyLabel: "true"},
+			},
+			Data: map[string][]byte{},
+		}
+		for k, v := range data {
+			secret.Data[k] = []byte(v)
+		}
+		return secret
+	}
+
+	authenticate := func(path, token string) (*helper.Principal, error) {
+		ctx, err := authenticator.Authenticate(httptest.NewRequest(http.MethodPost, path, nil), token)
+		if err != nil {
+			return nil, err
+		}
+		principal, ok := helper.GetPrincipal(ctx)
+		Expect(ok).To(BeTrue())
+		return principal, nil
+	}
+
+	BeforeEach(func() {
+		unlabeled := apiKeySecret("unlabeled", map[string]string{
+			APIKeyHashKey: HashAPIKey("kof_unlabeled_x"), APIKeyTenantKey: "a",
+		})
+		unlabeled.Labels = nil
+		client := fake.NewClientset(
+			apiKeySecret("ci-key", map[string]string{
+				APIKeyHashKey:         HashAPIKey(key),
+				APIKeyTenantKey:       "team-a",
+				APIKeyPathPrefixesKey: "/v1/, /metrics/api/v1/write",
+			}),
+			apiKeySecret("expired", map[string]string{
+				APIKeyHashKey:    HashAPIKey("kof_expired_x"),
+				APIKeyTenantKey:  "team-a",
+				APIKeyExpiresKey: "2020-01-01T00:00:00Z",
+			}),
+			unlabeled,
+		)
+		authenticator = NewAPIKeyAuthenticator(client, "kof", time.Minute)
+	})
+
+	It("should grant the tenant of a valid API key on its path prefixes", func() {
+		principal, err := authenticate("/v1/metrics", key)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(principal.Name).To(Equal("apikey:kof/ci-key"))
+		Expect(principal.Tenants).To(Equal([]string{"team-a"}))
+
+		_, err = authenticate("/metrics/api/v1/write", key)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should forbid the paths outside of the prefixes", func() {
+		for _, path := range []string{
+			"/logs/select/logsql/query",
+			"/metrics/api/v1/write_other",
+			"/metrics/api/v1/writes",
+			"/metrics/api/v1/write/../query",
+			"/v1",
+		} {
+			_, err := authenticate(path, key)
+			Expect(errors.Is(err, server.ErrForbidden)).To(BeTrue(), path)
+		}
+
+		_, err := authenticate("/metrics/api/v1/write/sub", key)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should reject wrong, unknown, unlabeled and expired API keys", func() {
+		for _, token := range []string{"kof_ci-key_wrong", "kof_missing_x", "kof_unlabeled_x", "kof_expired_x", "kof_"} {
+			_, err := authenticate("/v1/metrics", token)
+			Expect(err).To(HaveOccurred(), token)
+			Expect(errors.Is(err, server.ErrUnsupportedToken)).To(BeFalse(), token)
+		}
+	})
+
+	It("should be chained with the other authenticators by the middleware", func() {
+		handler := server.AuthenticationMiddleware(server.AuthConfig{
+			Authenticators: []server.Authenticator{
+				NewServiceAccountAuthenticator(fake.NewClientset(), nil, time.Minute),
+				authenticator,
+			},
+		})(func(res *server.Response, req *http.Request) {
+			principal, ok := helper.GetPrincipal(req.Context())
+			Expect(ok).To(BeTrue())
+			res.Send([]byte(principal.Tenants[0]), http.StatusOK)
+		})
+
+		serve := func(path, token string) *httptest.ResponseRecorder {
+			logger := ctrl.Log.WithName("test")
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, path, nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			handler(&server.Response{Writer: recorder, Logger: &logger}, req)
+			return recorder
+		}
+
+		recorder := serve("/v1/logs", key)
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Body.String()).To(Equal("team-a"))
+		Expect(serve("/logs/select/logsql/query", key).Code).To(Equal(http.StatusForbidden))
+		Expect(serve("/v1/logs", "kof_ci-key_wrong").Code).To(Equal(http.StatusUnauthorized))
+		Expect(serve("/v1/logs", "opaque").Code).To(Equal(http.StatusUnauthorized))
+	})
+})
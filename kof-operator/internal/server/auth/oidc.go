@@ -0,0 +1,94 @@
+// Package auth implements the authenticators of the bearer tokens accepted by
+// server.AuthenticationMiddleware: OIDC ID tokens of several issuers,
+// Kubernetes ServiceAccount tokens and API keys stored in Secrets.
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/k0rdent/kof/kof-operator/internal/server"
+	"github.com/k0rdent/kof/kof-operator/internal/server/helper"
+)
+
+// OIDCAuthenticator verifies the ID tokens of several OIDC issuers. The token
+// is verified by the provider of its issuer, and must be issued for one of the
+// accepted audiences.
+type OIDCAuthenticator struct {
+	verifiers map[string]*oidc.IDTokenVerifier
+	audiences []string
+}
+
+// NewOIDCAuthenticator discovers the providers of the issuers. The ctx may
+// carry the HTTP client used to fetch the discovery documents and the keys,
+// see oidc.ClientContext.
+func NewOIDCAuthenticator(ctx context.Context, issuers, audiences []string) (*OIDCAuthenticator, error) {
+	if len(issuers) == 0 {
+		return nil, fmt.Errorf("at least one OIDC issuer is required")
+	}
+	if len(audiences) == 0 {
+		return nil, fmt.Errorf("at least one OIDC audience is required")
+	}
+
+	verifiers := make(map[string]*oidc.IDTokenVerifier, len(issuers))
+	for _, issuer := range issuers {
+		provider, err := oidc.NewProvider(ctx, issuer)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize OIDC provider %q: %w", issuer, err)
+		}
+		// The audience is checked against all accepted audiences after verification.
+		verifiers[issuer] = provider.Verifier(&oidc.Config{SkipClientIDCheck: true})
+	}
+	return &OIDCAuthenticator{verifiers: verifiers, audiences: audiences}, nil
+}
+
+// Authenticate verifies an ID token of one of the issuers and stores it in the
+// context under helper.IdTokenContextKey.
+func (a *OIDCAuthenticator) Authenticate(req *http.Request, token string) (context.Context, error) {
+	issuer, ok := unverifiedIssuer(token)
+	if !ok {
+		return nil, server.ErrUnsupportedToken
+	}
+	verifier, ok := a.verifiers[issuer]
+	if !ok {
+		return nil, server.ErrUnsupportedToken
+	}
+
+	ctx := req.Context()
+	idToken, err := verifier.Verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.ContainsFunc(idToken.Audience, func(audience string) bool {
+		return slices.Contains(a.audiences, audience)
+	}) {
+		return nil, fmt.Errorf("ID token audiences %v are not accepted", idToken.Audience)
+	}
+	return context.WithValue(ctx, helper.IdTokenContextKey, idToken), nil
+}
+
+// unverifiedIssuer returns the "iss" claim of a JWT, without verifying it, to
+// select the verifier of the token.
+func unverifiedIssuer(token string) (string, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", false
+	}
+	var claims struct {
+		Issuer string `json:"iss"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Issuer == "" {
+		return "", false
+	}
+	return claims.Issuer, true
+}
@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/k0rdent/kof/kof-operator/internal/server"
+	"github.com/k0rdent/kof/kof-operator/internal/server/helper"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	serviceAccountUsernamePrefix = "system:serviceaccount:"
+	// DefaultTokenReviewCacheTTL is the time the results of the TokenReviews are cached,
+	// which delays the revocation of a token deleted with its ServiceAccount.
+	DefaultTokenReviewCacheTTL = time.Minute
+)
+
+// ServiceAccountAuthenticator verifies Kubernetes ServiceAccount tokens
+// through the TokenReview API. The tenants of the ServiceAccount are resolved
+// from the "email" claim "system:serviceaccount:<namespace>:<name>" and the
+// "groups" claim, e.g. "system:serviceaccounts:<namespace>".
+type ServiceAccountAuthenticator struct {
+	client    kubernetes.Interface
+	audiences []string
+	cache     *ttlCache[tokenReviewResult]
+}
+
+type tokenReviewResult struct {
+	principal *helper.Principal
+	err       error
+}
+
+// NewServiceAccountAuthenticator creates a ServiceAccountAuthenticator. The
+// tokens must be issued for one of the audiences, or for the audiences of the
+// API server when empty.
+func NewServiceAccountAuthenticator(client kubernetes.Interface, audiences []string, cacheTTL time.Duration) *ServiceAccountAuthenticator {
+	return &ServiceAccountAuthenticator{
+		client:    client,
+		audiences: audiences,
+		cache:     newTTLCache[tokenReviewResult](cacheTTL),
+	}
+}
+
+// Authenticate reviews a JWT and stores the ServiceAccount it was issued to in
+// the context under helper.PrincipalContextKey.
+func (a *ServiceAccountAuthenticator) Authenticate(req *http.Request, token string) (context.Context, error) {
+	if strings.Count(token, ".") != 2 {
+		return nil, server.ErrUnsupportedToken
+	}
+
+	ctx := req.Context()
+	key := tokenHash(token)
+	result, ok := a.cache.get(key)
+	if !ok {
+		var err error
+		if result, err = a.review(ctx, token); err != nil {
+			return nil, err
+		}
+		a.cache.set(key, result)
+	}
+	if result.err != nil {
+		return nil, result.err
+	}
+	return context.WithValue(ctx, helper.PrincipalContextKey, result.principal), nil
+}
+
+// review returns the cacheable result of the TokenReview of token, or an
+// error when the review itself failed.
+func (a *ServiceAccountAuthenticator) review(ctx context.Context, token string) (tokenReviewResult, error) {
+	review, err := a.client.AuthenticationV1().TokenReviews().Create(ctx, &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token, Audiences: a.audiences},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return tokenReviewResult{}, fmt.Errorf("failed to review the ServiceAccount token: %w", err)
+	}
+
+	status := review.Status
+	switch {
+	case !status.Authenticated:
+		return tokenReviewResult{err: fmt.Errorf("ServiceAccount token is not authenticated: %s", status.Error)}, nil
+	case !strings.HasPrefix(status.User.Username, serviceAccountUsernamePrefix):
+		return tokenReviewResult{err: fmt.Errorf("token of %q is not a ServiceAccount token", status.User.Username)}, nil
+	case len(a.audiences) > 0 && !slices.ContainsFunc(status.Audiences, func(audience string) bool {
+		return slices.Contains(a.audiences, audience)
+	}):
+		return tokenReviewResult{err: fmt.Errorf("ServiceAccount token audiences %v are not accepted", status.Audiences)}, nil
+	}
+
+	groups := make([]any, 0, len(status.User.Groups))
+	for _, group := range status.User.Groups {
+		groups = append(groups, group)
+	}
+	return tokenReviewResult{principal: &helper.Principal{
+		Name: status.User.Username,
+		Claims: map[string]any{
+			"sub":    status.User.UID,
+			"email":  status.User.Username,
+			"groups": groups,
+		},
+	}}, nil
+}
@@ -10,7 +10,10 @@ import (
 
 type ContextKey string
 
-const IdTokenContextKey ContextKey = "idToken"
+const (
+	IdTokenContextKey   ContextKey = "idToken"
+	PrincipalContextKey ContextKey = "principal"
+)
 
 func GetJwtTokenFromHeader(req *http.Request) string {
 	authHeader := req.Header.Get("Authorization")
@@ -24,3 +27,19 @@ func GetIDToken(ctx context.Context) (*oidc.IDToken, bool) {
 	idToken, ok := ctx.Value(IdTokenContextKey).(*oidc.IDToken)
 	return idToken, ok
 }
+
+// Principal is a machine client authenticated without an OIDC ID token,
+// e.g. with a ServiceAccount token or an API key.
+type Principal struct {
+	// Name identifies the client, e.g. "system:serviceaccount:<namespace>:<name>".
+	Name string
+	// Claims are resolved to tenants like the claims of an ID token.
+	Claims map[string]any
+	// Tenants, when set, are the only tenants the client may access, regardless of the claims.
+	Tenants []string
+}
+
+func GetPrincipal(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(PrincipalContextKey).(*Principal)
+	return principal, ok
+}
@@ -2,12 +2,12 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
 	"time"
 
-	"github.com/coreos/go-oidc/v3/oidc"
 	"github.com/k0rdent/kof/kof-operator/internal/server/helper"
 	"github.com/k0rdent/kof/kof-operator/internal/strutil"
 	"go.opentelemetry.io/otel/attribute"
@@ -25,14 +25,29 @@ type CORSConfig struct {
 }
 
 type AuthConfig struct {
-	Provider *oidc.Provider
-	ClientID string
+	// Authenticators verify the bearer token in order, until one of them supports it.
+	Authenticators []Authenticator
 	// ATTENTION: Do not use this in production!
 	// This will skip authentication if no token is provided.
 	// Useful for development and testing purposes only.
 	SkipOnEmptyToken bool
 }
 
+var (
+	// ErrUnsupportedToken is returned by an Authenticator when the token is not of its kind,
+	// so that the next one is tried.
+	ErrUnsupportedToken = errors.New("unsupported token")
+	// ErrForbidden is wrapped by the errors of an Authenticator when the token is valid
+	// but does not grant access to the request.
+	ErrForbidden = errors.New("forbidden")
+)
+
+// Authenticator verifies the bearer tokens of one kind, e.g. OIDC ID tokens or API keys.
+type Authenticator interface {
+	// Authenticate returns the context of req carrying the authenticated client.
+	Authenticate(req *http.Request, token string) (context.Context, error)
+}
+
 func Chain(h Handler, middleware ...Middleware) Handler {
 	for i := len(middleware) - 1; i >= 0; i-- {
 		h = middleware[i](h)
@@ -135,7 +150,6 @@ func DefaultCORSConfig() *CORSConfig {
 func AuthenticationMiddleware(config AuthConfig) Middleware {
 	return func(next Handler) Handler {
 		return func(res *Response, req *http.Request) {
-			ctx := req.Context()
 			jwtToken := helper.GetJwtTokenFromHeader(req)
 
 			if jwtToken == "" && config.SkipOnEmptyToken {
@@ -148,20 +162,24 @@ func AuthenticationMiddleware(config AuthConfig) Middleware {
 				return
 			}
 
-			verifier := config.Provider.Verifier(&oidc.Config{
-				ClientID: config.ClientID,
-			})
-
-			idToken, err := verifier.Verify(ctx, jwtToken)
-			if err != nil {
-				res.Fail("Unauthorized", http.StatusUnauthorized)
+			for _, authenticator := range config.Authenticators {
+				ctx, err := authenticator.Authenticate(req, jwtToken)
+				switch {
+				case errors.Is(err, ErrUnsupportedToken):
+					continue
+				case errors.Is(err, ErrForbidden):
+					res.Fail(err.Error(), http.StatusForbidden)
+					return
+				case err != nil:
+					res.Logger.V(1).Info("Authentication failed", "error", err.Error())
+					res.Fail("Unauthorized", http.StatusUnauthorized)
+					return
+				}
+				next(res, req.WithContext(ctx))
 				return
 			}
 
-			ctx = context.WithValue(ctx, helper.IdTokenContextKey, idToken)
-			req = req.WithContext(ctx)
-
-			next(res, req)
+			res.Fail("Unauthorized", http.StatusUnauthorized)
 		}
 	}
 }